@@ -0,0 +1,215 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+)
+
+// analyzeExtensions are the frames AnalyzeDirectory reads.
+var analyzeExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true}
+
+// AnalyzeOptions are for AnalyzeDirectory.
+type AnalyzeOptions struct {
+	// Pieces is the piece finder config to analyze the frames with, the board finder options
+	// and grid included. nil is the defaults.
+	Pieces *PieceFinderConfig
+	// Camera is what took the frames, for which of a paired point cloud lands on which square.
+	Camera camera.Properties
+	// Workers is how many frames are analyzed at once, 0 for one per CPU.
+	Workers int
+}
+
+// BoardAnalysis is what AnalyzeDirectory makes of a frame.
+type BoardAnalysis struct {
+	Board FindBoardResult
+	// Quality is ScoreCorners for the corners found, 0 when the board isn't.
+	Quality float64
+	// PointCloud is the .pcd file paired with the frame, "" if there isn't one.
+	PointCloud string
+	// Squares is what's on each square, in findBoardAndPieces order, when there's a point
+	// cloud.
+	Squares []Square
+	// Latency is how long the frame took, reading the files included.
+	Latency time.Duration
+}
+
+// AnalyzeStats are the totals over the frames AnalyzeDirectory went through.
+type AnalyzeStats struct {
+	Frames int
+	// Succeeded is how many frames had no error, so the board was found.
+	Succeeded   int
+	SuccessRate float64
+	// MeanQuality is over the frames that succeeded.
+	MeanQuality float64
+	MeanLatency time.Duration
+}
+
+// AnalyzeDirectory finds the board, and the pieces if a <name>.pcd is next to it, in each
+// image in dir, Workers at a time. fn gets every frame in name order, err being why it couldn't
+// be analyzed, not finding the board included. fn returning an error, or ctx being done, stops
+// the walk with that error.
+func AnalyzeDirectory(ctx context.Context, dir string, opts AnalyzeOptions, fn func(name string, result BoardAnalysis, err error) error) (AnalyzeStats, error) {
+	var stats AnalyzeStats
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return stats, err
+	}
+
+	conf := opts.Pieces
+	if conf == nil {
+		conf = &PieceFinderConfig{}
+	}
+	bopts, err := conf.boardFinderOptions()
+	if err != nil {
+		return stats, err
+	}
+
+	// ReadDir is sorted by name already
+	files := map[string]bool{}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		files[e.Name()] = true
+		if analyzeExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			names = append(names, e.Name())
+		}
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	type result struct {
+		analysis BoardAnalysis
+		err      error
+	}
+	results := make([]chan result, len(names))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	// at most 2*workers frames are analyzed ahead of fn, so a slow fn doesn't pile them up
+	ahead := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pcd := ""
+				if base := strings.TrimSuffix(names[i], filepath.Ext(names[i])) + ".pcd"; files[base] {
+					pcd = base
+				}
+				a, err := analyzeFrame(ctx, dir, names[i], pcd, conf, bopts, opts.Camera)
+				results[i] <- result{a, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range names {
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	quality, latency := 0.0, time.Duration(0)
+	for i, name := range names {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return stats, ctx.Err()
+		}
+		<-ahead
+		if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+
+		stats.Frames++
+		latency += r.analysis.Latency
+		if r.err == nil {
+			stats.Succeeded++
+			quality += r.analysis.Quality
+		}
+		err := fn(name, r.analysis, r.err)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	if stats.Frames > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(stats.Frames)
+		stats.MeanLatency = latency / time.Duration(stats.Frames)
+	}
+	if stats.Succeeded > 0 {
+		stats.MeanQuality = quality / float64(stats.Succeeded)
+	}
+	return stats, nil
+}
+
+// analyzeFrame is AnalyzeDirectory for dir/name, and dir/pcd when it isn't "".
+func analyzeFrame(ctx context.Context, dir, name, pcd string, conf *PieceFinderConfig, opts BoardFinderOptions, props camera.Properties) (res BoardAnalysis, err error) {
+	start := time.Now()
+	res.PointCloud = pcd
+	defer func() { res.Latency = time.Since(start) }()
+
+	img, err := rimage.ReadImageFromFile(filepath.Join(dir, name))
+	if err != nil {
+		return res, err
+	}
+
+	res.Board = detectBoardCtx(ctx, img, opts, nil)
+	if !res.Board.Found {
+		if res.Board.Err != nil {
+			return res, fmt.Errorf("board not found (%s): %w", res.Board.Reason, res.Board.Err)
+		}
+		return res, fmt.Errorf("board not found (%s)", res.Board.Reason)
+	}
+	res.Quality, err = scoreCorners(img, res.Board.SubPixelCorners, opts.gridSize())
+	if err != nil {
+		return res, err
+	}
+
+	if pcd == "" {
+		return res, nil
+	}
+	pc, err := pointcloud.NewFromFile(filepath.Join(dir, pcd), "")
+	if err != nil {
+		return res, err
+	}
+	squares, err := squaresOnBoard(nil, res.Board.Board, shakyCorners(res.Board.CornerConfidence, conf), img, pc, props, conf, opts.gridSize())
+	if err != nil {
+		return res, err
+	}
+	res.Squares = exportSquares(squares)
+	return res, nil
+}
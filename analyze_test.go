@@ -0,0 +1,104 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// analyzeDir is a directory of some of the data/ fixtures, and the things around them that
+// aren't frames.
+func analyzeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, fn := range []string{"board1.jpg", "board13.jpg", "board13.pcd", "board2.jpg", "board4.jpg", "board4.pcd", "board5.jpg"} {
+		abs, err := filepath.Abs(filepath.Join("data", fn))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, os.Symlink(abs, filepath.Join(dir, fn)), test.ShouldBeNil)
+	}
+	test.That(t, rimage.WriteImageToFile(filepath.Join(dir, "blank.png"), image.NewRGBA(image.Rect(0, 0, 640, 480))), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a frame"), 0o666), test.ShouldBeNil)
+	test.That(t, os.Mkdir(filepath.Join(dir, "sub.jpg"), 0o777), test.ShouldBeNil)
+	return dir
+}
+
+func TestAnalyzeDirectory(t *testing.T) {
+	dir := analyzeDir(t)
+
+	names := []string{}
+	results := map[string]BoardAnalysis{}
+	errs := map[string]error{}
+	stats, err := AnalyzeDirectory(context.Background(), dir, AnalyzeOptions{Camera: touch.RealSenseProperties, Workers: 3},
+		func(name string, result BoardAnalysis, err error) error {
+			names = append(names, name)
+			results[name] = result
+			errs[name] = err
+			return nil
+		})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, names, test.ShouldResemble, []string{"blank.png", "board1.jpg", "board13.jpg", "board2.jpg", "board4.jpg", "board5.jpg"})
+
+	test.That(t, errs["blank.png"], test.ShouldNotBeNil)
+	test.That(t, results["blank.png"].Board.Found, test.ShouldBeFalse)
+	test.That(t, results["blank.png"].Board.Reason, test.ShouldEqual, NotFoundNoLines)
+
+	for _, name := range names[1:] {
+		test.That(t, errs[name], test.ShouldBeNil)
+		test.That(t, results[name].Board.Found, test.ShouldBeTrue)
+		test.That(t, results[name].Quality, test.ShouldBeGreaterThan, .5)
+		test.That(t, results[name].Latency, test.ShouldBeGreaterThan, 0)
+	}
+
+	// only the frames with a point cloud next to them get their squares
+	test.That(t, results["board4.jpg"].PointCloud, test.ShouldEqual, "board4.pcd")
+	test.That(t, len(results["board4.jpg"].Squares), test.ShouldEqual, 64)
+	test.That(t, results["board13.jpg"].PointCloud, test.ShouldEqual, "board13.pcd")
+	test.That(t, len(results["board13.jpg"].Squares), test.ShouldEqual, 64)
+	test.That(t, results["board1.jpg"].PointCloud, test.ShouldEqual, "")
+	test.That(t, results["board1.jpg"].Squares, test.ShouldBeNil)
+
+	test.That(t, stats.Frames, test.ShouldEqual, 6)
+	test.That(t, stats.Succeeded, test.ShouldEqual, 5)
+	test.That(t, stats.SuccessRate, test.ShouldAlmostEqual, 5.0/6)
+	test.That(t, stats.MeanQuality, test.ShouldBeGreaterThan, .5)
+	test.That(t, stats.MeanLatency, test.ShouldBeGreaterThan, 0)
+}
+
+func TestAnalyzeDirectoryStops(t *testing.T) {
+	dir := analyzeDir(t)
+
+	// an error from fn stops the walk there
+	stop := errors.New("stop")
+	seen := 0
+	stats, err := AnalyzeDirectory(context.Background(), dir, AnalyzeOptions{Workers: 2},
+		func(name string, result BoardAnalysis, err error) error {
+			seen++
+			if name == "board1.jpg" {
+				return stop
+			}
+			return nil
+		})
+	test.That(t, err, test.ShouldEqual, stop)
+	test.That(t, seen, test.ShouldEqual, 2)
+	test.That(t, stats.Frames, test.ShouldEqual, 2)
+
+	// so does ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	seen = 0
+	_, err = AnalyzeDirectory(ctx, dir, AnalyzeOptions{Workers: 2},
+		func(name string, result BoardAnalysis, err error) error {
+			seen++
+			cancel()
+			return nil
+		})
+	test.That(t, err, test.ShouldEqual, context.Canceled)
+	test.That(t, seen, test.ShouldEqual, 1)
+}
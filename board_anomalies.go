@@ -0,0 +1,318 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	// anomalyCell is how big (mm) the cells are that the points sticking up off the board are
+	// clustered by, see findAnomalies: the points in touching cells are the same thing.
+	anomalyCell = 5.0
+	// straddleSpan is how many squares across something can be and still be a piece on one.
+	straddleSpan = 1.3
+	// straddleShare is how much of a piece has to be over a second square for it to be across
+	// both.
+	straddleShare = .2
+)
+
+// The kinds of Anomaly.
+const (
+	AnomalyFallen     = "fallen"     // a piece lying on its side
+	AnomalyStraddling = "straddling" // a piece, or something, across squares
+)
+
+// Anomaly is something on the board that isn't a piece standing on one square, see
+// findAnomalies.
+type Anomaly struct {
+	Kind    string   `json:"kind"`    // AnomalyFallen or AnomalyStraddling
+	Squares []string `json:"squares"` // the squares it's over, a1, b1 ... h8 order
+}
+
+// String is the anomaly as in "d4 e4 fallen".
+func (a Anomaly) String() string {
+	return strings.Join(a.Squares, " ") + " " + a.Kind
+}
+
+// raisedPoint is a point sticking up off the board, see raisedPoints.
+type raisedPoint struct {
+	at     r2.Point // on the board, see boardSurface.coords
+	height float64
+	square int // its square's index, see squareIndex
+}
+
+// raisedPoints is the points of pc over cells, the whole of each square so what's over the lines
+// between them is in too (see BoardCorners.squareCell), more than footprintHeight and no more
+// than conf's maxPieceHeight above the board, heights being how high a point is over each square.
+// It's one pass over pc, sharded across the CPUs like squareClouds.
+func raisedPoints(pc pointcloud.PointCloud, cells []image.Rectangle, heights []func(r3.Vector) float64, surface boardSurface, props camera.Properties, conf *PieceFinderConfig) []raisedPoint {
+	owners := newSquareOwners(cells)
+	ip := props.IntrinsicParams
+	top := conf.maxPieceHeight()
+
+	shards := runtime.GOMAXPROCS(0)
+	found := make([][]raisedPoint, shards)
+	parallelRows(shards, func(start, end int) {
+		for shard := start; shard < end; shard++ {
+			mine := []raisedPoint{}
+			pc.Iterate(shards, shard, func(p r3.Vector, d pointcloud.Data) bool {
+				if p.Z == 0 {
+					return true
+				}
+				i := owners.at(pointPixel(p, ip))
+				if i < 0 {
+					return true
+				}
+				if h := heights[i](p); h > footprintHeight && h <= top {
+					mine = append(mine, raisedPoint{surface.coords(p), h, i})
+				}
+				return true
+			})
+			found[shard] = mine
+		}
+	})
+	return slices.Concat(found...)
+}
+
+// squarePitch is how far apart (mm, on the board) the middles of side by side squares of an
+// n x n board are, the median of them by clouds, 0 when there aren't any to go by.
+func squarePitch(clouds []pointcloud.PointCloud, surface boardSurface, n int) float64 {
+	centers := make([]r2.Point, len(clouds))
+	for i, pc := range clouds {
+		var sum r2.Point
+		pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			sum = sum.Add(surface.coords(p))
+			return true
+		})
+		if pc.Size() > 0 {
+			centers[i] = sum.Mul(1 / float64(pc.Size()))
+		}
+	}
+	apart := []float64{}
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n)-1; file++ {
+			i, j := squareIndex(file, rank, n), squareIndex(file+1, rank, n)
+			if clouds[i].Size() > 0 && clouds[j].Size() > 0 {
+				apart = append(apart, centers[i].Sub(centers[j]).Norm())
+			}
+		}
+	}
+	if len(apart) == 0 {
+		return 0
+	}
+	slices.Sort(apart)
+	return apart[len(apart)/2]
+}
+
+// findAnomalies clusters points, those in touching anomalyCell cells being one thing, and is
+// what's wrong with each of more than minPiecePoints points: AnomalyFallen when it's a piece on
+// its side by isFallen (pieces at scale), otherwise AnomalyStraddling when it's more than
+// straddleSpan squares of pitch (mm) across, or more than straddleShare of it is over a second
+// square. It's over the squares of names with more than minPiecePointsFloor of its points.
+func findAnomalies(points []raisedPoint, pitch, scale float64, names []string) []*Anomaly {
+	at := make([]r2.Point, len(points))
+	for i, p := range points {
+		at[i] = p.at
+	}
+	anomalies := []*Anomaly{}
+	for _, cluster := range clusterPoints(at, anomalyCell) {
+		if a := clusterAnomaly(points, cluster, pitch, scale, names); a != nil {
+			anomalies = append(anomalies, a)
+		}
+	}
+	slices.SortFunc(anomalies, func(a, b *Anomaly) int {
+		return slices.Index(names, a.Squares[0]) - slices.Index(names, b.Squares[0])
+	})
+	return anomalies
+}
+
+// clusterPoints is which of at (mm, on the board) are the same thing, as indexes into at: those
+// in touching cells cell across. The clusters are in no particular order.
+func clusterPoints(at []r2.Point, cell float64) [][]int {
+	type key [2]int
+	cells := map[key][]int{}
+	for i, p := range at {
+		k := key{int(math.Floor(p.X / cell)), int(math.Floor(p.Y / cell))}
+		cells[k] = append(cells[k], i)
+	}
+
+	clusters := [][]int{}
+	seen := map[key]bool{}
+	for start := range cells {
+		if seen[start] {
+			continue
+		}
+		// everything touching it
+		seen[start] = true
+		queue := []key{start}
+		cluster := []int{}
+		for len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			cluster = append(cluster, cells[c]...)
+			for dx := -1; dx <= 1; dx++ {
+				for dy := -1; dy <= 1; dy++ {
+					next := key{c[0] + dx, c[1] + dy}
+					if _, ok := cells[next]; ok && !seen[next] {
+						seen[next] = true
+						queue = append(queue, next)
+					}
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// clusterAnomaly is what's wrong with the cluster of points, see findAnomalies, nil when nothing
+// is.
+func clusterAnomaly(points []raisedPoint, cluster []int, pitch, scale float64, names []string) *Anomaly {
+	if len(cluster) <= minPiecePoints {
+		return nil
+	}
+	hs := make([]float64, 0, len(cluster))
+	at := make([]r2.Point, 0, len(cluster))
+	counts := map[int]int{}
+	for _, i := range cluster {
+		hs = append(hs, points[i].height)
+		at = append(at, points[i].at)
+		counts[points[i].square]++
+	}
+	slices.Sort(hs)
+	tall, across := hs[len(hs)*95/100], footprintAcross(at)
+
+	squares := []int{}
+	shares := []int{}
+	for sq, n := range counts {
+		if n > minPiecePointsFloor {
+			squares = append(squares, sq)
+		}
+		shares = append(shares, n)
+	}
+	slices.Sort(squares)
+	slices.Sort(shares)
+	slices.Reverse(shares)
+
+	kind := ""
+	switch {
+	case isFallen(tall, across, scale):
+		kind = AnomalyFallen
+	case pitch > 0 && across > straddleSpan*pitch:
+		kind = AnomalyStraddling
+	case len(shares) > 1 && shares[1] > minPiecePoints && float64(shares[1]) >= straddleShare*float64(len(cluster)):
+		kind = AnomalyStraddling
+	default:
+		return nil
+	}
+	if len(squares) == 0 {
+		// spread too thin over too many to be anything
+		return nil
+	}
+	a := &Anomaly{Kind: kind, Squares: make([]string, 0, len(squares))}
+	for _, sq := range squares {
+		a.Squares = append(a.Squares, names[sq])
+	}
+	return a
+}
+
+// Anomalies is each of the anomalies on b's squares once, in the order of the squares they're
+// first over.
+func (b *Board64) Anomalies() []Anomaly {
+	list := []Anomaly{}
+	for _, sq := range b {
+		if sq.Anomaly == nil {
+			continue
+		}
+		if !slices.ContainsFunc(list, func(a Anomaly) bool {
+			return a.Kind == sq.Anomaly.Kind && slices.Equal(a.Squares, sq.Anomaly.Squares)
+		}) {
+			list = append(list, *sq.Anomaly)
+		}
+	}
+	return list
+}
+
+// squareAnomalies is each of the anomalies on squares once.
+func squareAnomalies(squares []squareInfo) []*Anomaly {
+	list := []*Anomaly{}
+	for _, sq := range squares {
+		if sq.anomaly != nil && !slices.Contains(list, sq.anomaly) {
+			list = append(list, sq.anomaly)
+		}
+	}
+	return list
+}
+
+// addAnomalies puts the anomalies on squares in a capture's extra as "anomalies", each its
+// "kind" and "squares", when there are any.
+func addAnomalies(extra map[string]interface{}, squares []squareInfo) {
+	anomalies := squareAnomalies(squares)
+	if len(anomalies) == 0 {
+		return
+	}
+	list := make([]interface{}, 0, len(anomalies))
+	for _, a := range anomalies {
+		names := make([]interface{}, 0, len(a.Squares))
+		for _, n := range a.Squares {
+			names = append(names, n)
+		}
+		list = append(list, map[string]interface{}{"kind": a.Kind, "squares": names})
+	}
+	extra["anomalies"] = list
+}
+
+// captureAnomalies is the anomalies a piece finder capture's extra says there are, see
+// addAnomalies.
+func captureAnomalies(extra map[string]interface{}) []Anomaly {
+	list, _ := extra["anomalies"].([]interface{})
+	anomalies := []Anomaly{}
+	for _, x := range list {
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		a := Anomaly{}
+		a.Kind, _ = m["kind"].(string)
+		switch sq := m["squares"].(type) {
+		case []interface{}:
+			for _, n := range sq {
+				if s, ok := n.(string); ok {
+					a.Squares = append(a.Squares, s)
+				}
+			}
+		case []string:
+			a.Squares = sq
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies
+}
+
+// anomaliesError is why the chess service won't make a move while there are anomalies.
+func anomaliesError(anomalies []Anomaly) error {
+	names := make([]string, 0, len(anomalies))
+	for _, a := range anomalies {
+		names = append(names, a.String())
+	}
+	return fmt.Errorf("not moving with pieces off their squares (%s), stand them up on them and try again", strings.Join(names, ", "))
+}
+
+// anomalyList is anomalies for a DoCommand response.
+func anomalyList(anomalies []Anomaly) []interface{} {
+	list := make([]interface{}, 0, len(anomalies))
+	for _, a := range anomalies {
+		list = append(list, map[string]interface{}{"kind": a.Kind, "squares": a.Squares})
+	}
+	return list
+}
@@ -0,0 +1,104 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// raisedBox is something height (mm) tall over the pixels of at.
+type raisedBox struct {
+	at     image.Rectangle
+	height float64
+}
+
+// boxesBoard is a flat board 600mm off at tiltedBoardCorners, 40mm squares, with boxes on it.
+// With white on top d4 is the pixels from (640, 330) to (700, 390), e4 the ones left of it.
+func boxesBoard(t *testing.T, boxes ...raisedBox) pointcloud.PointCloud {
+	t.Helper()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	pc := pointcloud.NewBasicEmpty()
+	board := pointcloud.NewColoredData(color.NRGBA{120, 120, 120, 255})
+	piece := pointcloud.NewColoredData(color.NRGBA{240, 240, 240, 255})
+	for v := 140; v < 640; v += 2 {
+		for u := 390; u < 890; u += 2 {
+			x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+			z, d := 600.0, board
+			for _, b := range boxes {
+				if (image.Point{u, v}).In(b.at) {
+					z, d = 600-b.height, piece
+				}
+			}
+			test.That(t, pc.Set(r3.Vector{X: x * z, Y: y * z, Z: z}, d), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+func anomaliesOf(t *testing.T, boxes ...raisedBox) ([]*Anomaly, viscapture.VisCapture) {
+	t.Helper()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := boxesBoard(t, boxes...)
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+	all, err := pf.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	return squareAnomalies(pf.last.squares), all
+}
+
+func TestFindAnomalies(t *testing.T) {
+	// a piece standing in the middle of its square, and two side by side, are fine
+	anomalies, all := anomaliesOf(t,
+		raisedBox{image.Rect(655, 345, 685, 375), 40},
+		raisedBox{image.Rect(475, 225, 505, 255), 40},
+		raisedBox{image.Rect(535, 225, 565, 255), 40},
+	)
+	test.That(t, anomalies, test.ShouldBeEmpty)
+	test.That(t, all.Extra["anomalies"], test.ShouldBeNil)
+
+	// one lying on its side across d4 and e4
+	anomalies, all = anomaliesOf(t, raisedBox{image.Rect(590, 350, 690, 370), 12})
+	test.That(t, len(anomalies), test.ShouldEqual, 1)
+	test.That(t, *anomalies[0], test.ShouldResemble, Anomaly{Kind: AnomalyFallen, Squares: []string{"d4", "e4"}})
+	test.That(t, captureAnomalies(all.Extra), test.ShouldResemble, []Anomaly{*anomalies[0]})
+
+	// one standing on the line between them
+	anomalies, all = anomaliesOf(t, raisedBox{image.Rect(625, 345, 655, 375), 40})
+	test.That(t, len(anomalies), test.ShouldEqual, 1)
+	test.That(t, *anomalies[0], test.ShouldResemble, Anomaly{Kind: AnomalyStraddling, Squares: []string{"d4", "e4"}})
+	test.That(t, captureAnomalies(all.Extra), test.ShouldResemble, []Anomaly{*anomalies[0]})
+
+	// something long standing over three squares
+	anomalies, _ = anomaliesOf(t, raisedBox{image.Rect(590, 350, 750, 370), 40})
+	test.That(t, len(anomalies), test.ShouldEqual, 1)
+	test.That(t, *anomalies[0], test.ShouldResemble, Anomaly{Kind: AnomalyStraddling, Squares: []string{"c4", "d4", "e4"}})
+}
+
+func TestBoard64Anomalies(t *testing.T) {
+	var b Board64
+	for i := range b {
+		b[i] = SquareOf(chess.Square(i))
+	}
+	fallen := &Anomaly{Kind: AnomalyFallen, Squares: []string{"d4", "e4"}}
+	b[chess.D4].Anomaly = fallen
+	b[chess.E4].Anomaly = fallen
+	test.That(t, b.Anomalies(), test.ShouldResemble, []Anomaly{*fallen})
+
+	// the squares don't share it once they've been through JSON, it's still once
+	data, err := json.Marshal(b)
+	test.That(t, err, test.ShouldBeNil)
+	var back Board64
+	test.That(t, json.Unmarshal(data, &back), test.ShouldBeNil)
+	test.That(t, back.Anomalies(), test.ShouldResemble, []Anomaly{*fallen})
+}
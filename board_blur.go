@@ -0,0 +1,131 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// sharpnessStride is how far apart, both ways, the pixels frameSharpness looks at are.
+	sharpnessStride = 2
+	// A soft frame's edge thresholds come down to softEdgeFraction of the softEdgePercentile of
+	// its gradients. The board's lines, smeared wide, are about the top tenth of its pixels, and
+	// the weakest of them, next to the pieces, about a third of the way up them.
+	softEdgePercentile = .9
+	softEdgeFraction   = .3
+	// minSoftEdgeThreshold is as far as they come down, under it is the sensor's noise.
+	minSoftEdgeThreshold = 12
+	// blurShift is the fixed point of blurGray's kernel.
+	blurShift = 12
+)
+
+// frameSharpness is the variance of the Laplacian of img over every sharpnessStride'th pixel
+// each way, scaled back up for a frame exposure times as contrasty as a normal one so a dim frame
+// isn't taken for a soft one. A board in focus is a couple of hundred, one a couple of pixels
+// out of focus under 10. A frame too small to tell is +Inf.
+func frameSharpness(img image.Image, exposure float64) float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 3 || height < 3 {
+		return math.Inf(1)
+	}
+
+	rows := [3][]uint8{make([]uint8, width), make([]uint8, width), make([]uint8, width)}
+	n, sum, sumSq := 0, 0.0, 0.0
+	for y := 1; y < height-1; y += sharpnessStride {
+		for i, row := range rows {
+			grayRow(img, bounds.Min.X, bounds.Min.Y+y-1+i, row)
+		}
+		up, mid, down := rows[0], rows[1], rows[2]
+		for x := 1; x < width-1; x += sharpnessStride {
+			l := float64(4*int(mid[x]) - int(mid[x-1]) - int(mid[x+1]) - int(up[x]) - int(down[x]))
+			n++
+			sum += l
+			sumSq += l * l
+		}
+	}
+
+	m := sum / float64(n)
+	variance := sumSq/float64(n) - m*m
+	if exposure > 0 {
+		variance /= exposure * exposure
+	}
+	return variance
+}
+
+// blurGray blurs gray in place by a Gaussian sigma pixels wide, across then down, the pixels
+// past the edges taken to be the edge's own.
+func blurGray(gray *GrayPlane, sigma float64) {
+	r := int(math.Ceil(3 * sigma))
+	if sigma <= 0 || r < 1 {
+		return
+	}
+	width, height := gray.Width, gray.Height
+
+	// the weights sum to 1<<blurShift, what rounding leaves over goes in the middle
+	kernel := make([]int, 2*r+1)
+	weights := make([]float64, len(kernel))
+	total := 0.0
+	for i := range weights {
+		d := float64(i - r)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += weights[i]
+	}
+	left := 1 << blurShift
+	for i, w := range weights {
+		kernel[i] = int(math.Round(w / total * (1 << blurShift)))
+		left -= kernel[i]
+	}
+	kernel[r] += left
+	const half = 1 << (blurShift - 1)
+
+	across := getGrayPlane(width, height)
+	defer across.release()
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			src, dst := gray.Row(y), across.Row(y)
+			for x := range dst {
+				acc := half
+				for i, k := range kernel {
+					acc += k * int(src[max(0, min(width-1, x+i-r))])
+				}
+				dst[x] = uint8(acc >> blurShift)
+			}
+		}
+	})
+	parallelRows(height, func(start, end int) {
+		sums := make([]int, width)
+		for y := start; y < end; y++ {
+			for x := range sums {
+				sums[x] = half
+			}
+			for i, k := range kernel {
+				src := across.Row(max(0, min(height-1, y+i-r)))
+				for x, v := range src {
+					sums[x] += k * int(v)
+				}
+			}
+			dst := gray.Row(y)
+			for x, s := range sums {
+				dst[x] = uint8(s >> blurShift)
+			}
+		}
+	})
+}
+
+// gradientPercentile is the magnitude in edges that a fraction p of its pixels are under.
+func gradientPercentile(edges *GrayPlane, p float64) int {
+	var hist [256]int
+	for _, v := range edges.Pix {
+		hist[v]++
+	}
+	want := int(p * float64(len(edges.Pix)))
+	seen := 0
+	for v, n := range hist {
+		seen += n
+		if seen > want {
+			return v
+		}
+	}
+	return 255
+}
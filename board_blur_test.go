@@ -0,0 +1,62 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// outOfFocus is img in gray, blurred by a Gaussian sigma pixels wide, like a webcam that's
+// lost its focus.
+func outOfFocus(img image.Image, sigma float64) *image.Gray {
+	gray := makeGrayImage(img)
+	defer gray.release()
+	blurGray(gray, sigma)
+	res := image.NewGray(image.Rect(0, 0, gray.Width, gray.Height))
+	copy(res.Pix, gray.Pix)
+	return res
+}
+
+func TestBlurGray(t *testing.T) {
+	gray := getGrayPlane(21, 21)
+	defer gray.release()
+	for i := range gray.Pix {
+		gray.Pix[i] = 100
+	}
+	blurGray(gray, 2)
+	for _, v := range gray.Pix {
+		test.That(t, v, test.ShouldEqual, 100)
+	}
+
+	// a bright dot spreads out evenly around where it was
+	clear(gray.Pix)
+	gray.Set(10, 10, 255)
+	blurGray(gray, 1)
+	test.That(t, gray.At(10, 10), test.ShouldBeBetween, 30, 50)
+	test.That(t, gray.At(9, 10), test.ShouldEqual, gray.At(11, 10))
+	test.That(t, gray.At(10, 9), test.ShouldEqual, gray.At(9, 10))
+	test.That(t, gray.At(14, 10), test.ShouldEqual, 0)
+}
+
+func TestFindBoardOutOfFocus(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board2.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{305, 71}, {883, 59}, {904, 639}, {311, 660}}
+	opts := DefaultBoardFinderOptions()
+
+	soft := outOfFocus(input, 4)
+	test.That(t, frameSharpness(input, 1), test.ShouldBeGreaterThan, opts.MinSharpness)
+	test.That(t, frameSharpness(soft, 1), test.ShouldBeLessThan, opts.MinSharpness)
+
+	// the fixed thresholds miss the top edge, next to the pieces, and fit the grid a row low
+	opts.MinSharpness = 0
+	_, err = findBoardWithOptions(soft, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	corners, err := findBoard(soft)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("%v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 7)
+}
@@ -0,0 +1,591 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var BoardCameraModel = family.WithModel("board-camera")
+
+// The board camera's images.
+const (
+	// sourceWarped is the board top down, see WarpBoard. It's what Image serves.
+	sourceWarped = "warped"
+	// sourceRaw is the input's frame as it came.
+	sourceRaw = "raw"
+	// sourceAnnotated is the input's frame with the board's corners marked, see RenderCornerOverlay.
+	sourceAnnotated = "annotated"
+	// sourceOccupancy is the board top down with each square tinted by how sure it is there's a
+	// piece on it, see occupancy, when BoardCameraConfig.Occupancy turns it on.
+	sourceOccupancy = "occupancy"
+)
+
+// boardCameraSources are the board camera's images in the order Images returns them, but for
+// sourceOccupancy, see sources.
+var boardCameraSources = []string{sourceWarped, sourceRaw, sourceAnnotated}
+
+func registerBoardCamera() {
+	resource.RegisterComponent(camera.API, BoardCameraModel,
+		resource.Registration[camera.Camera, *BoardCameraConfig]{
+			Constructor: newBoardCamera,
+		},
+	)
+}
+
+type BoardCameraConfig struct {
+	Input string // the camera looking at the board
+
+	// The view is Width x Height (either one 0 is the other, both 0 means 800, multiples of the
+	// grid size), with Margin of it around the board each side, sampled by Interpolation, see
+	// WarpOptions. Rotation is where white is in the input, as for the piece finder or "auto",
+	// and turns the view so a1 is bottom left; "" leaves it the way the input has it.
+	Width         int
+	Height        int
+	Margin        float64
+	Interpolation Interpolation
+	Rotation      string
+
+	// BoardOptions overrides individual BoardFinderOptions fields, by json name.
+	BoardOptions map[string]interface{} `json:"board-options"`
+
+	// Corners pins the board's TL, TR, BR, BL corners, [x, y] pixels in the input's frame, so
+	// the board finder isn't run, as for the piece finder.
+	Corners [][]int `json:"corners"`
+
+	// SquareSize is how big (mm) the board's squares are, 0 means 57, for the point cloud.
+	SquareSize float64 `json:"square-size"`
+
+	// Mode is how the warped image is drawn: "image" (the default), "hue", "gray", "edges",
+	// "mask" or "squares", see renderWarpMode. All but image have the grid drawn on them.
+	Mode string `json:"mode"`
+
+	// JPEGQuality is what the images are encoded at as JPEG, 1 to 100, 0 means 75. PNG is
+	// lossless, for the debug images.
+	JPEGQuality int `json:"jpeg-quality"`
+
+	// Occupancy adds the occupancy image, see sourceOccupancy.
+	Occupancy bool `json:"occupancy"`
+
+	// LabelScale is how many times the 7x13 pixel font the squares' labels are drawn with
+	// extra["draw"], 0 picks it from how big the squares are, see labelScale.
+	LabelScale int `json:"label-scale"`
+}
+
+const defaultBoardCameraSize = 800
+
+func (cfg *BoardCameraConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.Input == "" {
+		return nil, nil, fmt.Errorf("need an input")
+	}
+	opts, err := cfg.boardFinderOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad board-options: %w", err)
+	}
+	if cfg.Rotation == "auto" && opts.gridSize() != defaultGridSize {
+		return nil, nil, fmt.Errorf("rotation auto goes by where the chess pieces start, it needs grid-size 8")
+	}
+	if cfg.Rotation != "" && cfg.Rotation != "auto" {
+		if _, err := ParseBoardRotation(cfg.Rotation); err != nil {
+			return nil, nil, err
+		}
+	}
+	// any corners will do to check the size, margin and interpolation
+	layout, err := warpLayout(defaultCorners(100, 100), cfg.warpOptions(), Rotation0, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	// so the squares are all the same whole number of pixels
+	if n := opts.gridSize(); layout.Width%n != 0 || layout.Height%n != 0 {
+		return nil, nil, fmt.Errorf("width and height have to be multiples of %d, got %dx%d", n, layout.Width, layout.Height)
+	}
+	err = validateCorners(cfg.Corners)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateSquareSize(cfg.SquareSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateWarpMode(cfg.Mode)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateJPEGQuality(cfg.JPEGQuality)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateLabelScale(cfg.LabelScale)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{cfg.Input}, nil, nil
+}
+
+func (cfg *BoardCameraConfig) squareSize() float64 {
+	if cfg.SquareSize == 0 {
+		return defaultSquareSize
+	}
+	return cfg.SquareSize
+}
+
+func (cfg *BoardCameraConfig) boardFinderOptions() (BoardFinderOptions, error) {
+	return BoardFinderOptionsFromMap(cfg.BoardOptions)
+}
+
+func (cfg *BoardCameraConfig) warpOptions() WarpOptions {
+	opts := WarpOptions{
+		Width:         cfg.Width,
+		Height:        cfg.Height,
+		Margin:        cfg.Margin,
+		Interpolation: cfg.Interpolation,
+		Rotation:      cfg.Rotation,
+	}
+	if opts.Width == 0 && opts.Height == 0 {
+		opts.Width = defaultBoardCameraSize
+	}
+	return opts
+}
+
+func newBoardCamera(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (camera.Camera, error) {
+	conf, err := resource.NativeConfig[*BoardCameraConfig](rawConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBoardCamera(ctx, deps, rawConf.ResourceName(), conf, logger)
+}
+
+func NewBoardCamera(ctx context.Context, deps resource.Dependencies, name resource.Name, conf *BoardCameraConfig, logger logging.Logger) (camera.Camera, error) {
+	var err error
+
+	bc := &BoardCamera{
+		name:   name,
+		conf:   conf,
+		logger: logger,
+	}
+
+	bc.input, err = camera.FromProvider(deps, conf.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.props, err = bc.input.Properties(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCornersInFrame(conf.Corners, bc.props)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc, nil
+}
+
+// BoardCamera is a camera of the board top down, warped from its input's frames, along with
+// the frames themselves. See the board camera sources for its images.
+type BoardCamera struct {
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	name   resource.Name
+	conf   *BoardCameraConfig
+	logger logging.Logger
+
+	input camera.Camera
+	props camera.Properties
+
+	// reused between frames
+	mu        sync.Mutex
+	undistort undistorter
+	warper    BoardWarper
+	last      []image.Point // where the board was last found, for a frame it isn't
+	scan      *boardScan    // of the last frame the board was looked for in
+}
+
+func (bc *BoardCamera) Name() resource.Name {
+	return bc.name
+}
+
+// sources are the board camera's images in the order Images returns them.
+func (bc *BoardCamera) sources() []string {
+	if bc.conf.Occupancy {
+		return append(slices.Clip(boardCameraSources), sourceOccupancy)
+	}
+	return boardCameraSources
+}
+
+// checkSources errors unless each of names is one of the board camera's images.
+func (bc *BoardCamera) checkSources(names []string) error {
+	all := bc.sources()
+	for _, name := range names {
+		if !slices.Contains(all, name) {
+			return fmt.Errorf("no image %q, the board camera has %v", name, all)
+		}
+	}
+	return nil
+}
+
+// Images is each of the board camera's images named in filterSourceNames, all of them when it's
+// empty, from one frame of the input. The board's only looked for if one of them needs it.
+// extra["overlay"] draws the grid, the labels or both on the warped image, see drawWarpOverlay;
+// it's drawn in the configured mode, with the grid unless the overlay says otherwise.
+// extra["mime_type"] is what they're encoded as, JPEG (the default) or PNG.
+// The warped and annotated images have a bounding box for each square, labeled with its name and
+// the piece on it when the input has a point cloud, which extra["draw"] draws on them too.
+func (bc *BoardCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	if err := bc.checkSources(filterSourceNames); err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	overlay, err := overlayMode(extra)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	mimeType, _ := extra["mime_type"].(string)
+	mimeType, err = imageMimeType(mimeType)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	if _, ok := extra["overlay"]; !ok && bc.conf.Mode != "" && bc.conf.Mode != modeImage {
+		overlay = overlayGrid
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	sources := bc.sources()
+	if len(filterSourceNames) > 0 {
+		sources = nil
+		for _, s := range bc.sources() {
+			if slices.Contains(filterSourceNames, s) {
+				sources = append(sources, s)
+			}
+		}
+	}
+
+	img, err := camera.DecodeImageFromCamera(ctx, bc.input, nil, extra)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	meta := resource.ResponseMetadata{CapturedAt: time.Now()}
+	board := slices.ContainsFunc(sources, func(s string) bool { return s != sourceRaw })
+	var pc pointcloud.PointCloud
+	if board && bc.props.SupportsPCD {
+		pc, err = bc.input.NextPointCloud(ctx, extra)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	var res FindBoardResult
+	var squares []squareAnnotation
+	var info []squareInfo
+	if board {
+		start := time.Now()
+		res, err = bc.findBoard(ctx, img, opts)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		rot, _, err := bc.conf.warpOptions().frameRotation(img, res.Corners)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		squares, info = bc.squareAnnotations(img, pc, res, rot, opts.gridSize())
+		scan := newBoardScan(info, meta.CapturedAt, time.Since(start), res.Found)
+		bc.scan = &scan
+		bc.logger.Debugf("board scan: %s", scan.summary())
+	}
+	frameSquare := func(sq squareAnnotation) image.Rectangle { return sq.cell }
+	// .5, can't tell, for the squares there's nothing to go by on
+	occupancies := slices.Repeat([]float64{.5}, opts.gridSize()*opts.gridSize())
+	for _, sq := range info {
+		occupancies[squareIndex(sq.file, sq.rank, opts.gridSize())] = occupancy(sq)
+	}
+
+	images := make([]camera.NamedImage, 0, len(sources))
+	for _, s := range sources {
+		var out image.Image
+		var annotations data.Annotations
+		switch s {
+		case sourceWarped:
+			warped, layout, err := bc.warper.Warp(img, res.Corners, bc.conf.warpOptions())
+			if err != nil {
+				return nil, resource.ResponseMetadata{}, err
+			}
+			warped, err = renderWarpMode(warped, layout, opts.gridSize(), bc.conf.Mode)
+			if err != nil {
+				return nil, resource.ResponseMetadata{}, err
+			}
+			if overlay != overlayNone {
+				drawWarpOverlay(warped, layout, opts.gridSize(), overlay)
+			}
+			viewSquare := func(sq squareAnnotation) image.Rectangle { return layout.Square(sq.file, sq.rank, opts.gridSize()) }
+			annotations = squareBoxes(squares, warped.Bounds().Size(), viewSquare)
+			if extra["draw"] == true {
+				drawSquareBoxes(warped, squares, viewSquare, bc.conf.LabelScale)
+			}
+			out = warped
+		case sourceRaw:
+			out = img
+		case sourceAnnotated:
+			annotated := RenderCornerOverlay(img, res, OverlayOptions{})
+			annotations = squareBoxes(squares, img.Bounds().Size(), frameSquare)
+			if extra["draw"] == true {
+				drawSquareBoxes(annotated, squares, frameSquare, bc.conf.LabelScale)
+			}
+			out = annotated
+		case sourceOccupancy:
+			warped, layout, err := bc.warper.Warp(img, res.Corners, bc.conf.warpOptions())
+			if err != nil {
+				return nil, resource.ResponseMetadata{}, err
+			}
+			viewSquare := func(sq squareAnnotation) image.Rectangle { return layout.Square(sq.file, sq.rank, opts.gridSize()) }
+			drawOccupancy(warped, squares, viewSquare, func(sq squareAnnotation) float64 {
+				return occupancies[squareIndex(sq.file, sq.rank, opts.gridSize())]
+			})
+			annotations = squareBoxes(squares, warped.Bounds().Size(), viewSquare)
+			out = warped
+		}
+		ni, err := camera.NamedImageFromImage(out, s, mimeType, annotations)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		images = append(images, ni)
+	}
+	return images, meta, nil
+}
+
+// findBoard is where the board is in img: the pinned corners, or the board finder's, or where it
+// was last found when it can't find it now. bc.mu held.
+func (bc *BoardCamera) findBoard(ctx context.Context, img image.Image, opts BoardFinderOptions) (FindBoardResult, error) {
+	if bc.conf.Corners != nil {
+		board, err := pinnedBoard(bc.conf.Corners, img)
+		if err != nil {
+			return FindBoardResult{}, err
+		}
+		return foundResult(subPixelCornersFromSlice(floatCorners(board.Slice()))), nil
+	}
+
+	// without a point cloud the corners only come from the image, so there's no conf to go by
+	res := findCorners(ctx, &bc.undistort, img, nil, bc.props, nil, opts)
+	switch {
+	case res.Reason == NotFoundCanceled:
+		return FindBoardResult{}, res.Err
+	case res.Found:
+		bc.last = res.Corners
+	case bc.last != nil:
+		bc.logger.Debugf("board not found (%s), using where it was last", res.Reason)
+		res = foundResult(subPixelCornersFromSlice(floatCorners(bc.last)))
+	}
+	return res, nil
+}
+
+// Image is the warped image encoded as mimeType, JPEG at the configured quality when it's "" or
+// PNG, see encodeImage.
+func (bc *BoardCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
+	if _, err := imageMimeType(mimeType); err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	images, _, err := bc.Images(ctx, []string{sourceWarped}, extra)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	img, err := images[0].Image(ctx)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	data, mimeType, err := encodeImage(img, mimeType, bc.conf.JPEGQuality)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	return data, camera.ImageMetadata{MimeType: mimeType, Annotations: images[0].Annotations}, nil
+}
+
+// NextPointCloud is the input's point cloud over the board, in the board's frame, see
+// boardFrameCloud. extra["square"], "e2" say, is just what's over that square.
+func (bc *BoardCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+	var file rune
+	var rank int
+	square, one := extra["square"]
+	if one {
+		name, _ := square.(string)
+		file, rank, err = boardSquare(name, opts.gridSize())
+		if err != nil {
+			return nil, err
+		}
+	}
+	img, err := camera.DecodeImageFromCamera(ctx, bc.input, nil, extra)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.mu.Lock()
+	res, err := bc.findBoard(ctx, img, opts)
+	bc.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	rot, _, err := bc.conf.warpOptions().frameRotation(img, res.Corners)
+	if err != nil {
+		return nil, err
+	}
+	board, err := boardFrameCloud(pc, res.SubPixelCorners, rot, opts.gridSize(), bc.props, bc.conf.squareSize())
+	if err != nil || !one {
+		return board, err
+	}
+	return boardSquareCloud(board, file, rank, bc.conf.squareSize())
+}
+
+// Properties are the warped image's, with the intrinsics of a camera looking straight down at
+// the board from as far as the input is, see viewIntrinsics. They need where the board is, so
+// the first call finds it in a frame if it isn't pinned.
+func (bc *BoardCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	props := camera.Properties{
+		SupportsPCD: bc.props.SupportsPCD,
+		ImageType:   camera.ColorStream,
+		MimeTypes:   imageMimeTypes,
+		FrameRate:   bc.props.FrameRate,
+	}
+	if bc.props.IntrinsicParams == nil {
+		return props, nil
+	}
+
+	corners, err := bc.boardCorners(ctx)
+	if err != nil {
+		return camera.Properties{}, err
+	}
+	layout, err := warpLayout(corners, bc.conf.warpOptions(), Rotation0, false)
+	if err != nil {
+		return camera.Properties{}, err
+	}
+	props.IntrinsicParams, err = viewIntrinsics(layout, bc.props.IntrinsicParams)
+	if err != nil {
+		return camera.Properties{}, err
+	}
+	return props, nil
+}
+
+// boardCorners is where the board is, pinned or last found, finding it in a frame if it hasn't
+// been yet.
+func (bc *BoardCamera) boardCorners(ctx context.Context) ([]image.Point, error) {
+	if bc.conf.Corners != nil {
+		return configCorners(bc.conf.Corners).Slice(), nil
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.last != nil {
+		return bc.last, nil
+	}
+	img, err := camera.DecodeImageFromCamera(ctx, bc.input, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := bc.findBoard(ctx, img, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Found {
+		return nil, fmt.Errorf("can't find the board for the intrinsics: %s", res.Reason)
+	}
+	return res.Corners, nil
+}
+
+// viewIntrinsics is a pinhole camera's for the view laid out by layout, warped from frames with
+// ip's: the principal point in the middle of it, and the focal length ip's scaled by how many of
+// the view's pixels the frame's are there, so it's as if the camera had been moved round over
+// the middle of the board. The turn doesn't change it.
+func viewIntrinsics(layout WarpLayout, ip *transform.PinholeCameraIntrinsics) (*transform.PinholeCameraIntrinsics, error) {
+	toFrame, err := layout.Homography.Inverse()
+	if err != nil {
+		return nil, err
+	}
+	board := layout.Board
+	mid := r2.Point{X: float64(board.Min.X+board.Max.X) / 2, Y: float64(board.Min.Y+board.Max.Y) / 2}
+	at := toFrame.Apply(mid)
+	// how many of the frame's pixels one of the view's is, across and down
+	sx := toFrame.Apply(mid.Add(r2.Point{X: 1})).Sub(at).Norm()
+	sy := toFrame.Apply(mid.Add(r2.Point{Y: 1})).Sub(at).Norm()
+	if sx == 0 || sy == 0 {
+		return nil, errors.New("the board's too small for the intrinsics")
+	}
+	return &transform.PinholeCameraIntrinsics{
+		Width:  layout.Width,
+		Height: layout.Height,
+		Fx:     ip.Fx / sx,
+		Fy:     ip.Fy / sy,
+		Ppx:    float64(layout.Width) / 2,
+		Ppy:    float64(layout.Height) / 2,
+	}, nil
+}
+
+func (bc *BoardCamera) Geometries(ctx context.Context, extra map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
+
+func (bc *BoardCamera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["frame"] == true {
+		frame := maps.Clone(boardFrame)
+		frame["square-size"] = bc.conf.squareSize()
+		return frame, nil
+	}
+	if name, ok := cmd["get_square_pcd"]; ok {
+		return bc.squarePCD(ctx, name)
+	}
+	if name, ok := cmd["get_square_stats"]; ok {
+		return bc.squareStats(ctx, name)
+	}
+	if cmd["sample_squares"] == true {
+		squares, _, err := bc.currentSquares(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return sampleSquares(squares, bc.pieceConf()), nil
+	}
+	if cmd["suggest_thresholds"] == true {
+		squares, n, err := bc.currentSquares(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return suggestThresholds(squares, bc.pieceConf(), n)
+	}
+	if cmd["scan"] == true {
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+		if bc.scan == nil {
+			return nil, errors.New("no scan yet, the board hasn't been looked for")
+		}
+		return bc.scan.toMap(), nil
+	}
+	return nil, fmt.Errorf("DoCommand not supported")
+}
@@ -0,0 +1,117 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// squareAnnotation is a square of the board camera's frame, for its images' annotations.
+type squareAnnotation struct {
+	file  rune
+	rank  int
+	label string // <name>:<W, B or empty>, just the name without a point cloud to go by
+	cell  image.Rectangle
+}
+
+// pieceLabels are what's on a square for its annotation, by estimatePieceColor's colors.
+var pieceLabels = []string{"empty", "W", "B"}
+
+// pieceConf is how the board camera tells what's on the squares, the piece finder's defaults.
+func (bc *BoardCamera) pieceConf() *PieceFinderConfig {
+	return &PieceFinderConfig{Rotation: bc.conf.Rotation, BoardOptions: bc.conf.BoardOptions}
+}
+
+// currentSquares is the squares of a new frame, with what's on them, and how big the board is.
+func (bc *BoardCamera) currentSquares(ctx context.Context) ([]squareInfo, int, error) {
+	if !bc.props.SupportsPCD {
+		return nil, 0, errors.New("the input has no point cloud to tell what's on the squares")
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, 0, err
+	}
+	img, err := camera.DecodeImageFromCamera(ctx, bc.input, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	bc.mu.Lock()
+	res, err := bc.findBoard(ctx, img, opts)
+	bc.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	squares, err := squaresOnBoard(nil, res.Board, [4]bool{}, img, pc, bc.props, bc.pieceConf(), opts.gridSize())
+	return squares, opts.gridSize(), err
+}
+
+// squareAnnotations is each square of the board at res in img, white at rot, labeled with the
+// piece on it in pc, or with just its name when pc is nil or the squares can't be told apart in
+// it, and the squares it went by. bc.mu held.
+func (bc *BoardCamera) squareAnnotations(img image.Image, pc pointcloud.PointCloud, res FindBoardResult, rot BoardRotation, n int) ([]squareAnnotation, []squareInfo) {
+	colors := map[string]int{}
+	var info []squareInfo
+	if pc != nil {
+		var err error
+		info, err = squaresOnBoard(nil, res.Board, [4]bool{}, img, pc, bc.props, bc.pieceConf(), n)
+		if err != nil {
+			bc.logger.Debugf("can't tell what's on the squares: %v", err)
+			info = nil
+		}
+		for _, sq := range info {
+			colors[sq.name] = sq.color
+		}
+	}
+
+	names := gridSquareNames(n)
+	squares := make([]squareAnnotation, 0, n*n)
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			name := names[squareIndex(file, rank, n)]
+			label := name
+			if c, ok := colors[name]; ok {
+				label += ":" + pieceLabels[c]
+			}
+			col, row := gridPosition(file, rank, rot, n)
+			squares = append(squares, squareAnnotation{file, rank, label, res.Board.squareCell(col, row, n)})
+		}
+	}
+	return squares, info
+}
+
+// squareBoxes is a bounding box for each of squares, where bounds says it is in an image size big.
+func squareBoxes(squares []squareAnnotation, size image.Point, bounds func(squareAnnotation) image.Rectangle) data.Annotations {
+	boxes := make([]data.BoundingBox, 0, len(squares))
+	w, h := float64(size.X), float64(size.Y)
+	for _, sq := range squares {
+		r := bounds(sq)
+		boxes = append(boxes, data.BoundingBox{
+			Label:          sq.label,
+			XMinNormalized: float64(r.Min.X) / w,
+			YMinNormalized: float64(r.Min.Y) / h,
+			XMaxNormalized: float64(r.Max.X) / w,
+			YMaxNormalized: float64(r.Max.Y) / h,
+		})
+	}
+	return data.Annotations{BoundingBoxes: boxes}
+}
+
+// drawSquareBoxes outlines each of squares on img where bounds says it is, with its label in
+// the middle at labelScale's scale for scale, as createDebugImage does.
+func drawSquareBoxes(img *image.RGBA, squares []squareAnnotation, bounds func(squareAnnotation) image.Rectangle, scale int) {
+	for _, sq := range squares {
+		r := bounds(sq)
+		drawRect(img, r, color.RGBA{0, 255, 0, 255})
+		mid := image.Point{(r.Min.X + r.Max.X) / 2, (r.Min.Y + r.Max.Y) / 2}
+		drawLabel(img, mid, sq.label, labelScale(r, len(sq.label), scale), color.RGBA{255, 0, 0, 255})
+	}
+}
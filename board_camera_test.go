@@ -0,0 +1,430 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/testutils/inject"
+	rutils "go.viam.com/rdk/utils"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// newTestBoardCamera is a BoardCamera whose input, a RealSense, returns frame and pc.
+func newTestBoardCamera(t *testing.T, conf *BoardCameraConfig, frame image.Image, pc pointcloud.PointCloud) camera.Camera {
+	props := touch.RealSenseProperties
+	props.SupportsPCD = pc != nil
+	return newTestBoardCameraProps(t, conf, frame, pc, props)
+}
+
+// newTestBoardCameraProps is a BoardCamera whose input, a camera with props, returns frame and pc.
+func newTestBoardCameraProps(t *testing.T, conf *BoardCameraConfig, frame image.Image, pc pointcloud.PointCloud, props camera.Properties) camera.Camera {
+	cam := inject.NewCamera(conf.Input)
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{},
+	) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		ni, err := camera.NamedImageFromImage(frame, "color", "image/png", data.Annotations{})
+		return []camera.NamedImage{ni}, resource.ResponseMetadata{}, err
+	}
+	cam.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return pc, nil
+	}
+	cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+		return props, nil
+	}
+
+	deps := resource.Dependencies{camera.Named(conf.Input): cam}
+	bc, err := NewBoardCamera(context.Background(), deps, camera.Named("board"), conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	return bc
+}
+
+func TestBoardCameraImages(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 400, Height: 300}, input, nil)
+
+	sizes := func(images []camera.NamedImage) map[string]image.Rectangle {
+		out := map[string]image.Rectangle{}
+		for _, ni := range images {
+			img, err := ni.Image(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			out[ni.SourceName] = img.Bounds()
+		}
+		return out
+	}
+
+	// all of them, in order
+	images, _, err := bc.Images(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 3)
+	for i, s := range boardCameraSources {
+		test.That(t, images[i].SourceName, test.ShouldEqual, s)
+	}
+	test.That(t, sizes(images), test.ShouldResemble, map[string]image.Rectangle{
+		sourceWarped:    image.Rect(0, 0, 400, 300),
+		sourceRaw:       input.Bounds(),
+		sourceAnnotated: input.Bounds(),
+	})
+
+	// or just some, still in order
+	images, _, err = bc.Images(ctx, []string{sourceRaw}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sizes(images), test.ShouldResemble, map[string]image.Rectangle{sourceRaw: input.Bounds()})
+	images, _, err = bc.Images(ctx, []string{sourceAnnotated, sourceWarped}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 2)
+	test.That(t, images[0].SourceName, test.ShouldEqual, sourceWarped)
+	test.That(t, images[1].SourceName, test.ShouldEqual, sourceAnnotated)
+
+	_, _, err = bc.Images(ctx, []string{"depth"}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// Image is the warped one
+	b, meta, err := bc.Image(ctx, rutils.MimeTypeJPEG, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, meta.MimeType, test.ShouldEqual, rutils.MimeTypeJPEG)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Width, test.ShouldEqual, 400)
+	test.That(t, cfg.Height, test.ShouldEqual, 300)
+}
+
+func TestBoardCameraConfig(t *testing.T) {
+	deps, _, err := (&BoardCameraConfig{Input: "cam"}).Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"cam"})
+	test.That(t, (&BoardCameraConfig{Input: "cam"}).warpOptions().Width, test.ShouldEqual, 800)
+
+	for _, cfg := range []BoardCameraConfig{
+		{},
+		{Input: "cam", Rotation: "45"},
+		{Input: "cam", Margin: .5},
+		{Input: "cam", Interpolation: "lanczos"},
+		{Input: "cam", Corners: [][]int{{0, 0}, {10, 0}}},
+		{Input: "cam", Width: 401},
+		{Input: "cam", Width: 400, Height: 300, BoardOptions: map[string]interface{}{"grid-size": 9}},
+	} {
+		_, _, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+func TestBoardCameraOverlay(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	conf := &BoardCameraConfig{Input: "cam", Width: 400, Margin: .05, Rotation: "0", Corners: board13Corners}
+	bc := newTestBoardCamera(t, conf, input, nil)
+	_, layout, err := WarpBoard(input, configCorners(board13Corners).Slice(), conf.warpOptions())
+	test.That(t, err, test.ShouldBeNil)
+
+	warped := func(extra map[string]interface{}) *image.RGBA {
+		images, _, err := bc.Images(ctx, []string{sourceWarped}, extra)
+		test.That(t, err, test.ShouldBeNil)
+		img, err := images[0].Image(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		return img.(*image.RGBA)
+	}
+	changed := func(a, b *image.RGBA, r image.Rectangle) bool {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a.RGBAAt(x, y) != b.RGBAAt(x, y) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// none is what there was before there were overlays
+	plain := warped(nil)
+	test.That(t, warped(map[string]interface{}{"overlay": "none"}).Pix, test.ShouldResemble, plain.Pix)
+
+	// the grid is on the square edges and nothing else
+	grid := warped(map[string]interface{}{"overlay": "grid"})
+	edge := layout.SquareBounds(1, 3, 8)
+	test.That(t, grid.RGBAAt(edge.Min.X, edge.Min.Y+edge.Dy()/2), test.ShouldResemble, overlayGridColor)
+	test.That(t, grid.RGBAAt(edge.Min.X+edge.Dx()/2, edge.Min.Y), test.ShouldResemble, overlayGridColor)
+	test.That(t, changed(grid, plain, edge.Inset(2)), test.ShouldBeFalse)
+
+	// turned, a1's corner of the view gets the a and the 1, and h8's neither
+	labels := warped(map[string]interface{}{"overlay": "labels"})
+	a1, h8 := layout.Square('a', 1, 8), layout.Square('h', 8, 8)
+	test.That(t, a1, test.ShouldResemble, layout.SquareBounds(0, 7, 8))
+	test.That(t, changed(labels, plain, image.Rect(a1.Max.X-12, a1.Max.Y-14, a1.Max.X, a1.Max.Y)), test.ShouldBeTrue)
+	test.That(t, changed(labels, plain, image.Rect(a1.Min.X, a1.Min.Y, a1.Min.X+12, a1.Min.Y+14)), test.ShouldBeTrue)
+	test.That(t, changed(labels, plain, h8), test.ShouldBeFalse)
+	test.That(t, changed(labels, plain, image.Rect(edge.Min.X, edge.Min.Y, edge.Min.X+1, edge.Max.Y)), test.ShouldBeFalse)
+
+	both := warped(map[string]interface{}{"overlay": "both"})
+	test.That(t, both.RGBAAt(edge.Min.X, edge.Min.Y+edge.Dy()/2), test.ShouldResemble, overlayGridColor)
+	test.That(t, changed(both, labels, a1.Inset(2)), test.ShouldBeFalse)
+
+	_, _, err = bc.Images(ctx, nil, map[string]interface{}{"overlay": "arrows"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestBoardCameraPointCloud(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+
+	// board4 has white on the bottom
+	conf := &BoardCameraConfig{Input: "cam", Rotation: "180", Corners: [][]int{
+		{corners[0].X, corners[0].Y}, {corners[1].X, corners[1].Y}, {corners[2].X, corners[2].Y}, {corners[3].X, corners[3].Y},
+	}}
+	bc := newTestBoardCamera(t, conf, input, pc)
+	out, err := bc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.Size(), test.ShouldBeGreaterThan, 1000)
+	test.That(t, out.Size(), test.ShouldBeLessThan, pc.Size())
+
+	// the points in the middle of e2 in the frame are in the e2 cell, 4 to 5 squares along and 1 to 2 up
+	col, row := gridPosition('e', 2, Rotation180, 8)
+	e2 := computeSquareBounds(corners, col, row, 8).Inset(4)
+	ip := touch.RealSenseProperties.IntrinsicParams
+	var want []r3.Vector
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z > 0 && image.Pt(int(p.X/p.Z*ip.Fx+ip.Ppx), int(p.Y/p.Z*ip.Fy+ip.Ppy)).In(e2) {
+			want = append(want, p)
+		}
+		return true
+	})
+	test.That(t, len(want), test.ShouldBeGreaterThan, 50)
+
+	s := defaultSquareSize
+	inE2, board := 0, 0
+	out.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		test.That(t, p.X, test.ShouldBeBetweenOrEqual, 0, 8*s)
+		test.That(t, p.Y, test.ShouldBeBetweenOrEqual, 0, 8*s)
+		if p.X >= 4*s && p.X < 5*s && p.Y >= s && p.Y < 2*s {
+			inE2++
+		}
+		if p.Z < planeTolerance {
+			board++
+		}
+		return true
+	})
+	t.Logf("%d points in the middle of e2, %d in the cell", len(want), inE2)
+	test.That(t, inE2, test.ShouldBeGreaterThanOrEqualTo, len(want))
+	// most of it's the board, on the plane
+	test.That(t, board, test.ShouldBeGreaterThan, out.Size()/2)
+
+	frame, err := bc.DoCommand(ctx, map[string]interface{}{"frame": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, frame["units"], test.ShouldEqual, "mm")
+	test.That(t, frame["square-size"], test.ShouldEqual, s)
+}
+
+func TestBoardCameraProperties(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	// board13's board is about 665 pixels across in the frame, 400 in the view
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 400, Height: 300, Corners: board13Corners}, input, nil)
+	props, err := bc.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.SupportsPCD, test.ShouldBeFalse)
+	ip := props.IntrinsicParams
+	test.That(t, ip.Width, test.ShouldEqual, 400)
+	test.That(t, ip.Height, test.ShouldEqual, 300)
+	test.That(t, ip.Ppx, test.ShouldEqual, 200)
+	test.That(t, ip.Ppy, test.ShouldEqual, 150)
+	in := touch.RealSenseProperties.IntrinsicParams
+	test.That(t, ip.Fx, test.ShouldAlmostEqual, in.Fx*400/665, in.Fx*400/665*.05)
+	test.That(t, ip.Fy, test.ShouldAlmostEqual, in.Fy*300/665, in.Fy*300/665*.05)
+
+	// with a point cloud, and found rather than pinned
+	pc := pointcloud.NewBasicEmpty()
+	bc = newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 1024}, input, pc)
+	props, err = bc.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.SupportsPCD, test.ShouldBeTrue)
+	test.That(t, props.IntrinsicParams.Width, test.ShouldEqual, 1024)
+	test.That(t, props.IntrinsicParams.Height, test.ShouldEqual, 1024)
+	test.That(t, props.IntrinsicParams.Fx, test.ShouldAlmostEqual, in.Fx*1024/665, in.Fx*1024/665*.05)
+}
+
+func TestBoardCameraSize(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	pinned := [][]int{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	for _, size := range []int{400, 1024} {
+		conf := &BoardCameraConfig{Input: "cam", Width: size, Corners: pinned}
+		_, _, err := conf.Validate("")
+		test.That(t, err, test.ShouldBeNil)
+		bc := newTestBoardCamera(t, conf, input, nil)
+		images, _, err := bc.Images(ctx, []string{sourceWarped}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		img, err := images[0].Image(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, size, size))
+
+		// the squares are an eighth of it
+		_, layout, err := WarpBoard(input, corners, conf.warpOptions())
+		test.That(t, err, test.ShouldBeNil)
+		sq := size / 8
+		test.That(t, layout.SquareBounds(0, 0, 8), test.ShouldResemble, image.Rect(0, 0, sq, sq))
+		test.That(t, layout.SquareBounds(7, 7, 8), test.ShouldResemble, image.Rect(7*sq, 7*sq, size, size))
+		test.That(t, layout.SquareBounds(3, 5, 8), test.ShouldResemble, image.Rect(3*sq, 5*sq, 4*sq, 6*sq))
+	}
+}
+
+func TestBoardCameraAnnotations(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	conf := &PieceFinderConfig{Rotation: "180", CornerSource: cornerSourceImage}
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, conf)
+	test.That(t, err, test.ShouldBeNil)
+	want := map[string]string{}
+	for _, sq := range squares {
+		want[sq.name] = sq.name + ":" + pieceLabels[sq.color]
+	}
+
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 400, Margin: .05, Rotation: "180"}, input, pc)
+	images, _, err := bc.Images(ctx, []string{sourceWarped, sourceAnnotated}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	for _, ni := range images {
+		boxes := ni.Annotations.BoundingBoxes
+		test.That(t, len(boxes), test.ShouldEqual, 64)
+		for _, b := range boxes {
+			name, _, _ := strings.Cut(b.Label, ":")
+			test.That(t, b.Label, test.ShouldEqual, want[name])
+			test.That(t, b.XMinNormalized, test.ShouldBeLessThan, b.XMaxNormalized)
+			test.That(t, b.YMinNormalized, test.ShouldBeLessThan, b.YMaxNormalized)
+		}
+	}
+	// in the turned view e2 is fifth across and second from the bottom, 45 pixel squares 20 in
+	e2 := images[0].Annotations.BoundingBoxes[squareIndex('e', 2, 8)]
+	test.That(t, e2.Label, test.ShouldStartWith, "e2:")
+	test.That(t, e2.XMinNormalized, test.ShouldAlmostEqual, 200./400)
+	test.That(t, e2.YMinNormalized, test.ShouldAlmostEqual, 290./400)
+	test.That(t, e2.XMaxNormalized, test.ShouldAlmostEqual, 245./400)
+	test.That(t, e2.YMaxNormalized, test.ShouldAlmostEqual, 335./400)
+
+	// drawing them is up to the caller
+	plain, err := images[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	drawn, _, err := bc.Images(ctx, []string{sourceWarped}, map[string]interface{}{"draw": true})
+	test.That(t, err, test.ShouldBeNil)
+	img, err := drawn[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.(*image.RGBA).Pix, test.ShouldNotResemble, plain.(*image.RGBA).Pix)
+	test.That(t, drawn[0].Annotations, test.ShouldResemble, images[0].Annotations)
+
+	// without a point cloud they're just the squares
+	bc = newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Rotation: "180"}, input, nil)
+	images, _, err = bc.Images(ctx, []string{sourceWarped}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, images[0].Annotations.BoundingBoxes[squareIndex('e', 2, 8)].Label, test.ShouldEqual, "e2")
+}
+
+func TestBoardCameraScan(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 400, Rotation: "180"}, input, pc)
+	_, err = bc.DoCommand(ctx, map[string]interface{}{"scan": true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// the raw image alone doesn't look for the board
+	_, _, err = bc.Images(ctx, []string{sourceRaw}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = bc.DoCommand(ctx, map[string]interface{}{"scan": true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	images, _, err := bc.Images(ctx, []string{sourceWarped}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	resp, err := bc.DoCommand(ctx, map[string]interface{}{"scan": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["found"], test.ShouldBeTrue)
+	test.That(t, resp["took_ms"], test.ShouldBeGreaterThan, 0)
+	squares := resp["squares"].([]interface{})
+	test.That(t, len(squares), test.ShouldEqual, 64)
+	boxes := images[0].Annotations.BoundingBoxes
+	for i, s := range squares {
+		sq := s.(map[string]interface{})
+		test.That(t, boxes[i].Label, test.ShouldEqual, sq["name"].(string)+":"+sq["piece"].(string))
+		test.That(t, sq["points"], test.ShouldBeGreaterThan, 0)
+	}
+
+	// without a point cloud there's nothing on the squares to count
+	bc = newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Rotation: "180"}, input, nil)
+	_, _, err = bc.Images(ctx, []string{sourceWarped}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	resp, err = bc.DoCommand(ctx, map[string]interface{}{"scan": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["squares"], test.ShouldBeEmpty)
+	test.That(t, bc.(*BoardCamera).scan.summary(), test.ShouldContainSubstring, "no point cloud")
+}
+
+func TestBoardCameraCropped(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	boxes := func(frame image.Image) map[string]image.Rectangle {
+		// board1 wasn't taken with the RealSense, so it's not undistorted as one
+		bc := newTestBoardCameraProps(t, &BoardCameraConfig{Input: "cam", Rotation: "180"}, frame, nil, camera.Properties{})
+		images, _, err := bc.Images(ctx, []string{sourceAnnotated}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, bc.(*BoardCamera).last, test.ShouldNotBeNil)
+		size := frame.Bounds().Size()
+		out := map[string]image.Rectangle{}
+		for _, b := range images[0].Annotations.BoundingBoxes {
+			out[b.Label] = image.Rect(
+				int(b.XMinNormalized*float64(size.X)+.5), int(b.YMinNormalized*float64(size.Y)+.5),
+				int(b.XMaxNormalized*float64(size.X)+.5), int(b.YMaxNormalized*float64(size.Y)+.5))
+		}
+		return out
+	}
+	want := boxes(input)
+	test.That(t, len(want), test.ShouldEqual, 64)
+	// a1 is bottom left with white at 180, see gridPosition
+	test.That(t, want["a1"].Min.X, test.ShouldBeLessThan, want["h1"].Min.X)
+	test.That(t, want["a1"].Min.Y, test.ShouldBeGreaterThan, want["a8"].Min.Y)
+
+	// the squares are wherever the board is in the frame, whatever shape the frame is and
+	// wherever the board is in it
+	for _, r := range []image.Rectangle{
+		image.Rect(320, 0, 990, 720),  // portrait
+		image.Rect(330, 0, 1050, 720), // square, the board off to the left
+	} {
+		got := boxes(crop(input, r))
+		test.That(t, len(got), test.ShouldEqual, 64)
+		for name, b := range want {
+			g := got[name].Add(r.Min)
+			for _, d := range []image.Point{g.Min.Sub(b.Min), g.Max.Sub(b.Max)} {
+				test.That(t, d.X, test.ShouldBeBetween, -4, 4)
+				test.That(t, d.Y, test.ShouldBeBetween, -4, 4)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+const (
+	// referenceCornerContrast is how different (0-255) the two sides of the border next to a
+	// corner are, on average along its first cornerContrastSquares squares each way, for the
+	// corner to be fully trusted in a normally exposed frame. The light squares are about as
+	// light as the margin, so it's well under the contrast of a dark square.
+	referenceCornerContrast = 40
+	// cornerContrastSquares is how far along the border from a corner the contrast is sampled,
+	// a light square and a dark one whichever color the corner square is.
+	cornerContrastSquares = 2
+	// cornerContrastSamples is how many points along each of a corner's two border edges the
+	// contrast is sampled at.
+	cornerContrastSamples = 12
+	// cornerContrastOffset is how far (fraction of a square) to each side of the border they're sampled.
+	cornerContrastOffset = .15
+	// fallbackConfidence is how much of its confidence a corner keeps when one of its border
+	// lines was extended from the grid out of the frame, or had too few edge pixels to refine.
+	fallbackConfidence = .5
+)
+
+// withLines is res with where the border lines crossed before they were refined, and which of
+// its corners are on one that was extended from the grid or couldn't be refined, for
+// cornerConfidence. border and refined are top, bottom, left, right.
+func (res FindBoardResult) withLines(border, refined []Line) FindBoardResult {
+	if c, ok := borderCorners(border[0], border[1], border[2], border[3]); ok {
+		res.lineCorners = c.slice()
+	}
+	for ci, lines := range cornerLines {
+		for _, li := range lines {
+			// an extended line has no votes, and fitRefinedLine hands back one it can't refine
+			if border[li].votes == 0 || refined[li] == border[li] {
+				res.fallback[ci] = true
+			}
+		}
+	}
+	return res
+}
+
+// cornerConfidence is how sure the finder is of each of res's corners in img, 0 to 1: the
+// contrast across the border along the first squares from it each way next to
+// referenceCornerContrast, times how close it is to where the border lines crossed before they
+// were refined, next to saddleAgreement of a square, and fallbackConfidence if it came from a
+// fallback. A corner that's out of the frame has no contrast to go by and gets 0.
+func cornerConfidence(img image.Image, res FindBoardResult, opts BoardFinderOptions) [4]float64 {
+	var conf [4]float64
+	n := opts.gridSize()
+	h, err := computeHomography(res.SubPixelCorners, float64(n))
+	if err != nil {
+		return conf
+	}
+	toImage, err := h.Inverse()
+	if err != nil {
+		return conf
+	}
+	squares := cornerSquareSizes(subPixelCornersFromSlice(res.SubPixelCorners), n)
+
+	bounds := img.Bounds()
+	var px [1]uint8
+	gray := func(p r2.Point) (int, bool) {
+		pt := image.Point{X: int(math.Floor(p.X)), Y: int(math.Floor(p.Y))}.Add(bounds.Min)
+		if !pt.In(bounds) {
+			return 0, false
+		}
+		grayRow(img, pt.X, pt.Y, px[:])
+		return int(px[0]), true
+	}
+
+	side := float64(n)
+	// each corner on the board, and the directions along its two border edges
+	board := [4][3]r2.Point{
+		{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}},
+		{{X: side, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}},
+		{{X: side, Y: side}, {X: -1, Y: 0}, {X: 0, Y: -1}},
+		{{X: 0, Y: side}, {X: 1, Y: 0}, {X: 0, Y: -1}},
+	}
+	for ci, b := range board {
+		contrast, count := 0, 0
+		// along each edge, with the other one pointing into the board
+		for _, dirs := range [2][2]r2.Point{{b[1], b[2]}, {b[2], b[1]}} {
+			along, in := dirs[0], dirs[1].Mul(cornerContrastOffset)
+			for i := range cornerContrastSamples {
+				t := cornerContrastOffset + (cornerContrastSquares-cornerContrastOffset)*float64(i)/(cornerContrastSamples-1)
+				p := b[0].Add(along.Mul(t))
+				inside, ok1 := gray(toImage.Apply(p.Add(in)))
+				outside, ok2 := gray(toImage.Apply(p.Sub(in)))
+				if ok1 && ok2 {
+					contrast += max(inside-outside, outside-inside)
+					count++
+				}
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		c := min(1, float64(contrast)/float64(count)/opts.exposed(referenceCornerContrast))
+
+		if res.lineCorners != nil && squares[ci] > 0 {
+			d := res.SubPixelCorners[ci].Sub(res.lineCorners[ci]).Norm() / (saddleAgreement * squares[ci])
+			c /= 1 + d*d
+		}
+		if res.fallback[ci] {
+			c *= fallbackConfidence
+		}
+		conf[ci] = c
+	}
+	return conf
+}
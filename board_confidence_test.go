@@ -0,0 +1,78 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r2"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestCornerConfidence(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	opts := DefaultBoardFinderOptions()
+
+	res := FindBoardEx(input, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	t.Logf("confidence: %.2f", res.CornerConfidence)
+	for _, c := range res.CornerConfidence {
+		test.That(t, c, test.ShouldBeGreaterThan, .9)
+	}
+
+	// a fallback halves it, and so does the corner being an eighth of a square from where the
+	// border lines crossed
+	shaky := res
+	shaky.fallback[0] = true
+	shaky.lineCorners = append([]r2.Point(nil), res.SubPixelCorners...)
+	shaky.lineCorners[1] = shaky.lineCorners[1].Add(r2.Point{X: saddleAgreement * cornerSquareSizes(subPixelCornersFromSlice(res.SubPixelCorners), 8)[1]})
+	conf := cornerConfidence(input, shaky, opts)
+	test.That(t, conf[0], test.ShouldAlmostEqual, res.CornerConfidence[0]/2, .01)
+	test.That(t, conf[1], test.ShouldAlmostEqual, res.CornerConfidence[1]/2, .01)
+
+	// the BR corner painted over, so that's only found from the rest of its lines
+	flat := image.NewRGBA(input.Bounds())
+	draw.Draw(flat, flat.Bounds(), input, image.Point{}, draw.Src)
+	draw.Draw(flat, image.Rect(839, 565, 1039, 765), image.NewUniform(color.RGBA{120, 120, 120, 255}), image.Point{}, draw.Src)
+	res = FindBoardEx(flat, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	t.Logf("painted over: %.2f", res.CornerConfidence)
+	test.That(t, res.CornerConfidence[2], test.ShouldBeLessThan, defaultMinCornerConfidence)
+	for _, i := range []int{0, 1, 3} {
+		test.That(t, res.CornerConfidence[i], test.ShouldBeGreaterThan, .9)
+	}
+
+	test.That(t, notFoundResult(100, 100, NotFoundNoLines).CornerConfidence, test.ShouldResemble, [4]float64{})
+}
+
+func TestShakyCornerSquares(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	board := BoardCorners{image.Point{275, 7}, image.Point{952, 2}, image.Point{969, 683}, image.Point{271, 697}}
+	conf := &PieceFinderConfig{}
+
+	test.That(t, shakyCorners([4]float64{1, .5, .8, 0}, conf), test.ShouldResemble, [4]bool{false, true, false, true})
+	test.That(t, shakyCorners([4]float64{1, .5, .8, 0}, &PieceFinderConfig{MinCornerConfidence: -1}), test.ShouldResemble, [4]bool{})
+
+	sure, err := squaresOnBoard(nil, board, [4]bool{}, input, pc, touch.RealSenseProperties, conf, 8)
+	test.That(t, err, test.ShouldBeNil)
+	widened, err := squaresOnBoard(nil, board, [4]bool{false, false, true, false}, input, pc, touch.RealSenseProperties, conf, 8)
+	test.That(t, err, test.ShouldBeNil)
+
+	// only the square on the BR corner is any bigger
+	changed := 0
+	for i := range sure {
+		if sure[i].originalBounds != widened[i].originalBounds {
+			changed++
+			test.That(t, widened[i].originalBounds, test.ShouldResemble, board.squareBounds(7, 7, 8, defaultSquareMargin).Inset(-shakyCornerMargin))
+		}
+	}
+	test.That(t, changed, test.ShouldEqual, 1)
+}
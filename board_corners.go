@@ -0,0 +1,130 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// BoardCorners are the corners of the board where they are in the image, so TopLeft is the
+// corner at the top left of the picture, whichever square that happens to be.
+type BoardCorners struct {
+	TopLeft, TopRight, BottomRight, BottomLeft image.Point
+}
+
+// cornerNames are TL, TR, BR, BL for people.
+var cornerNames = [4]string{"top left", "top right", "bottom right", "bottom left"}
+
+// Slice is the corners in TL, TR, BR, BL order, for everything that still takes a slice.
+func (c BoardCorners) Slice() []image.Point {
+	return []image.Point{c.TopLeft, c.TopRight, c.BottomRight, c.BottomLeft}
+}
+
+// boardCornersFromSlice is BoardCorners from TL, TR, BR, BL.
+func boardCornersFromSlice(corners []image.Point) BoardCorners {
+	return BoardCorners{TopLeft: corners[0], TopRight: corners[1], BottomRight: corners[2], BottomLeft: corners[3]}
+}
+
+// check errors if the corners aren't a convex quad going clockwise from the top left.
+// Only corners sharing an edge are compared, so any perspective passes as long as the
+// board isn't turned sideways.
+func (c BoardCorners) check() error {
+	switch {
+	case c.TopLeft.X >= c.TopRight.X:
+		return fmt.Errorf("top left %v isn't left of top right %v", c.TopLeft, c.TopRight)
+	case c.BottomLeft.X >= c.BottomRight.X:
+		return fmt.Errorf("bottom left %v isn't left of bottom right %v", c.BottomLeft, c.BottomRight)
+	case c.TopLeft.Y >= c.BottomLeft.Y:
+		return fmt.Errorf("top left %v isn't above bottom left %v", c.TopLeft, c.BottomLeft)
+	case c.TopRight.Y >= c.BottomRight.Y:
+		return fmt.Errorf("top right %v isn't above bottom right %v", c.TopRight, c.BottomRight)
+	}
+
+	pts := c.Slice()
+	for i := range pts {
+		a, b, d := pts[i], pts[(i+1)%4], pts[(i+2)%4]
+		if cross(b.Sub(a), d.Sub(b)) <= 0 {
+			return fmt.Errorf("corners %v aren't a convex quad", c)
+		}
+	}
+	return nil
+}
+
+// squareCell is all of the square col, row counted from the top left of an n x n board. Like any
+// image.Rectangle it's [min, max), so a pixel on the line between two squares belongs to the right
+// or lower one, except that the last file and rank also get the board's far edge.
+func (c BoardCorners) squareCell(col, row, n int) image.Rectangle {
+	cell := c.squareRect(col, row, n)
+	if col == n-1 {
+		cell.Max.X++
+	}
+	if row == n-1 {
+		cell.Max.Y++
+	}
+	return cell
+}
+
+// squareBounds is the box inside squareRect that a square's pixels and points are classified
+// from, margin (under .5) of its width and height in from each side, clear of the lines between
+// the squares, the bases of the pieces on the next ones and any depth/RGB misalignment.
+func (c BoardCorners) squareBounds(col, row, n int, margin float64) image.Rectangle {
+	bounds := c.squareRect(col, row, n)
+	dx := int(math.Round(margin * float64(bounds.Dx())))
+	dy := int(math.Round(margin * float64(bounds.Dy())))
+	return image.Rect(bounds.Min.X+dx, bounds.Min.Y+dy, bounds.Max.X-dx, bounds.Max.Y-dy)
+}
+
+// squareRect is squareCell before the far edge is added. Neighbors share their edges exactly,
+// both sides are worked out by the same scale call.
+func (c BoardCorners) squareRect(col, row, n int) image.Rectangle {
+	side := float64(n)
+	colTopLeft := image.Point{
+		scale(c.TopLeft.X, c.TopRight.X, float64(col)/side),
+		scale(c.TopLeft.Y, c.TopRight.Y, float64(col)/side),
+	}
+
+	colTopRight := image.Point{
+		scale(c.TopLeft.X, c.TopRight.X, float64(1+col)/side),
+		scale(c.TopLeft.Y, c.TopRight.Y, float64(1+col)/side),
+	}
+
+	colBottomLeft := image.Point{
+		scale(c.BottomLeft.X, c.BottomRight.X, float64(col)/side),
+		scale(c.BottomLeft.Y, c.BottomRight.Y, float64(col)/side),
+	}
+
+	colBottomRight := image.Point{
+		scale(c.BottomLeft.X, c.BottomRight.X, float64(1+col)/side),
+		scale(c.BottomLeft.Y, c.BottomRight.Y, float64(1+col)/side),
+	}
+
+	return image.Rect(
+		scale(colTopLeft.X, colBottomLeft.X, float64(row)/side),
+		scale(colTopLeft.Y, colBottomLeft.Y, float64(row)/side),
+		scale(colTopRight.X, colBottomRight.X, float64(row+1)/side),
+		scale(colTopRight.Y, colBottomRight.Y, float64(row+1)/side),
+	)
+}
+
+// subPixelCorners is BoardCorners before rounding, what the border stages work on.
+type subPixelCorners struct {
+	topLeft, topRight, bottomRight, bottomLeft r2.Point
+}
+
+func subPixelCornersFromSlice(corners []r2.Point) subPixelCorners {
+	return subPixelCorners{topLeft: corners[0], topRight: corners[1], bottomRight: corners[2], bottomLeft: corners[3]}
+}
+
+// slice is the corners in TL, TR, BR, BL order.
+func (c subPixelCorners) slice() []r2.Point {
+	return []r2.Point{c.topLeft, c.topRight, c.bottomRight, c.bottomLeft}
+}
+
+func (c subPixelCorners) round() BoardCorners {
+	r := func(p r2.Point) image.Point {
+		return image.Point{X: int(math.Round(p.X)), Y: int(math.Round(p.Y))}
+	}
+	return BoardCorners{TopLeft: r(c.topLeft), TopRight: r(c.topRight), BottomRight: r(c.bottomRight), BottomLeft: r(c.bottomLeft)}
+}
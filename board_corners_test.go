@@ -0,0 +1,77 @@
+package viamchess
+
+import (
+	"image"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestBoardCornersCheck(t *testing.T) {
+	// seen from the near side, the far edge is shorter
+	skewed := BoardCorners{
+		TopLeft: image.Point{310, 120}, TopRight: image.Point{905, 140},
+		BottomRight: image.Point{1010, 690}, BottomLeft: image.Point{195, 660},
+	}
+	test.That(t, skewed.check(), test.ShouldBeNil)
+	test.That(t, boardCornersFromSlice(skewed.Slice()), test.ShouldResemble, skewed)
+
+	swapped := skewed
+	swapped.TopLeft, swapped.TopRight = swapped.TopRight, swapped.TopLeft
+	test.That(t, swapped.check(), test.ShouldNotBeNil)
+
+	flipped := skewed
+	flipped.TopLeft, flipped.BottomLeft = flipped.BottomLeft, flipped.TopLeft
+	test.That(t, flipped.check(), test.ShouldNotBeNil)
+
+	// the orders all hold, but the top right is pulled in past the diagonal
+	dented := skewed
+	dented.TopRight = image.Point{500, 500}
+	test.That(t, dented.check(), test.ShouldNotBeNil)
+}
+
+func TestFindBoardCornersStruct(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	board, err := FindBoardCorners(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, board.check(), test.ShouldBeNil)
+
+	corners, err := FindBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, board.Slice(), test.ShouldResemble, corners)
+
+	_, dbg, err := FindBoardDebug(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dbg.CornerError, test.ShouldBeNil)
+
+	for col := range 8 {
+		test.That(t, board.squareCell(col, 3, 8), test.ShouldResemble, computeSquareBounds(corners, col, 3, 8))
+	}
+}
+
+func TestFoundBoardsCornersCheck(t *testing.T) {
+	// every board found in the test images has to come out in TL, TR, BR, BL order
+	files, err := filepath.Glob("data/board*.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	found := 0
+	for _, fn := range files {
+		if strings.HasSuffix(fn, "_output.jpg") {
+			continue
+		}
+		input, err := rimage.ReadImageFromFile(fn)
+		test.That(t, err, test.ShouldBeNil)
+		res := FindBoardEx(input, DefaultBoardFinderOptions())
+		if !res.Found {
+			continue
+		}
+		found++
+		test.That(t, res.Board.check(), test.ShouldBeNil)
+		test.That(t, res.Board.Slice(), test.ShouldResemble, res.Corners)
+	}
+	test.That(t, found, test.ShouldBeGreaterThan, 0)
+}
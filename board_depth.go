@@ -0,0 +1,557 @@
+package viamchess
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	// planeTolerance is how far (mm) a point can be from the table to be on it. Pieces stick up
+	// more than that, the depth noise at arm's length is less.
+	planeTolerance = 6
+	// planeSamples is about how many points the plane is fit to, and planeIterations how many
+	// planes through three of them are tried.
+	planeSamples    = 8000
+	planeIterations = 200
+	// minPlaneSquare is the smallest (mm) a board's square can be.
+	minPlaneSquare = 15
+	// maxLatticeError is how far (fraction of a square) a dark square's middle can be from the
+	// grid through them all and still be part of it.
+	maxLatticeError = .08
+)
+
+// errNoPlaneBoard is when there's no board on the table in the point cloud, or no table.
+var errNoPlaneBoard = errors.New("no board on the plane")
+
+// FindBoardFromPointCloud finds the corners (TL, TR, BR, BL) of a chess board lying on a table
+// in pc, a point cloud from the camera props are for, in the camera's image. See
+// findBoardFromPointCloud.
+func FindBoardFromPointCloud(pc pointcloud.PointCloud, props camera.Properties) ([]image.Point, error) {
+	corners, err := findBoardFromPointCloud(pc, props, defaultGridSize)
+	if err != nil {
+		return nil, err
+	}
+	return corners.round().Slice(), nil
+}
+
+// findBoardFromPointCloud is FindBoardFromPointCloud for an n x n board, to sub-pixel precision.
+//
+// The table is the plane the most points are on, found with RANSAC, and the points on it are
+// drawn top down, so the board is a square whatever the camera's angle. Its dark squares are
+// the dark patches there about the same size and shape as each other, and the lattice through
+// their middles, fit with least squares, is the board's grid. The n x n squares of the lattice
+// where light and dark alternate best are the board, whose corners are then put back in the
+// image through the camera's intrinsics.
+func findBoardFromPointCloud(pc pointcloud.PointCloud, props camera.Properties, n int) (subPixelCorners, error) {
+	ip := props.IntrinsicParams
+	if ip == nil || ip.Fx == 0 || ip.Fy == 0 {
+		return subPixelCorners{}, errors.New("need camera intrinsics to find the board in a point cloud")
+	}
+	if pc == nil || pc.Size() < 3 {
+		return subPixelCorners{}, errNoPlaneBoard
+	}
+
+	plane, err := fitTablePlane(pc)
+	if err != nil {
+		return subPixelCorners{}, err
+	}
+	r := newPlaneRaster(pc, plane, ip.Fx)
+
+	lattice, err := r.darkSquares()
+	if err != nil {
+		return subPixelCorners{}, err
+	}
+	i0, j0, err := r.placeBoard(lattice, n)
+	if err != nil {
+		return subPixelCorners{}, err
+	}
+
+	corners := make([]r2.Point, 4)
+	for k, c := range []r2.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}} {
+		uv := lattice.apply(float64(i0)-.5+c.X*float64(n), float64(j0)-.5+c.Y*float64(n))
+		p := plane.point(uv)
+		if p.Z <= 0 {
+			return subPixelCorners{}, fmt.Errorf("board corner %v is behind the camera", p)
+		}
+		corners[k] = r2.Point{X: p.X/p.Z*ip.Fx + ip.Ppx, Y: p.Y/p.Z*ip.Fy + ip.Ppy}
+	}
+	res := subPixelCornersFromSlice(corners)
+	if err := res.round().check(); err != nil {
+		return subPixelCorners{}, err
+	}
+	return res, nil
+}
+
+// tablePlane is z = a x + b y + c in camera coordinates, with u and v axes along it, u the
+// camera's x axis seen from above the plane and v its y axis.
+type tablePlane struct {
+	a, b, c      float64
+	origin, u, v r3.Vector
+}
+
+// distance is how far p is from the plane.
+func (t tablePlane) distance(p r3.Vector) float64 {
+	return math.Abs(t.a*p.X+t.b*p.Y+t.c-p.Z) / math.Sqrt(t.a*t.a+t.b*t.b+1)
+}
+
+// height is how far p is above the plane, toward the camera, negative below it.
+func (t tablePlane) height(p r3.Vector) float64 {
+	return (t.a*p.X + t.b*p.Y + t.c - p.Z) / math.Sqrt(t.a*t.a+t.b*t.b+1)
+}
+
+// coords is where p is on the plane, along u and v.
+func (t tablePlane) coords(p r3.Vector) r2.Point {
+	d := p.Sub(t.origin)
+	return r2.Point{X: d.Dot(t.u), Y: d.Dot(t.v)}
+}
+
+// point is the point at uv on the plane.
+func (t tablePlane) point(uv r2.Point) r3.Vector {
+	return t.origin.Add(t.u.Mul(uv.X)).Add(t.v.Mul(uv.Y))
+}
+
+// fitTablePlane is the plane most of the points in pc are within planeTolerance of: the best of
+// planeIterations random planes through three of about planeSamples points, then the least
+// squares fit to the samples on it.
+func fitTablePlane(pc pointcloud.PointCloud) (tablePlane, error) {
+	step := max(1, pc.Size()/planeSamples)
+	samples := make([]r3.Vector, 0, planeSamples+1)
+	k := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if k%step == 0 && p.Z > 0 {
+			samples = append(samples, p)
+		}
+		k++
+		return true
+	})
+	if len(samples) < 3 {
+		return tablePlane{}, errNoPlaneBoard
+	}
+
+	// the same planes every time for the same cloud
+	rnd := rand.New(rand.NewSource(1))
+	var best tablePlane
+	bestCount := 0
+	for range planeIterations {
+		p1, p2, p3 := samples[rnd.Intn(len(samples))], samples[rnd.Intn(len(samples))], samples[rnd.Intn(len(samples))]
+		normal := p2.Sub(p1).Cross(p3.Sub(p1))
+		if math.Abs(normal.Z) < 1e-9*normal.Norm() || normal.Norm() == 0 {
+			continue
+		}
+		// a x + b y + c = z through p1
+		t := tablePlane{a: -normal.X / normal.Z, b: -normal.Y / normal.Z}
+		t.c = p1.Z - t.a*p1.X - t.b*p1.Y
+		count := 0
+		for _, p := range samples {
+			if t.distance(p) < planeTolerance {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = t, count
+		}
+	}
+	if bestCount < len(samples)/10 {
+		return tablePlane{}, errNoPlaneBoard
+	}
+
+	// least squares over the inliers, the normal equations of z = a x + b y + c
+	var m [3][4]float64
+	var centroid r3.Vector
+	count := 0
+	for _, p := range samples {
+		if best.distance(p) >= planeTolerance {
+			continue
+		}
+		row := [3]float64{p.X, p.Y, 1}
+		for i := range 3 {
+			for j := range 3 {
+				m[i][j] += row[i] * row[j]
+			}
+			m[i][3] += row[i] * p.Z
+		}
+		centroid = centroid.Add(p)
+		count++
+	}
+	if abc, ok := solve3(m); ok {
+		best.a, best.b, best.c = abc[0], abc[1], abc[2]
+	}
+
+	centroid = centroid.Mul(1 / float64(count))
+	best.origin = r3.Vector{X: centroid.X, Y: centroid.Y, Z: best.a*centroid.X + best.b*centroid.Y + best.c}
+	// the normal toward the camera, which is at the origin looking along z
+	normal := r3.Vector{X: best.a, Y: best.b, Z: -1}.Normalize()
+	x := r3.Vector{X: 1}
+	best.u = x.Sub(normal.Mul(x.Dot(normal))).Normalize()
+	best.v = best.u.Cross(normal)
+	return best, nil
+}
+
+// solve3 solves the augmented 3x3 system m, false if it's singular.
+func solve3(m [3][4]float64) ([3]float64, bool) {
+	det := func(c0, c1, c2 int) float64 {
+		return m[0][c0]*(m[1][c1]*m[2][c2]-m[1][c2]*m[2][c1]) -
+			m[0][c1]*(m[1][c0]*m[2][c2]-m[1][c2]*m[2][c0]) +
+			m[0][c2]*(m[1][c0]*m[2][c1]-m[1][c1]*m[2][c0])
+	}
+	d := det(0, 1, 2)
+	if math.Abs(d) < 1e-12 {
+		return [3]float64{}, false
+	}
+	// Cramer's rule, the constants in place of each column in turn
+	return [3]float64{det(3, 1, 2) / d, det(0, 3, 2) / d, det(0, 1, 3) / d}, true
+}
+
+// planeRaster is the points on the table drawn from above, cell mm a pixel, with the plane's
+// u, v at min the top left corner.
+type planeRaster struct {
+	width, height int
+	cell          float64
+	min           r2.Point
+	// gray is the average gray of the points in each pixel, has whether there are any
+	gray []uint8
+	has  []bool
+}
+
+// newPlaneRaster draws the points of pc on plane from above, with about four points to a pixel
+// for a camera with focal length fx (pixels).
+func newPlaneRaster(pc pointcloud.PointCloud, plane tablePlane, fx float64) *planeRaster {
+	// how far apart the points are where the plane crosses the camera's axis
+	cell := 2 * plane.origin.Norm() / fx
+
+	type onPlane struct {
+		uv   r2.Point
+		gray uint8
+	}
+	points := make([]onPlane, 0, pc.Size())
+	lo, hi := r2.Point{X: math.Inf(1), Y: math.Inf(1)}, r2.Point{X: math.Inf(-1), Y: math.Inf(-1)}
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z <= 0 || d == nil || !d.HasColor() || plane.distance(p) >= planeTolerance {
+			return true
+		}
+		uv := plane.coords(p)
+		lo = r2.Point{X: math.Min(lo.X, uv.X), Y: math.Min(lo.Y, uv.Y)}
+		hi = r2.Point{X: math.Max(hi.X, uv.X), Y: math.Max(hi.Y, uv.Y)}
+		r, g, b := d.RGB255()
+		points = append(points, onPlane{uv, uint8((19595*uint32(r) + 38470*uint32(g) + 7471*uint32(b) + 1<<15) >> 16)})
+		return true
+	})
+
+	res := &planeRaster{cell: cell, min: lo}
+	if len(points) == 0 {
+		return res
+	}
+	res.width = int((hi.X-lo.X)/cell) + 1
+	res.height = int((hi.Y-lo.Y)/cell) + 1
+	sums := make([]int, res.width*res.height)
+	counts := make([]int, res.width*res.height)
+	for _, p := range points {
+		i, ok := res.index(p.uv)
+		if ok {
+			sums[i] += int(p.gray)
+			counts[i]++
+		}
+	}
+	res.gray = make([]uint8, len(sums))
+	res.has = make([]bool, len(sums))
+	for i, c := range counts {
+		if c > 0 {
+			res.gray[i] = uint8(sums[i] / c)
+			res.has[i] = true
+		}
+	}
+	return res
+}
+
+// index is the pixel uv is in, false if it's off the raster.
+func (r *planeRaster) index(uv r2.Point) (int, bool) {
+	x, y := int((uv.X-r.min.X)/r.cell), int((uv.Y-r.min.Y)/r.cell)
+	if x < 0 || y < 0 || x >= r.width || y >= r.height {
+		return 0, false
+	}
+	return y*r.width + x, true
+}
+
+// center is the plane coordinates of the middle of pixel i.
+func (r *planeRaster) center(i int) r2.Point {
+	return r2.Point{X: r.min.X + (float64(i%r.width)+.5)*r.cell, Y: r.min.Y + (float64(i/r.width)+.5)*r.cell}
+}
+
+// planeLattice maps a board square's i, j to its middle on the plane,
+// u = u0 + ui i + uj j and v likewise.
+type planeLattice struct {
+	u0, ui, uj, v0, vi, vj float64
+	// squares are the dark squares it was fit to, and dark whether i+j is odd for them
+	squares [][2]int
+	dark    int
+}
+
+func (l planeLattice) apply(i, j float64) r2.Point {
+	return r2.Point{X: l.u0 + l.ui*i + l.uj*j, Y: l.v0 + l.vi*i + l.vj*j}
+}
+
+// invert is the i, j (fractional) of the square whose middle is at uv.
+func (l planeLattice) invert(uv r2.Point) (float64, float64) {
+	du, dv := uv.X-l.u0, uv.Y-l.v0
+	det := l.ui*l.vj - l.uj*l.vi
+	return (du*l.vj - dv*l.uj) / det, (dv*l.ui - du*l.vi) / det
+}
+
+// darkSquares is the lattice through the middles of the dark squares. They're the patches darker
+// than Otsu's split of the raster, filling enough of the box around them to be a square turned
+// any way, and of the most common size, at least minPlaneSquare across.
+func (r *planeRaster) darkSquares() (planeLattice, error) {
+	var hist [256]int
+	for i, h := range r.has {
+		if h {
+			hist[r.gray[i]]++
+		}
+	}
+	level, _, _, separation := otsuSplit(&hist)
+	if separation < minOtsuSeparation {
+		return planeLattice{}, errNoPlaneBoard
+	}
+
+	dark := make([]bool, len(r.gray))
+	for i, h := range r.has {
+		dark[i] = h && int(r.gray[i]) < level
+	}
+	// less a pixel all round, so squares touching at the corners come apart
+	mask := make([]bool, len(dark))
+	for y := 1; y < r.height-1; y++ {
+		for x := 1; x < r.width-1; x++ {
+			i := y*r.width + x
+			mask[i] = dark[i]
+			for dy := -1; dy <= 1 && mask[i]; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if !dark[i+dy*r.width+dx] {
+						mask[i] = false
+						break
+					}
+				}
+			}
+		}
+	}
+	labels, sizes := labelComponents(mask, r.width, r.height)
+	boxes := make([]image.Rectangle, len(sizes))
+	for i := range boxes {
+		boxes[i] = image.Rectangle{Min: image.Point{r.width, r.height}}
+	}
+	sums := make([]r2.Point, len(sizes))
+	for i, l := range labels {
+		if l != 0 {
+			x, y := i%r.width, i/r.width
+			b := &boxes[l]
+			b.Min = image.Point{min(b.Min.X, x), min(b.Min.Y, y)}
+			b.Max = image.Point{max(b.Max.X, x+1), max(b.Max.Y, y+1)}
+			sums[l] = sums[l].Add(r.center(i))
+		}
+	}
+
+	minArea := int(math.Pow(minPlaneSquare/r.cell, 2))
+	type patch struct {
+		area   int
+		middle r2.Point
+	}
+	patches := []patch{}
+	for l := 1; l < len(sizes); l++ {
+		box := boxes[l]
+		w, h := float64(box.Dx()), float64(box.Dy())
+		if sizes[l] < minArea || max(w, h) > 1.6*min(w, h) || float64(sizes[l]) < .45*w*h {
+			continue
+		}
+		patches = append(patches, patch{sizes[l], sums[l].Mul(1 / float64(sizes[l]))})
+	}
+
+	// the most patches within a factor of 1.5 of each other's area
+	sort.Slice(patches, func(a, b int) bool { return patches[a].area < patches[b].area })
+	from, to := 0, 0
+	for lo, hi := 0, 0; hi < len(patches); hi++ {
+		for float64(patches[hi].area) > 1.5*float64(patches[lo].area) {
+			lo++
+		}
+		if hi+1-lo > to-from {
+			from, to = lo, hi+1
+		}
+	}
+	patches = patches[from:to]
+	if len(patches) < 4 {
+		return planeLattice{}, fmt.Errorf("%w: only %d dark squares", errNoPlaneBoard, len(patches))
+	}
+
+	// each dark square's nearest is diagonal from it, which says which way the grid runs and
+	// how big its squares are
+	var turn r2.Point
+	gaps := []float64{}
+	for a, p := range patches {
+		nearest := r2.Point{}
+		for b, q := range patches {
+			if a != b && (nearest == r2.Point{} || q.middle.Sub(p.middle).Norm() < nearest.Norm()) {
+				nearest = q.middle.Sub(p.middle)
+			}
+		}
+		// four times the angle, so the four diagonals agree
+		angle := 4 * math.Atan2(nearest.Y, nearest.X)
+		turn = turn.Add(r2.Point{X: math.Cos(angle), Y: math.Sin(angle)})
+		gaps = append(gaps, nearest.Norm())
+	}
+	sort.Float64s(gaps)
+	size := gaps[len(gaps)/2] / math.Sqrt2
+	// a diagonal is at 45 degrees to the grid, which is a quarter turn of 4 times the angle
+	theta := (math.Atan2(turn.Y, turn.X) - math.Pi) / 4
+	if theta < -math.Pi/4 {
+		// within 45 degrees of u, so i runs along u and j along v
+		theta += math.Pi / 2
+	}
+	axisI := r2.Point{X: math.Cos(theta), Y: math.Sin(theta)}
+	axisJ := r2.Point{X: -axisI.Y, Y: axisI.X}
+
+	// starting from the first square, each one's i, j is where the lattice so far puts it
+	origin := patches[0].middle
+	lattice := planeLattice{u0: origin.X, ui: axisI.X * size, uj: axisJ.X * size, v0: origin.Y, vi: axisI.Y * size, vj: axisJ.Y * size}
+	for pass := range 4 {
+		// after the first fit, only the squares it fits well, not the ones a piece covers
+		// part of, which pulls their middles off
+		limit := .25
+		if pass > 0 {
+			limit = maxLatticeError
+		}
+		var mu, mv [3][4]float64
+		lattice.squares = lattice.squares[:0]
+		odd := 0
+		for _, p := range patches {
+			fi, fj := lattice.invert(p.middle)
+			i, j := int(math.Round(fi)), int(math.Round(fj))
+			if math.Hypot(fi-float64(i), fj-float64(j)) > limit {
+				continue
+			}
+			lattice.squares = append(lattice.squares, [2]int{i, j})
+			odd += (i + j) & 1
+			row := [3]float64{1, float64(i), float64(j)}
+			for a := range 3 {
+				for b := range 3 {
+					mu[a][b] += row[a] * row[b]
+					mv[a][b] += row[a] * row[b]
+				}
+				mu[a][3] += row[a] * p.middle.X
+				mv[a][3] += row[a] * p.middle.Y
+			}
+		}
+		if len(lattice.squares) < 4 {
+			return planeLattice{}, fmt.Errorf("%w: dark squares aren't a grid", errNoPlaneBoard)
+		}
+		pu, ok1 := solve3(mu)
+		pv, ok2 := solve3(mv)
+		if !ok1 || !ok2 {
+			return planeLattice{}, fmt.Errorf("%w: dark squares aren't a grid", errNoPlaneBoard)
+		}
+		lattice.u0, lattice.ui, lattice.uj = pu[0], pu[1], pu[2]
+		lattice.v0, lattice.vi, lattice.vj = pv[0], pv[1], pv[2]
+		lattice.dark = 0
+		if 2*odd > len(lattice.squares) {
+			lattice.dark = 1
+		}
+	}
+	return lattice, nil
+}
+
+// placeBoard is the first square, i0, j0, of the n x n squares of lattice the board is: of
+// the ones with every dark square on them, the one whose squares alternate light and dark the
+// most like the board's.
+func (r *planeRaster) placeBoard(lattice planeLattice, n int) (int, int, error) {
+	iMin, iMax, jMin, jMax := math.MaxInt, math.MinInt, math.MaxInt, math.MinInt
+	for _, s := range lattice.squares {
+		if (s[0]+s[1])&1 != lattice.dark {
+			continue
+		}
+		iMin, iMax = min(iMin, s[0]), max(iMax, s[0])
+		jMin, jMax = min(jMin, s[1]), max(jMax, s[1])
+	}
+	if iMax-iMin >= n || jMax-jMin >= n {
+		return 0, 0, fmt.Errorf("%w: dark squares span more than %d squares", errNoPlaneBoard, n)
+	}
+
+	// each square's average gray, from the middle 60% of it, -1 when none of it is on the plane
+	means := map[[2]int]float64{}
+	squareMean := func(i, j int) float64 {
+		if m, ok := means[[2]int{i, j}]; ok {
+			return m
+		}
+		sum, count := 0, 0
+		for a := range 5 {
+			for b := range 5 {
+				uv := lattice.apply(float64(i)-.3+.15*float64(a), float64(j)-.3+.15*float64(b))
+				if k, ok := r.index(uv); ok && r.has[k] {
+					sum += int(r.gray[k])
+					count++
+				}
+			}
+		}
+		m := -1.0
+		if count > 0 {
+			m = float64(sum) / float64(count)
+		}
+		means[[2]int{i, j}] = m
+		return m
+	}
+
+	bestI, bestJ, bestScore := 0, 0, math.Inf(-1)
+	for i0 := iMax - n + 1; i0 <= iMin; i0++ {
+		for j0 := jMax - n + 1; j0 <= jMin; j0++ {
+			// the light squares' gray less the dark squares'
+			score := 0.0
+			for i := i0; i < i0+n; i++ {
+				for j := j0; j < j0+n; j++ {
+					m := squareMean(i, j)
+					if m < 0 {
+						continue
+					}
+					if (i+j)&1 == lattice.dark {
+						score -= m
+					} else {
+						score += m
+					}
+				}
+			}
+			if score > bestScore {
+				bestI, bestJ, bestScore = i0, j0, score
+			}
+		}
+	}
+	return bestI, bestJ, nil
+}
+
+// Where PieceFinderConfig.CornerSource says the corners come from.
+const (
+	cornerSourcePointCloud = "point-cloud"
+	cornerSourceImage      = "image"
+)
+
+func validateCornerSource(source string) error {
+	switch source {
+	case "", cornerSourcePointCloud, cornerSourceImage:
+		return nil
+	}
+	return fmt.Errorf("bad corner-source %q, needs to be %s or %s", source, cornerSourcePointCloud, cornerSourceImage)
+}
+
+// pointCloudBoard is findBoardFromPointCloud as a FindBoardResult for img, the frame pc goes with,
+// put through the same checks as the board finder's. Not found, with no reason, when the board
+// isn't on the plane.
+func pointCloudBoard(img image.Image, pc pointcloud.PointCloud, props camera.Properties, opts BoardFinderOptions) FindBoardResult {
+	corners, err := findBoardFromPointCloud(pc, props, opts.gridSize())
+	if err != nil {
+		return FindBoardResult{}
+	}
+	return checkFoundBoard(img, foundResult(corners), opts)
+}
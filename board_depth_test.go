@@ -0,0 +1,131 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/test"
+)
+
+// tiltedBoardCloud is what a 640x480 depth camera sees of a board of 40 mm squares, with a
+// white margin, on a dark table 600 mm away, tilted 15 degrees toward the camera and the board
+// turned 5 degrees on it, a pawn sized bump on a few squares. It also returns where the board's
+// corners are in the image.
+func tiltedBoardCloud(t *testing.T) (pointcloud.PointCloud, camera.Properties, []r2.Point) {
+	t.Helper()
+	ip := &transform.PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 600, Fy: 600, Ppx: 320, Ppy: 240}
+	tilt, turn := 15*math.Pi/180, 5*math.Pi/180
+	origin := r3.Vector{Z: 600}
+	normal := r3.Vector{Y: math.Sin(tilt), Z: -math.Cos(tilt)}
+	u := r3.Vector{X: 1}
+	v := u.Cross(normal)
+	rotate := func(p r2.Point, a float64) r2.Point {
+		return r2.Point{X: p.X*math.Cos(a) - p.Y*math.Sin(a), Y: p.X*math.Sin(a) + p.Y*math.Cos(a)}
+	}
+	const square = 40.0
+	half := 4 * square
+
+	pc := pointcloud.NewBasicEmpty()
+	for y := range ip.Height {
+		for x := range ip.Width {
+			ray := r3.Vector{X: (float64(x) + .5 - ip.Ppx) / ip.Fx, Y: (float64(y) + .5 - ip.Ppy) / ip.Fy, Z: 1}
+			p := ray.Mul(origin.Dot(normal) / ray.Dot(normal))
+			d := p.Sub(origin)
+			// where it is on the board
+			b := rotate(r2.Point{X: d.Dot(u), Y: d.Dot(v)}, -turn)
+			c := color.NRGBA{40, 35, 30, 255}
+			switch {
+			case math.Abs(b.X) < half && math.Abs(b.Y) < half:
+				col, row := int(math.Floor((b.X+half)/square)), int(math.Floor((b.Y+half)/square))
+				c = color.NRGBA{230, 230, 225, 255}
+				if (col+row)%2 == 1 {
+					c = color.NRGBA{30, 90, 70, 255}
+				}
+				mid := r2.Point{X: (float64(col)+.5)*square - half, Y: (float64(row)+.5)*square - half}
+				if (row == 1 || row == 6) && b.Sub(mid).Norm() < 12 {
+					p = p.Mul(1 - 40/p.Norm())
+					c = color.NRGBA{250, 240, 220, 255}
+				}
+			case math.Abs(b.X) < half+20 && math.Abs(b.Y) < half+20:
+				c = color.NRGBA{235, 235, 230, 255}
+			}
+			test.That(t, pc.Set(p, pointcloud.NewColoredData(c)), test.ShouldBeNil)
+		}
+	}
+
+	corners := []r2.Point{}
+	for _, c := range []r2.Point{{X: -half, Y: -half}, {X: half, Y: -half}, {X: half, Y: half}, {X: -half, Y: half}} {
+		b := rotate(c, turn)
+		p := origin.Add(u.Mul(b.X)).Add(v.Mul(b.Y))
+		corners = append(corners, r2.Point{X: p.X/p.Z*ip.Fx + ip.Ppx, Y: p.Y/p.Z*ip.Fy + ip.Ppy})
+	}
+	return pc, camera.Properties{IntrinsicParams: ip}, corners
+}
+
+func TestFindBoardFromPointCloudTilted(t *testing.T) {
+	pc, props, expected := tiltedBoardCloud(t)
+	corners, err := findBoardFromPointCloud(pc, props, defaultGridSize)
+	test.That(t, err, test.ShouldBeNil)
+	worst := 0.0
+	for i, c := range corners.slice() {
+		worst = max(worst, c.Sub(expected[i]).Norm())
+	}
+	t.Logf("%v, expected %v (%.2f)", corners.slice(), expected, worst)
+	test.That(t, worst, test.ShouldBeLessThan, 1.5)
+
+	// a table with nothing on it
+	empty := pointcloud.NewBasicEmpty()
+	for y := -200.0; y < 200; y += 2 {
+		for x := -300.0; x < 300; x += 2 {
+			test.That(t, empty.Set(r3.Vector{X: x, Y: y, Z: 600}, pointcloud.NewColoredData(color.NRGBA{40, 35, 30, 255})), test.ShouldBeNil)
+		}
+	}
+	_, err = findBoardFromPointCloud(empty, props, defaultGridSize)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = findBoardFromPointCloud(pc, camera.Properties{}, defaultGridSize)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestFindBoardFromPointCloud(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{275, 7}, {952, 2}, {969, 683}, {271, 697}}
+
+	// the points of board4.pcd don't land quite on the same things in board4.jpg, about 11 pixels
+	// right and 8 down of them on average and more toward the top left, and the corners follow
+	// the points. The tilted board above is the test of how close they are.
+	corners, err := FindBoardFromPointCloud(pc, touch.RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("%v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 30)
+
+	// the piece finder goes by them, unless it's told to use the image, and finds the same pieces
+	col, row := gridPosition('a', 1, Rotation0, defaultGridSize)
+	fromCloud, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromCloud[0].originalBounds, test.ShouldResemble, boardCornersFromSlice(corners).squareBounds(col, row, defaultGridSize, defaultSquareMargin))
+
+	fromImage, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{CornerSource: "image"})
+	test.That(t, err, test.ShouldBeNil)
+	imageCorners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromImage[0].originalBounds, test.ShouldResemble, boardCornersFromSlice(imageCorners).squareBounds(col, row, defaultGridSize, defaultSquareMargin))
+	for i := range fromCloud {
+		test.That(t, fromCloud[i].color, test.ShouldEqual, fromImage[i].color)
+	}
+
+	test.That(t, validateCornerSource("depth"), test.ShouldNotBeNil)
+}
@@ -0,0 +1,134 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// defaultDiffConfidence is how sure of a square both captures have to be for diff_from_last to
+// count it.
+const defaultDiffConfidence = .5
+
+// SquareChange is one square that's different between two captures.
+type SquareChange struct {
+	Before, After Square
+	// Confidence is the lower of the two captures' confidence in the square.
+	Confidence float64
+}
+
+// BoardDiff is which squares changed between two captures, see DiffBoards, each list in a1,
+// b1 ... h8 order.
+type BoardDiff struct {
+	Emptied   []SquareChange // had a piece, now don't
+	Occupied  []SquareChange // didn't, now do
+	Recolored []SquareChange // had a piece and still do, of the other color, a capture
+	// Unsure is the squares that look different but aren't in the lists, one of the captures
+	// being less than the minimum confidence sure of them.
+	Unsure []string
+}
+
+// Empty is whether nothing changed.
+func (d BoardDiff) Empty() bool {
+	return len(d.Emptied) == 0 && len(d.Occupied) == 0 && len(d.Recolored) == 0
+}
+
+// DiffBoards is what changed from before to after, leaving out the squares either of them is
+// less than minConfidence sure of.
+func DiffBoards(before, after Board64, minConfidence float64) BoardDiff {
+	d := BoardDiff{}
+	for i := range before {
+		b, a := before[i], after[i]
+		confidence := min(b.Confidence, a.Confidence)
+		if b.Color == a.Color {
+			continue
+		}
+		if confidence < minConfidence {
+			d.Unsure = append(d.Unsure, b.Name)
+			continue
+		}
+		change := SquareChange{Before: b, After: a, Confidence: confidence}
+		switch {
+		case !a.Occupied:
+			d.Emptied = append(d.Emptied, change)
+		case !b.Occupied:
+			d.Occupied = append(d.Occupied, change)
+		default:
+			d.Recolored = append(d.Recolored, change)
+		}
+	}
+	return d
+}
+
+// toMap is d for a DoCommand response: each change as its square, colors before and after and
+// confidence.
+func (d BoardDiff) toMap() map[string]interface{} {
+	changes := func(list []SquareChange) []interface{} {
+		out := make([]interface{}, 0, len(list))
+		for _, c := range list {
+			out = append(out, map[string]interface{}{
+				"square":     c.After.Name,
+				"before":     c.Before.PieceColor,
+				"after":      c.After.PieceColor,
+				"confidence": c.Confidence,
+			})
+		}
+		return out
+	}
+	unsure := make([]interface{}, 0, len(d.Unsure))
+	for _, name := range d.Unsure {
+		unsure = append(unsure, name)
+	}
+	return map[string]interface{}{
+		"emptied":   changes(d.Emptied),
+		"occupied":  changes(d.Occupied),
+		"recolored": changes(d.Recolored),
+		"unsure":    unsure,
+		"changed":   !d.Empty(),
+	}
+}
+
+// diffFromLast is {"diff_from_last": true}: a capture, and what changed since the one before
+// it, leaving out squares either is less than minConfidence (0 means defaultDiffConfidence)
+// sure of.
+func (bc *PieceFinder) diffFromLast(ctx context.Context, minConfidence float64) (map[string]interface{}, error) {
+	if minConfidence == 0 {
+		minConfidence = defaultDiffConfidence
+	}
+	bc.captureLock.Lock()
+	var before []squareInfo
+	if bc.last != nil {
+		// never changed once it's in a lastAnalysis
+		before = bc.last.squares
+	}
+	bc.captureLock.Unlock()
+
+	var after []squareInfo
+	_, err := bc.captureWith(ctx, viscapture.CaptureOptions{}, nil, func(_ viscapture.VisCapture, served *lastAnalysis) error {
+		if served != nil {
+			after = served.squares
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if after == nil {
+		return nil, errors.New("diff_from_last: the board couldn't be analyzed, see status")
+	}
+	if before == nil {
+		return nil, errors.New("diff_from_last: no capture before this one to diff from, there is now")
+	}
+	a, err := newBoard64(after)
+	if err != nil {
+		return nil, fmt.Errorf("diff_from_last: %w", err)
+	}
+	b, err := newBoard64(before)
+	if err != nil {
+		return nil, fmt.Errorf("diff_from_last: %w", err)
+	}
+	return DiffBoards(b, a, minConfidence).toMap(), nil
+}
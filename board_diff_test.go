@@ -0,0 +1,119 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// boardOf is the Board64 of the pieces in fen, every square surely seen.
+func boardOf(t *testing.T, fen string) Board64 {
+	t.Helper()
+	opt, err := chess.FEN(fen)
+	test.That(t, err, test.ShouldBeNil)
+	return positionBoard(chess.NewGame(opt).Position())
+}
+
+func TestDiffBoards(t *testing.T) {
+	start := boardOf(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	e4 := boardOf(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+
+	d := DiffBoards(start, e4, .5)
+	test.That(t, changedNames(d.Emptied), test.ShouldResemble, []string{"e2"})
+	test.That(t, changedNames(d.Occupied), test.ShouldResemble, []string{"e4"})
+	test.That(t, d.Recolored, test.ShouldBeEmpty)
+	test.That(t, d.Occupied[0].Before.PieceColor, test.ShouldEqual, "empty")
+	test.That(t, d.Occupied[0].After.PieceColor, test.ShouldEqual, "white")
+	test.That(t, DiffBoards(e4, e4, .5).Empty(), test.ShouldBeTrue)
+
+	// exd5
+	d5 := boardOf(t, "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	exd5 := boardOf(t, "rnbqkbnr/ppp1pppp/8/3P4/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2")
+	d = DiffBoards(d5, exd5, .5)
+	test.That(t, changedNames(d.Emptied), test.ShouldResemble, []string{"e4"})
+	test.That(t, d.Occupied, test.ShouldBeEmpty)
+	test.That(t, changedNames(d.Recolored), test.ShouldResemble, []string{"d5"})
+	test.That(t, d.Recolored[0].Before.PieceColor, test.ShouldEqual, "black")
+	test.That(t, d.Recolored[0].After.PieceColor, test.ShouldEqual, "white")
+
+	// the lower of the two confidences comes through, and one too low leaves the square out
+	exd5[chess.D5].Confidence = .8
+	exd5[chess.E4].Confidence = .3
+	d = DiffBoards(d5, exd5, .5)
+	test.That(t, d.Recolored[0].Confidence, test.ShouldEqual, .8)
+	test.That(t, d.Emptied, test.ShouldBeEmpty)
+	test.That(t, d.Unsure, test.ShouldResemble, []string{"e4"})
+	test.That(t, DiffBoards(d5, exd5, .2).Emptied, test.ShouldHaveLength, 1)
+
+	m := d.toMap()
+	test.That(t, m["changed"], test.ShouldBeTrue)
+	test.That(t, m["recolored"], test.ShouldResemble, []interface{}{
+		map[string]interface{}{"square": "d5", "before": "black", "after": "white", "confidence": .8},
+	})
+}
+
+func TestDiffFromLast(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	// every capture analyzed again
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "0", CornerSource: cornerSourceImage, ChangeThreshold: -1}, &frame, &pc)
+
+	// nothing to diff the first one from
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"diff_from_last": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no capture before")
+
+	// the same frame again hasn't changed
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"diff_from_last": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["changed"], test.ShouldBeFalse)
+	test.That(t, ret["emptied"], test.ShouldBeEmpty)
+
+	// e2 lifted off the board since
+	pf.captureLock.Lock()
+	lifted := append([]squareInfo(nil), pf.last.squares...)
+	e2 := squareIndex('e', 2, 8)
+	lifted[e2].color, lifted[e2].confidence = 0, 1
+	pf.last.squares = lifted
+	pf.captureLock.Unlock()
+	ret, err = pf.DoCommand(ctx, map[string]interface{}{"diff_from_last": true, "min_confidence": .1})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["changed"], test.ShouldBeTrue)
+	occupied := ret["occupied"].([]interface{})
+	test.That(t, len(occupied), test.ShouldEqual, 1)
+	test.That(t, occupied[0].(map[string]interface{})["square"], test.ShouldEqual, "e2")
+}
+
+func TestDiffFromLastErrorFrame(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 1, ErrorFrames: "passthrough"}, &frame, &pc)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	frame = occludeHalf(input, corners)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	// past max-age the capture is an error frame, not the last analysis diffed with itself
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"diff_from_last": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "couldn't be analyzed")
+}
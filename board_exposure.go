@@ -0,0 +1,86 @@
+package viamchess
+
+import (
+	"image"
+)
+
+const (
+	// exposureStride is how far apart, both ways, the pixels in the exposure histogram are.
+	exposureStride = 4
+	// referenceContrast is how far apart (0-255) the means of the light and dark halves of a
+	// normally exposed frame are. The brightness thresholds are tuned for that much or more.
+	referenceContrast = 100
+	// minOtsuSeparation is how much of the histogram's variance the split between light and
+	// dark has to explain for there to be two halves at all. Under it the histogram is one hump
+	// (a bell curve gets about .64, a flat histogram .75) and the thresholds are left alone.
+	minOtsuSeparation = .8
+)
+
+// otsuSplit is Otsu's threshold for hist: the level that best splits it into a dark class
+// (below it) and a light class, with the mean of each and how much of the total variance the
+// split accounts for, 0 to 1.
+func otsuSplit(hist *[256]int) (level int, dark, light, separation float64) {
+	total, sum := 0, 0.0
+	for v, n := range hist {
+		total += n
+		sum += float64(v * n)
+	}
+	if total == 0 {
+		return 0, 0, 0, 0
+	}
+	mean := sum / float64(total)
+	variance := 0.0
+	for v, n := range hist {
+		d := float64(v) - mean
+		variance += d * d * float64(n)
+	}
+	variance /= float64(total)
+	if variance == 0 {
+		return 0, mean, mean, 0
+	}
+
+	best := -1.0
+	nDark, sumDark := 0, 0.0
+	for v := range 255 {
+		nDark += hist[v]
+		sumDark += float64(v * hist[v])
+		nLight := total - nDark
+		if nDark == 0 || nLight == 0 {
+			continue
+		}
+		mDark := sumDark / float64(nDark)
+		mLight := (sum - sumDark) / float64(nLight)
+		wDark := float64(nDark) / float64(total)
+		between := wDark * (1 - wDark) * (mLight - mDark) * (mLight - mDark)
+		if between > best {
+			best, level, dark, light = between, v+1, mDark, mLight
+		}
+	}
+	return level, dark, light, best / variance
+}
+
+// exposureScale is how much contrast img has next to a normally exposed board, from the gap
+// between the means of its light and dark halves that Otsu's threshold splits it into, between
+// minExposure and 1. It's 1 when the histogram doesn't split into two.
+func exposureScale(img image.Image, minExposure float64) float64 {
+	bounds := img.Bounds()
+	var hist [256]int
+	row := make([]uint8, bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += exposureStride {
+		grayRow(img, bounds.Min.X, y, row)
+		for x := 0; x < len(row); x += exposureStride {
+			hist[row[x]]++
+		}
+	}
+
+	return histogramExposure(&hist, minExposure)
+}
+
+// histogramExposure is exposureScale from the histogram of the frame's gray levels.
+func histogramExposure(hist *[256]int, minExposure float64) float64 {
+	_, dark, light, separation := otsuSplit(hist)
+	if separation < minOtsuSeparation {
+		return 1
+	}
+	return min(1, max(minExposure, (light-dark)/referenceContrast))
+}
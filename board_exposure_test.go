@@ -0,0 +1,83 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// withExposure is img with every channel scaled by f, like the same scene shot in worse light.
+func withExposure(img image.Image, f float64) *image.RGBA {
+	res := image.NewRGBA(img.Bounds())
+	draw.Draw(res, res.Bounds(), img, img.Bounds().Min, draw.Src)
+	for i := range res.Pix {
+		if i%4 != 3 {
+			res.Pix[i] = uint8(float64(res.Pix[i]) * f)
+		}
+	}
+	return res
+}
+
+func TestOtsuSplit(t *testing.T) {
+	// two humps, around 40 and 200
+	var hist [256]int
+	for v := 30; v <= 50; v++ {
+		hist[v] = 100
+	}
+	for v := 190; v <= 210; v++ {
+		hist[v] = 50
+	}
+	level, dark, light, separation := otsuSplit(&hist)
+	test.That(t, level, test.ShouldBeBetween, 50, 191)
+	test.That(t, dark, test.ShouldAlmostEqual, 40)
+	test.That(t, light, test.ShouldAlmostEqual, 200)
+	test.That(t, separation, test.ShouldBeGreaterThan, .9)
+
+	// one hump doesn't split well
+	hist = [256]int{}
+	for v := 80; v <= 160; v++ {
+		hist[v] = 40 - max(v-120, 120-v)
+	}
+	_, _, _, separation = otsuSplit(&hist)
+	test.That(t, separation, test.ShouldBeLessThan, minOtsuSeparation)
+
+	// nor does a flat gray frame, which keeps the thresholds as they are
+	flat := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{60, 60, 60, 255}), image.Point{}, draw.Src)
+	test.That(t, exposureScale(flat, .2), test.ShouldEqual, 1)
+}
+
+func TestFindBoardUnderexposed(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board5.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{296, 17}, {970, 17}, {982, 700}, {283, 705}}
+
+	test.That(t, exposureScale(input, .2), test.ShouldEqual, 1)
+
+	dim := withExposure(input, .7)
+	corners, err := findBoard(dim)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("0.7x: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 3.5)
+
+	// much darker than that the fixed thresholds lose the board
+	input, err = rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected = []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	dark := withExposure(input, .3)
+	test.That(t, exposureScale(dark, .2), test.ShouldBeBetween, .3, .6)
+
+	opts := DefaultBoardFinderOptions()
+	opts.MinExposure = 0
+	_, err = findBoardWithOptions(dark, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	corners, err = findBoard(dark)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("0.3x: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 3.5)
+}
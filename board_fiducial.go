@@ -0,0 +1,333 @@
+package viamchess
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"math/bits"
+
+	"github.com/golang/geo/r2"
+)
+
+// BoardModeFiducial is the BoardFinderOptions.Mode that looks for four printed tags, one on
+// each corner of the board, instead of the board itself. See findFiducialCorners.
+const BoardModeFiducial = "fiducial"
+
+// A fiducial tag is fiducialCells x fiducialCells cells: a white margin one cell wide, a black
+// border one cell wide, and the 4x4 data bits inside that, black for 1, row by row from the top left.
+const fiducialCells = 8
+
+// fiducialCodes are the data bits of the tags for the TL, TR, BR and BL corners. Any two of them,
+// either one turned any way, or one and itself turned, differ in at least 6 bits.
+var fiducialCodes = [4]uint16{0x2778, 0x19d6, 0x805d, 0xc71a}
+
+const (
+	// maxFiducialBitErrors is how many of a tag's bits can be misread and it still count.
+	maxFiducialBitErrors = 1
+	// fiducialContrast is how much darker (gray levels) than the average around it a pixel has to
+	// be to be part of a tag's black.
+	fiducialContrast = 20
+	// minFiducialContrast is how much darker a tag's black border has to be than its white margin.
+	minFiducialContrast = 40
+	// minFiducialSide is the fewest pixels across a tag's black can be, 2 a cell.
+	minFiducialSide = 12
+)
+
+// findFiducialCorners is the centers of the four tags in img, in TL, TR, BR, BL order by which
+// tag is which, false unless all four are there.
+//
+// The frame is thresholded against the average brightness around each pixel, and each patch of
+// dark pixels the size of a tag is taken for the black border of one: its four corners are the
+// pixel farthest from its middle, the one farthest from that, and the two farthest to each side
+// of the line between them. The cells inside are then read through the homography of those
+// corners, and have to be one of fiducialCodes turned some way.
+func findFiducialCorners(img image.Image) (subPixelCorners, bool) {
+	gray := makeGrayImage(img)
+	defer gray.release()
+	width, height := gray.Width, gray.Height
+
+	mask := fiducialMask(gray)
+	labels, sizes := labelComponents(mask, width, height)
+	boxes := make([]image.Rectangle, len(sizes))
+	for i := range boxes {
+		boxes[i] = image.Rectangle{Min: image.Point{width, height}}
+	}
+	for i, l := range labels {
+		if l != 0 {
+			x, y := i%width, i/width
+			b := &boxes[l]
+			b.Min = image.Point{min(b.Min.X, x), min(b.Min.Y, y)}
+			b.Max = image.Point{max(b.Max.X, x+1), max(b.Max.Y, y+1)}
+		}
+	}
+
+	maxSide := min(width, height) / 3
+	var centers [4]r2.Point
+	errs := [4]int{-1, -1, -1, -1}
+	for l := 1; l < len(sizes); l++ {
+		box := boxes[l]
+		if min(box.Dx(), box.Dy()) < minFiducialSide || max(box.Dx(), box.Dy()) > maxSide {
+			continue
+		}
+		quad, ok := fiducialQuad(labels, width, int32(l), box, sizes[l])
+		if !ok {
+			continue
+		}
+		id, bitErrs, center, ok := readFiducial(gray, quad)
+		if !ok || (errs[id] >= 0 && errs[id] <= bitErrs) {
+			continue
+		}
+		centers[id], errs[id] = center, bitErrs
+	}
+
+	for _, e := range errs {
+		if e < 0 {
+			return subPixelCorners{}, false
+		}
+	}
+	return subPixelCornersFromSlice(centers[:]), true
+}
+
+// fiducialMask is which pixels of gray are fiducialContrast darker than the average of the
+// square around them, a twentieth of the shorter side across each way.
+func fiducialMask(gray *GrayPlane) []bool {
+	width, height := gray.Width, gray.Height
+	r := max(4, min(width, height)/40)
+
+	// sums[(y)*(width+1)+x] is the sum of the pixels above and left of x, y
+	stride := width + 1
+	sums := make([]uint32, stride*(height+1))
+	for y := range height {
+		row := gray.Row(y)
+		var acc uint32
+		for x, v := range row {
+			acc += uint32(v)
+			sums[(y+1)*stride+x+1] = sums[y*stride+x+1] + acc
+		}
+	}
+
+	mask := make([]bool, width*height)
+	for y := range height {
+		y0, y1 := max(0, y-r), min(height, y+r+1)
+		row := gray.Row(y)
+		for x, v := range row {
+			x0, x1 := max(0, x-r), min(width, x+r+1)
+			sum := sums[y1*stride+x1] - sums[y0*stride+x1] - sums[y1*stride+x0] + sums[y0*stride+x0]
+			mean := int(sum) / ((y1 - y0) * (x1 - x0))
+			mask[y*width+x] = int(v)+fiducialContrast < mean
+		}
+	}
+	return mask
+}
+
+// fiducialQuad is the four corners, going clockwise, of the patch of count pixels labeled l
+// inside box, false if it doesn't fill enough of them to be a tag's black.
+func fiducialQuad(labels []int32, width int, l int32, box image.Rectangle, count int) ([4]r2.Point, bool) {
+	pixels := func(fn func(p r2.Point)) {
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				if labels[y*width+x] == l {
+					fn(r2.Point{X: float64(x) + .5, Y: float64(y) + .5})
+				}
+			}
+		}
+	}
+	farthest := func(score func(p r2.Point) float64) r2.Point {
+		var best r2.Point
+		bestScore := math.Inf(-1)
+		pixels(func(p r2.Point) {
+			if s := score(p); s > bestScore {
+				best, bestScore = p, s
+			}
+		})
+		return best
+	}
+
+	var middle r2.Point
+	pixels(func(p r2.Point) { middle = middle.Add(p) })
+	middle = middle.Mul(1 / float64(count))
+
+	a := farthest(func(p r2.Point) float64 { return p.Sub(middle).Norm() })
+	c := farthest(func(p r2.Point) float64 { return p.Sub(a).Norm() })
+	b := farthest(func(p r2.Point) float64 { return -c.Sub(a).Cross(p.Sub(a)) })
+	d := farthest(func(p r2.Point) float64 { return c.Sub(a).Cross(p.Sub(a)) })
+	quad := [4]r2.Point{a, b, c, d}
+
+	// the border is 20 of the 36 cells inside the margin, and some of the bits touch it
+	area := 0.0
+	for i := range quad {
+		area += quad[i].Cross(quad[(i+1)%4])
+	}
+	area /= 2
+	if area <= 0 || float64(count) < .5*area || float64(count) > 1.1*area {
+		return quad, false
+	}
+	return quad, true
+}
+
+// readFiducial reads the tag whose black border's corners are quad: which one it is, how many of
+// its bits were misread, and where its middle is. False if it isn't a tag.
+func readFiducial(gray *GrayPlane, quad [4]r2.Point) (id, bitErrs int, center r2.Point, ok bool) {
+	h, err := computeHomography(quad[:], fiducialCells-2)
+	if err != nil {
+		return 0, 0, center, false
+	}
+	toSource, err := h.Inverse()
+	if err != nil {
+		return 0, 0, center, false
+	}
+
+	// the average of a few points around the middle of cell col, row, counted from the top left
+	// of the black border, false if any of them is out of the frame
+	cell := func(col, row int) (float64, bool) {
+		sum := 0.0
+		for _, dy := range []float64{.3, .5, .7} {
+			for _, dx := range []float64{.3, .5, .7} {
+				p := toSource.Apply(r2.Point{X: float64(col) + dx, Y: float64(row) + dy})
+				x, y := int(math.Floor(p.X)), int(math.Floor(p.Y))
+				if x < 0 || y < 0 || x >= gray.Width || y >= gray.Height {
+					return 0, false
+				}
+				sum += float64(gray.At(x, y))
+			}
+		}
+		return sum / 9, true
+	}
+
+	const side = fiducialCells - 2
+	var border, margin []float64
+	for i := -1; i <= side; i++ {
+		for j := -1; j <= side; j++ {
+			inMargin := i == -1 || i == side || j == -1 || j == side
+			if !inMargin && i != 0 && i != side-1 && j != 0 && j != side-1 {
+				continue
+			}
+			v, ok := cell(i, j)
+			if !ok {
+				return 0, 0, center, false
+			}
+			if inMargin {
+				margin = append(margin, v)
+			} else {
+				border = append(border, v)
+			}
+		}
+	}
+	dark, light := mean(border), mean(margin)
+	if light-dark < minFiducialContrast {
+		return 0, 0, center, false
+	}
+	mid := (dark + light) / 2
+	for _, v := range border {
+		if v >= mid {
+			return 0, 0, center, false
+		}
+	}
+
+	var code uint16
+	for row := range 4 {
+		for col := range 4 {
+			v, _ := cell(col+1, row+1)
+			code <<= 1
+			if v < mid {
+				code |= 1
+			}
+		}
+	}
+	id, bitErrs = -1, maxFiducialBitErrors+1
+	for range 4 {
+		for i, c := range fiducialCodes {
+			if e := bits.OnesCount16(code ^ c); e < bitErrs {
+				id, bitErrs = i, e
+			}
+		}
+		code = turnFiducialCode(code)
+	}
+	if id < 0 {
+		return 0, 0, center, false
+	}
+	return id, bitErrs, toSource.Apply(r2.Point{X: side / 2, Y: side / 2}), true
+}
+
+// turnFiducialCode is the bits of code as read off the tag turned a quarter turn clockwise.
+func turnFiducialCode(code uint16) uint16 {
+	var res uint16
+	for row := range 4 {
+		for col := range 4 {
+			bit := code >> (15 - ((3-col)*4 + row)) & 1
+			res |= bit << (15 - (row*4 + col))
+		}
+	}
+	return res
+}
+
+// mean is the average of vs, 0 for none.
+func mean(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range vs {
+		sum += v
+	}
+	return sum / float64(len(vs))
+}
+
+// fiducialTag is the tag for corner id (TL, TR, BR, BL) with cells cellSize pixels across,
+// its white margin included.
+func fiducialTag(id, cellSize int) *image.Gray {
+	size := fiducialCells * cellSize
+	tag := image.NewGray(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			col, row := x/cellSize, y/cellSize
+			black := false
+			switch {
+			case col == 0 || row == 0 || col == fiducialCells-1 || row == fiducialCells-1:
+			case col == 1 || row == 1 || col == fiducialCells-2 || row == fiducialCells-2:
+				black = true
+			default:
+				black = fiducialCodes[id]>>(15-((row-2)*4+col-2))&1 == 1
+			}
+			if !black {
+				tag.Pix[y*tag.Stride+x] = 255
+			}
+		}
+	}
+	return tag
+}
+
+// GenerateFiducialSheet is a PNG of the four tags for BoardModeFiducial to print, each labeled
+// with the corner it goes on as the camera sees the board. Cut them out with their white margin,
+// all printed the same size, and stick each one centered on its corner of the board.
+func GenerateFiducialSheet() ([]byte, error) {
+	const (
+		cellSize = 40
+		tagSize  = fiducialCells * cellSize
+		gap      = 80
+		label    = 30
+	)
+	sheet := image.NewRGBA(image.Rect(0, 0, 2*tagSize+3*gap, 2*(tagSize+label)+3*gap))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	// TL and TR across the top, BL under TL
+	at := []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for id, p := range at {
+		x, y := gap+p.X*(tagSize+gap), gap+p.Y*(tagSize+label+gap)
+		tag := fiducialTag(id, cellSize)
+		r := tag.Bounds().Add(image.Point{x, y})
+		// a gray line just outside the margin to cut along
+		draw.Draw(sheet, r.Inset(-1), image.NewUniform(color.Gray{200}), image.Point{}, draw.Src)
+		draw.Draw(sheet, r, tag, image.Point{}, draw.Src)
+		drawString(sheet, x+cellSize, y+tagSize+label/2, cornerNames[id], color.Black)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sheet); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
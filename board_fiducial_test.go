@@ -0,0 +1,116 @@
+package viamchess
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"math/bits"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// withFiducials is img with the tags for the corners in ids stuck on the board whose corners are
+// corners, centered on them and size squares across, drawn in the board's plane. Tag i is turned
+// i quarter turns, to check they're read whichever way they're stuck on.
+func withFiducials(t *testing.T, img image.Image, corners []image.Point, size float64, ids ...int) *image.RGBA {
+	t.Helper()
+	res := image.NewRGBA(img.Bounds())
+	draw.Draw(res, res.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	h, err := ComputeHomography(corners, defaultGridSize)
+	test.That(t, err, test.ShouldBeNil)
+	square := []r2.Point{{X: 0, Y: 0}, {X: defaultGridSize, Y: 0}, {X: defaultGridSize, Y: defaultGridSize}, {X: 0, Y: defaultGridSize}}
+
+	const cellSize = 10
+	for _, id := range ids {
+		tag := fiducialTag(id, cellSize)
+		tagSize := tag.Bounds().Dx()
+		for y := res.Bounds().Min.Y; y < res.Bounds().Max.Y; y++ {
+			for x := res.Bounds().Min.X; x < res.Bounds().Max.X; x++ {
+				q := h.Apply(r2.Point{X: float64(x) + .5, Y: float64(y) + .5}).Sub(square[id])
+				u, v := (q.X/size+.5)*float64(tagSize), (q.Y/size+.5)*float64(tagSize)
+				if u < 0 || v < 0 || u >= float64(tagSize) || v >= float64(tagSize) {
+					continue
+				}
+				for range id {
+					u, v = v, float64(tagSize)-u
+				}
+				g := tag.GrayAt(min(tagSize-1, int(u)), min(tagSize-1, int(v))).Y
+				i := res.PixOffset(x, y)
+				res.Pix[i], res.Pix[i+1], res.Pix[i+2] = g, g, g
+			}
+		}
+	}
+	return res
+}
+
+func TestFiducialCodes(t *testing.T) {
+	for i, a := range fiducialCodes {
+		turned := a
+		for turn := 1; turn < 4; turn++ {
+			turned = turnFiducialCode(turned)
+			test.That(t, bits.OnesCount16(a^turned), test.ShouldBeGreaterThan, 2*maxFiducialBitErrors)
+		}
+		test.That(t, turnFiducialCode(turned), test.ShouldEqual, a)
+		for _, b := range fiducialCodes[i+1:] {
+			for range 4 {
+				test.That(t, bits.OnesCount16(a^b), test.ShouldBeGreaterThan, 2*maxFiducialBitErrors)
+				b = turnFiducialCode(b)
+			}
+		}
+	}
+}
+
+func TestFindFiducialCorners(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	img := withFiducials(t, input, expected, .8, 0, 1, 2, 3)
+	opts := DefaultBoardFinderOptions()
+	opts.Mode = BoardModeFiducial
+	res := FindBoardEx(img, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	t.Logf("tags: %v (%.1f)", res.Corners, maxCornerError(res.Corners, expected))
+	test.That(t, maxCornerError(res.Corners, expected), test.ShouldBeLessThan, 1.5)
+
+	// the board finder by itself doesn't look for them
+	withoutMode, err := findBoard(img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, maxCornerError(withoutMode, expected), test.ShouldBeLessThan, 10)
+
+	// with one missing it's the board finder's own corners
+	img = withFiducials(t, input, expected, .8, 0, 1, 3)
+	_, ok := findFiducialCorners(img)
+	test.That(t, ok, test.ShouldBeFalse)
+	res = FindBoardEx(img, opts)
+	opts.Mode = ""
+	test.That(t, res, test.ShouldResemble, FindBoardEx(img, opts))
+
+	// and on the plain frame, with no tags at all
+	_, ok = findFiducialCorners(input)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	_, err = BoardFinderOptionsFromMap(map[string]interface{}{"mode": "qr"})
+	test.That(t, err, test.ShouldNotBeNil)
+	opts, err = BoardFinderOptionsFromMap(map[string]interface{}{"mode": "fiducial"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts.Mode, test.ShouldEqual, BoardModeFiducial)
+}
+
+func TestGenerateFiducialSheet(t *testing.T) {
+	b, err := GenerateFiducialSheet()
+	test.That(t, err, test.ShouldBeNil)
+	sheet, err := png.Decode(bytes.NewReader(b))
+	test.That(t, err, test.ShouldBeNil)
+
+	// the tags are laid out like the corners they're for
+	corners, ok := findFiducialCorners(sheet)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, corners.round().check(), test.ShouldBeNil)
+}
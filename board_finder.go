@@ -1,9 +1,13 @@
 package viamchess
 
 import (
+	"context"
 	"image"
 	"math"
 	"sort"
+	"sync"
+
+	"github.com/golang/geo/r2"
 )
 
 // findBoard finds the four corners of the chess board.
@@ -11,19 +15,151 @@ import (
 // 2. Detect edges with Sobel
 // 3. Find lines with Hough transform
 // 4. Merge nearby lines, remove isolated lines
-// 5. Find border pair by fitting a regular 8-interval grid
+// 5. Find border pair by fitting a regular grid, 8 intervals for chess
 // 6. Refine border lines using Theil-Sen estimator on edge pixels
 // 7. Compute corners as line intersections
 func findBoard(img image.Image) ([]image.Point, error) {
+	return findBoardWithOptions(img, DefaultBoardFinderOptions())
+}
+
+func findBoardWithOptions(img image.Image, opts BoardFinderOptions) ([]image.Point, error) {
+	return findBoardCtx(context.Background(), img, opts)
+}
+
+// findBoardCtx is findBoardWithOptions, giving up with ctx.Err() once ctx is done.
+func findBoardCtx(ctx context.Context, img image.Image, opts BoardFinderOptions) ([]image.Point, error) {
+	res := detectBoardCtx(ctx, img, opts, nil)
+	return res.Board.Slice(), res.Err
+}
+
+// findBoardSubPixel is findBoard without rounding the line intersections.
+func findBoardSubPixel(img image.Image, opts BoardFinderOptions) ([]r2.Point, error) {
+	res := detectBoard(img, opts, nil)
+	return res.SubPixelCorners, res.Err
+}
+
+// detectBoard runs the pipeline. If dbg is not nil, each stage's intermediate results are recorded in it.
+func detectBoard(img image.Image, opts BoardFinderOptions, dbg *BoardFinderDebug) FindBoardResult {
+	return detectBoardCtx(context.Background(), img, opts, dbg)
+}
+
+// detectBoardCtx is detectBoard, stopping between stages, and every so often while voting for
+// lines, once ctx is done. Then the result is NotFoundCanceled with ctx.Err().
+func detectBoardCtx(ctx context.Context, img image.Image, opts BoardFinderOptions, dbg *BoardFinderDebug) FindBoardResult {
 	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+	canceled := func() bool { return ctx.Err() != nil }
+	if canceled() {
+		return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+	}
+
+	if opts.Mode == BoardModeFiducial {
+		if corners, ok := findFiducialCorners(img); ok {
+			return foundResult(corners)
+		}
+		if canceled() {
+			return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+		}
+	}
 
-	gray := makeGrayImage(img)
-	sobel := sobelEdgeDetection(gray, width, height)
+	if opts.GlareMinArea > 0 {
+		img = suppressGlare(img, opts.GlareMinArea)
+		if canceled() {
+			return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+		}
+	}
+	if opts.MinExposure > 0 {
+		opts.exposure = exposureScale(img, opts.MinExposure)
+	}
+	if opts.MinSharpness > 0 && opts.BlurSigma > 0 && frameSharpness(img, opts.exposure) < opts.MinSharpness {
+		opts.blur = opts.BlurSigma
+	}
+	if canceled() {
+		return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+	}
+
+	var res FindBoardResult
+	if opts.downscaled(bounds.Dx(), bounds.Dy()) {
+		res = detectBoardCoarseToFine(ctx, img, opts, dbg)
+	} else {
+		gray := makeGrayImage(img)
+		if !canceled() {
+			res, _ = detectBoardGray(ctx, gray, opts, dbg)
+		}
+		gray.release()
+	}
+	if canceled() {
+		return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+	}
+
+	if res.Found && opts.MinSaddlePoints > 0 {
+		res = checkInnerCorners(img, res, opts, dbg)
+		if canceled() {
+			return canceledResult(ctx, bounds.Dx(), bounds.Dy())
+		}
+	}
+
+	res = checkFoundBoard(img, res, opts)
+
+	if res.Found && dbg != nil {
+		dbg.CornerError = res.Board.check()
+	}
+	return res
+}
 
-	lines := houghLineDetection(sobel, width, height, 90)
+// checkFoundBoard is res, if it's found, unless its corners can't be a board or too little of
+// the board in img between them looks like one, with its CornerConfidence.
+func checkFoundBoard(img image.Image, res FindBoardResult, opts BoardFinderOptions) FindBoardResult {
+	if !res.Found {
+		return res
+	}
+	bounds := img.Bounds()
+	if err := validateCornerQuad(res.Board, bounds.Dx(), bounds.Dy(), opts); err != nil {
+		res = notFoundResult(bounds.Dx(), bounds.Dy(), NotFoundBadQuad)
+		res.Err = err
+		return res
+	}
+	if opts.MinBoardFraction > 0 && boardOccluded(img, res.SubPixelCorners, opts.gridSize(), opts.MinBoardFraction) {
+		return notFoundResult(bounds.Dx(), bounds.Dy(), NotFoundOccluded)
+	}
+	if opts.MaxCornerOutside > 0 {
+		if err := clippedEdgeError(img, res.SubPixelCorners, opts.gridSize()); err != nil {
+			res = notFoundResult(bounds.Dx(), bounds.Dy(), NotFoundBadQuad)
+			res.Err = err
+			return res
+		}
+	}
+	res.CornerConfidence = cornerConfidence(img, res, opts)
+	return res
+}
+
+// detectBoardGray is detectBoard on an already gray image. It also returns the refined
+// top, bottom, left and right lines when the board is found. gray is blurred in place when the frame
+// is out of focus.
+func detectBoardGray(ctx context.Context, gray *GrayPlane, opts BoardFinderOptions, dbg *BoardFinderDebug) (FindBoardResult, []Line) {
+	width, height := gray.Width, gray.Height
+	if opts.blur > 0 {
+		blurGray(gray, opts.blur)
+	}
+	sobel := sobelEdgeDetection(gray)
+	if dbg != nil {
+		dbg.sobel = sobel
+	} else {
+		defer sobel.release()
+	}
+	if ctx.Err() != nil {
+		return canceledResult(ctx, width, height), nil
+	}
+
+	edgeThreshold := opts.softThreshold(opts.edgeThreshold(), sobel.magnitude)
+	lines, err := houghLineDetection(ctx, sobel, width, height, edgeThreshold, opts.voteThreshold(width, height), axisWindows(opts.AngleTolerance))
+	if err != nil {
+		return canceledResult(ctx, width, height), nil
+	}
+	if dbg != nil {
+		dbg.HoughLines = lines
+	}
 	if len(lines) < 4 {
-		return defaultCorners(width, height), nil
+		return notFoundResult(width, height, NotFoundNoLines), nil
 	}
 
 	midX := width / 2
@@ -32,55 +168,203 @@ func findBoard(img image.Image) ([]image.Point, error) {
 	var hLines, vLines []lineWithPos
 	for _, l := range lines {
 		angleDeg := l.theta * 180 / math.Pi
-		if angleDeg > 75 && angleDeg < 105 {
+		if angleDeg > 90-opts.AngleTolerance && angleDeg < 90+opts.AngleTolerance {
 			y := (l.rho - float64(midX)*math.Cos(l.theta)) / math.Sin(l.theta)
 			hLines = append(hLines, lineWithPos{l, y})
-		} else if angleDeg < 15 || angleDeg > 165 {
+		} else if angleDeg < opts.AngleTolerance || angleDeg > 180-opts.AngleTolerance {
 			x := (l.rho - float64(midY)*math.Sin(l.theta)) / math.Cos(l.theta)
 			vLines = append(vLines, lineWithPos{l, x})
 		}
 	}
 
 	if len(hLines) < 2 || len(vLines) < 2 {
-		return defaultCorners(width, height), nil
+		return notFoundResult(width, height, NotFoundNoLines), nil
 	}
 
-	hLines = mergeByPosition(hLines, 15)
-	vLines = mergeByPosition(vLines, 15)
+	hLines = mergeByPosition(hLines, opts.MergeDistance)
+	vLines = mergeByPosition(vLines, opts.MergeDistance)
 
-	threshold := float64(height) * 0.20
+	threshold := float64(height) * opts.IsolatedFraction
 	hLines = filterIsolatedLines(hLines, threshold)
 	vLines = filterIsolatedLines(vLines, threshold)
 
+	// still sorted by votes, so this keeps the strongest for the grid fit
+	if n := opts.MaxLinesPerOrientation; n > 0 {
+		hLines = hLines[:min(n, len(hLines))]
+		vLines = vLines[:min(n, len(vLines))]
+	}
+
+	if dbg != nil {
+		for _, l := range hLines {
+			dbg.CandidateLines = append(dbg.CandidateLines, l.line)
+		}
+		for _, l := range vLines {
+			dbg.CandidateLines = append(dbg.CandidateLines, l.line)
+		}
+	}
+
 	if len(hLines) < 2 || len(vLines) < 2 {
-		return defaultCorners(width, height), nil
+		return notFoundResult(width, height, NotFoundNoLines), nil
+	}
+
+	maxOutside := opts.maxOutside(width, height)
+	topLine, bottomLine := findBorderPairByGrid(hLines, true, width, height, maxOutside, opts)
+	leftLine, rightLine := findBorderPairByGrid(vLines, false, width, height, maxOutside, opts)
+	border := []Line{topLine, bottomLine, leftLine, rightLine}
+
+	if dbg != nil {
+		dbg.BorderLines = []Line{topLine, bottomLine, leftLine, rightLine}
+		if grid, ok := borderCorners(topLine, bottomLine, leftLine, rightLine); ok {
+			dbg.GridCorners = grid.slice()
+		}
+		for _, l := range dbg.BorderLines {
+			pts, _ := refineEdgePoints(l, sobel, width, height, opts)
+			for _, p := range pts {
+				dbg.BoundaryPoints = append(dbg.BoundaryPoints, image.Point{X: int(p.x), Y: int(p.y)})
+			}
+		}
+	}
+
+	topLine = refineLineLocal(topLine, sobel, width, height, opts)
+	bottomLine = refineLineLocal(bottomLine, sobel, width, height, opts)
+	leftLine = refineLineLocal(leftLine, sobel, width, height, opts)
+	rightLine = refineLineLocal(rightLine, sobel, width, height, opts)
+
+	refined := []Line{topLine, bottomLine, leftLine, rightLine}
+	if dbg != nil {
+		dbg.RefinedLines = refined
+	}
+
+	corners, ok := borderCorners(topLine, bottomLine, leftLine, rightLine)
+	if !ok {
+		return notFoundResult(width, height, NotFoundNoBorder), nil
 	}
 
-	topLine, bottomLine := findBorderPairByGrid(hLines)
-	leftLine, rightLine := findBorderPairByGrid(vLines)
+	if dbg != nil {
+		dbg.RefinedCorners = corners.slice()
+	}
 
-	topLine = refineLineLocal(topLine, sobel, width, height, 80)
-	bottomLine = refineLineLocal(bottomLine, sobel, width, height, 80)
-	leftLine = refineLineLocal(leftLine, sobel, width, height, 80)
-	rightLine = refineLineLocal(rightLine, sobel, width, height, 80)
+	return foundResult(corners).withLines(border, refined), refined
+}
 
-	tl, ok1 := lineIntersection(topLine, leftLine)
-	tr, ok2 := lineIntersection(topLine, rightLine)
-	br, ok3 := lineIntersection(bottomLine, rightLine)
-	bl, ok4 := lineIntersection(bottomLine, leftLine)
+// borderCorners intersects the four border lines, false if any are parallel.
+func borderCorners(top, bottom, left, right Line) (subPixelCorners, bool) {
+	tl, ok1 := lineIntersection(top, left)
+	tr, ok2 := lineIntersection(top, right)
+	br, ok3 := lineIntersection(bottom, right)
+	bl, ok4 := lineIntersection(bottom, left)
 
 	if !ok1 || !ok2 || !ok3 || !ok4 {
-		return defaultCorners(width, height), nil
+		return subPixelCorners{}, false
+	}
+	return subPixelCorners{topLeft: tl, topRight: tr, bottomRight: br, bottomLeft: bl}, true
+}
+
+func foundResult(corners subPixelCorners) FindBoardResult {
+	board := corners.round()
+	return FindBoardResult{
+		Board:           board,
+		Corners:         board.Slice(),
+		SubPixelCorners: corners.slice(),
+		Found:           true,
 	}
+}
+
+// Why FindBoardResult.Found is false.
+const (
+	// NotFoundNoLines is when there weren't enough strong horizontal and vertical lines.
+	NotFoundNoLines = "no lines"
+	// NotFoundNoBorder is when the border lines found don't make a quad.
+	NotFoundNoBorder = "no border"
+	// NotFoundOccluded is when there is a quad, but something covers too much of it to trust.
+	NotFoundOccluded = "occluded"
+	// NotFoundBadQuad is when the corners found can't be a board, FindBoardResult.Err says why.
+	NotFoundBadQuad = "bad quad"
+	// NotFoundCanceled is when the context was done before the board was found, FindBoardResult.Err is its error.
+	NotFoundCanceled = "canceled"
+)
+
+func notFoundResult(width, height int, reason string) FindBoardResult {
+	return FindBoardResult{
+		Board:           boardCornersFromSlice(defaultCorners(width, height)),
+		Corners:         defaultCorners(width, height),
+		SubPixelCorners: defaultCornersSubPixel(width, height),
+		Reason:          reason,
+	}
+}
+
+// canceledResult is notFoundResult for a ctx that's done.
+func canceledResult(ctx context.Context, width, height int) FindBoardResult {
+	res := notFoundResult(width, height, NotFoundCanceled)
+	res.Err = ctx.Err()
+	return res
+}
+
+// FindBoardResult is what the board finder settled on.
+type FindBoardResult struct {
+	Board BoardCorners
+	// Corners is Board in TL, TR, BR, BL order, for older code.
+	Corners []image.Point
+	// SubPixelCorners are Corners before rounding.
+	SubPixelCorners []r2.Point
+	// Found is false when the pipeline gave up and the corners are just a guess at the middle of the image.
+	Found bool
+	// Reason is one of the NotFound constants when Found is false.
+	Reason string
+	// Err is what was wrong with the corners when Reason is NotFoundBadQuad, or the context's
+	// error when it's NotFoundCanceled.
+	Err error
+	// CornerConfidence is how sure the finder is of each of Corners, 0 to 1, see
+	// cornerConfidence. All 0 when the board isn't found.
+	CornerConfidence [4]float64
+
+	// lineCorners are where the border lines crossed before they were refined, nil when the
+	// corners didn't come from lines. fallback is which corners are on a line that was extended
+	// from the grid or couldn't be refined. See withLines.
+	lineCorners []r2.Point
+	fallback    [4]bool
+}
+
+// FindBoardEx is FindBoardWithOptions, but says whether the board was really found, and if not why.
+func FindBoardEx(img image.Image, opts BoardFinderOptions) FindBoardResult {
+	return detectBoard(img, opts, nil)
+}
 
-	return []image.Point{tl, tr, br, bl}, nil
+// FindBoardCorners finds the corners of the chess board with the default options.
+func FindBoardCorners(img image.Image) (BoardCorners, error) {
+	res := detectBoard(img, DefaultBoardFinderOptions(), nil)
+	return res.Board, res.Err
 }
 
-// FindBoard is an exported version of findBoard for testing
+// FindBoard is FindBoardCorners as a TL, TR, BR, BL slice.
 func FindBoard(img image.Image) ([]image.Point, error) {
 	return findBoard(img)
 }
 
+// FindBoardWithOptions is FindBoard with tunable thresholds instead of the defaults.
+func FindBoardWithOptions(img image.Image, opts BoardFinderOptions) ([]image.Point, error) {
+	return findBoardWithOptions(img, opts)
+}
+
+// FindBoardCtx is FindBoardWithOptions, returning ctx.Err() soon after ctx is done instead of
+// finishing the search.
+func FindBoardCtx(ctx context.Context, img image.Image, opts BoardFinderOptions) ([]image.Point, error) {
+	return findBoardCtx(ctx, img, opts)
+}
+
+// FindBoardSubPixel returns the corners in TL, TR, BR, BL order with fractional precision.
+func FindBoardSubPixel(img image.Image) ([]r2.Point, error) {
+	return findBoardSubPixel(img, DefaultBoardFinderOptions())
+}
+
+func roundCorners(corners []r2.Point) []image.Point {
+	res := make([]image.Point, len(corners))
+	for i, c := range corners {
+		res[i] = image.Point{X: int(math.Round(c.X)), Y: int(math.Round(c.Y))}
+	}
+	return res
+}
+
 type refinePoint struct{ x, y float64 }
 
 type lineWithPos struct {
@@ -120,73 +404,121 @@ func filterIsolatedLines(lines []lineWithPos, threshold float64) []lineWithPos {
 	return result
 }
 
-// findBorderPairByGrid finds the pair of lines that best fits an 8-interval chess grid.
-func findBorderPairByGrid(lines []lineWithPos) (Line, Line) {
+// findBorderPairByGrid finds the pair of lines that best fits a grid of opts.GridSize intervals.
+// lines are all horizontal or all vertical in a width x height image. If the best fit has a
+// border out of the frame, by no more than maxOutside pixels, that border comes from
+// extending the grid.
+func findBorderPairByGrid(lines []lineWithPos, horizontal bool, width, height int, maxOutside float64, opts BoardFinderOptions) (Line, Line) {
 	sort.Slice(lines, func(i, j int) bool { return lines[i].pos < lines[j].pos })
 
 	if len(lines) <= 2 {
 		return lines[0].line, lines[len(lines)-1].line
 	}
 
-	const intervals = 8
+	intervals := opts.gridSize()
+
+	gridVotes := make([]int, intervals+1)
+
+	// score is how well the grid with its first line at start and the given spacing fits
+	score := func(start, spacing float64) int {
+		for g := range gridVotes {
+			gridVotes[g] = 0
+		}
+
+		for k := range lines {
+			relPos := (lines[k].pos - start) / spacing
+			nearest := math.Round(relPos)
+			gridIdx := int(nearest)
+			if gridIdx >= 0 && gridIdx <= intervals &&
+				math.Abs(relPos-nearest) < opts.GridTolerance {
+				if lines[k].line.votes > gridVotes[gridIdx] {
+					gridVotes[gridIdx] = lines[k].line.votes
+				}
+			}
+		}
+
+		total := 0
+		for _, v := range gridVotes {
+			total += v
+		}
+		return total
+	}
 
 	bestI, bestJ := 0, len(lines)-1
 	bestScore := 0
 
-	var gridVotes [intervals + 1]int
-
 	for i := range lines {
 		for j := i + 1; j < len(lines); j++ {
 			spacing := (lines[j].pos - lines[i].pos) / float64(intervals)
-			if spacing < 10 {
+			if spacing < opts.MinGridSpacing {
 				continue
 			}
-
-			for g := range gridVotes {
-				gridVotes[g] = 0
+			if s := score(lines[i].pos, spacing); s > bestScore {
+				bestScore = s
+				bestI, bestJ = i, j
 			}
+		}
+	}
+	first, last := lines[bestI].line, lines[bestJ].line
 
-			for k := range lines {
-				relPos := (lines[k].pos - lines[i].pos) / spacing
-				nearest := math.Round(relPos)
-				gridIdx := int(nearest)
-				if gridIdx >= 0 && gridIdx <= intervals &&
-					math.Abs(relPos-nearest) < 0.15 {
-					if lines[k].line.votes > gridVotes[gridIdx] {
-						gridVotes[gridIdx] = lines[k].line.votes
-					}
-				}
-			}
+	if maxOutside <= 0 {
+		return first, last
+	}
 
-			score := 0
-			for _, v := range gridVotes {
-				score += v
-			}
+	size := float64(width)
+	if horizontal {
+		size = float64(height)
+	}
 
-			if score > bestScore {
-				bestScore = score
-				bestI, bestJ = i, j
+	// i and j are n lines apart, and the border on one side of them is past the edge, so the
+	// grid has to win on the lines that are there
+	for i := range lines {
+		for j := i + 1; j < len(lines); j++ {
+			for n := intervals - 1; n >= intervals/2; n-- {
+				spacing := (lines[j].pos - lines[i].pos) / float64(n)
+				if spacing < opts.MinGridSpacing {
+					continue
+				}
+
+				if start := lines[j].pos - float64(intervals)*spacing; start < 0 && start >= -maxOutside {
+					if s := score(start, spacing); s > bestScore {
+						bestScore = s
+						first = extrapolateLine(lines[i], lines[j], float64(n-intervals), float64(n), horizontal, width, height)
+						last = lines[j].line
+					}
+				}
+				if end := lines[i].pos + float64(intervals)*spacing; end > size && end <= size+maxOutside {
+					if s := score(lines[i].pos, spacing); s > bestScore {
+						bestScore = s
+						first = lines[i].line
+						last = extrapolateLine(lines[i], lines[j], float64(intervals), float64(n), horizontal, width, height)
+					}
+				}
 			}
 		}
 	}
 
-	return lines[bestI].line, lines[bestJ].line
+	return first, last
 }
 
-func makeGrayImage(img image.Image) [][]int {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+// extrapolateLine is the grid line g intervals on from a, when b is n intervals on. The angle
+// changes steadily from one line to the next like the position does, so perspective carries on.
+func extrapolateLine(a, b lineWithPos, g, n float64, horizontal bool, width, height int) Line {
+	f := g / n
+	theta := a.line.theta + (b.line.theta-a.line.theta)*f
+	pos := a.pos + (b.pos-a.pos)*f
+	if horizontal {
+		return Line{rho: float64(width/2)*math.Cos(theta) + pos*math.Sin(theta), theta: theta}
+	}
+	return Line{rho: pos*math.Cos(theta) + float64(height/2)*math.Sin(theta), theta: theta}
+}
 
-	gray := make([][]int, height)
-	for y := range height {
-		gray[y] = make([]int, width)
-		for x := range width {
-			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
-			r, g, b, _ := c.RGBA()
-			gray[y][x] = (int(r>>8) + int(g>>8) + int(b>>8)) / 3
-		}
+func defaultCornersSubPixel(width, height int) []r2.Point {
+	res := []r2.Point{}
+	for _, p := range defaultCorners(width, height) {
+		res = append(res, r2.Point{X: float64(p.X), Y: float64(p.Y)})
 	}
-	return gray
+	return res
 }
 
 func defaultCorners(width, height int) []image.Point {
@@ -205,55 +537,197 @@ type Line struct {
 	votes int
 }
 
+// sobelResult is the Sobel magnitude, clamped to 255, and the x and y gradients it came from.
+// gx and gy are indexed like magnitude.Pix.
 type sobelResult struct {
-	magnitude [][]int
-	gx        [][]int
-	gy        [][]int
+	magnitude *GrayPlane
+	gx        []int16
+	gy        []int16
 }
 
-func sobelEdgeDetection(gray [][]int, width, height int) sobelResult {
-	mag := make([][]int, height)
-	gxArr := make([][]int, height)
-	gyArr := make([][]int, height)
-	for y := range height {
-		mag[y] = make([]int, width)
-		gxArr[y] = make([]int, width)
-		gyArr[y] = make([]int, width)
+var sobelPool sync.Pool
+
+// release hands the buffers back for the next frame, sobel mustn't be used afterwards.
+func (sobel *sobelResult) release() {
+	sobel.magnitude.release()
+	sobel.magnitude = nil
+	sobelPool.Put(sobel)
+}
+
+// sobelEdgeDetection is the Sobel gradient of every pixel. Past the edge of the image the
+// nearest pixel in it is repeated, so the outermost ring gets a gradient along the image edge,
+// a board line running right along it still has edge pixels, and the image edge itself isn't one.
+func sobelEdgeDetection(gray *GrayPlane) *sobelResult {
+	width, height := gray.Width, gray.Height
+	res, _ := sobelPool.Get().(*sobelResult)
+	if res == nil {
+		res = &sobelResult{}
+	}
+	res.magnitude = getGrayPlane(width, height)
+	res.gx = resizeZeroed(res.gx, width*height)
+	res.gy = resizeZeroed(res.gy, width*height)
+
+	set := func(i, gx, gy int) {
+		m := int(math.Sqrt(float64(gx*gx + gy*gy)))
+		if m > 255 {
+			m = 255
+		}
+		res.magnitude.Pix[i] = uint8(m)
+		res.gx[i] = int16(gx)
+		res.gy[i] = int16(gy)
 	}
 
+	g := gray.Pix
 	for y := 1; y < height-1; y++ {
 		for x := 1; x < width-1; x++ {
-			gx := -gray[y-1][x-1] + gray[y-1][x+1] +
-				-2*gray[y][x-1] + 2*gray[y][x+1] +
-				-gray[y+1][x-1] + gray[y+1][x+1]
+			i := gray.offset(x, y)
+			up, down := i-width, i+width
+
+			gx := -int(g[up-1]) + int(g[up+1]) +
+				-2*int(g[i-1]) + 2*int(g[i+1]) +
+				-int(g[down-1]) + int(g[down+1])
+
+			gy := -int(g[up-1]) - 2*int(g[up]) - int(g[up+1]) +
+				int(g[down-1]) + 2*int(g[down]) + int(g[down+1])
 
-			gy := -gray[y-1][x-1] - 2*gray[y-1][x] - gray[y-1][x+1] +
-				gray[y+1][x-1] + 2*gray[y+1][x] + gray[y+1][x+1]
+			set(i, gx, gy)
+		}
+	}
+
+	// the outermost ring, slower since every neighbor has to be clamped into the image
+	at := func(x, y int) int {
+		return int(gray.At(max(0, min(width-1, x)), max(0, min(height-1, y))))
+	}
+	border := func(x, y int) {
+		gx := -at(x-1, y-1) + at(x+1, y-1) - 2*at(x-1, y) + 2*at(x+1, y) - at(x-1, y+1) + at(x+1, y+1)
+		gy := -at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1) + at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)
+		set(gray.offset(x, y), gx, gy)
+	}
+	for x := range width {
+		border(x, 0)
+		border(x, height-1)
+	}
+	for y := 1; y < height-1; y++ {
+		border(0, y)
+		border(width-1, y)
+	}
+
+	return res
+}
+
+// thetaWindow is a range of line angles, center +/- half (radians), wrapping at pi.
+type thetaWindow struct {
+	center, half float64
+}
 
-			m := int(math.Sqrt(float64(gx*gx + gy*gy)))
-			if m > 255 {
-				m = 255
+// axisWindows are the angles within tolerance (degrees) of horizontal and vertical, the only
+// lines detectBoardGray keeps.
+func axisWindows(tolerance float64) []thetaWindow {
+	half := tolerance * math.Pi / 180
+	return []thetaWindow{{center: 0, half: half}, {center: math.Pi / 2, half: half}}
+}
+
+// inThetaWindows is which theta bins are within margin bins of any of windows, all of them
+// if there are no windows.
+func inThetaWindows(windows []thetaWindow, numThetas, margin int) []bool {
+	in := make([]bool, numThetas)
+	for t := range numThetas {
+		if len(windows) == 0 {
+			in[t] = true
+			continue
+		}
+		theta := float64(t) * math.Pi / float64(numThetas)
+		for _, w := range windows {
+			d := math.Abs(math.Remainder(theta-w.center, math.Pi))
+			if d <= w.half+float64(margin)*math.Pi/float64(numThetas) {
+				in[t] = true
 			}
-			mag[y][x] = m
-			gxArr[y][x] = gx
-			gyArr[y][x] = gy
 		}
 	}
+	return in
+}
+
+// houghCheckRows is how many rows of votes houghLineDetection counts between looking at its
+// context, and houghCheckEdges how many edge pixels' votes.
+const houghCheckRows, houghCheckEdges = 16, 16384
+
+const (
+	// houghThetas is how many angle bins the Hough transform has over 180 degrees, a quarter
+	// degree each.
+	houghThetas = 720
+	// The coarse pass adds up the votes of houghCoarseRho by houghCoarseTheta fine bins, 4
+	// pixels by 2 degrees.
+	houghCoarseRho   = 4
+	houghCoarseTheta = 8
+)
 
-	return sobelResult{magnitude: mag, gx: gxArr, gy: gyArr}
+// houghBuffers are the accumulators of one houghLineDetection and the edge pixels it counts the
+// votes of, pooled so each frame doesn't allocate new ones.
+type houghBuffers struct {
+	coarse []int32
+	// fineAt[c] is where coarse bin c's houghCoarseRho x houghCoarseTheta fine bins start in
+	// fine, -1 if they aren't counted
+	fineAt []int32
+	fine   []int32
+	edges  []houghEdge
 }
 
-// houghLineDetection detects lines using gradient-directed Hough transform.
-func houghLineDetection(sobel sobelResult, width, height int, edgeThreshold int) []Line {
-	edges := sobel.magnitude
-	maxRho := int(math.Sqrt(float64(width*width + height*height)))
-	numThetas := 720
+// houghEdge is an edge pixel that votes, and the theta bin of its gradient.
+type houghEdge struct {
+	x, y, t int16
+}
+
+// bytes is how much memory the buffers take.
+func (b *houghBuffers) bytes() int {
+	return 4*(len(b.coarse)+len(b.fineAt)+len(b.fine)) + 6*len(b.edges)
+}
+
+var houghBuffersPool sync.Pool
 
-	accumulator := make([][]int, 2*maxRho+1)
-	for i := range accumulator {
-		accumulator[i] = make([]int, numThetas)
+// houghLineDetection detects lines using gradient-directed Hough transform. Only lines within
+// windows are looked for, or every angle if there are none. Edge pixels whose gradient can't
+// vote for one of them are skipped.
+// It stops with ctx.Err() once ctx is done.
+func houghLineDetection(ctx context.Context, sobel *sobelResult, width, height int, edgeThreshold, voteThreshold int, windows []thetaWindow) ([]Line, error) {
+	bufs, _ := houghBuffersPool.Get().(*houghBuffers)
+	if bufs == nil {
+		bufs = &houghBuffers{}
 	}
+	defer houghBuffersPool.Put(bufs)
+	return houghLines(ctx, bufs, sobel, width, height, edgeThreshold, voteThreshold, windows)
+}
 
+// houghLines is houghLineDetection with the accumulators in bufs. The votes are counted twice:
+// first in coarse bins, then in quarter degree, one pixel bins but only around the coarse bins
+// with at least voteThreshold votes. No fine bin can have more votes than the coarse one it's
+// in, so that's every fine bin that can be a line, and the ones next to them to check it's a peak.
+func houghLines(ctx context.Context, bufs *houghBuffers, sobel *sobelResult, width, height int, edgeThreshold, voteThreshold int, windows []thetaWindow) ([]Line, error) {
+	edges := sobel.magnitude
+	maxRho := int(math.Sqrt(float64(width*width + height*height)))
+	numRhos := 2*maxRho + 1
+	const numThetas = houghThetas
+	// each pixel votes this many bins either side of its gradient, and peaks are checked
+	// against this many either side, and this many rho bins
+	const voteSpread, peakSpread, peakRhoSpread = 5, 3, 2
+
+	isPeakBin := inThetaWindows(windows, numThetas, 0)
+	voteBins := inThetaWindows(windows, numThetas, voteSpread+peakSpread)
+
+	// slots[ct] is where coarse theta ct lives in each coarse row, -1 if nothing votes for it
+	numCoarseThetas := numThetas / houghCoarseTheta
+	numCoarseRhos := (numRhos + houghCoarseRho - 1) / houghCoarseRho
+	slots := make([]int, numCoarseThetas)
+	numSlots := 0
+	for ct := range numCoarseThetas {
+		slots[ct] = -1
+		for t := ct * houghCoarseTheta; t < (ct+1)*houghCoarseTheta; t++ {
+			if voteBins[t] {
+				slots[ct] = numSlots
+				numSlots++
+				break
+			}
+		}
+	}
 	cosTheta := make([]float64, numThetas)
 	sinTheta := make([]float64, numThetas)
 	for t := range numThetas {
@@ -262,16 +736,33 @@ func houghLineDetection(sobel sobelResult, width, height int, edgeThreshold int)
 		sinTheta[t] = math.Sin(theta)
 	}
 
+	// coarseSlot[t] is the slot of fine theta t's coarse bin
+	coarseSlot := make([]int, numThetas)
+	for t := range numThetas {
+		coarseSlot[t] = slots[t/houghCoarseTheta]
+	}
+	coarseBin := func(rhoIdx, t int) int {
+		return (rhoIdx/houghCoarseRho)*numSlots + coarseSlot[t]
+	}
+
+	const fineSize = houghCoarseRho * houghCoarseTheta
+	bufs.coarse = resizeZeroed(bufs.coarse, numCoarseRhos*numSlots)
+	bufs.fineAt = resizeZeroed(bufs.fineAt, len(bufs.coarse))
+	coarse, fineAt := bufs.coarse, bufs.fineAt
+
+	// the first pass keeps the edge pixels that vote, offset and gradient angle, for the second
+	bufs.edges = bufs.edges[:0]
 	for y := range height {
+		if y%houghCheckRows == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		for x := range width {
-			if edges[y][x] < edgeThreshold {
+			i := edges.offset(x, y)
+			if int(edges.Pix[i]) < edgeThreshold {
 				continue
 			}
 
-			gx := float64(sobel.gx[y][x])
-			gy := float64(sobel.gy[y][x])
-
-			gradAngle := math.Atan2(gy, gx)
+			gradAngle := math.Atan2(float64(sobel.gy[i]), float64(sobel.gx[i]))
 			if gradAngle < 0 {
 				gradAngle += math.Pi
 			}
@@ -279,37 +770,102 @@ func houghLineDetection(sobel sobelResult, width, height int, edgeThreshold int)
 			if tCenter >= numThetas {
 				tCenter = 0
 			}
+			if voteBins[tCenter] {
+				bufs.edges = append(bufs.edges, houghEdge{int16(x), int16(y), int16(tCenter)})
+			}
+		}
+	}
+
+	// vote counts the votes of every edge pixel, in coarse bins, or when fine isn't nil in the
+	// fine bins that are there
+	vote := func(fine []int32) error {
+		for n, e := range bufs.edges {
+			if n%houghCheckEdges == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			x, y := float64(e.x), float64(e.y)
+			for dt := -voteSpread; dt <= voteSpread; dt++ {
+				t := (int(e.t) + dt + numThetas) % numThetas
+				if !voteBins[t] {
+					continue
+				}
+				rhoIdx := int(x*cosTheta[t]+y*sinTheta[t]) + maxRho
+				if rhoIdx < 0 || rhoIdx >= numRhos {
+					continue
+				}
+				c := (rhoIdx/houghCoarseRho)*numSlots + coarseSlot[t]
+				if fine == nil {
+					coarse[c]++
+				} else if at := fineAt[c]; at >= 0 {
+					fine[int(at)+(rhoIdx%houghCoarseRho)*houghCoarseTheta+t%houghCoarseTheta]++
+				}
+			}
+		}
+		return nil
+	}
+
+	err := vote(nil)
+	if err != nil {
+		return nil, err
+	}
 
-			for dt := -5; dt <= 5; dt++ {
-				t := (tCenter + dt + numThetas) % numThetas
-				rho := float64(x)*cosTheta[t] + float64(y)*sinTheta[t]
-				rhoIdx := int(rho) + maxRho
-				if rhoIdx >= 0 && rhoIdx < 2*maxRho+1 {
-					accumulator[rhoIdx][t]++
+	// the coarse bins that can have a line in them, and those next to them
+	for i := range fineAt {
+		fineAt[i] = -1
+	}
+	numFine := int32(0)
+	for cr := range numCoarseRhos {
+		for ct := range numCoarseThetas {
+			if slots[ct] < 0 || int(coarse[cr*numSlots+slots[ct]]) < voteThreshold {
+				continue
+			}
+			for dr := -1; dr <= 1; dr++ {
+				for dt := -1; dt <= 1; dt++ {
+					r, s := cr+dr, slots[(ct+dt+numCoarseThetas)%numCoarseThetas]
+					if r >= 0 && r < numCoarseRhos && s >= 0 && fineAt[r*numSlots+s] < 0 {
+						fineAt[r*numSlots+s] = numFine
+						numFine += fineSize
+					}
 				}
 			}
 		}
 	}
+	if numFine == 0 {
+		return nil, nil
+	}
+
+	bufs.fine = resizeZeroed(bufs.fine, int(numFine))
+	fine := bufs.fine
+	err = vote(fine)
+	if err != nil {
+		return nil, err
+	}
+	fineBin := func(rhoIdx, t int) int {
+		return int(fineAt[coarseBin(rhoIdx, t)]) + (rhoIdx%houghCoarseRho)*houghCoarseTheta + t%houghCoarseTheta
+	}
 
 	var lines []Line
-	voteThreshold := 100
 
-	for rhoIdx := range 2*maxRho + 1 {
+	for rhoIdx := range numRhos {
 		for t := range numThetas {
-			if accumulator[rhoIdx][t] < voteThreshold {
+			if !isPeakBin[t] || int(coarse[coarseBin(rhoIdx, t)]) < voteThreshold {
+				continue
+			}
+			v := fine[fineBin(rhoIdx, t)]
+			if int(v) < voteThreshold {
 				continue
 			}
 
 			isMax := true
-			for dr := -2; dr <= 2 && isMax; dr++ {
-				for dt := -3; dt <= 3 && isMax; dt++ {
+			for dr := -peakRhoSpread; dr <= peakRhoSpread && isMax; dr++ {
+				for dt := -peakSpread; dt <= peakSpread && isMax; dt++ {
 					if dr == 0 && dt == 0 {
 						continue
 					}
 					nRho := rhoIdx + dr
 					nT := (t + dt + numThetas) % numThetas
-					if nRho >= 0 && nRho < 2*maxRho+1 {
-						if accumulator[nRho][nT] > accumulator[rhoIdx][t] {
+					if nRho >= 0 && nRho < numRhos {
+						if fine[fineBin(nRho, nT)] > v {
 							isMax = false
 						}
 					}
@@ -319,7 +875,7 @@ func houghLineDetection(sobel sobelResult, width, height int, edgeThreshold int)
 			if isMax {
 				rho := float64(rhoIdx - maxRho)
 				theta := float64(t) * math.Pi / float64(numThetas)
-				lines = append(lines, Line{rho: rho, theta: theta, votes: accumulator[rhoIdx][t]})
+				lines = append(lines, Line{rho: rho, theta: theta, votes: int(v)})
 			}
 		}
 	}
@@ -328,57 +884,49 @@ func houghLineDetection(sobel sobelResult, width, height int, edgeThreshold int)
 		return lines[i].votes > lines[j].votes
 	})
 
-	return lines
+	return lines, nil
 }
 
-func lineIntersection(l1, l2 Line) (image.Point, bool) {
+func lineIntersection(l1, l2 Line) (r2.Point, bool) {
 	c1, s1 := math.Cos(l1.theta), math.Sin(l1.theta)
 	c2, s2 := math.Cos(l2.theta), math.Sin(l2.theta)
 
 	det := c1*s2 - c2*s1
 	if math.Abs(det) < 1e-10 {
-		return image.Point{}, false
+		return r2.Point{}, false
 	}
 
 	x := (s2*l1.rho - s1*l2.rho) / det
 	y := (c1*l2.rho - c2*l1.rho) / det
 
-	return image.Point{X: int(math.Round(x)), Y: int(math.Round(y))}, true
+	return r2.Point{X: x, Y: y}, true
 }
 
-// refineLineLocal refines a line using edge pixels within ±3 pixels, with Theil-Sen estimator.
-func refineLineLocal(l Line, sobel sobelResult, width, height, edgeThreshold int) Line {
-	edges := sobel.magnitude
-	cosT, sinT := math.Cos(l.theta), math.Sin(l.theta)
-	angleDeg := l.theta * 180 / math.Pi
-	isHorizontal := angleDeg > 45 && angleDeg < 135
-
-	var pts []refinePoint
-
-	if isHorizontal {
-		for x := range width {
-			expectedY := (l.rho - float64(x)*cosT) / sinT
-			yMin := int(math.Max(0, expectedY-3))
-			yMax := int(math.Min(float64(height-1), expectedY+3))
-			for y := yMin; y <= yMax; y++ {
-				if edges[y][x] >= edgeThreshold {
-					pts = append(pts, refinePoint{float64(x), float64(y)})
-				}
-			}
-		}
-	} else {
-		for y := range height {
-			expectedX := (l.rho - float64(y)*sinT) / cosT
-			xMin := int(math.Max(0, expectedX-3))
-			xMax := int(math.Min(float64(width-1), expectedX+3))
-			for x := xMin; x <= xMax; x++ {
-				if edges[y][x] >= edgeThreshold {
-					pts = append(pts, refinePoint{float64(x), float64(y)})
-				}
-			}
+// refineLineLocal refines a line using edge pixels within ±opts.RefineBand pixels, with Theil-Sen estimator.
+func refineLineLocal(l Line, sobel *sobelResult, width, height int, opts BoardFinderOptions) Line {
+	pts, isHorizontal := refineEdgePoints(l, sobel, width, height, opts)
+	// a border mostly out of the frame only has a short stretch to fit, its slope would be
+	// worse than the one the grid gave it
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		along := p.y
+		if isHorizontal {
+			along = p.x
 		}
+		lo, hi = math.Min(lo, along), math.Max(hi, along)
+	}
+	side := float64(height)
+	if isHorizontal {
+		side = float64(width)
 	}
+	if hi-lo < side/4 {
+		return l
+	}
+	return fitRefinedLine(l, pts, isHorizontal)
+}
 
+// fitRefinedLine fits a line to the edge pixels near l, returning l if there aren't enough.
+func fitRefinedLine(l Line, pts []refinePoint, isHorizontal bool) Line {
 	if len(pts) < 10 {
 		return l
 	}
@@ -469,27 +1017,81 @@ func refineLineLocal(l Line, sobel sobelResult, width, height, edgeThreshold int
 	return Line{rho: medianIntercept * math.Cos(newTheta), theta: newTheta, votes: l.votes}
 }
 
+// refineEdgePoints returns the edge pixels within ±opts.RefineBand of l, and whether l is mostly horizontal.
+// The outermost ring is left out, its gradients only see one side and the fit is too easily
+// pulled by them when most of the line is out of the frame.
+func refineEdgePoints(l Line, sobel *sobelResult, width, height int, opts BoardFinderOptions) ([]refinePoint, bool) {
+	edges := sobel.magnitude
+	edgeThreshold := opts.softThreshold(opts.refineEdgeThreshold(), edges)
+	band := opts.RefineBand
+	cosT, sinT := math.Cos(l.theta), math.Sin(l.theta)
+	angleDeg := l.theta * 180 / math.Pi
+	isHorizontal := angleDeg > 45 && angleDeg < 135
+
+	var pts []refinePoint
+
+	if isHorizontal {
+		for x := 1; x < width-1; x++ {
+			expectedY := (l.rho - float64(x)*cosT) / sinT
+			yMin := int(math.Max(1, expectedY-band))
+			yMax := int(math.Min(float64(height-2), expectedY+band))
+			for y := yMin; y <= yMax; y++ {
+				if int(edges.At(x, y)) >= edgeThreshold {
+					pts = append(pts, refinePoint{float64(x), float64(y)})
+				}
+			}
+		}
+	} else {
+		for y := 1; y < height-1; y++ {
+			expectedX := (l.rho - float64(y)*sinT) / cosT
+			xMin := int(math.Max(1, expectedX-band))
+			xMax := int(math.Min(float64(width-2), expectedX+band))
+			for x := xMin; x <= xMax; x++ {
+				if int(edges.At(x, y)) >= edgeThreshold {
+					pts = append(pts, refinePoint{float64(x), float64(y)})
+				}
+			}
+		}
+	}
+
+	return pts, isHorizontal
+}
+
 // medianPerPosition groups edge points by position and returns one point per position
-// using the median cross-line value.
+// using the median cross-line value. It sorts pts in place.
 func medianPerPosition(pts []refinePoint, isHorizontal bool) []refinePoint {
-	groups := make(map[int][]float64)
-	for _, p := range pts {
+	pos := func(p refinePoint) (int, float64) {
 		if isHorizontal {
-			groups[int(p.x)] = append(groups[int(p.x)], p.y)
-		} else {
-			groups[int(p.y)] = append(groups[int(p.y)], p.x)
+			return int(p.x), p.y
 		}
+		return int(p.y), p.x
 	}
+	sort.Slice(pts, func(i, j int) bool {
+		pi, vi := pos(pts[i])
+		pj, vj := pos(pts[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return vi < vj
+	})
 
 	var result []refinePoint
-	for pos, vals := range groups {
-		sort.Float64s(vals)
-		median := vals[len(vals)/2]
+	for start := 0; start < len(pts); {
+		p, _ := pos(pts[start])
+		end := start + 1
+		for end < len(pts) {
+			if q, _ := pos(pts[end]); q != p {
+				break
+			}
+			end++
+		}
+		_, median := pos(pts[start+(end-start)/2])
 		if isHorizontal {
-			result = append(result, refinePoint{float64(pos), median})
+			result = append(result, refinePoint{float64(p), median})
 		} else {
-			result = append(result, refinePoint{median, float64(pos)})
+			result = append(result, refinePoint{median, float64(p)})
 		}
+		start = end
 	}
 	return result
 }
@@ -0,0 +1,241 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// detectBoardCoarseToFine finds the board on a downscaled copy of img, then refits the border
+// lines at full resolution using only the pixels in a window around each corner.
+func detectBoardCoarseToFine(ctx context.Context, img image.Image, opts BoardFinderOptions, dbg *BoardFinderDebug) FindBoardResult {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	f := opts.Downscale
+
+	small := downscaleGray(img, f)
+	if ctx.Err() != nil {
+		small.release()
+		return canceledResult(ctx, width, height)
+	}
+	coarse, lines := detectBoardGray(ctx, small, coarseOptions(opts), dbg)
+	small.release()
+	if dbg != nil {
+		dbg.upscale(f)
+		gray := makeGrayImage(img)
+		dbg.sobel = sobelEdgeDetection(gray)
+		gray.release()
+	}
+	if !coarse.Found {
+		return notFoundResult(width, height, coarse.Reason)
+	}
+
+	for i, l := range lines {
+		lines[i] = upscaleLine(l, f)
+	}
+
+	refined := refineBorderInWindows(img, lines, opts, dbg)
+	if dbg != nil {
+		dbg.RefinedLines = refined
+	}
+
+	corners, ok := borderCorners(refined[0], refined[1], refined[2], refined[3])
+	if !ok {
+		return notFoundResult(width, height, NotFoundNoBorder)
+	}
+
+	if dbg != nil {
+		dbg.RefinedCorners = corners.slice()
+	}
+
+	return foundResult(corners).withLines(lines, refined)
+}
+
+// coarseOptions are opts for the downscaled image, the distances are in full resolution pixels.
+func coarseOptions(opts BoardFinderOptions) BoardFinderOptions {
+	opts.MergeDistance *= opts.Downscale
+	opts.MinGridSpacing *= opts.Downscale
+	opts.blur *= opts.Downscale
+	return opts
+}
+
+// downscaleGray is makeGrayImage at f times the size, each pixel the average of the block it covers.
+func downscaleGray(img image.Image, f float64) *GrayPlane {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	sw, sh := int(float64(width)*f), int(float64(height)*f)
+
+	// blockEdges[i] is the first full resolution row/column of block i
+	blockEdges := func(n, full int) []int {
+		edges := make([]int, n+1)
+		for i := range edges {
+			edges[i] = min(int(float64(i)/f), full)
+		}
+		return edges
+	}
+	xs := blockEdges(sw, width)
+	ys := blockEdges(sh, height)
+
+	gray := getGrayPlane(sw, sh)
+	parallelRows(sh, func(start, end int) {
+		row := make([]uint8, width)
+		sums := make([]int, sw)
+		for y := start; y < end; y++ {
+			clear(sums)
+			for fy := ys[y]; fy < ys[y+1]; fy++ {
+				grayRow(img, bounds.Min.X, bounds.Min.Y+fy, row)
+				for x := range sw {
+					for fx := xs[x]; fx < xs[x+1]; fx++ {
+						sums[x] += int(row[fx])
+					}
+				}
+			}
+
+			dst := gray.Row(y)
+			for x := range sw {
+				if n := (ys[y+1] - ys[y]) * (xs[x+1] - xs[x]); n > 0 {
+					dst[x] = uint8(sums[x] / n)
+				}
+			}
+		}
+	})
+	return gray
+}
+
+// upscalePoint maps a pixel on the f times image back to full resolution, lining up pixel centers.
+func upscalePoint(p r2.Point, f float64) r2.Point {
+	c := .5 - .5*f
+	return r2.Point{X: (p.X + c) / f, Y: (p.Y + c) / f}
+}
+
+// upscaleLine is upscalePoint for every point on l.
+func upscaleLine(l Line, f float64) Line {
+	c := .5 - .5*f
+	cosT, sinT := math.Cos(l.theta), math.Sin(l.theta)
+	return Line{rho: (l.rho + c*(cosT+sinT)) / f, theta: l.theta, votes: l.votes}
+}
+
+// cornerLines are the indexes into top, bottom, left, right of the two lines meeting at
+// each of TL, TR, BR, BL.
+var cornerLines = [4][2]int{{0, 2}, {0, 3}, {1, 3}, {1, 2}}
+
+const (
+	// refineWindowSquares is how many of its squares (side lengths) a corner's refine window
+	// reaches, when that's less than RefineWindow. Any further and a distant board's window
+	// takes in grid lines and pieces well inside it.
+	refineWindowSquares = 1.25
+	// minRefineWindow is the smallest half size (pixels) of a refine window.
+	minRefineWindow = 12
+)
+
+// cornerSquareSizes is how big (pixels) the square at each of TL, TR, BR, BL of the n x n board
+// in corners is, the mean of its two sides on the border. Under perspective the ones at the far
+// edge are smaller. 0 if corners isn't a quad.
+func cornerSquareSizes(corners subPixelCorners, n int) [4]float64 {
+	var sizes [4]float64
+	h, err := computeHomography(corners.slice(), float64(n))
+	if err != nil {
+		return sizes
+	}
+	toImage, err := h.Inverse()
+	if err != nil {
+		return sizes
+	}
+
+	side := float64(n)
+	// each corner on the board, and the directions along its two border edges
+	board := [4][3]r2.Point{
+		{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}},
+		{{X: side, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}},
+		{{X: side, Y: side}, {X: -1, Y: 0}, {X: 0, Y: -1}},
+		{{X: 0, Y: side}, {X: 1, Y: 0}, {X: 0, Y: -1}},
+	}
+	for i, b := range board {
+		p := toImage.Apply(b[0])
+		sizes[i] = (toImage.Apply(b[0].Add(b[1])).Sub(p).Norm() + toImage.Apply(b[0].Add(b[2])).Sub(p).Norm()) / 2
+	}
+	return sizes
+}
+
+// refineWindow is the half size (pixels) of the refine window around a corner whose square is
+// square pixels: maxWindow, or less for small squares, but not under minRefineWindow.
+func refineWindow(maxWindow int, square float64) int {
+	if square <= 0 {
+		return maxWindow
+	}
+	r := min(float64(maxWindow), max(minRefineWindow, refineWindowSquares*square))
+	return int(math.Round(r))
+}
+
+// refineBorderInWindows refits top, bottom, left and right from the edge pixels near each line
+// in the windows around the two corners it ends at, each sized to the squares there, see
+// refineWindow. The windows are far apart, so the slope is still well constrained without
+// running Sobel over the whole image.
+func refineBorderInWindows(img image.Image, lines []Line, opts BoardFinderOptions, dbg *BoardFinderDebug) []Line {
+	bounds := img.Bounds()
+	corners, ok := borderCorners(lines[0], lines[1], lines[2], lines[3])
+	if !ok {
+		return lines
+	}
+
+	squares := cornerSquareSizes(corners, opts.gridSize())
+	pts := make([][]refinePoint, len(lines))
+	horizontal := make([]bool, len(lines))
+
+	for ci, c := range corners.slice() {
+		r := refineWindow(opts.RefineWindow, squares[ci])
+		cx, cy := int(math.Round(c.X)), int(math.Round(c.Y))
+		win := image.Rect(cx-r, cy-r, cx+r+1, cy+r+1).Intersect(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		if win.Dx() < 3 || win.Dy() < 3 {
+			continue
+		}
+
+		gray := makeGrayImageRect(img, win.Add(bounds.Min))
+		if opts.blur > 0 {
+			blurGray(gray, opts.blur)
+		}
+		sobel := sobelEdgeDetection(gray)
+		gray.release()
+
+		for _, li := range cornerLines[ci] {
+			l := lines[li]
+			ox, oy := float64(win.Min.X), float64(win.Min.Y)
+			local := Line{rho: l.rho - ox*math.Cos(l.theta) - oy*math.Sin(l.theta), theta: l.theta, votes: l.votes}
+
+			found, isHorizontal := refineEdgePoints(local, sobel, win.Dx(), win.Dy(), opts)
+			horizontal[li] = isHorizontal
+			for _, p := range found {
+				p = refinePoint{p.x + ox, p.y + oy}
+				pts[li] = append(pts[li], p)
+				if dbg != nil {
+					dbg.BoundaryPoints = append(dbg.BoundaryPoints, image.Point{X: int(p.x), Y: int(p.y)})
+				}
+			}
+		}
+		sobel.release()
+	}
+
+	refined := make([]Line, len(lines))
+	for i, l := range lines {
+		refined[i] = fitRefinedLine(l, pts[i], horizontal[i])
+	}
+	return refined
+}
+
+// upscale moves everything recorded on the f times image to full resolution coordinates.
+// The refinement results are dropped, the full resolution refinement records its own.
+func (dbg *BoardFinderDebug) upscale(f float64) {
+	for _, lines := range [][]Line{dbg.HoughLines, dbg.CandidateLines, dbg.BorderLines} {
+		for i, l := range lines {
+			lines[i] = upscaleLine(l, f)
+		}
+	}
+	for i, p := range dbg.GridCorners {
+		dbg.GridCorners[i] = upscalePoint(p, f)
+	}
+	dbg.BoundaryPoints = nil
+	dbg.RefinedLines = nil
+	dbg.RefinedCorners = nil
+}
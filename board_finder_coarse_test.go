@@ -0,0 +1,118 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestUpscaleLine(t *testing.T) {
+	l := Line{rho: 120, theta: 1.4}
+	up := upscaleLine(l, .5)
+
+	// any point on the small line lands on the upscaled line
+	for _, x := range []float64{0, 50, 300} {
+		y := (l.rho - x*math.Cos(l.theta)) / math.Sin(l.theta)
+		p := upscalePoint(r2.Point{X: x, Y: y}, .5)
+		d := p.X*math.Cos(up.theta) + p.Y*math.Sin(up.theta) - up.rho
+		test.That(t, math.Abs(d), test.ShouldBeLessThan, 1e-9)
+	}
+
+	// pixel 0 covers full resolution pixels 0 and 1, so its center is at .5
+	test.That(t, upscalePoint(r2.Point{}, .5), test.ShouldResemble, r2.Point{X: .5, Y: .5})
+}
+
+func TestFindBoardCoarseToFine(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board5.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	opts := DefaultBoardFinderOptions()
+	test.That(t, opts.downscaled(1280, 720), test.ShouldBeTrue)
+	test.That(t, opts.downscaled(640, 360), test.ShouldBeFalse)
+
+	coarse, err := findBoardWithOptions(input, opts)
+	test.That(t, err, test.ShouldBeNil)
+
+	opts.Downscale = 1
+	full, err := findBoardWithOptions(input, opts)
+	test.That(t, err, test.ShouldBeNil)
+
+	t.Logf("coarse %v full %v", coarse, full)
+	test.That(t, maxCornerError(coarse, full), test.ShouldBeLessThan, 2.5)
+}
+
+func TestRefineWindowScales(t *testing.T) {
+	// the fixtures' squares are 70-90 pixels, their windows stay at RefineWindow
+	test.That(t, refineWindow(80, 72), test.ShouldEqual, 80)
+	test.That(t, refineWindow(80, 35), test.ShouldEqual, 44)
+	test.That(t, refineWindow(80, 4), test.ShouldEqual, minRefineWindow)
+	test.That(t, refineWindow(80, 0), test.ShouldEqual, 80)
+
+	// the far edge of a board in perspective has smaller squares
+	sizes := cornerSquareSizes(subPixelCornersFromSlice([]r2.Point{{X: 100, Y: 100}, {X: 500, Y: 100}, {X: 600, Y: 500}, {X: 0, Y: 500}}), 8)
+	test.That(t, sizes[0], test.ShouldBeLessThan, sizes[3])
+	test.That(t, sizes[0], test.ShouldAlmostEqual, sizes[1], 1e-9)
+	test.That(t, sizes[2], test.ShouldAlmostEqual, sizes[3], 1e-9)
+}
+
+func TestRefineBorderDistantBoard(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board2.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{305, 71}, {883, 59}, {904, 639}, {311, 660}}
+	for i := range expected {
+		expected[i] = expected[i].Div(2)
+	}
+
+	// like a camera twice as far away, squares about 36 pixels
+	half := downscale(input, 2)
+	opts := DefaultBoardFinderOptions()
+	small := downscaleGray(half, opts.Downscale)
+	coarse, lines := detectBoardGray(context.Background(), small, coarseOptions(opts), nil)
+	small.release()
+	test.That(t, coarse.Found, test.ShouldBeTrue)
+	for i, l := range lines {
+		lines[i] = upscaleLine(l, opts.Downscale)
+	}
+	before, ok := borderCorners(lines[0], lines[1], lines[2], lines[3])
+	test.That(t, ok, test.ShouldBeTrue)
+
+	sizes := cornerSquareSizes(before, 8)
+	test.That(t, refineWindow(opts.RefineWindow, sizes[0]), test.ShouldBeLessThan, opts.RefineWindow)
+
+	refined := refineBorderInWindows(half, lines, opts, nil)
+	after, ok := borderCorners(refined[0], refined[1], refined[2], refined[3])
+	test.That(t, ok, test.ShouldBeTrue)
+
+	errBefore := maxCornerError(before.round().Slice(), expected)
+	errAfter := maxCornerError(after.round().Slice(), expected)
+	t.Logf("coarse %v (%.2f) refined %v (%.2f)", before.round().Slice(), errBefore, after.round().Slice(), errAfter)
+	test.That(t, errAfter, test.ShouldBeLessThanOrEqualTo, errBefore)
+	test.That(t, errAfter, test.ShouldBeLessThan, 2)
+}
+
+func BenchmarkFindBoard(b *testing.B) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(b, err, test.ShouldBeNil)
+
+	for _, f := range []float64{1, .5} {
+		opts := DefaultBoardFinderOptions()
+		opts.Downscale = f
+		name := "full"
+		if f < 1 {
+			name = "coarse"
+		}
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				_, err := findBoardWithOptions(input, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package viamchess
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// BoardFinderDebug is everything findBoard looked at on the way to its answer.
+// A stage that was never reached is left empty, so the first empty field is where it gave up.
+type BoardFinderDebug struct {
+	// Edges is the Sobel magnitude, the mask every later stage works from.
+	Edges *image.Gray
+	// Lines is the input with every Hough line in gray, the lines left after merging and
+	// dropping isolated ones in yellow, the grid border in blue and the refined border in red.
+	Lines *image.RGBA
+	// Corners is the input with the boundary points in green, the grid corners in blue, the
+	// inner corners and where they put the outer ones in magenta, and the refined corners in red.
+	Corners *image.RGBA
+
+	HoughLines     []Line
+	CandidateLines []Line
+	// BorderLines and RefinedLines are top, bottom, left, right.
+	BorderLines  []Line
+	RefinedLines []Line
+
+	// BoundaryPoints are the edge pixels the border lines were refined from.
+	BoundaryPoints []image.Point
+
+	// GridCorners are where the unrefined border lines cross, RefinedCorners the final answer.
+	// Both are TL, TR, BR, BL.
+	GridCorners    []r2.Point
+	RefinedCorners []r2.Point
+
+	// SaddlePoints are the inner corners found, SaddleCorners where the grid fitted through them
+	// puts the outer corners. Empty if there weren't enough.
+	SaddlePoints  []r2.Point
+	SaddleCorners []r2.Point
+
+	// CornerError is set when the corners found aren't a sensible TL, TR, BR, BL quad.
+	CornerError error
+
+	sobel *sobelResult
+}
+
+// FindBoardDebug is FindBoard, plus the intermediate results of each stage.
+// It's a lot slower, so only use it to work out why a board isn't being found.
+func FindBoardDebug(img image.Image) (FindBoardResult, *BoardFinderDebug, error) {
+	return FindBoardDebugWithOptions(img, DefaultBoardFinderOptions())
+}
+
+// FindBoardDebugWithOptions is FindBoardDebug with tunable thresholds instead of the defaults.
+func FindBoardDebugWithOptions(img image.Image, opts BoardFinderOptions) (FindBoardResult, *BoardFinderDebug, error) {
+	dbg := &BoardFinderDebug{}
+	res := detectBoard(img, opts, dbg)
+	dbg.render(img)
+	return res, dbg, nil
+}
+
+// Images are the debug images by name, for writing out or sending back.
+func (dbg *BoardFinderDebug) Images() map[string]image.Image {
+	return map[string]image.Image{
+		"edges":   dbg.Edges,
+		"lines":   dbg.Lines,
+		"corners": dbg.Corners,
+	}
+}
+
+// EncodeImages is Images as base64 encoded PNGs.
+func (dbg *BoardFinderDebug) EncodeImages() (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+	for name, img := range dbg.Images() {
+		s, err := encodePNG(img)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = s
+	}
+	return ret, nil
+}
+
+// encodePNG is img as a base64 encoded PNG, for returning from DoCommand.
+func encodePNG(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (dbg *BoardFinderDebug) render(img image.Image) {
+	bounds := img.Bounds()
+	rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+
+	dbg.Edges = image.NewGray(rect)
+	if dbg.sobel != nil {
+		copy(dbg.Edges.Pix, dbg.sobel.magnitude.Pix)
+	}
+
+	dbg.Lines = image.NewRGBA(rect)
+	draw.Draw(dbg.Lines, rect, img, bounds.Min, draw.Src)
+	for _, l := range dbg.HoughLines {
+		drawLine(dbg.Lines, l, color.RGBA{128, 128, 128, 255})
+	}
+	for _, l := range dbg.CandidateLines {
+		drawLine(dbg.Lines, l, color.RGBA{255, 255, 0, 255})
+	}
+	for _, l := range dbg.BorderLines {
+		drawLine(dbg.Lines, l, color.RGBA{0, 0, 255, 255})
+	}
+	for _, l := range dbg.RefinedLines {
+		drawLine(dbg.Lines, l, color.RGBA{255, 0, 0, 255})
+	}
+
+	dbg.Corners = image.NewRGBA(rect)
+	draw.Draw(dbg.Corners, rect, img, bounds.Min, draw.Src)
+	green := color.RGBA{0, 255, 0, 255}
+	for _, p := range dbg.BoundaryPoints {
+		dbg.Corners.Set(p.X, p.Y, green)
+	}
+	for _, p := range dbg.GridCorners {
+		drawMarker(dbg.Corners, p, 10, color.RGBA{0, 0, 255, 255})
+	}
+	for _, p := range dbg.SaddlePoints {
+		drawMarker(dbg.Corners, p, 5, color.RGBA{255, 0, 255, 255})
+	}
+	for _, p := range dbg.SaddleCorners {
+		drawMarker(dbg.Corners, p, 10, color.RGBA{255, 0, 255, 255})
+	}
+	for _, p := range dbg.RefinedCorners {
+		drawMarker(dbg.Corners, p, 15, color.RGBA{255, 0, 0, 255})
+	}
+}
+
+// drawLine draws l, in rho/theta form, across the whole image.
+func drawLine(img *image.RGBA, l Line, c color.Color) {
+	b := img.Bounds()
+	cosT, sinT := math.Cos(l.theta), math.Sin(l.theta)
+	if math.Abs(sinT) > math.Abs(cosT) {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			y := int(math.Round((l.rho - float64(x)*cosT) / sinT))
+			if y >= b.Min.Y && y < b.Max.Y {
+				img.Set(x, y, c)
+			}
+		}
+		return
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		x := int(math.Round((l.rho - float64(y)*sinT) / cosT))
+		if x >= b.Min.X && x < b.Max.X {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// drawMarker draws an x centered on p.
+func drawMarker(img *image.RGBA, p r2.Point, size int, c color.Color) {
+	cx, cy := int(math.Round(p.X)), int(math.Round(p.Y))
+	for d := -size; d <= size; d++ {
+		img.Set(cx+d, cy+d, c)
+		img.Set(cx+d, cy-d, c)
+	}
+}
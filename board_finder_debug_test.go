@@ -0,0 +1,82 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestFindBoardDebug(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	corners, err := FindBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+
+	res, dbg, err := FindBoardDebug(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	test.That(t, res.Corners, test.ShouldResemble, corners)
+
+	test.That(t, len(dbg.HoughLines), test.ShouldBeGreaterThan, 4)
+	test.That(t, len(dbg.CandidateLines), test.ShouldBeGreaterThanOrEqualTo, 4)
+	test.That(t, len(dbg.CandidateLines), test.ShouldBeLessThanOrEqualTo, len(dbg.HoughLines))
+	test.That(t, len(dbg.BorderLines), test.ShouldEqual, 4)
+	test.That(t, len(dbg.RefinedLines), test.ShouldEqual, 4)
+	test.That(t, len(dbg.BoundaryPoints), test.ShouldBeGreaterThan, 0)
+	test.That(t, len(dbg.GridCorners), test.ShouldEqual, 4)
+	test.That(t, dbg.RefinedCorners, test.ShouldResemble, res.SubPixelCorners)
+
+	size := input.Bounds().Size()
+	for name, img := range dbg.Images() {
+		test.That(t, img.Bounds().Size(), test.ShouldResemble, size)
+		t.Logf("%s ok", name)
+	}
+}
+
+func TestFindBoardDebugNotFound(t *testing.T) {
+	input := image.NewGray(image.Rect(0, 0, 200, 100))
+
+	res, dbg, err := FindBoardDebug(input)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res.Found, test.ShouldBeFalse)
+	test.That(t, res.Corners, test.ShouldResemble, defaultCorners(200, 100))
+
+	test.That(t, len(dbg.HoughLines), test.ShouldEqual, 0)
+	test.That(t, len(dbg.RefinedCorners), test.ShouldEqual, 0)
+	test.That(t, dbg.Edges.Bounds(), test.ShouldResemble, input.Bounds())
+}
+
+func TestPieceFinderDebugCommand(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	var pc pointcloud.PointCloud
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"debug": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["found"], test.ShouldBeTrue)
+	test.That(t, len(ret["corners"].([]interface{})), test.ShouldEqual, 4)
+
+	images := ret["images"].(map[string]interface{})
+	test.That(t, len(images), test.ShouldEqual, 3)
+	raw, err := base64.StdEncoding.DecodeString(images["lines"].(string))
+	test.That(t, err, test.ShouldBeNil)
+	img, err := png.Decode(bytes.NewReader(raw))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Size(), test.ShouldResemble, input.Bounds().Size())
+
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"foo": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,242 @@
+package viamchess
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	defaultGridSize = 8
+	// maxGridSize is as many files as there are letters to name them.
+	maxGridSize = 26
+)
+
+// BoardFinderOptions are the tunable thresholds used by findBoard.
+// Start from DefaultBoardFinderOptions and override what you need.
+type BoardFinderOptions struct {
+	// EdgeThreshold is the minimum Sobel magnitude for a pixel to vote in the Hough transform.
+	EdgeThreshold int `json:"edge-threshold"`
+	// RefineEdgeThreshold is the minimum Sobel magnitude for a pixel to be used when refining a line.
+	RefineEdgeThreshold int `json:"refine-edge-threshold"`
+	// RefineBand is how far (pixels) from a line edge pixels are considered when refining it.
+	RefineBand float64 `json:"refine-band"`
+
+	// VoteThreshold is the minimum number of Hough votes for a line. 0 means VoteFraction of the shorter image side.
+	VoteThreshold int `json:"vote-threshold"`
+	// VoteFraction scales the minimum votes with the image, since a board edge is about as long as the image is tall.
+	VoteFraction float64 `json:"vote-fraction"`
+	// MaxLinesPerOrientation is how many of the strongest horizontal, and vertical, lines are kept. 0 keeps them all.
+	MaxLinesPerOrientation int `json:"max-lines-per-orientation"`
+
+	// AngleTolerance is how far (degrees) from horizontal/vertical a line can be.
+	AngleTolerance float64 `json:"angle-tolerance"`
+	// MergeDistance is how close (pixels) two parallel lines have to be to merge.
+	MergeDistance float64 `json:"merge-distance"`
+	// IsolatedFraction is, as a fraction of the image height, how far a line can be from
+	// its nearest neighbor before it's dropped.
+	IsolatedFraction float64 `json:"isolated-fraction"`
+
+	// MinGridSpacing is the smallest square size (pixels) considered when fitting the grid.
+	MinGridSpacing float64 `json:"min-grid-spacing"`
+	// GridTolerance is how far off (fraction of a square) a line can be and still count as a grid line.
+	GridTolerance float64 `json:"grid-tolerance"`
+
+	// Downscale is how much to shrink the image before finding lines. The corners are then
+	// refined at full resolution. 1 runs everything at full resolution, as do images whose
+	// shorter side would end up under minCoarseSide.
+	Downscale float64 `json:"downscale"`
+	// RefineWindow is the half size (full resolution pixels) of the window around each coarse
+	// corner used to refine it. A corner whose squares are small gets a smaller one, see
+	// refineWindow.
+	RefineWindow int `json:"refine-window"`
+
+	// MinBoardFraction is how much of the board has to look like a checkerboard, see boardOccluded.
+	// Less than that and the board is reported occluded instead of found. 0 skips the check.
+	MinBoardFraction float64 `json:"min-board-fraction"`
+
+	// A board found has to cover MinQuadArea of the image, have no edge more than MaxEdgeRatio
+	// times as long as another, and every corner within CornerAngleTolerance (degrees) of square.
+	// See validateCornerQuad.
+	MinQuadArea          float64 `json:"min-quad-area"`
+	MaxEdgeRatio         float64 `json:"max-edge-ratio"`
+	CornerAngleTolerance float64 `json:"corner-angle-tolerance"`
+
+	// MaxCornerOutside is how far (fraction of the shorter image side) a corner can be out of
+	// the frame, for a camera mounted close. A border line that's out of the frame is extrapolated
+	// from the grid lines that aren't, as long as it's within this. 0 turns both off.
+	MaxCornerOutside float64 `json:"max-corner-outside"`
+
+	// MinSaddlePoints is how many of the 49 inner corners of a chess board have to be found before
+	// the grid through them gets a say in where the outer corners are, see checkInnerCorners.
+	// Other grids need the same share of theirs. 0 skips it.
+	MinSaddlePoints int `json:"min-saddle-points"`
+
+	// GlareMinArea is how many pixels a patch of saturated white has to cover to be taken for
+	// a reflection and painted over before looking for lines, see suppressGlare. 0 leaves it.
+	GlareMinArea int `json:"glare-min-area"`
+
+	// MinExposure is how far down (fraction) the edge and saddle contrast thresholds follow an
+	// underexposed frame, see exposureScale. 0 keeps them as they are.
+	MinExposure float64 `json:"min-exposure"`
+
+	// MinSharpness is how sharp a frame has to be, see frameSharpness, not to be taken for out of
+	// focus. One that isn't is blurred by BlurSigma (pixels) before looking for edges, which
+	// steadies its gradients, and the edge thresholds come down to what its gradients reach,
+	// see softThreshold. 0 for either turns it off.
+	MinSharpness float64 `json:"min-sharpness"`
+	BlurSigma    float64 `json:"blur-sigma"`
+
+	// GridSize is how many squares the board has along each side, 8 for chess, 10 for a
+	// draughts board or 6 for a teaching board. 0 means 8.
+	GridSize int `json:"grid-size"`
+
+	// Mode is how the board is found: "" by its lines and squares, or BoardModeFiducial by four
+	// printed tags on its corners, see GenerateFiducialSheet. Without all four tags in view it
+	// falls back to the lines and squares.
+	Mode string `json:"mode"`
+
+	// exposure is the exposureScale detectBoard found for the frame, 0 means 1.
+	exposure float64
+	// blur is the BlurSigma detectBoard blurs the frame by, 0 when the frame is sharp enough.
+	blur float64
+}
+
+// DefaultBoardFinderOptions are the values findBoard uses.
+func DefaultBoardFinderOptions() BoardFinderOptions {
+	return BoardFinderOptions{
+		EdgeThreshold:          90,
+		RefineEdgeThreshold:    80,
+		RefineBand:             3,
+		VoteFraction:           .14, // 100 votes at 720p
+		MaxLinesPerOrientation: 40,
+		AngleTolerance:         15,
+		MergeDistance:          15,
+		IsolatedFraction:       .2,
+		MinGridSpacing:         10,
+		GridTolerance:          .15,
+		Downscale:              .5,
+		RefineWindow:           80,
+		MinBoardFraction:       .75,
+		MinQuadArea:            .08,
+		MaxEdgeRatio:           1.5,
+		CornerAngleTolerance:   25,
+		MaxCornerOutside:       .1,
+		MinSaddlePoints:        30,
+		GlareMinArea:           300,
+		MinExposure:            .2,
+		MinSharpness:           25,
+		BlurSigma:              1.5,
+		GridSize:               defaultGridSize,
+	}
+}
+
+// BoardFinderOptionsFromMap starts from the defaults and overrides any fields set in m,
+// using the json names.
+func BoardFinderOptionsFromMap(m map[string]interface{}) (BoardFinderOptions, error) {
+	opts := DefaultBoardFinderOptions()
+	if len(m) == 0 {
+		return opts, nil
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          "json",
+		Result:           &opts,
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return opts, err
+	}
+
+	err = decoder.Decode(m)
+	if err != nil {
+		return opts, err
+	}
+	if opts.GridSize != 0 && (opts.GridSize < 2 || opts.GridSize > maxGridSize) {
+		return opts, fmt.Errorf("grid-size %d needs to be between 2 and %d", opts.GridSize, maxGridSize)
+	}
+	if opts.Mode != "" && opts.Mode != BoardModeFiducial {
+		return opts, fmt.Errorf("mode %q needs to be empty or %q", opts.Mode, BoardModeFiducial)
+	}
+	return opts, nil
+}
+
+// minSaddlePoints is MinSaddlePoints for the grid's own number of inner corners.
+func (opts BoardFinderOptions) minSaddlePoints() int {
+	inner := (opts.gridSize() - 1) * (opts.gridSize() - 1)
+	return opts.MinSaddlePoints * inner / 49
+}
+
+// gridSize is GridSize, 0 being 8.
+func (opts BoardFinderOptions) gridSize() int {
+	if opts.GridSize <= 0 {
+		return defaultGridSize
+	}
+	return opts.GridSize
+}
+
+// voteThreshold is the minimum Hough votes for a width x height image.
+func (opts BoardFinderOptions) voteThreshold(width, height int) int {
+	if opts.VoteThreshold > 0 {
+		return opts.VoteThreshold
+	}
+	return int(opts.VoteFraction * float64(min(width, height)))
+}
+
+// exposed is threshold, a brightness difference, scaled for the frame's exposure.
+func (opts BoardFinderOptions) exposed(threshold float64) float64 {
+	if opts.exposure <= 0 {
+		return threshold
+	}
+	return threshold * opts.exposure
+}
+
+// edgeThreshold is EdgeThreshold for this frame.
+func (opts BoardFinderOptions) edgeThreshold() int {
+	return int(math.Round(opts.exposed(float64(opts.EdgeThreshold))))
+}
+
+// refineEdgeThreshold is RefineEdgeThreshold for this frame.
+func (opts BoardFinderOptions) refineEdgeThreshold() int {
+	return int(math.Round(opts.exposed(float64(opts.RefineEdgeThreshold))))
+}
+
+// softThreshold is threshold, an edge threshold, brought down for an out of focus frame to
+// softEdgeFraction of the softEdgePercentile of the gradients in edges, when that's under it,
+// but not below minSoftEdgeThreshold. A frame in focus keeps it.
+func (opts BoardFinderOptions) softThreshold(threshold int, edges *GrayPlane) int {
+	if opts.blur <= 0 {
+		return threshold
+	}
+	return min(threshold, max(minSoftEdgeThreshold, int(softEdgeFraction*float64(gradientPercentile(edges, softEdgePercentile)))))
+}
+
+// maxOutside is MaxCornerOutside in pixels for a width x height image.
+func (opts BoardFinderOptions) maxOutside(width, height int) float64 {
+	return opts.MaxCornerOutside * float64(min(width, height))
+}
+
+// minCoarseSide is the smallest the downscaled image can be, below that there aren't enough
+// pixels per square for the grid fit to be reliable.
+const minCoarseSide = 320
+
+// downscaled is true if a width x height image should go through the coarse pass.
+func (opts BoardFinderOptions) downscaled(width, height int) bool {
+	return opts.Downscale > 0 && opts.Downscale < 1 &&
+		float64(min(width, height))*opts.Downscale >= minCoarseSide
+}
+
+// Fingerprint is a short hash of every option, to tell apart results from different settings.
+func (o BoardFinderOptions) Fingerprint() string {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return "unknown"
+	}
+	h := fnv.New32a()
+	h.Write(b)
+	return fmt.Sprintf("%08x", h.Sum32())
+}
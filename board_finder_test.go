@@ -1,15 +1,22 @@
 package viamchess
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"math"
+	"os"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/golang/geo/r2"
 	"go.viam.com/rdk/rimage"
 	"go.viam.com/test"
+	xdraw "golang.org/x/image/draw"
 )
 
 type boardTestCase struct {
@@ -165,23 +172,19 @@ func testBoardCornerDetection(t *testing.T, tc boardTestCase) {
 	test.That(t, err, test.ShouldBeNil)
 
 	// Find board corners
-	corners, err := findBoard(input)
-	test.That(t, err, test.ShouldBeNil)
+	opts := DefaultBoardFinderOptions()
+	res := FindBoardEx(input, opts)
+	corners := res.Corners
 	test.That(t, len(corners), test.ShouldEqual, 4)
 
 	t.Logf("Found corners: %v", corners)
 	t.Logf("Image size: %dx%d", input.Bounds().Dx(), input.Bounds().Dy())
 
-	// Draw corners on output image
-	output := image.NewRGBA(input.Bounds())
-	draw.Draw(output, input.Bounds(), input, image.Point{}, draw.Src)
-
-	// Mark detected corners with red circles
-	red := color.RGBA{255, 0, 0, 255}
-	for _, corner := range corners {
-		drawCircle(output, corner.X, corner.Y, 10, red)
-		drawCross(output, corner.X, corner.Y, 15, red)
-	}
+	// Draw corners on output image, stamped with what made them if asked
+	output := RenderCornerOverlay(input, res, OverlayOptions{
+		Stamp:  os.Getenv(stampArtifactsEnv) != "",
+		Params: opts,
+	})
 
 	// Mark expected corners with green circles
 	green := color.RGBA{0, 255, 0, 255}
@@ -216,27 +219,571 @@ func testBoardCornerDetection(t *testing.T, tc boardTestCase) {
 	}
 }
 
-func drawCircle(img *image.RGBA, cx, cy, radius int, c color.Color) {
-	for angle := 0.0; angle < 360; angle += 1 {
-		x := cx + int(float64(radius)*math.Cos(angle*math.Pi/180))
-		y := cy + int(float64(radius)*math.Sin(angle*math.Pi/180))
-		if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-			img.Set(x, y, c)
+// stampArtifactsEnv set to anything makes the test output images carry a footer saying
+// when and with what options they were made.
+const stampArtifactsEnv = "VIAM_CHESS_STAMP_ARTIFACTS"
+
+// renderCheckerboard draws an n x n board with a white border on a dark table.
+// The board is centered at (cx, cy), size pixels wide, rotated by angle radians.
+// Pixels are 4x4 supersampled so edges land at sub-pixel positions.
+// It returns the image and the TL, TR, BR, BL corners of the grid, using the
+// same convention as findBoard where pixel (x, y) is centered on integer coordinates.
+func renderCheckerboard(width, height int, cx, cy, size, angle float64, n int) (*image.RGBA, []r2.Point) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	cosA, sinA := math.Cos(angle), math.Sin(angle)
+	toImage := func(u, v float64) r2.Point {
+		x := (u - .5) * size
+		y := (v - .5) * size
+		return r2.Point{X: cx + x*cosA - y*sinA - .5, Y: cy + x*sinA + y*cosA - .5}
+	}
+
+	const ss = 4
+	for py := range height {
+		for px := range width {
+			var total float64
+			for sy := range ss {
+				for sx := range ss {
+					x := float64(px) + (float64(sx)+.5)/ss - cx
+					y := float64(py) + (float64(sy)+.5)/ss - cy
+					u := (x*cosA+y*sinA)/size + .5
+					v := (-x*sinA+y*cosA)/size + .5
+
+					switch {
+					case u >= 0 && u < 1 && v >= 0 && v < 1:
+						if (int(u*float64(n))+int(v*float64(n)))%2 == 0 {
+							total += 235
+						} else {
+							total += 40
+						}
+					case u >= -.08 && u < 1.08 && v >= -.08 && v < 1.08:
+						total += 235
+					default:
+						total += 30
+					}
+				}
+			}
+			g := uint8(total / (ss * ss))
+			img.SetRGBA(px, py, color.RGBA{g, g, g, 255})
 		}
 	}
+
+	return img, []r2.Point{toImage(0, 0), toImage(1, 0), toImage(1, 1), toImage(0, 1)}
+}
+
+func TestFindBoardSubPixelSynthetic(t *testing.T) {
+	img, expected := renderCheckerboard(640, 480, 320.3, 241.7, 330.6, 0.021, 8)
+
+	corners, err := FindBoardSubPixel(img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(corners), test.ShouldEqual, 4)
+
+	for i, c := range corners {
+		d := c.Sub(expected[i]).Norm()
+		t.Logf("corner %d expected %v got %v (%.2f)", i, expected[i], c, d)
+		test.That(t, d, test.ShouldBeLessThan, .5)
+	}
+
+	rounded, err := FindBoard(img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rounded, test.ShouldResemble, roundCorners(corners))
 }
 
-func drawCross(img *image.RGBA, cx, cy, size int, c color.Color) {
-	for d := -size; d <= size; d++ {
-		// Horizontal line
-		x := cx + d
-		if x >= 0 && x < img.Bounds().Max.X && cy >= 0 && cy < img.Bounds().Max.Y {
-			img.Set(x, cy, c)
+// boxBlur averages every pixel with its (2r+1)x(2r+1) neighborhood.
+func boxBlur(src image.Image, r int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var tr, tg, tb, n uint32
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					p := image.Point{x + dx, y + dy}
+					if !p.In(b) {
+						continue
+					}
+					cr, cg, cb, _ := src.At(p.X, p.Y).RGBA()
+					tr += cr >> 8
+					tg += cg >> 8
+					tb += cb >> 8
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(tr / n), uint8(tg / n), uint8(tb / n), 255})
 		}
-		// Vertical line
-		y := cy + d
-		if cx >= 0 && cx < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-			img.Set(cx, y, c)
+	}
+	return dst
+}
+
+func maxCornerError(found, expected []image.Point) float64 {
+	worst := 0.0
+	for i := range expected {
+		dx := float64(found[i].X - expected[i].X)
+		dy := float64(found[i].Y - expected[i].Y)
+		worst = math.Max(worst, math.Sqrt(dx*dx+dy*dy))
+	}
+	return worst
+}
+
+func TestFindBoardWithOptionsBlurred(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	// defaults are what findBoard uses
+	a, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	b, err := FindBoardWithOptions(input, DefaultBoardFinderOptions())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, b, test.ShouldResemble, a)
+
+	blurred := boxBlur(input, 3)
+
+	// at full resolution; the coarse pass sharpens the blur enough to hide the point, and with the
+	// thresholds fixed; out of focus frames get their own, see TestFindBoardOutOfFocus
+	opts := DefaultBoardFinderOptions()
+	opts.Downscale = 1
+	opts.MinSharpness = 0
+	// they only find half the board, which gets rejected for being twice as wide as it is tall
+	corners, err := FindBoardWithOptions(blurred, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+	t.Logf("default thresholds on blurred: %v (%.1f) %v", corners, maxCornerError(corners, expected), err)
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeGreaterThan, 10)
+
+	opts.EdgeThreshold = 50
+	opts.RefineEdgeThreshold = 44
+	corners, err = FindBoardWithOptions(blurred, opts)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("lower thresholds on blurred: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 4)
+
+	opts = DefaultBoardFinderOptions()
+	opts.Downscale = 1
+	corners, err = FindBoardWithOptions(blurred, opts)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("out of focus on blurred: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 5)
+}
+
+func TestBoardFinderOptionsFromMap(t *testing.T) {
+	opts, err := BoardFinderOptionsFromMap(nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts, test.ShouldResemble, DefaultBoardFinderOptions())
+
+	opts, err = BoardFinderOptionsFromMap(map[string]interface{}{"edge-threshold": 40, "grid-tolerance": .2})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts.EdgeThreshold, test.ShouldEqual, 40)
+	test.That(t, opts.GridTolerance, test.ShouldEqual, .2)
+	test.That(t, opts.VoteThreshold, test.ShouldEqual, DefaultBoardFinderOptions().VoteThreshold)
+
+	_, err = BoardFinderOptionsFromMap(map[string]interface{}{"not-a-field": 1})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// downscale averages each f x f block of src into one pixel.
+func downscale(src image.Image, f int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()/f, b.Dy()/f))
+	for y := range b.Dy() / f {
+		for x := range b.Dx() / f {
+			var r, g, bl uint32
+			for dy := range f {
+				for dx := range f {
+					cr, cg, cb, _ := src.At(b.Min.X+x*f+dx, b.Min.Y+y*f+dy).RGBA()
+					r += cr >> 8
+					g += cg >> 8
+					bl += cb >> 8
+				}
+			}
+			n := uint32(f * f)
+			dst.Set(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), 255})
 		}
 	}
+	return dst
+}
+
+func TestFindBoardDownscaled(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	small := downscale(input, 2)
+	corners, err := findBoard(small)
+	test.That(t, err, test.ShouldBeNil)
+
+	for i := range corners {
+		corners[i] = corners[i].Mul(2)
+	}
+	t.Logf("downscaled corners (full res): %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 2*3.5)
+
+	// a fixed threshold tuned for 720p finds the wrong board at half size
+	opts := DefaultBoardFinderOptions()
+	opts.VoteThreshold = 100
+	opts.MaxCornerOutside = 0 // extending it past the frame makes it a quad that gets rejected
+	corners, err = findBoardWithOptions(small, opts)
+	test.That(t, err, test.ShouldBeNil)
+	for i := range corners {
+		corners[i] = corners[i].Mul(2)
+	}
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeGreaterThan, 2*3.5)
+}
+
+func TestFindBoardCanceled(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = FindBoardCtx(ctx, input, DefaultBoardFinderOptions())
+	test.That(t, errors.Is(err, context.Canceled), test.ShouldBeTrue)
+
+	// it gives up at the next stage, or the next few rows of voting, instead of finishing. The
+	// best of a few tries each, so a garbage collection in the middle of one doesn't fail it, and
+	// against the uncanceled run, which the race detector or a busy machine slows just the same.
+	best, full := time.Hour, time.Hour
+	for range 3 {
+		start := time.Now()
+		_, err = FindBoardCtx(context.Background(), input, DefaultBoardFinderOptions())
+		test.That(t, err, test.ShouldBeNil)
+		full = min(full, time.Since(start))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		start = time.Now()
+		_, err = FindBoardCtx(ctx, input, DefaultBoardFinderOptions())
+		best = min(best, time.Since(start))
+		cancel()
+		test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+	}
+	t.Logf("canceled after %v, %v uncanceled", best, full)
+	test.That(t, best, test.ShouldBeLessThan, full)
+}
+
+func TestVoteThreshold(t *testing.T) {
+	opts := DefaultBoardFinderOptions()
+	test.That(t, opts.voteThreshold(1280, 720), test.ShouldEqual, 100)
+	test.That(t, opts.voteThreshold(320, 240), test.ShouldEqual, 33)
+	test.That(t, opts.voteThreshold(3840, 2160), test.ShouldEqual, 302)
+
+	opts.VoteThreshold = 70
+	test.That(t, opts.voteThreshold(3840, 2160), test.ShouldEqual, 70)
+}
+
+func TestMaxLinesPerOrientation(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board5.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	opts := DefaultBoardFinderOptions()
+	opts.MaxLinesPerOrientation = 0
+	_, all, err := FindBoardDebugWithOptions(input, opts)
+	test.That(t, err, test.ShouldBeNil)
+
+	opts.MaxLinesPerOrientation = 5
+	_, capped, err := FindBoardDebugWithOptions(input, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(capped.CandidateLines), test.ShouldBeLessThanOrEqualTo, 10)
+	test.That(t, len(capped.CandidateLines), test.ShouldBeLessThan, len(all.CandidateLines))
+}
+
+// houghTestInput is board2, the most skewed board, ready for houghLineDetection.
+func houghTestInput(t testing.TB) (*sobelResult, int, int, BoardFinderOptions) {
+	input, err := rimage.ReadImageFromFile("data/board2.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	gray := makeGrayImage(input)
+	defer gray.release()
+	opts := DefaultBoardFinderOptions()
+	return sobelEdgeDetection(gray), gray.Width, gray.Height, opts
+}
+
+func TestHoughThetaWindows(t *testing.T) {
+	sobel, width, height, opts := houghTestInput(t)
+	defer sobel.release()
+	vt := opts.voteThreshold(width, height)
+
+	inTolerance := func(lines []Line) []Line {
+		res := []Line{}
+		for _, l := range lines {
+			deg := l.theta * 180 / math.Pi
+			if deg < opts.AngleTolerance || deg > 180-opts.AngleTolerance ||
+				(deg > 90-opts.AngleTolerance && deg < 90+opts.AngleTolerance) {
+				res = append(res, l)
+			}
+		}
+		return res
+	}
+
+	all, err := houghLineDetection(context.Background(), sobel, width, height, opts.EdgeThreshold, vt, nil)
+	test.That(t, err, test.ShouldBeNil)
+	windowed, err := houghLineDetection(context.Background(), sobel, width, height, opts.EdgeThreshold, vt, axisWindows(opts.AngleTolerance))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, inTolerance(windowed), test.ShouldResemble, inTolerance(all))
+}
+
+// denseHoughLines is houghLineDetection counting every vote in a single full resolution
+// accumulator, as it did before the coarse pass, for checking the two agree.
+func denseHoughLines(sobel *sobelResult, width, height int, edgeThreshold, voteThreshold int, windows []thetaWindow) ([]Line, int) {
+	edges := sobel.magnitude
+	maxRho := int(math.Sqrt(float64(width*width + height*height)))
+	numThetas := 720
+	// each pixel votes this many bins either side of its gradient, and peaks are checked
+	// against this many either side
+	const voteSpread, peakSpread = 5, 3
+
+	peakBins := []int{}
+	for t, in := range inThetaWindows(windows, numThetas, 0) {
+		if in {
+			peakBins = append(peakBins, t)
+		}
+	}
+	voteBins := inThetaWindows(windows, numThetas, voteSpread+peakSpread)
+
+	// slots[t] is where bin t lives in each accumulator row, -1 if nothing votes for it
+	slots := make([]int, numThetas)
+	numSlots := 0
+	for t := range numThetas {
+		slots[t] = -1
+		if voteBins[t] {
+			slots[t] = numSlots
+			numSlots++
+		}
+	}
+
+	// accumulator[rhoIdx*numSlots+slots[t]]
+	accumulator := make([]int32, (2*maxRho+1)*numSlots)
+
+	cosTheta := make([]float64, numThetas)
+	sinTheta := make([]float64, numThetas)
+	for t := range numThetas {
+		theta := float64(t) * math.Pi / float64(numThetas)
+		cosTheta[t] = math.Cos(theta)
+		sinTheta[t] = math.Sin(theta)
+	}
+
+	for y := range height {
+		for x := range width {
+			i := edges.offset(x, y)
+			if int(edges.Pix[i]) < edgeThreshold {
+				continue
+			}
+
+			gx := float64(sobel.gx[i])
+			gy := float64(sobel.gy[i])
+
+			gradAngle := math.Atan2(gy, gx)
+			if gradAngle < 0 {
+				gradAngle += math.Pi
+			}
+			tCenter := int(gradAngle * float64(numThetas) / math.Pi)
+			if tCenter >= numThetas {
+				tCenter = 0
+			}
+			if !voteBins[tCenter] {
+				continue
+			}
+
+			for dt := -voteSpread; dt <= voteSpread; dt++ {
+				t := (tCenter + dt + numThetas) % numThetas
+				if slots[t] < 0 {
+					continue
+				}
+				rho := float64(x)*cosTheta[t] + float64(y)*sinTheta[t]
+				rhoIdx := int(rho) + maxRho
+				if rhoIdx >= 0 && rhoIdx < 2*maxRho+1 {
+					accumulator[rhoIdx*numSlots+slots[t]]++
+				}
+			}
+		}
+	}
+
+	var lines []Line
+
+	for rhoIdx := range 2*maxRho + 1 {
+		for _, t := range peakBins {
+			v := accumulator[rhoIdx*numSlots+slots[t]]
+			if int(v) < voteThreshold {
+				continue
+			}
+
+			isMax := true
+			for dr := -2; dr <= 2 && isMax; dr++ {
+				for dt := -peakSpread; dt <= peakSpread && isMax; dt++ {
+					if dr == 0 && dt == 0 {
+						continue
+					}
+					nRho := rhoIdx + dr
+					nT := (t + dt + numThetas) % numThetas
+					if nRho >= 0 && nRho < 2*maxRho+1 {
+						if accumulator[nRho*numSlots+slots[nT]] > v {
+							isMax = false
+						}
+					}
+				}
+			}
+
+			if isMax {
+				rho := float64(rhoIdx - maxRho)
+				theta := float64(t) * math.Pi / float64(numThetas)
+				lines = append(lines, Line{rho: rho, theta: theta, votes: int(v)})
+			}
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].votes > lines[j].votes
+	})
+
+	return lines, 4 * len(accumulator)
+}
+
+func TestHoughMatchesDense(t *testing.T) {
+	opts := DefaultBoardFinderOptions()
+	for i := 1; i <= 13; i++ {
+		input, err := rimage.ReadImageFromFile(fmt.Sprintf("data/board%d.jpg", i))
+		test.That(t, err, test.ShouldBeNil)
+		for _, f := range []float64{1, .5} {
+			gray := downscaleGray(input, f)
+			sobel := sobelEdgeDetection(gray)
+			vt := opts.voteThreshold(gray.Width, gray.Height)
+			for _, windows := range [][]thetaWindow{nil, axisWindows(opts.AngleTolerance)} {
+				lines, err := houghLineDetection(context.Background(), sobel, gray.Width, gray.Height, opts.EdgeThreshold, vt, windows)
+				test.That(t, err, test.ShouldBeNil)
+				dense, _ := denseHoughLines(sobel, gray.Width, gray.Height, opts.EdgeThreshold, vt, windows)
+				test.That(t, lines, test.ShouldResemble, dense)
+			}
+			sobel.release()
+			gray.release()
+		}
+	}
+}
+
+func BenchmarkHoughLineDetection(b *testing.B) {
+	sobel, width, height, opts := houghTestInput(b)
+	defer sobel.release()
+
+	// board2 blown up to 1080p, where the dense accumulator was a few MB
+	input, err := rimage.ReadImageFromFile("data/board2.jpg")
+	test.That(b, err, test.ShouldBeNil)
+	big := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+	xdraw.ApproxBiLinear.Scale(big, big.Bounds(), input, input.Bounds(), draw.Src, nil)
+	gray := makeGrayImage(big)
+	bigSobel := sobelEdgeDetection(gray)
+	gray.release()
+	defer bigSobel.release()
+
+	type frame struct {
+		sobel         *sobelResult
+		width, height int
+	}
+	for _, size := range []struct {
+		name string
+		frame
+	}{{"720p", frame{sobel, width, height}}, {"1080p", frame{bigSobel, 1920, 1080}}} {
+		vt := opts.voteThreshold(size.width, size.height)
+		for name, windows := range map[string][]thetaWindow{"all": nil, "windowed": axisWindows(opts.AngleTolerance)} {
+			b.Run(size.name+"/"+name, func(b *testing.B) {
+				b.ReportAllocs()
+				bufs := &houghBuffers{}
+				for range b.N {
+					houghLines(context.Background(), bufs, size.sobel, size.width, size.height, opts.EdgeThreshold, vt, windows)
+				}
+				b.ReportMetric(float64(bufs.bytes()), "accumulator-B")
+			})
+			b.Run(size.name+"/"+name+"/dense", func(b *testing.B) {
+				b.ReportAllocs()
+				bytes := 0
+				for range b.N {
+					_, bytes = denseHoughLines(size.sobel, size.width, size.height, opts.EdgeThreshold, vt, windows)
+				}
+				b.ReportMetric(float64(bytes), "accumulator-B")
+			})
+		}
+	}
+}
+
+// crop is the r part of src, moved to the origin.
+func crop(src image.Image, r image.Rectangle) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+	return dst
+}
+
+func TestFindBoardClipped(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	b := input.Bounds()
+
+	// camera too close: the bottom left corner is 38 pixels left of the frame and the left
+	// border line is almost all out of it
+	off := image.Pt(385, 0)
+	clipped := crop(input, image.Rectangle{Min: off, Max: b.Max})
+	expected := []image.Point{}
+	for _, p := range []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}} {
+		expected = append(expected, p.Sub(off))
+	}
+
+	corners, err := findBoard(clipped)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("clipped: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, corners[3].X, test.ShouldBeLessThan, 0)
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 5)
+
+	sub, err := findBoardSubPixel(clipped, DefaultBoardFinderOptions())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, checkerScore(clipped, sub, 8), test.ShouldBeGreaterThan, .9)
+
+	// further out than max-corner-outside lets it be
+	off = image.Pt(440, 0)
+	clipped = crop(input, image.Rectangle{Min: off, Max: b.Max})
+	_, err = findBoard(clipped)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "out of the frame")
+
+	opts := DefaultBoardFinderOptions()
+	opts.MaxCornerOutside = .15
+	corners, err = findBoardWithOptions(clipped, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, corners[3].X, test.ShouldBeLessThan, -80)
+}
+
+func TestSobelImageBorder(t *testing.T) {
+	// dark left, light right, the boundary running off the top and bottom of the image
+	gray := getGrayPlane(20, 10)
+	defer gray.release()
+	for y := range gray.Height {
+		for x := 10; x < gray.Width; x++ {
+			gray.Set(x, y, 200)
+		}
+	}
+	sobel := sobelEdgeDetection(gray)
+	defer sobel.release()
+
+	// the outermost rows see the boundary like every other row
+	for _, y := range []int{0, 5, 9} {
+		test.That(t, sobel.magnitude.At(10, y), test.ShouldEqual, 255)
+		test.That(t, sobel.gx[gray.offset(10, y)], test.ShouldEqual, 800)
+	}
+	// and the edge of the image isn't an edge
+	for _, p := range []image.Point{{0, 0}, {0, 5}, {19, 5}, {19, 9}, {5, 0}} {
+		test.That(t, sobel.magnitude.At(p.X, p.Y), test.ShouldEqual, 0)
+	}
+}
+
+func TestFindBoardTouchingTop(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	// cropped so the top border runs along the first row and off the frame at the right, the
+	// only edge pixels left on it are in the outermost ring
+	off := image.Pt(0, 5)
+	clipped := crop(input, image.Rectangle{Min: off, Max: input.Bounds().Max})
+	expected := []image.Point{}
+	for _, p := range []image.Point{{275, 7}, {952, 2}, {969, 683}, {271, 697}} {
+		expected = append(expected, p.Sub(off))
+	}
+
+	corners, err := findBoard(clipped)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("touching the top: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 3)
 }
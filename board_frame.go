@@ -0,0 +1,104 @@
+package viamchess
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// defaultSquareSize is how big (mm) a tournament board's squares are.
+const defaultSquareSize = 57.0
+
+// boardFrame is where the board camera's point cloud is, for the frame command.
+var boardFrame = map[string]interface{}{
+	"origin": "the outside corner of a1",
+	"x":      "along the first rank toward h",
+	"y":      "up the a file toward the eighth rank",
+	"z":      "up from the board",
+	"units":  "mm",
+}
+
+func validateSquareSize(size float64) error {
+	if size < 0 {
+		return fmt.Errorf("square-size can't be negative, got %v", size)
+	}
+	return nil
+}
+
+// boardCoords is where g, a point on the n x n board in the frame's squares with the top left
+// corner at 0, 0 (see computeHomography), is in files and ranks from a1's outside corner with
+// white at rot: the continuous gridPosition turned around.
+func boardCoords(g r2.Point, rot BoardRotation, n int) r2.Point {
+	side := float64(n)
+	switch rot {
+	case Rotation90:
+		return r2.Point{X: side - g.Y, Y: side - g.X}
+	case Rotation180:
+		return r2.Point{X: g.X, Y: side - g.Y}
+	case Rotation270:
+		return r2.Point{X: g.Y, Y: g.X}
+	default:
+		return r2.Point{X: side - g.X, Y: g.Y}
+	}
+}
+
+// boardFrameCloud is the points of pc, from the camera props are for, that are in the image on
+// the n x n board with corners, with white at rot, in the board's own frame (see boardFrame):
+// X and Y where they are over it in squares of squareSize mm, and Z how far above the table
+// plane under them.
+func boardFrameCloud(pc pointcloud.PointCloud, corners []r2.Point, rot BoardRotation, n int, props camera.Properties, squareSize float64) (pointcloud.PointCloud, error) {
+	ip := props.IntrinsicParams
+	if ip == nil || ip.Fx == 0 || ip.Fy == 0 {
+		return nil, errors.New("need camera intrinsics to put the point cloud on the board")
+	}
+	toBoard, err := computeHomography(corners, float64(n))
+	if err != nil {
+		return nil, err
+	}
+
+	// what's over the board, where it is on it
+	side := float64(n)
+	on := pointcloud.NewBasicEmpty()
+	var points []r3.Vector
+	var data []pointcloud.Data
+	var coords []r2.Point
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z <= 0 {
+			return true
+		}
+		g := toBoard.Apply(r2.Point{X: p.X/p.Z*ip.Fx + ip.Ppx, Y: p.Y/p.Z*ip.Fy + ip.Ppy})
+		if g.X < 0 || g.Y < 0 || g.X >= side || g.Y >= side {
+			return true
+		}
+		points, data = append(points, p), append(data, d)
+		coords = append(coords, boardCoords(g, rot, n))
+		err = on.Set(p, d)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if on.Size() < 3 {
+		return nil, errNoPlaneBoard
+	}
+
+	// and most of that is the board
+	plane, err := fitTablePlane(on)
+	if err != nil {
+		return nil, err
+	}
+	out := pointcloud.NewBasicEmpty()
+	for i, p := range points {
+		c := coords[i]
+		err = out.Set(r3.Vector{X: c.X * squareSize, Y: c.Y * squareSize, Z: plane.distance(p)}, data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,25 @@
+package viamchess
+
+import (
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/test"
+)
+
+func TestBoardCoords(t *testing.T) {
+	// the middle of every square is in the middle of the same square on the board, whichever way it's turned
+	for _, rot := range []BoardRotation{Rotation0, Rotation90, Rotation180, Rotation270} {
+		for rank := 1; rank <= 8; rank++ {
+			for file := 'a'; file <= 'h'; file++ {
+				col, row := gridPosition(file, rank, rot, 8)
+				c := boardCoords(r2.Point{X: float64(col) + .5, Y: float64(row) + .5}, rot, 8)
+				test.That(t, c, test.ShouldResemble, r2.Point{X: float64(file-'a') + .5, Y: float64(rank-1) + .5})
+			}
+		}
+	}
+
+	test.That(t, validateSquareSize(0), test.ShouldBeNil)
+	test.That(t, validateSquareSize(-1), test.ShouldNotBeNil)
+}
@@ -0,0 +1,149 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// glareLevel is how bright (0-255) all three channels have to be for a pixel to be glare
+// rather than a light square.
+const glareLevel = 250
+
+// suppressGlare paints over every 4-connected blob of at least minArea saturated pixels with
+// what's around it, so an overhead light reflected in a glossy board doesn't make edges of its
+// own. Each pixel of a blob gets the per channel median of the nearest unsaturated pixel left,
+// right, above and below it, which keeps a grid line running under the blob straight.
+// img itself is returned, without copying, when there are no such blobs.
+func suppressGlare(img image.Image, minArea int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	saturated := make([]bool, width*height)
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			saturatedRow(img, bounds.Min.X, bounds.Min.Y+y, saturated[y*width:(y+1)*width])
+		}
+	})
+	glare := glareBlobs(saturated, width, height, minArea)
+	if glare == nil {
+		return img
+	}
+
+	res := image.NewRGBA(bounds)
+	draw.Draw(res, bounds, img, bounds.Min, draw.Src)
+
+	// the fill is worked out from res before any of it is painted, so nothing is filled from a
+	// pixel that was glare itself
+	fill := make([][3]uint8, width*height)
+	parallelRows(height, func(start, end int) {
+		var around [4][3]uint8
+		for y := start; y < end; y++ {
+			for x := range width {
+				if !glare[y*width+x] {
+					continue
+				}
+				n := 0
+				for _, d := range [4]image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+					p := image.Point{x, y}.Add(d)
+					for p.X >= 0 && p.X < width && p.Y >= 0 && p.Y < height && glare[p.Y*width+p.X] {
+						p = p.Add(d)
+					}
+					if p.X < 0 || p.X >= width || p.Y < 0 || p.Y >= height {
+						continue
+					}
+					i := res.PixOffset(bounds.Min.X+p.X, bounds.Min.Y+p.Y)
+					around[n] = [3]uint8{res.Pix[i], res.Pix[i+1], res.Pix[i+2]}
+					n++
+				}
+				fill[y*width+x] = medianColor(around[:n])
+			}
+		}
+	})
+
+	for i, g := range glare {
+		if g {
+			p := res.PixOffset(bounds.Min.X+i%width, bounds.Min.Y+i/width)
+			copy(res.Pix[p:p+3], fill[i][:])
+		}
+	}
+	return res
+}
+
+// saturatedRow sets dst[x] when all three channels of pixel (x0+x, y) are over glareLevel,
+// reading *image.RGBA and *image.YCbCr straight out of Pix like grayRow.
+func saturatedRow(img image.Image, x0, y int, dst []bool) {
+	switch im := img.(type) {
+	case *image.RGBA:
+		i := im.PixOffset(x0, y)
+		for x := range dst {
+			p := im.Pix[i+4*x : i+4*x+3 : i+4*x+3]
+			dst[x] = p[0] > glareLevel && p[1] > glareLevel && p[2] > glareLevel
+		}
+	case *image.YCbCr:
+		for x := range dst {
+			// luma is a weighted average of the channels, so it's over too when they all are
+			yi := im.YOffset(x0+x, y)
+			if im.Y[yi] <= glareLevel {
+				dst[x] = false
+				continue
+			}
+			ci := im.COffset(x0+x, y)
+			r, g, b := color.YCbCrToRGB(im.Y[yi], im.Cb[ci], im.Cr[ci])
+			dst[x] = r > glareLevel && g > glareLevel && b > glareLevel
+		}
+	default:
+		for x := range dst {
+			r, g, b, _ := img.At(x0+x, y).RGBA()
+			dst[x] = r>>8 > glareLevel && g>>8 > glareLevel && b>>8 > glareLevel
+		}
+	}
+}
+
+// glareBlobs is saturated with only the blobs of at least minArea pixels left, nil if there
+// aren't any. Holes smaller than minArea inside a blob, like the dark edge of a piece sitting in
+// the reflection, are part of it too, so they're painted over with the rest of the blob instead
+// of left as islands with an edge all the way round.
+func glareBlobs(saturated []bool, width, height, minArea int) []bool {
+	labels, sizes := labelComponents(saturated, width, height)
+	var glare []bool
+	for i, l := range labels {
+		if l == 0 || sizes[l] < minArea {
+			continue
+		}
+		if glare == nil {
+			glare = make([]bool, len(saturated))
+		}
+		glare[i] = true
+	}
+	if glare != nil {
+		fillHoles(glare, width, height, minArea)
+	}
+	return glare
+}
+
+// medianColor is the per channel median of colors, black if there are none.
+func medianColor(colors [][3]uint8) [3]uint8 {
+	var res [3]uint8
+	if len(colors) == 0 {
+		return res
+	}
+	var vals [4]uint8
+	for c := range 3 {
+		v := vals[:0]
+		for _, col := range colors {
+			v = append(v, col[c])
+		}
+		for i := 1; i < len(v); i++ {
+			for j := i; j > 0 && v[j] < v[j-1]; j-- {
+				v[j], v[j-1] = v[j-1], v[j]
+			}
+		}
+		if len(v)%2 == 1 {
+			res[c] = v[len(v)/2]
+		} else {
+			res[c] = uint8((int(v[len(v)/2-1]) + int(v[len(v)/2])) / 2)
+		}
+	}
+	return res
+}
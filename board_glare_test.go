@@ -0,0 +1,74 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// withGlare is img with a saturated white ellipse centered on c, like an overhead light
+// reflected in a glossy board.
+func withGlare(img image.Image, c image.Point, rx, ry int) *image.RGBA {
+	res := image.NewRGBA(img.Bounds())
+	draw.Draw(res, res.Bounds(), img, img.Bounds().Min, draw.Src)
+	for y := -ry; y <= ry; y++ {
+		for x := -rx; x <= rx; x++ {
+			if x*x*ry*ry+y*y*rx*rx <= rx*rx*ry*ry {
+				res.Set(c.X+x, c.Y+y, color.RGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return res
+}
+
+func TestSuppressGlare(t *testing.T) {
+	// light left half, dark right half
+	img := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{200, 190, 180, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(50, 0, 100, 60), image.NewUniform(color.RGBA{40, 90, 40, 255}), image.Point{}, draw.Src)
+
+	// nothing saturated, same image back
+	test.That(t, suppressGlare(img, 300), test.ShouldEqual, img)
+
+	// too small to be glare
+	small := withGlare(img, image.Pt(20, 20), 3, 3)
+	test.That(t, suppressGlare(small, 300), test.ShouldEqual, small)
+
+	glared := withGlare(img, image.Pt(50, 30), 20, 12)
+	res := suppressGlare(glared, 300).(*image.RGBA)
+	test.That(t, res, test.ShouldNotEqual, glared)
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 100; x++ {
+			r, _, _, _ := res.At(x, y).RGBA()
+			test.That(t, r>>8, test.ShouldBeLessThanOrEqualTo, glareLevel)
+		}
+	}
+	// the boundary between the halves runs straight through where the blob was
+	test.That(t, res.RGBAAt(45, 30), test.ShouldResemble, color.RGBA{200, 190, 180, 255})
+	test.That(t, res.RGBAAt(55, 30), test.ShouldResemble, color.RGBA{40, 90, 40, 255})
+	test.That(t, res.RGBAAt(20, 20), test.ShouldResemble, color.RGBA{200, 190, 180, 255})
+}
+
+func TestFindBoardGlare(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	// a reflection across the top border, over the squares either side of it
+	glared := withGlare(input, image.Pt(620, 66), 110, 60)
+
+	opts := DefaultBoardFinderOptions()
+	opts.GlareMinArea = 0
+	corners, err := findBoardWithOptions(glared, opts)
+	t.Logf("without suppression: %v (%.1f) %v", corners, maxCornerError(corners, expected), err)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	corners, err = findBoard(glared)
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("with suppression: %v (%.1f)", corners, maxCornerError(corners, expected))
+	test.That(t, maxCornerError(corners, expected), test.ShouldBeLessThan, 5)
+}
@@ -0,0 +1,260 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+const (
+	// cellSamples is how many points per side are averaged for each square's brightness.
+	// They're spread over the middle 70% of the square so the grid lines don't count.
+	cellSamples = 6
+
+	// minAlternatingLines is how many of every 4 ranks, and of every 4 files, have to alternate
+	// light and dark for the quad to be a board, 6 of the 8 on a chess board.
+	minAlternatingLines = 3
+)
+
+// squareBrightness is the average gray of each of the n x n squares in the quad corners (TL, TR,
+// BR, BL), indexed [row][col] from the TL corner. Only the part of the board in the frame is
+// sampled, seen is false for the squares entirely out of it.
+func squareBrightness(img image.Image, corners []r2.Point, n int) (cells [][]float64, seen [][]bool) {
+	bounds := img.Bounds()
+	cells, seen = make([][]float64, n), make([][]bool, n)
+	for row := range n {
+		cells[row], seen[row] = make([]float64, n), make([]bool, n)
+	}
+
+	// one unit per square, so a sample's board position is just col+u, row+v
+	h, err := computeHomography(corners, float64(n))
+	if err != nil {
+		return cells, seen
+	}
+	toSource, err := h.Inverse()
+	if err != nil {
+		return cells, seen
+	}
+
+	var px [1]uint8
+	for row := range n {
+		for col := range n {
+			sum, count := 0, 0
+			for i := range cellSamples {
+				for j := range cellSamples {
+					p := toSource.Apply(r2.Point{
+						X: float64(col) + .15 + .7*float64(i)/(cellSamples-1),
+						Y: float64(row) + .15 + .7*float64(j)/(cellSamples-1),
+					})
+					pt := image.Point{X: int(math.Floor(p.X)), Y: int(math.Floor(p.Y))}.Add(bounds.Min)
+					if !pt.In(bounds) {
+						continue
+					}
+					grayRow(img, pt.X, pt.Y, px[:])
+					sum += int(px[0])
+					count++
+				}
+			}
+			if count > 0 {
+				cells[row][col] = float64(sum) / float64(count)
+				seen[row][col] = true
+			}
+		}
+	}
+	return cells, seen
+}
+
+// parity is 1 for the squares the same color as the TL one, -1 for the others.
+func parity(row, col int) float64 {
+	if (row+col)%2 == 0 {
+		return 1
+	}
+	return -1
+}
+
+// boardOccluded is true when less than minFraction of the quad looks like a checkerboard.
+// Pieces don't change a square's brightness enough to matter, a hand or the arm does.
+func boardOccluded(img image.Image, corners []r2.Point, n int, minFraction float64) bool {
+	return checkerScore(img, corners, n) < minFraction
+}
+
+// checkerScore is how much (0-1) of the quad, an n x n board, looks like a checkerboard: the
+// largest connected patch of squares that contrast with their neighbors the right way, as a
+// fraction of the squares in the frame. It's 0 if fewer than minAlternatingLines in 4 ranks or
+// files alternate light and dark at all.
+func checkerScore(img image.Image, corners []r2.Point, n int) float64 {
+	cells, seen := squareBrightness(img, corners, n)
+
+	// contrast is positive when the TL square is the light one
+	contrast, visible := 0.0, 0
+	for row := range n {
+		for col := range n {
+			if seen[row][col] {
+				contrast += parity(row, col) * cells[row][col]
+				visible++
+			}
+		}
+	}
+	if contrast == 0 {
+		return 0
+	}
+	contrast /= float64(visible)
+
+	rows, cols := 0, 0
+	for i := range n {
+		r, c := 0.0, 0.0
+		rn, cn := 0, 0
+		for j := range n {
+			if seen[i][j] {
+				r += parity(i, j) * cells[i][j]
+				rn++
+			}
+			if seen[j][i] {
+				c += parity(j, i) * cells[j][i]
+				cn++
+			}
+		}
+		if rn > 0 && r/float64(rn)/contrast > .3 {
+			rows++
+		}
+		if cn > 0 && c/float64(cn)/contrast > .3 {
+			cols++
+		}
+	}
+	if minLines := n * minAlternatingLines / 4; rows < minLines || cols < minLines {
+		return 0
+	}
+
+	good := make([][]bool, n)
+	for row := range n {
+		good[row] = make([]bool, n)
+		for col := range n {
+			if !seen[row][col] {
+				continue
+			}
+			sum, count := 0.0, 0.0
+			for _, d := range neighbors4 {
+				r, c := row+d.Y, col+d.X
+				if r >= 0 && r < n && c >= 0 && c < n && seen[r][c] {
+					sum += cells[r][c]
+					count++
+				}
+			}
+			good[row][col] = count > 0 && parity(row, col)*(cells[row][col]-sum/count)/contrast > .5
+		}
+	}
+
+	return float64(largestComponent(good)) / float64(visible)
+}
+
+var neighbors4 = []image.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+// largestComponent is the size of the biggest 4-connected patch of true squares, cells being
+// square.
+func largestComponent(cells [][]bool) int {
+	n := len(cells)
+	seen := make([][]bool, n)
+	for row := range n {
+		seen[row] = make([]bool, n)
+	}
+	best := 0
+	for row := range n {
+		for col := range n {
+			if !cells[row][col] || seen[row][col] {
+				continue
+			}
+			seen[row][col] = true
+			stack := []image.Point{{col, row}}
+			size := 0
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				size++
+				for _, d := range neighbors4 {
+					q := p.Add(d)
+					if q.X >= 0 && q.X < n && q.Y >= 0 && q.Y < n && cells[q.Y][q.X] && !seen[q.Y][q.X] {
+						seen[q.Y][q.X] = true
+						stack = append(stack, q)
+					}
+				}
+			}
+			best = max(best, size)
+		}
+	}
+	return best
+}
+
+// clippedEdgeError errors if a border of the quad corners, an n x n board, is out of the frame
+// but the squares along it that are in the frame don't alternate light and dark. Then the grid
+// was extended over the table, not a board cut off by the frame.
+func clippedEdgeError(img image.Image, corners []r2.Point, n int) error {
+	b := img.Bounds()
+	frame := r2.RectFromPoints(r2.Point{}, r2.Point{X: float64(b.Dx()), Y: float64(b.Dy())})
+	cells, seen := squareBrightness(img, corners, n)
+
+	contrast, visible := 0.0, 0
+	for row := range n {
+		for col := range n {
+			if seen[row][col] {
+				contrast += parity(row, col) * cells[row][col]
+				visible++
+			}
+		}
+	}
+	if visible == 0 || contrast == 0 {
+		return nil
+	}
+	contrast /= float64(visible)
+
+	// the squares along each border, TL-TR, TR-BR, BR-BL, BL-TL
+	along := func(edge, i int) (int, int) {
+		switch edge {
+		case 0:
+			return 0, i
+		case 1:
+			return i, n - 1
+		case 2:
+			return n - 1, i
+		}
+		return i, 0
+	}
+
+	for edge := range 4 {
+		a, c := corners[edge], corners[(edge+1)%4]
+		if frame.ContainsPoint(a) && frame.ContainsPoint(c) {
+			continue
+		}
+
+		good, count := 0, 0
+		for i := range n {
+			row, col := along(edge, i)
+			if !seen[row][col] {
+				continue
+			}
+			sum, m := 0.0, 0.0
+			for _, j := range []int{i - 1, i + 1} {
+				if j < 0 || j >= n {
+					continue
+				}
+				r, cl := along(edge, j)
+				if seen[r][cl] {
+					sum += cells[r][cl]
+					m++
+				}
+			}
+			if m == 0 {
+				continue
+			}
+			count++
+			if parity(row, col)*(cells[row][col]-sum/m)/contrast > .5 {
+				good++
+			}
+		}
+		if count >= 2 && good*2 < count {
+			return fmt.Errorf("corners %v go out of the frame, but only %d of the %d squares along that edge look like a board", corners, good, count)
+		}
+	}
+	return nil
+}
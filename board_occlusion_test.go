@@ -0,0 +1,141 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// occludeHalf is img with a dark rectangle, like an arm, over the left half of the board in corners.
+func occludeHalf(img image.Image, corners []image.Point) *image.RGBA {
+	res := image.NewRGBA(img.Bounds())
+	draw.Draw(res, res.Bounds(), img, img.Bounds().Min, draw.Src)
+	r := image.Rect(corners[0].X-20, corners[0].Y-20, (corners[0].X+corners[1].X)/2, corners[2].Y+20)
+	draw.Draw(res, r, image.NewUniform(color.RGBA{30, 25, 20, 255}), image.Point{}, draw.Src)
+	return res
+}
+
+func TestFindBoardOccluded(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	opts := DefaultBoardFinderOptions()
+
+	res := FindBoardEx(input, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	test.That(t, res.Reason, test.ShouldEqual, "")
+
+	occluded := occludeHalf(input, res.Corners)
+	res = FindBoardEx(occluded, opts)
+	test.That(t, res.Found, test.ShouldBeFalse)
+	test.That(t, res.Reason, test.ShouldEqual, NotFoundOccluded)
+
+	// without the checks it happily returns whatever it found
+	opts.MinBoardFraction = 0
+	opts.MaxCornerOutside = 0
+	res = FindBoardEx(occluded, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+}
+
+func TestFindBoardNotFoundReason(t *testing.T) {
+	blank := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	res := FindBoardEx(blank, DefaultBoardFinderOptions())
+	test.That(t, res.Found, test.ShouldBeFalse)
+	test.That(t, res.Reason, test.ShouldEqual, NotFoundNoLines)
+}
+
+func TestBoardOccludedFixtures(t *testing.T) {
+	// pieces aren't occlusions
+	for _, fn := range []string{"data/board1.jpg", "data/board4.jpg", "data/board13.jpg"} {
+		input, err := rimage.ReadImageFromFile(fn)
+		test.That(t, err, test.ShouldBeNil)
+		res, _, err := FindBoardDebug(input)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Found, test.ShouldBeTrue)
+		test.That(t, boardOccluded(input, res.SubPixelCorners, 8, 1), test.ShouldBeFalse)
+	}
+}
+
+func TestCaptureOccludedServesLast(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	first := ret.Objects
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	frame = occludeHalf(input, corners)
+
+	ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["occluded"], test.ShouldBeTrue)
+	test.That(t, ret.Extra["age"], test.ShouldEqual, 1)
+	test.That(t, ret.Image, test.ShouldEqual, image.Image(input))
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	for i := range first {
+		test.That(t, ret.Objects[i], test.ShouldEqual, first[i])
+	}
+
+	// only for max-age captures, then it's too old to act on
+	pf = newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 2}, &frame, &pc)
+	frame = input
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	frame = occludeHalf(input, corners)
+	for age := 1; age <= 2; age++ {
+		ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ret.Extra["age"], test.ShouldEqual, age)
+	}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, errors.Is(err, errBoardOccluded), test.ShouldBeTrue)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, errors.Is(err, errBoardOccluded), test.ShouldBeTrue)
+
+	// with nothing to fall back on it's an error
+	pf = newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldEqual, errBoardOccluded)
+}
+
+func TestClippedEdgeError(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []r2.Point{{X: 390, Y: 48}, {X: 965, Y: 85}, {X: 939, Y: 665}, {X: 347, Y: 635}}
+	test.That(t, clippedEdgeError(input, corners, 8), test.ShouldBeNil)
+
+	// a rank lower runs the bottom row over the table past the frame
+	shifted := []r2.Point{}
+	for _, p := range corners {
+		shifted = append(shifted, p.Add(r2.Point{Y: 73}))
+	}
+	err = clippedEdgeError(input, shifted, 8)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "out of the frame")
+
+	// cut off at the left it's still a board
+	clipped := crop(input, image.Rect(385, 0, 1280, 720))
+	for i := range corners {
+		corners[i] = corners[i].Sub(r2.Point{X: 385})
+	}
+	test.That(t, clippedEdgeError(clipped, corners, 8), test.ShouldBeNil)
+}
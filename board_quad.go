@@ -0,0 +1,85 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// ValidateCornerQuad errors if corners can't be a board in a width x height image,
+// using the default thresholds.
+func ValidateCornerQuad(corners BoardCorners, width, height int) error {
+	return validateCornerQuad(corners, width, height, DefaultBoardFinderOptions())
+}
+
+// validateCornerQuad checks that corners are a convex quad covering at least MinQuadArea of
+// the image, with no edge more than MaxEdgeRatio times another and every corner within
+// CornerAngleTolerance of square. A 0 threshold skips its check.
+func validateCornerQuad(corners BoardCorners, width, height int, opts BoardFinderOptions) error {
+	pts := corners.Slice()
+
+	for i := range pts {
+		a, b, c := pts[i], pts[(i+1)%4], pts[(i+2)%4]
+		if cross(b.Sub(a), c.Sub(b)) <= 0 {
+			return fmt.Errorf("corners %v aren't a convex quad, the edges cross or bend back at %v", pts, b)
+		}
+	}
+
+	if opts.MinQuadArea > 0 {
+		area := 0
+		for i := range pts {
+			area += cross(pts[i], pts[(i+1)%4])
+		}
+		frac := float64(area) / 2 / float64(width*height)
+		if frac < opts.MinQuadArea {
+			return fmt.Errorf("corners %v only cover %.1f%% of the image, need %.1f%%", pts, frac*100, opts.MinQuadArea*100)
+		}
+	}
+
+	if opts.MaxEdgeRatio > 0 {
+		shortest, longest := math.Inf(1), 0.0
+		for i := range pts {
+			l := edgeLength(pts[i], pts[(i+1)%4])
+			shortest = math.Min(shortest, l)
+			longest = math.Max(longest, l)
+		}
+		if longest > shortest*opts.MaxEdgeRatio {
+			return fmt.Errorf("corners %v have a %.0f pixel edge and a %.0f one, more than %v times apart", pts, longest, shortest, opts.MaxEdgeRatio)
+		}
+	}
+
+	if limit := opts.maxOutside(width, height); limit > 0 {
+		frame := r2.RectFromPoints(r2.Point{}, r2.Point{X: float64(width), Y: float64(height)})
+		for _, p := range pts {
+			q := r2.Point{X: float64(p.X), Y: float64(p.Y)}
+			if d := q.Sub(frame.ClampPoint(q)).Norm(); d > limit {
+				return fmt.Errorf("corner %v is %.0f pixels out of the frame, more than %.0f", p, d, limit)
+			}
+		}
+	}
+
+	if opts.CornerAngleTolerance > 0 {
+		for i := range pts {
+			prev, p, next := pts[(i+3)%4], pts[i], pts[(i+1)%4]
+			u, v := prev.Sub(p), next.Sub(p)
+			cos := float64(u.X*v.X+u.Y*v.Y) / edgeLength(prev, p) / edgeLength(next, p)
+			angle := math.Acos(math.Max(-1, math.Min(1, cos))) * 180 / math.Pi
+			if math.Abs(angle-90) > opts.CornerAngleTolerance {
+				return fmt.Errorf("corner %v is %.0f degrees, more than %v off square", p, angle, opts.CornerAngleTolerance)
+			}
+		}
+	}
+
+	return nil
+}
+
+// cross is the z of the cross product of a and b, positive when b turns clockwise from a with y down.
+func cross(a, b image.Point) int {
+	return a.X*b.Y - a.Y*b.X
+}
+
+func edgeLength(a, b image.Point) float64 {
+	return math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+}
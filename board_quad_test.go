@@ -0,0 +1,61 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestValidateCornerQuad(t *testing.T) {
+	quad := func(tl, tr, br, bl image.Point) BoardCorners {
+		return BoardCorners{TopLeft: tl, TopRight: tr, BottomRight: br, BottomLeft: bl}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		corners BoardCorners
+		err     string
+	}{
+		{"board1", quad(image.Pt(352, 61), image.Pt(928, 68), image.Pt(921, 649), image.Pt(331, 643)), ""},
+		{"perspective", quad(image.Pt(420, 150), image.Pt(860, 150), image.Pt(960, 650), image.Pt(320, 650)), ""},
+		{"crossed", quad(image.Pt(300, 100), image.Pt(900, 100), image.Pt(300, 650), image.Pt(900, 650)), "convex"},
+		{"collinear", quad(image.Pt(300, 100), image.Pt(600, 100), image.Pt(900, 100), image.Pt(600, 650)), "convex"},
+		{"tiny", quad(image.Pt(600, 300), image.Pt(700, 300), image.Pt(700, 400), image.Pt(600, 400)), "cover"},
+		{"sliver", quad(image.Pt(100, 300), image.Pt(1100, 300), image.Pt(1100, 700), image.Pt(100, 700)), "times apart"},
+		{"sheared", quad(image.Pt(500, 100), image.Pt(1100, 100), image.Pt(800, 650), image.Pt(200, 650)), "degrees"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCornerQuad(tc.corners, 1280, 720)
+			if tc.err == "" {
+				test.That(t, err, test.ShouldBeNil)
+				return
+			}
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, tc.err)
+		})
+	}
+}
+
+func TestFindBoardBadQuad(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	// board1 covers about 37% of the frame
+	opts := DefaultBoardFinderOptions()
+	opts.MinQuadArea = .5
+
+	res := FindBoardEx(input, opts)
+	test.That(t, res.Found, test.ShouldBeFalse)
+	test.That(t, res.Reason, test.ShouldEqual, NotFoundBadQuad)
+	test.That(t, res.Err.Error(), test.ShouldContainSubstring, "cover")
+
+	_, err = FindBoardWithOptions(input, opts)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	opts.MinQuadArea = 0
+	res = FindBoardEx(input, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+	test.That(t, res.Err, test.ShouldBeNil)
+}
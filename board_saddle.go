@@ -0,0 +1,271 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r2"
+)
+
+const (
+	// saddleAgreement is how far (fraction of a square) the border corners can be from where
+	// the inner corners put them and still be used. The border lines are sharper, but the grid
+	// can't be fooled by a white border or the table edge.
+	saddleAgreement = .125
+	// saddleRadius is how far (fraction of a square) around where an inner corner should be
+	// its gradients are used, and how far it can end up from there.
+	saddleRadius = .3
+	// saddleIterations caps how many times an inner corner is re-estimated from its gradients.
+	saddleIterations = 6
+	// minSaddleContrast is how different (0-255) the two diagonals around an inner corner have
+	// to be in a normally exposed frame.
+	minSaddleContrast = 25
+)
+
+// checkInnerCorners is the last stage of detectBoard. If enough inner corners are found inside
+// the board res found, and the grid through them ends somewhere else, whichever of the two
+// quads scoreCorners likes better wins.
+func checkInnerCorners(img image.Image, res FindBoardResult, opts BoardFinderOptions, dbg *BoardFinderDebug) FindBoardResult {
+	n := opts.gridSize()
+	points := detectInnerCorners(img, subPixelCornersFromSlice(res.SubPixelCorners), n, opts.exposed(minSaddleContrast))
+	if dbg != nil {
+		for _, p := range points {
+			dbg.SaddlePoints = append(dbg.SaddlePoints, p.pixel)
+		}
+	}
+
+	grid, ok := fitInnerCorners(points, opts.minSaddlePoints(), n)
+	if !ok {
+		return res
+	}
+	if dbg != nil {
+		dbg.SaddleCorners = grid.slice()
+	}
+
+	c := grid.slice()
+	square := 0.0
+	for i := range c {
+		square += c[(i+1)%4].Sub(c[i]).Norm() / float64(4*n)
+	}
+	if maxCornerDistance(c, res.SubPixelCorners) <= saddleAgreement*square {
+		return res
+	}
+	// a white border or the table edge fits the border lines but not the squares
+	borderScore, err := scoreCorners(img, res.SubPixelCorners, n)
+	if err != nil {
+		return res
+	}
+	if gridScore, err := scoreCorners(img, c, n); err != nil || gridScore < borderScore {
+		return res
+	}
+	if dbg != nil {
+		dbg.RefinedCorners = c
+	}
+	// the border lines still don't agree with them, that's for cornerConfidence to weigh
+	found := foundResult(grid)
+	found.lineCorners = res.lineCorners
+	return found
+}
+
+// saddlePoint is an inner corner of the board, where it is in the image and where it is on the
+// board in squares from the TL corner.
+type saddlePoint struct {
+	board, pixel r2.Point
+}
+
+// detectInnerCorners finds the (n-1)x(n-1) corners where four squares meet inside the rough quad
+// of an n x n board.
+// Each is looked for where rough says it should be, moved to the point every nearby gradient
+// points away from, and kept only if it really looks like an X of two light and two dark
+// squares the right way round, with the diagonals at least minContrast apart.
+func detectInnerCorners(img image.Image, rough subPixelCorners, n int, minContrast float64) []saddlePoint {
+	h, err := computeHomography(rough.slice(), float64(n))
+	if err != nil {
+		return nil
+	}
+	toImage, err := h.Inverse()
+	if err != nil {
+		return nil
+	}
+
+	found := []saddlePoint{}
+	signs := []float64{}
+	for j := 1; j < n; j++ {
+		for i := 1; i < n; i++ {
+			board := r2.Point{X: float64(i), Y: float64(j)}
+			p, sign, ok := findSaddle(img, toImage, board, minContrast)
+			if !ok {
+				continue
+			}
+			found = append(found, saddlePoint{board: board, pixel: p})
+			signs = append(signs, sign*parity(j, i))
+		}
+	}
+
+	// every light square is the same color, so the real ones all agree on which diagonal is lighter
+	vote := 0.0
+	for _, s := range signs {
+		vote += s
+	}
+	res := found[:0]
+	for i, p := range found {
+		if signs[i]*vote > 0 {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// findSaddle refines the inner corner at board, mapped into the image by toImage. sign is
+// positive when the squares to its top left and bottom right are the lighter ones.
+func findSaddle(img image.Image, toImage Homography, board r2.Point, minContrast float64) (r2.Point, float64, bool) {
+	bounds := img.Bounds()
+	p0 := toImage.Apply(board)
+	u := toImage.Apply(board.Add(r2.Point{X: .5})).Sub(toImage.Apply(board.Sub(r2.Point{X: .5})))
+	v := toImage.Apply(board.Add(r2.Point{Y: .5})).Sub(toImage.Apply(board.Sub(r2.Point{Y: .5})))
+	square := math.Min(u.Norm(), v.Norm())
+
+	r := saddleRadius * square
+	if r < 3 {
+		return r2.Point{}, 0, false
+	}
+
+	// big enough for the quadrant samples a quarter square out from anywhere q can get to
+	half := int(math.Ceil(r+.4*square)) + 2
+	cx, cy := int(math.Round(p0.X)), int(math.Round(p0.Y))
+	win := image.Rect(cx-half, cy-half, cx+half+1, cy+half+1)
+	if !win.In(image.Rect(0, 0, bounds.Dx(), bounds.Dy())) {
+		return r2.Point{}, 0, false
+	}
+
+	gray := makeGrayImageRect(img, win.Add(bounds.Min))
+	defer gray.release()
+	sobel := sobelEdgeDetection(gray)
+	defer sobel.release()
+
+	origin := r2.Point{X: float64(win.Min.X), Y: float64(win.Min.Y)}
+	q := p0.Sub(origin)
+	start := q
+	for range saddleIterations {
+		next, ok := saddleStep(sobel, gray.Width, gray.Height, q, r)
+		if !ok {
+			return r2.Point{}, 0, false
+		}
+		moved := next.Sub(q).Norm()
+		q = next
+		if q.Sub(start).Norm() > r {
+			return r2.Point{}, 0, false
+		}
+		if moved < .05 {
+			break
+		}
+	}
+
+	// the four squares meeting at q, sampled a quarter square into each
+	at := func(du, dv float64) float64 {
+		s := q.Add(u.Mul(du)).Add(v.Mul(dv))
+		x := min(max(int(math.Round(s.X)), 1), gray.Width-2)
+		y := min(max(int(math.Round(s.Y)), 1), gray.Height-2)
+		sum := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				sum += int(gray.At(x+dx, y+dy))
+			}
+		}
+		return float64(sum) / 9
+	}
+	tl, br := at(-.25, -.25), at(.25, .25)
+	tr, bl := at(.25, -.25), at(-.25, .25)
+	contrast := (tl+br)/2 - (tr+bl)/2
+	if math.Abs(contrast) < minContrast ||
+		math.Abs(tl-br) > math.Abs(contrast)/2 || math.Abs(tr-bl) > math.Abs(contrast)/2 {
+		return r2.Point{}, 0, false
+	}
+
+	return q.Add(origin), math.Copysign(1, contrast), true
+}
+
+// saddleStep is the point within r of q that the gradients around it are most nearly all
+// perpendicular to the direction from, which at an X of squares is where the edges cross.
+func saddleStep(sobel *sobelResult, width, height int, q r2.Point, r float64) (r2.Point, bool) {
+	var a11, a12, a22, b1, b2 float64
+	x0, x1 := max(1, int(q.X-r)), min(width-2, int(q.X+r)+1)
+	y0, y1 := max(1, int(q.Y-r)), min(height-2, int(q.Y+r)+1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			dx, dy := float64(x)-q.X, float64(y)-q.Y
+			if dx*dx+dy*dy > r*r {
+				continue
+			}
+			i := y*width + x
+			gx, gy := float64(sobel.gx[i]), float64(sobel.gy[i])
+			gxx, gxy, gyy := gx*gx, gx*gy, gy*gy
+			a11 += gxx
+			a12 += gxy
+			a22 += gyy
+			b1 += gxx*float64(x) + gxy*float64(y)
+			b2 += gxy*float64(x) + gyy*float64(y)
+		}
+	}
+
+	det := a11*a22 - a12*a12
+	// both edge directions have to be there, a single edge has no crossing to find
+	if det <= 1e-6*(a11+a22)*(a11+a22) {
+		return r2.Point{}, false
+	}
+	return r2.Point{X: (a22*b1 - a12*b2) / det, Y: (a11*b2 - a12*b1) / det}, true
+}
+
+// fitInnerCorners fits the board to image homography best matching the inner corners, drops the
+// ones too far off it and fits again, and returns the outer corners the fitted n x n grid ends
+// at. False if fewer than minPoints corners are left to fit.
+func fitInnerCorners(points []saddlePoint, minPoints, n int) (subPixelCorners, bool) {
+	fit := func(points []saddlePoint) (Homography, bool) {
+		from := make([]r2.Point, len(points))
+		to := make([]r2.Point, len(points))
+		for i, p := range points {
+			from[i], to[i] = p.board, p.pixel
+		}
+		h, err := fitHomography(from, to)
+		return h, err == nil
+	}
+
+	if len(points) < minPoints {
+		return subPixelCorners{}, false
+	}
+	h, ok := fit(points)
+	if !ok {
+		return subPixelCorners{}, false
+	}
+
+	residuals := make([]float64, len(points))
+	for i, p := range points {
+		residuals[i] = h.Apply(p.board).Sub(p.pixel).Norm()
+	}
+	sorted := append([]float64(nil), residuals...)
+	sort.Float64s(sorted)
+	limit := math.Max(1.5, 3*sorted[len(sorted)/2])
+
+	kept := []saddlePoint{}
+	for i, p := range points {
+		if residuals[i] <= limit {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) < minPoints {
+		return subPixelCorners{}, false
+	}
+	h, ok = fit(kept)
+	if !ok {
+		return subPixelCorners{}, false
+	}
+
+	side := float64(n)
+	return subPixelCorners{
+		topLeft:     h.Apply(r2.Point{X: 0, Y: 0}),
+		topRight:    h.Apply(r2.Point{X: side, Y: 0}),
+		bottomRight: h.Apply(r2.Point{X: side, Y: side}),
+		bottomLeft:  h.Apply(r2.Point{X: 0, Y: side}),
+	}, true
+}
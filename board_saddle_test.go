@@ -0,0 +1,57 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestDetectInnerCorners(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	res := FindBoardEx(input, DefaultBoardFinderOptions())
+	test.That(t, res.Found, test.ShouldBeTrue)
+
+	// the two ranks of pieces at each end hide some, but most of the middle should be there
+	points := detectInnerCorners(input, subPixelCornersFromSlice(res.SubPixelCorners), 8, minSaddleContrast)
+	t.Logf("%d inner corners", len(points))
+	test.That(t, len(points), test.ShouldBeGreaterThanOrEqualTo, 30)
+
+	grid, ok := fitInnerCorners(points, 30, 8)
+	test.That(t, ok, test.ShouldBeTrue)
+	t.Logf("grid corners %v", grid.round().Slice())
+	test.That(t, maxCornerError(grid.round().Slice(), expected), test.ShouldBeLessThan, 5)
+
+	_, ok = fitInnerCorners(points[:20], 30, 8)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestCheckInnerCornersFixesBorder(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	opts := DefaultBoardFinderOptions()
+
+	res := FindBoardEx(input, opts)
+	test.That(t, checkInnerCorners(input, res, opts, nil), test.ShouldResemble, res)
+
+	// as if the border lines had latched onto something a fifth of a square outside the board
+	c := subPixelCornersFromSlice(res.SubPixelCorners)
+	center := c.topLeft.Add(c.bottomRight).Mul(.5)
+	grown := []r2.Point{}
+	for _, p := range c.slice() {
+		grown = append(grown, center.Add(p.Sub(center).Mul(1+.2/4)))
+	}
+	bad := foundResult(subPixelCornersFromSlice(grown))
+	test.That(t, maxCornerError(bad.Corners, expected), test.ShouldBeGreaterThan, 15)
+
+	fixed := checkInnerCorners(input, bad, opts, nil)
+	t.Logf("fixed %v", fixed.Corners)
+	test.That(t, fixed.Found, test.ShouldBeTrue)
+	test.That(t, maxCornerError(fixed.Corners, expected), test.ShouldBeLessThan, 5)
+}
@@ -0,0 +1,74 @@
+package viamchess
+
+import (
+	"fmt"
+	"time"
+)
+
+// boardScan is what one look at the board saw, for the logs and the scan command.
+type boardScan struct {
+	at      time.Time
+	took    time.Duration // finding the board and what's on it, 0 when it wasn't timed
+	found   bool          // there was a board to go by, found, pinned or remembered
+	squares []squareScan  // empty without a point cloud to go by
+}
+
+// squareScan is what a boardScan saw on one square.
+type squareScan struct {
+	Square
+	confidence  float64 // of its color, see estimatePieceColor
+	footprintMM float64 // see pieceShape
+	fallen      bool
+}
+
+// newBoardScan is the scan of squares, taken at at.
+func newBoardScan(squares []squareInfo, at time.Time, took time.Duration, found bool) boardScan {
+	scan := boardScan{at: at, took: took, found: found, squares: make([]squareScan, 0, len(squares))}
+	for _, sq := range squares {
+		scan.squares = append(scan.squares, squareScan{sq.export(), sq.confidence, sq.footprintMM, sq.fallen})
+	}
+	return scan
+}
+
+// counts is how many of the squares are empty, white and black, and the points over them all.
+func (s boardScan) counts() ([3]int, int) {
+	var colors [3]int
+	points := 0
+	for _, sq := range s.squares {
+		colors[sq.Color]++
+		points += sq.PointCount
+	}
+	return colors, points
+}
+
+// summary is the scan on one line.
+func (s boardScan) summary() string {
+	if len(s.squares) == 0 {
+		return fmt.Sprintf("board found %v, no point cloud, took %v", s.found, s.took)
+	}
+	colors, points := s.counts()
+	return fmt.Sprintf("board found %v, %d white %d black %d empty, %d points, took %v",
+		s.found, colors[1], colors[2], colors[0], points, s.took)
+}
+
+// toMap is the scan for a DoCommand response.
+func (s boardScan) toMap() map[string]interface{} {
+	squares := make([]interface{}, 0, len(s.squares))
+	for _, sq := range s.squares {
+		squares = append(squares, map[string]interface{}{
+			"name":         sq.Name,
+			"piece":        pieceLabels[sq.Color],
+			"confidence":   sq.confidence,
+			"points":       sq.PointCount,
+			"height_mm":    sq.Height,
+			"footprint_mm": sq.footprintMM,
+			"fallen":       sq.fallen,
+		})
+	}
+	return map[string]interface{}{
+		"at":      s.at.Format(time.RFC3339Nano),
+		"took_ms": float64(s.took) / float64(time.Millisecond),
+		"found":   s.found,
+		"squares": squares,
+	}
+}
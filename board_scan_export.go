@@ -0,0 +1,165 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+)
+
+// minExportFreeBytes is how much room the filesystem ExportBoardScan writes to has to have.
+const minExportFreeBytes = 100 << 20
+
+// BoardScan is one look at the board with everything it was made from, for ExportBoardScan.
+type BoardScan struct {
+	At      time.Time
+	Corners [][]int     // the board's TL, TR, BR and BL corners in the frame
+	Warped  image.Image // the board top down, see WarpBoard
+	Squares []Square
+	// Clouds are the points each square was classified from, by its name.
+	Clouds map[string]pointcloud.PointCloud
+}
+
+// scanBoard is the BoardScan, taken at at, of the board at corners in img, and pc, which can be
+// nil, the way conf has the piece finder look at it.
+func scanBoard(img image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig, corners [][]int, at time.Time) (BoardScan, error) {
+	board := configCorners(corners).Slice()
+	squares, err := findBoardAndPiecesWithCorners(img, pc, props, conf, board)
+	if err != nil {
+		return BoardScan{}, err
+	}
+	warped, _, err := WarpBoard(img, board, WarpOptions{Width: defaultBoardCameraSize, Rotation: conf.Rotation})
+	if err != nil {
+		return BoardScan{}, err
+	}
+	scan := BoardScan{At: at, Corners: corners, Warped: warped, Clouds: map[string]pointcloud.PointCloud{}}
+	for _, sq := range squares {
+		scan.Squares = append(scan.Squares, sq.export())
+		scan.Clouds[sq.name] = sq.pc
+	}
+	return scan, nil
+}
+
+// ExportBoardScan writes scan to a new directory in dir named for when it was taken, and returns
+// its path: the warped image as warped.png, the corners and squares as summary.json, and each
+// square's points as <square>.pcd, binary, empty when it had none. It won't with under
+// minExportFreeBytes free where dir is.
+func ExportBoardScan(dir string, scan BoardScan) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	free, err := freeBytes(dir)
+	if err != nil {
+		return "", err
+	}
+	if free < minExportFreeBytes {
+		return "", fmt.Errorf("only %d MB free in %s, need %d", free>>20, dir, minExportFreeBytes>>20)
+	}
+
+	path := filepath.Join(dir, "scan-"+scan.At.UTC().Format("20060102-150405.000"))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return "", err
+	}
+	if err := writeBoardScan(path, scan); err != nil {
+		return "", errors.Join(err, os.RemoveAll(path))
+	}
+	return path, nil
+}
+
+func writeBoardScan(path string, scan BoardScan) error {
+	if err := rimage.WriteImageToFile(filepath.Join(path, "warped.png"), scan.Warped); err != nil {
+		return err
+	}
+	summary, err := json.MarshalIndent(map[string]interface{}{
+		"at":      scan.At.Format(time.RFC3339Nano),
+		"corners": scan.Corners,
+		"squares": scan.Squares,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(path, "summary.json"), summary, 0o644); err != nil {
+		return err
+	}
+	for _, sq := range scan.Squares {
+		pc := scan.Clouds[sq.Name]
+		if pc == nil {
+			pc = pointcloud.NewBasicEmpty()
+		}
+		if err := writePCD(filepath.Join(path, sq.Name+".pcd"), pc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePCD(fn string, pc pointcloud.PointCloud) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := pointcloud.ToPCD(pc, f, pointcloud.PCDBinary); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	return f.Close()
+}
+
+// exportScan is {"export_scan": "<dir>"}: the current frame's BoardScan written to dir by
+// ExportBoardScan, on the machine running the module, the board at the DoCommand's corners if
+// it had any, the pinned ones or wherever it's found.
+func (bc *PieceFinder) exportScan(ctx context.Context, dir string) (map[string]interface{}, error) {
+	if dir == "" {
+		return nil, errors.New("export_scan needs the directory to write to")
+	}
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, nil)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if err != nil {
+		bc.logger.Warnf("no point cloud, exporting the image alone: %v", err)
+		pc = nil
+	}
+	bc.captureLock.Lock()
+	conf := bc.captureConf()
+	bc.captureLock.Unlock()
+	if corners := bc.requestCorners(ctx.Value(cornersKey{}), img); corners != nil {
+		conf.Corners = corners
+	}
+
+	corners := conf.Corners
+	if corners == nil {
+		opts, err := conf.boardFinderOptions()
+		if err != nil {
+			return nil, err
+		}
+		res := findCorners(ctx, nil, img, pc, bc.props, conf, opts)
+		if !res.Found {
+			if res.Err != nil {
+				return nil, fmt.Errorf("board not found (%s): %w", res.Reason, res.Err)
+			}
+			return nil, fmt.Errorf("board not found (%s)", res.Reason)
+		}
+		corners = roundedCorners(res.SubPixelCorners)
+	}
+	scan, err := scanBoard(img, pc, bc.props, conf, corners, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	path, err := ExportBoardScan(dir, scan)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": path}, nil
+}
@@ -0,0 +1,90 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestExportBoardScan(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	at := time.Date(2026, 3, 1, 12, 30, 5, 0, time.UTC)
+	scan, err := scanBoard(input, pc, touch.RealSenseProperties, &PieceFinderConfig{}, board13Corners, at)
+	test.That(t, err, test.ShouldBeNil)
+	dir := t.TempDir()
+	path, err := ExportBoardScan(dir, scan)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, path, test.ShouldEqual, filepath.Join(dir, "scan-20260301-123005.000"))
+
+	warped, err := rimage.ReadImageFromFile(filepath.Join(path, "warped.png"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, warped.Bounds().Size(), test.ShouldResemble, image.Pt(defaultBoardCameraSize, defaultBoardCameraSize))
+
+	data, err := os.ReadFile(filepath.Join(path, "summary.json"))
+	test.That(t, err, test.ShouldBeNil)
+	var summary struct {
+		At      string   `json:"at"`
+		Corners [][]int  `json:"corners"`
+		Squares []Square `json:"squares"`
+	}
+	test.That(t, json.Unmarshal(data, &summary), test.ShouldBeNil)
+	test.That(t, summary.At, test.ShouldEqual, "2026-03-01T12:30:05Z")
+	test.That(t, summary.Corners, test.ShouldResemble, board13Corners)
+	test.That(t, summary.Squares, test.ShouldResemble, scan.Squares)
+	test.That(t, len(summary.Squares), test.ShouldEqual, 64)
+
+	files, err := filepath.Glob(filepath.Join(path, "*.pcd"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 64)
+	for _, sq := range summary.Squares {
+		back, err := pointcloud.NewFromFile(filepath.Join(path, sq.Name+".pcd"), "")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, back.Size(), test.ShouldEqual, scan.Clouds[sq.Name].Size())
+	}
+	e2 := summary.Squares[squareIndex('e', 2, 8)]
+	test.That(t, e2.Name, test.ShouldEqual, "e2")
+	test.That(t, e2.Color, test.ShouldEqual, 1)
+
+	// a second one taken at the same time doesn't write over it
+	_, err = ExportBoardScan(dir, scan)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	free, err := freeBytes(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, free, test.ShouldBeGreaterThan, 0)
+}
+
+func TestExportScanCommand(t *testing.T) {
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := boxesBoard(t, raisedBox{image.Rect(595, 345, 625, 375), 40})
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	dir := t.TempDir()
+	res, err := pf.DoCommand(context.Background(), map[string]interface{}{"export_scan": dir})
+	test.That(t, err, test.ShouldBeNil)
+	path, _ := res["path"].(string)
+	test.That(t, strings.HasPrefix(path, filepath.Join(dir, "scan-")), test.ShouldBeTrue)
+	e4, err := pointcloud.NewFromFile(filepath.Join(path, "e4.pcd"), "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, e4.Size(), test.ShouldBeGreaterThan, 0)
+
+	_, err = pf.DoCommand(context.Background(), map[string]interface{}{"export_scan": ""})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,62 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestScoreCorners(t *testing.T) {
+	for _, tc := range []boardTestCase{
+		{inputFile: "data/board1.jpg", expectedCorners: []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}},
+		{inputFile: "data/board5.jpg", expectedCorners: []image.Point{{296, 17}, {970, 17}, {982, 700}, {283, 705}}},
+	} {
+		input, err := rimage.ReadImageFromFile(tc.inputFile)
+		test.That(t, err, test.ShouldBeNil)
+
+		good, err := ScoreCorners(input, tc.expectedCorners)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, good, test.ShouldBeGreaterThan, .6)
+
+		for _, d := range []image.Point{{20, 0}, {0, 20}, {-20, -20}} {
+			shifted := make([]image.Point, 4)
+			for i, c := range tc.expectedCorners {
+				shifted[i] = c.Add(d)
+			}
+			bad, err := ScoreCorners(input, shifted)
+			test.That(t, err, test.ShouldBeNil)
+			t.Logf("%s: %.2f, shifted %v %.2f", tc.inputFile, good, d, bad)
+			test.That(t, bad, test.ShouldBeLessThan, good/2)
+		}
+	}
+
+	_, err := ScoreCorners(image.NewRGBA(image.Rect(0, 0, 100, 100)), []image.Point{{1, 1}})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = ScoreCorners(image.NewRGBA(image.Rect(0, 0, 100, 100)), []image.Point{{200, 200}, {300, 200}, {300, 300}, {200, 300}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestScoreCornersCommand(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	var pc pointcloud.PointCloud
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{
+		"score_corners": []interface{}{
+			[]interface{}{390.0, 48.0}, []interface{}{965.0, 85.0}, []interface{}{939.0, 665.0}, []interface{}{347.0, 635.0},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["score"], test.ShouldBeGreaterThan, .6)
+
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"score_corners": []interface{}{[]interface{}{390.0, 48.0}}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
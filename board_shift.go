@@ -0,0 +1,176 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"time"
+
+	"github.com/golang/geo/r2"
+)
+
+const (
+	defaultShiftThreshold = 20.0
+	defaultShiftFrames    = 3
+
+	// shiftStripWidth is how far (fraction of a square) to each side of the board's edge the
+	// strip borderSignature samples is.
+	shiftStripWidth = .25
+	// shiftSamples is how many points along each square's stretch of the edge it's sampled at.
+	shiftSamples = 4
+
+	// maxEvents is how many events the piece finder keeps for the events command.
+	maxEvents = 50
+)
+
+// borderSignature is the brightness of the strip along the outside edge of the n x n board with
+// corners in img, the squares on the inside and the margin on the outside: the mean gray of each
+// square's stretch of it, both sides, around the board from the top left. Light and dark squares
+// alternate along it, so a board that moved a fraction of a square changes most of them. nil if
+// the corners don't make a quad.
+func borderSignature(img image.Image, corners []r2.Point, n int) []float64 {
+	h, err := computeHomography(corners, float64(n))
+	if err != nil {
+		return nil
+	}
+	toImage, err := h.Inverse()
+	if err != nil {
+		return nil
+	}
+
+	bounds := img.Bounds()
+	var px [1]uint8
+	side := float64(n)
+	// each edge's start, the way along it, and the way into the board
+	edges := [4][3]r2.Point{
+		{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}},
+		{{X: side, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}},
+		{{X: side, Y: side}, {X: -1, Y: 0}, {X: 0, Y: -1}},
+		{{X: 0, Y: side}, {X: 0, Y: -1}, {X: 1, Y: 0}},
+	}
+	sig := make([]float64, 0, 8*n)
+	for _, e := range edges {
+		start, along, in := e[0], e[1], e[2].Mul(shiftStripWidth/2)
+		for k := range n {
+			for _, offset := range []r2.Point{in, in.Mul(-1)} {
+				sum, count := 0, 0
+				for i := range shiftSamples {
+					t := float64(k) + (float64(i)+.5)/shiftSamples
+					p := toImage.Apply(start.Add(along.Mul(t)).Add(offset))
+					pt := image.Point{X: int(math.Floor(p.X)), Y: int(math.Floor(p.Y))}.Add(bounds.Min)
+					if !pt.In(bounds) {
+						continue
+					}
+					grayRow(img, pt.X, pt.Y, px[:])
+					sum += int(px[0])
+					count++
+				}
+				v := 0.0
+				if count > 0 {
+					v = float64(sum) / float64(count)
+				}
+				sig = append(sig, v)
+			}
+		}
+	}
+	return sig
+}
+
+// signatureDistance is the mean absolute difference between borderSignatures a and b.
+func signatureDistance(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return math.Inf(1)
+	}
+	d := 0.0
+	for i := range a {
+		d += math.Abs(a[i] - b[i])
+	}
+	return d / float64(len(a))
+}
+
+func (cfg *PieceFinderConfig) shiftThreshold() float64 {
+	if cfg.ShiftThreshold == 0 {
+		return defaultShiftThreshold
+	}
+	return cfg.ShiftThreshold
+}
+
+func (cfg *PieceFinderConfig) shiftFrames() int {
+	if cfg.ShiftFrames <= 0 {
+		return defaultShiftFrames
+	}
+	return cfg.ShiftFrames
+}
+
+// shiftWatch notices the board moving out from under frozen or cached corners, see
+// cornerSmoother.skip. The border strip under them is taken as the reference on the first frame
+// they're used for, and any frame whose strip is more than threshold away from it counts toward
+// a shift.
+type shiftWatch struct {
+	ref   []float64
+	count int // frames in a row past the threshold
+}
+
+// pieceFinderEvent is something the piece finder did on its own, for the events command.
+type pieceFinderEvent struct {
+	when    time.Time
+	kind    string
+	message string
+}
+
+// checkShift looks for the board having moved in img from under frozen or cached corners, and
+// once it has for conf.shiftFrames frames in a row forgets them so this capture finds it again,
+// and records it in bc's events. bc.captureLock held.
+func (bc *PieceFinder) checkShift(img image.Image, conf *PieceFinderConfig) {
+	threshold := conf.shiftThreshold()
+	opts, err := conf.boardFinderOptions()
+	if err != nil || threshold < 0 || conf.Corners != nil || !bc.corners.skip() {
+		bc.shift = shiftWatch{}
+		return
+	}
+
+	sig := borderSignature(img, bc.corners.avg, opts.gridSize())
+	if bc.shift.ref == nil {
+		bc.shift.ref = sig
+		return
+	}
+	d := signatureDistance(bc.shift.ref, sig)
+	if d <= threshold {
+		bc.shift.count = 0
+		return
+	}
+	bc.shift.count++
+	if bc.shift.count < conf.shiftFrames() {
+		return
+	}
+
+	msg := fmt.Sprintf("board border changed by %.1f for %d frames, finding the board again", d, bc.shift.count)
+	bc.logger.Warn(msg)
+	bc.addEvent("board-shift", msg)
+	bc.corners.reset()
+	bc.shift = shiftWatch{}
+}
+
+// addEvent records an event of kind, dropping the oldest past maxEvents. bc.captureLock held.
+func (bc *PieceFinder) addEvent(kind, message string) {
+	bc.events = append(bc.events, pieceFinderEvent{when: time.Now(), kind: kind, message: message})
+	if len(bc.events) > maxEvents {
+		bc.events = append(bc.events[:0], bc.events[len(bc.events)-maxEvents:]...)
+	}
+}
+
+// eventList is {"events": true}, the events bc recorded, oldest first.
+func (bc *PieceFinder) eventList() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	events := []interface{}{}
+	for _, e := range bc.events {
+		events = append(events, map[string]interface{}{
+			"time":    e.when.Format(time.RFC3339Nano),
+			"event":   e.kind,
+			"message": e.message,
+		})
+	}
+	return map[string]interface{}{"events": events}
+}
@@ -0,0 +1,109 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// translated is img moved by d, the table's color filling in behind it.
+func translated(img image.Image, d image.Point) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.RGBA{60, 50, 40, 255}), image.Point{}, draw.Src)
+	draw.Draw(out, img.Bounds().Add(d), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+func TestBorderSignature(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []r2.Point{{X: 314, Y: 22}, {X: 979, Y: 22}, {X: 976, Y: 687}, {X: 313, Y: 687}}
+
+	ref := borderSignature(input, corners, 8)
+	test.That(t, len(ref), test.ShouldEqual, 64)
+	test.That(t, signatureDistance(ref, borderSignature(input, corners, 8)), test.ShouldEqual, 0)
+
+	// moved a quarter of a square, most of the strip is a different color
+	for _, d := range []image.Point{{20, 0}, {0, -20}} {
+		moved := signatureDistance(ref, borderSignature(translated(input, d), corners, 8))
+		t.Logf("moved %v: %.1f", d, moved)
+		test.That(t, moved, test.ShouldBeGreaterThan, defaultShiftThreshold)
+	}
+	// a couple of pixels isn't a bump
+	test.That(t, signatureDistance(ref, borderSignature(translated(input, image.Pt(2, 1)), corners, 8)), test.ShouldBeLessThan, defaultShiftThreshold)
+
+	test.That(t, borderSignature(input, []r2.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}}, 8), test.ShouldBeNil)
+}
+
+func TestPieceFinderBoardShift(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// the cloud doesn't move with the frame, so the corners have to come from the image
+	frame := image.Image(input)
+	conf := &PieceFinderConfig{Input: "cam", CornerSource: cornerSourceImage, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	capture := func() {
+		_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	events := func() []interface{} {
+		res, err := pf.DoCommand(ctx, map[string]interface{}{"events": true})
+		test.That(t, err, test.ShouldBeNil)
+		return res["events"].([]interface{})
+	}
+
+	capture()
+	before := pf.corners.corners()
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"freeze": true})
+	test.That(t, err, test.ShouldBeNil)
+	for range 5 {
+		capture()
+	}
+	test.That(t, events(), test.ShouldBeEmpty)
+
+	// bumped 25 pixels right, it takes shift-frames frames to believe it
+	frame = translated(input, image.Pt(25, 0))
+	for range defaultShiftFrames - 1 {
+		capture()
+		test.That(t, pf.corners.corners(), test.ShouldResemble, before)
+	}
+	capture()
+	test.That(t, len(events()), test.ShouldEqual, 1)
+	event := events()[0].(map[string]interface{})
+	test.That(t, event["event"], test.ShouldEqual, "board-shift")
+	after := pf.corners.corners()
+	for i, c := range after {
+		test.That(t, c.X-before[i].X, test.ShouldAlmostEqual, 25, 4)
+		test.That(t, c.Y-before[i].Y, test.ShouldAlmostEqual, 0, 4)
+	}
+
+	// still frozen, now where it went
+	test.That(t, pf.corners.frozen, test.ShouldBeTrue)
+	for range 5 {
+		capture()
+	}
+	test.That(t, len(events()), test.ShouldEqual, 1)
+	test.That(t, pf.corners.corners(), test.ShouldResemble, after)
+
+	// and not watched for with it turned off
+	conf.ShiftThreshold = -1
+	frame = input
+	for range 5 {
+		capture()
+	}
+	test.That(t, len(events()), test.ShouldEqual, 1)
+}
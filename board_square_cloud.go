@@ -0,0 +1,94 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// boardSquare is the file and rank of the square called name on an n x n board.
+func boardSquare(name string, n int) (rune, int, error) {
+	i := slices.Index(gridSquareNames(n), name)
+	if i < 0 {
+		return 0, 0, fmt.Errorf("no square %q on a %dx%d board", name, n, n)
+	}
+	return 'a' + rune(i%n), i/n + 1, nil
+}
+
+// boardSquareCloud is the points of board, a point cloud in the board's frame (see
+// boardFrameCloud) with squareSize mm squares, that are over file, rank.
+func boardSquareCloud(board pointcloud.PointCloud, file rune, rank int, squareSize float64) (pointcloud.PointCloud, error) {
+	x0, y0 := float64(file-'a')*squareSize, float64(rank-1)*squareSize
+	out := pointcloud.NewBasicEmpty()
+	var err error
+	board.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.X >= x0 && p.X < x0+squareSize && p.Y >= y0 && p.Y < y0+squareSize {
+			err = out.Set(p, d)
+		}
+		return err == nil
+	})
+	return out, err
+}
+
+// squareCloud is the board camera's point cloud over the square called name, from a new frame.
+func (bc *BoardCamera) squareCloud(ctx context.Context, name interface{}) (pointcloud.PointCloud, error) {
+	s, _ := name.(string)
+	return bc.NextPointCloud(ctx, map[string]interface{}{"square": s})
+}
+
+// squarePCD is the get_square_pcd command: the point cloud over a square as a base64 binary PCD.
+func (bc *BoardCamera) squarePCD(ctx context.Context, name interface{}) (map[string]interface{}, error) {
+	pc, err := bc.squareCloud(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := pointcloud.ToPCD(pc, &buf, pointcloud.PCDBinary); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"square": name,
+		"points": pc.Size(),
+		"pcd":    base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// squareStats is the get_square_stats command: how many points are over a square, how low and
+// high (mm above the board) they go, and their average color.
+func (bc *BoardCamera) squareStats(ctx context.Context, name interface{}) (map[string]interface{}, error) {
+	pc, err := bc.squareCloud(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	minZ, maxZ := math.Inf(1), math.Inf(-1)
+	var sum [3]float64
+	colored := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		minZ, maxZ = min(minZ, p.Z), max(maxZ, p.Z)
+		if d != nil && d.HasColor() {
+			r, g, b := d.RGB255()
+			sum[0], sum[1], sum[2] = sum[0]+float64(r), sum[1]+float64(g), sum[2]+float64(b)
+			colored++
+		}
+		return true
+	})
+	stats := map[string]interface{}{"square": name, "points": pc.Size()}
+	if pc.Size() > 0 {
+		stats["min_z"], stats["max_z"] = minZ, maxZ
+	}
+	if colored > 0 {
+		stats["color"] = map[string]interface{}{
+			"r": sum[0] / float64(colored),
+			"g": sum[1] / float64(colored),
+			"b": sum[2] / float64(colored),
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,85 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestBoardSquare(t *testing.T) {
+	file, rank, err := boardSquare("e2", 8)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, file, test.ShouldEqual, 'e')
+	test.That(t, rank, test.ShouldEqual, 2)
+	file, rank, err = boardSquare("j10", 10)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, file, test.ShouldEqual, 'j')
+	test.That(t, rank, test.ShouldEqual, 10)
+
+	for _, name := range []string{"", "i1", "a9", "E2", "e"} {
+		_, _, err = boardSquare(name, 8)
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+func TestBoardCameraSquareCloud(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam"}, input, pc)
+
+	// the square's part of the whole board's cloud
+	board, err := bc.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	inE2 := 0
+	board.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.X >= 4*defaultSquareSize && p.X < 5*defaultSquareSize && p.Y >= defaultSquareSize && p.Y < 2*defaultSquareSize {
+			inE2++
+		}
+		return true
+	})
+	e2, err := bc.NextPointCloud(ctx, map[string]interface{}{"square": "e2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, e2.Size(), test.ShouldEqual, inE2)
+	test.That(t, e2.Size(), test.ShouldBeGreaterThan, 100)
+
+	_, err = bc.NextPointCloud(ctx, map[string]interface{}{"square": "z9"})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = bc.NextPointCloud(ctx, map[string]interface{}{"square": 12})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// the same cloud as a PCD
+	resp, err := bc.DoCommand(ctx, map[string]interface{}{"get_square_pcd": "e2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["points"], test.ShouldEqual, e2.Size())
+	raw, err := base64.StdEncoding.DecodeString(resp["pcd"].(string))
+	test.That(t, err, test.ShouldBeNil)
+	decoded, err := pointcloud.ReadPCD(bytes.NewReader(raw), "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, decoded.Size(), test.ShouldEqual, e2.Size())
+
+	// there's a white pawn on e2 and nothing on e4
+	stats, err := bc.DoCommand(ctx, map[string]interface{}{"get_square_stats": "e2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stats["points"], test.ShouldEqual, e2.Size())
+	test.That(t, stats["min_z"], test.ShouldBeLessThan, 5)
+	test.That(t, stats["max_z"], test.ShouldBeGreaterThan, 20)
+	pawn := stats["color"].(map[string]interface{})
+	stats, err = bc.DoCommand(ctx, map[string]interface{}{"get_square_stats": "e4"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stats["points"], test.ShouldBeGreaterThan, 100)
+	test.That(t, stats["max_z"], test.ShouldBeLessThan, defaultMinPieceSize)
+	test.That(t, stats["color"], test.ShouldNotResemble, pawn)
+
+	_, err = bc.DoCommand(ctx, map[string]interface{}{"get_square_stats": "k1"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,135 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// visibleWidth is how wide (pixels) IsBoardVisible shrinks the frame to.
+	visibleWidth = 160
+	// visibleEdge is how strong (Sobel magnitude) an edge of the shrunk frame has to be, in a
+	// normally exposed frame, to be part of the grid.
+	visibleEdge = 80
+	// minVisibleArea is how much of the frame the box around the grid's edges has to cover
+	// for the board to be all there, a board a few times farther away than the test frames.
+	minVisibleArea = .1
+	// The edges cover from minVisibleFill to fullVisibleFill of the box around them: about
+	// half on the test boards, a tenth or so for an outline like the arm's or the table's edge.
+	minVisibleFill  = .2
+	fullVisibleFill = .4
+	// And from minVisibleAxis to fullVisibleAxis of their gradients are within about 27 degrees
+	// of horizontal or vertical, like the grid lines the board finder looks for: over .8 on
+	// the test boards, half for the texture of something else.
+	minVisibleAxis  = .6
+	fullVisibleAxis = .75
+	// minVisibleScore is where IsBoardVisible says the board is there.
+	minVisibleScore = .5
+	// visibleRowStep is how many rows of the frame apart the ones IsBoardVisible reads are.
+	visibleRowStep = 3
+)
+
+// IsBoardVisible is a quick look, a small fraction of what finding the board costs, at whether
+// there's a board in img at all, with how sure it is, 0 to 1. The frame is shrunk to
+// visibleWidth wide, and the largest connected patch of strong edges in it has to be a grid:
+// spread over enough of the frame, filling enough of the box around it, and mostly running
+// along the axes.
+func IsBoardVisible(img image.Image) (bool, float64) {
+	score := boardVisibleScore(img, DefaultBoardFinderOptions().MinExposure)
+	return score >= minVisibleScore, score
+}
+
+// boardVisibleScore is IsBoardVisible's score, with the edge threshold scaled to the frame's
+// exposure down to minExposure, 0 leaving it alone.
+func boardVisibleScore(img image.Image, minExposure float64) float64 {
+	gray := visibleGray(img)
+	defer gray.release()
+	width, height := gray.Width, gray.Height
+	if width < 3 || height < 3 {
+		return 0
+	}
+
+	threshold := float64(visibleEdge)
+	if minExposure > 0 {
+		var hist [256]int
+		for _, v := range gray.Pix[:width*height] {
+			hist[v]++
+		}
+		threshold *= histogramExposure(&hist, minExposure)
+	}
+
+	sobel := sobelEdgeDetection(gray)
+	mask := make([]bool, width*height)
+	for i := range mask {
+		mask[i] = float64(sobel.magnitude.Pix[i]) > threshold
+	}
+	defer sobel.release()
+
+	labels, sizes := labelComponents(mask, width, height)
+	largest := 0
+	for l := 1; l < len(sizes); l++ {
+		if sizes[l] > sizes[largest] {
+			largest = l
+		}
+	}
+	if largest == 0 {
+		return 0
+	}
+
+	// the box around it, and how many of its gradients are near horizontal or vertical
+	box := image.Rectangle{Min: image.Point{width, height}}
+	axis := 0
+	for i, l := range labels {
+		if int(l) != largest {
+			continue
+		}
+		x, y := i%width, i/width
+		box.Min = image.Point{min(box.Min.X, x), min(box.Min.Y, y)}
+		box.Max = image.Point{max(box.Max.X, x+1), max(box.Max.Y, y+1)}
+		gx, gy := math.Abs(float64(sobel.gx[i])), math.Abs(float64(sobel.gy[i]))
+		if gx > 2*gy || gy > 2*gx {
+			axis++
+		}
+	}
+
+	ramp := func(v, lo, hi float64) float64 { return max(0, min(1, (v-lo)/(hi-lo))) }
+	area := float64(box.Dx() * box.Dy())
+	spread := min(1, area/float64(width*height)/minVisibleArea)
+	fill := ramp(float64(sizes[largest])/area, minVisibleFill, fullVisibleFill)
+	aligned := ramp(float64(axis)/float64(sizes[largest]), minVisibleAxis, fullVisibleAxis)
+	return spread * fill * aligned
+}
+
+// visibleGray is img shrunk to visibleWidth wide, for IsBoardVisible. Each pixel is the average
+// of the block it covers, but only every visibleRowStep'th row of the block is read.
+func visibleGray(img image.Image) *GrayPlane {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	f := min(1, float64(visibleWidth)/float64(width))
+	sw, sh := int(float64(width)*f), int(float64(height)*f)
+
+	gray := getGrayPlane(sw, sh)
+	row := make([]uint8, width)
+	sums := make([]int, sw)
+	for y := range sh {
+		clear(sums)
+		rows := 0
+		for fy := int(float64(y) / f); fy < min(height, int(float64(y+1)/f)); fy += visibleRowStep {
+			grayRow(img, bounds.Min.X, bounds.Min.Y+fy, row)
+			for x := range sw {
+				for _, v := range row[int(float64(x)/f):min(width, int(float64(x+1)/f))] {
+					sums[x] += int(v)
+				}
+			}
+			rows++
+		}
+
+		dst := gray.Row(y)
+		for x := range sw {
+			if n := rows * (min(width, int(float64(x+1)/f)) - int(float64(x)/f)); n > 0 {
+				dst[x] = uint8(sums[x] / n)
+			}
+		}
+	}
+	return gray
+}
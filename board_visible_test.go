@@ -0,0 +1,106 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// emptyTable is the frame with the table left of the board repeated across all of it.
+func emptyTable(img image.Image, boardLeft int) *image.RGBA {
+	b := img.Bounds()
+	res := image.NewRGBA(b)
+	for x := 0; x < b.Dx(); x += boardLeft {
+		draw.Draw(res, image.Rect(x, 0, x+boardLeft, b.Dy()), img, b.Min, draw.Src)
+	}
+	return res
+}
+
+func TestIsBoardVisible(t *testing.T) {
+	for _, fn := range []string{"data/board1.jpg", "data/board5.jpg", "data/board13.jpg"} {
+		input, err := rimage.ReadImageFromFile(fn)
+		test.That(t, err, test.ShouldBeNil)
+		visible, score := IsBoardVisible(input)
+		test.That(t, visible, test.ShouldBeTrue)
+		test.That(t, score, test.ShouldBeGreaterThan, .9)
+
+		// farther away and darker too
+		visible, _ = IsBoardVisible(downscale(input, 3))
+		test.That(t, visible, test.ShouldBeTrue)
+		visible, _ = IsBoardVisible(withExposure(input, .4))
+		test.That(t, visible, test.ShouldBeTrue)
+	}
+
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	visible, score := IsBoardVisible(emptyTable(input, 300))
+	test.That(t, visible, test.ShouldBeFalse)
+	test.That(t, score, test.ShouldBeLessThan, .2)
+
+	// the arm parked over the board
+	arm := image.NewRGBA(input.Bounds())
+	draw.Draw(arm, arm.Bounds(), input, image.Point{}, draw.Src)
+	draw.Draw(arm, image.Rect(200, 0, 1100, 720), image.NewUniform(color.RGBA{40, 40, 45, 255}), image.Point{}, draw.Src)
+	visible, _ = IsBoardVisible(arm)
+	test.That(t, visible, test.ShouldBeFalse)
+
+	visible, score = IsBoardVisible(image.NewRGBA(image.Rect(0, 0, 640, 480)))
+	test.That(t, visible, test.ShouldBeFalse)
+	test.That(t, score, test.ShouldEqual, 0)
+}
+
+func TestCaptureNotVisible(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	table := emptyTable(input, 250)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	// the last analysis stands in while the board's out of view
+	frame = table
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["not_visible"], test.ShouldBeTrue)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	test.That(t, ret.Image, test.ShouldEqual, image.Image(input))
+
+	res, err := pf.DoCommand(ctx, map[string]interface{}{"visible": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["visible"], test.ShouldBeFalse)
+	test.That(t, res["score"], test.ShouldBeLessThan, minVisibleScore)
+	test.That(t, res["last_capture_score"], test.ShouldEqual, res["score"])
+
+	// but not for longer than max-age captures
+	for i := 1; i < defaultMaxAge; i++ {
+		_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, errors.Is(err, errBoardOccluded), test.ShouldBeTrue)
+
+	// with nothing to fall back on it's the capture's error
+	pf = newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, errors.Is(err, errBoardNotVisible), test.ShouldBeTrue)
+
+	// unless it's turned off and the board finder has a go at it, and finds something
+	pf = newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MinVisibleScore: -1}, &frame, &pc)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, errors.Is(err, errBoardNotVisible), test.ShouldBeFalse)
+	test.That(t, pf.visible, test.ShouldBeNil)
+}
@@ -0,0 +1,200 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// boardZeroMaxMove is how far (pixels) a corner of the board can be from where it was when
+// calibrate_board was run before the calibration is taken to be for some other spot.
+const boardZeroMaxMove = 10.0
+
+// boardZero is what calibrate_board measured of the empty board: the depth (mm) of the middle
+// of each square's surface, the zero a piece's height goes from, and where the board was.
+type boardZero struct {
+	Corners [][]int            `json:"corners"` // TL, TR, BR, BL
+	Squares map[string]float64 `json:"squares"` // by name
+	At      time.Time          `json:"at"`
+}
+
+// boardZeroFile is where a piece finder called name keeps its boardZero, in the module's data
+// directory.
+func boardZeroFile(name string) string {
+	return os.Getenv("VIAM_MODULE_DATA") + name + "-board.json"
+}
+
+// readBoardZero is the boardZero saved in fn, nil if there isn't one.
+func readBoardZero(fn string) (*boardZero, error) {
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	z := &boardZero{}
+	if err := json.Unmarshal(data, z); err != nil {
+		return nil, fmt.Errorf("bad board calibration in %s: %w", fn, err)
+	}
+	if err := validateCorners(z.Corners); err != nil || z.Corners == nil {
+		return nil, fmt.Errorf("bad board calibration in %s: no corners", fn)
+	}
+	return z, nil
+}
+
+func (z *boardZero) save(fn string) error {
+	data, err := json.MarshalIndent(z, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, 0666)
+}
+
+// moved is how far (pixels) the corner of board furthest from where it was calibrated is.
+func (z *boardZero) moved(board BoardCorners) float64 {
+	far := 0.0
+	for i, c := range board.Slice() {
+		was := configCorners(z.Corners).Slice()[i]
+		far = max(far, math.Hypot(float64(c.X-was.X), float64(c.Y-was.Y)))
+	}
+	return far
+}
+
+// zeroedConf is conf without its board calibration when board has moved too far from it since,
+// which is logged to logger so it gets run again.
+func zeroedConf(logger logging.Logger, conf *PieceFinderConfig, board BoardCorners) *PieceFinderConfig {
+	if conf.zero == nil {
+		return conf
+	}
+	if moved := conf.zero.moved(board); moved > boardZeroMaxMove {
+		logger.Warnf("the board has moved %.0f pixels since calibrate_board, fitting its surface instead until it's run again", moved)
+		stale := *conf
+		stale.zero = nil
+		return &stale
+	}
+	return conf
+}
+
+// withZero is s for a square whose empty surface was at depth zero (mm): a point's height is
+// how much nearer the camera it is than that.
+func (s boardSurface) withZero(zero float64) boardSurface {
+	s.zero, s.zeroed = zero, true
+	return s
+}
+
+// medianDepth is the median Z (mm) of pc's points.
+func medianDepth(pc pointcloud.PointCloud) float64 {
+	zs := make([]float64, 0, pc.Size())
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		zs = append(zs, p.Z)
+		return true
+	})
+	if len(zs) == 0 {
+		return 0
+	}
+	slices.Sort(zs)
+	return zs[len(zs)/2]
+}
+
+// measureBoardZero is the boardZero of squares, an empty board's, at board.
+func measureBoardZero(squares []squareInfo, board BoardCorners) (*boardZero, error) {
+	occupied := []string{}
+	for _, sq := range squares {
+		if sq.color != 0 {
+			occupied = append(occupied, sq.name)
+		}
+	}
+	if len(occupied) > 0 {
+		return nil, fmt.Errorf("calibrate_board needs the board empty, there's something on %s", strings.Join(occupied, ", "))
+	}
+	z := &boardZero{Corners: roundedCornersOf(board), Squares: map[string]float64{}, At: time.Now()}
+	for _, sq := range squares {
+		z.Squares[sq.name] = medianDepth(sq.pc)
+	}
+	return z, nil
+}
+
+// roundedCornersOf is board the way the corners config has it.
+func roundedCornersOf(board BoardCorners) [][]int {
+	corners := [][]int{}
+	for _, c := range board.Slice() {
+		corners = append(corners, []int{c.X, c.Y})
+	}
+	return corners
+}
+
+// calibrateBoard is {"calibrate_board": true}: with the board empty, each square's surface depth
+// is measured and saved, and from then on is what the heights of the pieces on it go from, until
+// the board moves.
+func (bc *PieceFinder) calibrateBoard(ctx context.Context) (map[string]interface{}, error) {
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	bc.captureLock.Lock()
+	conf := bc.captureConf()
+	bc.captureLock.Unlock()
+	// measured against the fit surface, not the last calibration
+	conf.zero = nil
+	opts, err := conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	var board BoardCorners
+	if conf.Corners != nil {
+		board, err = pinnedBoard(conf.Corners, img)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res := findCorners(ctx, nil, img, pc, bc.props, conf, opts)
+		if !res.Found {
+			return nil, fmt.Errorf("board not found (%s)", res.Reason)
+		}
+		board = res.Board
+	}
+	squares, err := squaresOnBoard(nil, board, [4]bool{}, img, pc, bc.props, conf, opts.gridSize())
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("calibrate_board", squares); err != nil {
+		return nil, err
+	}
+	z, err := measureBoardZero(squares, board)
+	if err != nil {
+		return nil, err
+	}
+	if err := z.save(bc.zeroFile); err != nil {
+		return nil, err
+	}
+
+	bc.captureLock.Lock()
+	bc.zero = z
+	// the last analysis went by the old zero
+	bc.last = nil
+	bc.captureLock.Unlock()
+
+	depths := map[string]interface{}{}
+	for name, d := range z.Squares {
+		depths[name] = d
+	}
+	return map[string]interface{}{"calibrated": true, "file": bc.zeroFile, "squares": depths, "corners": cornersJSON(z.Corners)}, nil
+}
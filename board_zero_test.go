@@ -0,0 +1,123 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// tiltedBoardCorners are where tiltedBoard's board is, 60 pixel squares.
+var tiltedBoardCorners = [][]int{{400, 150}, {880, 150}, {880, 630}, {400, 630}}
+
+// tiltedBoard is a RealSense cloud of a board tilted away from the camera, 600mm off at the
+// middle of the frame and a tenth of a mm further for every mm down it, with a 40mm white
+// piece in the middle of each of the pieces squares (by col and row from the top left).
+func tiltedBoard(t *testing.T, pieces ...image.Point) pointcloud.PointCloud {
+	t.Helper()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	pc := pointcloud.NewBasicEmpty()
+	board := pointcloud.NewColoredData(color.NRGBA{120, 120, 120, 255})
+	white := pointcloud.NewColoredData(color.NRGBA{240, 240, 240, 255})
+	for v := 140; v < 640; v += 2 {
+		for u := 390; u < 890; u += 2 {
+			x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+			z := 600 / (1 - .1*y)
+			d := board
+			col, row := (u-400)/60, (v-150)/60
+			inX, inY := (u-400)%60, (v-150)%60
+			for _, p := range pieces {
+				if p.X == col && p.Y == row && inX >= 15 && inX < 45 && inY >= 15 && inY < 45 {
+					z -= 40
+					d = white
+				}
+			}
+			test.That(t, pc.Set(r3.Vector{X: x * z, Y: y * z, Z: z}, d), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+func TestCalibrateBoardTilted(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := tiltedBoard(t)
+	// a blank frame, with the board pinned where it is
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+	pf.zeroFile = filepath.Join(t.TempDir(), "pf-board.json")
+
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"calibrate_board": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["calibrated"], test.ShouldBeTrue)
+	depths := ret["squares"].(map[string]interface{})
+	test.That(t, len(depths), test.ShouldEqual, 64)
+	// the far side of the board is a good bit further off than the near one
+	lo, hi := 1e9, 0.0
+	for _, d := range depths {
+		lo, hi = min(lo, d.(float64)), max(hi, d.(float64))
+	}
+	test.That(t, hi-lo, test.ShouldBeGreaterThan, 20)
+
+	// it's kept, and read back by the next one
+	saved, err := readBoardZero(pf.zeroFile)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, saved.Squares, test.ShouldResemble, pf.zero.Squares)
+	test.That(t, saved.Corners, test.ShouldResemble, tiltedBoardCorners)
+	missing, err := readBoardZero(filepath.Join(t.TempDir(), "none.json"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, missing, test.ShouldBeNil)
+
+	// pieces on the near and far sides are found and measured the same
+	pc = tiltedBoard(t, image.Point{0, 0}, image.Point{7, 7}, image.Point{3, 4})
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	occupied := 0
+	for _, o := range all.Objects {
+		if !strings.HasSuffix(o.Geometry.Label(), "-0") {
+			occupied++
+		}
+	}
+	test.That(t, occupied, test.ShouldEqual, 3)
+	for _, sq := range pf.last.squares {
+		if sq.color != 0 {
+			test.That(t, sq.heightMM, test.ShouldBeBetween, 35, 45)
+		}
+	}
+
+	// with pieces on it, it can't be calibrated
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"calibrate_board": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "empty")
+}
+
+func TestBoardZeroStale(t *testing.T) {
+	z := &boardZero{Corners: tiltedBoardCorners, Squares: map[string]float64{"a1": 600}}
+	conf := &PieceFinderConfig{zero: z}
+	logger := logging.NewTestLogger(t)
+
+	board := configCorners(tiltedBoardCorners)
+	test.That(t, z.moved(board), test.ShouldEqual, 0)
+	test.That(t, zeroedConf(logger, conf, board).zero, test.ShouldEqual, z)
+
+	// a few pixels of jitter is still the same spot
+	board.TopLeft.X += 4
+	test.That(t, zeroedConf(logger, conf, board).zero, test.ShouldEqual, z)
+
+	// a board moved 20 pixels isn't, and goes back to the fit surface
+	board.BottomRight.Y += 20
+	test.That(t, z.moved(board), test.ShouldEqual, 20)
+	test.That(t, zeroedConf(logger, conf, board).zero, test.ShouldBeNil)
+	test.That(t, conf.zero, test.ShouldEqual, z)
+}
@@ -0,0 +1,181 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+const defaultCallBudgetMillis = 60000
+
+// callPhase is what kind of dependency call a command is waiting on.
+type callPhase string
+
+const (
+	phaseCapture callPhase = "capture" // the piece finder
+	phasePose    callPhase = "pose"    // frame system queries
+	phaseMotion  callPhase = "motion"  // each leg the arm moves
+	phaseGripper callPhase = "gripper" // opening, closing and asking the gripper
+)
+
+// phaseShares is how much of the call budget a single call of each phase gets.
+var phaseShares = map[callPhase]float64{
+	phaseCapture: .1,
+	phasePose:    .05,
+	phaseMotion:  .2,
+	phaseGripper: .05,
+}
+
+// callTimeout is how long one call of phase gets before it's given up on.
+func (cfg *ChessConfig) callTimeout(phase callPhase) time.Duration {
+	budget := cfg.CallBudgetMillis
+	if budget <= 0 {
+		budget = defaultCallBudgetMillis
+	}
+	return time.Duration(float64(budget)*phaseShares[phase]) * time.Millisecond
+}
+
+// callTimeoutError is a dependency call that didn't come back in its slice of the budget.
+type callTimeoutError struct {
+	phase   callPhase
+	timeout time.Duration
+	err     error
+}
+
+func (e *callTimeoutError) Error() string {
+	return fmt.Sprintf("%s call timed out after %v: %v", e.phase, e.timeout, e.err)
+}
+
+func (e *callTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// phaseStats is how the calls of one phase have gone since startup.
+type phaseStats struct {
+	calls, timeouts int
+	total, max      time.Duration
+	last            time.Duration
+}
+
+// callStats are phaseStats for every phase, safe to use from any goroutine.
+type callStats struct {
+	mu     sync.Mutex
+	phases map[callPhase]*phaseStats
+}
+
+func (cs *callStats) record(phase callPhase, d time.Duration, timedOut bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.phases == nil {
+		cs.phases = map[callPhase]*phaseStats{}
+	}
+	p := cs.phases[phase]
+	if p == nil {
+		p = &phaseStats{}
+		cs.phases[phase] = p
+	}
+	p.calls++
+	if timedOut {
+		p.timeouts++
+	}
+	p.total += d
+	p.max = max(p.max, d)
+	p.last = d
+}
+
+// status is the stats in milliseconds, keyed by phase.
+func (cs *callStats) status() map[string]interface{} {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	res := map[string]interface{}{}
+	for phase, p := range cs.phases {
+		res[string(phase)] = map[string]interface{}{
+			"calls":    p.calls,
+			"timeouts": p.timeouts,
+			"avg_ms":   p.total.Milliseconds() / int64(p.calls),
+			"max_ms":   p.max.Milliseconds(),
+			"last_ms":  p.last.Milliseconds(),
+		}
+	}
+	return res
+}
+
+// callWithTimeout runs fn with its phase's slice of the call budget and records how long it
+// took. Running out of time is a *callTimeoutError, the caller's own ctx ending isn't.
+func callWithTimeout[T any](ctx context.Context, s *viamChessChess, phase callPhase, fn func(context.Context) (T, error)) (T, error) {
+	timeout := s.conf.callTimeout(phase)
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := fn(callCtx)
+	timedOut := err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded)
+	s.calls.record(phase, time.Since(start), timedOut)
+	if timedOut {
+		return res, &callTimeoutError{phase: phase, timeout: timeout, err: err}
+	}
+	return res, err
+}
+
+// callWithTimeoutErr is callWithTimeout for calls that only return an error.
+func callWithTimeoutErr(ctx context.Context, s *viamChessChess, phase callPhase, fn func(context.Context) error) error {
+	_, err := callWithTimeout(ctx, s, phase, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// capture is the piece finder's view of the board, within the capture slice of the budget.
+func (s *viamChessChess) capture(ctx context.Context) (viscapture.VisCapture, error) {
+	all, err := callWithTimeout(ctx, s, phaseCapture, func(ctx context.Context) (viscapture.VisCapture, error) {
+		extra := map[string]interface{}{}
+		if s.hasGripperFrame(ctx) {
+			// the gripper is often over the board, it isn't a piece
+			extra["exclude_frames"] = []interface{}{s.conf.Gripper}
+		}
+		return s.pieceFinder.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, extra)
+	})
+	if err != nil {
+		return all, err
+	}
+	s.statusLock.Lock()
+	s.anomalies = captureAnomalies(all.Extra)
+	s.statusLock.Unlock()
+	return all, checkChessGrid(all)
+}
+
+// hasGripperFrame is whether the frame system has the gripper's frame for the piece finder to
+// cut out of its point cloud. It's looked up until the frame system answers, and said once when
+// it isn't there, instead of the piece finder warning about it on every capture.
+func (s *viamChessChess) hasGripperFrame(ctx context.Context) bool {
+	if has := s.gripperFrame.Load(); has != nil {
+		return *has
+	}
+	if s.rfs == nil {
+		return false
+	}
+	fsConf, err := s.rfs.FrameSystemConfig(ctx)
+	if err != nil {
+		// only an answer is kept, the next capture looks again
+		if ctx.Err() == nil {
+			s.logger.Debugf("can't look for %s in the frame system, this capture won't exclude it: %v", s.conf.Gripper, err)
+		}
+		return false
+	}
+	has := false
+	for _, part := range fsConf.Parts {
+		if part.FrameConfig != nil && part.FrameConfig.Name() == s.conf.Gripper {
+			has = true
+			break
+		}
+	}
+	if !has {
+		s.logger.Warnf("%s isn't in the frame system, captures won't exclude it", s.conf.Gripper)
+	}
+	s.gripperFrame.Store(&has)
+	return has
+}
@@ -0,0 +1,56 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+)
+
+func TestCallTimeout(t *testing.T) {
+	cfg := &ChessConfig{}
+	test.That(t, cfg.callTimeout(phaseMotion), test.ShouldEqual, 12*time.Second)
+	cfg.CallBudgetMillis = 1000
+	test.That(t, cfg.callTimeout(phaseCapture), test.ShouldEqual, 100*time.Millisecond)
+	test.That(t, cfg.callTimeout(phasePose), test.ShouldEqual, 50*time.Millisecond)
+}
+
+func TestHungFrameSystem(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+	s.conf.CallBudgetMillis = 1000
+
+	rfs := inject.NewFrameSystemService("fs")
+	rfs.GetPoseFunc = func(ctx context.Context, componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame, extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	s.rfs = rfs
+
+	start := time.Now()
+	_, err := s.DoCommand(ctx, map[string]interface{}{
+		"move": map[string]interface{}{"from": "e2", "to": "e4", "n": 1},
+	})
+	took := time.Since(start)
+
+	var timeout *callTimeoutError
+	test.That(t, errors.As(err, &timeout), test.ShouldBeTrue)
+	test.That(t, timeout.phase, test.ShouldEqual, phasePose)
+	test.That(t, timeout.timeout, test.ShouldEqual, 50*time.Millisecond)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "pose call timed out after 50ms")
+	// the move's own goToStart and the one after it, not forever
+	test.That(t, took, test.ShouldBeLessThan, 5*time.Second)
+	test.That(t, f.indexOf("grab"), test.ShouldEqual, -1)
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	pose := res["calls"].(map[string]interface{})["pose"].(map[string]interface{})
+	test.That(t, pose["timeouts"], test.ShouldBeGreaterThanOrEqualTo, 2)
+	test.That(t, pose["max_ms"], test.ShouldBeGreaterThanOrEqualTo, 50)
+}
@@ -0,0 +1,185 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// The roles of a FusedCamera.
+const (
+	RoleOverhead = "overhead" // the input camera, which says what's on each square
+	RoleSide     = "side"     // one that measures the pieces on the squares it can see
+)
+
+const (
+	// sideVisibleShare is how much of a square's points have to be its own surface, no more than
+	// the surface band up, for a side camera to see it rather than the piece in front of it.
+	sideVisibleShare = .25
+	// fusionConflictFactor is what a square's confidence is multiplied by for each side camera
+	// that disagrees with the overhead one about whether there's a piece on it.
+	fusionConflictFactor = .5
+)
+
+// FusedCamera is one of the cameras the piece finder looks at the board with, see
+// PieceFinderConfig.Cameras.
+type FusedCamera struct {
+	Name string `json:"name"`
+	Role string `json:"role"` // RoleOverhead or RoleSide
+	// Corners are where the board's TL, TR, BR and BL corners are in a side camera's frame, the
+	// way the corners config has them, and Rotation where white sits in it; the board isn't
+	// looked for from the side.
+	Corners  [][]int `json:"corners"`
+	Rotation string  `json:"rotation"`
+}
+
+func validateCameras(input string, cameras []FusedCamera) error {
+	for i, c := range cameras {
+		switch c.Role {
+		case RoleOverhead:
+			if c.Name != input {
+				return fmt.Errorf("cameras %d: the overhead camera is the input, %q, not %q", i, input, c.Name)
+			}
+		case RoleSide:
+			if c.Name == "" || c.Name == input {
+				return fmt.Errorf("cameras %d: a side camera needs a name of its own", i)
+			}
+			if c.Corners == nil {
+				return fmt.Errorf("cameras %d: side camera %s needs the board's corners", i, c.Name)
+			}
+			if err := validateCorners(c.Corners); err != nil {
+				return fmt.Errorf("cameras %d: %w", i, err)
+			}
+			if _, err := ParseBoardRotation(c.Rotation); err != nil {
+				return fmt.Errorf("cameras %d: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("cameras %d: role has to be %q or %q, got %q", i, RoleOverhead, RoleSide, c.Role)
+		}
+	}
+	return nil
+}
+
+// sideCameraNames are the names of the side cameras of cameras, for the dependencies.
+func sideCameraNames(cameras []FusedCamera) []string {
+	names := []string{}
+	for _, c := range cameras {
+		if c.Role == RoleSide {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// sideCamera is a RoleSide camera and what it last made of the squares.
+type sideCamera struct {
+	conf    FusedCamera
+	cam     camera.Camera
+	props   camera.Properties
+	squares []squareInfo // reused between captures like PieceFinder.squares
+}
+
+// sideSquares is what side sees on the squares, found the way the input's are with conf but at
+// its own corners and rotation.
+func (bc *PieceFinder) sideSquares(ctx context.Context, side *sideCamera, conf *PieceFinderConfig) ([]squareInfo, error) {
+	ni, _, err := side.cam.Images(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(ni) == 0 {
+		return nil, fmt.Errorf("no images returned from %s", side.conf.Name)
+	}
+	img, err := ni[0].Image(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := side.cam.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pc == nil || pc.Size() == 0 {
+		return nil, fmt.Errorf("no point cloud from %s", side.conf.Name)
+	}
+
+	sc := *conf
+	sc.Corners = side.conf.Corners
+	sc.Rotation = side.conf.Rotation
+	// the calibrations are the input's
+	sc.zero = nil
+	sc.colorBaseline = nil
+	side.squares, err = findBoardAndPiecesInto(ctx, bc.logger, side.squares, nil, nil, img, pc, side.props, &sc)
+	return side.squares, err
+}
+
+// fuseSides looks at the board with each of the side cameras too and fuses what they see into
+// bc.squares, see fuseSquares. One that can't, for whatever reason, is logged and left out.
+func (bc *PieceFinder) fuseSides(ctx context.Context, conf *PieceFinderConfig) error {
+	for i := range bc.squares {
+		bc.squares[i].cameras = []string{bc.conf.Input}
+		bc.squares[i].measuredBy = bc.conf.Input
+	}
+	for _, side := range bc.sides {
+		squares, err := bc.sideSquares(ctx, side, conf)
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			bc.logger.Warnf("going without side camera %s: %v", side.conf.Name, err)
+			continue
+		}
+		if len(squares) != len(bc.squares) {
+			bc.logger.Warnf("going without side camera %s, it sees %d squares", side.conf.Name, len(squares))
+			continue
+		}
+		fuseSquares(bc.squares, side.conf.Name, squares, conf)
+	}
+	return nil
+}
+
+// fuseSquares fuses into squares, the overhead camera's, what the side camera called name sees
+// on them, sides, in the same order. On each square it can see (see sideVisible) the overhead
+// camera still says whether there's a piece and its color, but when the side camera says
+// otherwise the square's confidence is cut by fusionConflictFactor. When they agree there's a
+// piece, its height, footprint and type are the first side camera's to see it.
+func fuseSquares(squares []squareInfo, name string, sides []squareInfo, conf *PieceFinderConfig) {
+	surface := squaresSurface(sides)
+	for i := range squares {
+		sq, side := &squares[i], sides[i]
+		if !sideVisible(side, surface, conf) {
+			continue
+		}
+		sq.cameras = append(sq.cameras, name)
+		if (side.color != 0) != (sq.color != 0) {
+			sq.confidence *= fusionConflictFactor
+			continue
+		}
+		if sq.color == 0 || sq.measuredBy != sq.cameras[0] {
+			continue
+		}
+		sq.heightMM, sq.footprintMM, sq.fallen = side.heightMM, side.footprintMM, side.fallen
+		sq.pieceType, sq.typeConfidence = side.pieceType, side.typeConfidence
+		sq.measuredBy = name
+	}
+}
+
+// sideVisible is whether a side camera sees sq, its square, rather than something in front of
+// it: at least sideVisibleShare of its points are the surface of the board, surface.
+func sideVisible(sq squareInfo, surface boardSurface, conf *PieceFinderConfig) bool {
+	if sq.source != sourceDepth || sq.pc == nil || sq.pc.Size() == 0 {
+		return false
+	}
+	height := surface.heights(sq.pc)
+	band := conf.surfaceBand()
+	floor := 0
+	sq.pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if height(p) <= band {
+			floor++
+		}
+		return true
+	})
+	return float64(floor) >= sideVisibleShare*float64(sq.pc.Size())
+}
@@ -0,0 +1,121 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// newTestSide is a side camera called name whose frame is blank and cloud whatever *pc points
+// at, seeing the board the way the input does in boxesBoard.
+func newTestSide(name string, pc *pointcloud.PointCloud) *sideCamera {
+	cam := inject.NewCamera(name)
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{},
+	) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		ni, err := camera.NamedImageFromImage(image.NewRGBA(image.Rect(0, 0, 1280, 720)), "color", "image/png", data.Annotations{})
+		return []camera.NamedImage{ni}, resource.ResponseMetadata{}, err
+	}
+	cam.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return *pc, nil
+	}
+	return &sideCamera{
+		conf:  FusedCamera{Name: name, Role: RoleSide, Corners: tiltedBoardCorners, Rotation: "0"},
+		cam:   cam,
+		props: touch.RealSenseProperties,
+	}
+}
+
+func TestFuseSideCamera(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	// the overhead camera sees pieces on d4 and e4
+	pc := boxesBoard(t,
+		raisedBox{image.Rect(655, 345, 685, 375), 40},
+		raisedBox{image.Rect(595, 345, 625, 375), 40},
+	)
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	alone := lastSquare(t, pf, "e4")
+	test.That(t, alone.color, test.ShouldEqual, 1)
+	test.That(t, alone.cameras, test.ShouldBeNil)
+
+	// the side camera sees the d4 piece taller, nothing on e4, and c4 behind something as big
+	// as it
+	side := boxesBoard(t,
+		raisedBox{image.Rect(655, 345, 685, 375), 50},
+		raisedBox{image.Rect(700, 330, 760, 390), 40},
+	)
+	pf.sides = []*sideCamera{newTestSide("side", &side)}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	d4 := lastSquare(t, pf, "d4")
+	test.That(t, d4.color, test.ShouldEqual, 1)
+	test.That(t, d4.cameras, test.ShouldResemble, []string{"cam", "side"})
+	test.That(t, d4.measuredBy, test.ShouldEqual, "side")
+	test.That(t, d4.heightMM, test.ShouldAlmostEqual, 50, 2)
+
+	// they disagree on e4, it's still the overhead camera's piece but less sure
+	e4 := lastSquare(t, pf, "e4")
+	test.That(t, e4.color, test.ShouldEqual, 1)
+	test.That(t, e4.cameras, test.ShouldResemble, []string{"cam", "side"})
+	test.That(t, e4.measuredBy, test.ShouldEqual, "cam")
+	test.That(t, e4.heightMM, test.ShouldAlmostEqual, alone.heightMM)
+	test.That(t, e4.confidence, test.ShouldAlmostEqual, alone.confidence*fusionConflictFactor)
+
+	// it can't see c4, so it doesn't count there
+	c4 := lastSquare(t, pf, "c4")
+	test.That(t, c4.color, test.ShouldEqual, 0)
+	test.That(t, c4.cameras, test.ShouldResemble, []string{"cam"})
+
+	// and the overhead camera has the last word on the empty ones
+	a1 := lastSquare(t, pf, "a1")
+	test.That(t, a1.cameras, test.ShouldResemble, []string{"cam", "side"})
+	test.That(t, a1.confidence, test.ShouldAlmostEqual, lastSquareAlone(t, conf, &frame, &pc, "a1").confidence)
+
+	// a side camera that can't see the board is left out
+	empty := pointcloud.PointCloud(pointcloud.NewBasicEmpty())
+	pf.sides = []*sideCamera{newTestSide("side", &empty)}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	e4 = lastSquare(t, pf, "e4")
+	test.That(t, e4.cameras, test.ShouldResemble, []string{"cam"})
+	test.That(t, e4.confidence, test.ShouldAlmostEqual, alone.confidence)
+}
+
+// lastSquareAlone is square name of a capture with conf by only the input camera.
+func lastSquareAlone(t *testing.T, conf *PieceFinderConfig, frame *image.Image, pc *pointcloud.PointCloud, name string) squareInfo {
+	t.Helper()
+	pf := newTestPieceFinder(t, conf, frame, pc)
+	pf.pinned = conf.Corners
+	_, err := pf.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	return lastSquare(t, pf, name)
+}
+
+func TestValidateCameras(t *testing.T) {
+	side := FusedCamera{Name: "side", Role: RoleSide, Corners: tiltedBoardCorners, Rotation: "0"}
+	test.That(t, validateCameras("cam", nil), test.ShouldBeNil)
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "cam", Role: RoleOverhead}, side}), test.ShouldBeNil)
+	test.That(t, sideCameraNames([]FusedCamera{{Name: "cam", Role: RoleOverhead}, side}), test.ShouldResemble, []string{"side"})
+
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "other", Role: RoleOverhead}}), test.ShouldNotBeNil)
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "side", Role: "behind"}}), test.ShouldNotBeNil)
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "side", Role: RoleSide, Rotation: "0"}}), test.ShouldNotBeNil)
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "cam", Role: RoleSide, Corners: tiltedBoardCorners}}), test.ShouldNotBeNil)
+	test.That(t, validateCameras("cam", []FusedCamera{{Name: "side", Role: RoleSide, Corners: tiltedBoardCorners, Rotation: "auto"}}), test.ShouldNotBeNil)
+}
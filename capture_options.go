@@ -0,0 +1,149 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// captureWants is whether opts asks for a capture's objects and its detections. Asking for
+// nothing, the way the piece finder's own calls do, gets what it always has: the image, the
+// objects and the detections.
+func captureWants(opts viscapture.CaptureOptions) (objects, detections bool) {
+	if opts == (viscapture.CaptureOptions{}) {
+		return true, true
+	}
+	return opts.ReturnObject, opts.ReturnDetections
+}
+
+// serveLast puts what opts asks for of last, an analysis, in ret, first making the objects or
+// detections of it that no capture has asked for yet.
+func (bc *PieceFinder) serveLast(ctx context.Context, ret *viscapture.VisCapture, last *lastAnalysis, opts viscapture.CaptureOptions) error {
+	objects, detections := captureWants(opts)
+	var err error
+	if objects && last.objects == nil {
+		last.objects, last.worldPoses, err = bc.captureObjects(ctx, last.squares, last.graveyard)
+		if err != nil {
+			return err
+		}
+	}
+	if detections && last.detections == nil {
+		last.detections = bc.captureDetections(last.squares)
+	}
+
+	ret.Objects, ret.Detections = nil, nil
+	if objects {
+		ret.Objects = append([]*viz.Object(nil), last.objects...)
+	}
+	if detections {
+		ret.Detections = append([]objectdetection.Detection(nil), last.detections...)
+	}
+	if opts.ReturnClassifications {
+		ret.Classifications = pieceClassifications(last.squares, 0)
+	}
+	if opts != (viscapture.CaptureOptions{}) && !opts.ReturnImage {
+		ret.Image = nil
+	}
+	return nil
+}
+
+// captureObjects is an object for each of squares, its points in the world labeled as
+// labelCache has it, and the box of its piece where piecePoses has one, and then the
+// graveyard's, and whether the pieces are posed in the world.
+func (bc *PieceFinder) captureObjects(ctx context.Context, squares []squareInfo, graveyard []graveyardPiece) ([]*viz.Object, bool, error) {
+	var poses map[int]piecePose
+	worldPoses := false
+	if bc.conf.CameraFrame != "" {
+		var err error
+		poses, worldPoses, err = bc.piecePoses(ctx, squares)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	objects := make([]*viz.Object, 0, len(squares)+len(graveyard))
+	for idx, s := range squares {
+		label := bc.labels.label(idx, s.name, s.color)
+		if s.source == sourceImage {
+			// nowhere in the world to put it, only its label
+			objects = append(objects, &viz.Object{
+				PointCloud: pointcloud.NewBasicEmpty(),
+				Geometry:   spatialmath.NewPoint(r3.Vector{}, label),
+			})
+			continue
+		}
+		pc := s.pc
+		if bc.rfs != nil {
+			var err error
+			pc, err = bc.rfs.TransformPointCloud(ctx, s.pc, bc.conf.cameraFrame(), "world")
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
+		if pc == nil {
+			return nil, false, fmt.Errorf("why is pc nil")
+		}
+
+		o, err := viz.NewObjectWithLabel(pc, label, nil)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if o.Geometry == nil {
+			return nil, false, fmt.Errorf("why is Geometry nil for square: %s %v", s.name, s)
+		}
+		if p, ok := poses[idx]; ok {
+			// the piece itself, where motion can take the gripper
+			o.Geometry, err = spatialmath.NewBox(p.pose, p.dims, label)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		objects = append(objects, o)
+	}
+
+	dead, err := bc.graveyardObjects(ctx, graveyard)
+	if err != nil {
+		return nil, false, err
+	}
+	return append(objects, dead...), worldPoses, nil
+}
+
+// captureDetections is a detection of each of squares' bounds, labeled as its object is, and of
+// one with points a 10 pixel box, "x-" and its label, around where the lowest of them is in the
+// frame.
+func (bc *PieceFinder) captureDetections(squares []squareInfo) []objectdetection.Detection {
+	detections := make([]objectdetection.Detection, 0, 2*len(squares))
+	for idx, s := range squares {
+		label := bc.labels.label(idx, s.name, s.color)
+		detections = append(detections, objectdetection.NewDetectionWithoutImgBounds(s.originalBounds, 1, label))
+		if s.source == sourceImage {
+			continue
+		}
+
+		lowPoint := touch.PCFindLowestInRegion(s.pc, image.Rect(-10000, -10000, 10000, 10000))
+
+		lowX, lowY := bc.props.IntrinsicParams.PointToPixel(lowPoint.X, lowPoint.Y, lowPoint.Z)
+
+		detections = append(detections,
+			objectdetection.NewDetectionWithoutImgBounds(
+				image.Rect(
+					int(lowX-5),
+					int(lowY-5),
+					int(lowX+5),
+					int(lowY+5),
+				),
+				1, "x-"+label))
+	}
+	return detections
+}
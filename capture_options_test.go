@@ -0,0 +1,99 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestPropertiesFollowTheInput(t *testing.T) {
+	ctx := context.Background()
+	depth, rgb := touch.RealSenseProperties, touch.RealSenseProperties
+	depth.SupportsPCD = true
+	deps := resource.Dependencies{}
+	for name, props := range map[string]camera.Properties{"depth": depth, "rgb": rgb} {
+		cam := inject.NewCamera(name)
+		cam.PropertiesFunc = func(ctx context.Context) (camera.Properties, error) {
+			return props, nil
+		}
+		deps[camera.Named(name)] = cam
+	}
+
+	properties := func(input string) *vision.Properties {
+		pf, err := NewPieceFinder(ctx, deps, vision.Named("pf"), &PieceFinderConfig{Input: input}, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeNil)
+		props, err := pf.GetProperties(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		return props
+	}
+	test.That(t, properties("depth"), test.ShouldResemble, &vision.Properties{
+		ClassificationSupported: true, DetectionSupported: true, ObjectPCDsSupported: true,
+	})
+	// from the frame alone there are still pieces to classify and box, but no points
+	test.That(t, properties("rgb"), test.ShouldResemble, &vision.Properties{
+		ClassificationSupported: true, DetectionSupported: true, ObjectPCDsSupported: false,
+	})
+}
+
+func TestCaptureOptions(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := boxesBoard(t, raisedBox{image.Rect(595, 345, 625, 375), 40})
+	newFinder := func() *PieceFinder {
+		conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1}
+		pf := newTestPieceFinder(t, conf, &frame, &pc)
+		pf.pinned = conf.Corners
+		return pf
+	}
+	full, err := newFinder().CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, full.Image, test.ShouldNotBeNil)
+	test.That(t, len(full.Objects), test.ShouldEqual, 64)
+	test.That(t, len(full.Detections), test.ShouldEqual, 128)
+	test.That(t, full.Classifications, test.ShouldBeNil)
+
+	// only the classifications, the objects and detections aren't made
+	pf := newFinder()
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{ReturnClassifications: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Image, test.ShouldBeNil)
+	test.That(t, ret.Objects, test.ShouldBeNil)
+	test.That(t, ret.Detections, test.ShouldBeNil)
+	test.That(t, len(ret.Classifications), test.ShouldEqual, 64)
+	test.That(t, ret.Classifications, test.ShouldResemble, pieceClassifications(pf.last.squares, 0))
+	test.That(t, ret.Extra["unchanged"], test.ShouldBeFalse)
+	test.That(t, pf.last.objects, test.ShouldBeNil)
+	test.That(t, pf.last.detections, test.ShouldBeNil)
+
+	// the same frame again wanting the detections has them made then, as they would have been
+	ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{ReturnDetections: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["unchanged"], test.ShouldBeTrue)
+	test.That(t, ret.Objects, test.ShouldBeNil)
+	test.That(t, ret.Classifications, test.ShouldBeNil)
+	test.That(t, ret.Detections, test.ShouldResemble, full.Detections)
+	test.That(t, pf.last.objects, test.ShouldBeNil)
+
+	ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{ReturnImage: true, ReturnObject: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["unchanged"], test.ShouldBeTrue)
+	test.That(t, ret.Image, test.ShouldNotBeNil)
+	test.That(t, ret.Detections, test.ShouldBeNil)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	for i, o := range ret.Objects {
+		test.That(t, o.Geometry.Label(), test.ShouldEqual, full.Objects[i].Geometry.Label())
+	}
+	e4 := objectOn(t, ret, "e4")
+	test.That(t, e4.Geometry.Label(), test.ShouldEqual, "e4-1")
+	test.That(t, e4.PointCloud.Size(), test.ShouldBeGreaterThan, 0)
+}
@@ -0,0 +1,112 @@
+package viamchess
+
+import (
+	"image"
+
+	"go.viam.com/rdk/pointcloud"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	signatureCells = 32 // per side, so 4x4 cells per square
+
+	defaultChangeThreshold = 20
+	defaultMaxAge          = 10
+
+	// changePointFraction is how much the cloud size has to change to count as a change.
+	changePointFraction = .01
+)
+
+// frameSignature is a cheap summary of a frame: the board region averaged down to a small
+// grid of gray cells, plus the number of points in the cloud.
+type frameSignature struct {
+	region image.Rectangle
+	cells  [signatureCells * signatureCells]uint8
+	points int
+}
+
+func computeFrameSignature(img image.Image, pc pointcloud.PointCloud, region image.Rectangle) frameSignature {
+	sig := frameSignature{region: region.Intersect(img.Bounds())}
+	if pc != nil {
+		sig.points = pc.Size()
+	}
+
+	r := sig.region
+	if r.Dx() < signatureCells || r.Dy() < signatureCells {
+		return sig
+	}
+
+	// sample every other pixel, plenty to average out sensor noise
+	const step = 2
+	for cy := range signatureCells {
+		y0 := r.Min.Y + cy*r.Dy()/signatureCells
+		y1 := r.Min.Y + (cy+1)*r.Dy()/signatureCells
+		for cx := range signatureCells {
+			x0 := r.Min.X + cx*r.Dx()/signatureCells
+			x1 := r.Min.X + (cx+1)*r.Dx()/signatureCells
+
+			sum, n := 0, 0
+			for y := y0; y < y1; y += step {
+				for x := x0; x < x1; x += step {
+					cr, cg, cb, _ := img.At(x, y).RGBA()
+					sum += (int(cr>>8) + int(cg>>8) + int(cb>>8)) / 3
+					n++
+				}
+			}
+			if n > 0 {
+				sig.cells[cy*signatureCells+cx] = uint8(sum / n)
+			}
+		}
+	}
+	return sig
+}
+
+// changed is true if any cell's brightness moved by more than threshold, or the cloud grew or
+// shrank by more than changePointFraction.
+func (sig *frameSignature) changed(other *frameSignature, threshold int) bool {
+	if sig.region != other.region {
+		return true
+	}
+
+	pointDelta := sig.points - other.points
+	if pointDelta < 0 {
+		pointDelta = -pointDelta
+	}
+	if float64(pointDelta) > float64(sig.points)*changePointFraction {
+		return true
+	}
+
+	for i, c := range sig.cells {
+		d := int(c) - int(other.cells[i])
+		if d > threshold || -d > threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// lastAnalysis is the most recent full run of the pipeline, handed back while frames don't change.
+type lastAnalysis struct {
+	sig        frameSignature
+	image      image.Image                 // the frame it was done on
+	objects    []*viz.Object               // nil until a capture asks for them, see serveLast
+	detections []objectdetection.Detection // the same
+	age        int                         // captures since the analysis was done
+
+	squares []squareInfo  // what it was done from
+	pieces  []*viz.Object // pieceObjects of squares, made when GetObjectPointClouds first wants them
+
+	worldPoses bool // whether objects' pieces are posed in the world frame, see piecePoses
+
+	graveyard []graveyardPiece // what's in the graveyards, see findGraveyard
+}
+
+// boardRegion is the bounding box of all the squares.
+func boardRegion(squares []squareInfo) image.Rectangle {
+	r := image.Rectangle{}
+	for _, s := range squares {
+		r = r.Union(s.originalBounds)
+	}
+	return r
+}
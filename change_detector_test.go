@@ -0,0 +1,155 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// newTestPieceFinder is a PieceFinder whose camera returns whatever *frame and *pc point at.
+func newTestPieceFinder(t *testing.T, conf *PieceFinderConfig, frame *image.Image, pc *pointcloud.PointCloud) *PieceFinder {
+	cam := inject.NewCamera(conf.Input)
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{},
+	) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		ni, err := camera.NamedImageFromImage(*frame, "color", "image/png", data.Annotations{})
+		return []camera.NamedImage{ni}, resource.ResponseMetadata{}, err
+	}
+	cam.NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return *pc, nil
+	}
+
+	rfs := inject.NewFrameSystemService("fs")
+	rfs.TransformPointCloudFunc = func(ctx context.Context, srcpc pointcloud.PointCloud, srcName, dstName string,
+	) (pointcloud.PointCloud, error) {
+		return srcpc, nil
+	}
+
+	return &PieceFinder{
+		conf:    conf,
+		logger:  logging.NewTestLogger(t),
+		input:   cam,
+		props:   touch.RealSenseProperties,
+		rfs:     rfs,
+		corners: newCornerSmoother(conf),
+	}
+}
+
+func changeFlags(t *testing.T, ret viscapture.VisCapture) (bool, int) {
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	return ret.Extra["unchanged"].(bool), ret.Extra["age"].(int)
+}
+
+func TestCaptureSkipsUnchangedFrames(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	unchanged, age := changeFlags(t, ret)
+	test.That(t, unchanged, test.ShouldBeFalse)
+	test.That(t, age, test.ShouldEqual, 0)
+	first := ret.Objects
+
+	for i := 1; i <= 3; i++ {
+		ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		unchanged, age = changeFlags(t, ret)
+		test.That(t, unchanged, test.ShouldBeTrue)
+		test.That(t, age, test.ShouldEqual, i)
+		test.That(t, ret.Objects[10], test.ShouldEqual, first[10])
+	}
+
+	// a hand over the middle of the board
+	changed := image.NewRGBA(input.Bounds())
+	draw.Draw(changed, changed.Bounds(), input, image.Point{}, draw.Src)
+	region := boardRegion(pf.squares)
+	center := image.Point{(region.Min.X + region.Max.X) / 2, (region.Min.Y + region.Max.Y) / 2}
+	draw.Draw(changed, image.Rectangle{center, center.Add(image.Point{60, 60})},
+		image.NewUniform(color.RGBA{200, 150, 120, 255}), image.Point{}, draw.Src)
+	frame = changed
+
+	ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	unchanged, age = changeFlags(t, ret)
+	test.That(t, unchanged, test.ShouldBeFalse)
+	test.That(t, age, test.ShouldEqual, 0)
+
+	ret, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	unchanged, age = changeFlags(t, ret)
+	test.That(t, unchanged, test.ShouldBeTrue)
+	test.That(t, age, test.ShouldEqual, 1)
+}
+
+func TestCaptureMaxAge(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 2}, &frame, &pc)
+
+	pattern := []bool{}
+	for range 6 {
+		ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		unchanged, _ := changeFlags(t, ret)
+		pattern = append(pattern, unchanged)
+	}
+	test.That(t, pattern, test.ShouldResemble, []bool{false, true, true, false, true, true})
+
+	// negative threshold turns skipping off
+	pf = newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", ChangeThreshold: -1}, &frame, &pc)
+	for range 2 {
+		ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		unchanged, _ := changeFlags(t, ret)
+		test.That(t, unchanged, test.ShouldBeFalse)
+	}
+}
+
+func TestFrameSignatureCloudDelta(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	region := image.Rect(10, 10, 90, 90)
+
+	small := pointcloud.NewBasicEmpty()
+	big := pointcloud.NewBasicEmpty()
+	for i := range 200 {
+		if i < 150 {
+			test.That(t, small.Set(r3.Vector{X: float64(i)}, nil), test.ShouldBeNil)
+		}
+		test.That(t, big.Set(r3.Vector{X: float64(i)}, nil), test.ShouldBeNil)
+	}
+
+	a := computeFrameSignature(img, big, region)
+	b := computeFrameSignature(img, big, region)
+	c := computeFrameSignature(img, small, region)
+	test.That(t, a.changed(&b, 20), test.ShouldBeFalse)
+	test.That(t, a.changed(&c, 20), test.ShouldBeTrue)
+}
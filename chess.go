@@ -15,6 +15,7 @@ import (
 	"go.uber.org/multierr"
 
 	"github.com/golang/geo/r3"
+	"github.com/google/uuid"
 
 	"github.com/mitchellh/mapstructure"
 
@@ -44,9 +45,14 @@ import (
 var ChessModel = family.WithModel("chess")
 var numCaptured = 0
 
-const safeZ = 200.0
+const (
+	safeZ                = 200.0
+	defaultMinGrabHeight = 12.0
+	defaultGrabStep      = 10.0
+	defaultDropHeight    = 60.0
+)
 
-func init() {
+func registerChess() {
 	resource.RegisterService(generic.API, ChessModel,
 		resource.Registration[resource.Resource, *ChessConfig]{
 			Constructor: newViamChessChess,
@@ -67,6 +73,124 @@ type ChessConfig struct {
 	EngineMillis int `json:"engine-millis"`
 
 	CaptureDir string // mostly for vla data
+
+	// DropPosition is where a piece we can't identify gets left when the gripper
+	// is found still holding it.
+	DropPosition *r3.Vector `json:"drop-position"`
+
+	// PieceScale is how big the pieces are next to a standard set, 0 means 1. Every height below
+	// that isn't set is scaled by it, so a garden set with 400mm kings is about 4.7.
+	PieceScale float64 `json:"piece-scale"`
+
+	// The gripper travels at SafeHeight (mm, 0 means 200) and won't reach lower than
+	// MinGrabHeight (0 means 12) to grab a piece.
+	SafeHeight    float64 `json:"safe-height"`
+	MinGrabHeight float64 `json:"min-grab-height"`
+
+	// A piece is carried just high enough to clear the tallest piece within LiftCorridor (mm)
+	// of its path by LiftClearance (mm), but never lower than MinLiftHeight or higher than SafeHeight.
+	LiftClearance float64 `json:"lift-clearance"`
+	MinLiftHeight float64 `json:"min-lift-height"`
+	LiftCorridor  float64 `json:"lift-corridor"`
+
+	// MotionProfile (normal, quiet or fast, "" means normal) picks the arm speed, acceleration,
+	// lift heights and grab retry pause for whichever of them aren't set here. set_motion_profile
+	// switches it at runtime.
+	MotionProfile   string  `json:"motion-profile"`
+	Speed           float64 // degs/sec
+	Acceleration    float64 // degs/sec^2
+	GrabRetryMillis int     `json:"grab-retry-millis"`
+
+	// A reset with clear_lanes falls back to the plain order if clearing the lanes first would
+	// take more than ResetMoveFactor times as many moves.
+	ResetMoveFactor float64 `json:"reset-move-factor"`
+
+	// Each call to a dependency gets a slice of CallBudgetMillis (0 means 60000) and fails if
+	// it takes longer: 10% for a capture, 5% for a pose query or the gripper, 20% for each
+	// leg the arm moves.
+	CallBudgetMillis int `json:"call-budget-millis"`
+
+	// PointHeight is how far (mm, 0 means 80) over a square, or the piece on it, the gripper
+	// hovers for point_at.
+	PointHeight float64 `json:"point-height"`
+
+	// Locale ("en" if empty, or "fr", a region like fr-CA is fine) is the language of move
+	// descriptions, wizard instructions and mismatch reports. Anything not translated is English.
+	Locale string
+
+	// MaxGames is how many finished games (0 means 100) are kept in the history, oldest go first.
+	MaxGames int `json:"max-games"`
+
+	// AllowImageOnly lets the arm move pieces when the piece finder only had the camera image to
+	// go by, no point cloud, which it otherwise won't: the squares are right more often than not,
+	// but there's nothing to say how high anything on them is.
+	AllowImageOnly bool `json:"allow-image-only"`
+}
+
+func (cfg *ChessConfig) dropPosition() r3.Vector {
+	if cfg.DropPosition == nil {
+		return r3.Vector{X: 400, Y: -400, Z: cfg.dropHeight()}
+	}
+	return *cfg.DropPosition
+}
+
+// dropHeight is how high a piece is let go of off the board, in the graveyard or at the drop position.
+func (cfg *ChessConfig) dropHeight() float64 {
+	return defaultDropHeight * pieceScale(cfg.PieceScale)
+}
+
+func (cfg *ChessConfig) safeHeight() float64 {
+	return scaledDefault(cfg.SafeHeight, safeZ, cfg.PieceScale)
+}
+
+func (cfg *ChessConfig) minGrabHeight() float64 {
+	return scaledDefault(cfg.MinGrabHeight, defaultMinGrabHeight, cfg.PieceScale)
+}
+
+// grabStep is how much lower each retry reaches after a grab misses.
+func (cfg *ChessConfig) grabStep() float64 {
+	return defaultGrabStep * pieceScale(cfg.PieceScale)
+}
+
+// liftClearance and minLiftHeight are for the configured motion profile, see motionSettings
+// for the one in use.
+func (cfg *ChessConfig) liftClearance() float64 {
+	return cfg.motionSettings(cfg.motionProfile()).liftClearance
+}
+
+func (cfg *ChessConfig) minLiftHeight() float64 {
+	return cfg.motionSettings(cfg.motionProfile()).minLiftHeight
+}
+
+// scaleWarnings are the explicit heights that look like they were tuned for different pieces.
+func (cfg *ChessConfig) scaleWarnings() []string {
+	warnings := []string{}
+	for _, w := range []string{
+		scaleWarning("safe-height", cfg.SafeHeight, safeZ, cfg.PieceScale),
+		scaleWarning("min-grab-height", cfg.MinGrabHeight, defaultMinGrabHeight, cfg.PieceScale),
+		scaleWarning("lift-clearance", cfg.LiftClearance, defaultLiftClearance, cfg.PieceScale),
+		scaleWarning("min-lift-height", cfg.MinLiftHeight, defaultMinLiftHeight, cfg.PieceScale),
+		scaleWarning("point-height", cfg.PointHeight, defaultPointHeight, cfg.PieceScale),
+	} {
+		if w != "" {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+func (cfg *ChessConfig) liftCorridor() float64 {
+	if cfg.LiftCorridor <= 0 {
+		return defaultLiftCorridor
+	}
+	return cfg.LiftCorridor
+}
+
+func (cfg *ChessConfig) resetMoveFactor() float64 {
+	if cfg.ResetMoveFactor <= 0 {
+		return defaultResetMoveFactor
+	}
+	return cfg.ResetMoveFactor
 }
 
 func (cfg *ChessConfig) engine() string {
@@ -96,6 +220,21 @@ func (cfg *ChessConfig) Validate(path string) ([]string, []string, error) {
 	if cfg.PoseStart == "" {
 		return nil, nil, fmt.Errorf("need a pose-start")
 	}
+	if err := validatePieceScale(cfg.PieceScale); err != nil {
+		return nil, nil, err
+	}
+	if err := validateMotionProfile(cfg.motionProfile()); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Speed < 0 || cfg.Acceleration < 0 || cfg.GrabRetryMillis < 0 {
+		return nil, nil, fmt.Errorf("speed, acceleration and grab-retry-millis can't be negative")
+	}
+	if cfg.minGrabHeight() >= cfg.safeHeight() {
+		return nil, nil, fmt.Errorf("min-grab-height %v has to be under safe-height %v", cfg.minGrabHeight(), cfg.safeHeight())
+	}
+	if cfg.minLiftHeight() > cfg.safeHeight() {
+		return nil, nil, fmt.Errorf("min-lift-height %v can't be over safe-height %v", cfg.minLiftHeight(), cfg.safeHeight())
+	}
 
 	deps := []string{cfg.PieceFinder, cfg.Arm, cfg.Gripper, cfg.PoseStart, motion.Named("builtin").String()}
 
@@ -137,11 +276,39 @@ type viamChessChess struct {
 
 	engine *uci.Engine
 
-	fenFile string
+	fenFile         string
+	calibrationFile string
+	historyDir      string // a PGN and summary for each finished game
 
 	doCommandLock   sync.Mutex
 	doCommandCount  atomic.Int32
 	movePieceStatus atomic.Int32
+
+	held       *heldPiece // protected by doCommandLock
+	lastLift   liftPlan   // protected by doCommandLock
+	wizard     *wizardRun // protected by doCommandLock
+	lastMove   string     // description of the last move made, protected by doCommandLock
+	lastGameID string     // the game it was made in, protected by doCommandLock
+	armSpeed   [2]float64 // speed and acceleration last sent to the arm
+
+	// statusLock is held only to read or write what status and set_motion_profile need, so they
+	// don't wait for the command in progress.
+	statusLock    sync.Mutex
+	anomalies     []Anomaly // what the last capture saw wrong on the board, protected by statusLock
+	motionProfile string    // protected by statusLock
+
+	calls callStats // how long each phase of dependency calls takes
+
+	gripperFrame atomic.Pointer[bool] // whether the frame system has the gripper, nil until looked up
+
+	pointLock   sync.Mutex
+	pointCancel context.CancelFunc // stops the point_at in progress, protected by pointLock
+}
+
+// heldPiece is a piece that has been grabbed but not yet released.
+type heldPiece struct {
+	from, to string
+	z        float64 // height it was grabbed at
 }
 
 func newViamChessChess(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (resource.Resource, error) {
@@ -166,6 +333,15 @@ func NewChess(ctx context.Context, deps resource.Dependencies, name resource.Nam
 		conf:        conf,
 		cancelFunc:  cancelFunc,
 		skillAdjust: 50,
+
+		motionProfile: conf.motionProfile(),
+	}
+
+	for _, w := range conf.scaleWarnings() {
+		logger.Warn(w)
+	}
+	if _, ok := localeTranslations(conf.Locale); !ok {
+		logger.Warnf("no translations for locale %q, using English", conf.Locale)
 	}
 
 	s.pieceFinder, err = vision.FromProvider(deps, conf.PieceFinder)
@@ -212,6 +388,8 @@ func NewChess(ctx context.Context, deps resource.Dependencies, name resource.Nam
 
 	s.fenFile = os.Getenv("VIAM_MODULE_DATA") + "state.json"
 	s.logger.Infof("fenFile: %v", s.fenFile)
+	s.calibrationFile = os.Getenv("VIAM_MODULE_DATA") + "calibration.json"
+	s.historyDir = os.Getenv("VIAM_MODULE_DATA") + "games"
 	s.engine, err = uci.New(conf.engine())
 	if err != nil {
 		return nil, err
@@ -239,12 +417,48 @@ type MoveCmd struct {
 	N        int
 }
 
+// Validate normalizes From and To to lower case squares like "e2", and errors if either isn't
+// a real square or they're the same.
+func (m *MoveCmd) Validate() error {
+	from, err := ParseSquare(m.From)
+	if err != nil {
+		return fmt.Errorf("bad from: %w", err)
+	}
+	to, err := ParseSquare(m.To)
+	if err != nil {
+		return fmt.Errorf("bad to: %w", err)
+	}
+	if from == to {
+		return fmt.Errorf("from and to are both %s", from)
+	}
+	m.From, m.To = from.String(), to.String()
+	return nil
+}
+
 type cmdStruct struct {
 	Move  MoveCmd
 	Go    int
 	Reset bool
 	Wipe  bool
 	Skill float64
+
+	// ClearLanes goes with Reset, see planReset.
+	ClearLanes bool `mapstructure:"clear_lanes"`
+
+	// Wizard is start, next, status or abort, see wizardCommand.
+	Wizard string
+
+	SetMotionProfile string `mapstructure:"set_motion_profile"`
+	Status           bool
+
+	PointAt *PointAtCmd `mapstructure:"point_at"`
+
+	// NewGame starts a game with a new ID, one in progress has to be finished or abandoned.
+	NewGame    bool   `mapstructure:"new_game"`
+	Abandon    bool   // goes with NewGame
+	FinishGame string `mapstructure:"finish_game"` // 1-0, 0-1, 1/2-1/2, or "over" if it ended on the board
+	ListGames  bool   `mapstructure:"list_games"`
+	GetGame    string `mapstructure:"get_game"`
 }
 
 func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interface{}) (map[string]interface{}, error) {
@@ -252,22 +466,83 @@ func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interf
 	ctx, span := trace.StartSpan(ctx, "chess::DoCommand")
 	defer span.End()
 
-	s.doCommandLock.Lock()
+	// these don't wait for the command in progress: abort is for stopping it, and pointing
+	// at a square in the middle of a move would only get in the way
+	if cmdMap["abort"] == true {
+		return map[string]interface{}{"aborted": s.abortPoint()}, nil
+	}
+
+	var cmd cmdStruct
+	err := mapstructure.Decode(cmdMap, &cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// these only read or set state, so they don't wait for the command in progress either: a
+	// status call is how a slow one gets looked into, and the games are just files
+	if cmd.SetMotionProfile != "" {
+		return s.setMotionProfile(cmd.SetMotionProfile)
+	}
+	if cmd.Status {
+		return s.status(), nil
+	}
+	if cmd.ListGames {
+		return s.listGames(ctx)
+	}
+	if cmd.GetGame != "" {
+		return s.getGameByID(ctx, cmd.GetGame)
+	}
+
+	if _, ok := cmdMap["point_at"]; ok {
+		if !s.doCommandLock.TryLock() {
+			return nil, errBusy
+		}
+	} else {
+		s.doCommandLock.Lock()
+	}
 	defer s.doCommandLock.Unlock()
 
+	// only the wizard's next captures, the rest just look at or drop the run in progress
+	if cmd.Wizard != "" && cmd.Wizard != "next" {
+		return s.wizardCommand(ctx, cmd.Wizard)
+	}
+
 	defer func() {
 		err := s.goToStart(ctx)
 		if err != nil {
 			s.logger.Warnf("can't go home: %v", err)
 		}
 	}()
-	var cmd cmdStruct
-	err := mapstructure.Decode(cmdMap, &cmd)
+
+	moving := cmd.Move.To != "" || cmd.Move.From != ""
+	if moving {
+		err = cmd.Move.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.recoverHeldPiece(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if cmd.Move.To != "" && cmd.Move.From != "" {
+	if cmd.Wizard != "" {
+		return s.wizardCommand(ctx, cmd.Wizard)
+	}
+
+	if cmd.PointAt != nil {
+		return s.pointAt(ctx, *cmd.PointAt)
+	}
+
+	if cmd.NewGame {
+		return s.newGame(ctx, cmd.Abandon)
+	}
+	if cmd.FinishGame != "" {
+		return s.finishGame(ctx, cmd.FinishGame)
+	}
+
+	if moving {
 		s.logger.Infof("move %v to %v", cmd.Move.From, cmd.Move.To)
 
 		for x := range cmd.Move.N {
@@ -280,7 +555,7 @@ func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interf
 			if x%2 == 1 {
 				to, from = from, to
 			}
-			all, err := s.pieceFinder.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+			all, err := s.capture(ctx)
 			if err != nil {
 				return nil, err
 			}
@@ -291,7 +566,11 @@ func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interf
 			}
 		}
 
-		return nil, nil
+		return map[string]interface{}{
+			"lift_height":    s.lastLift.height,
+			"lift_square":    s.lastLift.square,
+			"motion_profile": s.motionSettings().profile,
+		}, nil
 	}
 
 	if cmd.Go > 0 {
@@ -302,11 +581,16 @@ func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interf
 				return nil, err
 			}
 		}
-		return map[string]interface{}{"move": m.String()}, nil
+		return map[string]interface{}{
+			"move":           m.String(),
+			"description":    s.lastMove,
+			"game_id":        s.lastGameID,
+			"motion_profile": s.motionSettings().profile,
+		}, nil
 	}
 
 	if cmd.Reset {
-		return nil, s.resetBoard(ctx)
+		return nil, s.resetBoard(ctx, cmd.ClearLanes)
 	}
 
 	if cmd.Wipe {
@@ -321,6 +605,17 @@ func (s *viamChessChess) DoCommand(ctx context.Context, cmdMap map[string]interf
 	return nil, fmt.Errorf("bad cmd %v", cmdMap)
 }
 
+// status is the motion settings in use, how long calls have been taking and what the last
+// capture saw wrong on the board.
+func (s *viamChessChess) status() map[string]interface{} {
+	ret := s.motionSettings().status()
+	ret["calls"] = s.calls.status()
+	s.statusLock.Lock()
+	ret["anomalies"] = anomalyList(s.anomalies)
+	s.statusLock.Unlock()
+	return ret
+}
+
 func (s *viamChessChess) Close(ctx context.Context) error {
 	var err error
 
@@ -333,6 +628,22 @@ func (s *viamChessChess) Close(ctx context.Context) error {
 	return err
 }
 
+// checkChessGrid errors if the piece finder is set up for a board that isn't 8x8, one object per
+// square. Only chess is played, a draughts or teaching board is for the piece finder on its own.
+func checkChessGrid(all viscapture.VisCapture) error {
+	n := 0
+	for _, o := range all.Objects {
+		if !isGraveyardLabel(o.Geometry.Label()) {
+			n++
+		}
+	}
+	if n > 0 && n != len(squareNames) {
+		side := int(math.Round(math.Sqrt(float64(n))))
+		return fmt.Errorf("the piece finder sees a %dx%d board (%d squares), chess needs grid-size 8", side, side, n)
+	}
+	return nil
+}
+
 func (s *viamChessChess) findObject(data viscapture.VisCapture, pos string) *viz.Object {
 	for _, o := range data.Objects {
 		if strings.HasPrefix(o.Geometry.Label(), pos) {
@@ -362,14 +673,14 @@ func (s *viamChessChess) graveyardPosition(data viscapture.VisCapture, pos int)
 	}
 
 	md := oo.MetaData()
-	return r3.Vector{md.Center().X, md.Center().Y - float64(ex*80), 60}, nil
+	return r3.Vector{X: md.Center().X, Y: md.Center().Y - float64(ex*80), Z: s.conf.dropHeight()}, nil
 
 }
 
 func (s *viamChessChess) getCenterFor(data viscapture.VisCapture, pos string, theState *state) (r3.Vector, error) {
 	if pos == "-" {
 		if theState == nil {
-			return r3.Vector{400 + float64(numCaptured%3*50), -400, 200}, nil
+			return r3.Vector{X: 400 + float64(numCaptured%3*50), Y: -400, Z: s.conf.safeHeight()}, nil
 		}
 		return s.graveyardPosition(data, len(theState.graveyard))
 	}
@@ -381,7 +692,15 @@ func (s *viamChessChess) getCenterFor(data viscapture.VisCapture, pos string, th
 			return r3.Vector{}, fmt.Errorf("bad special graveyard (%s)", pos)
 		}
 
-		return s.graveyardPosition(data, x)
+		at, err := s.graveyardPosition(data, x)
+		if err != nil {
+			return r3.Vector{}, err
+		}
+		// the piece finder watching the graveyard knows where the piece really is
+		if o := nearestGraveyardPiece(data, at); o != nil {
+			return pieceGrabPoint(data, o), nil
+		}
+		return at, nil
 	}
 
 	o := s.findObject(data, pos)
@@ -389,19 +708,28 @@ func (s *viamChessChess) getCenterFor(data viscapture.VisCapture, pos string, th
 		return r3.Vector{}, fmt.Errorf("can't find object for: %s", pos)
 	}
 
-	md := o.MetaData()
-	center := md.Center()
-
 	if strings.HasSuffix(o.Geometry.Label(), "-0") {
-		return center, nil
+		md := o.MetaData()
+		return md.Center(), nil
 	}
+	return pieceGrabPoint(data, o), nil
+}
 
+// pieceGrabPoint is where to grab the piece o is, the top of it.
+func pieceGrabPoint(data viscapture.VisCapture, o *viz.Object) r3.Vector {
+	md := o.MetaData()
+	center := md.Center()
 	high := touch.PCFindHighestInRegion(o, image.Rect(-1000, -1000, 1000, 1000))
+	if data.Extra["world_poses"] == true {
+		// the piece finder posed the piece itself, see its camera-frame
+		at := o.Geometry.Pose().Point()
+		return r3.Vector{X: at.X, Y: at.Y, Z: high.Z}
+	}
 	return r3.Vector{
 		X: (center.X + high.X) / 2,
 		Y: (center.Y + high.Y) / 2,
 		Z: high.Z,
-	}, nil
+	}
 }
 
 func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCapture, theState *state, from, to string, m *chess.Move) error {
@@ -413,6 +741,10 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 	ctx, span := trace.StartSpan(ctx, "movePiece")
 	defer span.End()
 
+	if data.Extra["source"] == sourceImage && !s.conf.AllowImageOnly {
+		return errImageOnly
+	}
+
 	s.logger.Infof("movePiece called: %s -> %s", from, to)
 	if to != "-" && to[0] != 'X' { // check where we're going
 		o := s.findObject(data, to)
@@ -443,7 +775,7 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 	var fromCenter r3.Vector
 	s.logger.Infof("Okay doing a move with useZ=%f", useZ)
 
-	const magicMin = 12.0
+	minGrab := s.conf.minGrabHeight()
 	{
 		center, err := s.getCenterFor(data, from, theState)
 		if err != nil {
@@ -451,11 +783,11 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 		}
 		fromCenter = center
 
-		useZ = max(magicMin, center.Z) // HACK 5 should not be there
+		useZ = max(minGrab, center.Z) // HACK 5 should not be there
 		s.logger.Infof("WE'RE MOVING TO useZ=%f", useZ)
 		s.logger.Errorf("WE'RE MOVING TO useZ=%f", useZ)
 
-		err = s.gripper.Open(ctx, nil)
+		err = s.openGripper(ctx)
 		if err != nil {
 			return err
 		}
@@ -467,13 +799,13 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 		}
 		time.Sleep(500 * time.Millisecond)
 
-		err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, safeZ})
+		err = s.moveGripper(ctx, r3.Vector{X: center.X, Y: center.Y, Z: s.conf.safeHeight()})
 		if err != nil {
 			return err
 		}
 
 		for {
-			err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, useZ})
+			err = s.moveGripper(ctx, r3.Vector{X: center.X, Y: center.Y, Z: useZ})
 			if err != nil {
 				return err
 			}
@@ -486,8 +818,8 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 				break
 			}
 
-			useZ -= 10
-			if useZ < magicMin { // todo: magic number
+			useZ -= s.conf.grabStep()
+			if useZ < minGrab {
 				return fmt.Errorf("couldn't grab, and scared to go lower")
 			}
 
@@ -497,52 +829,128 @@ func (s *viamChessChess) movePiece(ctx context.Context, data viscapture.VisCaptu
 			if err != nil {
 				return err
 			}
-			time.Sleep(250 * time.Millisecond)
+			time.Sleep(s.motionSettings().grabRetryPause)
 		}
 
-		err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, safeZ})
-		if err != nil {
-			return err
-		}
+		s.held = &heldPiece{from: from, to: to, z: useZ}
+	}
+
+	s.logger.Infof("THE STATE: %v", theState)
+	toCenter, err := s.getCenterFor(data, to, theState)
+	s.logger.Infof("CENTER for to: %v is %v", to, toCenter)
+	if err != nil {
+		return err
+	}
+
+	lift := s.planLift(data, from, to, fromCenter, toCenter, useZ)
+	s.lastLift = lift
+	s.logger.Infof("carrying %s -> %s at %.0f, limited by %q, %s motion", from, to, lift.height, lift.square, s.motionSettings().profile)
+
+	err = s.moveGripper(ctx, r3.Vector{X: fromCenter.X, Y: fromCenter.Y, Z: lift.height})
+	if err != nil {
+		return err
 	}
 
 	if to == "-" || to[0] == 'X' {
-		if err := s.Taunt(ctx, r3.Vector{fromCenter.X, fromCenter.Y, safeZ}); err != nil {
+		if err := s.Taunt(ctx, r3.Vector{X: fromCenter.X, Y: fromCenter.Y, Z: s.conf.safeHeight()}); err != nil {
 			s.logger.Warnf("taunt failed, continuing: %v", err)
 		}
 	}
 
-	{
-		s.logger.Infof("THE STATE: %v", theState)
-		center, err := s.getCenterFor(data, to, theState)
+	return s.placeHeld(ctx, toCenter, useZ, lift.height)
+}
 
-		s.logger.Infof("CENTER for to: %v is %v", to, center)
-		if err != nil {
-			return err
-		}
+// placeHeld carries whatever we're holding to above center at travelZ, lowers it to height z,
+// and lets go.
+func (s *viamChessChess) placeHeld(ctx context.Context, center r3.Vector, z, travelZ float64) error {
+	err := s.moveGripper(ctx, r3.Vector{X: center.X, Y: center.Y, Z: travelZ})
+	if err != nil {
+		return err
+	}
 
-		err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, safeZ})
-		if err != nil {
-			return err
-		}
+	err = s.moveGripper(ctx, r3.Vector{X: center.X, Y: center.Y, Z: z})
+	if err != nil {
+		return err
+	}
 
-		err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, useZ})
+	err = s.setupGripper(ctx)
+	if err != nil {
+		return err
+	}
+	s.held = nil
+
+	return s.moveGripper(ctx, r3.Vector{X: center.X, Y: center.Y, Z: travelZ})
+}
+
+// recoverHeldPiece checks if a previous command left a piece in the gripper, and if so
+// puts it where it was going, or back where it came from, before anything else moves.
+func (s *viamChessChess) recoverHeldPiece(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "recoverHeldPiece")
+	defer span.End()
+
+	holding := s.held != nil
+	status, err := callWithTimeout(ctx, s, phaseGripper, func(ctx context.Context) (gripper.HoldingStatus, error) {
+		return s.gripper.IsHoldingSomething(ctx, nil)
+	})
+	if err != nil {
+		s.logger.Warnf("can't ask gripper if it's holding something, using last known state (%v): %v", holding, err)
+	} else {
+		holding = status.IsHoldingSomething
+	}
+	if !holding {
+		s.held = nil
+		return nil
+	}
+
+	held := s.held
+	var placeErrs []string // why it couldn't go on either square
+	if held != nil {
+		s.logger.Warnf("gripper still holding piece from %s going to %s, recovering", held.from, held.to)
+
+		all, err := s.capture(ctx)
 		if err != nil {
 			return err
 		}
 
-		err = s.setupGripper(ctx)
-		if err != nil {
-			return err
+		for _, pos := range []string{held.to, held.from} {
+			err = s.placeHeldOn(ctx, all, pos, held.z)
+			if err == nil {
+				return nil
+			}
+			s.logger.Warnf("can't put held piece on %s: %v", pos, err)
+			placeErrs = append(placeErrs, fmt.Sprintf("%s: %v", pos, err))
 		}
+	}
 
-		err = s.moveGripper(ctx, r3.Vector{center.X, center.Y, safeZ})
-		if err != nil {
-			return err
+	piece := "an unknown piece"
+	if held != nil {
+		piece = fmt.Sprintf("the piece from %s going to %s and can't put it back (%s)", held.from, held.to, strings.Join(placeErrs, ", "))
+	}
+	drop := s.conf.dropPosition()
+	err = s.placeHeld(ctx, drop, drop.Z, s.conf.safeHeight())
+	if err != nil {
+		return fmt.Errorf("gripper is holding %s, and can't drop it: %w", piece, err)
+	}
+	return fmt.Errorf("gripper was holding %s, left it at %v, needs an operator", piece, drop)
+}
+
+// placeHeldOn puts the held piece on a square, which must be empty.
+func (s *viamChessChess) placeHeldOn(ctx context.Context, data viscapture.VisCapture, pos string, z float64) error {
+	if pos != "-" && pos[0] != 'X' {
+		o := s.findObject(data, pos)
+		if o == nil {
+			return fmt.Errorf("can't find object for: %s", pos)
+		}
+		if !strings.HasSuffix(o.Geometry.Label(), "-0") {
+			return fmt.Errorf("%s is occupied", pos)
 		}
 	}
 
-	return nil
+	center, err := s.getCenterFor(data, pos, nil)
+	if err != nil {
+		return err
+	}
+	return s.placeHeld(ctx, center, z, s.conf.safeHeight())
 }
 
 func (s *viamChessChess) Taunt(ctx context.Context, currentPos r3.Vector) error {
@@ -551,35 +959,42 @@ func (s *viamChessChess) Taunt(ctx context.Context, currentPos r3.Vector) error
 
 	s.logger.Infof("Taunting with captured piece at position %v", currentPos)
 
-	joints, err := s.arm.JointPositions(ctx, nil)
+	joints, err := callWithTimeout(ctx, s, phaseMotion, func(ctx context.Context) ([]referenceframe.Input, error) {
+		return s.arm.JointPositions(ctx, nil)
+	})
 	if err != nil {
 		return err
 	}
+	moveJoints := func() error {
+		return callWithTimeoutErr(ctx, s, phaseMotion, func(ctx context.Context) error {
+			return s.arm.MoveToJointPositions(ctx, joints, nil)
+		})
+	}
 
 	tauntJoint := 3
 	original := joints[tauntJoint]
 
 	for i := 0; i < 2; i++ {
 		joints[tauntJoint] = original - math.Pi/12
-		err = s.arm.MoveToJointPositions(ctx, joints, nil)
+		err = moveJoints()
 		if err != nil {
 			return err
 		}
 		joints[tauntJoint] = original + math.Pi/12
-		err = s.arm.MoveToJointPositions(ctx, joints, nil)
+		err = moveJoints()
 		if err != nil {
 			return err
 		}
 	}
 
 	joints[tauntJoint] = original
-	err = s.arm.MoveToJointPositions(ctx, joints, nil)
+	err = moveJoints()
 	if err != nil {
 		return err
 	}
 
 	joints[tauntJoint] = original
-	return s.arm.MoveToJointPositions(ctx, joints, nil)
+	return moveJoints()
 
 }
 
@@ -587,18 +1002,25 @@ func (s *viamChessChess) goToStart(ctx context.Context) error {
 	ctx, span := trace.StartSpan(ctx, "goToStart")
 	defer span.End()
 
-	err := s.poseStart.SetPosition(ctx, 2, nil)
+	err := callWithTimeoutErr(ctx, s, phaseMotion, func(ctx context.Context) error {
+		return s.poseStart.SetPosition(ctx, 2, nil)
+	})
 	if err != nil {
 		return err
 	}
-	err = s.gripper.Open(ctx, nil)
-	if err != nil {
-		return err
+
+	if s.held == nil { // keep holding it so recoverHeldPiece can put it back
+		err = s.openGripper(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	time.Sleep(time.Millisecond * 250)
 
-	s.startPose, err = s.rfs.GetPose(ctx, s.conf.Gripper, "world", nil, nil)
+	s.startPose, err = callWithTimeout(ctx, s, phasePose, func(ctx context.Context) (*referenceframe.PoseInFrame, error) {
+		return s.rfs.GetPose(ctx, s.conf.Gripper, "world", nil, nil)
+	})
 	if err != nil {
 		return err
 	}
@@ -610,14 +1032,24 @@ func (s *viamChessChess) setupGripper(ctx context.Context) error {
 	ctx, span := trace.StartSpan(ctx, "setupGripper")
 	defer span.End()
 
-	_, err := s.arm.DoCommand(ctx, map[string]interface{}{"move_gripper": 450.0})
-	return err
+	return callWithTimeoutErr(ctx, s, phaseGripper, func(ctx context.Context) error {
+		_, err := s.arm.DoCommand(ctx, map[string]interface{}{"move_gripper": 450.0})
+		return err
+	})
+}
+
+func (s *viamChessChess) openGripper(ctx context.Context) error {
+	return callWithTimeoutErr(ctx, s, phaseGripper, func(ctx context.Context) error {
+		return s.gripper.Open(ctx, nil)
+	})
 }
 
 func (s *viamChessChess) moveGripper(ctx context.Context, p r3.Vector) error {
 	ctx, span := trace.StartSpan(ctx, "moveGripper")
 	defer span.End()
 
+	s.applyArmSpeed(ctx)
+
 	orientation := &spatialmath.OrientationVectorDegrees{
 		OZ:    -1,
 		Theta: s.startPose.Pose().Orientation().OrientationVectorDegrees().Theta + 180,
@@ -634,9 +1066,11 @@ func (s *viamChessChess) moveGripper(ctx context.Context, p r3.Vector) error {
 	s.logger.Errorf("ORIENTATION %v", orientation)
 	myPose := spatialmath.NewPose(p, orientation)
 	s.logger.Errorf("ORIGINAL POSE %v", myPose)
-	_, err := s.motion.Move(ctx, motion.MoveReq{
-		ComponentName: s.conf.Gripper,
-		Destination:   referenceframe.NewPoseInFrame("world", myPose),
+	_, err := callWithTimeout(ctx, s, phaseMotion, func(ctx context.Context) (bool, error) {
+		return s.motion.Move(ctx, motion.MoveReq{
+			ComponentName: s.conf.Gripper,
+			Destination:   referenceframe.NewPoseInFrame("world", myPose),
+		})
 	})
 	if err != nil {
 		return fmt.Errorf("can't move to %v: %w", myPose, err)
@@ -647,11 +1081,22 @@ func (s *viamChessChess) moveGripper(ctx context.Context, p r3.Vector) error {
 type state struct {
 	game      *chess.Game
 	graveyard []int
+
+	id      string    // the game's ID, assigned the first time it's saved
+	started time.Time // when it was first saved
+	start   string    // the FEN the game started from, "" for the starting position
 }
 
 type savedState struct {
 	FEN       string `json:"fen"`
 	Graveyard []int  `json:"graveyard"`
+
+	// ID, Started and Moves (UCI, from Start or the starting position) go into the game's PGN
+	// in the history. A file with only a FEN is a game starting there.
+	ID      string    `json:"id,omitempty"`
+	Started time.Time `json:"started,omitempty"`
+	Start   string    `json:"start,omitempty"`
+	Moves   []string  `json:"moves,omitempty"`
 }
 
 func (s *viamChessChess) getGame(ctx context.Context) (*state, error) {
@@ -664,7 +1109,7 @@ func readState(ctx context.Context, fn string) (*state, error) {
 
 	data, err := os.ReadFile(fn)
 	if os.IsNotExist(err) {
-		return &state{chess.NewGame(), []int{}}, nil
+		return &state{game: chess.NewGame(), graveyard: []int{}}, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading fen (%s) %T", fn, err)
@@ -676,26 +1121,63 @@ func readState(ctx context.Context, fn string) (*state, error) {
 		return nil, fmt.Errorf("cannot unmarshal json")
 	}
 
-	f, err := chess.FEN(ss.FEN)
-	if err != nil {
-		return nil, fmt.Errorf("invalid fen from (%s) (%s) %w", fn, data, err)
+	theState := &state{graveyard: ss.Graveyard, id: ss.ID, started: ss.Started, start: ss.Start}
+	if len(ss.Moves) == 0 {
+		f, err := chess.FEN(ss.FEN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fen from (%s) (%s) %w", fn, data, err)
+		}
+		theState.game = chess.NewGame(f)
+		if theState.start == "" && ss.FEN != chess.StartingPosition().String() {
+			theState.start = ss.FEN
+		}
+		return theState, nil
 	}
-	return &state{chess.NewGame(f), ss.Graveyard}, nil
+
+	theState.game = chess.NewGame()
+	if ss.Start != "" {
+		f, err := chess.FEN(ss.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start fen from (%s) %w", fn, err)
+		}
+		theState.game = chess.NewGame(f)
+	}
+	for _, m := range ss.Moves {
+		err = theState.game.PushNotationMove(m, chess.UCINotation{}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("bad move %s in (%s) %w", m, fn, err)
+		}
+	}
+	if theState.game.FEN() != ss.FEN {
+		return nil, fmt.Errorf("moves in (%s) end at %s, not %s", fn, theState.game.FEN(), ss.FEN)
+	}
+	return theState, nil
 }
 
 func (s *viamChessChess) saveGame(ctx context.Context, theState *state) error {
 	ctx, span := trace.StartSpan(ctx, "saveGame")
 	defer span.End()
 
+	if theState.id == "" {
+		theState.id = uuid.NewString()
+		theState.started = time.Now()
+	}
+
 	ss := savedState{
 		FEN:       theState.game.FEN(),
 		Graveyard: theState.graveyard,
+		ID:        theState.id,
+		Started:   theState.started,
+		Start:     theState.start,
+	}
+	for _, m := range theState.game.Moves() {
+		ss.Moves = append(ss.Moves, m.String())
 	}
 	b, err := json.MarshalIndent(&ss, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.fenFile, b, 0666)
+	return replaceFile(s.fenFile, b)
 }
 
 func (s *viamChessChess) pickMove(ctx context.Context, game *chess.Game) (*chess.Move, error) {
@@ -744,10 +1226,13 @@ func (s *viamChessChess) makeAMove(ctx context.Context, doSanityCheck bool) (*ch
 		return nil, err
 	}
 
-	all, err := s.pieceFinder.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	all, err := s.capture(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if anomalies := captureAnomalies(all.Extra); len(anomalies) > 0 {
+		return nil, anomaliesError(anomalies)
+	}
 
 	if doSanityCheck {
 		err = s.checkPositionForMoves(ctx, all)
@@ -805,6 +1290,7 @@ func (s *viamChessChess) makeAMove(ctx context.Context, doSanityCheck bool) (*ch
 		return nil, err
 	}
 
+	s.lastMove = describeMove(s.conf.Locale, theState.game.Position(), m)
 	err = theState.game.Move(m, nil)
 	if err != nil {
 		return nil, err
@@ -814,19 +1300,25 @@ func (s *viamChessChess) makeAMove(ctx context.Context, doSanityCheck bool) (*ch
 	if err != nil {
 		return nil, err
 	}
+	s.lastGameID = theState.id
+	s.gameLogger(theState).Infof("played %v (%s)", m, s.lastMove)
 
 	return m, nil
 }
 
 func (s *viamChessChess) myGrab(ctx context.Context) (bool, error) {
-	got, err := s.gripper.Grab(ctx, nil)
+	got, err := callWithTimeout(ctx, s, phaseGripper, func(ctx context.Context) (bool, error) {
+		return s.gripper.Grab(ctx, nil)
+	})
 	if err != nil {
 		return false, err
 	}
 
 	time.Sleep(300 * time.Millisecond)
 
-	res, err := s.arm.DoCommand(ctx, map[string]interface{}{"get_gripper": true})
+	res, err := callWithTimeout(ctx, s, phaseGripper, func(ctx context.Context) (map[string]interface{}, error) {
+		return s.arm.DoCommand(ctx, map[string]interface{}{"get_gripper": true})
+	})
 	if err != nil {
 		return false, err
 	}
@@ -846,7 +1338,7 @@ func (s *viamChessChess) myGrab(ctx context.Context) (bool, error) {
 	return got, nil
 }
 
-func (s *viamChessChess) resetBoard(ctx context.Context) error {
+func (s *viamChessChess) resetBoard(ctx context.Context, clearLanes bool) error {
 	theMainState, err := s.getGame(ctx)
 	if err != nil {
 		return err
@@ -854,31 +1346,28 @@ func (s *viamChessChess) resetBoard(ctx context.Context) error {
 
 	theState := &resetState{theMainState.game.Position().Board(), theMainState.graveyard}
 
-	for {
-		from, to, err := nextResetMove(theState)
-		if err != nil {
-			return err
-		}
-		if from < 0 {
-			break
-		}
+	var moves []resetMove
+	if clearLanes {
+		moves, err = planResetWithLanes(theState, s.conf.resetMoveFactor())
+	} else {
+		moves, err = planReset(theState, false)
+	}
+	if err != nil {
+		return err
+	}
 
+	for _, m := range moves {
 		err = s.goToStart(ctx)
 		if err != nil {
 			return err
 		}
 
-		all, err := s.pieceFinder.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
-		if err != nil {
-			return err
-		}
-
-		err = s.movePiece(ctx, all, nil, squareToString(from), squareToString(to), nil)
+		all, err := s.capture(ctx)
 		if err != nil {
 			return err
 		}
 
-		err = theState.applyMove(from, to)
+		err = s.movePiece(ctx, all, nil, squareToString(m.from), squareToString(m.to), nil)
 		if err != nil {
 			return err
 		}
@@ -887,7 +1376,18 @@ func (s *viamChessChess) resetBoard(ctx context.Context) error {
 	return s.wipe(ctx)
 }
 
+// wipe forgets the current game, keeping it in the history (as abandoned if it wasn't over).
 func (s *viamChessChess) wipe(ctx context.Context) error {
+	theState, err := s.getGame(ctx)
+	if err != nil {
+		return err
+	}
+	if len(theState.game.Moves()) > 0 {
+		err = s.archiveGame(theState, theState.game.Outcome() == chess.NoOutcome)
+		if err != nil {
+			return err
+		}
+	}
 	return os.Remove(s.fenFile)
 }
 
@@ -900,102 +1400,23 @@ func (s *viamChessChess) checkPositionForMoves(ctx context.Context, all viscaptu
 		return err
 	}
 
-	differnces := []chess.Square{}
-	from := chess.NoSquare
-	to := chess.NoSquare
-
-	for sq := chess.A1; sq <= chess.H8; sq++ {
-		x := squareToString(sq)
-
-		fromState := theState.game.Position().Board().Piece(sq)
-		o := s.findObject(all, x)
-		oc := int(o.Geometry.Label()[3] - '0')
-
-		if int(fromState.Color()) != oc {
-			s.logger.Infof("differnent %s fromState: %v o: %v oc: %v", x, fromState, o.Geometry.Label(), oc)
-			differnces = append(differnces, sq)
-			if oc == 0 {
-				from = sq
-			} else if oc > 0 {
-				to = sq
-			}
-		}
-
-	}
-
-	if len(differnces) == 0 {
+	board, _ := captureBoard(all)
+	diff := DiffBoards(positionBoard(theState.game.Position()), board, 0)
+	if diff.Empty() {
 		return nil
 	}
+	s.logger.Infof("the board %s", diffSummary(diff))
 
-	if len(differnces) == 4 {
-		// is this a castle??
-		if squaresSame(differnces, []chess.Square{chess.E1, chess.F1, chess.G1, chess.H1}) {
-			// white king castle
-			from = chess.E1
-			to = chess.G1
-			differnces = nil
-		} else if squaresSame(differnces, []chess.Square{chess.E1, chess.A1, chess.C1, chess.D1}) {
-			// white queen castle
-			from = chess.E1
-			to = chess.C1
-			differnces = nil
-		} else if squaresSame(differnces, []chess.Square{chess.E8, chess.F8, chess.G8, chess.H8}) {
-			// black king castle
-			from = chess.E8
-			to = chess.G8
-			differnces = nil
-		} else if squaresSame(differnces, []chess.Square{chess.E8, chess.A8, chess.C8, chess.D8}) {
-			// black queen castle
-			from = chess.E8
-			to = chess.C8
-			differnces = nil
-		}
-	}
-
-	if len(differnces) != 2 && len(differnces) != 0 {
-		return fmt.Errorf("bad number of differnces (%d) : %v", len(differnces), differnces)
-	}
-
-	moves := theState.game.ValidMoves()
-	for _, m := range moves {
-		if m.S1() == from && m.S2() == to {
-			s.logger.Infof("found it: %v", m.String())
-			err = theState.game.Move(&m, nil)
-			if err != nil {
-				return err
-			}
-
-			err = s.saveGame(ctx, theState)
-			if err != nil {
-				return err
-			}
-
-			return nil
-		}
-	}
-
-	return fmt.Errorf("no valid moves from: %v to %v found out of %d", from, to, len(moves))
-}
-
-func squaresSame(a, b []chess.Square) bool {
-	if len(a) != len(b) {
-		return false
+	m, err := MoveFromDiff(diff, theState.game)
+	if err != nil {
+		return err
 	}
-
-	// Check that every element in a exists in b
-	for _, sq := range a {
-		found := false
-		for _, sq2 := range b {
-			if sq == sq2 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	s.gameLogger(theState).Infof("found it: %v", m.String())
+	err = theState.game.Move(&m, nil)
+	if err != nil {
+		return err
 	}
-	return true
+	return s.saveGame(ctx, theState)
 }
 
 func (s *viamChessChess) runCaptureThread(ctx context.Context) {
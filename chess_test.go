@@ -0,0 +1,468 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/gripper"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	injectmotion "go.viam.com/rdk/testutils/inject/motion"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// fakeRobot records everything the chess service asks the hardware to do.
+type fakeRobot struct {
+	mu       sync.Mutex
+	events   []string
+	holding  bool
+	occupied map[string]int // square -> color
+
+	imageOnly bool          // captures are from the image alone, see squaresFromImage
+	anomalies []interface{} // what captures say is wrong on the board, see addAnomalies
+
+	captureExtra map[string]interface{} // the last capture's
+}
+
+func (f *fakeRobot) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeRobot) indexOf(e string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, x := range f.events {
+		if x == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// fakeSquareCenter is where each square sits in the fake world, 50mm apart.
+func fakeSquareCenter(name string) r3.Vector {
+	return r3.Vector{X: float64(name[0]-'a') * 50, Y: float64(name[1]-'1') * 50, Z: 0}
+}
+
+func fakeSquareCloud(center r3.Vector, height float64) (pointcloud.PointCloud, error) {
+	pc := pointcloud.NewBasicEmpty()
+	for x := -10.0; x <= 10; x += 5 {
+		for y := -10.0; y <= 10; y += 5 {
+			err := pc.Set(r3.Vector{X: center.X + x, Y: center.Y + y, Z: center.Z}, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if height > 0 {
+		err := pc.Set(r3.Vector{X: center.X, Y: center.Y, Z: center.Z + height}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pc, nil
+}
+
+func (f *fakeRobot) capture() (viscapture.VisCapture, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ret := viscapture.VisCapture{}
+	for _, name := range squareNames {
+		c := f.occupied[name]
+		height := 0.0
+		if c > 0 {
+			height = 40
+		}
+		pc, err := fakeSquareCloud(fakeSquareCenter(name), height)
+		if err != nil {
+			return ret, err
+		}
+		o, err := viz.NewObjectWithLabel(pc, fmt.Sprintf("%s-%d", name, c), nil)
+		if err != nil {
+			return ret, err
+		}
+		ret.Objects = append(ret.Objects, o)
+	}
+	ret.Extra = map[string]interface{}{}
+	if f.imageOnly {
+		ret.Extra["source"] = sourceImage
+	}
+	if f.anomalies != nil {
+		ret.Extra["anomalies"] = f.anomalies
+	}
+	return ret, nil
+}
+
+func newTestChess(t *testing.T) (*viamChessChess, *fakeRobot) {
+	f := &fakeRobot{occupied: map[string]int{}}
+
+	g := inject.NewGripper("gripper")
+	g.OpenFunc = func(ctx context.Context, extra map[string]interface{}) error {
+		f.record("open")
+		f.mu.Lock()
+		f.holding = false
+		f.mu.Unlock()
+		return nil
+	}
+	g.GrabFunc = func(ctx context.Context, extra map[string]interface{}) (bool, error) {
+		f.record("grab")
+		f.mu.Lock()
+		f.holding = true
+		f.mu.Unlock()
+		return true, nil
+	}
+	g.IsHoldingSomethingFunc = func(ctx context.Context, extra map[string]interface{}) (gripper.HoldingStatus, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return gripper.HoldingStatus{IsHoldingSomething: f.holding}, nil
+	}
+
+	a := inject.NewArm("arm")
+	a.DoFunc = func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+		if _, ok := cmd["move_gripper"]; ok {
+			f.record("release")
+			f.mu.Lock()
+			f.holding = false
+			f.mu.Unlock()
+			return nil, nil
+		}
+		if _, ok := cmd["set_speed"]; ok {
+			f.record("speed %v %v", cmd["set_speed"], cmd["set_acceleration"])
+			return nil, nil
+		}
+		if _, ok := cmd["get_gripper"]; ok {
+			return map[string]interface{}{"gripper_position": 100.0}, nil
+		}
+		return nil, fmt.Errorf("unknown arm cmd %v", cmd)
+	}
+
+	m := injectmotion.NewMotionService("builtin")
+	m.MoveFunc = func(ctx context.Context, req motion.MoveReq) (bool, error) {
+		p := req.Destination.Pose().Point()
+		f.record("move %.0f,%.0f,%.0f", p.X, p.Y, p.Z)
+		return true, nil
+	}
+
+	poseStart := inject.NewSwitch("pose-start")
+	poseStart.SetPositionFunc = func(ctx context.Context, position uint32, extra map[string]interface{}) error {
+		f.record("start")
+		return nil
+	}
+
+	rfs := inject.NewFrameSystemService("fs")
+	rfs.GetPoseFunc = func(ctx context.Context, componentName, destinationFrame string,
+		supplementalTransforms []*referenceframe.LinkInFrame, extra map[string]interface{},
+	) (*referenceframe.PoseInFrame, error) {
+		return referenceframe.NewPoseInFrame("world", spatialmath.NewZeroPose()), nil
+	}
+
+	pf := inject.NewVisionService("piece-finder")
+	pf.CaptureAllFromCameraFunc = func(ctx context.Context, cameraName string, opts viscapture.CaptureOptions,
+		extra map[string]interface{},
+	) (viscapture.VisCapture, error) {
+		f.mu.Lock()
+		f.captureExtra = extra
+		f.mu.Unlock()
+		return f.capture()
+	}
+
+	dir := t.TempDir()
+	s := &viamChessChess{
+		logger:      logging.NewTestLogger(t),
+		conf:        &ChessConfig{Gripper: "gripper"},
+		pieceFinder: pf,
+		arm:         a,
+		gripper:     g,
+		poseStart:   poseStart,
+		motion:      m,
+		rfs: &partsFrameSystem{FrameSystemService: rfs, parts: []*referenceframe.FrameSystemPart{
+			{FrameConfig: referenceframe.NewLinkInFrame("world", spatialmath.NewZeroPose(), "gripper", nil)},
+		}},
+		skillAdjust: 50,
+		fenFile:     dir + "/state.json",
+
+		calibrationFile: dir + "/calibration.json",
+		historyDir:      dir + "/games",
+		motionProfile:   defaultMotionProfile,
+	}
+	s.startPose = referenceframe.NewPoseInFrame("world", spatialmath.NewZeroPose())
+
+	return s, f
+}
+
+func fakeMoveEvent(p r3.Vector) string {
+	return fmt.Sprintf("move %.0f,%.0f,%.0f", p.X, p.Y, math.Round(p.Z))
+}
+
+func TestCaptureExcludesGripper(t *testing.T) {
+	s, f := newTestChess(t)
+	_, err := s.capture(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.captureExtra["exclude_frames"], test.ShouldResemble, []interface{}{"gripper"})
+
+	// not when the frame system doesn't have it, and that's looked up once
+	s, f = newTestChess(t)
+	counting := &countingFrameSystem{partsFrameSystem: s.rfs.(*partsFrameSystem)}
+	counting.parts = nil
+	s.rfs = counting
+	for i := 0; i < 3; i++ {
+		_, err = s.capture(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, f.captureExtra["exclude_frames"], test.ShouldBeNil)
+	}
+	test.That(t, counting.lookups, test.ShouldEqual, 1)
+
+	// or when there's no frame system at all
+	s, f = newTestChess(t)
+	s.rfs = nil
+	_, err = s.capture(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.captureExtra["exclude_frames"], test.ShouldBeNil)
+
+	// a lookup that fails isn't kept, the next capture looks again
+	s, f = newTestChess(t)
+	counting = &countingFrameSystem{partsFrameSystem: s.rfs.(*partsFrameSystem), err: errors.New("frame system is restarting")}
+	s.rfs = counting
+	_, err = s.capture(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.captureExtra["exclude_frames"], test.ShouldBeNil)
+	counting.err = nil
+	for i := 0; i < 2; i++ {
+		_, err = s.capture(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, f.captureExtra["exclude_frames"], test.ShouldResemble, []interface{}{"gripper"})
+	}
+	test.That(t, counting.lookups, test.ShouldEqual, 2)
+}
+
+// countingFrameSystem is a partsFrameSystem counting how often its config is asked for, failing
+// with err when it's set.
+type countingFrameSystem struct {
+	*partsFrameSystem
+	lookups int
+	err     error
+}
+
+func (fs *countingFrameSystem) FrameSystemConfig(ctx context.Context) (*framesystem.Config, error) {
+	fs.lookups++
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	return fs.partsFrameSystem.FrameSystemConfig(ctx)
+}
+
+func TestGoRefusesAnomalies(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+	f.anomalies = []interface{}{map[string]interface{}{"kind": AnomalyFallen, "squares": []interface{}{"d4", "e4"}}}
+
+	_, err := s.DoCommand(ctx, map[string]interface{}{"go": 1})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "d4 e4 fallen")
+	test.That(t, f.indexOf("grab"), test.ShouldEqual, -1)
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["anomalies"], test.ShouldResemble, []interface{}{
+		map[string]interface{}{"kind": AnomalyFallen, "squares": []string{"d4", "e4"}},
+	})
+}
+
+func TestStatusDoesntMove(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	// a move is in progress with a piece in the gripper
+	s.held = &heldPiece{from: "e2", to: "e4", z: 30}
+	f.holding = true
+	holdingAsked := false
+	s.gripper.(*inject.Gripper).IsHoldingSomethingFunc = func(ctx context.Context, extra map[string]interface{}) (gripper.HoldingStatus, error) {
+		holdingAsked = true
+		return gripper.HoldingStatus{IsHoldingSomething: true}, nil
+	}
+	s.doCommandLock.Lock()
+	defer s.doCommandLock.Unlock()
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["motion_profile"], test.ShouldEqual, defaultMotionProfile)
+	test.That(t, res["calls"], test.ShouldNotBeNil)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"set_motion_profile": "quiet"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["motion_profile"], test.ShouldEqual, "quiet")
+
+	test.That(t, holdingAsked, test.ShouldBeFalse)
+	test.That(t, f.events, test.ShouldBeEmpty)
+	test.That(t, s.held, test.ShouldNotBeNil)
+}
+
+func TestRecoverHeldPieceBeforeNextCommand(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	// an aborted e2 -> e4 left the pawn in the gripper
+	s.held = &heldPiece{from: "e2", to: "e4", z: 30}
+	f.holding = true
+	f.occupied["d2"] = 1
+
+	_, err := s.DoCommand(ctx, map[string]interface{}{
+		"move": map[string]interface{}{"from": "d2", "to": "d4", "n": 1},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s.held, test.ShouldBeNil)
+
+	e4 := fakeSquareCenter("e4")
+	placed := f.indexOf(fakeMoveEvent(r3.Vector{X: e4.X, Y: e4.Y, Z: 30}))
+	test.That(t, placed, test.ShouldBeGreaterThanOrEqualTo, 0)
+
+	d2 := fakeSquareCenter("d2")
+	pickup := f.indexOf(fakeMoveEvent(r3.Vector{X: d2.X, Y: d2.Y, Z: safeZ}))
+	test.That(t, pickup, test.ShouldBeGreaterThan, placed)
+
+	// the release of the recovered piece happens before the new command's first grab
+	test.That(t, f.indexOf("release"), test.ShouldBeLessThan, f.indexOf("grab"))
+}
+
+func TestRecoverHeldPieceDestinationOccupied(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	s.held = &heldPiece{from: "e2", to: "e4", z: 30}
+	f.holding = true
+	f.occupied["e4"] = 2 // something else got there first
+
+	err := s.recoverHeldPiece(ctx)
+	test.That(t, err, test.ShouldBeNil)
+
+	e2 := fakeSquareCenter("e2")
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e2.X, Y: e2.Y, Z: 30})), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, f.holding, test.ShouldBeFalse)
+}
+
+func TestRecoverHeldPieceUnknown(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	f.holding = true
+
+	err := s.recoverHeldPiece(ctx)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "operator")
+
+	drop := s.conf.dropPosition()
+	test.That(t, f.indexOf(fakeMoveEvent(drop)), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, f.holding, test.ShouldBeFalse)
+}
+
+func TestRecoverHeldPieceNowhereToGo(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	s.held = &heldPiece{from: "e2", to: "e4", z: 30}
+	f.holding = true
+	f.occupied["e4"] = 2
+	f.occupied["e2"] = 1
+
+	err := s.recoverHeldPiece(ctx)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldNotContainSubstring, "unknown")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "from e2 going to e4")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "e4: e4 is occupied, e2: e2 is occupied")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "operator")
+
+	drop := s.conf.dropPosition()
+	test.That(t, f.indexOf(fakeMoveEvent(drop)), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, f.holding, test.ShouldBeFalse)
+}
+
+func TestRecoverNotHolding(t *testing.T) {
+	s, f := newTestChess(t)
+	err := s.recoverHeldPiece(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(f.events), test.ShouldEqual, 0)
+}
+
+func TestParseSquare(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{"e2", "e2"},
+		{"E2", "e2"},
+		{" e2", "e2"},
+		{"h8\n", "h8"},
+		{"\tA1 ", "a1"},
+	} {
+		sq, err := ParseSquare(tc.in)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, sq.String(), test.ShouldEqual, tc.out)
+	}
+
+	for _, in := range []string{
+		"", " ", "e", "2", "e9", "e0", "i2", "22", "ee", "e22", "e 2", "2e", "X0", "é2",
+	} {
+		_, err := ParseSquare(in)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "a-h")
+	}
+}
+
+func TestMoveCmdValidate(t *testing.T) {
+	m := MoveCmd{From: " E2", To: "e4 ", N: 1}
+	test.That(t, m.Validate(), test.ShouldBeNil)
+	test.That(t, m.From, test.ShouldEqual, "e2")
+	test.That(t, m.To, test.ShouldEqual, "e4")
+
+	m = MoveCmd{From: "e2", To: "E2"}
+	test.That(t, m.Validate(), test.ShouldNotBeNil)
+
+	m = MoveCmd{From: "e", To: "e4"}
+	err := m.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "from")
+
+	m = MoveCmd{From: "e2"}
+	err = m.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "to")
+}
+
+func TestDoCommandRejectsBadSquare(t *testing.T) {
+	s, f := newTestChess(t)
+	_, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"move": map[string]interface{}{"from": "e", "to": "e4", "n": 1},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, f.indexOf("grab"), test.ShouldEqual, -1)
+}
+
+func TestCaptureRejectsOtherGrids(t *testing.T) {
+	s, f := newTestChess(t)
+	_, err := s.capture(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+
+	all, err := f.capture()
+	test.That(t, err, test.ShouldBeNil)
+	for range 36 {
+		all.Objects = append(all.Objects, all.Objects[0])
+	}
+	err = checkChessGrid(all)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "10x10")
+}
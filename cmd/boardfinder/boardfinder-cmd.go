@@ -1,11 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,18 +14,39 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <input.jpg> [output.jpg]\n", os.Args[0])
+	debugDir := flag.String("debug-dir", "", "write the board finder's intermediate images to this directory")
+	stamp := flag.Bool("stamp", false, "add a footer to the output with the time, options, corners and quality")
+	sheet := flag.String("fiducial-sheet", "", "write the corner tags for the fiducial mode to this PNG and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--debug-dir <dir>] [--stamp] <input.jpg> [output.jpg]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  If output is not specified, it will be <input>_output.jpg\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *sheet != "" {
+		b, err := viamchess.GenerateFiducialSheet()
+		if err == nil {
+			err = os.WriteFile(*sheet, b, 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing fiducial sheet: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	inputFile := os.Args[1]
+	inputFile := flag.Arg(0)
 
 	// Determine output file name
 	var outputFile string
-	if len(os.Args) >= 3 {
-		outputFile = os.Args[2]
+	if flag.NArg() >= 2 {
+		outputFile = flag.Arg(1)
 	} else {
 		// Generate output filename: input.jpg -> input_output.jpg
 		ext := filepath.Ext(inputFile)
@@ -45,33 +64,34 @@ func main() {
 	fmt.Printf("Image size: %dx%d\n", input.Bounds().Dx(), input.Bounds().Dy())
 
 	// Find board corners
-	corners, err := viamchess.FindBoard(input)
+	opts := viamchess.DefaultBoardFinderOptions()
+	var res viamchess.FindBoardResult
+	if *debugDir != "" {
+		res, err = findBoardDebug(input, *debugDir)
+	} else {
+		res = viamchess.FindBoardEx(input, opts)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error finding board corners: %v\n", err)
 		os.Exit(1)
 	}
+	corners := res.Board
 
-	if len(corners) != 4 {
-		fmt.Fprintf(os.Stderr, "Expected 4 corners, found %d\n", len(corners))
-		os.Exit(1)
+	if !res.Found {
+		fmt.Printf("Board not found (%s), using default corners\n", res.Reason)
+		if res.Err != nil {
+			fmt.Printf("  %v\n", res.Err)
+		}
 	}
 
 	fmt.Printf("Found corners:\n")
-	fmt.Printf("  Top-left:     (%d, %d)\n", corners[0].X, corners[0].Y)
-	fmt.Printf("  Top-right:    (%d, %d)\n", corners[1].X, corners[1].Y)
-	fmt.Printf("  Bottom-right: (%d, %d)\n", corners[2].X, corners[2].Y)
-	fmt.Printf("  Bottom-left:  (%d, %d)\n", corners[3].X, corners[3].Y)
+	fmt.Printf("  Top-left:     (%d, %d)\n", corners.TopLeft.X, corners.TopLeft.Y)
+	fmt.Printf("  Top-right:    (%d, %d)\n", corners.TopRight.X, corners.TopRight.Y)
+	fmt.Printf("  Bottom-right: (%d, %d)\n", corners.BottomRight.X, corners.BottomRight.Y)
+	fmt.Printf("  Bottom-left:  (%d, %d)\n", corners.BottomLeft.X, corners.BottomLeft.Y)
 
 	// Draw corners on output image
-	output := image.NewRGBA(input.Bounds())
-	draw.Draw(output, input.Bounds(), input, image.Point{}, draw.Src)
-
-	// Mark detected corners with red circles and crosses
-	red := color.RGBA{255, 0, 0, 255}
-	for _, corner := range corners {
-		drawCircle(output, corner.X, corner.Y, 10, red)
-		drawCross(output, corner.X, corner.Y, 15, red)
-	}
+	output := viamchess.RenderCornerOverlay(input, res, viamchess.OverlayOptions{Stamp: *stamp, Params: opts})
 
 	// Save output image
 	err = rimage.WriteImageToFile(outputFile, output)
@@ -83,27 +103,29 @@ func main() {
 	fmt.Printf("Saved output image to %s\n", outputFile)
 }
 
-func drawCircle(img *image.RGBA, cx, cy, radius int, c color.Color) {
-	for angle := 0.0; angle < 360; angle += 1 {
-		x := cx + int(float64(radius)*math.Cos(angle*math.Pi/180))
-		y := cy + int(float64(radius)*math.Sin(angle*math.Pi/180))
-		if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-			img.Set(x, y, c)
-		}
+// findBoardDebug finds the corners and writes the debug images to dir as <name>.png.
+func findBoardDebug(input image.Image, dir string) (viamchess.FindBoardResult, error) {
+	res, dbg, err := viamchess.FindBoardDebug(input)
+	if err != nil {
+		return res, err
+	}
+	if dbg.CornerError != nil {
+		fmt.Printf("Warning: %v\n", dbg.CornerError)
 	}
-}
 
-func drawCross(img *image.RGBA, cx, cy, size int, c color.Color) {
-	for d := -size; d <= size; d++ {
-		// Horizontal line
-		x := cx + d
-		if x >= 0 && x < img.Bounds().Max.X && cy >= 0 && cy < img.Bounds().Max.Y {
-			img.Set(x, cy, c)
-		}
-		// Vertical line
-		y := cy + d
-		if cx >= 0 && cx < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-			img.Set(cx, y, c)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return res, err
+	}
+
+	for name, img := range dbg.Images() {
+		fn := filepath.Join(dir, name+".png")
+		err = rimage.WriteImageToFile(fn, img)
+		if err != nil {
+			return res, err
 		}
+		fmt.Printf("Saved debug image to %s\n", fn)
 	}
+
+	return res, nil
 }
@@ -46,6 +46,14 @@ func realMain() error {
 		return fmt.Errorf("need command")
 	}
 
+	move := viamchess.MoveCmd{From: *from, To: *to, N: *n}
+	if *cmd == "move" {
+		err := move.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
 	cfg := viamchess.ChessConfig{
 		PieceFinder: "piece-finder",
 		Arm:         "arm",
@@ -79,7 +87,7 @@ func realMain() error {
 	switch *cmd {
 	case "move":
 		res, err := thing.DoCommand(ctx, map[string]interface{}{
-			"move": map[string]interface{}{"from": *from, "to": *to, "n": *n},
+			"move": map[string]interface{}{"from": move.From, "to": move.To, "n": move.N},
 		})
 		if err != nil {
 			return err
@@ -108,6 +116,4 @@ func realMain() error {
 	default:
 		return fmt.Errorf("unknown command [%s]", *cmd)
 	}
-
-	return nil
 }
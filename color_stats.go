@@ -0,0 +1,206 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// colorBand is the colors of some of a square's points, see colorBands.
+type colorBand struct {
+	points       int
+	mean, stddev [3]float64 // r, g, b
+}
+
+func (b *colorBand) add(r, g, bl uint8, sum, sq *[3]float64) {
+	for i, v := range [3]float64{float64(r), float64(g), float64(bl)} {
+		sum[i] += v
+		sq[i] += v * v
+	}
+	b.points++
+}
+
+func (b *colorBand) finish(sum, sq [3]float64) {
+	if b.points == 0 {
+		return
+	}
+	n := float64(b.points)
+	for i := range 3 {
+		b.mean[i] = sum[i] / n
+		b.stddev[i] = math.Sqrt(max(0, sq[i]/n-b.mean[i]*b.mean[i]))
+	}
+}
+
+func (b colorBand) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"points":    b.points,
+		"mean":      b.mean[:],
+		"stddev":    b.stddev[:],
+		"luminance": .299*b.mean[0] + .587*b.mean[1] + .114*b.mean[2],
+	}
+}
+
+// squareColorBands are the colors of the points of the square pc is the points over that are
+// its surface and that are a piece, by estimatePieceColor's colorBands.
+func squareColorBands(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig) (colorBand, colorBand) {
+	band, top := colorBands(pc, height, conf)
+	var surface, piece colorBand
+	var surfaceSum, surfaceSq, pieceSum, pieceSq [3]float64
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if d == nil || !d.HasColor() {
+			return true
+		}
+		r, g, b := d.RGB255()
+		switch h := height(p); {
+		case h > top:
+			piece.add(r, g, b, &pieceSum, &pieceSq)
+		case h <= band:
+			surface.add(r, g, b, &surfaceSum, &surfaceSq)
+		}
+		return true
+	})
+	surface.finish(surfaceSum, surfaceSq)
+	piece.finish(pieceSum, pieceSq)
+	return surface, piece
+}
+
+// squaresSurface is the board under squares, see fitBoardSurface.
+func squaresSurface(squares []squareInfo) boardSurface {
+	clouds := make([]pointcloud.PointCloud, len(squares))
+	for i, sq := range squares {
+		clouds[i] = sq.pc
+	}
+	return fitBoardSurface(clouds)
+}
+
+// sampleSquares is the sample_squares command for squares: each one's surface and piece colors,
+// see squareColorBands, and what estimatePieceColor made of them.
+func sampleSquares(squares []squareInfo, conf *PieceFinderConfig) map[string]interface{} {
+	surface := squaresSurface(squares)
+	out := make([]interface{}, 0, len(squares))
+	for _, sq := range squares {
+		base, piece := squareColorBands(sq.pc, surface.heights(sq.pc), conf)
+		out = append(out, map[string]interface{}{
+			"name":    sq.name,
+			"piece":   pieceLabels[sq.color],
+			"points":  sq.pc.Size(),
+			"surface": base.toMap(),
+			"above":   piece.toMap(),
+		})
+	}
+	return map[string]interface{}{"squares": out}
+}
+
+// homeRank is whether rank is one the pieces start on, the first and last two of an n x n board.
+func homeRank(rank, n int) bool {
+	return rank <= 2 || rank > n-2
+}
+
+// twoMeans is the centers, lower then higher, of the two clusters of values k-means finds, and
+// how many values are in each.
+func twoMeans(values []float64) ([2]float64, [2]int) {
+	lo, hi := slices.Min(values), slices.Max(values)
+	centers := [2]float64{lo, hi}
+	var counts [2]int
+	for range 50 {
+		var sums [2]float64
+		counts = [2]int{}
+		for _, v := range values {
+			i := 0
+			if math.Abs(v-centers[1]) < math.Abs(v-centers[0]) {
+				i = 1
+			}
+			sums[i] += v
+			counts[i]++
+		}
+		next := centers
+		for i := range 2 {
+			if counts[i] > 0 {
+				next[i] = sums[i] / float64(counts[i])
+			}
+		}
+		if next == centers {
+			break
+		}
+		centers = next
+	}
+	return centers, counts
+}
+
+// suggestThresholds is the suggest_thresholds command for squares, an n x n board's: the
+// luminance of the piece points of the home rank squares with pieces on them split in two by
+// k-means, so the black pieces' center, the white pieces' and the cutoff half way between them,
+// with the darkest white point and the brightest black one to show how far apart they are.
+func suggestThresholds(squares []squareInfo, conf *PieceFinderConfig, n int) (map[string]interface{}, error) {
+	surface := squaresSurface(squares)
+	var lums []float64
+	used := 0
+	for _, sq := range squares {
+		if !homeRank(sq.rank, n) || sq.color == 0 {
+			continue
+		}
+		height := surface.heights(sq.pc)
+		_, top := colorBands(sq.pc, height, conf)
+		sq.pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			if d != nil && d.HasColor() && height(p) > top {
+				lums = append(lums, luminance(d.RGB255()))
+			}
+			return true
+		})
+		used++
+	}
+	if len(lums) < 2 {
+		return nil, errors.New("no pieces on the home ranks to go by, set the pieces up first")
+	}
+
+	centers, counts := twoMeans(lums)
+	cutoff := (centers[0] + centers[1]) / 2
+	darkestWhite, brightestBlack := math.Inf(1), math.Inf(-1)
+	for _, v := range lums {
+		if v >= cutoff {
+			darkestWhite = min(darkestWhite, v)
+		} else {
+			brightestBlack = max(brightestBlack, v)
+		}
+	}
+	return map[string]interface{}{
+		"squares": used,
+		"points":  len(lums),
+		"black":   map[string]interface{}{"center": centers[0], "points": counts[0], "max": brightestBlack},
+		"white":   map[string]interface{}{"center": centers[1], "points": counts[1], "min": darkestWhite},
+		"cutoff":  cutoff,
+	}, nil
+}
+
+// sampleSquares is the sample_squares command on the current frame.
+func (bc *PieceFinder) sampleSquares(ctx context.Context) (map[string]interface{}, error) {
+	_, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("sample_squares", squares); err != nil {
+		return nil, err
+	}
+	return sampleSquares(squares, bc.conf), nil
+}
+
+// suggestThresholds is the suggest_thresholds command on the current frame.
+func (bc *PieceFinder) suggestThresholds(ctx context.Context) (map[string]interface{}, error) {
+	_, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("suggest_thresholds", squares); err != nil {
+		return nil, err
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+	return suggestThresholds(squares, bc.conf, opts.gridSize())
+}
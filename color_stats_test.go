@@ -0,0 +1,102 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestTwoMeans(t *testing.T) {
+	centers, counts := twoMeans([]float64{10, 12, 14, 200, 210, 220, 230})
+	test.That(t, centers[0], test.ShouldAlmostEqual, 12)
+	test.That(t, centers[1], test.ShouldAlmostEqual, 215)
+	test.That(t, counts, test.ShouldResemble, [2]int{3, 4})
+
+	// all the same is one cluster at it
+	centers, _ = twoMeans([]float64{5, 5, 5})
+	test.That(t, centers, test.ShouldResemble, [2]float64{5, 5})
+}
+
+func TestColorBand(t *testing.T) {
+	var b colorBand
+	var sum, sq [3]float64
+	b.add(10, 20, 30, &sum, &sq)
+	b.add(30, 20, 10, &sum, &sq)
+	b.finish(sum, sq)
+	test.That(t, b.points, test.ShouldEqual, 2)
+	test.That(t, b.mean, test.ShouldResemble, [3]float64{20, 20, 20})
+	test.That(t, b.stddev, test.ShouldResemble, [3]float64{10, 0, 10})
+
+	// nothing in it is all zeros
+	var empty colorBand
+	empty.finish([3]float64{}, [3]float64{})
+	test.That(t, empty.toMap()["points"], test.ShouldEqual, 0)
+	test.That(t, empty.mean, test.ShouldResemble, [3]float64{})
+}
+
+func TestSampleSquaresBoard4(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}, &frame, &pc)
+
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"sample_squares": true})
+	test.That(t, err, test.ShouldBeNil)
+	squares := ret["squares"].([]interface{})
+	test.That(t, len(squares), test.ShouldEqual, 64)
+	byName := map[string]map[string]interface{}{}
+	for _, s := range squares {
+		sq := s.(map[string]interface{})
+		byName[sq["name"].(string)] = sq
+		surface := sq["surface"].(map[string]interface{})
+		above := sq["above"].(map[string]interface{})
+		test.That(t, surface["points"], test.ShouldBeGreaterThan, 0)
+		test.That(t, surface["points"].(int)+above["points"].(int), test.ShouldBeLessThanOrEqualTo, sq["points"])
+		if sq["piece"] == "empty" {
+			test.That(t, above["points"], test.ShouldBeLessThanOrEqualTo, minPiecePoints)
+		}
+	}
+	// white has played e4: a white pawn on d2, nothing on e2, a black one on d7
+	test.That(t, byName["d2"]["piece"], test.ShouldEqual, "W")
+	test.That(t, byName["e2"]["piece"], test.ShouldEqual, "empty")
+	test.That(t, byName["d7"]["piece"], test.ShouldEqual, "B")
+	white := byName["d2"]["above"].(map[string]interface{})
+	black := byName["d7"]["above"].(map[string]interface{})
+	test.That(t, white["points"], test.ShouldBeGreaterThan, minPiecePoints)
+	test.That(t, white["luminance"], test.ShouldBeGreaterThan, black["luminance"])
+	test.That(t, len(white["stddev"].([]float64)), test.ShouldEqual, 3)
+
+	ret, err = pf.DoCommand(ctx, map[string]interface{}{"suggest_thresholds": true})
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("thresholds %v", ret)
+	test.That(t, ret["squares"], test.ShouldEqual, 31)
+	w, b := ret["white"].(map[string]interface{}), ret["black"].(map[string]interface{})
+	test.That(t, w["center"], test.ShouldBeGreaterThan, ret["cutoff"])
+	test.That(t, b["center"], test.ShouldBeLessThan, ret["cutoff"])
+	test.That(t, w["min"], test.ShouldBeGreaterThanOrEqualTo, ret["cutoff"])
+	test.That(t, b["max"], test.ShouldBeLessThan, ret["cutoff"])
+	// the pieces' mean luminances are on the side of it they're on
+	test.That(t, white["luminance"], test.ShouldBeGreaterThan, ret["cutoff"])
+	test.That(t, black["luminance"], test.ShouldBeLessThan, ret["cutoff"])
+
+	// the board camera has them too
+	bc := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Rotation: "180"}, input, pc)
+	fromCamera, err := bc.DoCommand(ctx, map[string]interface{}{"sample_squares": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(fromCamera["squares"].([]interface{})), test.ShouldEqual, 64)
+	fromCamera, err = bc.DoCommand(ctx, map[string]interface{}{"suggest_thresholds": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromCamera["cutoff"], test.ShouldAlmostEqual, ret["cutoff"], 20)
+
+	// but not without a point cloud
+	bc = newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Rotation: "180"}, input, nil)
+	_, err = bc.DoCommand(ctx, map[string]interface{}{"sample_squares": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
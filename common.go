@@ -1,7 +1,34 @@
 package viamchess
 
 import (
+	"sync"
+
+	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/resource"
+	generic "go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/services/vision"
 )
 
 var family = resource.ModelNamespace("erh").WithFamily("viam-chess")
+
+var registerOnce sync.Once
+
+// RegisterModels adds the chess, piece-finder and board-camera models to the RDK registry. The module calls it
+// from main, just importing the package (for FindBoard, say) registers nothing. Safe to call more
+// than once.
+func RegisterModels() {
+	registerOnce.Do(func() {
+		registerChess()
+		registerPieceFinder()
+		registerBoardCamera()
+	})
+}
+
+// Models are the models RegisterModels adds, the ones the module serves and meta.json lists.
+func Models() []resource.APIModel {
+	return []resource.APIModel{
+		{API: generic.API, Model: ChessModel},
+		{API: vision.API, Model: PieceFinderModel},
+		{API: camera.API, Model: BoardCameraModel},
+	}
+}
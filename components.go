@@ -0,0 +1,106 @@
+package viamchess
+
+// labelComponents gives every 4-connected patch of true pixels in the width x height mask its
+// own label, 1 up, with 0 for the false pixels. sizes[l] is how many pixels have label l.
+// It's the two pass union-find labeling: the first pass joins each pixel to the one left of
+// it and the one above, the second gives every pixel its root's label.
+func labelComponents(mask []bool, width, height int) (labels []int32, sizes []int) {
+	labels = make([]int32, len(mask))
+	parent := []int32{0}
+
+	find := func(l int32) int32 {
+		root := l
+		for parent[root] != root {
+			root = parent[root]
+		}
+		for parent[l] != root {
+			parent[l], l = root, parent[l]
+		}
+		return root
+	}
+	union := func(a, b int32) int32 {
+		a, b = find(a), find(b)
+		if a < b {
+			parent[b] = a
+			return a
+		}
+		parent[a] = b
+		return b
+	}
+
+	for y := range height {
+		row := y * width
+		for x := range width {
+			i := row + x
+			if !mask[i] {
+				continue
+			}
+			var left, up int32
+			if x > 0 {
+				left = labels[i-1]
+			}
+			if y > 0 {
+				up = labels[i-width]
+			}
+			switch {
+			case left != 0 && up != 0 && left != up:
+				labels[i] = union(left, up)
+			case left != 0:
+				labels[i] = left
+			case up != 0:
+				labels[i] = up
+			default:
+				labels[i] = int32(len(parent))
+				parent = append(parent, labels[i])
+			}
+		}
+	}
+
+	// a label's parent is never bigger than it, so going up the labels in order resolves each
+	// one's root from roots already resolved, and numbers the roots in the order they were
+	// first seen
+	final := make([]int32, len(parent))
+	n := int32(0)
+	for l := 1; l < len(parent); l++ {
+		if parent[l] == int32(l) {
+			n++
+			final[l] = n
+		} else {
+			final[l] = final[parent[l]]
+		}
+	}
+	sizes = make([]int, n+1)
+	for i, l := range labels {
+		if l != 0 {
+			labels[i] = final[l]
+			sizes[labels[i]]++
+		}
+	}
+	return labels, sizes
+}
+
+// fillHoles sets every patch of false pixels smaller than maxHole that doesn't touch the edge
+// of the mask, so it's surrounded by true ones.
+func fillHoles(mask []bool, width, height, maxHole int) {
+	inverse := make([]bool, len(mask))
+	for i, m := range mask {
+		inverse[i] = !m
+	}
+	labels, sizes := labelComponents(inverse, width, height)
+
+	outside := make([]bool, len(sizes))
+	for x := range width {
+		outside[labels[x]] = true
+		outside[labels[(height-1)*width+x]] = true
+	}
+	for y := range height {
+		outside[labels[y*width]] = true
+		outside[labels[y*width+width-1]] = true
+	}
+
+	for i, l := range labels {
+		if l != 0 && !outside[l] && sizes[l] < maxHole {
+			mask[i] = true
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package viamchess
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// parseMask is rows of '#' for set pixels, anything else for unset ones.
+func parseMask(rows ...string) (mask []bool, width, height int) {
+	width, height = len(rows[0]), len(rows)
+	mask = make([]bool, width*height)
+	for y, row := range rows {
+		for x, c := range row {
+			mask[y*width+x] = c == '#'
+		}
+	}
+	return mask, width, height
+}
+
+func TestLabelComponents(t *testing.T) {
+	mask, width, height := parseMask(
+		"#.#...####",
+		"#.#...#..#",
+		"###...#..#",
+		"......####",
+		"..........",
+		"##.......#",
+		".#.......#",
+	)
+	labels, sizes := labelComponents(mask, width, height)
+
+	// the U starts out as two labels that get joined along its bottom
+	test.That(t, sizes, test.ShouldResemble, []int{0, 7, 12, 3, 2})
+	test.That(t, labels[0], test.ShouldEqual, int32(1))
+	test.That(t, labels[2], test.ShouldEqual, int32(1))
+	test.That(t, labels[2*width+1], test.ShouldEqual, int32(1))
+	test.That(t, labels[6], test.ShouldEqual, int32(2))
+	test.That(t, labels[3*width+9], test.ShouldEqual, int32(2))
+	test.That(t, labels[6*width+1], test.ShouldEqual, int32(3))
+	test.That(t, labels[6*width+9], test.ShouldEqual, int32(4))
+	test.That(t, labels[1], test.ShouldEqual, int32(0))
+
+	// the ring's hole is 4 pixels, the gap in the U runs out the top so it isn't a hole
+	filled := append([]bool(nil), mask...)
+	fillHoles(filled, width, height, 4)
+	test.That(t, filled, test.ShouldResemble, mask)
+
+	fillHoles(filled, width, height, 5)
+	for y := range height {
+		for x := range width {
+			hole := (x == 7 || x == 8) && (y == 1 || y == 2)
+			test.That(t, filled[y*width+x], test.ShouldEqual, mask[y*width+x] || hole)
+		}
+	}
+
+	// only the U and the ring are big enough, and the ring comes back solid
+	glare := glareBlobs(mask, width, height, 5)
+	for y := range height {
+		for x := range width {
+			want := (x <= 2 && y <= 2 && x != 1) || (x == 1 && y == 2) || (x >= 6 && y <= 3)
+			test.That(t, glare[y*width+x], test.ShouldEqual, want)
+		}
+	}
+	test.That(t, glareBlobs(mask, width, height, 13), test.ShouldBeNil)
+}
+
+func BenchmarkGlareBlobs(b *testing.B) {
+	// a 1080p mask of diagonal stripes broken into blobs of all sizes
+	width, height := 1920, 1080
+	mask := make([]bool, width*height)
+	for y := range height {
+		for x := range width {
+			mask[y*width+x] = (x+y)%40 < 15 && (x*7+y*3)%97 > 5
+		}
+	}
+	b.Run("label", func(b *testing.B) {
+		for b.Loop() {
+			labelComponents(mask, width, height)
+		}
+	})
+	b.Run("blobs", func(b *testing.B) {
+		for b.Loop() {
+			glareBlobs(mask, width, height, 300)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package viamchess
+
+import (
+	"time"
+
+	"github.com/golang/geo/r2"
+)
+
+const (
+	defaultSmoothFrames  = 5
+	defaultMaxCornerJump = 15.0
+	defaultJumpFrames    = 3
+)
+
+// cornerSmoother steadies the board corners from frame to frame. Detections near the average
+// are folded into an exponential moving average over about smoothFrames frames. One that jumps
+// more than maxJump is ignored, unless jumpFrames in a row agree on where it jumped to, in which
+// case the board really moved and the average starts over there.
+type cornerSmoother struct {
+	smoothFrames int
+	maxJump      float64
+	jumpFrames   int
+
+	avg     []r2.Point // nil until the first detection
+	raw     []r2.Point // the last detection
+	pending []r2.Point // where it's jumped to, if it has
+	count   int        // frames in a row at pending
+
+	// confidence is the last detection's FindBoardResult.CornerConfidence, when the time it was made
+	confidence [4]float64
+	when       time.Time
+
+	// frozen uses the average as it is instead of looking for the board, once there is one
+	frozen bool
+
+	// ttl is how long after a detection the average is used as it is, like frozen, 0 for every
+	// capture to look for the board; until is when that runs out
+	ttl   time.Duration
+	until time.Time
+	// detections is how many times the board was looked for, for get_corners
+	detections int
+}
+
+func newCornerSmoother(conf *PieceFinderConfig) cornerSmoother {
+	return cornerSmoother{
+		smoothFrames: conf.smoothFrames(),
+		maxJump:      conf.maxCornerJump(),
+		jumpFrames:   conf.jumpFrames(),
+		ttl:          conf.cornerCacheTTL(),
+	}
+}
+
+// maxCornerDistance is how far the corner that moved most between a and b moved.
+func maxCornerDistance(a, b []r2.Point) float64 {
+	d := 0.0
+	for i := range a {
+		d = max(d, a[i].Sub(b[i]).Norm())
+	}
+	return d
+}
+
+// update takes this frame's detection and returns the corners to use.
+// A detection that didn't find the board leaves the average alone.
+func (cs *cornerSmoother) update(res FindBoardResult) []r2.Point {
+	cs.detections++
+	if !res.Found {
+		if cs.avg == nil {
+			return res.SubPixelCorners
+		}
+		return cs.corners()
+	}
+
+	c := res.SubPixelCorners
+	cs.raw = append(cs.raw[:0], c...)
+	cs.confidence, cs.when = res.CornerConfidence, time.Now()
+	if cs.ttl > 0 {
+		cs.until = cs.when.Add(cs.ttl)
+	}
+
+	if cs.avg == nil {
+		cs.avg = append([]r2.Point(nil), c...)
+		return cs.corners()
+	}
+
+	if maxCornerDistance(c, cs.avg) <= cs.maxJump {
+		alpha := 2 / float64(cs.smoothFrames+1)
+		for i := range cs.avg {
+			cs.avg[i] = cs.avg[i].Add(c[i].Sub(cs.avg[i]).Mul(alpha))
+		}
+		cs.pending, cs.count = nil, 0
+		return cs.corners()
+	}
+
+	if cs.pending != nil && maxCornerDistance(c, cs.pending) <= cs.maxJump {
+		cs.count++
+	} else {
+		cs.count = 1
+	}
+	cs.pending = append(cs.pending[:0], c...)
+
+	if cs.count >= cs.jumpFrames {
+		copy(cs.avg, c)
+		cs.pending, cs.count = nil, 0
+	}
+	return cs.corners()
+}
+
+// reset forgets every detection, so the next one is taken as it is. Frozen stays frozen, on that one.
+func (cs *cornerSmoother) reset() {
+	cs.avg, cs.raw, cs.pending, cs.count = nil, nil, nil, 0
+	cs.confidence, cs.when = [4]float64{}, time.Time{}
+	cs.invalidate()
+}
+
+// invalidate has the next capture look for the board, whatever's left of the ttl, folding what it
+// finds into the average.
+func (cs *cornerSmoother) invalidate() {
+	cs.until = time.Time{}
+}
+
+// cached is true while the last detection is younger than the ttl.
+func (cs *cornerSmoother) cached() bool {
+	return cs.avg != nil && time.Now().Before(cs.until)
+}
+
+// skip is true when the board shouldn't be looked for, the average is frozen or cached.
+func (cs *cornerSmoother) skip() bool {
+	return cs.avg != nil && (cs.frozen || cs.cached())
+}
+
+// corners is a copy of the current average.
+func (cs *cornerSmoother) corners() []r2.Point {
+	return append([]r2.Point(nil), cs.avg...)
+}
@@ -0,0 +1,243 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r2"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+var smootherTruth = []r2.Point{{X: 300, Y: 50}, {X: 960, Y: 80}, {X: 940, Y: 660}, {X: 340, Y: 630}}
+
+func shifted(corners []r2.Point, dx, dy float64) FindBoardResult {
+	res := FindBoardResult{Found: true}
+	for _, c := range corners {
+		res.SubPixelCorners = append(res.SubPixelCorners, r2.Point{X: c.X + dx, Y: c.Y + dy})
+	}
+	return res
+}
+
+// meanSquaredError is how far, on average, corners are from smootherTruth.
+func meanSquaredError(corners []r2.Point) float64 {
+	sum := 0.0
+	for i, c := range corners {
+		d := c.Sub(smootherTruth[i])
+		sum += d.Dot(d)
+	}
+	return sum / float64(len(corners))
+}
+
+func TestCornerSmootherJitter(t *testing.T) {
+	cs := newCornerSmoother(&PieceFinderConfig{})
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var in, out float64
+	n := 0
+	for i := range 200 {
+		res := FindBoardResult{Found: true}
+		for _, c := range smootherTruth {
+			res.SubPixelCorners = append(res.SubPixelCorners, r2.Point{X: c.X + rng.NormFloat64()*3, Y: c.Y + rng.NormFloat64()*3})
+		}
+		smoothed := cs.update(res)
+		if i < 20 {
+			continue // let it settle
+		}
+		in += meanSquaredError(res.SubPixelCorners)
+		out += meanSquaredError(smoothed)
+		n++
+	}
+	in /= float64(n)
+	out /= float64(n)
+	test.That(t, out, test.ShouldBeLessThan, in/2)
+}
+
+func TestCornerSmootherJumps(t *testing.T) {
+	cs := newCornerSmoother(&PieceFinderConfig{})
+	cs.update(shifted(smootherTruth, 0, 0))
+
+	// one wild frame is ignored
+	got := cs.update(shifted(smootherTruth, 60, 0))
+	test.That(t, got, test.ShouldResemble, smootherTruth)
+	test.That(t, cs.count, test.ShouldEqual, 1)
+
+	// and a good one after it clears it
+	cs.update(shifted(smootherTruth, 0, 0))
+	test.That(t, cs.count, test.ShouldEqual, 0)
+
+	// not finding the board doesn't move anything either
+	got = cs.update(FindBoardResult{SubPixelCorners: defaultCornersSubPixel(1280, 720)})
+	test.That(t, got, test.ShouldResemble, smootherTruth)
+
+	// but if it stays moved, the board moved
+	for range defaultJumpFrames - 1 {
+		got = cs.update(shifted(smootherTruth, 60, 0))
+		test.That(t, got, test.ShouldResemble, smootherTruth)
+	}
+	got = cs.update(shifted(smootherTruth, 61, 0))
+	test.That(t, got, test.ShouldResemble, shifted(smootherTruth, 61, 0).SubPixelCorners)
+	test.That(t, cs.count, test.ShouldEqual, 0)
+}
+
+func TestCornerSmootherOff(t *testing.T) {
+	cs := newCornerSmoother(&PieceFinderConfig{SmoothFrames: 1})
+	cs.update(shifted(smootherTruth, 0, 0))
+	got := cs.update(shifted(smootherTruth, 2, -1))
+	test.That(t, got, test.ShouldResemble, shifted(smootherTruth, 2, -1).SubPixelCorners)
+}
+
+func TestPieceFinderCornersCommand(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	res, err := pf.DoCommand(ctx, map[string]interface{}{"corners": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(res["corners"].([]interface{})), test.ShouldEqual, 4)
+	test.That(t, res["corners"], test.ShouldResemble, res["raw"])
+	test.That(t, res["pending"], test.ShouldEqual, 0)
+}
+
+func TestPieceFinderFreezeCorners(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	// a frame with no board in it, every capture looked at
+	flat := image.NewRGBA(input.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+
+	frame := image.Image(input)
+	conf := &PieceFinderConfig{Input: "cam", MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	capture := func() error {
+		_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		return err
+	}
+	do := func(cmd string) map[string]interface{} {
+		res, err := pf.DoCommand(ctx, map[string]interface{}{cmd: true})
+		test.That(t, err, test.ShouldBeNil)
+		return res
+	}
+
+	test.That(t, do("get_corners")["corners"], test.ShouldResemble, []interface{}{})
+	test.That(t, capture(), test.ShouldBeNil)
+	found := do("get_corners")
+	test.That(t, len(found["corners"].([]interface{})), test.ShouldEqual, 4)
+	test.That(t, found["source"], test.ShouldEqual, "detected")
+	test.That(t, found["frozen"], test.ShouldBeFalse)
+	test.That(t, len(found["confidence"].([]interface{})), test.ShouldEqual, 4)
+	test.That(t, found["time"], test.ShouldNotBeNil)
+
+	// frozen, the board isn't looked for, it's where it was, even when it's gone
+	test.That(t, do("freeze")["frozen"], test.ShouldBeTrue)
+	test.That(t, capture(), test.ShouldBeNil)
+	frame = flat
+	test.That(t, capture(), test.ShouldBeNil)
+	frozen := do("get_corners")
+	test.That(t, frozen["frozen"], test.ShouldBeTrue)
+	test.That(t, frozen["corners"], test.ShouldResemble, found["corners"])
+	test.That(t, frozen["time"], test.ShouldEqual, found["time"])
+
+	// a refresh looks on the next capture, frozen or not, and with nothing to go by fails
+	do("refresh")
+	test.That(t, capture(), test.ShouldNotBeNil)
+	frame = input
+	test.That(t, capture(), test.ShouldBeNil)
+	refreshed := do("get_corners")
+	test.That(t, refreshed["frozen"], test.ShouldBeTrue)
+	test.That(t, refreshed["time"], test.ShouldNotEqual, found["time"])
+	test.That(t, capture(), test.ShouldBeNil)
+	test.That(t, do("get_corners")["time"], test.ShouldEqual, refreshed["time"])
+
+	test.That(t, do("unfreeze")["frozen"], test.ShouldBeFalse)
+	test.That(t, capture(), test.ShouldBeNil)
+	test.That(t, do("get_corners")["time"], test.ShouldNotEqual, refreshed["time"])
+
+	// a reconfigure is a new piece finder, not frozen
+	test.That(t, newTestPieceFinder(t, conf, &frame, &pc).corners.frozen, test.ShouldBeFalse)
+
+	// pinned corners are what's used
+	pf.pinned = board13Corners
+	test.That(t, do("get_corners"), test.ShouldResemble, map[string]interface{}{"corners": cornersJSON(board13Corners), "source": "pinned"})
+}
+
+func TestPieceFinderCornerCache(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// the cloud doesn't move with the frame, so the corners have to come from the image
+	frame := image.Image(input)
+	conf := &PieceFinderConfig{Input: "cam", CornerSource: cornerSourceImage, MinVisibleScore: -1, ChangeThreshold: -1, CornerCacheTTL: 60}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	capture := func() {
+		_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	do := func(cmd string) map[string]interface{} {
+		res, err := pf.DoCommand(ctx, map[string]interface{}{cmd: true})
+		test.That(t, err, test.ShouldBeNil)
+		return res
+	}
+
+	// the board is looked for once, the next captures use what it found
+	for range 3 {
+		capture()
+	}
+	corners := do("get_corners")
+	test.That(t, corners["detections"], test.ShouldEqual, 1)
+	test.That(t, corners["cached"], test.ShouldBeTrue)
+	test.That(t, corners["frozen"], test.ShouldBeFalse)
+
+	// until it's told not to
+	test.That(t, do("invalidate_cache")["invalidated"], test.ShouldBeTrue)
+	test.That(t, pf.last, test.ShouldBeNil)
+	capture()
+	capture()
+	test.That(t, do("get_corners")["detections"], test.ShouldEqual, 2)
+
+	// or the ttl runs out
+	pf.corners.until = time.Now()
+	capture()
+	capture()
+	test.That(t, do("get_corners")["detections"], test.ShouldEqual, 3)
+
+	// or the board moves out from under the corners
+	frame = translated(input, image.Pt(25, 0))
+	for range defaultShiftFrames {
+		capture()
+	}
+	test.That(t, do("get_corners")["detections"], test.ShouldEqual, 4)
+	test.That(t, len(do("events")["events"].([]interface{})), test.ShouldEqual, 1)
+
+	// with no ttl, every capture looks
+	conf.CornerCacheTTL = 0
+	pf = newTestPieceFinder(t, conf, &frame, &pc)
+	capture()
+	capture()
+	test.That(t, do("get_corners")["detections"], test.ShouldEqual, 2)
+	test.That(t, do("get_corners")["cached"], test.ShouldBeFalse)
+}
@@ -0,0 +1,262 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"time"
+)
+
+// How createDebugImage draws the frame under the grid, see PieceFinderConfig.DebugTheme.
+const (
+	themeImage      = "image"                  // the frame itself
+	themeHue        = "hue"                    // every pixel at full saturation and value
+	themeSaturation = "saturation"             // saturation as gray
+	themeValue      = "value"                  // the brightest channel as gray, for exposure
+	themeSquareMean = "square-mean"            // each square filled with its mean color
+	themeDelta      = "delta-from-calibration" // how far each square's mean is from the empty board
+)
+
+// deltaRange is how far (RGB distance) a square's mean color has to be from the empty board
+// to be drawn white in the delta-from-calibration theme. It's fixed so frames compare.
+const deltaRange = 80.0
+
+func validateDebugTheme(theme string) error {
+	switch theme {
+	case "", themeImage, themeHue, themeSaturation, themeValue, themeSquareMean, themeDelta:
+		return nil
+	}
+	return fmt.Errorf("bad debug-theme %q, needs to be %s, %s, %s, %s, %s or %s",
+		theme, themeImage, themeHue, themeSaturation, themeValue, themeSquareMean, themeDelta)
+}
+
+// squareMeanColors is the mean color of the pixels inside each square's bounds.
+func squareMeanColors(img image.Image, squares []squareInfo) []color.RGBA {
+	res := make([]color.RGBA, len(squares))
+	for i, sq := range squares {
+		r := sq.originalBounds.Intersect(img.Bounds())
+		var sum [3]uint64
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				cr, cg, cb, _ := img.At(x, y).RGBA()
+				sum[0] += uint64(cr >> 8)
+				sum[1] += uint64(cg >> 8)
+				sum[2] += uint64(cb >> 8)
+			}
+		}
+		if n := uint64(r.Dx() * r.Dy()); n > 0 {
+			res[i] = color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 255}
+		}
+	}
+	return res
+}
+
+// colorDistance is the RGB distance between a and b.
+func colorDistance(a, b color.RGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// hsv is c's hue in degrees and its saturation and value in [0, 1].
+func hsv(c color.RGBA) (h, s, v float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := max(r, g, b), min(r, g, b)
+	v = hi
+	if hi > 0 {
+		s = (hi - lo) / hi
+	}
+	d := hi - lo
+	switch {
+	case d == 0:
+		h = 0
+	case hi == r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case hi == g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// hueColor is the fully saturated, full value color with hue h in degrees.
+func hueColor(h float64) color.RGBA {
+	x := 1 - math.Abs(math.Mod(h/60, 2)-1)
+	var r, g, b float64
+	switch int(h/60) % 6 {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	case 3:
+		g, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+	return color.RGBA{uint8(255 * r), uint8(255 * g), uint8(255 * b), 255}
+}
+
+// themePixels is input with every pixel mapped through fn.
+func themePixels(input image.Image, fn func(color.RGBA) color.RGBA) *image.RGBA {
+	bounds := input.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, input, bounds.Min, draw.Src)
+	parallelRows(bounds.Dy(), func(start, end int) {
+		for y := bounds.Min.Y + start; y < bounds.Min.Y+end; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dst.SetRGBA(x, y, fn(dst.RGBAAt(x, y)))
+			}
+		}
+	})
+	return dst
+}
+
+// themeSquares is a black image the size of bounds with each square's bounds filled with fill[i].
+func themeSquares(bounds image.Rectangle, squares []squareInfo, fill []color.RGBA) *image.RGBA {
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	for i, sq := range squares {
+		draw.Draw(dst, sq.originalBounds, image.NewUniform(fill[i]), image.Point{}, draw.Src)
+	}
+	return dst
+}
+
+// renderTheme is the layer createDebugImage draws the grid on. baseline is the empty board's
+// squareMeanColors, only needed for the delta-from-calibration theme.
+func renderTheme(input image.Image, squares []squareInfo, theme string, baseline []color.RGBA) (*image.RGBA, error) {
+	switch theme {
+	case "", themeImage:
+		return themePixels(input, func(c color.RGBA) color.RGBA { return c }), nil
+	case themeHue:
+		return themePixels(input, func(c color.RGBA) color.RGBA {
+			h, _, _ := hsv(c)
+			return hueColor(h)
+		}), nil
+	case themeSaturation:
+		return themePixels(input, func(c color.RGBA) color.RGBA {
+			_, s, _ := hsv(c)
+			g := uint8(255 * s)
+			return color.RGBA{g, g, g, 255}
+		}), nil
+	case themeValue:
+		return themePixels(input, func(c color.RGBA) color.RGBA {
+			g := max(c.R, c.G, c.B)
+			return color.RGBA{g, g, g, 255}
+		}), nil
+	case themeSquareMean:
+		return themeSquares(input.Bounds(), squares, squareMeanColors(input, squares)), nil
+	case themeDelta:
+		if len(baseline) != len(squares) {
+			return nil, fmt.Errorf("no empty board calibration, run calibrate_colors with the board empty first")
+		}
+		means := squareMeanColors(input, squares)
+		fill := make([]color.RGBA, len(squares))
+		for i := range squares {
+			fill[i] = heatColor(colorDistance(means[i], baseline[i]) / deltaRange)
+		}
+		return themeSquares(input.Bounds(), squares, fill), nil
+	}
+	return nil, validateDebugTheme(theme)
+}
+
+// calibrateColors remembers the mean color of each square of the current frame, which should be
+// of an empty board, for the delta-from-calibration debug theme.
+func (bc *PieceFinder) calibrateColors(ctx context.Context) (map[string]interface{}, error) {
+	img, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	baseline := squareMeanColors(img, squares)
+
+	bc.captureLock.Lock()
+	bc.colorBaseline = baseline
+	bc.captureLock.Unlock()
+
+	means := map[string]interface{}{}
+	for i, sq := range squares {
+		c := baseline[i]
+		means[sq.name] = []interface{}{c.R, c.G, c.B}
+	}
+	return map[string]interface{}{"calibrated": true, "means": means}, nil
+}
+
+// debugImage is the current frame drawn with theme, or the configured one if that's empty,
+// as a base64 PNG.
+func (bc *PieceFinder) debugImage(ctx context.Context, theme string) (map[string]interface{}, error) {
+	if theme == "" {
+		theme = bc.conf.DebugTheme
+	}
+	if err := validateDebugTheme(theme); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	img, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	took := time.Since(start)
+
+	bc.captureLock.Lock()
+	baseline := bc.colorBaseline
+	bc.captureLock.Unlock()
+
+	out, scan, err := createDebugImage(img, squares, theme, baseline, bc.conf.LabelScale)
+	if err != nil {
+		return nil, err
+	}
+	scan.took = took
+	bc.logger.Debugf("debug image scan: %s", scan.summary())
+	encoded, err := encodePNG(out)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"image": encoded, "scan": scan.toMap()}, nil
+}
+
+// currentSquares is the current frame and its squares, analyzed from scratch, the board at the
+// DoCommand's corners if it had any, and from the frame alone when there's no point cloud.
+func (bc *PieceFinder) currentSquares(ctx context.Context) (image.Image, []squareInfo, error) {
+	return bc.currentSquaresWith(ctx, nil)
+}
+
+// currentSquaresWith is currentSquares with the config changed by adjust, when it isn't nil.
+func (bc *PieceFinder) currentSquaresWith(ctx context.Context, adjust func(*PieceFinderConfig)) (image.Image, []squareInfo, error) {
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, nil)
+	if err != nil && ctx.Err() != nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		bc.logger.Warnf("no point cloud, going by the image alone: %v", err)
+		pc = nil
+	}
+	bc.captureLock.Lock()
+	conf := bc.captureConf()
+	bc.captureLock.Unlock()
+	if corners := bc.requestCorners(ctx.Value(cornersKey{}), img); corners != nil {
+		conf.Corners = corners
+	}
+	if adjust != nil {
+		adjust(conf)
+	}
+	squares, err := findBoardAndPieces(img, pc, bc.props, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, squares, nil
+}
@@ -0,0 +1,166 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestDebugThemes(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+
+	grid := color.RGBA{0, 255, 0, 255}
+	text := color.RGBA{255, 0, 0, 255}
+	render := func(theme string, baseline []color.RGBA) *image.RGBA {
+		out, _, err := createDebugImage(input, squares, theme, baseline, 0)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, out.Bounds(), test.ShouldResemble, input.Bounds())
+		// the grid is drawn in every theme
+		sq := squares[squareIndex('d', 4, 8)].originalBounds
+		test.That(t, out.(*image.RGBA).RGBAAt(sq.Min.X+1, sq.Min.Y), test.ShouldResemble, grid)
+		return out.(*image.RGBA)
+	}
+	// the labels' backgrounds darken what's under them, see drawLabel
+	var labels []image.Rectangle
+	for _, sq := range squares {
+		label := sq.name + "-" + []string{"", "W", "B"}[sq.color]
+		r := sq.originalBounds
+		size := labelSize(label, labelScale(r, len(label), 0))
+		tl := image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2).Sub(size.Div(2))
+		labels = append(labels, image.Rectangle{tl, tl.Add(size)})
+	}
+	skip := func(x, y int, c color.RGBA) bool {
+		if c == grid || c == text {
+			return true
+		}
+		for _, r := range labels {
+			if image.Pt(x, y).In(r) {
+				return true
+			}
+		}
+		return false
+	}
+	at := func(p image.Point) int {
+		for i, sq := range squares {
+			if p.In(sq.originalBounds) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	t.Run("image", func(t *testing.T) {
+		out := render("", nil)
+		test.That(t, out.RGBAAt(5, 5), test.ShouldResemble, color.RGBAModel.Convert(input.At(5, 5)))
+	})
+
+	t.Run("hue", func(t *testing.T) {
+		out := render(themeHue, nil)
+		// every pixel is fully saturated, so one channel is 255 and one is 0
+		for y := 0; y < out.Bounds().Dy(); y += 7 {
+			for x := 0; x < out.Bounds().Dx(); x += 7 {
+				c := out.RGBAAt(x, y)
+				if skip(x, y, c) {
+					continue
+				}
+				test.That(t, max(c.R, c.G, c.B), test.ShouldEqual, 255)
+				test.That(t, min(c.R, c.G, c.B), test.ShouldEqual, 0)
+			}
+		}
+	})
+
+	t.Run("saturation and value", func(t *testing.T) {
+		sat, val := render(themeSaturation, nil), render(themeValue, nil)
+		for y := 0; y < sat.Bounds().Dy(); y += 7 {
+			for x := 0; x < sat.Bounds().Dx(); x += 7 {
+				s, v := sat.RGBAAt(x, y), val.RGBAAt(x, y)
+				if skip(x, y, s) {
+					continue
+				}
+				test.That(t, s.R == s.G && s.G == s.B, test.ShouldBeTrue)
+				test.That(t, v.R == v.G && v.G == v.B, test.ShouldBeTrue)
+				r, g, b, _ := input.At(x, y).RGBA()
+				test.That(t, v.R, test.ShouldEqual, uint8(max(r, g, b)>>8))
+			}
+		}
+	})
+
+	t.Run("square mean", func(t *testing.T) {
+		out := render(themeSquareMean, nil)
+		means := squareMeanColors(input, squares)
+		// one flat fill per square, its mean, and black everywhere else
+		fills := make([]map[color.RGBA]bool, len(squares))
+		b := out.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := out.RGBAAt(x, y)
+				if skip(x, y, c) {
+					continue
+				}
+				i := at(image.Pt(x, y))
+				if i < 0 {
+					test.That(t, c, test.ShouldResemble, color.RGBA{0, 0, 0, 255})
+					continue
+				}
+				if fills[i] == nil {
+					fills[i] = map[color.RGBA]bool{}
+				}
+				fills[i][c] = true
+			}
+		}
+		for i, f := range fills {
+			test.That(t, f, test.ShouldResemble, map[color.RGBA]bool{means[i]: true})
+		}
+	})
+
+	t.Run("delta from calibration", func(t *testing.T) {
+		_, _, err := createDebugImage(input, squares, themeDelta, nil, 0)
+		test.That(t, err, test.ShouldNotBeNil)
+
+		// against itself nothing has drifted
+		baseline := squareMeanColors(input, squares)
+		out := render(themeDelta, baseline)
+		c := squares[squareIndex('e', 5, 8)].originalBounds.Min.Add(image.Pt(2, 2))
+		test.That(t, out.RGBAAt(c.X, c.Y), test.ShouldResemble, heatColor(0))
+
+		// one square off by deltaRange or more is white
+		baseline[squareIndex('e', 5, 8)] = color.RGBA{0, 0, 0, 255}
+		out = render(themeDelta, baseline)
+		test.That(t, out.RGBAAt(c.X, c.Y), test.ShouldResemble, color.RGBA{255, 255, 255, 255})
+	})
+
+	_, _, err = createDebugImage(input, squares, "sepia", nil, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestHSV(t *testing.T) {
+	for _, c := range []struct {
+		in      color.RGBA
+		h, s, v float64
+	}{
+		{color.RGBA{255, 0, 0, 255}, 0, 1, 1},
+		{color.RGBA{0, 255, 0, 255}, 120, 1, 1},
+		{color.RGBA{0, 0, 255, 255}, 240, 1, 1},
+		{color.RGBA{255, 0, 255, 255}, 300, 1, 1},
+		{color.RGBA{51, 51, 51, 255}, 0, 0, .2},
+	} {
+		h, s, v := hsv(c.in)
+		test.That(t, h, test.ShouldAlmostEqual, c.h)
+		test.That(t, s, test.ShouldAlmostEqual, c.s)
+		test.That(t, v, test.ShouldAlmostEqual, c.v)
+		if c.s == 1 {
+			test.That(t, hueColor(h), test.ShouldResemble, c.in)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	densityCell   = 40 // pixels per square in the heat map
+	densityLegend = 30 // height of the legend strip under the board
+)
+
+// squarePointCounts is how many points landed in each square.
+func squarePointCounts(squares []squareInfo) []float64 {
+	values := make([]float64, len(squares))
+	for i, s := range squares {
+		values[i] = float64(s.pc.Size())
+	}
+	return values
+}
+
+// squareHeights is, per square, how far (mm) the 90th percentile point sticks up above the
+// board, which is the median of the squares' deepest points.
+func squareHeights(squares []squareInfo) []float64 {
+	bottoms := make([]float64, len(squares))
+	for i, s := range squares {
+		bottoms[i] = s.pc.MetaData().MaxZ
+	}
+	sorted := append([]float64(nil), bottoms...)
+	sort.Float64s(sorted)
+	plane := sorted[len(sorted)/2]
+
+	values := make([]float64, len(squares))
+	for i, s := range squares {
+		zs := make([]float64, 0, s.pc.Size())
+		s.pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			zs = append(zs, p.Z)
+			return true
+		})
+		if len(zs) == 0 {
+			continue
+		}
+		sort.Float64s(zs)
+		values[i] = math.Max(0, plane-zs[len(zs)/10])
+	}
+	return values
+}
+
+// heatColor maps v in [0, 1] from black through red and yellow to white, so brighter is more.
+func heatColor(v float64) color.RGBA {
+	v = math.Max(0, math.Min(1, v))
+	ch := func(start float64) uint8 {
+		return uint8(255 * math.Max(0, math.Min(1, (v-start)*3)))
+	}
+	return color.RGBA{ch(0), ch(1. / 3), ch(2. / 3), 255}
+}
+
+// renderDensity draws values, one per square, as a heat map with a8 (the last rank's a file on
+// other boards) at the top left like a diagram, scaled to this frame's min and max, with a
+// legend strip underneath.
+func renderDensity(squares []squareInfo, values []float64, unit string) (*image.RGBA, float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	scale := func(v float64) float64 {
+		if hi <= lo {
+			return 0
+		}
+		return (v - lo) / (hi - lo)
+	}
+
+	n := int(math.Round(math.Sqrt(float64(len(squares)))))
+	size := n * densityCell
+	img := image.NewRGBA(image.Rect(0, 0, size, size+densityLegend))
+
+	for i, s := range squares {
+		x0 := int(s.file-'a') * densityCell
+		y0 := (n - s.rank) * densityCell
+		c := heatColor(scale(values[i]))
+		for y := y0; y < y0+densityCell; y++ {
+			for x := x0; x < x0+densityCell; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		drawString(img, x0+2, y0+12, s.name, color.RGBA{0, 128, 255, 255})
+	}
+
+	for x := range size {
+		c := heatColor(float64(x) / float64(size-1))
+		for y := size; y < size+densityLegend/2; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for x := range size {
+		for y := size + densityLegend/2; y < size+densityLegend; y++ {
+			img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	black := color.RGBA{0, 0, 0, 255}
+	drawString(img, 2, size+densityLegend-3, fmt.Sprintf("%.0f %s", lo, unit), black)
+	maxLabel := fmt.Sprintf("%.0f %s", hi, unit)
+	drawString(img, size-len(maxLabel)*7-2, size+densityLegend-3, maxLabel, black)
+
+	return img, lo, hi
+}
@@ -0,0 +1,101 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+func cellBrightness(img *image.RGBA, file rune, rank int) int {
+	// away from the label in the corner
+	x := int(file-'a')*densityCell + densityCell*3/4
+	y := (8-rank)*densityCell + densityCell*3/4
+	c := img.RGBAAt(x, y)
+	return int(c.R) + int(c.G) + int(c.B)
+}
+
+func TestRenderDensity(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+
+	values := squarePointCounts(squares)
+	most, least := 0, 0
+	for i, v := range values {
+		if v > values[most] {
+			most = i
+		}
+		if v < values[least] {
+			least = i
+		}
+	}
+
+	img, lo, hi := renderDensity(squares, values, "points")
+	test.That(t, lo, test.ShouldEqual, values[least])
+	test.That(t, hi, test.ShouldEqual, values[most])
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 8*densityCell)
+	test.That(t, img.Bounds().Dy(), test.ShouldEqual, 8*densityCell+densityLegend)
+
+	brightest := 0
+	for i, s := range squares {
+		if cellBrightness(img, s.file, s.rank) > cellBrightness(img, squares[brightest].file, squares[brightest].rank) {
+			brightest = i
+		}
+	}
+	t.Logf("most points %s (%.0f), least %s (%.0f)", squares[most].name, hi, squares[least].name, lo)
+	test.That(t, squares[brightest].name, test.ShouldEqual, squares[most].name)
+	test.That(t, cellBrightness(img, squares[least].file, squares[least].rank), test.ShouldEqual, 0)
+
+	// pieces stick up, empty squares don't
+	heights := squareHeights(squares)
+	for i, s := range squares {
+		if s.color == 0 {
+			test.That(t, heights[i], test.ShouldBeLessThan, defaultMinPieceSize)
+		}
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	last := -1
+	for v := 0.0; v <= 1; v += .05 {
+		c := heatColor(v)
+		b := int(c.R) + int(c.G) + int(c.B)
+		test.That(t, b, test.ShouldBeGreaterThanOrEqualTo, last)
+		last = b
+	}
+	test.That(t, heatColor(1), test.ShouldResemble, heatColor(2))
+}
+
+func TestPieceFinderDensityCommand(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	for _, mode := range []string{"points", "height"} {
+		ret, err := pf.DoCommand(context.Background(), map[string]interface{}{"density": mode})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, ret["density"], test.ShouldNotBeEmpty)
+		test.That(t, ret["max"].(float64), test.ShouldBeGreaterThan, ret["min"].(float64))
+		if mode == "height" {
+			// the fixture is a standard set
+			test.That(t, ret["suggested_piece_scale"], test.ShouldAlmostEqual, 1, .3)
+		}
+	}
+
+	_, err = pf.DoCommand(context.Background(), map[string]interface{}{"density": "nope"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,99 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"time"
+
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// What a capture returns when the board or pieces can't be found, see PieceFinderConfig.ErrorFrames.
+const (
+	errorFramesError       = "error"
+	errorFramesPassthrough = "passthrough"
+	errorFramesPlaceholder = "placeholder"
+)
+
+func validateErrorFrames(mode string) error {
+	switch mode {
+	case "", errorFramesError, errorFramesPassthrough, errorFramesPlaceholder:
+		return nil
+	}
+	return fmt.Errorf("bad error-frames %q, needs to be error, passthrough or placeholder", mode)
+}
+
+// captureError is the last analysis failure, kept for the status DoCommand.
+type captureError struct {
+	err  error
+	when time.Time
+}
+
+// failedCapture is what CaptureAllFromCamera returns when analyzing ret.Image failed with err.
+// Unless error-frames is error, the stream stays up with a frame flagged unprocessed.
+// Has to be called with captureLock held.
+func (bc *PieceFinder) failedCapture(ret viscapture.VisCapture, err error) (viscapture.VisCapture, error) {
+	bc.lastErr = &captureError{err, time.Now()}
+
+	switch bc.conf.ErrorFrames {
+	case errorFramesPassthrough:
+	case errorFramesPlaceholder:
+		ret.Image = renderErrorFrame(ret.Image.Bounds(), err)
+	default:
+		return ret, err
+	}
+
+	bc.logger.Debugf("serving %s frame: %v", bc.conf.ErrorFrames, err)
+	ret.Objects = nil
+	ret.Detections = nil
+	ret.Extra = map[string]interface{}{"unprocessed": true, "error": err.Error()}
+	return ret, nil
+}
+
+// renderErrorFrame is an image the size of bounds saying what went wrong.
+func renderErrorFrame(bounds image.Rectangle, err error) *image.RGBA {
+	rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	img := image.NewRGBA(rect)
+	draw.Draw(img, rect, image.NewUniform(color.RGBA{40, 40, 40, 255}), image.Point{}, draw.Src)
+
+	red := color.RGBA{255, 80, 80, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	drawString(img, 10, 20, "can't find the board", red)
+	for i, line := range wrapText(err.Error(), (rect.Dx()-20)/7) {
+		drawString(img, 10, 40+i*15, line, white)
+	}
+	return img
+}
+
+// wrapText splits s into lines of at most width characters, breaking at spaces where it can.
+func wrapText(s string, width int) []string {
+	width = max(width, 1)
+	lines := []string{}
+	line := ""
+	for _, w := range strings.Fields(s) {
+		for len(w) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, w[:width])
+			w = w[width:]
+		}
+		switch {
+		case line == "":
+			line = w
+		case len(line)+1+len(w) <= width:
+			line += " " + w
+		default:
+			lines = append(lines, line)
+			line = w
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
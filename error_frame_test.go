@@ -0,0 +1,115 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// newFailingPieceFinder has a real board in the frame but no points on it, only one well out of
+// the frame, so every capture fails.
+func newFailingPieceFinder(t *testing.T, mode string) (*PieceFinder, image.Image) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pc := pointcloud.NewBasicEmpty()
+	test.That(t, pc.Set(r3.Vector{X: -5000, Y: -5000, Z: 1000}, nil), test.ShouldBeNil)
+	return newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", ErrorFrames: mode}, &frame, &pc), frame
+}
+
+func TestErrorFramesError(t *testing.T) {
+	ctx := context.Background()
+	for _, mode := range []string{"", "error"} {
+		pf, _ := newFailingPieceFinder(t, mode)
+
+		_, captureErr := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, captureErr, test.ShouldNotBeNil)
+		test.That(t, captureErr.Error(), test.ShouldContainSubstring, "is empty")
+
+		status, err := pf.DoCommand(ctx, map[string]interface{}{"status": true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, status["ok"], test.ShouldBeFalse)
+		test.That(t, status["error"], test.ShouldEqual, captureErr.Error())
+	}
+}
+
+func TestErrorFramesPassthrough(t *testing.T) {
+	ctx := context.Background()
+	pf, frame := newFailingPieceFinder(t, "passthrough")
+
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["unprocessed"], test.ShouldBeTrue)
+	test.That(t, ret.Extra["error"], test.ShouldContainSubstring, "is empty")
+	test.That(t, len(ret.Objects), test.ShouldEqual, 0)
+	test.That(t, ret.Image.Bounds(), test.ShouldResemble, frame.Bounds())
+	test.That(t, ret.Image.At(200, 200), test.ShouldResemble, frame.At(200, 200))
+
+	status, err := pf.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["ok"], test.ShouldBeFalse)
+	test.That(t, status["error"], test.ShouldEqual, ret.Extra["error"])
+}
+
+func TestErrorFramesPlaceholder(t *testing.T) {
+	ctx := context.Background()
+	pf, frame := newFailingPieceFinder(t, "placeholder")
+
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret.Extra["unprocessed"], test.ShouldBeTrue)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 0)
+	test.That(t, ret.Image.Bounds().Size(), test.ShouldResemble, frame.Bounds().Size())
+
+	// the background is flat where there's no text
+	r, g, b, _ := ret.Image.At(ret.Image.Bounds().Dx()-5, ret.Image.Bounds().Dy()-5).RGBA()
+	test.That(t, []uint32{r >> 8, g >> 8, b >> 8}, test.ShouldResemble, []uint32{40, 40, 40})
+}
+
+func TestErrorFramesStatusClears(t *testing.T) {
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	good, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pc := pointcloud.NewBasicEmpty()
+	test.That(t, pc.Set(r3.Vector{X: -5000, Y: -5000, Z: 1000}, nil), test.ShouldBeNil)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", ErrorFrames: "passthrough"}, &frame, &pc)
+
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pf.status()["ok"], test.ShouldBeFalse)
+
+	pc = good
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	test.That(t, pf.status(), test.ShouldResemble, map[string]interface{}{"ok": true})
+}
+
+func TestErrorFramesValidate(t *testing.T) {
+	_, _, err := (&PieceFinderConfig{Input: "cam", ErrorFrames: "blank"}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "placeholder")
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("pc for a1 is empty in findBoardAndPieces", 12)
+	test.That(t, lines, test.ShouldResemble, []string{"pc for a1 is", "empty in", "findBoardAnd", "Pieces"})
+	for _, l := range lines {
+		test.That(t, len(l), test.ShouldBeLessThanOrEqualTo, 12)
+	}
+	test.That(t, strings.Join(wrapText("short", 80), ""), test.ShouldEqual, "short")
+}
@@ -0,0 +1,168 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// excludeMargin is how far (mm) around an excluded frame's geometries points are cut too, the
+// geometries only being roughly the arm's or gripper's shape.
+const excludeMargin = 10.0
+
+func validateExcludeFrames(names []string) error {
+	for i, n := range names {
+		if n == "" {
+			return fmt.Errorf("exclude-frames %d is empty, needs to be a frame's name", i)
+		}
+	}
+	return nil
+}
+
+// excludeFrameNames is conf's exclude-frames and the ones a capture's extra asks for as
+// "exclude_frames", each once.
+func excludeFrameNames(conf *PieceFinderConfig, extra map[string]interface{}) []string {
+	names := append([]string(nil), conf.ExcludeFrames...)
+	add := func(name string) {
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	switch arg := extra["exclude_frames"].(type) {
+	case []string:
+		for _, n := range arg {
+			add(n)
+		}
+	case []interface{}:
+		for _, n := range arg {
+			if s, ok := n.(string); ok && s != "" {
+				add(s)
+			}
+		}
+	case string:
+		if arg != "" {
+			add(arg)
+		}
+	}
+	return names
+}
+
+// excludedGeometries is where the geometries of the frames called names are right now, in the
+// camera's frame (camera-frame), the one its point cloud is in.
+func (bc *PieceFinder) excludedGeometries(ctx context.Context, names []string) ([]spatialmath.Geometry, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if bc.rfs == nil {
+		return nil, fmt.Errorf("no frame system to find %v in", names)
+	}
+	fsConf, err := bc.rfs.FrameSystemConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := referenceframe.NewFrameSystem("piece-finder", fsConf.Parts, nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := bc.rfs.CurrentInputs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inputs := current.ToLinearInputs()
+
+	geometries := []spatialmath.Geometry{}
+	for _, name := range names {
+		if fs.Frame(name) == nil {
+			return nil, fmt.Errorf("no frame %q in the frame system", name)
+		}
+		// a model's geometries are its own, the one set in a part's frame config its origin's
+		for _, frame := range []referenceframe.Frame{fs.Frame(name), fs.Frame(name + "_origin")} {
+			if frame == nil {
+				continue
+			}
+			g, err := frameGeometries(fs, inputs, frame, bc.conf.cameraFrame())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			geometries = append(geometries, g...)
+		}
+	}
+	return geometries, nil
+}
+
+// frameGeometries is where frame's geometries are in dst, the frame system being at inputs.
+func frameGeometries(fs *referenceframe.FrameSystem, inputs *referenceframe.LinearInputs, frame referenceframe.Frame, dst string) ([]spatialmath.Geometry, error) {
+	frameInputs, err := inputs.GetFrameInputs(frame)
+	if err != nil {
+		return nil, err
+	}
+	inFrame, err := frame.Geometries(frameInputs)
+	if err != nil {
+		return nil, err
+	}
+	if len(inFrame.Geometries()) == 0 {
+		return nil, nil
+	}
+	inDst, err := fs.Transform(inputs, inFrame, dst)
+	if err != nil {
+		return nil, err
+	}
+	return inDst.(*referenceframe.GeometriesInFrame).Geometries(), nil
+}
+
+// excludeFrames is pc without the points on the frames called names, and how many were cut.
+// Not being able to find where they are is logged and pc is used whole, the board being
+// worth analyzing anyway.
+func (bc *PieceFinder) excludeFrames(ctx context.Context, pc pointcloud.PointCloud, names []string) (pointcloud.PointCloud, int, error) {
+	geometries, err := bc.excludedGeometries(ctx, names)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, err
+		}
+		bc.logger.Warnf("not excluding %v from the point cloud: %v", names, err)
+		return pc, 0, nil
+	}
+	return excludePoints(pc, geometries, excludeMargin)
+}
+
+// excludePoints is pc without the points within margin of any of geometries, and how many
+// were cut. pc itself is returned when there's nothing to cut.
+func excludePoints(pc pointcloud.PointCloud, geometries []spatialmath.Geometry, margin float64) (pointcloud.PointCloud, int, error) {
+	if pc == nil || len(geometries) == 0 {
+		return pc, 0, nil
+	}
+	kept := pointcloud.NewBasicPointCloud(pc.Size())
+	cut := 0
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		pt := spatialmath.NewPoint(p, "")
+		for _, g := range geometries {
+			var in bool
+			in, _, err = g.CollidesWith(pt, margin)
+			if err != nil {
+				return false
+			}
+			if in {
+				cut++
+				return true
+			}
+		}
+		err = kept.Set(p, d)
+		return err == nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if cut == 0 {
+		return pc, 0, nil
+	}
+	return kept, cut, nil
+}
@@ -0,0 +1,149 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// partsFrameSystem is a frame system service made of parts, nothing in it moving.
+type partsFrameSystem struct {
+	*inject.FrameSystemService
+	parts []*referenceframe.FrameSystemPart
+}
+
+func (fs *partsFrameSystem) FrameSystemConfig(ctx context.Context) (*framesystem.Config, error) {
+	return &framesystem.Config{Parts: fs.parts}, nil
+}
+
+func (fs *partsFrameSystem) CurrentInputs(ctx context.Context) (referenceframe.FrameSystemInputs, error) {
+	return referenceframe.FrameSystemInputs{}, nil
+}
+
+// armOverBoard is tiltedBoard with a gripper hovering 80mm over the middle of d4 and d5 (the
+// fifth column from the left, fourth and fifth rows from the top with white on top), and the box
+// where and how big the box it's in is.
+func armOverBoard(t *testing.T) (pointcloud.PointCloud, spatialmath.Pose, r3.Vector) {
+	t.Helper()
+	pc := tiltedBoard(t)
+	ip := touch.RealSenseProperties.IntrinsicParams
+	arm := pointcloud.NewColoredData(color.NRGBA{250, 250, 250, 255})
+	lo, hi := r3.Vector{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)}, r3.Vector{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)}
+	for v := 150 + 3*60 + 15; v < 150+5*60-15; v += 2 {
+		for u := 400 + 4*60 + 15; u < 400+4*60+45; u += 2 {
+			x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+			z := 600/(1-.1*y) - 80
+			p := r3.Vector{X: x * z, Y: y * z, Z: z}
+			test.That(t, pc.Set(p, arm), test.ShouldBeNil)
+			lo = r3.Vector{X: min(lo.X, p.X), Y: min(lo.Y, p.Y), Z: min(lo.Z, p.Z)}
+			hi = r3.Vector{X: max(hi.X, p.X), Y: max(hi.Y, p.Y), Z: max(hi.Z, p.Z)}
+		}
+	}
+	return pc, spatialmath.NewPoseFromPoint(lo.Add(hi).Mul(.5)), hi.Sub(lo)
+}
+
+func occupiedNames(squares []squareInfo) []string {
+	names := []string{}
+	for _, sq := range squares {
+		if sq.color != 0 {
+			names = append(names, sq.name)
+		}
+	}
+	return names
+}
+
+func TestExcludeFrames(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc, at, dims := armOverBoard(t)
+	box, err := spatialmath.NewBox(spatialmath.NewZeroPose(), dims, "gripper")
+	test.That(t, err, test.ShouldBeNil)
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	// the gripper's in the camera's frame, where its box is
+	pf.rfs = &partsFrameSystem{
+		FrameSystemService: pf.rfs.(*inject.FrameSystemService),
+		parts: []*referenceframe.FrameSystemPart{
+			{FrameConfig: referenceframe.NewLinkInFrame("world", spatialmath.NewZeroPose(), "cam", nil)},
+			{FrameConfig: referenceframe.NewLinkInFrame("cam", at, "gripper", box)},
+		},
+	}
+
+	// over the board, it's a couple of big white pieces
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, occupiedNames(pf.last.squares), test.ShouldResemble, []string{"d4", "d5"})
+
+	// cut out, there's nothing there
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, map[string]interface{}{"exclude_frames": []interface{}{"gripper"}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, occupiedNames(pf.last.squares), test.ShouldBeEmpty)
+	test.That(t, all.Extra["excluded_points"], test.ShouldBeGreaterThan, 0)
+
+	// the same from the config
+	conf.ExcludeFrames = []string{"gripper"}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, occupiedNames(pf.last.squares), test.ShouldBeEmpty)
+
+	// a frame that isn't there is warned about and the cloud used whole
+	conf.ExcludeFrames = []string{"elbow"}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, occupiedNames(pf.last.squares), test.ShouldResemble, []string{"d4", "d5"})
+}
+
+func TestExcludeFramesCameraFrame(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc, at, dims := armOverBoard(t)
+	box, err := spatialmath.NewBox(spatialmath.NewZeroPose(), dims, "gripper")
+	test.That(t, err, test.ShouldBeNil)
+	conf := &PieceFinderConfig{
+		Input: "cam", CameraFrame: "cam-frame", Rotation: "0", Corners: tiltedBoardCorners,
+		MinVisibleScore: -1, ChangeThreshold: -1, ExcludeFrames: []string{"gripper"},
+	}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	// the cloud's in cam-frame, a frame called cam is somewhere else altogether
+	pf.rfs = &partsFrameSystem{
+		FrameSystemService: pf.rfs.(*inject.FrameSystemService),
+		parts: []*referenceframe.FrameSystemPart{
+			{FrameConfig: referenceframe.NewLinkInFrame("world", spatialmath.NewZeroPose(), "cam-frame", nil)},
+			{FrameConfig: referenceframe.NewLinkInFrame("world", spatialmath.NewPoseFromPoint(r3.Vector{X: 1000}), "cam", nil)},
+			{FrameConfig: referenceframe.NewLinkInFrame("world", at, "gripper", box)},
+		},
+	}
+
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, occupiedNames(pf.last.squares), test.ShouldBeEmpty)
+}
+
+func TestExcludeFrameNames(t *testing.T) {
+	conf := &PieceFinderConfig{ExcludeFrames: []string{"arm", "gripper"}}
+	test.That(t, excludeFrameNames(conf, nil), test.ShouldResemble, []string{"arm", "gripper"})
+	test.That(t, excludeFrameNames(conf, map[string]interface{}{"exclude_frames": []interface{}{"gripper", "hand"}}),
+		test.ShouldResemble, []string{"arm", "gripper", "hand"})
+	test.That(t, excludeFrameNames(&PieceFinderConfig{}, map[string]interface{}{"exclude_frames": "gripper"}),
+		test.ShouldResemble, []string{"gripper"})
+	test.That(t, conf.ExcludeFrames, test.ShouldResemble, []string{"arm", "gripper"})
+
+	test.That(t, validateExcludeFrames([]string{"arm", ""}), test.ShouldNotBeNil)
+}
@@ -0,0 +1,293 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/corentings/chess/v2"
+	"github.com/google/uuid"
+
+	"go.viam.com/rdk/logging"
+)
+
+const defaultMaxGames = 100
+
+// errGameInProgress is a new_game while the current game has moves and no result.
+var errGameInProgress = errors.New("a game is in progress, finish it with finish_game or start over with {\"new_game\": true, \"abandon\": true}")
+
+// gameSummary is what's kept about a finished game, next to its PGN in the history directory.
+type gameSummary struct {
+	ID        string    `json:"id"`
+	Started   time.Time `json:"started"`
+	Ended     time.Time `json:"ended"`
+	Result    string    `json:"result"` // 1-0, 0-1, 1/2-1/2, or * when abandoned
+	Method    string    `json:"method,omitempty"`
+	Abandoned bool      `json:"abandoned,omitempty"`
+	Moves     int       `json:"moves"`
+}
+
+// maxGames is how many finished games the history directory keeps.
+func (cfg *ChessConfig) maxGames() int {
+	if cfg.MaxGames <= 0 {
+		return defaultMaxGames
+	}
+	return cfg.MaxGames
+}
+
+// gameLogger is the logger with theState's game ID on every record.
+func (s *viamChessChess) gameLogger(theState *state) logging.Logger {
+	return s.logger.WithFields("game_id", theState.id)
+}
+
+// gamePGN is theState's game as PGN, with its ID and when it started in the headers.
+func gamePGN(theState *state) string {
+	theState.game.AddTagPair("GameId", theState.id)
+	theState.game.AddTagPair("Date", theState.started.Format("2006.01.02"))
+	theState.game.AddTagPair("Result", theState.game.Outcome().String())
+	if theState.start != "" {
+		theState.game.AddTagPair("SetUp", "1")
+		theState.game.AddTagPair("FEN", theState.start)
+	}
+	return theState.game.String()
+}
+
+// newGame starts a fresh game with a new ID. A game that's over goes into the history first,
+// one in progress only with abandon, and one without any moves is just dropped.
+func (s *viamChessChess) newGame(ctx context.Context, abandon bool) (map[string]interface{}, error) {
+	theState, err := s.getGame(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case theState.game.Outcome() != chess.NoOutcome:
+		err = s.archiveGame(theState, false)
+	case len(theState.game.Moves()) == 0:
+	case abandon:
+		err = s.archiveGame(theState, true)
+	default:
+		return nil, errGameInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := &state{game: chess.NewGame(), graveyard: []int{}}
+	err = s.saveGame(ctx, fresh)
+	if err != nil {
+		return nil, err
+	}
+	s.gameLogger(fresh).Infof("new game")
+	return map[string]interface{}{"game_id": fresh.id}, nil
+}
+
+// finishGame records result (1-0, 0-1 or 1/2-1/2, or "over" for the game's own from a checkmate
+// or stalemate) for the current game and moves it into the history.
+func (s *viamChessChess) finishGame(ctx context.Context, result string) (map[string]interface{}, error) {
+	theState, err := s.getGame(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if theState.id == "" {
+		return nil, fmt.Errorf("no game to finish")
+	}
+
+	g := theState.game
+	if g.Outcome() == chess.NoOutcome {
+		switch chess.Outcome(result) {
+		case chess.WhiteWon:
+			g.Resign(chess.Black)
+		case chess.BlackWon:
+			g.Resign(chess.White)
+		case chess.Draw:
+			err = g.Draw(chess.DrawOffer)
+		case "over":
+			return nil, fmt.Errorf("the game isn't over, finish_game needs a result")
+		default:
+			return nil, fmt.Errorf("bad result %q, needs to be 1-0, 0-1 or 1/2-1/2", result)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if result != "over" && result != g.Outcome().String() {
+		return nil, fmt.Errorf("the game already ended %s by %s", g.Outcome(), g.Method())
+	}
+
+	err = s.archiveGame(theState, false)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Remove(s.fenFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return map[string]interface{}{"game_id": theState.id, "result": g.Outcome().String()}, nil
+}
+
+// archiveGame writes theState's PGN and summary to the history directory, then drops the
+// oldest games past maxGames.
+func (s *viamChessChess) archiveGame(theState *state, abandoned bool) error {
+	if theState.id == "" {
+		return nil
+	}
+	err := os.MkdirAll(s.historyDir, 0777)
+	if err != nil {
+		return err
+	}
+
+	summary := gameSummary{
+		ID:        theState.id,
+		Started:   theState.started,
+		Ended:     time.Now(),
+		Result:    theState.game.Outcome().String(),
+		Abandoned: abandoned,
+		Moves:     len(theState.game.Moves()),
+	}
+	if m := theState.game.Method(); m != chess.NoMethod {
+		summary.Method = m.String()
+	}
+
+	err = replaceFile(filepath.Join(s.historyDir, theState.id+".pgn"), []byte(gamePGN(theState)))
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(&summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = replaceFile(filepath.Join(s.historyDir, theState.id+".json"), b)
+	if err != nil {
+		return err
+	}
+	s.gameLogger(theState).Infof("game over %s (%s), %d moves", summary.Result, summary.Method, summary.Moves)
+
+	games, err := s.gameHistory()
+	if err != nil {
+		return err
+	}
+	for _, old := range games[min(len(games), s.conf.maxGames()):] {
+		for _, ext := range []string{".pgn", ".json"} {
+			err = os.Remove(filepath.Join(s.historyDir, old.ID+ext))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceFile writes fn through a temporary file renamed over it, so list_games and get_game,
+// which don't wait for the command in progress, never read half of one.
+func replaceFile(fn string, data []byte) error {
+	tmp := fn + ".tmp"
+	err := os.WriteFile(tmp, data, 0666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// gameHistory is the summaries of the finished games, newest first.
+func (s *viamChessChess) gameHistory() ([]gameSummary, error) {
+	entries, err := os.ReadDir(s.historyDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	games := []gameSummary{}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.historyDir, e.Name()))
+		if os.IsNotExist(err) {
+			continue // dropped past max-games since the listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		var g gameSummary
+		err = json.Unmarshal(b, &g)
+		if err != nil {
+			return nil, fmt.Errorf("bad game summary %s: %w", e.Name(), err)
+		}
+		games = append(games, g)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].Ended.After(games[j].Ended) })
+	return games, nil
+}
+
+// listGames is {"list_games": true}, the current game's ID and the finished games.
+func (s *viamChessChess) listGames(ctx context.Context) (map[string]interface{}, error) {
+	theState, err := s.getGame(ctx)
+	if err != nil {
+		return nil, err
+	}
+	games, err := s.gameHistory()
+	if err != nil {
+		return nil, err
+	}
+	list := []interface{}{}
+	for _, g := range games {
+		list = append(list, g)
+	}
+	return map[string]interface{}{"current": theState.id, "games": list}, nil
+}
+
+// getGameByID is {"get_game": "<id>"}, the summary and PGN of a finished game or the current one.
+func (s *viamChessChess) getGameByID(ctx context.Context, id string) (map[string]interface{}, error) {
+	theState, err := s.getGame(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id == theState.id {
+		return map[string]interface{}{
+			"id":      id,
+			"active":  true,
+			"started": theState.started,
+			"moves":   len(theState.game.Moves()),
+			"pgn":     gamePGN(theState),
+		}, nil
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("bad game id %q: %w", id, err)
+	}
+	b, err := os.ReadFile(filepath.Join(s.historyDir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no game %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var summary gameSummary
+	err = json.Unmarshal(b, &summary)
+	if err != nil {
+		return nil, err
+	}
+	pgn, err := os.ReadFile(filepath.Join(s.historyDir, id+".pgn"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":        summary.ID,
+		"active":    false,
+		"started":   summary.Started,
+		"ended":     summary.Ended,
+		"result":    summary.Result,
+		"method":    summary.Method,
+		"abandoned": summary.Abandoned,
+		"moves":     summary.Moves,
+		"pgn":       string(pgn),
+	}, nil
+}
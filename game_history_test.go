@@ -0,0 +1,178 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/test"
+)
+
+// playMoves plays the UCI moves in the current game and saves it, like the robot and the
+// person across from it would.
+func playMoves(t *testing.T, s *viamChessChess, moves ...string) *state {
+	t.Helper()
+	ctx := context.Background()
+	theState, err := s.getGame(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	for _, m := range moves {
+		test.That(t, theState.game.PushNotationMove(m, chess.UCINotation{}, nil), test.ShouldBeNil)
+	}
+	test.That(t, s.saveGame(ctx, theState), test.ShouldBeNil)
+	return theState
+}
+
+func TestGameHistory(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChess(t)
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"new_game": true})
+	test.That(t, err, test.ShouldBeNil)
+	first := res["game_id"].(string)
+	test.That(t, first, test.ShouldNotBeEmpty)
+
+	// the ID stays with the game as it's saved and read back
+	playMoves(t, s, "f2f3", "e7e5", "g2g4")
+	theState, err := s.getGame(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, theState.id, test.ShouldEqual, first)
+	test.That(t, len(theState.game.Moves()), test.ShouldEqual, 3)
+
+	// a game in progress isn't thrown away without saying so
+	_, err = s.DoCommand(ctx, map[string]interface{}{"new_game": true})
+	test.That(t, err, test.ShouldEqual, errGameInProgress)
+
+	// fool's mate, the game's over on its own
+	playMoves(t, s, "d8h4")
+	res, err = s.DoCommand(ctx, map[string]interface{}{"new_game": true})
+	test.That(t, err, test.ShouldBeNil)
+	second := res["game_id"].(string)
+	test.That(t, second, test.ShouldNotEqual, first)
+
+	pgn, err := os.ReadFile(filepath.Join(s.historyDir, first+".pgn"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(pgn), test.ShouldContainSubstring, `[GameId "`+first+`"]`)
+	test.That(t, string(pgn), test.ShouldContainSubstring, `[Result "0-1"]`)
+	test.That(t, string(pgn), test.ShouldContainSubstring, "Qh4#")
+
+	var summary gameSummary
+	b, err := os.ReadFile(filepath.Join(s.historyDir, first+".json"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, json.Unmarshal(b, &summary), test.ShouldBeNil)
+	test.That(t, summary.ID, test.ShouldEqual, first)
+	test.That(t, summary.Result, test.ShouldEqual, "0-1")
+	test.That(t, summary.Method, test.ShouldEqual, "Checkmate")
+	test.That(t, summary.Moves, test.ShouldEqual, 4)
+	test.That(t, summary.Abandoned, test.ShouldBeFalse)
+
+	// the second game is abandoned partway
+	playMoves(t, s, "e2e4", "c7c5")
+	_, err = s.DoCommand(ctx, map[string]interface{}{"new_game": true, "abandon": true})
+	test.That(t, err, test.ShouldBeNil)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"list_games": true})
+	test.That(t, err, test.ShouldBeNil)
+	games := res["games"].([]interface{})
+	test.That(t, len(games), test.ShouldEqual, 2)
+	test.That(t, games[0].(gameSummary).ID, test.ShouldEqual, second)
+	test.That(t, games[0].(gameSummary).Abandoned, test.ShouldBeTrue)
+	test.That(t, games[0].(gameSummary).Result, test.ShouldEqual, "*")
+	test.That(t, games[1].(gameSummary).ID, test.ShouldEqual, first)
+	third := res["current"].(string)
+	test.That(t, third, test.ShouldNotBeIn, first, second)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"get_game": second})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["abandoned"], test.ShouldBeTrue)
+	test.That(t, res["pgn"], test.ShouldContainSubstring, "1. e4 c5")
+
+	// the current game too, before it's archived
+	playMoves(t, s, "d2d4")
+	res, err = s.DoCommand(ctx, map[string]interface{}{"get_game": third})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["active"], test.ShouldBeTrue)
+	test.That(t, res["pgn"], test.ShouldContainSubstring, `[GameId "`+third+`"]`)
+
+	_, err = s.DoCommand(ctx, map[string]interface{}{"get_game": "nope"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"finish_game": "1/2-1/2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["game_id"], test.ShouldEqual, third)
+	_, err = os.Stat(s.fenFile)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"get_game": third})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["result"], test.ShouldEqual, "1/2-1/2")
+	test.That(t, res["method"], test.ShouldEqual, "DrawOffer")
+}
+
+func TestGameHistoryBounded(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestChess(t)
+	s.conf.MaxGames = 2
+
+	ids := []string{}
+	for range 3 {
+		theState := playMoves(t, s, "e2e4")
+		ids = append(ids, theState.id)
+		_, err := s.DoCommand(ctx, map[string]interface{}{"finish_game": "1-0"})
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	games, err := s.gameHistory()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(games), test.ShouldEqual, 2)
+	test.That(t, games[0].ID, test.ShouldEqual, ids[2])
+	test.That(t, games[1].ID, test.ShouldEqual, ids[1])
+
+	entries, err := os.ReadDir(s.historyDir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(entries), test.ShouldEqual, 4)
+	for _, e := range entries {
+		test.That(t, strings.HasPrefix(e.Name(), ids[0]), test.ShouldBeFalse)
+	}
+}
+
+func TestGameHistoryDuringMove(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+	theState := playMoves(t, s, "e2e4")
+
+	// a move is in progress with a piece in the gripper
+	s.held = &heldPiece{from: "e7", to: "e5", z: 30}
+	f.holding = true
+	s.doCommandLock.Lock()
+	defer s.doCommandLock.Unlock()
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"list_games": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["current"], test.ShouldEqual, theState.id)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"get_game": theState.id})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["moves"], test.ShouldEqual, 1)
+
+	test.That(t, f.events, test.ShouldBeEmpty)
+	test.That(t, s.held, test.ShouldNotBeNil)
+}
+
+func TestReadStateFENOnly(t *testing.T) {
+	// state files from before game IDs are a game starting at their FEN
+	theState, err := readState(context.Background(), "data/reset1.json")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, theState.id, test.ShouldBeEmpty)
+	test.That(t, theState.start, test.ShouldEqual, theState.game.FEN())
+
+	s, _ := newTestChess(t)
+	test.That(t, s.saveGame(context.Background(), theState), test.ShouldBeNil)
+	theState = playMoves(t, s)
+	test.That(t, theState.id, test.ShouldNotBeEmpty)
+	test.That(t, gamePGN(theState), test.ShouldContainSubstring, `[SetUp "1"]`)
+}
@@ -0,0 +1,283 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// graveyardSnap is how far across (mm) from where the chess service put a captured piece it
+// takes a piece seen in the graveyard to be that one.
+const graveyardSnap = 40.0
+
+// GraveyardRegion is somewhere beside the board captured pieces are put, given as exactly one
+// of Image or World.
+type GraveyardRegion struct {
+	// Image is the min x, min y, max x and max y (pixels) of it in the input frame.
+	Image []int `json:"image,omitempty"`
+	// World is the min x, y and z then the max x, y and z (mm) of it in the world frame.
+	World []float64 `json:"world,omitempty"`
+}
+
+func validateGraveyards(regions []GraveyardRegion) error {
+	for i, r := range regions {
+		switch {
+		case r.Image != nil && r.World != nil:
+			return fmt.Errorf("graveyards %d has both image and world, needs one", i)
+		case r.Image != nil:
+			if len(r.Image) != 4 || r.Image[0] >= r.Image[2] || r.Image[1] >= r.Image[3] {
+				return fmt.Errorf("graveyards %d image needs to be [min x, min y, max x, max y], got %v", i, r.Image)
+			}
+		case r.World != nil:
+			if len(r.World) != 6 || r.World[0] >= r.World[3] || r.World[1] >= r.World[4] || r.World[2] >= r.World[5] {
+				return fmt.Errorf("graveyards %d world needs to be [min x, y, z, max x, y, z], got %v", i, r.World)
+			}
+		default:
+			return fmt.Errorf("graveyards %d needs an image or world box", i)
+		}
+	}
+	return nil
+}
+
+// graveyardPiece is a piece seen in a graveyard region, see findGraveyard.
+type graveyardPiece struct {
+	color      int // 1 white or 2 black
+	confidence float64
+	points     pointcloud.PointCloud // in the camera's frame
+	center     r3.Vector             // the mean of points
+}
+
+// graveyardLabel is the label of the i'th graveyard piece's object, "X<i>-<color>" the way a
+// square's is "<square>-<color>", X<i> being how squareToString has the graveyard.
+func graveyardLabel(i int, p graveyardPiece) string {
+	return fmt.Sprintf("X%d-%d", i, p.color)
+}
+
+// isGraveyardLabel is whether label is a graveyard piece's, see graveyardLabel.
+func isGraveyardLabel(label string) bool {
+	return strings.HasPrefix(label, "X")
+}
+
+// nearestGraveyardPiece is the graveyard piece in data nearest at, no further than graveyardSnap
+// across, nil when there isn't one.
+func nearestGraveyardPiece(data viscapture.VisCapture, at r3.Vector) *viz.Object {
+	var best *viz.Object
+	bestDist := graveyardSnap
+	for _, o := range data.Objects {
+		if !isGraveyardLabel(o.Geometry.Label()) {
+			continue
+		}
+		md := o.MetaData()
+		c := md.Center()
+		if d := math.Hypot(c.X-at.X, c.Y-at.Y); d <= bestDist {
+			best, bestDist = o, d
+		}
+	}
+	return best
+}
+
+// inRegion is a test of whether a point of the input camera's cloud is in r: by its pixel for an
+// image region, and where camera (nil when that isn't known) puts it in the world for a world one.
+func inRegion(r GraveyardRegion, props *PieceFinder, camera spatialmath.Pose) func(r3.Vector) bool {
+	if r.Image != nil {
+		rect := image.Rect(r.Image[0], r.Image[1], r.Image[2], r.Image[3])
+		ip := props.props.IntrinsicParams
+		return func(p r3.Vector) bool {
+			return p.Z > 0 && pointPixel(p, ip).In(rect)
+		}
+	}
+	if camera == nil {
+		return func(r3.Vector) bool { return false }
+	}
+	rm, at := camera.Orientation().RotationMatrix(), camera.Point()
+	lo, hi := r3.Vector{X: r.World[0], Y: r.World[1], Z: r.World[2]}, r3.Vector{X: r.World[3], Y: r.World[4], Z: r.World[5]}
+	return func(p r3.Vector) bool {
+		w := at.Add(rm.Mul(p))
+		return w.X >= lo.X && w.X <= hi.X && w.Y >= lo.Y && w.Y <= hi.Y && w.Z >= lo.Z && w.Z <= hi.Z
+	}
+}
+
+// cameraPose is where the input camera is in the world, nil when the frame system can't say,
+// which is logged.
+func (bc *PieceFinder) cameraPose(ctx context.Context) (spatialmath.Pose, error) {
+	if bc.rfs == nil {
+		bc.logger.Warnf("no frame system, can't look in the world graveyards")
+		return nil, nil
+	}
+	in, err := bc.rfs.TransformPose(ctx, referenceframe.NewPoseInFrame(bc.conf.cameraFrame(), spatialmath.NewZeroPose()), referenceframe.World, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		bc.logger.Warnf("can't look in the world graveyards, %s isn't in the frame system: %v", bc.conf.cameraFrame(), err)
+		return nil, nil
+	}
+	return in.Pose(), nil
+}
+
+// findGraveyard is the pieces in conf's graveyards in pc, in the order of the regions and in
+// each from the top of the frame down. A piece is what sticks up more than footprintHeight off
+// the floor of the region, its lowest points, clustered like findAnomalies, and colored against
+// that floor like a square's piece is against its surface, see classifyPieceColor; heights go by
+// surface, the board's.
+func (bc *PieceFinder) findGraveyard(ctx context.Context, pc pointcloud.PointCloud, surface boardSurface, conf *PieceFinderConfig) ([]graveyardPiece, error) {
+	var camera spatialmath.Pose
+	for _, r := range conf.Graveyards {
+		if r.World != nil {
+			var err error
+			camera, err = bc.cameraPose(ctx)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	pieces := []graveyardPiece{}
+	for _, r := range conf.Graveyards {
+		in := inRegion(r, bc, camera)
+		region := pointcloud.NewBasicEmpty()
+		var err error
+		pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			if in(p) {
+				err = region.Set(p, d)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		found, err := regionPieces(region, surface, conf)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, found...)
+	}
+	return pieces, nil
+}
+
+// regionPieces is the pieces in region, see findGraveyard, from the top of the frame down.
+func regionPieces(region pointcloud.PointCloud, surface boardSurface, conf *PieceFinderConfig) ([]graveyardPiece, error) {
+	if region.Size() == 0 {
+		return nil, nil
+	}
+	height := surface.heights(region)
+	hs := []float64{}
+	region.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		hs = append(hs, height(p))
+		return true
+	})
+	slices.Sort(hs)
+	// the lowest of them, past the noise under it
+	floor := hs[len(hs)/100]
+
+	var floorPoints, raised []pointcloud.PointAndData
+	at := []r2.Point{}
+	region.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		switch h := height(p) - floor; {
+		case h > conf.maxPieceHeight():
+			// an arm or hand over it
+		case h > footprintHeight:
+			raised = append(raised, pointcloud.PointAndData{P: p, D: d})
+			at = append(at, surface.coords(p))
+		case h <= conf.surfaceBand():
+			floorPoints = append(floorPoints, pointcloud.PointAndData{P: p, D: d})
+		}
+		return true
+	})
+
+	pieces := []graveyardPiece{}
+	for _, cluster := range clusterPoints(at, anomalyCell) {
+		if len(cluster) <= minPiecePoints {
+			continue
+		}
+		points := pointcloud.NewBasicPointCloud(len(cluster))
+		// with the floor round it to color it against
+		colored := pointcloud.NewBasicPointCloud(len(cluster) + len(floorPoints))
+		var sum r3.Vector
+		for _, i := range cluster {
+			if err := points.Set(raised[i].P, raised[i].D); err != nil {
+				return nil, err
+			}
+			if err := colored.Set(raised[i].P, raised[i].D); err != nil {
+				return nil, err
+			}
+			sum = sum.Add(raised[i].P)
+		}
+		for _, pd := range floorPoints {
+			if err := colored.Set(pd.P, pd.D); err != nil {
+				return nil, err
+			}
+		}
+		c, confidence, _, _, _ := classifyPieceColor(colored, height, conf, minPiecePoints)
+		if c == 0 {
+			// not tall enough to be a piece
+			continue
+		}
+		pieces = append(pieces, graveyardPiece{c, confidence, points, sum.Mul(1 / float64(len(cluster)))})
+	}
+	slices.SortFunc(pieces, func(a, b graveyardPiece) int {
+		if a.center.Y != b.center.Y {
+			return compareFloats(a.center.Y, b.center.Y)
+		}
+		return compareFloats(a.center.X, b.center.X)
+	})
+	return pieces, nil
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// graveyardObjects is an object for each of pieces, labeled by graveyardLabel, its points in the
+// world frame like a square's.
+func (bc *PieceFinder) graveyardObjects(ctx context.Context, pieces []graveyardPiece) ([]*viz.Object, error) {
+	objects := make([]*viz.Object, 0, len(pieces))
+	for i, p := range pieces {
+		pc := p.points
+		if bc.rfs != nil {
+			var err error
+			pc, err = bc.rfs.TransformPointCloud(ctx, p.points, bc.conf.cameraFrame(), "world")
+			if err != nil {
+				return nil, err
+			}
+		}
+		o, err := viz.NewObjectWithLabel(pc, graveyardLabel(i, p), nil)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// addGraveyard puts how many of pieces, the graveyard's, are white and black in a capture's
+// extra as "graveyard", when there are graveyards to look in.
+func (bc *PieceFinder) addGraveyard(extra map[string]interface{}, pieces []graveyardPiece) {
+	if len(bc.conf.Graveyards) == 0 {
+		return
+	}
+	var counts [3]int
+	for _, p := range pieces {
+		counts[p.color]++
+	}
+	extra["graveyard"] = map[string]interface{}{"white": counts[1], "black": counts[2]}
+}
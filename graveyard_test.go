@@ -0,0 +1,194 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// graveyardBoard is boxesBoard with the table right of it, the same 600mm off, from pixel 900
+// across, and a white piece and under it a black one standing on it.
+func graveyardBoard(t *testing.T) pointcloud.PointCloud {
+	t.Helper()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	pc := boxesBoard(t)
+	table := pointcloud.NewColoredData(color.NRGBA{120, 120, 120, 255})
+	white := pointcloud.NewColoredData(color.NRGBA{240, 240, 240, 255})
+	black := pointcloud.NewColoredData(color.NRGBA{30, 30, 30, 255})
+	for v := 250; v < 500; v += 2 {
+		for u := 900; u < 1050; u += 2 {
+			z, d := 600.0, table
+			switch p := (image.Point{u, v}); {
+			case p.In(image.Rect(960, 300, 990, 330)):
+				z, d = 560, white
+			case p.In(image.Rect(960, 400, 990, 430)):
+				z, d = 560, black
+			}
+			x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+			test.That(t, pc.Set(r3.Vector{X: x * z, Y: y * z, Z: z}, d), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+func TestGraveyard(t *testing.T) {
+	ctx := context.Background()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := graveyardBoard(t)
+	conf := &PieceFinderConfig{
+		Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1,
+		Graveyards: []GraveyardRegion{{Image: []int{900, 250, 1050, 500}}},
+	}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	check := func(all viscapture.VisCapture) {
+		t.Helper()
+		test.That(t, all.Extra["graveyard"], test.ShouldResemble, map[string]interface{}{"white": 1, "black": 1})
+		test.That(t, len(all.Objects), test.ShouldEqual, 66)
+		test.That(t, checkChessGrid(all), test.ShouldBeNil)
+		// the white one's higher up the frame, so first
+		for _, want := range []struct {
+			label string
+			at    image.Rectangle
+		}{{"X0-1", image.Rect(960, 300, 990, 330)}, {"X1-2", image.Rect(960, 400, 990, 430)}} {
+			o := objectOn(t, all, want.label[:2])
+			test.That(t, o.Geometry.Label(), test.ShouldEqual, want.label)
+			md := o.MetaData()
+			c := md.Center()
+			u, v := ip.PointToPixel(c.X, c.Y, c.Z)
+			test.That(t, image.Pt(int(u), int(v)).In(want.at), test.ShouldBeTrue)
+			test.That(t, c.Z, test.ShouldAlmostEqual, 560, 1)
+		}
+	}
+
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	check(all)
+	// and still when the frame hasn't changed
+	conf.ChangeThreshold = 0
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["unchanged"], test.ShouldBeTrue)
+	check(all)
+	conf.ChangeThreshold = -1
+
+	// the same place as a box in the world, the camera at its origin
+	rfs := pf.rfs.(*inject.FrameSystemService)
+	rfs.TransformPoseFunc = func(ctx context.Context, pose *referenceframe.PoseInFrame, dst string,
+		additionalTransforms []*referenceframe.LinkInFrame,
+	) (*referenceframe.PoseInFrame, error) {
+		return referenceframe.NewPoseInFrame(dst, pose.Pose()), nil
+	}
+	conf.Graveyards = []GraveyardRegion{{World: []float64{
+		(900 - ip.Ppx) / ip.Fx * 600, (250 - ip.Ppy) / ip.Fy * 600, 500,
+		(1050 - ip.Ppx) / ip.Fx * 600, (500 - ip.Ppy) / ip.Fy * 600, 610,
+	}}}
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	check(all)
+
+	// an empty one has nothing in it
+	conf.Graveyards = []GraveyardRegion{{Image: []int{1060, 250, 1200, 500}}}
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["graveyard"], test.ShouldResemble, map[string]interface{}{"white": 0, "black": 0})
+	test.That(t, len(all.Objects), test.ShouldEqual, 64)
+
+	// without any it isn't said
+	conf.Graveyards = nil
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["graveyard"], test.ShouldBeNil)
+}
+
+func TestGraveyardFailureKeepsLast(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := graveyardBoard(t)
+	inImage := []GraveyardRegion{{Image: []int{900, 250, 1050, 500}}}
+	conf := &PieceFinderConfig{
+		Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1,
+		Graveyards: inImage,
+	}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	// the caller gives up while the camera's being looked up for a world graveyard
+	rfs := pf.rfs.(*inject.FrameSystemService)
+	rfs.TransformPoseFunc = func(ctx context.Context, pose *referenceframe.PoseInFrame, dst string,
+		additionalTransforms []*referenceframe.LinkInFrame,
+	) (*referenceframe.PoseInFrame, error) {
+		cancel()
+		return nil, ctx.Err()
+	}
+	conf.Graveyards = []GraveyardRegion{{World: []float64{-1000, -1000, 0, 1000, 1000, 1000}}}
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// the last good analysis is still there to serve
+	conf.Graveyards = inImage
+	conf.ChangeThreshold = 0
+	all, err := pf.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["unchanged"], test.ShouldBeTrue)
+	test.That(t, all.Extra["graveyard"], test.ShouldResemble, map[string]interface{}{"white": 1, "black": 1})
+}
+
+func TestValidateGraveyards(t *testing.T) {
+	test.That(t, validateGraveyards(nil), test.ShouldBeNil)
+	test.That(t, validateGraveyards([]GraveyardRegion{{Image: []int{0, 0, 10, 10}}, {World: []float64{0, 0, 0, 1, 1, 1}}}), test.ShouldBeNil)
+	test.That(t, validateGraveyards([]GraveyardRegion{{}}), test.ShouldNotBeNil)
+	test.That(t, validateGraveyards([]GraveyardRegion{{Image: []int{0, 0, 10, 10}, World: []float64{0, 0, 0, 1, 1, 1}}}), test.ShouldNotBeNil)
+	test.That(t, validateGraveyards([]GraveyardRegion{{Image: []int{10, 0, 0, 10}}}), test.ShouldNotBeNil)
+	test.That(t, validateGraveyards([]GraveyardRegion{{World: []float64{0, 0, 0, 1, 1}}}), test.ShouldNotBeNil)
+}
+
+func TestGetCenterForGraveyard(t *testing.T) {
+	s, f := newTestChess(t)
+	all, err := f.capture()
+	test.That(t, err, test.ShouldBeNil)
+
+	// the piece finder sees the fourth captured piece a little off where it was put
+	put, err := s.graveyardPosition(all, 3)
+	test.That(t, err, test.ShouldBeNil)
+	seen := r3.Vector{X: put.X + 15, Y: put.Y - 10}
+	pc, err := fakeSquareCloud(seen, 40)
+	test.That(t, err, test.ShouldBeNil)
+	o, err := viz.NewObjectWithLabel(pc, "X0-2", nil)
+	test.That(t, err, test.ShouldBeNil)
+	all.Objects = append(all.Objects, o)
+	test.That(t, checkChessGrid(all), test.ShouldBeNil)
+
+	at, err := s.getCenterFor(all, "X3", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, at.X, test.ShouldAlmostEqual, seen.X, 1)
+	test.That(t, at.Y, test.ShouldAlmostEqual, seen.Y, 1)
+	test.That(t, at.Z, test.ShouldAlmostEqual, 40)
+
+	// one it doesn't see is where it was put
+	at, err = s.getCenterFor(all, "X5", nil)
+	test.That(t, err, test.ShouldBeNil)
+	want, err := s.graveyardPosition(all, 5)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.R3VectorAlmostEqual(at, want, 1e-9), test.ShouldBeTrue)
+}
@@ -0,0 +1,131 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"sync"
+)
+
+// GrayPlane is an 8 bit gray image in one flat slice, rows one after the other, so a frame
+// is a single allocation instead of one per row.
+type GrayPlane struct {
+	Width, Height int
+	Pix           []uint8
+}
+
+func (p *GrayPlane) offset(x, y int) int {
+	return y*p.Width + x
+}
+
+// At is the gray value at x, y.
+func (p *GrayPlane) At(x, y int) uint8 {
+	return p.Pix[p.offset(x, y)]
+}
+
+// Set sets the gray value at x, y.
+func (p *GrayPlane) Set(x, y int, v uint8) {
+	p.Pix[p.offset(x, y)] = v
+}
+
+// Row is row y, sharing Pix.
+func (p *GrayPlane) Row(y int) []uint8 {
+	i := p.offset(0, y)
+	return p.Pix[i : i+p.Width : i+p.Width]
+}
+
+var grayPlanePool sync.Pool
+
+// getGrayPlane is an all black width x height plane, reusing a released one when there is one.
+func getGrayPlane(width, height int) *GrayPlane {
+	p, _ := grayPlanePool.Get().(*GrayPlane)
+	if p == nil {
+		p = &GrayPlane{}
+	}
+	p.Width, p.Height = width, height
+	p.Pix = resizeZeroed(p.Pix, width*height)
+	return p
+}
+
+// release hands p back for the next frame, it mustn't be used afterwards.
+func (p *GrayPlane) release() {
+	if p != nil {
+		grayPlanePool.Put(p)
+	}
+}
+
+// resizeZeroed is s with length n and every element zero, only allocating if it's too small.
+func resizeZeroed[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	s = s[:n]
+	clear(s)
+	return s
+}
+
+func makeGrayImage(img image.Image) *GrayPlane {
+	return makeGrayImageRect(img, img.Bounds())
+}
+
+// makeGrayImageRect is makeGrayImage of just bounds, which has to be inside the image.
+func makeGrayImageRect(img image.Image, bounds image.Rectangle) *GrayPlane {
+	gray := getGrayPlane(bounds.Dx(), bounds.Dy())
+	parallelRows(gray.Height, func(start, end int) {
+		for y := start; y < end; y++ {
+			grayRow(img, bounds.Min.X, bounds.Min.Y+y, gray.Row(y))
+		}
+	})
+	return gray
+}
+
+// grayRow fills dst with the gray of the len(dst) pixels starting at (x0, y), in image coordinates.
+// *image.RGBA and *image.YCbCr are read straight out of Pix, everything else goes through At.
+// Both give exactly the same values.
+func grayRow(img image.Image, x0, y int, dst []uint8) {
+	switch im := img.(type) {
+	case *image.RGBA:
+		i := im.PixOffset(x0, y)
+		for x := range dst {
+			p := im.Pix[i+4*x : i+4*x+3 : i+4*x+3]
+			dst[x] = uint8((int(p[0]) + int(p[1]) + int(p[2])) / 3)
+		}
+	case *image.YCbCr:
+		for x := range dst {
+			yi := im.YOffset(x0+x, y)
+			ci := im.COffset(x0+x, y)
+			r, g, b, _ := color.YCbCr{Y: im.Y[yi], Cb: im.Cb[ci], Cr: im.Cr[ci]}.RGBA()
+			dst[x] = uint8((int(r>>8) + int(g>>8) + int(b>>8)) / 3)
+		}
+	default:
+		grayRowGeneric(img, x0, y, dst)
+	}
+}
+
+func grayRowGeneric(img image.Image, x0, y int, dst []uint8) {
+	for x := range dst {
+		r, g, b, _ := img.At(x0+x, y).RGBA()
+		dst[x] = uint8((int(r>>8) + int(g>>8) + int(b>>8)) / 3)
+	}
+}
+
+// parallelRows splits [0, n) into one contiguous chunk per CPU, runs fn on each at the same
+// time, and waits for them all.
+func parallelRows(n int, fn func(start, end int)) {
+	workers := min(runtime.GOMAXPROCS(0), n)
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, min(start+chunk, n))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package viamchess
+
+import (
+	"image"
+	"image/draw"
+	"runtime"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// genericImage hides the concrete type so grayRow has to go through At.
+type genericImage struct {
+	image.Image
+}
+
+func TestGrayFastPaths(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	_, ok := input.(*image.YCbCr)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	rgba := image.NewRGBA(input.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), input, input.Bounds().Min, draw.Src)
+
+	for name, img := range map[string]image.Image{"ycbcr": input, "rgba": rgba} {
+		t.Run(name, func(t *testing.T) {
+			slow := genericImage{img}
+			test.That(t, makeGrayImage(img), test.ShouldResemble, makeGrayImage(slow))
+
+			// a window that doesn't start at the origin, like refineBorderInWindows uses
+			win := image.Rect(101, 57, 333, 290)
+			test.That(t, makeGrayImageRect(img, win), test.ShouldResemble, makeGrayImageRect(slow, win))
+
+			test.That(t, downscaleGray(img, .5), test.ShouldResemble, downscaleGray(slow, .5))
+		})
+	}
+
+	// a sub image has a non zero Min, which the fast paths have to offset by
+	sub := rgba.SubImage(image.Rect(40, 30, 500, 400))
+	test.That(t, makeGrayImage(sub), test.ShouldResemble, makeGrayImage(genericImage{sub}))
+}
+
+func BenchmarkMakeGrayImage(b *testing.B) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(b, err, test.ShouldBeNil)
+
+	b.Run("generic", func(b *testing.B) {
+		img := genericImage{input}
+		for b.Loop() {
+			makeGrayImage(img).release()
+		}
+	})
+	b.Run("fast", func(b *testing.B) {
+		for b.Loop() {
+			makeGrayImage(input).release()
+		}
+	})
+}
+
+func TestGrayPlane(t *testing.T) {
+	p := getGrayPlane(4, 3)
+	p.Set(2, 1, 200)
+	test.That(t, p.At(2, 1), test.ShouldEqual, uint8(200))
+	test.That(t, p.Pix[6], test.ShouldEqual, uint8(200))
+	test.That(t, p.Row(1), test.ShouldResemble, []uint8{0, 0, 200, 0})
+	p.release()
+
+	// a reused plane comes back black
+	p = getGrayPlane(3, 4)
+	test.That(t, p.Pix, test.ShouldResemble, make([]uint8, 12))
+	p.release()
+}
+
+func TestGrayAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector has the pools drop what's put back")
+	}
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	// once the pools are warm, a frame's planes cost a handful of allocations, not a few per row
+	allocs := testing.AllocsPerRun(5, func() {
+		gray := makeGrayImage(input)
+		sobelEdgeDetection(gray).release()
+		gray.release()
+	})
+	test.That(t, allocs, test.ShouldBeLessThan, 5)
+
+	allocs = testing.AllocsPerRun(5, func() {
+		findBoardWithOptions(input, DefaultBoardFinderOptions())
+	})
+	test.That(t, allocs, test.ShouldBeLessThan, input.Bounds().Dy())
+}
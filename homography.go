@@ -0,0 +1,197 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// Homography is a 3x3 perspective transform, row major, mapping source image pixels to a
+// top down outputSize x outputSize view of the board with TL at 0,0.
+type Homography [9]float64
+
+// ComputeHomography maps corners (TL, TR, BR, BL) to the corners of an outputSize square.
+func ComputeHomography(corners []image.Point, outputSize int) (Homography, error) {
+	return computeHomography(floatCorners(corners), float64(outputSize))
+}
+
+// floatCorners is corners as sub-pixel corners.
+func floatCorners(corners []image.Point) []r2.Point {
+	pts := make([]r2.Point, len(corners))
+	for i, c := range corners {
+		pts[i] = r2.Point{X: float64(c.X), Y: float64(c.Y)}
+	}
+	return pts
+}
+
+// computeHomography is ComputeHomography for sub-pixel corners.
+func computeHomography(corners []r2.Point, size float64) (Homography, error) {
+	if len(corners) != 4 {
+		return Homography{}, fmt.Errorf("need 4 corners, got %d", len(corners))
+	}
+	if size <= 0 {
+		return Homography{}, fmt.Errorf("output size has to be positive, got %v", size)
+	}
+	dst := []r2.Point{{X: 0, Y: 0}, {X: size, Y: 0}, {X: size, Y: size}, {X: 0, Y: size}}
+
+	// u = (h0 x + h1 y + h2) / (h6 x + h7 y + 1), and v likewise with h3 h4 h5,
+	// gives two linear equations per corner for the 8 unknowns
+	var a [8][9]float64
+	for i, s := range corners {
+		d := dst[i]
+		a[2*i] = [9]float64{s.X, s.Y, 1, 0, 0, 0, -d.X * s.X, -d.X * s.Y, d.X}
+		a[2*i+1] = [9]float64{0, 0, 0, s.X, s.Y, 1, -d.Y * s.X, -d.Y * s.Y, d.Y}
+	}
+
+	h, ok := solve8(a)
+	if !ok {
+		return Homography{}, fmt.Errorf("corners %v don't make a quad", corners)
+	}
+	return Homography{h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7], 1}, nil
+}
+
+// solve8 solves the augmented 8x8 system a with Gaussian elimination, false if it's singular.
+func solve8(a [8][9]float64) ([8]float64, bool) {
+	for col := range 8 {
+		pivot := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return [8]float64{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := range 8 {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for c := col; c < 9; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+
+	var x [8]float64
+	for i := range 8 {
+		x[i] = a[i][8] / a[i][i]
+	}
+	return x, true
+}
+
+// Apply maps p through h.
+func (h Homography) Apply(p r2.Point) r2.Point {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	return r2.Point{
+		X: (h[0]*p.X + h[1]*p.Y + h[2]) / w,
+		Y: (h[3]*p.X + h[4]*p.Y + h[5]) / w,
+	}
+}
+
+// Inverse is the homography mapping back the other way.
+func (h Homography) Inverse() (Homography, error) {
+	// adjugate over determinant
+	inv := Homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	det := h[0]*inv[0] + h[1]*inv[3] + h[2]*inv[6]
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, fmt.Errorf("homography isn't invertible")
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, nil
+}
+
+// MapSourceToWarped is where source pixel p lands in the top down view of h.
+func MapSourceToWarped(h Homography, p r2.Point) r2.Point {
+	return h.Apply(p)
+}
+
+// MapWarpedToSource is which source pixel the top down view point p of h came from.
+func MapWarpedToSource(h Homography, p r2.Point) (r2.Point, error) {
+	inv, err := h.Inverse()
+	if err != nil {
+		return r2.Point{}, err
+	}
+	return inv.Apply(p), nil
+}
+
+// fitHomography is the least squares homography taking each of from to the same index of to,
+// it needs at least 4 pairs.
+func fitHomography(from, to []r2.Point) (Homography, error) {
+	if len(from) < 4 || len(from) != len(to) {
+		return Homography{}, fmt.Errorf("need at least 4 matching points, got %d and %d", len(from), len(to))
+	}
+
+	// centered and scaled to about 1, or the normal equations lose everything to rounding
+	nf, nt := normalizing(from), normalizing(to)
+
+	var ata [8][9]float64
+	for i := range from {
+		s, d := nf.Apply(from[i]), nt.Apply(to[i])
+		rows := [2][9]float64{
+			{s.X, s.Y, 1, 0, 0, 0, -d.X * s.X, -d.X * s.Y, d.X},
+			{0, 0, 0, s.X, s.Y, 1, -d.Y * s.X, -d.Y * s.Y, d.Y},
+		}
+		for _, row := range rows {
+			for r := range 8 {
+				for c := range 9 {
+					ata[r][c] += row[r] * row[c]
+				}
+			}
+		}
+	}
+
+	x, ok := solve8(ata)
+	if !ok {
+		return Homography{}, fmt.Errorf("points don't constrain a homography")
+	}
+	h := Homography{x[0], x[1], x[2], x[3], x[4], x[5], x[6], x[7], 1}
+
+	undo, err := nt.Inverse()
+	if err != nil {
+		return Homography{}, err
+	}
+	return undo.mul(h).mul(nf), nil
+}
+
+// normalizing moves pts to be centered on 0,0, on average sqrt 2 away.
+func normalizing(pts []r2.Point) Homography {
+	var c r2.Point
+	for _, p := range pts {
+		c = c.Add(p)
+	}
+	c = c.Mul(1 / float64(len(pts)))
+
+	d := 0.0
+	for _, p := range pts {
+		d += p.Sub(c).Norm()
+	}
+	s := 1.0
+	if d > 0 {
+		s = math.Sqrt2 * float64(len(pts)) / d
+	}
+	return Homography{s, 0, -s * c.X, 0, s, -s * c.Y, 0, 0, 1}
+}
+
+// mul is h after o.
+func (h Homography) mul(o Homography) Homography {
+	var res Homography
+	for r := range 3 {
+		for c := range 3 {
+			for k := range 3 {
+				res[r*3+c] += h[r*3+k] * o[k*3+c]
+			}
+		}
+	}
+	return res
+}
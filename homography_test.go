@@ -0,0 +1,86 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"go.viam.com/test"
+)
+
+func TestComputeHomography(t *testing.T) {
+	// a board seen at an angle, the far edge shorter than the near one
+	corners := []image.Point{{310, 120}, {905, 140}, {1010, 690}, {195, 660}}
+	h, err := ComputeHomography(corners, 1)
+	test.That(t, err, test.ShouldBeNil)
+
+	unit := []r2.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}}
+	for i, c := range corners {
+		src := r2.Point{X: float64(c.X), Y: float64(c.Y)}
+
+		w := MapSourceToWarped(h, src)
+		test.That(t, w.X, test.ShouldAlmostEqual, unit[i].X, 1e-9)
+		test.That(t, w.Y, test.ShouldAlmostEqual, unit[i].Y, 1e-9)
+
+		back, err := MapWarpedToSource(h, w)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, back.X, test.ShouldAlmostEqual, src.X, 1e-6)
+		test.That(t, back.Y, test.ShouldAlmostEqual, src.Y, 1e-6)
+	}
+
+	// the middle of the board is where the diagonals cross, nearer the far edge than the average of the corners
+	mid, err := MapWarpedToSource(h, r2.Point{X: .5, Y: .5})
+	test.That(t, err, test.ShouldBeNil)
+	w := MapSourceToWarped(h, mid)
+	test.That(t, w.X, test.ShouldAlmostEqual, .5, 1e-9)
+	test.That(t, w.Y, test.ShouldAlmostEqual, .5, 1e-9)
+	test.That(t, mid.Y, test.ShouldBeLessThan, 400)
+
+	big, err := ComputeHomography(corners, 800)
+	test.That(t, err, test.ShouldBeNil)
+	p := big.Apply(r2.Point{X: 1010, Y: 690})
+	test.That(t, p.X, test.ShouldAlmostEqual, 800, 1e-6)
+	test.That(t, p.Y, test.ShouldAlmostEqual, 800, 1e-6)
+}
+
+func TestComputeHomographyDegenerate(t *testing.T) {
+	_, err := ComputeHomography([]image.Point{{0, 0}, {10, 0}, {20, 0}, {30, 0}}, 8)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = ComputeHomography([]image.Point{{0, 0}, {10, 0}, {10, 10}}, 8)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = ComputeHomography([]image.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestFitHomography(t *testing.T) {
+	corners := []image.Point{{310, 120}, {905, 140}, {1010, 690}, {195, 660}}
+	h, err := ComputeHomography(corners, 8)
+	test.That(t, err, test.ShouldBeNil)
+	toImage, err := h.Inverse()
+	test.That(t, err, test.ShouldBeNil)
+
+	from, to := []r2.Point{}, []r2.Point{}
+	for j := 1; j < 8; j++ {
+		for i := 1; i < 8; i++ {
+			if (i+j)%3 == 0 {
+				continue
+			}
+			p := r2.Point{X: float64(i), Y: float64(j)}
+			from = append(from, p)
+			to = append(to, toImage.Apply(p))
+		}
+	}
+
+	fitted, err := fitHomography(from, to)
+	test.That(t, err, test.ShouldBeNil)
+	for i, c := range corners {
+		p := fitted.Apply([]r2.Point{{X: 0, Y: 0}, {X: 8, Y: 0}, {X: 8, Y: 8}, {X: 0, Y: 8}}[i])
+		test.That(t, p.X, test.ShouldAlmostEqual, c.X, 1e-6)
+		test.That(t, p.Y, test.ShouldAlmostEqual, c.Y, 1e-6)
+	}
+
+	_, err = fitHomography(from[:3], to[:3])
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,56 @@
+package viamchess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	rutils "go.viam.com/rdk/utils"
+)
+
+// imageMimeTypes are what the board camera encodes its images as, the first when none is asked for.
+var imageMimeTypes = []string{rutils.MimeTypeJPEG, rutils.MimeTypePNG}
+
+func validateJPEGQuality(quality int) error {
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("jpeg-quality has to be 1 to 100, or 0 for %d, got %d", jpeg.DefaultQuality, quality)
+	}
+	return nil
+}
+
+// imageMimeType is the mime type an image asked for as mimeType is encoded as: the same without
+// any lazy suffix, JPEG when it's "".
+func imageMimeType(mimeType string) (string, error) {
+	mimeType, _ = rutils.CheckLazyMIMEType(mimeType)
+	switch mimeType {
+	case "":
+		return rutils.MimeTypeJPEG, nil
+	case rutils.MimeTypeJPEG, rutils.MimeTypePNG:
+		return mimeType, nil
+	}
+	return "", fmt.Errorf("can't encode images as %q, only %v", mimeType, imageMimeTypes)
+}
+
+// encodeImage is img as mimeType, see imageMimeType, JPEG at quality (0 is jpeg.DefaultQuality),
+// so PNG for a debug image that shouldn't have JPEG's artifacts. It's also the mime type used.
+func encodeImage(img image.Image, mimeType string, quality int) ([]byte, string, error) {
+	mimeType, err := imageMimeType(mimeType)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if mimeType == rutils.MimeTypePNG {
+		err = png.Encode(&buf, img)
+	} else {
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mimeType, nil
+}
@@ -0,0 +1,117 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	rutils "go.viam.com/rdk/utils"
+	"go.viam.com/test"
+)
+
+var (
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+	pngMagic  = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+)
+
+func TestEncodeImage(t *testing.T) {
+	img, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, tc := range []struct {
+		asked, used string
+		magic       []byte
+	}{
+		{"", rutils.MimeTypeJPEG, jpegMagic},
+		{rutils.MimeTypeJPEG, rutils.MimeTypeJPEG, jpegMagic},
+		{rutils.WithLazyMIMEType(rutils.MimeTypeJPEG), rutils.MimeTypeJPEG, jpegMagic},
+		{rutils.MimeTypePNG, rutils.MimeTypePNG, pngMagic},
+	} {
+		b, used, err := encodeImage(img, tc.asked, 0)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, used, test.ShouldEqual, tc.used)
+		test.That(t, bytes.HasPrefix(b, tc.magic), test.ShouldBeTrue)
+	}
+
+	// the quality's what's given, the default's in between
+	sizes := map[int]int{}
+	for _, q := range []int{10, 0, 95} {
+		b, _, err := encodeImage(img, rutils.MimeTypeJPEG, q)
+		test.That(t, err, test.ShouldBeNil)
+		sizes[q] = len(b)
+	}
+	test.That(t, sizes[10], test.ShouldBeLessThan, sizes[0])
+	test.That(t, sizes[0], test.ShouldBeLessThan, sizes[95])
+
+	_, _, err = encodeImage(img, "image/gif", 0)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, validateJPEGQuality(0), test.ShouldBeNil)
+	test.That(t, validateJPEGQuality(100), test.ShouldBeNil)
+	test.That(t, validateJPEGQuality(-1), test.ShouldNotBeNil)
+	test.That(t, validateJPEGQuality(101), test.ShouldNotBeNil)
+}
+
+func TestBoardCameraMimeType(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pinned := [][]int{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	conf := &BoardCameraConfig{Input: "cam", Width: 400, Corners: pinned}
+	bc := newTestBoardCamera(t, conf, input, nil)
+
+	props, err := bc.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.MimeTypes, test.ShouldResemble, []string{rutils.MimeTypeJPEG, rutils.MimeTypePNG})
+
+	for _, tc := range []struct {
+		asked, used string
+		magic       []byte
+	}{
+		{"", rutils.MimeTypeJPEG, jpegMagic},
+		{rutils.MimeTypeJPEG, rutils.MimeTypeJPEG, jpegMagic},
+		{rutils.MimeTypePNG, rutils.MimeTypePNG, pngMagic},
+	} {
+		b, meta, err := bc.Image(ctx, tc.asked, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, meta.MimeType, test.ShouldEqual, tc.used)
+		test.That(t, bytes.HasPrefix(b, tc.magic), test.ShouldBeTrue)
+		test.That(t, len(meta.Annotations.BoundingBoxes), test.ShouldEqual, 64)
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, cfg.Width, test.ShouldEqual, 400)
+	}
+	_, _, err = bc.Image(ctx, "image/gif", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// PNG is lossless, the warped image exactly
+	images, _, err := bc.Images(ctx, []string{sourceWarped}, map[string]interface{}{"mime_type": rutils.MimeTypePNG})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, images[0].MimeType(), test.ShouldEqual, rutils.MimeTypePNG)
+	b, err := images[0].Bytes(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, bytes.HasPrefix(b, pngMagic), test.ShouldBeTrue)
+	warped, err := images[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	encoded, _, err := bc.Image(ctx, rutils.MimeTypePNG, nil)
+	test.That(t, err, test.ShouldBeNil)
+	decoded, _, err := image.Decode(bytes.NewReader(encoded))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, decoded.(*image.RGBA).Pix, test.ShouldResemble, warped.(*image.RGBA).Pix)
+
+	_, _, err = bc.Images(ctx, nil, map[string]interface{}{"mime_type": "image/gif"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a lower quality is smaller
+	low := newTestBoardCamera(t, &BoardCameraConfig{Input: "cam", Width: 400, Corners: pinned, JPEGQuality: 10}, input, nil)
+	small, _, err := low.Image(ctx, rutils.MimeTypeJPEG, nil)
+	test.That(t, err, test.ShouldBeNil)
+	big, _, err := bc.Image(ctx, rutils.MimeTypeJPEG, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(small), test.ShouldBeLessThan, len(big))
+
+	_, _, err = (&BoardCameraConfig{Input: "cam", JPEGQuality: 101}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
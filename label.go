@@ -0,0 +1,78 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// glyphWidth, glyphHeight and glyphAscent are basicfont.Face7x13's.
+	glyphWidth  = 7
+	glyphHeight = 13
+	glyphAscent = 11
+)
+
+// labelBackground is behind every label, so it can be read over light squares and dark ones.
+var labelBackground = color.NRGBA{0, 0, 0, 160}
+
+func validateLabelScale(scale int) error {
+	if scale < 0 {
+		return fmt.Errorf("label-scale can't be negative, got %d (0 picks it from the square size)", scale)
+	}
+	return nil
+}
+
+// labelScale is how many times bigger than basicfont.Face7x13 a label chars long is drawn in
+// square: scale when it's set, otherwise as big as keeps it a quarter of the square tall and
+// inside it across, and never less than 1.
+func labelScale(square image.Rectangle, chars, scale int) int {
+	if scale > 0 {
+		return scale
+	}
+	tall := square.Dy() / (4 * glyphHeight)
+	across := square.Dx() / (chars*glyphWidth + 2)
+	return max(1, min(tall, across))
+}
+
+// labelSize is how big a label of s at scale is, with the scale pixels of background around it.
+func labelSize(s string, scale int) image.Point {
+	return image.Pt((len(s)*glyphWidth+2)*scale, (glyphHeight+2)*scale)
+}
+
+// drawLabel draws s at scale with its middle at mid: basicfont.Face7x13's glyphs drawn once
+// into a mask and each of its pixels drawn scale x scale in c, over labelBackground. It's where
+// the label is, background and all.
+func drawLabel(dst *image.RGBA, mid image.Point, s string, scale int, c color.Color) image.Rectangle {
+	size := labelSize(s, scale)
+	box := image.Rectangle{Min: mid.Sub(size.Div(2))}
+	box.Max = box.Min.Add(size)
+	draw.Draw(dst, box, image.NewUniform(labelBackground), image.Point{}, draw.Over)
+
+	mask := image.NewAlpha(image.Rect(0, 0, len(s)*glyphWidth, glyphHeight))
+	d := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: basicfont.Face7x13,
+		Dot:  fixed.Point26_6{X: 0, Y: fixed.I(glyphAscent)},
+	}
+	d.DrawString(s)
+
+	fill := image.NewUniform(c)
+	text := box.Min.Add(image.Pt(scale, scale))
+	for y := range glyphHeight {
+		for x := range len(s) * glyphWidth {
+			if mask.AlphaAt(x, y).A == 0 {
+				continue
+			}
+			at := text.Add(image.Pt(x*scale, y*scale))
+			draw.Draw(dst, image.Rectangle{at, at.Add(image.Pt(scale, scale))}, fill, image.Point{}, draw.Over)
+		}
+	}
+	return box.Intersect(dst.Bounds())
+}
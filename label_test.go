@@ -0,0 +1,87 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// colorBounds is the smallest rectangle of img holding every pixel that's c.
+func colorBounds(img *image.RGBA, c color.RGBA) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y) == c {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestLabelScale(t *testing.T) {
+	square := func(size int) image.Rectangle { return image.Rect(0, 0, size, size) }
+	test.That(t, labelScale(square(20), 6, 0), test.ShouldEqual, 1)
+	test.That(t, labelScale(square(70), 6, 0), test.ShouldEqual, 1)
+	test.That(t, labelScale(square(120), 6, 0), test.ShouldEqual, 2)
+	test.That(t, labelScale(square(240), 6, 0), test.ShouldEqual, 4)
+	// a long label has to fit across
+	test.That(t, labelScale(square(240), 20, 0), test.ShouldEqual, 1)
+	// unless it's set
+	test.That(t, labelScale(square(20), 6, 3), test.ShouldEqual, 3)
+
+	test.That(t, labelSize("e2-W", 1), test.ShouldResemble, image.Pt(30, 15))
+	test.That(t, labelSize("e2-W", 3), test.ShouldResemble, image.Pt(90, 45))
+
+	test.That(t, validateLabelScale(0), test.ShouldBeNil)
+	test.That(t, validateLabelScale(-1), test.ShouldNotBeNil)
+}
+
+func TestDrawLabel(t *testing.T) {
+	white := image.NewUniform(color.White)
+	red := color.RGBA{255, 0, 0, 255}
+	for _, scale := range []int{1, 2, 4} {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+		draw.Draw(img, img.Bounds(), white, image.Point{}, draw.Src)
+		box := drawLabel(img, image.Pt(200, 50), "e2:empty", scale, red)
+		test.That(t, box.Size(), test.ShouldResemble, labelSize("e2:empty", scale))
+		test.That(t, box.Min, test.ShouldResemble, image.Pt(200, 50).Sub(box.Size().Div(2)))
+
+		// the text is inside its background, which is darker than the white it's on
+		text := colorBounds(img, red)
+		test.That(t, text.In(box), test.ShouldBeTrue)
+		test.That(t, text.Dy(), test.ShouldBeGreaterThanOrEqualTo, 8*scale)
+		test.That(t, img.RGBAAt(box.Min.X, box.Min.Y).R, test.ShouldBeLessThan, 128)
+		test.That(t, img.RGBAAt(box.Min.X-1, box.Min.Y).R, test.ShouldEqual, 255)
+	}
+
+	// off the edge is cut off
+	img := image.NewRGBA(image.Rect(0, 0, 50, 50))
+	box := drawLabel(img, image.Pt(0, 0), "h8", 2, color.RGBA{255, 0, 0, 255})
+	test.That(t, box.Min, test.ShouldResemble, image.Point{})
+}
+
+func TestCreateDebugImageLabels(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	textSize := func(size, scale int) image.Point {
+		input := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(input, input.Bounds(), image.NewUniform(color.Gray{128}), image.Point{}, draw.Src)
+		squares := []squareInfo{{name: "e2", file: 'e', rank: 2, color: 1, originalBounds: input.Bounds()}}
+		out, _, err := createDebugImage(input, squares, themeImage, nil, scale)
+		test.That(t, err, test.ShouldBeNil)
+		return colorBounds(out.(*image.RGBA), red).Size()
+	}
+
+	// the bigger the square the bigger the label
+	small, medium, big := textSize(60, 0), textSize(120, 0), textSize(240, 0)
+	test.That(t, medium.X, test.ShouldBeGreaterThan, small.X)
+	test.That(t, medium.Y, test.ShouldBeGreaterThan, small.Y)
+	test.That(t, big.X, test.ShouldBeGreaterThan, medium.X)
+	test.That(t, big.X, test.ShouldEqual, 2*medium.X)
+	// and it stays what it's set to
+	test.That(t, textSize(240, 1), test.ShouldResemble, small)
+}
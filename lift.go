@@ -0,0 +1,104 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/vision/viscapture"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+const (
+	defaultLiftClearance = 30.0
+	defaultMinLiftHeight = 60.0
+	defaultLiftCorridor  = 40.0
+)
+
+// liftPlan is how high a piece gets carried, and which square made it that high.
+// square is "" when nothing on the way mattered.
+type liftPlan struct {
+	height float64
+	square string
+}
+
+// distanceToSegment is how far p is from the segment a-b.
+func distanceToSegment(p, a, b r2.Point) float64 {
+	ab := b.Sub(a)
+	l2 := ab.Dot(ab)
+	if l2 == 0 {
+		return p.Sub(a).Norm()
+	}
+	t := math.Max(0, math.Min(1, p.Sub(a).Dot(ab)/l2))
+	return p.Sub(a.Add(ab.Mul(t))).Norm()
+}
+
+// squaresInCorridor are the squares whose centers are within halfWidth of the straight line
+// from a to b, sorted by name. Knight moves are treated as a straight line too, the piece
+// is carried over, not around.
+func squaresInCorridor(a, b r2.Point, halfWidth float64, centers map[string]r2.Point) []string {
+	res := []string{}
+	for name, c := range centers {
+		if distanceToSegment(c, a, b) <= halfWidth {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// planLift picks how high to carry a piece grabbed at grabZ from fromCenter to toCenter: high enough
+// that its bottom clears the tallest piece along the way by the clearance, but no higher than the safe height.
+func (s *viamChessChess) planLift(data viscapture.VisCapture, from, to string, fromCenter, toCenter r3.Vector, grabZ float64) liftPlan {
+	centers := map[string]r2.Point{}
+	tops := map[string]float64{}
+	empty := []float64{}
+
+	for _, o := range data.Objects {
+		label := o.Geometry.Label()
+		name, _, ok := strings.Cut(label, "-")
+		if !ok || name == from || name == to {
+			continue
+		}
+		md := o.MetaData()
+		center := md.Center()
+		if strings.HasSuffix(label, "-0") {
+			empty = append(empty, center.Z)
+			continue
+		}
+		centers[name] = r2.Point{X: center.X, Y: center.Y}
+		tops[name] = touch.PCFindHighestInRegion(o, image.Rect(-1000, -1000, 1000, 1000)).Z
+	}
+
+	// the board is where the empty squares are
+	boardZ := 0.0
+	if len(empty) > 0 {
+		sort.Float64s(empty)
+		boardZ = empty[len(empty)/2]
+	}
+
+	plan := liftPlan{}
+	tallest := boardZ
+	path := squaresInCorridor(
+		r2.Point{X: fromCenter.X, Y: fromCenter.Y},
+		r2.Point{X: toCenter.X, Y: toCenter.Y},
+		s.conf.liftCorridor(), centers)
+	for _, name := range path {
+		if tops[name] > tallest {
+			tallest = tops[name]
+			plan.square = name
+		}
+	}
+
+	// the held piece hangs below the gripper by as much as it was grabbed above the board
+	motion := s.motionSettings()
+	plan.height = tallest + (grabZ - boardZ) + motion.liftClearance
+	plan.height = math.Max(plan.height, motion.minLiftHeight)
+	plan.height = math.Min(plan.height, s.conf.safeHeight())
+	return plan
+}
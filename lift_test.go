@@ -0,0 +1,93 @@
+package viamchess
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+)
+
+func TestDistanceToSegment(t *testing.T) {
+	a, b := r2.Point{X: 0, Y: 0}, r2.Point{X: 100, Y: 0}
+	test.That(t, distanceToSegment(r2.Point{X: 50, Y: 10}, a, b), test.ShouldAlmostEqual, 10)
+	test.That(t, distanceToSegment(r2.Point{X: -30, Y: 40}, a, b), test.ShouldAlmostEqual, 50)
+	test.That(t, distanceToSegment(r2.Point{X: 130, Y: 0}, a, b), test.ShouldAlmostEqual, 30)
+	test.That(t, distanceToSegment(r2.Point{X: 3, Y: 4}, a, a), test.ShouldAlmostEqual, 5)
+}
+
+func fakeCenters() map[string]r2.Point {
+	centers := map[string]r2.Point{}
+	for _, name := range squareNames {
+		c := fakeSquareCenter(name)
+		centers[name] = r2.Point{X: c.X, Y: c.Y}
+	}
+	return centers
+}
+
+func corridor(from, to string, halfWidth float64) []string {
+	a, b := fakeSquareCenter(from), fakeSquareCenter(to)
+	return squaresInCorridor(r2.Point{X: a.X, Y: a.Y}, r2.Point{X: b.X, Y: b.Y}, halfWidth, fakeCenters())
+}
+
+func TestSquaresInCorridor(t *testing.T) {
+	test.That(t, corridor("e2", "e4", 30), test.ShouldResemble, []string{"e2", "e3", "e4"})
+	test.That(t, corridor("e2", "e4", 60), test.ShouldResemble,
+		[]string{"d2", "d3", "d4", "e1", "e2", "e3", "e4", "e5", "f2", "f3", "f4"})
+
+	// the neighbors of a diagonal are 35mm off it on a 50mm board
+	test.That(t, corridor("a1", "d4", 30), test.ShouldResemble, []string{"a1", "b2", "c3", "d4"})
+	test.That(t, len(corridor("a1", "d4", 40)), test.ShouldEqual, 10)
+
+	// a knight is carried over the two squares it would hop
+	test.That(t, corridor("b1", "c3", 30), test.ShouldResemble, []string{"b1", "b2", "c2", "c3"})
+	test.That(t, corridor("g1", "h3", 20), test.ShouldResemble, []string{"g1", "h3"})
+}
+
+func TestMoveLiftHeight(t *testing.T) {
+	ctx := context.Background()
+
+	move := func(occupied map[string]int) (map[string]interface{}, *fakeRobot) {
+		s, f := newTestChess(t)
+		f.occupied = occupied
+		res, err := s.DoCommand(ctx, map[string]interface{}{
+			"move": map[string]interface{}{"from": "e2", "to": "e4", "n": 1},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return res, f
+	}
+
+	// grabbed at the top of the 40mm pawn, so its bottom is 40mm under the gripper
+	res, f := move(map[string]int{"e2": 1})
+	test.That(t, res["lift_height"], test.ShouldEqual, 40+defaultLiftClearance)
+	test.That(t, res["lift_square"], test.ShouldEqual, "")
+	e4 := fakeSquareCenter("e4")
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e4.X, Y: e4.Y, Z: safeZ})), test.ShouldEqual, -1)
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e4.X, Y: e4.Y, Z: 70})), test.ShouldBeGreaterThanOrEqualTo, 0)
+
+	// d3 is next to the path, not on it
+	res, _ = move(map[string]int{"e2": 1, "d3": 1})
+	test.That(t, res["lift_square"], test.ShouldEqual, "")
+
+	res, f = move(map[string]int{"e2": 1, "e3": 2})
+	test.That(t, res["lift_height"], test.ShouldEqual, 40+40+defaultLiftClearance)
+	test.That(t, res["lift_square"], test.ShouldEqual, "e3")
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e4.X, Y: e4.Y, Z: 110})), test.ShouldBeGreaterThanOrEqualTo, 0)
+}
+
+func TestPlanLiftBounds(t *testing.T) {
+	s, f := newTestChess(t)
+	f.occupied = map[string]int{"e2": 1, "e3": 1}
+	data, err := f.capture()
+	test.That(t, err, test.ShouldBeNil)
+
+	from, to := fakeSquareCenter("e2"), fakeSquareCenter("e4")
+
+	s.conf.MinLiftHeight = 190
+	test.That(t, s.planLift(data, "e2", "e4", from, to, 40).height, test.ShouldEqual, 190)
+
+	s.conf.MinLiftHeight = 0
+	s.conf.LiftClearance = 500
+	test.That(t, s.planLift(data, "e2", "e4", from, to, 40).height, test.ShouldEqual, safeZ)
+}
@@ -0,0 +1,150 @@
+package viamchess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/corentings/chess/v2"
+)
+
+const defaultLocale = "en"
+
+// translations are the user facing strings, fmt formats keyed by what they say. English has
+// every key, anything missing from another locale is said in English. Squares, moves in
+// SAN/UCI and FENs aren't translated.
+var translations = map[string]map[string]string{
+	"en": {
+		"piece.king":   "king",
+		"piece.queen":  "queen",
+		"piece.rook":   "rook",
+		"piece.bishop": "bishop",
+		"piece.knight": "knight",
+		"piece.pawn":   "pawn",
+
+		"move.to":           "%s to %s",
+		"move.takes":        "%s takes on %s",
+		"move.castle.o-o":   "castles kingside",
+		"move.castle.o-o-o": "castles queenside",
+		"move.promotes":     ", promoting to %s",
+		"move.check":        ", check",
+		"move.checkmate":    ", checkmate",
+
+		"wizard.corners":           "Take every piece off the board and keep hands and arm out of view.",
+		"wizard.orientation":       "Set the pieces up in the starting position, white on ranks 1 and 2.",
+		"wizard.heights":           "Leave the pieces in the starting position, the heights of all of them are measured.",
+		"wizard.graveyard":         "Clear the area next to the a file, the arm visits the first and last graveyard spots at safe height.",
+		"wizard.envelope":          "Leave the pieces in the starting position, the travel height is checked against the tallest one.",
+		"wizard.empty-board":       "empty board",
+		"wizard.starting-position": "starting position",
+
+		"mismatch.not-empty": "board isn't empty, there's something on %s",
+		"mismatch.flipped":   "black is on ranks 1 and 2, the piece finder's rotation is probably off by 180",
+		"mismatch.not-start": "not the starting position, check %s",
+	},
+	"fr": {
+		"piece.king":   "le roi",
+		"piece.queen":  "la dame",
+		"piece.rook":   "la tour",
+		"piece.bishop": "le fou",
+		"piece.knight": "le cavalier",
+		"piece.pawn":   "le pion",
+
+		"move.to":           "%s en %s",
+		"move.takes":        "%s prend en %s",
+		"move.castle.o-o":   "petit roque",
+		"move.castle.o-o-o": "grand roque",
+		"move.promotes":     ", promotion : %s",
+		"move.check":        ", échec",
+		"move.checkmate":    ", échec et mat",
+
+		"wizard.corners":           "Retirez toutes les pièces de l'échiquier et gardez les mains et le bras hors du champ.",
+		"wizard.orientation":       "Placez les pièces en position de départ, les blancs sur les rangées 1 et 2.",
+		"wizard.heights":           "Laissez les pièces en position de départ, la hauteur de chacune est mesurée.",
+		"wizard.graveyard":         "Dégagez la zone à côté de la colonne a, le bras va au-dessus de la première et de la dernière place du cimetière.",
+		"wizard.envelope":          "Laissez les pièces en position de départ, la hauteur de déplacement est vérifiée par rapport à la plus haute.",
+		"wizard.empty-board":       "échiquier vide",
+		"wizard.starting-position": "position de départ",
+
+		"mismatch.not-empty": "l'échiquier n'est pas vide, il y a quelque chose en %s",
+		"mismatch.flipped":   "les noirs sont sur les rangées 1 et 2, la rotation du piece finder est sans doute décalée de 180",
+		"mismatch.not-start": "ce n'est pas la position de départ, vérifiez %s",
+	},
+}
+
+// localeTranslations is the translations for locale, which can have a region like fr-CA, and
+// whether there are any.
+func localeTranslations(locale string) (map[string]string, bool) {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if lang == "" {
+		lang = defaultLocale
+	}
+	t, ok := translations[lang]
+	return t, ok
+}
+
+// localize is the string for key in locale, formatted with args. A locale or key that isn't
+// translated is said in English.
+func localize(locale, key string, args ...interface{}) string {
+	format, ok := "", false
+	if t, found := localeTranslations(locale); found {
+		format, ok = t[key]
+	}
+	if !ok {
+		format, ok = translations[defaultLocale][key]
+	}
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// pieceName is what pt is called in locale.
+func pieceName(locale string, pt chess.PieceType) string {
+	switch pt {
+	case chess.King:
+		return localize(locale, "piece.king")
+	case chess.Queen:
+		return localize(locale, "piece.queen")
+	case chess.Rook:
+		return localize(locale, "piece.rook")
+	case chess.Bishop:
+		return localize(locale, "piece.bishop")
+	case chess.Knight:
+		return localize(locale, "piece.knight")
+	}
+	return localize(locale, "piece.pawn")
+}
+
+// describeMove is m, played from pos, in words, like "knight takes on f6" for an announcement.
+func describeMove(locale string, pos *chess.Position, m *chess.Move) string {
+	var res string
+	switch {
+	case m.HasTag(chess.KingSideCastle):
+		res = localize(locale, "move.castle.o-o")
+	case m.HasTag(chess.QueenSideCastle):
+		res = localize(locale, "move.castle.o-o-o")
+	default:
+		piece := pieceName(locale, pos.Board().Piece(m.S1()).Type())
+		if m.HasTag(chess.Capture) || m.HasTag(chess.EnPassant) {
+			res = localize(locale, "move.takes", piece, m.S2())
+		} else {
+			res = localize(locale, "move.to", piece, m.S2())
+		}
+	}
+
+	if m.Promo() != chess.NoPieceType {
+		res += localize(locale, "move.promotes", pieceName(locale, m.Promo()))
+	}
+	if m.HasTag(chess.Check) {
+		if pos.Update(m).Status() == chess.Checkmate {
+			res += localize(locale, "move.checkmate")
+		} else {
+			res += localize(locale, "move.check")
+		}
+	}
+	return res
+}
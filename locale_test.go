@@ -0,0 +1,61 @@
+package viamchess
+
+import (
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestDescribeMove(t *testing.T) {
+	describe := func(locale, fen, uci string) string {
+		opt, err := chess.FEN(fen)
+		test.That(t, err, test.ShouldBeNil)
+		pos := chess.NewGame(opt).Position()
+		m, err := chess.UCINotation{}.Decode(pos, uci)
+		test.That(t, err, test.ShouldBeNil)
+		return describeMove(locale, pos, m)
+	}
+
+	capture := "k7/8/5p2/3N4/8/8/8/4K3 w - - 0 1"
+	test.That(t, describe("fr", capture, "d5f6"), test.ShouldEqual, "le cavalier prend en f6")
+	test.That(t, describe("fr-CA", capture, "d5f6"), test.ShouldEqual, "le cavalier prend en f6")
+	test.That(t, describe("en", capture, "d5f6"), test.ShouldEqual, "knight takes on f6")
+	test.That(t, describe("", capture, "e1e2"), test.ShouldEqual, "king to e2")
+
+	check := "4k3/8/5p2/3N4/8/8/8/4K3 w - - 0 1"
+	test.That(t, describe("fr", check, "d5f6"), test.ShouldEqual, "le cavalier prend en f6, échec")
+
+	mate := "k7/8/1K6/8/8/8/8/7Q w - - 0 1"
+	test.That(t, describe("en", mate, "h1h8"), test.ShouldEqual, "queen to h8, checkmate")
+
+	castle := "4k3/8/8/8/8/8/8/4K2R w K - 0 1"
+	test.That(t, describe("fr", castle, "e1g1"), test.ShouldEqual, "petit roque")
+
+	promo := "8/k3P3/8/8/8/8/8/4K3 w - - 0 1"
+	test.That(t, describe("en", promo, "e7e8q"), test.ShouldEqual, "pawn to e8, promoting to queen")
+
+	// a locale there are no translations for is English
+	test.That(t, describe("tlh", capture, "d5f6"), test.ShouldEqual, "knight takes on f6")
+}
+
+func TestLocalizeFallback(t *testing.T) {
+	_, ok := localeTranslations("tlh")
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = localeTranslations("")
+	test.That(t, ok, test.ShouldBeTrue)
+
+	// a key missing from a locale falls back to English on its own
+	flipped := translations["fr"]["mismatch.flipped"]
+	delete(translations["fr"], "mismatch.flipped")
+	defer func() { translations["fr"]["mismatch.flipped"] = flipped }()
+	test.That(t, localize("fr", "mismatch.flipped"), test.ShouldEqual, translations["en"]["mismatch.flipped"])
+	test.That(t, localize("fr", "no.such.key"), test.ShouldEqual, "no.such.key")
+
+	err := checkBoardEmpty("fr", viscapture.VisCapture{})
+	test.That(t, err.Error(), test.ShouldStartWith, "l'échiquier n'est pas vide, il y a quelque chose en a1 b1")
+	err = checkBoardEmpty("tlh", viscapture.VisCapture{})
+	test.That(t, err.Error(), test.ShouldStartWith, "board isn't empty, there's something on a1 b1")
+}
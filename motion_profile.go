@@ -0,0 +1,150 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultMotionProfile = "normal"
+
+// motionProfile is a bundle of defaults for how the arm moves. Lift heights are mm for a standard
+// set, piece-scale scales them like every other height.
+type motionProfile struct {
+	speed, acceleration          float64 // degs/sec and degs/sec^2 for the arm's joints
+	liftClearance, minLiftHeight float64
+	grabRetryPause               time.Duration // between a missed grab and the next try
+}
+
+// motionProfiles are the motion-profile choices. normal is the arm's own defaults and how
+// pieces have always been carried, quiet is for libraries and museums.
+var motionProfiles = map[string]motionProfile{
+	"normal": {
+		speed:          50,
+		acceleration:   100,
+		liftClearance:  defaultLiftClearance,
+		minLiftHeight:  defaultMinLiftHeight,
+		grabRetryPause: 250 * time.Millisecond,
+	},
+	"quiet": {
+		speed:          20,
+		acceleration:   30,
+		liftClearance:  15,
+		minLiftHeight:  40,
+		grabRetryPause: time.Second,
+	},
+	"fast": {
+		speed:          90,
+		acceleration:   250,
+		liftClearance:  40,
+		minLiftHeight:  defaultMinLiftHeight,
+		grabRetryPause: 100 * time.Millisecond,
+	},
+}
+
+func validateMotionProfile(name string) error {
+	if _, ok := motionProfiles[name]; ok {
+		return nil
+	}
+	names := []string{}
+	for n := range motionProfiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown motion-profile %q, needs to be one of %s", name, strings.Join(names, " "))
+}
+
+// motionSettings is what the arm actually moves with, a profile with every field set in the
+// config winning over it.
+type motionSettings struct {
+	profile                      string
+	speed, acceleration          float64
+	liftClearance, minLiftHeight float64
+	grabRetryPause               time.Duration
+}
+
+func (cfg *ChessConfig) motionProfile() string {
+	if cfg.MotionProfile == "" {
+		return defaultMotionProfile
+	}
+	return cfg.MotionProfile
+}
+
+// motionSettings resolves profile, which has to be valid, against the config.
+func (cfg *ChessConfig) motionSettings(profile string) motionSettings {
+	p := motionProfiles[profile]
+	m := motionSettings{
+		profile:        profile,
+		speed:          p.speed,
+		acceleration:   p.acceleration,
+		liftClearance:  scaledDefault(cfg.LiftClearance, p.liftClearance, cfg.PieceScale),
+		minLiftHeight:  scaledDefault(cfg.MinLiftHeight, p.minLiftHeight, cfg.PieceScale),
+		grabRetryPause: p.grabRetryPause,
+	}
+	if cfg.Speed > 0 {
+		m.speed = cfg.Speed
+	}
+	if cfg.Acceleration > 0 {
+		m.acceleration = cfg.Acceleration
+	}
+	if cfg.GrabRetryMillis > 0 {
+		m.grabRetryPause = time.Duration(cfg.GrabRetryMillis) * time.Millisecond
+	}
+	return m
+}
+
+func (m motionSettings) status() map[string]interface{} {
+	return map[string]interface{}{
+		"motion_profile":  m.profile,
+		"speed":           m.speed,
+		"acceleration":    m.acceleration,
+		"lift_clearance":  m.liftClearance,
+		"min_lift_height": m.minLiftHeight,
+		"grab_retry_ms":   m.grabRetryPause.Milliseconds(),
+	}
+}
+
+// motionSettings are the settings for the profile currently in use.
+func (s *viamChessChess) motionSettings() motionSettings {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	return s.conf.motionSettings(s.motionProfile)
+}
+
+// setMotionProfile switches profile without waiting for a move in progress, which picks it up
+// from its next arm motion, no reconfigure needed.
+func (s *viamChessChess) setMotionProfile(name string) (map[string]interface{}, error) {
+	err := validateMotionProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	m := s.conf.motionSettings(name)
+	if m.minLiftHeight > s.conf.safeHeight() {
+		return nil, fmt.Errorf("%s motion lifts to at least %v, over safe-height %v", name, m.minLiftHeight, s.conf.safeHeight())
+	}
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	s.logger.Infof("motion profile %s -> %s", s.motionProfile, name)
+	s.motionProfile = name
+	return m.status(), nil
+}
+
+// applyArmSpeed tells the arm the speed and acceleration of the current profile, if it hasn't
+// been already. An arm that can't change speed just keeps its own.
+func (s *viamChessChess) applyArmSpeed(ctx context.Context) {
+	m := s.motionSettings()
+	want := [2]float64{m.speed, m.acceleration}
+	if want == s.armSpeed {
+		return
+	}
+	err := callWithTimeoutErr(ctx, s, phaseMotion, func(ctx context.Context) error {
+		_, err := s.arm.DoCommand(ctx, map[string]interface{}{"set_speed": m.speed, "set_acceleration": m.acceleration})
+		return err
+	})
+	if err != nil {
+		s.logger.Warnf("can't set arm speed for %s motion, it'll move at its own: %v", m.profile, err)
+	}
+	s.armSpeed = want
+}
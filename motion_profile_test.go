@@ -0,0 +1,82 @@
+package viamchess
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestMotionSettings(t *testing.T) {
+	cfg := &ChessConfig{}
+	test.That(t, cfg.motionProfile(), test.ShouldEqual, "normal")
+	m := cfg.motionSettings("normal")
+	test.That(t, m.liftClearance, test.ShouldEqual, defaultLiftClearance)
+	test.That(t, m.minLiftHeight, test.ShouldEqual, defaultMinLiftHeight)
+
+	quiet := cfg.motionSettings("quiet")
+	test.That(t, quiet.speed, test.ShouldBeLessThan, m.speed)
+	test.That(t, quiet.minLiftHeight, test.ShouldBeLessThan, m.minLiftHeight)
+	test.That(t, quiet.grabRetryPause, test.ShouldBeGreaterThan, m.grabRetryPause)
+
+	// explicit fields win over whichever profile is in use
+	cfg = &ChessConfig{Speed: 35, LiftClearance: 25, GrabRetryMillis: 400}
+	for _, name := range []string{"normal", "quiet", "fast"} {
+		m := cfg.motionSettings(name)
+		test.That(t, m.speed, test.ShouldEqual, 35)
+		test.That(t, m.acceleration, test.ShouldEqual, motionProfiles[name].acceleration)
+		test.That(t, m.liftClearance, test.ShouldEqual, 25)
+		test.That(t, m.minLiftHeight, test.ShouldEqual, motionProfiles[name].minLiftHeight)
+		test.That(t, m.grabRetryPause, test.ShouldEqual, 400*time.Millisecond)
+	}
+
+	// profile heights are scaled like the defaults, explicit ones aren't
+	cfg = &ChessConfig{PieceScale: 2, MinLiftHeight: 70}
+	test.That(t, cfg.motionSettings("quiet").liftClearance, test.ShouldEqual, 30)
+	test.That(t, cfg.motionSettings("quiet").minLiftHeight, test.ShouldEqual, 70)
+
+	cfg = &ChessConfig{PieceFinder: "pf", Arm: "arm", Gripper: "gripper", PoseStart: "ps", MotionProfile: "stealthy"}
+	_, _, err := cfg.Validate("")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "quiet")
+	cfg.MotionProfile = "quiet"
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestSetMotionProfileMidSession(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+	move := func(from, to string) map[string]interface{} {
+		f.occupied = map[string]int{from: 1}
+		res, err := s.DoCommand(ctx, map[string]interface{}{
+			"move": map[string]interface{}{"from": from, "to": to, "n": 1},
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return res
+	}
+
+	first := move("e2", "e4")
+	test.That(t, first["motion_profile"], test.ShouldEqual, "normal")
+	normalSpeed := f.indexOf("speed 50 100")
+	test.That(t, normalSpeed, test.ShouldBeGreaterThanOrEqualTo, 0)
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"set_motion_profile": "quiet"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["motion_profile"], test.ShouldEqual, "quiet")
+	test.That(t, f.indexOf("speed 20 30"), test.ShouldEqual, -1)
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["motion_profile"], test.ShouldEqual, "quiet")
+	test.That(t, res["min_lift_height"], test.ShouldEqual, 40)
+
+	second := move("d2", "d4")
+	test.That(t, second["motion_profile"], test.ShouldEqual, "quiet")
+	test.That(t, f.indexOf("speed 20 30"), test.ShouldBeGreaterThan, normalSpeed)
+	test.That(t, second["lift_height"], test.ShouldBeLessThan, first["lift_height"])
+
+	_, err = s.DoCommand(ctx, map[string]interface{}{"set_motion_profile": "ludicrous"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, s.motionProfile, test.ShouldEqual, "quiet")
+}
@@ -0,0 +1,153 @@
+package viamchess
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/corentings/chess/v2"
+)
+
+// colorChange is the color (0 empty, 1 white, 2 black) on a square before and after a move.
+type colorChange [2]int
+
+// diffColors is the squares of d, other than the unsure ones, by what they were and became.
+func diffColors(d BoardDiff) map[chess.Square]colorChange {
+	colors := map[chess.Square]colorChange{}
+	for _, list := range [][]SquareChange{d.Emptied, d.Occupied, d.Recolored} {
+		for _, c := range list {
+			colors[c.After.ChessSquare()] = colorChange{c.Before.Color, c.After.Color}
+		}
+	}
+	return colors
+}
+
+// moveColors is the squares m changes the colors of in pos, by what they were and become.
+func moveColors(pos *chess.Position, m *chess.Move) map[chess.Square]colorChange {
+	before, after := pos.Board(), pos.Update(m).Board()
+	colors := map[chess.Square]colorChange{}
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		b, a := int(before.Piece(sq).Color()), int(after.Piece(sq).Color())
+		if b != a {
+			colors[sq] = colorChange{b, a}
+		}
+	}
+	return colors
+}
+
+// diffMatches is whether the observed changes are the ones a move makes, the squares in unsure
+// possibly having been left out of observed.
+func diffMatches(observed, move map[chess.Square]colorChange, unsure map[chess.Square]bool) bool {
+	for sq, c := range observed {
+		if move[sq] != c {
+			return false
+		}
+	}
+	for sq := range move {
+		if _, ok := observed[sq]; !ok && !unsure[sq] {
+			return false
+		}
+	}
+	return true
+}
+
+// MoveFromDiff is the legal move in game's position that changes the board the way diff says it
+// changed: one square emptied and one occupied or taken for a move or capture, the king's and
+// rook's two each for castling, and the pawn's, where it lands and the pawn it takes for en
+// passant. A square diff is unsure of can be one the move changes or not. A pawn reaching the
+// last rank promotes to a queen, there being no telling from the board what else it became.
+// It's an error, listing what it could be, when no legal move or more than one fits.
+func MoveFromDiff(diff BoardDiff, game *chess.Game) (chess.Move, error) {
+	observed := diffColors(diff)
+	if len(observed) == 0 {
+		return chess.Move{}, errors.New("the board hasn't changed")
+	}
+	unsure := map[chess.Square]bool{}
+	for _, name := range diff.Unsure {
+		if sq, err := ParseSquare(name); err == nil {
+			unsure[sq] = true
+		}
+	}
+
+	pos := game.Position()
+	var fits, near []chess.Move
+	for _, m := range game.ValidMoves() {
+		moved := moveColors(pos, &m)
+		if diffMatches(observed, moved, unsure) {
+			fits = append(fits, m)
+			continue
+		}
+		for sq := range moved {
+			if _, ok := observed[sq]; ok {
+				near = append(near, m)
+				break
+			}
+		}
+	}
+
+	// the promotions to each piece look the same
+	if len(fits) > 1 && fits[0].Promo() != chess.NoPieceType {
+		for _, m := range fits {
+			if m.Promo() == chess.Queen && m.S1() == fits[0].S1() && m.S2() == fits[0].S2() {
+				fits = []chess.Move{m}
+				break
+			}
+		}
+	}
+
+	switch len(fits) {
+	case 1:
+		return fits[0], nil
+	case 0:
+		if len(near) == 0 {
+			return chess.Move{}, fmt.Errorf("no legal move %s, and none touch those squares", diffSummary(diff))
+		}
+		return chess.Move{}, fmt.Errorf("no legal move %s, the nearest are %s", diffSummary(diff), moveList(near))
+	}
+	return chess.Move{}, fmt.Errorf("more than one legal move %s: %s", diffSummary(diff), moveList(fits))
+}
+
+// diffSummary is what changed in d, as in "empties e2 and occupies e4".
+func diffSummary(d BoardDiff) string {
+	parts := []string{}
+	for _, l := range []struct {
+		verb string
+		list []SquareChange
+	}{{"empties", d.Emptied}, {"occupies", d.Occupied}, {"recolors", d.Recolored}} {
+		if len(l.list) > 0 {
+			parts = append(parts, l.verb+" "+strings.Join(changedNames(l.list), " "))
+		}
+	}
+	return strings.Join(parts, " and ")
+}
+
+func changedNames(list []SquareChange) []string {
+	names := make([]string, 0, len(list))
+	for _, c := range list {
+		names = append(names, c.After.Name)
+	}
+	return names
+}
+
+// moveList is moves in UCI, sorted, like "e2e3, e2e4".
+func moveList(moves []chess.Move) string {
+	names := make([]string, 0, len(moves))
+	for _, m := range moves {
+		names = append(names, m.String())
+	}
+	slices.Sort(names)
+	return strings.Join(slices.Compact(names), ", ")
+}
+
+// positionBoard is the board of pos as a Board64, every square surely what pos has on it.
+func positionBoard(pos *chess.Position) Board64 {
+	var b Board64
+	board := pos.Board()
+	for i := range b {
+		sq := SquareOf(chess.Square(i))
+		b[i] = newSquare(sq.File, sq.Rank, int(board.Piece(chess.Square(i)).Color()))
+		b[i].Confidence = 1
+	}
+	return b
+}
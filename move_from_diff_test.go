@@ -0,0 +1,83 @@
+package viamchess
+
+import (
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/test"
+)
+
+// moveDiff is the game at fen and the diff of its board to the one after uci is played.
+func moveDiff(t *testing.T, fen, uci string) (*chess.Game, BoardDiff) {
+	t.Helper()
+	opt, err := chess.FEN(fen)
+	test.That(t, err, test.ShouldBeNil)
+	game := chess.NewGame(opt)
+	after := chess.NewGame(opt)
+	test.That(t, after.PushNotationMove(uci, chess.UCINotation{}, nil), test.ShouldBeNil)
+	return game, DiffBoards(positionBoard(game.Position()), positionBoard(after.Position()), .5)
+}
+
+func TestMoveFromDiff(t *testing.T) {
+	for _, tc := range []struct{ name, fen, uci string }{
+		{"move", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4"},
+		{"knight", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1", "g8f6"},
+		{"capture", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "e4d5"},
+		{"short castle", "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1", "e1g1"},
+		{"long castle", "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R b KQkq - 0 1", "e8c8"},
+		{"en passant", "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3", "e5d6"},
+		{"promotion", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8q"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			game, d := moveDiff(t, tc.fen, tc.uci)
+			m, err := MoveFromDiff(d, game)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, m.String(), test.ShouldEqual, tc.uci)
+		})
+	}
+}
+
+func TestMoveFromDiffUnsure(t *testing.T) {
+	game, d := moveDiff(t, "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1", "e1g1")
+	// not sure of where the rook went, still only castling fits
+	test.That(t, changedNames(d.Occupied), test.ShouldResemble, []string{"f1", "g1"})
+	d.Occupied = d.Occupied[1:]
+	d.Unsure = []string{"f1"}
+	m, err := MoveFromDiff(d, game)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, m.String(), test.ShouldEqual, "e1g1")
+}
+
+func TestMoveFromDiffErrors(t *testing.T) {
+	start := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	opt, err := chess.FEN(start)
+	test.That(t, err, test.ShouldBeNil)
+	game := chess.NewGame(opt)
+
+	_, err = MoveFromDiff(BoardDiff{}, game)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "hasn't changed")
+
+	// two pawns bumped forward at once isn't a move, the ones of either are offered
+	bumped := boardOf(t, "rnbqkbnr/pppppppp/8/8/4P3/3P4/PPP2PPP/RNBQKBNR b KQkq - 0 1")
+	_, err = MoveFromDiff(DiffBoards(positionBoard(game.Position()), bumped, .5), game)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "empties d2 e2 and occupies d3 e4")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "d2d3, d2d4, e2e3, e2e4")
+
+	// a pawn only half seen fits either of its moves
+	e4 := boardOf(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	d := DiffBoards(positionBoard(game.Position()), e4, .5)
+	d.Occupied = nil
+	d.Unsure = []string{"e3", "e4"}
+	_, err = MoveFromDiff(d, game)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "more than one legal move empties e2: e2e3, e2e4")
+
+	// a piece appearing from nowhere touches no move
+	_, err = MoveFromDiff(DiffBoards(positionBoard(game.Position()),
+		boardOf(t, "rnbqkbnr/pppppppp/8/7P/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"), .5), game)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "none touch")
+}
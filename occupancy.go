@@ -0,0 +1,47 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+)
+
+// occupancy is how sure it is that there's a piece on sq, from 0, sure it's empty, to 1, sure
+// there's one, .5 when it can't tell: estimatePieceColor's confidence either way, and .5 when
+// there aren't more than minPiecePoints points over the square to go by. A square found from the
+// image alone goes by squaresFromImage's confidence.
+func occupancy(sq squareInfo) float64 {
+	if sq.source != sourceImage && (sq.pc == nil || sq.pc.Size() <= minPiecePoints) {
+		return .5
+	}
+	if sq.color == 0 {
+		return .5 - .5*sq.confidence
+	}
+	return .5 + .5*sq.confidence
+}
+
+// occupancyColor is green for an occupancy of 0, yellow for .5 and red for 1, in between
+// the two it's between.
+func occupancyColor(v float64) color.RGBA {
+	v = min(1, max(0, v))
+	if v < .5 {
+		return color.RGBA{uint8(255 * 2 * v), 255, 0, 255}
+	}
+	return color.RGBA{255, uint8(255 * 2 * (1 - v)), 0, 255}
+}
+
+// drawOccupancy blends each of squares on img, where bounds says it is, half and half with the
+// occupancyColor of occ's occupancy of it.
+func drawOccupancy(img *image.RGBA, squares []squareAnnotation, bounds func(squareAnnotation) image.Rectangle, occ func(squareAnnotation) float64) {
+	for _, sq := range squares {
+		c := occupancyColor(occ(sq))
+		r := bounds(sq).Intersect(img.Bounds())
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+			for x := 0; x < len(row); x += 4 {
+				row[x] = uint8((int(row[x]) + int(c.R)) / 2)
+				row[x+1] = uint8((int(row[x+1]) + int(c.G)) / 2)
+				row[x+2] = uint8((int(row[x+2]) + int(c.B)) / 2)
+			}
+		}
+	}
+}
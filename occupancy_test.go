@@ -0,0 +1,87 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestOccupancy(t *testing.T) {
+	test.That(t, occupancyColor(0), test.ShouldResemble, color.RGBA{0, 255, 0, 255})
+	test.That(t, occupancyColor(.5), test.ShouldResemble, color.RGBA{255, 255, 0, 255})
+	test.That(t, occupancyColor(1), test.ShouldResemble, color.RGBA{255, 0, 0, 255})
+	test.That(t, occupancyColor(2), test.ShouldResemble, occupancyColor(1))
+
+	pc := pointcloud.NewBasicEmpty()
+	for i := range 2 * minPiecePoints {
+		test.That(t, pc.Set(pointcloud.NewVector(float64(i), 0, 0), nil), test.ShouldBeNil)
+	}
+	test.That(t, occupancy(squareInfo{color: 0, confidence: 1, pc: pc}), test.ShouldEqual, 0)
+	test.That(t, occupancy(squareInfo{color: 2, confidence: 1, pc: pc}), test.ShouldEqual, 1)
+	test.That(t, occupancy(squareInfo{color: 1, confidence: .2, pc: pc}), test.ShouldAlmostEqual, .6)
+	// too few points to say
+	test.That(t, occupancy(squareInfo{color: 0, confidence: 1, pc: pointcloud.NewBasicEmpty()}), test.ShouldEqual, .5)
+}
+
+// patchColor is the mean color of img over r.
+func patchColor(img *image.RGBA, r image.Rectangle) [3]float64 {
+	var sum [3]float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			sum[0], sum[1], sum[2] = sum[0]+float64(c.R), sum[1]+float64(c.G), sum[2]+float64(c.B)
+		}
+	}
+	n := float64(r.Dx() * r.Dy())
+	return [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+}
+
+func TestBoardCameraOccupancy(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// only there when it's turned on
+	conf := &BoardCameraConfig{Input: "cam", Width: 400, Rotation: "180"}
+	_, _, err = newTestBoardCamera(t, conf, input, pc).Images(ctx, []string{sourceOccupancy}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	conf.Occupancy = true
+	bc := newTestBoardCamera(t, conf, input, pc)
+	images, _, err := bc.Images(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 4)
+	test.That(t, images[3].SourceName, test.ShouldEqual, sourceOccupancy)
+	test.That(t, images[3].Annotations, test.ShouldResemble, images[0].Annotations)
+
+	heat, err := images[3].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	warped, err := images[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, heat.Bounds(), test.ShouldResemble, warped.Bounds())
+
+	// board4's white has played e4, so there are pawns on d2 and f2 and nothing on e2 or e5, 50
+	// pixel squares in the turned view
+	square := func(file rune, rank int) image.Rectangle {
+		x, y := 50*int(file-'a'), 50*(8-rank)
+		return image.Rect(x, y, x+50, y+50).Inset(5)
+	}
+	for _, sq := range []image.Rectangle{square('d', 2), square('f', 2), square('e', 1)} {
+		c := patchColor(heat.(*image.RGBA), sq)
+		test.That(t, c[0], test.ShouldBeGreaterThan, c[1]+40)
+	}
+	for _, sq := range []image.Rectangle{square('e', 2), square('e', 5)} {
+		c := patchColor(heat.(*image.RGBA), sq)
+		test.That(t, c[1], test.ShouldBeGreaterThan, c[0]+40)
+	}
+	// half the photo is still there
+	photo := patchColor(warped.(*image.RGBA), square('e', 5))
+	test.That(t, patchColor(heat.(*image.RGBA), square('e', 5))[2], test.ShouldAlmostEqual, photo[2]/2, 1)
+}
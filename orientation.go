@@ -0,0 +1,121 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// BoardRotation describes where a1 sits in the image, as a number of 90 degree
+// clockwise turns relative to the robot's native mounting (h1 at the top-left
+// corner, white along the top edge).
+type BoardRotation int
+
+const (
+	Rotation0   BoardRotation = 0 // white on top, h1 top-left
+	Rotation90  BoardRotation = 1 // white on the right, h8 top-left
+	Rotation180 BoardRotation = 2 // white on the bottom, a8 top-left
+	Rotation270 BoardRotation = 3 // white on the left, a1 top-left
+)
+
+func (r BoardRotation) String() string {
+	return fmt.Sprintf("%d", int(r)*90)
+}
+
+// ParseBoardRotation accepts "0", "90", "180" or "270".
+func ParseBoardRotation(s string) (BoardRotation, error) {
+	switch s {
+	case "", "0":
+		return Rotation0, nil
+	case "90":
+		return Rotation90, nil
+	case "180":
+		return Rotation180, nil
+	case "270":
+		return Rotation270, nil
+	}
+	return Rotation0, fmt.Errorf("invalid rotation (%s), needs to be 0, 90, 180 or 270", s)
+}
+
+// gridPosition returns the column (left to right) and row (top to bottom) in the
+// image grid of an n x n board for a square.
+func gridPosition(file rune, rank int, rot BoardRotation, n int) (int, int) {
+	f := int(file - 'a') // 0..n-1
+	r := rank - 1        // 0..n-1
+	last := n - 1
+	switch rot {
+	case Rotation90:
+		return last - r, last - f
+	case Rotation180:
+		return f, last - r
+	case Rotation270:
+		return r, f
+	default:
+		return last - f, r
+	}
+}
+
+// DetectBoardOrientation figures out which side of the board white is on by
+// comparing the brightness of the two outer ranks/files along each edge of the
+// board. This only works when the pieces are mostly on their home ranks
+// (i.e. at or near the start of a game).
+func DetectBoardOrientation(img image.Image, corners []image.Point) (BoardRotation, error) {
+	if len(corners) != 4 {
+		return Rotation0, fmt.Errorf("need 4 corners, got %d", len(corners))
+	}
+
+	// band brightness along each edge, two squares deep
+	top := quadBandBrightness(img, corners, 0, 1, 0, .25)
+	bottom := quadBandBrightness(img, corners, 0, 1, .75, 1)
+	left := quadBandBrightness(img, corners, 0, .25, 0, 1)
+	right := quadBandBrightness(img, corners, .75, 1, 0, 1)
+
+	if math.Abs(top-bottom) >= math.Abs(left-right) {
+		if top > bottom {
+			return Rotation0, nil
+		}
+		return Rotation180, nil
+	}
+
+	if right > left {
+		return Rotation90, nil
+	}
+	return Rotation270, nil
+}
+
+// quadBandBrightness averages the gray value over the part of the board quad
+// between the fractional coordinates [u0,u1] x [v0,v1].
+func quadBandBrightness(img image.Image, corners []image.Point, u0, u1, v0, v1 float64) float64 {
+	const steps = 64
+	bounds := img.Bounds()
+
+	total := 0.0
+	count := 0
+	for i := range steps {
+		u := u0 + (u1-u0)*(float64(i)+.5)/steps
+		for j := range steps {
+			v := v0 + (v1-v0)*(float64(j)+.5)/steps
+			x, y := quadPoint(corners, u, v)
+			p := image.Point{bounds.Min.X + int(x), bounds.Min.Y + int(y)}
+			if !p.In(bounds) {
+				continue
+			}
+			r, g, b, _ := img.At(p.X, p.Y).RGBA()
+			total += float64(r>>8+g>>8+b>>8) / 3
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// quadPoint bilinearly interpolates inside the TL, TR, BR, BL quad.
+func quadPoint(corners []image.Point, u, v float64) (float64, float64) {
+	topX := float64(corners[0].X) + u*float64(corners[1].X-corners[0].X)
+	topY := float64(corners[0].Y) + u*float64(corners[1].Y-corners[0].Y)
+	botX := float64(corners[3].X) + u*float64(corners[2].X-corners[3].X)
+	botY := float64(corners[3].Y) + u*float64(corners[2].Y-corners[3].Y)
+	return topX + v*(botX-topX), topY + v*(botY-topY)
+}
@@ -0,0 +1,80 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestGridPosition(t *testing.T) {
+	col, row := gridPosition('h', 1, Rotation0, 8)
+	test.That(t, col, test.ShouldEqual, 0)
+	test.That(t, row, test.ShouldEqual, 0)
+
+	col, row = gridPosition('a', 1, Rotation180, 8)
+	test.That(t, col, test.ShouldEqual, 0)
+	test.That(t, row, test.ShouldEqual, 7)
+
+	col, row = gridPosition('a', 1, Rotation90, 8)
+	test.That(t, col, test.ShouldEqual, 7)
+	test.That(t, row, test.ShouldEqual, 7)
+
+	col, row = gridPosition('a', 1, Rotation270, 8)
+	test.That(t, col, test.ShouldEqual, 0)
+	test.That(t, row, test.ShouldEqual, 0)
+
+	// every rotation is a permutation of the grid
+	for _, rot := range []BoardRotation{Rotation0, Rotation90, Rotation180, Rotation270} {
+		seen := map[image.Point]bool{}
+		for rank := 1; rank <= 8; rank++ {
+			for file := 'a'; file <= 'h'; file++ {
+				col, row := gridPosition(file, rank, rot, 8)
+				seen[image.Point{col, row}] = true
+			}
+		}
+		test.That(t, len(seen), test.ShouldEqual, 64)
+	}
+}
+
+func TestParseBoardRotation(t *testing.T) {
+	r, err := ParseBoardRotation("180")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, r, test.ShouldEqual, Rotation180)
+
+	r, err = ParseBoardRotation("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, r, test.ShouldEqual, Rotation0)
+
+	_, err = ParseBoardRotation("45")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func testOrientation(t *testing.T, fn string, expected BoardRotation, e2 image.Rectangle) {
+	input, err := rimage.ReadImageFromFile(fn)
+	test.That(t, err, test.ShouldBeNil)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+
+	rot, err := DetectBoardOrientation(input, corners)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rot, test.ShouldEqual, expected)
+
+	col, row := gridPosition('e', 2, rot, 8)
+	bounds := computeSquareBounds(corners, col, row, 8)
+	center := image.Point{(bounds.Min.X + bounds.Max.X) / 2, (bounds.Min.Y + bounds.Max.Y) / 2}
+	t.Logf("e2 center: %v", center)
+	test.That(t, center.In(e2), test.ShouldBeTrue)
+}
+
+func TestDetectBoardOrientation(t *testing.T) {
+	// native mounting, white on top
+	testOrientation(t, "data/board5.jpg", Rotation0, image.Rect(550, 100, 640, 190))
+	testOrientation(t, "data/board13.jpg", Rotation0, image.Rect(560, 105, 650, 190))
+
+	// rotated 180, white on the bottom
+	testOrientation(t, "data/board1.jpg", Rotation180, image.Rect(650, 480, 740, 570))
+	testOrientation(t, "data/board4.jpg", Rotation180, image.Rect(620, 515, 710, 605))
+}
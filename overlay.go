@@ -0,0 +1,101 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"time"
+)
+
+const overlayFooterHeight = 36
+
+// OverlayOptions are how RenderCornerOverlay draws.
+type OverlayOptions struct {
+	// Stamp adds a footer strip under the image with the time, the Params fingerprint,
+	// each corner, and the checkerboard quality score.
+	Stamp bool
+	// Time is the time stamped, zero means now.
+	Time time.Time
+	// Params are the options the corners were found with.
+	Params BoardFinderOptions
+}
+
+// RenderCornerOverlay is img with the corners in res marked in red.
+func RenderCornerOverlay(img image.Image, res FindBoardResult, opts OverlayOptions) *image.RGBA {
+	bounds := img.Bounds()
+	rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	if opts.Stamp {
+		rect.Max.Y += overlayFooterHeight
+	}
+
+	out := image.NewRGBA(rect)
+	draw.Draw(out, rect, img, bounds.Min, draw.Src)
+
+	red := color.RGBA{255, 0, 0, 255}
+	for _, c := range res.Corners {
+		drawCircle(out, c.X, c.Y, 10, red)
+		drawCross(out, c.X, c.Y, 15, red)
+	}
+
+	if opts.Stamp {
+		stampFooter(out, img, res, opts)
+	}
+	return out
+}
+
+// stampFooter fills the strip under img with the stamp.
+func stampFooter(out *image.RGBA, img image.Image, res FindBoardResult, opts OverlayOptions) {
+	h := img.Bounds().Dy()
+	footer := image.Rect(0, h, out.Bounds().Dx(), h+overlayFooterHeight)
+	draw.Draw(out, footer, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	when := opts.Time
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	quality := 0.0
+	status := "found"
+	if res.Found {
+		quality = checkerScore(img, res.SubPixelCorners, opts.Params.gridSize())
+	} else {
+		status = "not found: " + res.Reason
+	}
+
+	white := color.RGBA{255, 255, 255, 255}
+	drawString(out, 4, h+14, fmt.Sprintf("%s  params %s  quality %.2f  %s",
+		when.UTC().Format(time.RFC3339), opts.Params.Fingerprint(), quality, status), white)
+
+	line := ""
+	for i, c := range res.Corners {
+		line += fmt.Sprintf("%s (%d,%d)  ", []string{"TL", "TR", "BR", "BL"}[i], c.X, c.Y)
+	}
+	drawString(out, 4, h+30, line, white)
+}
+
+func drawCircle(img *image.RGBA, cx, cy, radius int, c color.Color) {
+	for angle := 0.0; angle < 360; angle += 1 {
+		x := cx + int(float64(radius)*math.Cos(angle*math.Pi/180))
+		y := cy + int(float64(radius)*math.Sin(angle*math.Pi/180))
+		if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+func drawCross(img *image.RGBA, cx, cy, size int, c color.Color) {
+	for d := -size; d <= size; d++ {
+		// Horizontal line
+		x := cx + d
+		if x >= 0 && x < img.Bounds().Max.X && cy >= 0 && cy < img.Bounds().Max.Y {
+			img.Set(x, cy, c)
+		}
+		// Vertical line
+		y := cy + d
+		if cx >= 0 && cx < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
+			img.Set(cx, y, c)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package viamchess
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestRenderCornerOverlayStamp(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	opts := DefaultBoardFinderOptions()
+	res := FindBoardEx(input, opts)
+	test.That(t, res.Found, test.ShouldBeTrue)
+
+	plain := RenderCornerOverlay(input, res, OverlayOptions{Params: opts})
+	test.That(t, plain.Bounds(), test.ShouldResemble, image.Rect(0, 0, 1280, 720))
+
+	stamped := RenderCornerOverlay(input, res, OverlayOptions{
+		Stamp:  true,
+		Time:   time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Params: opts,
+	})
+	test.That(t, stamped.Bounds(), test.ShouldResemble, image.Rect(0, 0, 1280, 720+overlayFooterHeight))
+
+	// everything above the footer is the same
+	above := stamped.SubImage(plain.Bounds()).(*image.RGBA)
+	for y := range 720 {
+		test.That(t, above.Pix[above.PixOffset(0, y):above.PixOffset(0, y)+1280*4], test.ShouldResemble,
+			plain.Pix[plain.PixOffset(0, y):plain.PixOffset(0, y)+1280*4])
+	}
+
+	// and there's text in the footer
+	lit := 0
+	for y := 720; y < 720+overlayFooterHeight; y++ {
+		for x := range 1280 {
+			if stamped.RGBAAt(x, y).R > 128 {
+				lit++
+			}
+		}
+	}
+	test.That(t, lit, test.ShouldBeGreaterThan, 200)
+}
+
+func TestBoardFinderOptionsFingerprint(t *testing.T) {
+	opts := DefaultBoardFinderOptions()
+	test.That(t, opts.Fingerprint(), test.ShouldEqual, DefaultBoardFinderOptions().Fingerprint())
+	test.That(t, len(opts.Fingerprint()), test.ShouldEqual, 8)
+
+	opts.EdgeThreshold++
+	test.That(t, opts.Fingerprint(), test.ShouldNotEqual, DefaultBoardFinderOptions().Fingerprint())
+}
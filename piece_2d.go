@@ -0,0 +1,185 @@
+package viamchess
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+
+	"github.com/corentings/chess/v2"
+)
+
+// Where a square's analysis came from, see squareInfo.source: the point cloud, or the image
+// alone when there wasn't one.
+const (
+	sourceDepth = "3d"
+	sourceImage = "2d"
+)
+
+const (
+	imageSquareSize = 48 // pixels per square in the top down view squaresFromImage works in
+
+	// a center pixel is an edge when its Sobel magnitude is over imageEdgeMagnitude, and a square
+	// is taken to have a piece on it when more than imageEdgeDensity of its center are edges or its
+	// center's mean color is more than imageColorDistance from the empty square's
+	imageEdgeMagnitude = 60
+	imageEdgeDensity   = .08
+	imageColorDistance = 40.0
+)
+
+var errNeedsDepth = errors.New("needs the point cloud, there's only the image")
+
+// errImageOnly is why the chess service won't move a piece, unless allow-image-only is set, on a
+// capture of squares found from the image alone.
+var errImageOnly = errors.New("the piece finder only has the image to go by, not moving pieces without a point cloud (set allow-image-only to anyway)")
+
+// imageOnly is whether squares were found from the image alone, see squaresFromImage.
+func imageOnly(squares []squareInfo) bool {
+	return len(squares) > 0 && squares[0].source == sourceImage
+}
+
+// squaresSource is where squares' analysis came from, sourceImage or sourceDepth.
+func squaresSource(squares []squareInfo) string {
+	if imageOnly(squares) {
+		return sourceImage
+	}
+	return sourceDepth
+}
+
+// needsDepth is errNeedsDepth for what when squares were found from the image alone.
+func needsDepth(what string, squares []squareInfo) error {
+	if imageOnly(squares) {
+		return fmt.Errorf("%s %w", what, errNeedsDepth)
+	}
+	return nil
+}
+
+// imageSquareStats is the mean color of the middle half (each way) of each square of an n x n
+// board in view, a top down view laid out by layout, and how much of it is edges, in
+// squareNames order.
+func imageSquareStats(view *image.RGBA, layout WarpLayout, n int) ([]color.RGBA, []float64) {
+	gray := makeGrayImage(view)
+	defer gray.release()
+	sobel := sobelEdgeDetection(gray)
+	defer sobel.release()
+
+	means := make([]color.RGBA, n*n)
+	edges := make([]float64, n*n)
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			idx := squareIndex(file, rank, n)
+			r := layout.Square(file, rank, n)
+			r = r.Inset(min(r.Dx(), r.Dy()) / 4).Intersect(view.Bounds())
+			var sum [3]int
+			count, edgy := 0, 0
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					c := view.RGBAAt(x, y)
+					sum[0], sum[1], sum[2] = sum[0]+int(c.R), sum[1]+int(c.G), sum[2]+int(c.B)
+					count++
+					if sobel.magnitude.At(x, y) > imageEdgeMagnitude {
+						edgy++
+					}
+				}
+			}
+			if count > 0 {
+				means[idx] = color.RGBA{uint8(sum[0] / count), uint8(sum[1] / count), uint8(sum[2] / count), 255}
+				edges[idx] = float64(edgy) / float64(count)
+			}
+		}
+	}
+	return means, edges
+}
+
+// emptySquareColors is what each square of an n x n board looks like empty: baseline, from
+// calibrate_colors, when there is one, otherwise for the light and dark squares each the mean of
+// the quarter of them with the fewest edges, which on a board set up or part way through a game
+// have nothing on them.
+func emptySquareColors(means []color.RGBA, edges []float64, baseline []color.RGBA, n int) []color.RGBA {
+	if len(baseline) == n*n {
+		return baseline
+	}
+	var parity [2][]int
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			idx := squareIndex(file, rank, n)
+			p := (int(file-'a') + rank) % 2
+			parity[p] = append(parity[p], idx)
+		}
+	}
+	var empty [2]color.RGBA
+	for p, squares := range parity {
+		slices.SortStableFunc(squares, func(a, b int) int { return cmp.Compare(edges[a], edges[b]) })
+		quiet := squares[:max(1, len(squares)/4)]
+		var sum [3]int
+		for _, idx := range quiet {
+			sum[0], sum[1], sum[2] = sum[0]+int(means[idx].R), sum[1]+int(means[idx].G), sum[2]+int(means[idx].B)
+		}
+		k := len(quiet)
+		empty[p] = color.RGBA{uint8(sum[0] / k), uint8(sum[1] / k), uint8(sum[2] / k), 255}
+	}
+	res := make([]color.RGBA, n*n)
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			res[squareIndex(file, rank, n)] = empty[(int(file-'a')+rank)%2]
+		}
+	}
+	return res
+}
+
+// midLuminance is half way between how bright the light and dark squares are empty, the mean
+// luminance of empty, which has as many of each.
+func midLuminance(empty []color.RGBA) float64 {
+	total := 0.0
+	for _, c := range empty {
+		total += luminance(c.R, c.G, c.B)
+	}
+	return total / float64(max(1, len(empty)))
+}
+
+// squaresFromImage is squaresOnBoard without a point cloud to go by: each square is looked at in
+// the top down view of board in srcImg, and has a piece on it when its center's color is far
+// off the empty square's or it has a lot more edges than a bare square does. The squares have
+// no points, height or type, and their source is sourceImage. rects are the squares' bounds in
+// srcImg, in squareNames order.
+func squaresFromImage(dst []squareInfo, board BoardCorners, rot BoardRotation, rects []image.Rectangle, srcImg image.Image, conf *PieceFinderConfig, n int) ([]squareInfo, error) {
+	view, layout, err := WarpBoard(srcImg, board.Slice(), WarpOptions{Width: n * imageSquareSize, Rotation: rot.String()})
+	if err != nil {
+		return nil, err
+	}
+	means, edges := imageSquareStats(view, layout, n)
+	empty := emptySquareColors(means, edges, conf.colorBaseline, n)
+	mid := midLuminance(empty)
+	names := gridSquareNames(n)
+
+	squares := dst[:0]
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			idx := squareIndex(file, rank, n)
+			// how far over the line it is, 1 being on it
+			score := max(colorDistance(means[idx], empty[idx])/imageColorDistance, edges[idx]/imageEdgeDensity)
+			pieceColor := 0
+			if score > 1 {
+				// a white piece is brighter than either square, a black one darker
+				pieceColor = 2
+				if luminance(means[idx].R, means[idx].G, means[idx].B) >= mid {
+					pieceColor = 1
+				}
+			}
+			squares = append(squares, squareInfo{
+				rank:           rank,
+				file:           file,
+				name:           names[idx],
+				originalBounds: rects[idx],
+				color:          pieceColor,
+				confidence:     min(1, math.Abs(score-1)),
+				pieceType:      chess.NoPieceType,
+				source:         sourceImage,
+			})
+		}
+	}
+	return squares, nil
+}
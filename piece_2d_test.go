@@ -0,0 +1,95 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestSquaresFromImageBoard1(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+
+	// there's no point cloud of board1, white has played e4
+	squares, err := findBoardAndPieces(input, nil, camera.Properties{}, &PieceFinderConfig{Rotation: "180", CornerSource: cornerSourceImage})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(squares), test.ShouldEqual, 64)
+	white, black := 0, 0
+	for _, sq := range squares {
+		test.That(t, sq.source, test.ShouldEqual, sourceImage)
+		test.That(t, sq.pc, test.ShouldBeNil)
+		switch {
+		case sq.name == "e2", sq.rank >= 3 && sq.rank <= 6 && sq.name != "e4":
+			test.That(t, sq.color, test.ShouldEqual, 0)
+		default:
+			test.That(t, sq.color, test.ShouldNotEqual, 0)
+			test.That(t, occupancy(sq), test.ShouldBeGreaterThan, .5)
+		}
+		if sq.rank == 1 && sq.color == 1 {
+			white++
+		}
+		if sq.rank == 8 && sq.color == 2 {
+			black++
+		}
+	}
+	test.That(t, white, test.ShouldEqual, 8)
+	test.That(t, black, test.ShouldEqual, 8)
+	test.That(t, needsDepth("density", squares), test.ShouldWrap, errNeedsDepth)
+}
+
+func TestCaptureWithoutPointCloud(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	var pc pointcloud.PointCloud
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}, &frame, &pc)
+	pf.props = camera.Properties{}
+	pf.input.(*inject.Camera).NextPointCloudFunc = func(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+		return nil, errors.New("no depth")
+	}
+
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["source"], test.ShouldEqual, sourceImage)
+	test.That(t, len(all.Objects), test.ShouldEqual, 64)
+	board, _ := captureBoard(all)
+	test.That(t, board.At(chess.E2).Occupied, test.ShouldBeFalse)
+	test.That(t, board.At(chess.E4).PieceColor, test.ShouldEqual, "white")
+
+	// no pieces to make of points that aren't there
+	objects, err := pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldEqual, 0)
+
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"density": "points"})
+	test.That(t, err, test.ShouldWrap, errNeedsDepth)
+}
+
+func TestMoveRefusesImageOnly(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+	f.occupied = map[string]int{"e2": 1}
+	f.imageOnly = true
+	move := map[string]interface{}{"move": map[string]interface{}{"from": "e2", "to": "e4", "n": 1}}
+
+	_, err := s.DoCommand(ctx, move)
+	test.That(t, err, test.ShouldWrap, errImageOnly)
+	test.That(t, f.indexOf("grab"), test.ShouldEqual, -1)
+
+	// unless it's been told it's fine
+	s.conf.AllowImageOnly = true
+	_, err = s.DoCommand(ctx, move)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, f.indexOf("grab"), test.ShouldNotEqual, -1)
+}
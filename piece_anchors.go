@@ -0,0 +1,230 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"github.com/lucasb-eyer/go-colorful"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// defaultColorRejectBand is how much nearer one anchor than the other a piece's color has to be,
+// as a part of how far apart the anchors are, for it to be that anchor's color.
+const defaultColorRejectBand = .2
+
+// minAnchorDistance is how far apart (CIE76 delta E) learn_piece_colors has to find the white and
+// black pieces for them to be told apart by color.
+const minAnchorDistance = 10.0
+
+// labColor is a color in CIE L*a*b*, L* 0 to 100.
+type labColor [3]float64
+
+// toLab is the color of d in CIE L*a*b*.
+func toLab(d pointcloud.Data) labColor {
+	r, g, b := d.RGB255()
+	l, a, bb := colorful.Color{R: float64(r) / 255, G: float64(g) / 255, B: float64(b) / 255}.Lab()
+	return labColor{l * 100, a * 100, bb * 100}
+}
+
+// distance is how far apart c and o look, CIE76 delta E.
+func (c labColor) distance(o labColor) float64 {
+	return math.Sqrt((c[0]-o[0])*(c[0]-o[0]) + (c[1]-o[1])*(c[1]-o[1]) + (c[2]-o[2])*(c[2]-o[2]))
+}
+
+func validateColorRejectBand(band float64) error {
+	if band < 0 || band >= 1 {
+		return fmt.Errorf("color-reject-band has to be from 0 up to 1, got %v", band)
+	}
+	return nil
+}
+
+func (cfg *PieceFinderConfig) colorRejectBand() float64 {
+	if cfg.ColorRejectBand == 0 {
+		return defaultColorRejectBand
+	}
+	return cfg.ColorRejectBand
+}
+
+// pieceAnchors is what learn_piece_colors found the white and black pieces' colors to be.
+type pieceAnchors struct {
+	White labColor  `json:"white"`
+	Black labColor  `json:"black"`
+	At    time.Time `json:"at"`
+}
+
+// pieceAnchorsFile is where a piece finder called name keeps its pieceAnchors, next to its
+// boardZeroFile.
+func pieceAnchorsFile(name string) string {
+	return os.Getenv("VIAM_MODULE_DATA") + name + "-piece-colors.json"
+}
+
+// readPieceAnchors is the pieceAnchors saved in fn, nil if there aren't any.
+func readPieceAnchors(fn string) (*pieceAnchors, error) {
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	a := &pieceAnchors{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("bad piece colors in %s: %w", fn, err)
+	}
+	if a.White.distance(a.Black) < minAnchorDistance {
+		return nil, fmt.Errorf("bad piece colors in %s: white and black are the same", fn)
+	}
+	return a, nil
+}
+
+func (a *pieceAnchors) save(fn string) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, 0666)
+}
+
+// classify is which of the anchors c is (1 white, 2 black), how sure of that, and whether it's
+// ambiguous: nearer one than the other by less than reject of how far apart they are. An
+// ambiguous piece is still given the nearer one's color, but under .5 sure of it.
+func (a *pieceAnchors) classify(c labColor, reject float64) (int, float64, bool) {
+	toWhite, toBlack := c.distance(a.White), c.distance(a.Black)
+	color := 1
+	if toBlack < toWhite {
+		color = 2
+	}
+	// 0 halfway between them, 1 on or past one of them
+	margin := min(1, math.Abs(toWhite-toBlack)/a.White.distance(a.Black))
+	if margin < reject {
+		return color, .5 * margin / reject, true
+	}
+	return color, .5 + .5*(margin-reject)/(1-reject), false
+}
+
+// pieceLab is the color of what sticks up off the square pc is the points over, the median of
+// each of L*, a* and b* so the highlights on a glossy piece don't count much, and how many points
+// it's from. height and conf are as for estimatePieceColor.
+func pieceLab(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig) (labColor, int) {
+	_, top := colorBands(pc, height, conf)
+	var channels [3][]float64
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if d == nil || !d.HasColor() || height(p) <= top {
+			return true
+		}
+		c := toLab(d)
+		for i := range channels {
+			channels[i] = append(channels[i], c[i])
+		}
+		return true
+	})
+	var c labColor
+	n := len(channels[0])
+	if n == 0 {
+		return c, 0
+	}
+	for i := range channels {
+		slices.Sort(channels[i])
+		c[i] = channels[i][n/2]
+	}
+	return c, n
+}
+
+// classifyPieceColor is estimatePieceColor of pc, then by conf's learned anchors when there are
+// some, with the piece's color and how many points that's from (see pieceLab), and whether it's
+// ambiguous (see pieceAnchors.classify).
+func classifyPieceColor(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig, minPoints int) (int, float64, labColor, int, bool) {
+	pieceColor, confidence := estimatePieceColor(pc, height, conf, minPoints)
+	if pieceColor == 0 {
+		return 0, confidence, labColor{}, 0, false
+	}
+	lab, labPoints := pieceLab(pc, height, conf)
+	ambiguous := false
+	if conf.anchors != nil && labPoints > 0 {
+		pieceColor, confidence, ambiguous = conf.anchors.classify(lab, conf.colorRejectBand())
+	}
+	return pieceColor, confidence, lab, labPoints, ambiguous
+}
+
+// learnPieceAnchors is the pieceAnchors of squares, the white pieces' color the mean of those
+// on rank 1 and the black pieces' of those on rank 8, which all have to be there.
+func learnPieceAnchors(squares []squareInfo) (*pieceAnchors, error) {
+	if len(squares) != 64 {
+		return nil, fmt.Errorf("learn_piece_colors needs a chess board, not %d squares", len(squares))
+	}
+	var sums [2]labColor
+	var counts [2]int
+	missing := []string{}
+	for _, sq := range squares {
+		if sq.rank != 1 && sq.rank != 8 {
+			continue
+		}
+		if sq.color == 0 || sq.labPoints == 0 {
+			missing = append(missing, sq.name)
+			continue
+		}
+		side := 0
+		if sq.rank == 8 {
+			side = 1
+		}
+		for i := range sq.lab {
+			sums[side][i] += sq.lab[i]
+		}
+		counts[side]++
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("learn_piece_colors needs the back ranks set up, nothing on %s", strings.Join(missing, " "))
+	}
+	a := &pieceAnchors{At: time.Now()}
+	for i := range sums[0] {
+		a.White[i] = sums[0][i] / float64(counts[0])
+		a.Black[i] = sums[1][i] / float64(counts[1])
+	}
+	if d := a.White.distance(a.Black); d < minAnchorDistance {
+		return nil, fmt.Errorf("the white and black pieces are too alike to tell apart by color, %.1f apart", d)
+	}
+	return a, nil
+}
+
+// learnPieceColors is {"learn_piece_colors": true}, learning the colors of the white and black
+// pieces from the back ranks of the current frame, which have to be set up as they start, and
+// telling pieces' colors by them from then on. They're kept next to the board calibration.
+func (bc *PieceFinder) learnPieceColors(ctx context.Context) (map[string]interface{}, error) {
+	_, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("learn_piece_colors", squares); err != nil {
+		return nil, err
+	}
+	a, err := learnPieceAnchors(squares)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.save(bc.anchorsFile); err != nil {
+		return nil, err
+	}
+
+	bc.captureLock.Lock()
+	bc.anchors = a
+	// the last analysis was colored without them
+	bc.last = nil
+	bc.captureLock.Unlock()
+
+	return map[string]interface{}{
+		"learned": true,
+		"white":   a.White[:],
+		"black":   a.Black[:],
+		"file":    bc.anchorsFile,
+	}, nil
+}
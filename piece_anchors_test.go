@@ -0,0 +1,155 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+var (
+	creamPiece = color.NRGBA{230, 220, 190, 255}
+	blackPiece = color.NRGBA{40, 40, 40, 255}
+	grayPiece  = color.NRGBA{125, 125, 125, 255}
+)
+
+// coloredBoard is a flat gray board 600mm off at tiltedBoardCorners, with a 40mm piece of each
+// color in pieces on the square it's keyed by, with white on top.
+func coloredBoard(t *testing.T, pieces map[string]color.NRGBA) pointcloud.PointCloud {
+	t.Helper()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	pc := pointcloud.NewBasicEmpty()
+	board := pointcloud.NewColoredData(color.NRGBA{90, 90, 90, 255})
+	for v := 140; v < 640; v += 2 {
+		for u := 390; u < 890; u += 2 {
+			x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+			z := 600.0
+			d := board
+			col, row := (u-400)/60, (v-150)/60
+			inX, inY := (u-400)%60, (v-150)%60
+			if u >= 400 && v >= 150 && col < 8 && row < 8 && inX >= 15 && inX < 45 && inY >= 15 && inY < 45 {
+				if c, ok := pieces[SquareName(rune('h'-col), row+1)]; ok {
+					z -= 40
+					d = pointcloud.NewColoredData(c)
+				}
+			}
+			test.That(t, pc.Set(r3.Vector{X: x * z, Y: y * z, Z: z}, d), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+// backRanks is cream pieces on rank 1 and black ones on rank 8, and the rest of pieces.
+func backRanks(pieces map[string]color.NRGBA) map[string]color.NRGBA {
+	all := map[string]color.NRGBA{}
+	for file := 'a'; file <= 'h'; file++ {
+		all[SquareName(file, 1)] = creamPiece
+		all[SquareName(file, 8)] = blackPiece
+	}
+	for name, c := range pieces {
+		all[name] = c
+	}
+	return all
+}
+
+func lastSquare(t *testing.T, pf *PieceFinder, name string) squareInfo {
+	t.Helper()
+	for _, sq := range pf.last.squares {
+		if sq.name == name {
+			return sq
+		}
+	}
+	t.Fatalf("no %s in the last capture", name)
+	return squareInfo{}
+}
+
+func TestLearnPieceColors(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := coloredBoard(t, backRanks(map[string]color.NRGBA{"e4": grayPiece}))
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+	pf.anchorsFile = filepath.Join(t.TempDir(), "pf-piece-colors.json")
+
+	// by brightness alone a mid-gray piece on a darker square is white
+	_, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	e4 := lastSquare(t, pf, "e4")
+	test.That(t, e4.color, test.ShouldEqual, 1)
+	test.That(t, e4.ambiguous, test.ShouldBeFalse)
+
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"learn_piece_colors": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret["learned"], test.ShouldBeTrue)
+	test.That(t, ret["file"], test.ShouldEqual, pf.anchorsFile)
+	white, black := ret["white"].([]float64), ret["black"].([]float64)
+	test.That(t, white[0], test.ShouldBeGreaterThan, 80)
+	test.That(t, black[0], test.ShouldBeLessThan, 25)
+
+	// they're kept, and read back by the next one
+	saved, err := readPieceAnchors(pf.anchorsFile)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, saved.White, test.ShouldResemble, pf.anchors.White)
+	test.That(t, saved.Black, test.ShouldResemble, pf.anchors.Black)
+
+	// now it's as near one as the other
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	e4 = lastSquare(t, pf, "e4")
+	test.That(t, e4.ambiguous, test.ShouldBeTrue)
+	test.That(t, e4.confidence, test.ShouldBeLessThan, .5)
+	test.That(t, e4.export().Ambiguous, test.ShouldBeTrue)
+	test.That(t, lastSquare(t, pf, "a1").color, test.ShouldEqual, 1)
+	test.That(t, lastSquare(t, pf, "a8").color, test.ShouldEqual, 2)
+
+	// and the real pieces are told apart surely
+	pc = coloredBoard(t, backRanks(map[string]color.NRGBA{"e4": creamPiece, "d5": blackPiece}))
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	for name, c := range map[string]int{"e4": 1, "d5": 2} {
+		sq := lastSquare(t, pf, name)
+		test.That(t, sq.color, test.ShouldEqual, c)
+		test.That(t, sq.ambiguous, test.ShouldBeFalse)
+		test.That(t, sq.confidence, test.ShouldBeGreaterThan, .5)
+	}
+
+	// without the back ranks there's nothing to learn from
+	pc = coloredBoard(t, map[string]color.NRGBA{"e4": creamPiece})
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"learn_piece_colors": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "back ranks")
+}
+
+func TestPieceAnchorsClassify(t *testing.T) {
+	a := &pieceAnchors{White: labColor{90, 0, 10}, Black: labColor{15, 0, 0}}
+
+	c, confidence, ambiguous := a.classify(labColor{85, 0, 8}, defaultColorRejectBand)
+	test.That(t, c, test.ShouldEqual, 1)
+	test.That(t, ambiguous, test.ShouldBeFalse)
+	test.That(t, confidence, test.ShouldBeGreaterThan, .8)
+
+	c, _, ambiguous = a.classify(labColor{10, 0, 0}, defaultColorRejectBand)
+	test.That(t, c, test.ShouldEqual, 2)
+	test.That(t, ambiguous, test.ShouldBeFalse)
+
+	// halfway between them
+	_, confidence, ambiguous = a.classify(labColor{53, 0, 5}, defaultColorRejectBand)
+	test.That(t, ambiguous, test.ShouldBeTrue)
+	test.That(t, confidence, test.ShouldBeLessThan, .1)
+
+	// no reject band, nothing's ambiguous
+	_, _, ambiguous = a.classify(labColor{53, 0, 5}, 0)
+	test.That(t, ambiguous, test.ShouldBeFalse)
+
+	test.That(t, validateColorRejectBand(1), test.ShouldNotBeNil)
+	test.That(t, validateColorRejectBand(-.1), test.ShouldNotBeNil)
+}
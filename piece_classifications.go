@@ -0,0 +1,60 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"slices"
+
+	"go.viam.com/rdk/vision/classification"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// pieceClassifications is a classification for each of squares, named <square>:<empty, white or
+// black> and scored by how sure estimatePieceColor is of it, the least sure first since those are
+// the ones worth a look, and only the first n of them when n is more than 0.
+func pieceClassifications(squares []squareInfo, n int) classification.Classifications {
+	sorted := slices.Clone(squares)
+	slices.SortStableFunc(sorted, func(a, b squareInfo) int {
+		switch {
+		case a.confidence < b.confidence:
+			return -1
+		case a.confidence > b.confidence:
+			return 1
+		}
+		return 0
+	})
+	if n > 0 && n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	classifications := classification.Classifications{}
+	for _, sq := range sorted {
+		label := fmt.Sprintf("%s:%s", sq.name, pieceColorNames[sq.color])
+		classifications = append(classifications, classification.NewClassification(sq.confidence, label))
+	}
+	return classifications
+}
+
+// ClassificationsFromCamera is pieceClassifications of a capture, see CaptureAllFromCamera.
+func (bc *PieceFinder) ClassificationsFromCamera(ctx context.Context, cameraName string, n int, extra map[string]interface{}) (classification.Classifications, error) {
+	var classifications classification.Classifications
+	_, err := bc.captureWith(ctx, viscapture.CaptureOptions{ReturnClassifications: true}, extra, func(_ viscapture.VisCapture, served *lastAnalysis) error {
+		if served != nil {
+			classifications = pieceClassifications(served.squares, n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return classifications, nil
+}
+
+// Classifications is pieceClassifications of img, a frame of the input, see frameSquares.
+func (bc *PieceFinder) Classifications(ctx context.Context, img image.Image, n int, extra map[string]interface{}) (classification.Classifications, error) {
+	squares, err := bc.frameSquares(ctx, img, extra)
+	if err != nil {
+		return nil, err
+	}
+	return pieceClassifications(squares, n), nil
+}
@@ -0,0 +1,83 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestClassificationsBoard13(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "0", CornerSource: cornerSourceImage}, &frame, &pc)
+
+	all, err := pf.ClassificationsFromCamera(ctx, "", 0, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(all), test.ShouldEqual, 64)
+	states := map[string]string{}
+	for i, c := range all {
+		name, state, ok := strings.Cut(c.Label(), ":")
+		test.That(t, ok, test.ShouldBeTrue)
+		states[name] = state
+		test.That(t, c.Score(), test.ShouldBeBetweenOrEqual, 0, 1)
+		if i > 0 {
+			test.That(t, c.Score(), test.ShouldBeGreaterThanOrEqualTo, all[i-1].Score())
+		}
+	}
+	test.That(t, len(states), test.ShouldEqual, 64)
+	test.That(t, states["a1"], test.ShouldEqual, "white")
+	test.That(t, states["e2"], test.ShouldEqual, "white")
+	test.That(t, states["e4"], test.ShouldEqual, "empty")
+	test.That(t, states["a7"], test.ShouldEqual, "black")
+
+	// n of them is the n least sure
+	few, err := pf.ClassificationsFromCamera(ctx, "", 5, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(few), test.ShouldEqual, 5)
+	for i, c := range few {
+		test.That(t, c.Label(), test.ShouldEqual, all[i].Label())
+	}
+
+	fromImage, err := pf.Classifications(ctx, input, 0, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(fromImage), test.ShouldEqual, 64)
+
+	props, err := pf.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.ClassificationSupported, test.ShouldBeTrue)
+}
+
+func TestClassificationsErrorFrame(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 1, ErrorFrames: "passthrough"}, &frame, &pc)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	for _, occluded := range []bool{false, true} {
+		if occluded {
+			frame = occludeHalf(input, corners)
+		}
+		all, err := pf.ClassificationsFromCamera(ctx, "", 0, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(all), test.ShouldEqual, 64)
+	}
+
+	// past max-age it's an error frame, even with the last analysis kept to fall back on
+	all, err := pf.ClassificationsFromCamera(ctx, "", 0, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all, test.ShouldBeNil)
+}
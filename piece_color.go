@@ -0,0 +1,115 @@
+package viamchess
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	defaultSurfaceBand      = 10.0
+	defaultMinPieceContrast = 30.0
+	// minPiecePoints is how many points have to stick up off a square for a piece to be on it,
+	// one as densely covered as most, see piecePointThreshold.
+	minPiecePoints = 10
+)
+
+func validatePieceColor(surfaceBand, minContrast float64) error {
+	if surfaceBand < 0 {
+		return fmt.Errorf("surface-band can't be negative, got %v", surfaceBand)
+	}
+	if minContrast < 0 || minContrast > 255 {
+		return fmt.Errorf("min-piece-contrast has to be 0 to 255, got %v", minContrast)
+	}
+	return nil
+}
+
+func (cfg *PieceFinderConfig) surfaceBand() float64 {
+	if cfg.SurfaceBand == 0 {
+		return defaultSurfaceBand
+	}
+	return cfg.SurfaceBand
+}
+
+func (cfg *PieceFinderConfig) minPieceContrast() float64 {
+	if cfg.MinPieceContrast == 0 {
+		return defaultMinPieceContrast
+	}
+	return cfg.MinPieceContrast
+}
+
+// luminance is how bright r, g, b looks, 0-255.
+func luminance(r, g, b uint8) float64 {
+	return .299*float64(r) + .587*float64(g) + .114*float64(b)
+}
+
+// colorBands are how high (see estimatePieceColor) a point of pc can be to be the square's
+// surface, and has to be to be a piece.
+func colorBands(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig) (float64, float64) {
+	floor := math.Inf(1)
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		floor = min(floor, height(p))
+		return true
+	})
+	return floor + min(conf.surfaceBand(), conf.minPieceSize()), floor + conf.minPieceSize()
+}
+
+// estimatePieceColor is what's on the square pc is the points over, 0 - blank, 1 - white,
+// 2 - black, and how sure of that it is, 0 to 1. height is how high a point is above the board
+// (see boardSurface), and heights go from the square's lowest point, the bottom of the depth
+// noise, as min-piece-size always has: its surface is the points no more than conf's
+// surfaceBand over that and a piece is what sticks up more than its minPieceSize. The piece's
+// color is told by how much brighter or darker it is than the surface, so a black piece on a
+// light square in strong light is still black; when it's within conf's minPieceContrast of the
+// surface it's taken to be the square's own color, less surely the nearer it is to the contrast.
+// There's a piece when more than minPoints points stick up, see piecePointThreshold.
+func estimatePieceColor(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig, minPoints int) (int, float64) {
+	band, top := colorBands(pc, height, conf)
+	var base, piece float64
+	baseCount, pieceCount := 0, 0
+
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if d == nil || !d.HasColor() {
+			return true
+		}
+		switch h := height(p); {
+		case h > top:
+			piece += luminance(d.RGB255())
+			pieceCount++
+		case h <= band:
+			base += luminance(d.RGB255())
+			baseCount++
+		}
+		return true
+	})
+
+	if pieceCount <= minPoints {
+		// blank - no piece detected, less surely the closer it came to one
+		return 0, 1 - float64(pieceCount)/float64(minPoints+1)
+	}
+	piece /= float64(pieceCount)
+	if baseCount == 0 {
+		// nothing to compare it with, so it's the middle of the range
+		base = 128
+	} else {
+		base /= float64(baseCount)
+	}
+
+	contrast := conf.minPieceContrast()
+	diff := piece - base
+	if math.Abs(diff) >= contrast {
+		confidence := min(1, math.Abs(diff)/(2*contrast))
+		if diff > 0 {
+			return 1, confidence // white
+		}
+		return 2, confidence // black
+	}
+	confidence := .5 * (1 - math.Abs(diff)/contrast)
+	if base > 128 {
+		return 1, confidence
+	}
+	return 2, confidence
+}
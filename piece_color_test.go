@@ -0,0 +1,102 @@
+package viamchess
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// squareCloud is a 10 x 10 square of surface 500mm from the camera with a 4 x 4 piece 60mm
+// tall on it, without the piece if its color is the zero color.
+func squareCloud(t *testing.T, surface, piece color.NRGBA) pointcloud.PointCloud {
+	t.Helper()
+	pc := pointcloud.NewBasicEmpty()
+	for x := range 10 {
+		for y := range 10 {
+			test.That(t, pc.Set(r3.Vector{X: float64(x), Y: float64(y), Z: 500}, pointcloud.NewColoredData(surface)), test.ShouldBeNil)
+			if piece != (color.NRGBA{}) && x > 2 && x < 7 && y > 2 && y < 7 {
+				test.That(t, pc.Set(r3.Vector{X: float64(x), Y: float64(y), Z: 440}, pointcloud.NewColoredData(piece)), test.ShouldBeNil)
+			}
+		}
+	}
+	return pc
+}
+
+func TestEstimatePieceColor(t *testing.T) {
+	// each square on its own, over its deepest point
+	estimate := func(pc pointcloud.PointCloud, conf *PieceFinderConfig) (int, float64) {
+		return estimatePieceColor(pc, boardSurface{}.heights(pc), conf, minPiecePoints)
+	}
+	conf := &PieceFinderConfig{}
+	white := color.NRGBA{245, 245, 240, 255}
+	dark := color.NRGBA{60, 50, 40, 255}
+
+	// a black piece under strong light is brighter than 128, but still well under the square
+	c, confidence := estimate(squareCloud(t, white, color.NRGBA{160, 160, 160, 255}), conf)
+	test.That(t, c, test.ShouldEqual, 2)
+	test.That(t, confidence, test.ShouldEqual, 1)
+
+	// and a white piece in shadow darker than 128, but well over its square
+	c, confidence = estimate(squareCloud(t, dark, color.NRGBA{110, 110, 110, 255}), conf)
+	test.That(t, c, test.ShouldEqual, 1)
+	test.That(t, confidence, test.ShouldBeGreaterThan, .5)
+
+	// one about the square's own shade is its color, not for sure
+	c, confidence = estimate(squareCloud(t, white, color.NRGBA{230, 230, 230, 255}), conf)
+	test.That(t, c, test.ShouldEqual, 1)
+	test.That(t, confidence, test.ShouldBeBetween, 0, .5)
+	c, _ = estimate(squareCloud(t, dark, dark), conf)
+	test.That(t, c, test.ShouldEqual, 2)
+
+	// a shiny empty square is still empty
+	c, confidence = estimate(squareCloud(t, color.NRGBA{255, 255, 255, 255}, color.NRGBA{}), conf)
+	test.That(t, c, test.ShouldEqual, 0)
+	test.That(t, confidence, test.ShouldEqual, 1)
+
+	// the contrast is configurable
+	c, _ = estimate(squareCloud(t, white, color.NRGBA{160, 160, 160, 255}), &PieceFinderConfig{MinPieceContrast: 100})
+	test.That(t, c, test.ShouldEqual, 1)
+}
+
+func TestEstimatePieceColorBoard13E2(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+
+	surface := fitBoardSurface(squareCloudsOf(squares))
+	test.That(t, surface.ok, test.ShouldBeTrue)
+
+	// a white pawn on a light square: about the same shade, so white but not for sure
+	e2 := squares[squareIndex('e', 2, 8)]
+	test.That(t, e2.name, test.ShouldEqual, "e2")
+	c, confidence := estimatePieceColor(e2.pc, surface.heights(e2.pc), &PieceFinderConfig{}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 1)
+	test.That(t, confidence, test.ShouldBeBetween, 0, .5)
+	test.That(t, e2.color, test.ShouldEqual, c)
+	// the board's fit to the squares less their speckle, about the one that's fit to them all
+	test.That(t, e2.confidence, test.ShouldAlmostEqual, confidence, 1e-3)
+
+	// e4 is empty
+	e4 := squares[squareIndex('e', 4, 8)]
+	c, confidence = estimatePieceColor(e4.pc, surface.heights(e4.pc), &PieceFinderConfig{}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 0)
+	test.That(t, confidence, test.ShouldEqual, 1)
+}
+
+func TestValidatePieceColor(t *testing.T) {
+	test.That(t, validatePieceColor(0, 0), test.ShouldBeNil)
+	test.That(t, validatePieceColor(5, 40), test.ShouldBeNil)
+	test.That(t, validatePieceColor(-1, 0), test.ShouldNotBeNil)
+	test.That(t, validatePieceColor(0, 300), test.ShouldNotBeNil)
+
+	_, _, err := (&PieceFinderConfig{Input: "cam", MinPieceContrast: -5}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
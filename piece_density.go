@@ -0,0 +1,63 @@
+package viamchess
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+// minPiecePointsFloor is the fewest points that can stick up off a square for a piece to be on it,
+// however sparse the square's points, fewer than that being depth noise.
+const minPiecePointsFloor = 3
+
+func validateDensityScale(scale float64) error {
+	if scale > 4 {
+		return fmt.Errorf("density-scale has to be 4 or less, got %v", scale)
+	}
+	return nil
+}
+
+func (cfg *PieceFinderConfig) densityScale() float64 {
+	if cfg.DensityScale == 0 {
+		return 1
+	}
+	return cfg.DensityScale
+}
+
+// squareDensities is how many points each of clouds has next to the median of them, 1 for a
+// square with as many as most, under that for the ones far off from the camera that get fewer.
+// It's 1 for all of them when they're all empty.
+func squareDensities(clouds []pointcloud.PointCloud) []float64 {
+	counts := make([]float64, len(clouds))
+	for i, pc := range clouds {
+		counts[i] = float64(pc.Size())
+	}
+	sorted := slices.Clone(counts)
+	slices.Sort(sorted)
+	median := 0.0
+	if len(sorted) > 0 {
+		median = sorted[len(sorted)/2]
+	}
+	for i := range counts {
+		if median == 0 {
+			counts[i] = 1
+			continue
+		}
+		counts[i] /= median
+	}
+	return counts
+}
+
+// piecePointThreshold is how many points have to stick up off a square with density (see
+// squareDensities) for a piece to be on it: minPiecePoints times density to the power of conf's
+// densityScale, so it follows how many points a piece there gets, and no fewer than
+// minPiecePointsFloor. A negative density-scale keeps it at minPiecePoints.
+func piecePointThreshold(density float64, conf *PieceFinderConfig) int {
+	scale := conf.densityScale()
+	if scale < 0 || density <= 0 {
+		return minPiecePoints
+	}
+	return max(minPiecePointsFloor, int(math.Round(minPiecePoints*math.Pow(density, scale))))
+}
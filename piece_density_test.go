@@ -0,0 +1,85 @@
+package viamchess
+
+import (
+	"testing"
+
+	"github.com/corentings/chess/v2"
+	"github.com/erh/vmodutils/touch"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestSquareDensities(t *testing.T) {
+	clouds := []pointcloud.PointCloud{}
+	for _, n := range []int{10, 20, 20, 40} {
+		pc := pointcloud.NewBasicEmpty()
+		for i := range n {
+			test.That(t, pc.Set(pointcloud.NewVector(float64(i), 0, 500), nil), test.ShouldBeNil)
+		}
+		clouds = append(clouds, pc)
+	}
+	test.That(t, squareDensities(clouds), test.ShouldResemble, []float64{.5, 1, 1, 2})
+
+	empty := []pointcloud.PointCloud{pointcloud.NewBasicEmpty(), pointcloud.NewBasicEmpty()}
+	test.That(t, squareDensities(empty), test.ShouldResemble, []float64{1, 1})
+}
+
+func TestPiecePointThreshold(t *testing.T) {
+	conf := &PieceFinderConfig{}
+	test.That(t, piecePointThreshold(1, conf), test.ShouldEqual, minPiecePoints)
+	test.That(t, piecePointThreshold(.5, conf), test.ShouldEqual, 5)
+	test.That(t, piecePointThreshold(2, conf), test.ShouldEqual, 20)
+	// never down to the depth noise
+	test.That(t, piecePointThreshold(.1, conf), test.ShouldEqual, minPiecePointsFloor)
+	test.That(t, piecePointThreshold(0, conf), test.ShouldEqual, minPiecePoints)
+
+	// part way
+	test.That(t, piecePointThreshold(.25, &PieceFinderConfig{DensityScale: .5}), test.ShouldEqual, 5)
+	// or not at all
+	test.That(t, piecePointThreshold(.25, &PieceFinderConfig{DensityScale: -1}), test.ShouldEqual, minPiecePoints)
+
+	test.That(t, validateDensityScale(5), test.ShouldNotBeNil)
+	test.That(t, validateDensityScale(-1), test.ShouldBeNil)
+}
+
+func TestFarPawnCounts(t *testing.T) {
+	// a pawn far off from the camera is only 6 points, out of a square with 40% of the
+	// usual points on it
+	pawn := pieceOnSquare(t, 6, 4, 50)
+	height := boardSurface{}.heights(pawn)
+	conf := &PieceFinderConfig{}
+
+	c, _ := estimatePieceColor(pawn, height, conf, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 0)
+	c, _ = estimatePieceColor(pawn, height, conf, piecePointThreshold(.4, conf))
+	test.That(t, c, test.ShouldNotEqual, 0)
+}
+
+func TestDensityBoard13(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	b, err := FindPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{Rotation: "0", CornerSource: cornerSourceImage})
+	test.That(t, err, test.ShouldBeNil)
+
+	// most squares are about as dense, the ones on the ranks away from the camera, behind the
+	// pieces, the least
+	sparsest := b[0]
+	for _, sq := range b {
+		test.That(t, sq.Density, test.ShouldBeBetween, .3, 1.2)
+		if sq.Density < sparsest.Density {
+			sparsest = sq
+		}
+	}
+	test.That(t, b.At(chess.E5).Density, test.ShouldAlmostEqual, 1, .05)
+	test.That(t, sparsest.Rank, test.ShouldBeLessThanOrEqualTo, 3)
+	test.That(t, sparsest.MinPiecePoints, test.ShouldBeLessThan, minPiecePoints)
+	test.That(t, sparsest.MinPiecePoints, test.ShouldBeGreaterThanOrEqualTo, minPiecePointsFloor)
+
+	// and the pieces on them are still found
+	test.That(t, b.At(chess.D1).PieceColor, test.ShouldEqual, "white")
+	test.That(t, b.At(chess.D3).Occupied, test.ShouldBeFalse)
+}
@@ -0,0 +1,66 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// pieceDetections is a detection for each of squares with a piece on it, the square's bounds in
+// the frame (see computeSquareBounds) cut to bounds, the frame's, labeled as pieceObject labels
+// it and scored by its occupancy.
+func pieceDetections(squares []squareInfo, bounds image.Rectangle) []objectdetection.Detection {
+	detections := []objectdetection.Detection{}
+	for _, sq := range squares {
+		if sq.color == 0 {
+			continue
+		}
+		r := sq.originalBounds.Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		label := fmt.Sprintf("%s-%s", sq.name, pieceColorNames[sq.color])
+		detections = append(detections, objectdetection.NewDetection(bounds, r, occupancy(sq), label))
+	}
+	return detections
+}
+
+// DetectionsFromCamera is pieceDetections of a capture, see CaptureAllFromCamera.
+func (bc *PieceFinder) DetectionsFromCamera(ctx context.Context, cameraName string, extra map[string]interface{}) ([]objectdetection.Detection, error) {
+	var detections []objectdetection.Detection
+	_, err := bc.captureWith(ctx, viscapture.CaptureOptions{ReturnImage: true}, extra, func(ret viscapture.VisCapture, served *lastAnalysis) error {
+		if served != nil {
+			detections = pieceDetections(served.squares, ret.Image.Bounds())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return detections, nil
+}
+
+// frameSquares is the squares of img, which is taken to be a frame of the input: what's on them
+// needs the input's point cloud, and that's its current one.
+func (bc *PieceFinder) frameSquares(ctx context.Context, img image.Image, extra map[string]interface{}) ([]squareInfo, error) {
+	pc, err := bc.input.NextPointCloud(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+	bc.captureLock.Lock()
+	conf := bc.captureConf()
+	bc.captureLock.Unlock()
+	return findBoardAndPieces(img, pc, bc.props, conf)
+}
+
+// Detections is pieceDetections of img, a frame of the input, see frameSquares.
+func (bc *PieceFinder) Detections(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objectdetection.Detection, error) {
+	squares, err := bc.frameSquares(ctx, img, extra)
+	if err != nil {
+		return nil, err
+	}
+	return pieceDetections(squares, img.Bounds()), nil
+}
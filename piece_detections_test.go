@@ -0,0 +1,84 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestDetectionsBoard4(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}, &frame, &pc)
+
+	detections, err := pf.DetectionsFromCamera(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(detections), test.ShouldBeGreaterThan, 20)
+
+	boxes := map[string]image.Rectangle{}
+	for _, d := range detections {
+		name, c, ok := strings.Cut(d.Label(), "-")
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, c, test.ShouldBeIn, "white", "black")
+		test.That(t, d.Score(), test.ShouldBeBetweenOrEqual, .5, 1)
+		bb := *d.BoundingBox()
+		test.That(t, bb.Empty(), test.ShouldBeFalse)
+		test.That(t, bb.In(input.Bounds()), test.ShouldBeTrue)
+		boxes[name] = bb
+	}
+	// white has played e4, so there's nothing on e2 to box
+	_, ok := boxes["e2"]
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, boxes["d2"], test.ShouldNotResemble, image.Rectangle{})
+
+	// neighboring squares' boxes only share their edge
+	d1, e1 := boxes["d1"], boxes["e1"]
+	test.That(t, d1.Empty(), test.ShouldBeFalse)
+	test.That(t, e1.Empty(), test.ShouldBeFalse)
+	overlap := d1.Intersect(e1)
+	test.That(t, min(overlap.Dx(), overlap.Dy()), test.ShouldBeLessThanOrEqualTo, 3)
+
+	// the same frame handed in is boxed the same
+	fromImage, err := pf.Detections(ctx, input, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(fromImage), test.ShouldEqual, len(detections))
+
+	props, err := pf.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.DetectionSupported, test.ShouldBeTrue)
+}
+
+func TestDetectionsErrorFrame(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 1, ErrorFrames: "passthrough"}, &frame, &pc)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	for _, occluded := range []bool{false, true} {
+		if occluded {
+			frame = occludeHalf(input, corners)
+		}
+		detections, err := pf.DetectionsFromCamera(ctx, "", nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(detections), test.ShouldBeGreaterThan, 0)
+	}
+
+	// past max-age it's an error frame, even with the last analysis kept to fall back on
+	detections, err := pf.DetectionsFromCamera(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, detections, test.ShouldBeNil)
+}
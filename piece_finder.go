@@ -2,11 +2,17 @@ package viamchess
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/golang/geo/r2"
 	"github.com/golang/geo/r3"
 
 	"golang.org/x/image/font"
@@ -17,22 +23,27 @@ import (
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
 	"go.viam.com/rdk/robot/framesystem"
 	"go.viam.com/rdk/services/vision"
-	viz "go.viam.com/rdk/vision"
-	"go.viam.com/rdk/vision/classification"
-	"go.viam.com/rdk/vision/objectdetection"
 	"go.viam.com/rdk/vision/viscapture"
 	"go.viam.com/utils/trace"
 
-	"github.com/erh/vmodutils/touch"
+	"github.com/corentings/chess/v2"
 )
 
 var PieceFinderModel = family.WithModel("piece-finder")
 
-const minPieceSize = 25.0
+const (
+	defaultMinPieceSize        = 25.0
+	defaultMinCornerConfidence = .75
+	defaultSquareMargin        = .08
+	// shakyCornerMargin is how many pixels each way the square on a shaky corner is widened,
+	// about squareBounds' inset, in case the corner is off by about that.
+	shakyCornerMargin = 6
+)
 
-func init() {
+func registerPieceFinder() {
 	resource.RegisterService(vision.API, PieceFinderModel,
 		resource.Registration[vision.Service, *PieceFinderConfig]{
 			Constructor: newPieceFinder,
@@ -41,14 +52,312 @@ func init() {
 }
 
 type PieceFinderConfig struct {
-	Input string // this is the cropped camera for the board, TODO: what orientation???
+	Input string // this is the cropped camera for the board
+
+	// Rotation is where white sits in the image, see BoardRotation.
+	// "" or "0" is the native mounting, "auto" detects it from the pieces every capture.
+	Rotation string
+
+	// BoardOptions overrides individual BoardFinderOptions fields, by json name.
+	BoardOptions map[string]interface{} `json:"board-options"`
+
+	// SquareMargin is how much of a square's side (0 means .08, negative none) in from each of its
+	// edges is left out of what's on it, the lines between the squares and the bottoms of the
+	// pieces on the next ones. The square_clouds command shows the whole squares.
+	SquareMargin float64 `json:"square-margin"`
+
+	// ChangeThreshold is how much (0-255) the brightness of any part of the board has to change
+	// before a capture is analyzed again instead of reusing the last one. 0 means 20, negative
+	// analyzes every capture.
+	ChangeThreshold int `json:"change-threshold"`
+	// MaxAge is how many captures in a row can reuse an analysis, 0 means 10.
+	MaxAge int `json:"max-age"`
+
+	// ErrorFrames is what a capture returns when the board or pieces can't be found.
+	// "error" (the default) fails the capture, "passthrough" returns the input frame and
+	// "placeholder" an image with the error on it, both without objects and flagged unprocessed.
+	ErrorFrames string `json:"error-frames"`
+
+	// The corners are smoothed over about SmoothFrames captures, 0 means 5 and 1 turns it off.
+	// A detection with a corner more than MaxCornerJump pixels (0 means 15) from the smoothed
+	// corners is ignored, unless it stays there JumpFrames captures in a row (0 means 3).
+	SmoothFrames  int     `json:"smooth-frames"`
+	MaxCornerJump float64 `json:"max-corner-jump"`
+	JumpFrames    int     `json:"jump-frames"`
+	// For CornerCacheTTL seconds after a detection the corners are used as they are, without
+	// looking for the board, as when they're frozen. 0, the default, looks every capture.
+	CornerCacheTTL float64 `json:"corner-cache-ttl"`
+
+	// PieceScale is how big the pieces are next to a standard set, 0 means 1, see the density
+	// height command for a suggestion. A square is occupied when something sticks up more than
+	// MinPieceSize (mm), 0 means 25 times the scale.
+	PieceScale   float64 `json:"piece-scale"`
+	MinPieceSize float64 `json:"min-piece-size"`
+
+	// A piece needs more than 10 of a square's points to stick up more than MinPieceSize, times how
+	// many points the square has next to the median square to the power of DensityScale (0 means 1,
+	// in proportion, negative keeps it at 10), so a piece far off from the camera with fewer points
+	// on it still counts. It's never under 3.
+	DensityScale float64 `json:"density-scale"`
+
+	// Only the points from SurfaceTolerance (mm, 0 means 20) below the board to MaxPieceHeight
+	// (mm, 0 means 150 times the scale) above it are a square's, the rest are the table under
+	// it or an arm or hand over it.
+	MaxPieceHeight   float64 `json:"max-piece-height"`
+	SurfaceTolerance float64 `json:"surface-tolerance"`
+
+	// PieceSizes are how tall and across each type of piece ("pawn", "knight", "bishop", "rook",
+	// "queen" and "king") is in mm, over a standard Staunton set's times the scale, see the
+	// calibrate_pieces command to fit them. A piece is labeled with the type it most likely is
+	// when that's at least MinTypeConfidence (0-1, 0 means .6) likely.
+	PieceSizes        map[string]PieceSize `json:"piece-sizes"`
+	MinTypeConfidence float64              `json:"min-type-confidence"`
+
+	// A piece's color is told from the square's own surface, the points no more than
+	// SurfaceBand (mm, 0 means 10) above the lowest. It's white when its points are at least
+	// MinPieceContrast (0-255 luminance, 0 means 30) brighter than that and black when they're
+	// that much darker; closer than that it's taken to be the square's own color.
+	SurfaceBand      float64 `json:"surface-band"`
+	MinPieceContrast float64 `json:"min-piece-contrast"`
+	// Once learn_piece_colors has learned what color the white and black pieces are, a piece is
+	// the one its CIE L*a*b* color is nearer, and ambiguous when it's nearer by less than
+	// ColorRejectBand (0-1 of how far apart they are, 0 means .2).
+	ColorRejectBand float64 `json:"color-reject-band"`
+
+	// Depth speckle, points floating over a square on their own, is dropped from each square
+	// before it's looked at: a point needs SpeckleMinPoints (0 means 4, negative keeps them all)
+	// in the SpeckleVoxel (mm, 0 means 5) cube it's in and the ones touching it, itself included.
+	SpeckleVoxel     float64 `json:"speckle-voxel"`
+	SpeckleMinPoints int     `json:"speckle-min-points"`
+
+	// Graveyards are where beside the board captured pieces are put, each a rectangle of the
+	// input image or a box in the world frame. The pieces in them are objects too, labeled
+	// "X0-<color>", "X1-<color>" ... after the squares', and how many of each color there are is
+	// the capture's extra "graveyard".
+	Graveyards []GraveyardRegion `json:"graveyards"`
+
+	// Cameras are the cameras the board is looked at with, Input being the "overhead" one, which
+	// says what's on each square, and any "side" ones measuring the heights and types of the
+	// pieces on the squares they see, at corners of their own. When one disagrees with the
+	// overhead camera about whether a square has a piece on it, that square's confidence is
+	// halved.
+	Cameras []FusedCamera `json:"cameras"`
+
+	// DebugTheme is how the debug_image command draws the frame under the grid when it isn't
+	// given a theme: "image" (the default), "hue", "saturation", "value", "square-mean" or
+	// "delta-from-calibration", which needs calibrate_colors run on the empty board first.
+	DebugTheme string `json:"debug-theme"`
+
+	// LabelScale is how many times the 7x13 pixel font the debug image's square labels are drawn,
+	// 0 picks it from how big the squares are, see labelScale.
+	LabelScale int `json:"label-scale"`
+
+	// MinVisibleScore is how sure IsBoardVisible has to be (0-1) that there's a board in a frame
+	// before the board finder is run on it. 0 means .5, negative runs it on every frame.
+	MinVisibleScore float64 `json:"min-visible-score"`
+
+	// CornerSource is where the board's corners come from when there's a point cloud:
+	// "point-cloud" (the default) fits the table and the board on it in the cloud, falling back
+	// to the image when that fails, and "image" only looks in the image.
+	CornerSource string `json:"corner-source"`
+
+	// MinCornerConfidence is how sure the board finder has to be of a corner, see
+	// FindBoardResult.CornerConfidence, not to log a warning about it and widen the square
+	// on it by shakyCornerMargin pixels each way. 0 means .75, negative turns it off.
+	MinCornerConfidence float64 `json:"min-corner-confidence"`
+
+	// Corners pins the board's TL, TR, BR, BL corners, [x, y] pixels in the input's frame, for a
+	// camera that doesn't move. The board finder isn't run at all, see the save_corners command
+	// for what it finds to put here.
+	Corners [][]int `json:"corners"`
+
+	// While the corners are frozen, a frame whose strip along the board's edge is more than
+	// ShiftThreshold (0-255, 0 means 20, negative turns it off) brighter or darker on average
+	// than when they were frozen counts as the board being bumped, and after ShiftFrames (0
+	// means 3) of those in a row the board is found again.
+	ShiftThreshold float64 `json:"shift-threshold"`
+	ShiftFrames    int     `json:"shift-frames"`
+
+	// ExcludeFrames are frame system frames, like the arm and its gripper, whose geometries, where
+	// they are when a capture is taken, are cut out of the point cloud before it's split into
+	// squares, so an arm hovering over the board doesn't read as pieces. A capture's extra can add
+	// more as "exclude_frames".
+	ExcludeFrames []string `json:"exclude-frames"`
+
+	// CameraFrame is the input camera's frame in the frame system, when it isn't called what the
+	// camera is. Setting it poses the object of each occupied square at its piece's centroid in
+	// the world frame, a box around the piece, for motion to go to; a capture's extra says
+	// "world_poses" false when the frame system couldn't say where the camera is and they're in
+	// its frame instead.
+	CameraFrame string `json:"camera-frame"`
+
+	zero          *boardZero    // from calibrate_board, see captureConf
+	colorBaseline []color.RGBA  // from calibrate_colors, see captureConf
+	anchors       *pieceAnchors // from learn_piece_colors, see captureConf
 }
 
 func (cfg *PieceFinderConfig) Validate(path string) ([]string, []string, error) {
 	if cfg.Input == "" {
 		return nil, nil, fmt.Errorf("need an input")
 	}
-	return []string{cfg.Input}, nil, nil
+	if cfg.Rotation != "auto" {
+		_, err := ParseBoardRotation(cfg.Rotation)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	opts, err := cfg.boardFinderOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad board-options: %w", err)
+	}
+	if cfg.Rotation == "auto" && opts.gridSize() != defaultGridSize {
+		return nil, nil, fmt.Errorf("rotation auto goes by where the chess pieces start, it needs grid-size 8")
+	}
+	if cfg.SquareMargin >= .5 {
+		return nil, nil, fmt.Errorf("square-margin has to be under .5, got %v", cfg.SquareMargin)
+	}
+	if cfg.CornerCacheTTL < 0 {
+		return nil, nil, fmt.Errorf("corner-cache-ttl can't be negative, got %v", cfg.CornerCacheTTL)
+	}
+	err = validateErrorFrames(cfg.ErrorFrames)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validatePieceScale(cfg.PieceScale)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validatePieceBand(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validatePieceSizes(cfg.PieceSizes, cfg.MinTypeConfidence)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validatePieceColor(cfg.SurfaceBand, cfg.MinPieceContrast)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateColorRejectBand(cfg.ColorRejectBand)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateSpeckle(cfg.SpeckleVoxel)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateGraveyards(cfg.Graveyards)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateCameras(cfg.Input, cfg.Cameras)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateDensityScale(cfg.DensityScale)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateDebugTheme(cfg.DebugTheme)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateLabelScale(cfg.LabelScale)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateCornerSource(cfg.CornerSource)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateCorners(cfg.Corners)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateExcludeFrames(cfg.ExcludeFrames)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append([]string{cfg.Input}, sideCameraNames(cfg.Cameras)...), nil, nil
+}
+
+func (cfg *PieceFinderConfig) minPieceSize() float64 {
+	return scaledDefault(cfg.MinPieceSize, defaultMinPieceSize, cfg.PieceScale)
+}
+
+func (cfg *PieceFinderConfig) squareMargin() float64 {
+	switch {
+	case cfg.SquareMargin == 0:
+		return defaultSquareMargin
+	case cfg.SquareMargin < 0:
+		return 0
+	}
+	return cfg.SquareMargin
+}
+
+func (cfg *PieceFinderConfig) boardFinderOptions() (BoardFinderOptions, error) {
+	return BoardFinderOptionsFromMap(cfg.BoardOptions)
+}
+
+func (cfg *PieceFinderConfig) changeThreshold() int {
+	if cfg.ChangeThreshold == 0 {
+		return defaultChangeThreshold
+	}
+	return cfg.ChangeThreshold
+}
+
+func (cfg *PieceFinderConfig) minVisibleScore() float64 {
+	if cfg.MinVisibleScore == 0 {
+		return minVisibleScore
+	}
+	return cfg.MinVisibleScore
+}
+
+func (cfg *PieceFinderConfig) minCornerConfidence() float64 {
+	if cfg.MinCornerConfidence == 0 {
+		return defaultMinCornerConfidence
+	}
+	return cfg.MinCornerConfidence
+}
+
+func (cfg *PieceFinderConfig) maxAge() int {
+	if cfg.MaxAge <= 0 {
+		return defaultMaxAge
+	}
+	return cfg.MaxAge
+}
+
+func (cfg *PieceFinderConfig) smoothFrames() int {
+	if cfg.SmoothFrames <= 0 {
+		return defaultSmoothFrames
+	}
+	return cfg.SmoothFrames
+}
+
+func (cfg *PieceFinderConfig) maxCornerJump() float64 {
+	if cfg.MaxCornerJump <= 0 {
+		return defaultMaxCornerJump
+	}
+	return cfg.MaxCornerJump
+}
+
+func (cfg *PieceFinderConfig) jumpFrames() int {
+	if cfg.JumpFrames <= 0 {
+		return defaultJumpFrames
+	}
+	return cfg.JumpFrames
+}
+
+func (cfg *PieceFinderConfig) cornerCacheTTL() time.Duration {
+	return time.Duration(cfg.CornerCacheTTL * float64(time.Second))
+}
+
+func (cfg *PieceFinderConfig) rotation(img image.Image, corners []image.Point) (BoardRotation, error) {
+	if cfg.Rotation == "auto" {
+		return DetectBoardOrientation(img, corners)
+	}
+	return ParseBoardRotation(cfg.Rotation)
 }
 
 func newPieceFinder(ctx context.Context, deps resource.Dependencies, rawConf resource.Config, logger logging.Logger) (vision.Service, error) {
@@ -64,9 +373,14 @@ func NewPieceFinder(ctx context.Context, deps resource.Dependencies, name resour
 	var err error
 
 	bc := &PieceFinder{
-		name:   name,
-		conf:   conf,
-		logger: logger,
+		name:    name,
+		conf:    conf,
+		logger:  logger,
+		corners: newCornerSmoother(conf),
+	}
+
+	if w := scaleWarning("min-piece-size", conf.MinPieceSize, defaultMinPieceSize, conf.PieceScale); w != "" {
+		logger.Warn(w)
 	}
 
 	bc.input, err = camera.FromProvider(deps, conf.Input)
@@ -78,6 +392,42 @@ func NewPieceFinder(ctx context.Context, deps resource.Dependencies, name resour
 	if err != nil {
 		return nil, err
 	}
+	err = checkCornersInFrame(conf.Corners, bc.props)
+	if err != nil {
+		return nil, err
+	}
+	bc.pinned = conf.Corners
+
+	for _, c := range conf.Cameras {
+		if c.Role != RoleSide {
+			continue
+		}
+		side := &sideCamera{conf: c}
+		side.cam, err = camera.FromProvider(deps, c.Name)
+		if err != nil {
+			return nil, err
+		}
+		side.props, err = side.cam.Properties(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = checkCornersInFrame(c.Corners, side.props)
+		if err != nil {
+			return nil, fmt.Errorf("side camera %s: %w", c.Name, err)
+		}
+		bc.sides = append(bc.sides, side)
+	}
+
+	bc.zeroFile = boardZeroFile(name.Name)
+	bc.zero, err = readBoardZero(bc.zeroFile)
+	if err != nil {
+		logger.Warnf("ignoring the board calibration: %v", err)
+	}
+	bc.anchorsFile = pieceAnchorsFile(name.Name)
+	bc.anchors, err = readPieceAnchors(bc.anchorsFile)
+	if err != nil {
+		logger.Warnf("ignoring the learned piece colors: %v", err)
+	}
 
 	bc.rfs, err = framesystem.FromDependencies(deps)
 	if err != nil {
@@ -98,8 +448,90 @@ type PieceFinder struct {
 	rfs   framesystem.Service
 	input camera.Camera
 	props camera.Properties
+	sides []*sideCamera // the side cameras of conf.Cameras
+
+	// reused between captures so streaming doesn't churn the heap
+	captureLock sync.Mutex
+	squares     []squareInfo
+	labels      labelCache
+	last        *lastAnalysis
+	lastErr     *captureError
+	corners     cornerSmoother
+	undistort   undistorter
+	pinned      [][]int // conf.Corners until set_corners changes them
+	shift       shiftWatch
+	events      []pieceFinderEvent
+	visible     *float64 // boardVisibleScore of the last frame looked at, nil when not checked
+
+	colorBaseline []color.RGBA // squareMeanColors of the empty board, from calibrate_colors
+
+	pieceSizes map[string]PieceSize // conf.PieceSizes until calibrate_pieces fits them
+
+	zero     *boardZero // from calibrate_board, nil before it's been run
+	zeroFile string     // where it's kept
+
+	anchors     *pieceAnchors // from learn_piece_colors, nil before it's been run
+	anchorsFile string        // where they're kept
 }
 
+// squareNames are the squares of a chess board in findBoardAndPieces order.
+var squareNames = func() [64]string {
+	names := [64]string{}
+	copy(names[:], makeSquareNames(defaultGridSize))
+	return names
+}()
+
+// makeSquareNames is the names of the squares of an n x n board in findBoardAndPieces order.
+// Files go on past h and ranks past 8, so the far corner of a 10x10 board is j10.
+func makeSquareNames(n int) []string {
+	names := make([]string, n*n)
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			names[squareIndex(file, rank, n)] = SquareName(file, rank)
+		}
+	}
+	return names
+}
+
+// gridSquareNames is makeSquareNames without building the chess board's again.
+func gridSquareNames(n int) []string {
+	if n == defaultGridSize {
+		return squareNames[:]
+	}
+	return makeSquareNames(n)
+}
+
+// squareIndex is the position of a square of an n x n board in the slice returned by
+// findBoardAndPieces.
+func squareIndex(file rune, rank, n int) int {
+	return (rank-1)*n + int(file-'a')
+}
+
+// labelCache keeps the "<square>-<color>" labels between captures, only building
+// a new string when a square's color changes.
+type labelCache struct {
+	names  []string
+	colors []int
+	labels []string
+}
+
+func (lc *labelCache) label(idx int, name string, color int) string {
+	for len(lc.labels) <= idx {
+		lc.names = append(lc.names, "")
+		lc.colors = append(lc.colors, 0)
+		lc.labels = append(lc.labels, "")
+	}
+	if lc.labels[idx] == "" || lc.colors[idx] != color || lc.names[idx] != name {
+		lc.names[idx] = name
+		lc.colors[idx] = color
+		lc.labels[idx] = fmt.Sprintf("%s-%d", name, color)
+	}
+	return lc.labels[idx]
+}
+
+// squareInfo is one square of a capture. originalBounds is its squareBounds, pc the points that
+// project inside it. Both go by the same rule: a pixel, or a point's pixel rounded down, is in a
+// square when it's in [min, max) of its bounds, so nothing on a boundary is counted twice.
 type squareInfo struct {
 	rank int
 	file rune
@@ -107,136 +539,359 @@ type squareInfo struct {
 
 	originalBounds image.Rectangle
 
-	color int // 0,1,2
+	color      int     // 0,1,2
+	confidence float64 // of color, see estimatePieceColor
+
+	// how tall and across (mm) what's on the square is, see pieceShape, and whether that's a
+	// piece lying on its side
+	heightMM, footprintMM float64
+	fallen                bool
+
+	// what type of piece it is, see classifyPiece, and how sure of that
+	pieceType      chess.PieceType
+	typeConfidence float64
+
+	pc     pointcloud.PointCloud // nil when there wasn't one, see squaresFromImage
+	source string                // sourceDepth or sourceImage
+
+	// how many points it has next to most squares, see squareDensities, and how many of them had
+	// to stick up for a piece, see piecePointThreshold
+	density   float64
+	minPoints int
 
-	pc pointcloud.PointCloud
+	// the color of what sticks up off it and how many points that's from, see pieceLab, and
+	// whether its color was too near both learned ones to tell, see pieceAnchors.classify
+	lab       labColor
+	labPoints int
+	ambiguous bool
+
+	// what's wrong with what's on it, nil when nothing is, see findAnomalies; the squares an
+	// anomaly's over share it
+	anomaly *Anomaly
+
+	// the cameras that saw it, the input first, and the one that measured what's on it, see
+	// fuseSquares; nil and "" without any side cameras
+	cameras    []string
+	measuredBy string
+
+	// how many points of depth speckle were dropped from it, see removeSpeckle
+	speckle int
 }
 
 func scale(start, end int, amount float64) int {
-	//fmt.Printf("\t %v %v %v\n", start, end, amount)
 	return int(float64(end-start)*amount) + start
 }
 
-func computeSquareBounds(corners []image.Point, col, row int) image.Rectangle {
+// computeSquareBounds is BoardCorners.squareCell for corners in TL, TR, BR, BL order of an
+// n x n board.
+func computeSquareBounds(corners []image.Point, col, row, n int) image.Rectangle {
+	return boardCornersFromSlice(corners).squareCell(col, row, n)
+}
 
-	colTopLeft := image.Point{
-		scale(corners[0].X, corners[1].X, float64(col)/8),
-		scale(corners[0].Y, corners[1].Y, float64(col)/8),
-	}
+// computeSquareClassificationBounds is computeSquareBounds less margin of its side in from each
+// edge, what of it a square's pixels and points are classified from, see
+// BoardCorners.squareBounds.
+func computeSquareClassificationBounds(corners []image.Point, col, row, n int, margin float64) image.Rectangle {
+	return boardCornersFromSlice(corners).squareBounds(col, row, n, margin)
+}
 
-	colTopRight := image.Point{
-		scale(corners[0].X, corners[1].X, float64(1+col)/8),
-		scale(corners[0].Y, corners[1].Y, float64(1+col)/8),
-	}
+// squareClouds splits pc into the points that project inside each of rects, see squareInfo.
+// A point inside two overlapping rects goes to the first. It's one pass over pc, sharded
+// across the CPUs, each point's rect looked up by its pixel in squareOwners.
+func squareClouds(pc pointcloud.PointCloud, rects []image.Rectangle, props camera.Properties) ([]pointcloud.PointCloud, error) {
+	owners := newSquareOwners(rects)
+	ip := props.IntrinsicParams
+
+	shards := runtime.GOMAXPROCS(0)
+	found := make([][][]pointcloud.PointAndData, shards)
+	parallelRows(shards, func(start, end int) {
+		for shard := start; shard < end; shard++ {
+			mine := make([][]pointcloud.PointAndData, len(rects))
+			pc.Iterate(shards, shard, func(p r3.Vector, d pointcloud.Data) bool {
+				if p.Z == 0 {
+					return true
+				}
+				if i := owners.at(pointPixel(p, ip)); i >= 0 {
+					mine[i] = append(mine[i], pointcloud.PointAndData{P: p, D: d})
+				}
+				return true
+			})
+			found[shard] = mine
+		}
+	})
 
-	colBottomLeft := image.Point{
-		scale(corners[3].X, corners[2].X, float64(col)/8),
-		scale(corners[3].Y, corners[2].Y, float64(col)/8),
+	out := make([]pointcloud.PointCloud, len(rects))
+	for i := range out {
+		size := 0
+		for _, mine := range found {
+			size += len(mine[i])
+		}
+		out[i] = pointcloud.NewBasicPointCloud(size)
+		for _, mine := range found {
+			for _, pd := range mine[i] {
+				if err := out[i].Set(pd.P, pd.D); err != nil {
+					return nil, err
+				}
+			}
+		}
 	}
+	return out, nil
+}
 
-	colBottomRight := image.Point{
-		scale(corners[3].X, corners[2].X, float64(1+col)/8),
-		scale(corners[3].Y, corners[2].Y, float64(1+col)/8),
+// pointPixel is the pixel p, in front of the camera, is in. Not PointToPixel, that rounds and
+// would put a point just left of a boundary right of it.
+func pointPixel(p r3.Vector, ip *transform.PinholeCameraIntrinsics) image.Point {
+	return image.Point{
+		X: int(math.Floor(p.X/p.Z*ip.Fx + ip.Ppx)),
+		Y: int(math.Floor(p.Y/p.Z*ip.Fy + ip.Ppy)),
 	}
+}
 
-	//fmt.Printf("colTopLeft: %v\n", colTopLeft)
-	//fmt.Printf("colBottomLeft: %v\n", colBottomLeft)
-	//fmt.Printf("colTopRight: %v\n", colTopRight)
-	//fmt.Printf("colBottomRight: %v\n", colBottomRight)
+// squareOwners is which of some rects each pixel over them is in first.
+type squareOwners struct {
+	bounds image.Rectangle
+	owner  []int16 // by pixel in bounds, row by row, -1 for none of them
+}
 
-	bounds := image.Rect(
-		scale(colTopLeft.X, colBottomLeft.X, float64(row)/8),
-		scale(colTopLeft.Y, colBottomLeft.Y, float64(row)/8),
-		scale(colTopRight.X, colBottomRight.X, float64(row+1)/8),
-		scale(colTopRight.Y, colBottomRight.Y, float64(row+1)/8),
-	)
+// newSquareOwners is the squareOwners of rects, which can be up to maxGridSize squared.
+func newSquareOwners(rects []image.Rectangle) squareOwners {
+	var bounds image.Rectangle
+	for _, r := range rects {
+		bounds = bounds.Union(r)
+	}
+	o := squareOwners{bounds, make([]int16, bounds.Dx()*bounds.Dy())}
+	for i := range o.owner {
+		o.owner[i] = -1
+	}
+	// the last first, so the first is what's left where they overlap
+	for i := len(rects) - 1; i >= 0; i-- {
+		r := rects[i].Intersect(bounds)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			row := o.owner[(y-bounds.Min.Y)*bounds.Dx():]
+			for x := r.Min.X; x < r.Max.X; x++ {
+				row[x-bounds.Min.X] = int16(i)
+			}
+		}
+	}
+	return o
+}
 
-	// Add inset to avoid capturing border lines between squares
-	// and to account for depth/RGB alignment issues
-	// Shrink by 10 pixels on each side to stay well within the square
-	inset := 10
-	bounds.Min.X += inset
-	bounds.Min.Y += inset
-	bounds.Max.X -= inset
-	bounds.Max.Y -= inset
+// at is which rect p is in first, -1 for none.
+func (o squareOwners) at(p image.Point) int {
+	if !p.In(o.bounds) {
+		return -1
+	}
+	return int(o.owner[(p.Y-o.bounds.Min.Y)*o.bounds.Dx()+p.X-o.bounds.Min.X])
+}
+
+// errBoardOccluded is when something, probably someone's hand, is in the way of the board.
+var errBoardOccluded = errors.New("board is occluded")
 
-	return bounds
+func findBoardAndPieces(srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig) ([]squareInfo, error) {
+	return findBoardAndPiecesInto(context.Background(), logging.NewBlankLogger(""), nil, nil, nil, srcImg, pc, props, conf)
 }
 
-func findBoardAndPieces(srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties) ([]squareInfo, error) {
+// findBoardAndPiecesInto is findBoardAndPieces but reuses the dst slice, if smoother isn't nil
+// uses the smoothed corners, and gives up with ctx.Err() once ctx is done. When props have the
+// lens distortion the board is looked for in the frame with it taken out by und, nil for a new
+// one, and the corners put back where they are in srcImg. A corner the board finder isn't sure
+// of is logged to logger. With conf.Corners the board is there and isn't looked for at all, and
+// with smoother frozen it's where it was. The returned squares are only valid until dst is
+// passed in again.
+func findBoardAndPiecesInto(ctx context.Context, logger logging.Logger, dst []squareInfo, smoother *cornerSmoother, und *undistorter, srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig) ([]squareInfo, error) {
 
-	corners, err := findBoard(srcImg)
+	opts, err := conf.boardFinderOptions()
 	if err != nil {
 		return nil, err
 	}
 
-	//fmt.Printf("corners: %v\n", corners)
+	if conf.Corners != nil {
+		board, err := pinnedBoard(conf.Corners, srcImg)
+		if err != nil {
+			return nil, err
+		}
+		return squaresOnBoard(dst, board, [4]bool{}, srcImg, pc, props, zeroedConf(logger, conf, board), opts.gridSize())
+	}
+	if smoother != nil && smoother.skip() {
+		board := boardCornersFromSlice(roundCorners(smoother.corners()))
+		return squaresOnBoard(dst, board, shakyCorners(smoother.confidence, conf), srcImg, pc, props, zeroedConf(logger, conf, board), opts.gridSize())
+	}
 
-	squares := []squareInfo{}
+	res := findCorners(ctx, und, srcImg, pc, props, conf, opts)
+	switch res.Reason {
+	case NotFoundCanceled:
+		return nil, res.Err
+	case NotFoundOccluded:
+		return nil, errBoardOccluded
+	case NotFoundBadQuad:
+		return nil, fmt.Errorf("board corners rejected: %w", res.Err)
+	}
+	board := res.Board
+	if smoother != nil {
+		board = boardCornersFromSlice(roundCorners(smoother.update(res)))
+	}
+	shaky := shakyCorners(res.CornerConfidence, conf)
+	for i, c := range res.CornerConfidence {
+		if shaky[i] {
+			logger.Warnf("not sure of the board's %s corner (confidence %.2f), widening the square on it", cornerNames[i], c)
+		}
+	}
+	return squaresOnBoard(dst, board, shaky, srcImg, pc, props, zeroedConf(logger, conf, board), opts.gridSize())
+}
 
-	for rank := 1; rank <= 8; rank++ {
-		for file := 'a'; file <= 'h'; file++ {
-			name := fmt.Sprintf("%s%d", string([]byte{byte(file)}), rank)
+// findCorners is the board findBoardAndPiecesInto finds in srcImg, from pc first unless conf
+// says not to, the lens distortion taken out by und, nil for a new one.
+func findCorners(ctx context.Context, und *undistorter, srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig, opts BoardFinderOptions) FindBoardResult {
+	if und == nil {
+		und = &undistorter{}
+	}
+	img := und.undistort(srcImg, props)
 
-			srcRect := computeSquareBounds(corners, int('h'-file), rank-1)
+	var res FindBoardResult
+	if pc != nil && conf.CornerSource != cornerSourceImage {
+		res = pointCloudBoard(img, pc, props, opts)
+		if ctx.Err() != nil {
+			return canceledResult(ctx, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+	if !res.Found && res.Reason != NotFoundOccluded {
+		res = detectBoardCtx(ctx, img, opts, nil)
+	}
+	return distortResult(res, props)
+}
 
-			subPc, err := touch.PCLimitToImageBoxes(pc, []*image.Rectangle{&srcRect}, nil, props)
-			if err != nil {
-				return nil, err
+// shakyCorners is which of the corners with confidence (TL, TR, BR, BL) are under
+// conf.minCornerConfidence.
+func shakyCorners(confidence [4]float64, conf *PieceFinderConfig) [4]bool {
+	var shaky [4]bool
+	for i, c := range confidence {
+		shaky[i] = c < conf.minCornerConfidence()
+	}
+	return shaky
+}
+
+// squaresOnBoard is the second half of findBoardAndPiecesInto, each of the n x n squares of
+// board with its part of pc and the color of the piece on it. The squares on the corners that
+// are shaky take in shakyCornerMargin more pixels each way. Without a pc, or with an empty one,
+// it's squaresFromImage.
+func squaresOnBoard(dst []squareInfo, board BoardCorners, shaky [4]bool, srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig, n int) ([]squareInfo, error) {
+	rot, err := conf.rotation(srcImg, board.Slice())
+	if err != nil {
+		return nil, err
+	}
+	// where the squares on TL, TR, BR, BL are
+	cornerSquares := [4]image.Point{{0, 0}, {n - 1, 0}, {n - 1, n - 1}, {0, n - 1}}
+
+	// squareNames order
+	names := gridSquareNames(n)
+	rects := make([]image.Rectangle, 0, n*n)
+	cells := make([]image.Rectangle, 0, n*n)
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			col, row := gridPosition(file, rank, rot, n)
+			cells = append(cells, board.squareCell(col, row, n))
+			r := board.squareBounds(col, row, n, conf.squareMargin())
+			for i, p := range cornerSquares {
+				if shaky[i] && p == (image.Point{col, row}) {
+					r = r.Inset(-shakyCornerMargin)
+				}
 			}
+			rects = append(rects, r)
+		}
+	}
+	if pc == nil || pc.Size() == 0 {
+		return squaresFromImage(dst, board, rot, rects, srcImg, conf, n)
+	}
+	clouds, err := squareClouds(pc, rects, props)
+	if err != nil {
+		return nil, err
+	}
+	// the board under them all, for how high what's on each of them is
+	surface := fitBoardSurface(clouds)
+	densities := squareDensities(clouds)
+	sizes := conf.pieceSizes()
+	zeroed := conf.zero != nil && len(conf.zero.Squares) == n*n
+
+	squares := dst[:0]
+	heights := make([]func(r3.Vector) float64, n*n)
+
+	for rank := 1; rank <= n; rank++ {
+		for file := 'a'; file < 'a'+rune(n); file++ {
+			idx := squareIndex(file, rank, n)
+			name := names[idx]
+			srcRect := rects[idx]
+			subPc := clouds[idx]
 
 			if subPc.Size() == 0 {
 				return nil, fmt.Errorf("pc for %s is empty in findBoardAndPieces", name)
 			}
-
-			pieceColor := estimatePieceColor(subPc)
+			under := surface
+			if zeroed {
+				under = surface.withZero(conf.zero.Squares[name])
+			}
+			subPc, err = cropToBand(subPc, under.heights(subPc), conf)
+			if err != nil {
+				return nil, err
+			}
+			if subPc.Size() == 0 {
+				// all of it is over the pieces, something's in the way of the square
+				return nil, fmt.Errorf("%w: nothing of %s in reach of the board", errBoardOccluded, name)
+			}
+			cleaned, speckle, err := removeSpeckle(subPc, conf)
+			if err != nil {
+				return nil, err
+			}
+			if cleaned.Size() > 0 {
+				subPc = cleaned
+			}
+			// else it's all speckle and there's nothing on the square, its points still say where it is
+
+			height := under.heights(subPc)
+			heights[idx] = height
+			minPoints := piecePointThreshold(densities[idx], conf)
+			pieceColor, confidence, lab, labPoints, ambiguous := classifyPieceColor(cleaned, height, conf, minPoints)
+			tall, across := pieceShape(cleaned, under)
+			fallen := isFallen(tall, across, conf.PieceScale)
+			pieceType, typeConfidence := chess.NoPieceType, 0.0
+			if pieceColor != 0 && !fallen {
+				pieceType, typeConfidence = classifyPiece(tall, across, sizes, conf.PieceScale)
+			}
 
 			squares = append(squares, squareInfo{
-				rank,
-				file,
-				name,
-				srcRect,
-				pieceColor,
-				subPc,
+				rank:           rank,
+				file:           file,
+				name:           name,
+				originalBounds: srcRect,
+				color:          pieceColor,
+				confidence:     confidence,
+				heightMM:       tall,
+				footprintMM:    across,
+				fallen:         fallen,
+				pieceType:      pieceType,
+				typeConfidence: typeConfidence,
+				pc:             subPc,
+				source:         sourceDepth,
+				density:        densities[idx],
+				minPoints:      minPoints,
+				lab:            lab,
+				labPoints:      labPoints,
+				ambiguous:      ambiguous,
+				speckle:        speckle,
 			})
 		}
 	}
 
-	return squares, nil
-}
-
-// 0 - blank, 1 - white, 2 - black
-func estimatePieceColor(pc pointcloud.PointCloud) int {
-	minZ := pc.MetaData().MaxZ - minPieceSize
-	var totalR, totalG, totalB float64
-	count := 0
-
-	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
-		if p.Z < minZ && d != nil && d.HasColor() {
-			r, g, b := d.RGB255()
-			totalR += float64(r)
-			totalG += float64(g)
-			totalB += float64(b)
-			count++
+	// a piece on its side or across squares, which no one square shows all of
+	raised := raisedPoints(pc, cells, heights, surface, props, conf)
+	for _, a := range findAnomalies(raised, squarePitch(clouds, surface, n), conf.PieceScale, names) {
+		for i := range squares {
+			if slices.Contains(a.Squares, squares[i].name) {
+				squares[i].anomaly = a
+			}
 		}
-		return true
-	})
-
-	if count <= 10 {
-		return 0 // blank - no piece detected
 	}
 
-	// calculate average brightness
-	avgR := totalR / float64(count)
-	avgG := totalG / float64(count)
-	avgB := totalB / float64(count)
-	brightness := (avgR + avgG + avgB) / 3.0
-
-	// threshold to distinguish white vs black pieces
-	if brightness > 128 {
-		return 1 // white
-	}
-	return 2 // black
+	return squares, nil
 }
 
 func drawString(dst *image.RGBA, x, y int, s string, c color.Color) {
@@ -250,38 +905,388 @@ func drawString(dst *image.RGBA, x, y int, s string, c color.Color) {
 }
 
 func (bc *PieceFinder) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	// the commands that look at the current frame take the board to be at "corners" if they're
+	// given, see currentSquares; {"corners": true} is the smoothed corners
+	if arg, ok := cmd["corners"]; ok && arg != true {
+		ctx = context.WithValue(ctx, cornersKey{}, arg)
+	}
+	if cmd["debug"] == true {
+		return bc.debugBoard(ctx)
+	}
+	if mode, ok := cmd["density"].(string); ok {
+		return bc.density(ctx, mode)
+	}
+	if cmd["status"] == true {
+		return bc.status(), nil
+	}
+	if cmd["visible"] == true {
+		return bc.visibility(ctx)
+	}
+	if cmd["corners"] == true {
+		return bc.smoothedCorners(), nil
+	}
+	if cmd["get_corners"] == true {
+		return bc.activeCorners(), nil
+	}
+	if cmd["refresh"] == true {
+		return bc.refresh(), nil
+	}
+	if cmd["invalidate_cache"] == true {
+		return bc.invalidateCache(), nil
+	}
+	if cmd["freeze"] == true {
+		return bc.freeze(true), nil
+	}
+	if cmd["unfreeze"] == true {
+		return bc.freeze(false), nil
+	}
+	if cmd["events"] == true {
+		return bc.eventList(), nil
+	}
+	if cmd["debug_image"] == true {
+		theme, _ := cmd["theme"].(string)
+		return bc.debugImage(ctx, theme)
+	}
+	if cmd["square_clouds"] == true {
+		tile, _ := cmd["tile"].(float64)
+		dir, _ := cmd["dir"].(string)
+		return bc.squareClouds(ctx, int(tile), dir)
+	}
+	if dir, ok := cmd["export_scan"].(string); ok {
+		return bc.exportScan(ctx, dir)
+	}
+	if cmd["sample_squares"] == true {
+		return bc.sampleSquares(ctx)
+	}
+	if cmd["suggest_thresholds"] == true {
+		return bc.suggestThresholds(ctx)
+	}
+	if cmd["calibrate_colors"] == true {
+		return bc.calibrateColors(ctx)
+	}
+	if cmd["calibrate_pieces"] == true {
+		return bc.calibratePieces(ctx)
+	}
+	if cmd["calibrate_board"] == true {
+		return bc.calibrateBoard(ctx)
+	}
+	if cmd["learn_piece_colors"] == true {
+		return bc.learnPieceColors(ctx)
+	}
+	if cmd["diff_from_last"] == true {
+		minConfidence, _ := cmd["min_confidence"].(float64)
+		return bc.diffFromLast(ctx, minConfidence)
+	}
+	if corners, ok := cmd["score_corners"]; ok {
+		return bc.scoreCorners(ctx, corners)
+	}
+	if corners, ok := cmd["set_corners"]; ok {
+		return bc.setCorners(corners)
+	}
+	if cmd["save_corners"] == true {
+		return bc.saveCorners(ctx)
+	}
 	return nil, fmt.Errorf("DoCommand not supported")
 }
 
-func (bc *PieceFinder) Name() resource.Name {
-	return bc.name
+// status is whether the last capture was analyzed, and if not why.
+func (bc *PieceFinder) status() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	if bc.lastErr == nil {
+		return map[string]interface{}{"ok": true}
+	}
+	return map[string]interface{}{
+		"ok":    false,
+		"error": bc.lastErr.err.Error(),
+		"time":  bc.lastErr.when.Format(time.RFC3339),
+	}
+}
+
+// visibility is {"visible": true}, what IsBoardVisible makes of the current frame, and the score
+// the last capture got if it was checked.
+func (bc *PieceFinder) visibility(ctx context.Context) (map[string]interface{}, error) {
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+	score := boardVisibleScore(img, opts.MinExposure)
+	minScore := bc.conf.minVisibleScore()
+	ret := map[string]interface{}{
+		"visible":   score >= max(0, minScore),
+		"score":     score,
+		"min_score": minScore,
+	}
+
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+	if bc.visible != nil {
+		ret["last_capture_score"] = *bc.visible
+	}
+	return ret, nil
+}
+
+// smoothedCorners are the corners captures are using, the last detection, and how many frames
+// in a row a jump away from them has been seen.
+func (bc *PieceFinder) smoothedCorners() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	points := func(ps []r2.Point) []interface{} {
+		res := []interface{}{}
+		for _, p := range ps {
+			res = append(res, []interface{}{p.X, p.Y})
+		}
+		return res
+	}
+	return map[string]interface{}{
+		"corners": points(bc.corners.avg),
+		"raw":     points(bc.corners.raw),
+		"pending": bc.corners.count,
+	}
 }
 
-func (bc *PieceFinder) DetectionsFromCamera(ctx context.Context, cameraName string, extra map[string]interface{}) ([]objectdetection.Detection, error) {
-	return nil, fmt.Errorf("DetectionsFromCamera not implemented")
+// activeCorners is {"get_corners": true}, the corners captures are using and where they came
+// from: "pinned" by the corners config or set_corners, or "detected", with the time and
+// confidence of the last detection and whether they're frozen. No corners before the first one.
+func (bc *PieceFinder) activeCorners() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	if bc.pinned != nil {
+		return map[string]interface{}{"corners": cornersJSON(bc.pinned), "source": "pinned"}
+	}
+	ret := map[string]interface{}{
+		"corners":    cornersJSON(roundedCorners(bc.corners.avg)),
+		"source":     "detected",
+		"frozen":     bc.corners.frozen,
+		"cached":     bc.corners.cached(),
+		"detections": bc.corners.detections,
+	}
+	if !bc.corners.when.IsZero() {
+		confidence := []interface{}{}
+		for _, c := range bc.corners.confidence {
+			confidence = append(confidence, c)
+		}
+		ret["time"] = bc.corners.when.Format(time.RFC3339Nano)
+		ret["confidence"] = confidence
+	}
+	return ret
 }
 
-func (bc *PieceFinder) Detections(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objectdetection.Detection, error) {
-	return nil, fmt.Errorf("Detections not implemented")
+// refresh is {"refresh": true}, forgetting the corners so the next capture looks for the board
+// again and takes what it finds as it is, frozen or not, like after the board was bumped.
+func (bc *PieceFinder) refresh() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	bc.corners.reset()
+	bc.shift = shiftWatch{}
+	bc.last = nil
+	return map[string]interface{}{"refresh": true}
 }
 
-func (bc *PieceFinder) ClassificationsFromCamera(ctx context.Context, cameraName string, n int, extra map[string]interface{}) (classification.Classifications, error) {
-	return nil, fmt.Errorf("ClassificationsFromCamera not implemented")
+// invalidateCache is {"invalidate_cache": true}, having the next capture look for the board and
+// analyze the frame again, without forgetting the corners it already has.
+func (bc *PieceFinder) invalidateCache() map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	bc.corners.invalidate()
+	bc.last = nil
+	return map[string]interface{}{"invalidated": true}
 }
 
-func (bc *PieceFinder) Classifications(ctx context.Context, img image.Image, n int, extra map[string]interface{}) (classification.Classifications, error) {
-	return nil, fmt.Errorf("Classifications not implemented")
+// freeze is {"freeze": true} and {"unfreeze": true}, whether captures stop looking for the board
+// and keep the corners they have, until unfrozen or the next reconfigure.
+func (bc *PieceFinder) freeze(frozen bool) map[string]interface{} {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	bc.corners.frozen = frozen
+	return map[string]interface{}{"frozen": frozen}
 }
 
-func (bc *PieceFinder) GetObjectPointClouds(ctx context.Context, cameraName string, extra map[string]interface{}) ([]*viz.Object, error) {
-	ret, err := bc.CaptureAllFromCamera(ctx, cameraName, viscapture.CaptureOptions{}, extra)
+func (bc *PieceFinder) currentImage(ctx context.Context) (image.Image, error) {
+	ni, _, err := bc.input.Images(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(ni) == 0 {
+		return nil, fmt.Errorf("no images returned from input camera")
+	}
+	return ni[0].Image(ctx)
+}
+
+// debugBoard runs the board finder on the current frame and returns its debug images as base64 PNGs.
+func (bc *PieceFinder) debugBoard(ctx context.Context) (map[string]interface{}, error) {
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	res, dbg, err := FindBoardDebugWithOptions(img, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := dbg.EncodeImages()
 	if err != nil {
 		return nil, err
 	}
-	return ret.Objects, nil
+
+	corners := []interface{}{}
+	for _, c := range res.Corners {
+		corners = append(corners, []interface{}{c.X, c.Y})
+	}
+
+	confidence := []interface{}{}
+	for _, c := range res.CornerConfidence {
+		confidence = append(confidence, c)
+	}
+
+	ret := map[string]interface{}{
+		"found":             res.Found,
+		"reason":            res.Reason,
+		"corners":           corners,
+		"corner_confidence": confidence,
+		"images":            images,
+	}
+	if res.Err != nil {
+		ret["error"] = res.Err.Error()
+	}
+	return ret, nil
+}
+
+// scoreCorners is {"score_corners": [[x, y], ...]}, how well the TL, TR, BR, BL corners given fit
+// the board in the current frame, see ScoreCorners.
+func (bc *PieceFinder) scoreCorners(ctx context.Context, arg interface{}) (map[string]interface{}, error) {
+	corners, err := parseCorners("score_corners", arg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	score, err := scoreCorners(img, corners, opts.gridSize())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"score": score}, nil
+}
+
+// density analyzes the current frame and returns a heat map of the cloud per square as a
+// base64 PNG, mode is "points" for the point count or "height" for how far it sticks up.
+func (bc *PieceFinder) density(ctx context.Context, mode string) (map[string]interface{}, error) {
+	_, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("density", squares); err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	var unit string
+	switch mode {
+	case "", "points":
+		values, unit = squarePointCounts(squares), "points"
+	case "height":
+		values, unit = squareHeights(squares), "mm"
+	default:
+		return nil, fmt.Errorf("unknown density mode %q, needs to be points or height", mode)
+	}
+
+	heatMap, lo, hi := renderDensity(squares, values, unit)
+	encoded, err := encodePNG(heatMap)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]interface{}{
+		"density": encoded,
+		"min":     lo,
+		"max":     hi,
+	}
+	if mode == "height" {
+		ret["suggested_piece_scale"] = suggestPieceScale(values)
+	}
+	return ret, nil
+}
+
+func (bc *PieceFinder) Name() resource.Name {
+	return bc.name
+}
+
+// errBoardNotVisible is when IsBoardVisible doesn't think there's a board in the frame.
+var errBoardNotVisible = errors.New("no board in view")
+
+// checkVisible errors with errBoardNotVisible when img scores under min-visible-score, and
+// remembers the score for status.
+func (bc *PieceFinder) checkVisible(img image.Image) error {
+	bc.visible = nil
+	minScore := bc.conf.minVisibleScore()
+	if minScore < 0 {
+		return nil
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return err
+	}
+	score := boardVisibleScore(img, opts.MinExposure)
+	bc.visible = &score
+	if score < minScore {
+		return fmt.Errorf("%w (score %.2f)", errBoardNotVisible, score)
+	}
+	return nil
+}
+
+// servePrevious is ret with what opts asks for of prev's analysis, still the best there is while
+// the board can't be seen for the reason why, flagged in Extra. After max-age captures it's too
+// old to act on and the capture fails with errBoardOccluded.
+func (bc *PieceFinder) servePrevious(ctx context.Context, ret viscapture.VisCapture, prev *lastAnalysis, why string, opts viscapture.CaptureOptions) (viscapture.VisCapture, error) {
+	bc.last = prev
+	if prev.age >= bc.conf.maxAge() {
+		return bc.failedCapture(ret, fmt.Errorf("%w: %s for %d captures", errBoardOccluded, why, prev.age))
+	}
+	bc.last.age++
+	ret.Image = prev.image
+	if err := bc.serveLast(ctx, &ret, prev, opts); err != nil {
+		return ret, err
+	}
+	ret.Extra = map[string]interface{}{"unchanged": true, why: true, "age": prev.age, "source": squaresSource(prev.squares)}
+	bc.addWorldPoses(ret.Extra, prev)
+	addAnomalies(ret.Extra, prev.squares)
+	bc.addGraveyard(ret.Extra, prev.graveyard)
+	return ret, nil
 }
 
 func (bc *PieceFinder) CaptureAllFromCamera(ctx context.Context, cameraName string, opts viscapture.CaptureOptions, extra map[string]interface{}) (viscapture.VisCapture, error) {
+	return bc.captureWith(ctx, opts, extra, nil)
+}
+
+// captureWith is CaptureAllFromCamera, then use, when it's set, of the analysis the capture
+// served, nil for an error frame (see failedCapture), with captureLock still held so another
+// capture can't have replaced it.
+func (bc *PieceFinder) captureWith(ctx context.Context, opts viscapture.CaptureOptions, extra map[string]interface{},
+	use func(ret viscapture.VisCapture, served *lastAnalysis) error,
+) (viscapture.VisCapture, error) {
 	ctx, span := trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera")
 	defer span.End()
 
@@ -297,9 +1302,14 @@ func (bc *PieceFinder) CaptureAllFromCamera(ctx context.Context, cameraName stri
 	_, span2 = trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::NextPointCloud")
 	pc, err := bc.input.NextPointCloud(ctx, extra)
 	span2.End()
-	if err != nil {
+	if err != nil && ctx.Err() != nil {
 		return ret, err
 	}
+	if err != nil {
+		// the image alone is still worth something, see squaresFromImage
+		bc.logger.Warnf("no point cloud, going by the image alone: %v", err)
+		pc = nil
+	}
 
 	if len(ni) == 0 {
 		return ret, fmt.Errorf("no images returned from input camera")
@@ -312,71 +1322,160 @@ func (bc *PieceFinder) CaptureAllFromCamera(ctx context.Context, cameraName stri
 		return ret, err
 	}
 
-	_, span2 = trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::findBoardAndPieces")
-	squares, err := findBoardAndPieces(ret.Image, pc, bc.props)
-	span2.End()
-	if err != nil {
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+
+	ret, err = bc.analyze(ctx, ret, pc, opts, extra)
+	if err != nil || use == nil {
 		return ret, err
 	}
+	served := bc.last
+	if ret.Extra["unprocessed"] == true {
+		// an error frame, see failedCapture, with nothing in it
+		served = nil
+	}
+	return ret, use(ret, served)
+}
 
-	_, span2 = trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::Finish")
-	defer span2.End()
-
-	ret.Objects = []*viz.Object{}
-	ret.Detections = []objectdetection.Detection{}
-
-	for _, s := range squares {
-		pc, err := bc.rfs.TransformPointCloud(ctx, s.pc, bc.conf.Input, "world")
-		if err != nil {
+// analyze is ret with what's on the board in its image and pc, bc.last the analysis it served.
+// Has to be called with captureLock held.
+func (bc *PieceFinder) analyze(ctx context.Context, ret viscapture.VisCapture, pc pointcloud.PointCloud,
+	opts viscapture.CaptureOptions, extra map[string]interface{},
+) (viscapture.VisCapture, error) {
+	if bc.reuseLast(ret.Image, pc) {
+		bc.last.age++
+		if err := bc.serveLast(ctx, &ret, bc.last, opts); err != nil {
 			return ret, err
 		}
+		ret.Extra = map[string]interface{}{"unchanged": true, "age": bc.last.age, "source": squaresSource(bc.last.squares)}
+		bc.addWorldPoses(ret.Extra, bc.last)
+		bc.addPlacement(ret.Extra, bc.last.squares)
+		addAnomalies(ret.Extra, bc.last.squares)
+		bc.addGraveyard(ret.Extra, bc.last.graveyard)
+		return ret, nil
+	}
+	prev := bc.last
+	bc.last = nil
 
-		if pc == nil {
-			return ret, fmt.Errorf("why is pc nil")
+	// the arm parked over the board between games shouldn't cost a full search every frame
+	if err := bc.checkVisible(ret.Image); err != nil {
+		if prev != nil {
+			return bc.servePrevious(ctx, ret, prev, "not_visible", opts)
 		}
+		return bc.failedCapture(ret, err)
+	}
 
-		label := fmt.Sprintf("%s-%d", s.name, s.color)
-		o, err := viz.NewObjectWithLabel(pc, label, nil)
+	_, span2 := trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::findBoardAndPieces")
+	conf := bc.captureConf()
+	if corners := bc.requestCorners(extra["corners"], ret.Image); corners != nil {
+		conf.Corners = corners
+	}
+	bc.checkShift(ret.Image, conf)
+	// the next capture is compared with the cloud as the camera took it
+	raw, excluded := pc, 0
+	var err error
+	if names := excludeFrameNames(bc.conf, extra); len(names) > 0 && pc != nil {
+		pc, excluded, err = bc.excludeFrames(ctx, pc, names)
 		if err != nil {
+			bc.last = prev
 			return ret, err
 		}
-
-		if o.Geometry == nil {
-			return ret, fmt.Errorf("why is Geometry nil for square: %s %v", s.name, s)
+	}
+	bc.squares, err = findBoardAndPiecesInto(ctx, bc.logger, bc.squares, &bc.corners, &bc.undistort, ret.Image, pc, bc.props, conf)
+	span2.End()
+	if errors.Is(err, errBoardOccluded) && prev != nil {
+		// whatever is in the way will move, until then the last good analysis is the best there is
+		return bc.servePrevious(ctx, ret, prev, "occluded", opts)
+	}
+	if err != nil && ctx.Err() != nil {
+		// the caller gave up, the board's as good as it was for the next one
+		bc.last = prev
+		return ret, err
+	}
+	if err != nil {
+		return bc.failedCapture(ret, err)
+	}
+	if len(bc.sides) > 0 && squaresSource(bc.squares) == sourceDepth {
+		_, span2 = trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::fuseSides")
+		err = bc.fuseSides(ctx, conf)
+		span2.End()
+		if err != nil {
+			bc.last = prev
+			return ret, err
 		}
-		ret.Objects = append(ret.Objects, o)
-
-		ret.Detections = append(ret.Detections, objectdetection.NewDetectionWithoutImgBounds(s.originalBounds, 1, label))
+	}
 
-		lowPoint := touch.PCFindLowestInRegion(s.pc, image.Rect(-10000, -10000, 10000, 10000))
+	_, span2 = trace.StartSpan(ctx, "PieceFinder::CaptureAllFromCamera::Finish")
+	defer span2.End()
 
-		lowX, lowY := bc.props.IntrinsicParams.PointToPixel(lowPoint.X, lowPoint.Y, lowPoint.Z)
+	var graveyard []graveyardPiece
+	if len(conf.Graveyards) > 0 && pc != nil && pc.Size() > 0 {
+		graveyard, err = bc.findGraveyard(ctx, pc, squaresSurface(bc.squares), conf)
+		if err != nil && ctx.Err() != nil {
+			bc.last = prev
+			return ret, err
+		}
+		if err != nil {
+			// the graveyards are extra, the board is still worth having
+			bc.logger.Warnf("can't look in the graveyards: %v", err)
+			graveyard = nil
+		}
+	}
 
-		ret.Detections = append(ret.Detections,
-			objectdetection.NewDetectionWithoutImgBounds(
-				image.Rect(
-					int(lowX-5),
-					int(lowY-5),
-					int(lowX+5),
-					int(lowY+5),
-				),
-				1, "x-"+label))
+	// everything put in ret is newly allocated, bc.squares is not handed out
+	bc.last = &lastAnalysis{
+		sig:       computeFrameSignature(ret.Image, raw, boardRegion(bc.squares)),
+		image:     ret.Image,
+		squares:   append([]squareInfo(nil), bc.squares...),
+		graveyard: graveyard,
+	}
+	if err := bc.serveLast(ctx, &ret, bc.last, opts); err != nil {
+		bc.last = nil
+		return ret, err
 	}
+	bc.lastErr = nil
+	logSpeckle(bc.logger, bc.squares)
+	ret.Extra = map[string]interface{}{"unchanged": false, "age": 0, "source": squaresSource(bc.squares)}
+	bc.addWorldPoses(ret.Extra, bc.last)
+	if excluded > 0 {
+		ret.Extra["excluded_points"] = excluded
+	}
+	bc.addPlacement(ret.Extra, bc.squares)
+	addAnomalies(ret.Extra, bc.squares)
+	bc.addGraveyard(ret.Extra, graveyard)
 
 	return ret, nil
 }
 
+// reuseLast is true if the last analysis is young enough and the frame hasn't changed since.
+func (bc *PieceFinder) reuseLast(img image.Image, pc pointcloud.PointCloud) bool {
+	threshold := bc.conf.changeThreshold()
+	if bc.last == nil || threshold < 0 || bc.last.age >= bc.conf.maxAge() {
+		return false
+	}
+	sig := computeFrameSignature(img, pc, bc.last.sig.region)
+	return !bc.last.sig.changed(&sig, threshold)
+}
+
+// GetProperties is what the piece finder can do: classify and detect the pieces always, from the
+// frame alone when it has to (see squaresFromImage), and give their point clouds only when the
+// input has point clouds to give.
 func (bc *PieceFinder) GetProperties(ctx context.Context, extra map[string]interface{}) (*vision.Properties, error) {
 	return &vision.Properties{
-		ObjectPCDsSupported: true,
+		ClassificationSupported: true,
+		DetectionSupported:      true,
+		ObjectPCDsSupported:     bc.props.SupportsPCD,
 	}, nil
 }
 
-func createDebugImage(input image.Image, squares []squareInfo) (image.Image, error) {
-	// Create a copy of the input image to draw on
-	bounds := input.Bounds()
-	dst := image.NewRGBA(bounds)
-	draw.Draw(dst, bounds, input, image.Point{}, draw.Src)
+// createDebugImage is input drawn with theme, see renderTheme, with each square outlined and
+// labeled with its piece color at labelScale's scale for scale, and the scan of squares it
+// shows, untimed.
+func createDebugImage(input image.Image, squares []squareInfo, theme string, baseline []color.RGBA, scale int) (image.Image, boardScan, error) {
+	dst, err := renderTheme(input, squares, theme, baseline)
+	if err != nil {
+		return nil, boardScan{}, err
+	}
 
 	// Draw debug info for each square
 	for _, sq := range squares {
@@ -388,19 +1487,12 @@ func createDebugImage(input image.Image, squares []squareInfo) (image.Image, err
 		pieceLabel := colorNames[sq.color]
 		text := fmt.Sprintf("%s-%s", sq.name, pieceLabel)
 
-		// Calculate center of the square for text placement
-		centerX := (sq.originalBounds.Min.X + sq.originalBounds.Max.X) / 2
-		centerY := (sq.originalBounds.Min.Y + sq.originalBounds.Max.Y) / 2
-
-		// Adjust position to center the text (roughly)
-		textX := centerX - len(text)*3
-		textY := centerY + 3
-
-		// Draw the text
-		drawString(dst, textX, textY, text, color.RGBA{255, 0, 0, 255})
+		// Draw it in the middle of the square
+		center := image.Pt((sq.originalBounds.Min.X+sq.originalBounds.Max.X)/2, (sq.originalBounds.Min.Y+sq.originalBounds.Max.Y)/2)
+		drawLabel(dst, center, text, labelScale(sq.originalBounds, len(text), scale), color.RGBA{255, 0, 0, 255})
 	}
 
-	return dst, nil
+	return dst, newBoardScan(squares, time.Now(), 0, true), nil
 }
 
 // drawRect draws a rectangle outline on the image
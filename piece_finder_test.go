@@ -1,13 +1,20 @@
 package viamchess
 
 import (
+	"context"
 	"image"
+	"math"
 	"os"
+	"runtime"
 	"testing"
 
 	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/vision/viscapture"
 	"go.viam.com/test"
 
 	"github.com/erh/vmodutils/touch"
@@ -28,7 +35,7 @@ func TestComputeSquareBounds(t *testing.T) {
 		{0, 80},
 	}
 
-	res := computeSquareBounds(corners, 0, 0)
+	res := computeSquareBounds(corners, 0, 0, 8)
 	test.That(t, res.Min.X, test.ShouldEqual, 0)
 	test.That(t, res.Min.Y, test.ShouldEqual, 0)
 
@@ -42,16 +49,99 @@ func TestComputeSquareBounds(t *testing.T) {
 		{257, 680},
 	}
 
-	res = computeSquareBounds(corners, 0, 0)
+	res = computeSquareBounds(corners, 0, 0, 8)
 	test.That(t, res.Min.X, test.ShouldEqual, 360)
 	test.That(t, res.Min.Y, test.ShouldEqual, 3)
 
-	res = computeSquareBounds(corners, 0, 6)
+	res = computeSquareBounds(corners, 0, 6, 8)
 	test.That(t, res.Min.X, test.ShouldEqual, 283)
 	test.That(t, res.Min.Y, test.ShouldEqual, 510)
 
 }
 
+func TestComputeSquareBoundsGrid10(t *testing.T) {
+	// a draughts board, found and split into its 100 squares
+	img, expected := renderCheckerboard(640, 480, 320.3, 241.7, 400.6, 0.015, 10)
+
+	opts := DefaultBoardFinderOptions()
+	opts.GridSize = 10
+	found, err := findBoardSubPixel(img, opts)
+	test.That(t, err, test.ShouldBeNil)
+	for i, c := range found {
+		t.Logf("corner %d expected %v got %v", i, expected[i], c)
+		test.That(t, c.Sub(expected[i]).Norm(), test.ShouldBeLessThan, 1)
+	}
+	corners := roundCorners(found)
+
+	for row := range 10 {
+		for col := range 10 {
+			cell := computeSquareBounds(corners, col, row, 10)
+			inner := image.Rect(cell.Min.X+cell.Dx()/4, cell.Min.Y+cell.Dy()/4, cell.Max.X-cell.Dx()/4, cell.Max.Y-cell.Dy()/4)
+			light := img.RGBAAt(inner.Min.X, inner.Min.Y).R > 128 && img.RGBAAt(inner.Max.X, inner.Max.Y).R > 128
+			dark := img.RGBAAt(inner.Min.X, inner.Min.Y).R < 128 && img.RGBAAt(inner.Max.X, inner.Max.Y).R < 128
+			test.That(t, light, test.ShouldEqual, (row+col)%2 == 0)
+			test.That(t, dark, test.ShouldEqual, (row+col)%2 == 1)
+		}
+	}
+	test.That(t, computeSquareBounds(corners, 9, 9, 10).Max, test.ShouldResemble, corners[2].Add(image.Pt(1, 1)))
+
+	names := makeSquareNames(10)
+	test.That(t, len(names), test.ShouldEqual, 100)
+	test.That(t, names[squareIndex('j', 10, 10)], test.ShouldEqual, "j10")
+	test.That(t, names[squareIndex('i', 1, 10)], test.ShouldEqual, "i1")
+	col, row := gridPosition('a', 1, Rotation0, 10)
+	test.That(t, []int{col, row}, test.ShouldResemble, []int{9, 0})
+
+	_, err = BoardFinderOptionsFromMap(map[string]interface{}{"grid-size": 30})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", Rotation: "auto", BoardOptions: map[string]interface{}{"grid-size": 10}}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSquareMargin(t *testing.T) {
+	ip := touch.RealSenseProperties.IntrinsicParams
+	corners := []image.Point{{400, 150}, {880, 150}, {880, 630}, {400, 630}}
+	full := computeSquareBounds(corners, 4, 3, 8)
+	test.That(t, computeSquareClassificationBounds(corners, 4, 3, 8, defaultSquareMargin), test.ShouldResemble, full.Inset(5))
+	test.That(t, computeSquareClassificationBounds(corners, 4, 3, 8, 0), test.ShouldResemble, full)
+
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := boxesBoard(t)
+	// e4 is (580, 330) to (640, 390), on the board's surface 2% and 12% of it in from d4
+	at := func(u int) r3.Vector {
+		x, y := (float64(u)-ip.Ppx)/ip.Fx, (360-ip.Ppy)/ip.Fy
+		return r3.Vector{X: x * 600, Y: y * 600, Z: 600}
+	}
+	nearEdge, inside := at(638), at(632)
+	e4 := func(margin float64) pointcloud.PointCloud {
+		conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, SquareMargin: margin}
+		squares, err := findBoardAndPieces(frame, pc, touch.RealSenseProperties, conf)
+		test.That(t, err, test.ShouldBeNil)
+		for _, sq := range squares {
+			if sq.name == "e4" {
+				return sq.pc
+			}
+		}
+		t.Fatal("no e4")
+		return nil
+	}
+
+	classified := e4(0)
+	_, got := classified.At(nearEdge.X, nearEdge.Y, nearEdge.Z)
+	test.That(t, got, test.ShouldBeFalse)
+	_, got = classified.At(inside.X, inside.Y, inside.Z)
+	test.That(t, got, test.ShouldBeTrue)
+
+	whole := e4(-1)
+	_, got = whole.At(nearEdge.X, nearEdge.Y, nearEdge.Z)
+	test.That(t, got, test.ShouldBeTrue)
+	_, got = whole.At(inside.X, inside.Y, inside.Z)
+	test.That(t, got, test.ShouldBeTrue)
+
+	_, _, err := (&PieceFinderConfig{Input: "cam", Rotation: "0", SquareMargin: .5}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
 func testBoardPiece(t *testing.T, boardName string) {
 	// Read the input image
 	imageFile := "data/" + boardName + ".jpg"
@@ -63,42 +153,28 @@ func testBoardPiece(t *testing.T, boardName string) {
 	pc, err := pointcloud.NewFromFile(pcdFile, "")
 	test.That(t, err, test.ShouldBeNil)
 
-	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
 	test.That(t, err, test.ShouldBeNil)
 
 	// Create debug image with square labels
-	out, err := createDebugImage(input, squares)
+	out, scan, err := createDebugImage(input, squares, themeImage, nil, 0)
 	test.That(t, err, test.ShouldBeNil)
 
 	// Save the output image for inspection
 	outputFile := "data/" + boardName + "_piece_test_output.jpg"
 	err = rimage.WriteImageToFile(outputFile, out)
 	test.That(t, err, test.ShouldBeNil)
-	t.Logf("Saved output image to %s", outputFile)
-
-	// Verify we have 64 squares
-	test.That(t, len(squares), test.ShouldEqual, 64)
 
-	// Verify every square has a valid (non-empty) pointcloud
-	emptySquares := []string{}
-	for _, sq := range squares {
-		if sq.pc == nil || sq.pc.Size() == 0 {
-			emptySquares = append(emptySquares, sq.name)
-		}
-	}
-
-	if len(emptySquares) > 0 {
-		t.Errorf("Found %d squares with empty pointclouds: %v", len(emptySquares), emptySquares)
-	}
-
-	// Log square info for debugging
-	for _, sq := range squares {
-		pcSize := 0
-		if sq.pc != nil {
-			pcSize = sq.pc.Size()
-		}
-		t.Logf("Square %s: color=%d, pc_size=%d", sq.name, sq.color, pcSize)
+	// Verify we have 64 squares, each with a valid (non-empty) pointcloud
+	test.That(t, len(scan.squares), test.ShouldEqual, 64)
+	for i, sq := range scan.squares {
+		test.That(t, sq.Name, test.ShouldEqual, squares[i].name)
+		test.That(t, sq.Color, test.ShouldEqual, squares[i].color)
+		test.That(t, sq.PointCount, test.ShouldBeGreaterThan, 0)
 	}
+	colors, points := scan.counts()
+	test.That(t, colors[0]+colors[1]+colors[2], test.ShouldEqual, 64)
+	test.That(t, points, test.ShouldBeGreaterThan, 64)
 }
 
 func TestBoardPiece4(t *testing.T) {
@@ -118,7 +194,7 @@ func TestBoard13E2Pointcloud(t *testing.T) {
 	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
 	test.That(t, err, test.ShouldBeNil)
 
-	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
 	test.That(t, err, test.ShouldBeNil)
 
 	// Find the e2 square
@@ -165,3 +241,285 @@ func TestBoard13E2Pointcloud(t *testing.T) {
 
 	t.Log("Saved complete e2 pointcloud to data/board13_e2.pcd")
 }
+
+func TestSquareNames(t *testing.T) {
+	test.That(t, squareNames[squareIndex('a', 1, 8)], test.ShouldEqual, "a1")
+	test.That(t, squareNames[squareIndex('e', 2, 8)], test.ShouldEqual, "e2")
+	test.That(t, squareNames[squareIndex('h', 8, 8)], test.ShouldEqual, "h8")
+}
+
+func TestLabelCacheSteadyState(t *testing.T) {
+	lc := labelCache{}
+	test.That(t, lc.label(squareIndex('e', 2, 8), "e2", 1), test.ShouldEqual, "e2-1")
+	test.That(t, lc.label(squareIndex('e', 2, 8), "e2", 0), test.ShouldEqual, "e2-0")
+
+	colors := [64]int{}
+	for i := range colors {
+		colors[i] = i % 3
+	}
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		for i, c := range colors {
+			lc.label(i, squareNames[i], c)
+		}
+	})
+	test.That(t, allocs, test.ShouldEqual, 0)
+}
+
+// soakEnv set to anything makes TestCaptureSoak run its full 1000 captures, not 100.
+const soakEnv = "VIAM_CHESS_SOAK"
+
+func TestCaptureSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("a soak isn't short")
+	}
+	captures := 100
+	if os.Getenv(soakEnv) != "" {
+		captures = 1000
+	}
+	ctx := context.Background()
+
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	full, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// two clouds taking turns, a few percent of board4's points each so a soak doesn't take all
+	// day, every one a new frame to analyze
+	clouds := []pointcloud.PointCloud{pointcloud.NewBasicEmpty(), pointcloud.NewBasicEmpty()}
+	i := 0
+	full.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if i%64 < len(clouds) {
+			err = clouds[i%64].Set(p, d)
+		}
+		i++
+		return err == nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	conf := &PieceFinderConfig{Input: "cam", MinVisibleScore: -1, ChangeThreshold: -1}
+	for _, c := range corners {
+		conf.Corners = append(conf.Corners, []int{c.X, c.Y})
+	}
+	frame := image.Image(input)
+	pc := clouds[0]
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	var before, after runtime.MemStats
+	for n := range captures {
+		pc = clouds[n%len(clouds)]
+		ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+		// what's reused between frames is all there after the first few
+		if n == 19 {
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+		}
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	growth := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	t.Logf("heap growth over %d captures: %d", captures-20, growth)
+	test.That(t, growth, test.ShouldBeLessThan, 1<<20)
+}
+
+func BenchmarkFindBoardAndPiecesStreaming(b *testing.B) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(b, err, test.ShouldBeNil)
+
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(b, err, test.ShouldBeNil)
+
+	conf := &PieceFinderConfig{}
+	var squares []squareInfo
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		squares, err = findBoardAndPiecesInto(context.Background(), logging.NewTestLogger(b), squares, nil, nil, input, pc, touch.RealSenseProperties, conf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSquareCellBoundaries(t *testing.T) {
+	board := BoardCorners{
+		TopLeft:     image.Point{0, 0},
+		TopRight:    image.Point{80, 0},
+		BottomRight: image.Point{80, 80},
+		BottomLeft:  image.Point{0, 80},
+	}
+
+	owner := func(x int) []int {
+		cols := []int{}
+		for col := 0; col < 8; col++ {
+			if (image.Point{x, 5}).In(board.squareCell(col, 0, 8)) {
+				cols = append(cols, col)
+			}
+		}
+		return cols
+	}
+	test.That(t, owner(0), test.ShouldResemble, []int{0})
+	test.That(t, owner(9), test.ShouldResemble, []int{0})
+	test.That(t, owner(10), test.ShouldResemble, []int{1})
+	test.That(t, owner(79), test.ShouldResemble, []int{7})
+	test.That(t, owner(80), test.ShouldResemble, []int{7})
+	test.That(t, owner(81), test.ShouldResemble, []int{})
+
+	// the same rule down the ranks
+	test.That(t, (image.Point{5, 80}).In(board.squareCell(0, 7, 8)), test.ShouldBeTrue)
+	test.That(t, (image.Point{5, 70}).In(board.squareCell(0, 6, 8)), test.ShouldBeFalse)
+}
+
+func TestSquareCloudsBoundaries(t *testing.T) {
+	props := camera.Properties{
+		IntrinsicParams: &transform.PinholeCameraIntrinsics{Width: 100, Height: 100, Fx: 1, Fy: 1},
+	}
+	board := BoardCorners{
+		TopLeft:     image.Point{0, 0},
+		TopRight:    image.Point{80, 0},
+		BottomRight: image.Point{80, 80},
+		BottomLeft:  image.Point{0, 80},
+	}
+	rects := []image.Rectangle{}
+	for col := 0; col < 8; col++ {
+		rects = append(rects, board.squareCell(col, 0, 8))
+	}
+
+	pc := pointcloud.NewBasicEmpty()
+	for _, x := range []float64{0, 10, 10 - 1e-9, 80 - 1e-9, 80, -.5} {
+		test.That(t, pc.Set(r3.Vector{X: x, Y: 5, Z: 1}, nil), test.ShouldBeNil)
+	}
+
+	clouds, err := squareClouds(pc, rects, props)
+	test.That(t, err, test.ShouldBeNil)
+	sizes := []int{}
+	for _, c := range clouds {
+		sizes = append(sizes, c.Size())
+	}
+	// 0 and just under 10 in a, 10 in b, just under 80 and 80 in h, -.5 nowhere
+	test.That(t, sizes, test.ShouldResemble, []int{2, 1, 0, 0, 0, 0, 0, 2})
+}
+
+// squareCloudsLinear is squareClouds the way it was, every point tried against each of rects in
+// turn, for what squareClouds has to match and how much faster it is.
+func squareCloudsLinear(pc pointcloud.PointCloud, rects []image.Rectangle, props camera.Properties) []pointcloud.PointCloud {
+	out := make([]pointcloud.PointCloud, len(rects))
+	for i := range out {
+		out[i] = pointcloud.NewBasicEmpty()
+	}
+	ip := props.IntrinsicParams
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if p.Z == 0 {
+			return true
+		}
+		px := image.Point{X: int(math.Floor(p.X/p.Z*ip.Fx + ip.Ppx)), Y: int(math.Floor(p.Y/p.Z*ip.Fy + ip.Ppy))}
+		for i, r := range rects {
+			if px.In(r) {
+				_ = out[i].Set(p, d)
+				break
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// board4Rects is the board4 frame's point cloud and its squares' rects.
+func board4Rects(t testing.TB) (pointcloud.PointCloud, []image.Rectangle) {
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+	rects := make([]image.Rectangle, len(squares))
+	for i, sq := range squares {
+		rects[i] = sq.originalBounds
+	}
+	return pc, rects
+}
+
+func TestSquareCloudsMatchLinear(t *testing.T) {
+	pc, rects := board4Rects(t)
+	// and a shaky corner's square, widened over its neighbors
+	rects[0] = rects[0].Inset(-shakyCornerMargin)
+
+	clouds, err := squareClouds(pc, rects, touch.RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	want := squareCloudsLinear(pc, rects, touch.RealSenseProperties)
+	test.That(t, len(clouds), test.ShouldEqual, len(want))
+	for i, c := range clouds {
+		test.That(t, c.Size(), test.ShouldEqual, want[i].Size())
+		test.That(t, c.Size(), test.ShouldBeGreaterThan, 0)
+		want[i].Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			got, ok := c.At(p.X, p.Y, p.Z)
+			test.That(t, ok, test.ShouldBeTrue)
+			test.That(t, got, test.ShouldResemble, d)
+			return true
+		})
+	}
+}
+
+func BenchmarkSquareClouds(b *testing.B) {
+	pc, rects := board4Rects(b)
+	b.Run("linear", func(b *testing.B) {
+		for range b.N {
+			squareCloudsLinear(pc, rects, touch.RealSenseProperties)
+		}
+	})
+	b.Run("lookup", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := squareClouds(pc, rects, touch.RealSenseProperties); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// the second pass raisedPoints makes, over the whole squares; cheaper than keeping all the
+	// board's points from the first to go over again
+	cells, heights, surface := board4Cells(b, pc, rects)
+	b.Run("raised", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			raisedPoints(pc, cells, heights, surface, touch.RealSenseProperties, &PieceFinderConfig{})
+		}
+	})
+}
+
+// board4Cells is the whole squares round rects, board4Rects', how high a point of pc is over
+// each and the board under them.
+func board4Cells(t testing.TB, pc pointcloud.PointCloud, rects []image.Rectangle) ([]image.Rectangle, []func(r3.Vector) float64, boardSurface) {
+	clouds, err := squareClouds(pc, rects, touch.RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	surface := fitBoardSurface(clouds)
+	cells := make([]image.Rectangle, len(rects))
+	heights := make([]func(r3.Vector) float64, len(rects))
+	for i, r := range rects {
+		cells[i] = r.Inset(-10)
+		heights[i] = surface.heights(clouds[i])
+	}
+	return cells, heights, surface
+}
+
+func TestSquareCloudsCoverTheBoard(t *testing.T) {
+	pc, rects := board4Rects(t)
+	cells, _, _ := board4Cells(t, pc, rects)
+	// what raisedPoints goes over, each point in one square, the same as trying each in turn
+	clouds, err := squareClouds(pc, cells, touch.RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	inside, err := squareClouds(pc, rects, touch.RealSenseProperties)
+	test.That(t, err, test.ShouldBeNil)
+	want := squareCloudsLinear(pc, cells, touch.RealSenseProperties)
+	for i, c := range clouds {
+		test.That(t, c.Size(), test.ShouldEqual, want[i].Size())
+		// the lines round a square are more of it
+		test.That(t, c.Size(), test.ShouldBeGreaterThan, inside[i].Size())
+	}
+}
@@ -0,0 +1,125 @@
+package viamchess
+
+import (
+	"context"
+	"slices"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// pieceColorNames are estimatePieceColor's colors for an object's label.
+var pieceColorNames = []string{"empty", "white", "black"}
+
+// pieceExtent is the points of the piece on a square, see measurePiece, and where they are.
+type pieceExtent struct {
+	points   pointcloud.PointCloud
+	center   r3.Vector // the middle of their box
+	dims     r3.Vector // how big the box is each way
+	centroid r3.Vector // the mean of them
+}
+
+// measurePiece is the piece on sq, nil when the square's empty, was only seen in the image or
+// there aren't more than minPiecePoints points of it: the points more than footprintHeight above
+// surface, the same ones pieceShape goes by, boxed from the 1st to the 99th percentile of them each
+// way, in the camera's frame (mm).
+func measurePiece(sq squareInfo, surface boardSurface) (*pieceExtent, error) {
+	if sq.color == 0 || sq.pc == nil {
+		return nil, nil
+	}
+	height := surface.heights(sq.pc)
+	piece := pointcloud.NewBasicEmpty()
+	var xs, ys, zs []float64
+	var sum r3.Vector
+	var err error
+	sq.pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if height(p) <= footprintHeight {
+			return true
+		}
+		xs, ys, zs = append(xs, p.X), append(ys, p.Y), append(zs, p.Z)
+		sum = sum.Add(p)
+		err = piece.Set(p, d)
+		return err == nil
+	})
+	if err != nil || len(xs) <= minPiecePoints {
+		return nil, err
+	}
+
+	span := func(v []float64) (float64, float64) {
+		slices.Sort(v)
+		return v[len(v)/100], v[len(v)-1-len(v)/100]
+	}
+	x0, x1 := span(xs)
+	y0, y1 := span(ys)
+	z0, z1 := span(zs)
+	return &pieceExtent{
+		points: piece,
+		center: r3.Vector{X: (x0 + x1) / 2, Y: (y0 + y1) / 2, Z: (z0 + z1) / 2},
+		// a box has to have some size each way
+		dims:     r3.Vector{X: max(x1-x0, 1), Y: max(y1-y0, 1), Z: max(z1-z0, 1)},
+		centroid: sum.Mul(1 / float64(len(xs))),
+	}, nil
+}
+
+// pieceObject is the piece on sq as an object, nil when there isn't one (see measurePiece): its
+// points in their box, in the camera's frame (mm), labeled by pieceLabel.
+func pieceObject(sq squareInfo, surface boardSurface, minConfidence float64) (*viz.Object, error) {
+	piece, err := measurePiece(sq, surface)
+	if piece == nil || err != nil {
+		return nil, err
+	}
+	box, err := spatialmath.NewBox(spatialmath.NewPoseFromPoint(piece.center), piece.dims, pieceLabel(sq, minConfidence))
+	if err != nil {
+		return nil, err
+	}
+	return &viz.Object{PointCloud: piece.points, Geometry: box}, nil
+}
+
+// pieceObjects is a pieceObject for each of squares with a piece on it, typed when it's at least
+// minConfidence sure of the type.
+func pieceObjects(squares []squareInfo, minConfidence float64) ([]*viz.Object, error) {
+	objects := []*viz.Object{}
+	if imageOnly(squares) {
+		// no points to make them of
+		return objects, nil
+	}
+	surface := squaresSurface(squares)
+	for _, sq := range squares {
+		o, err := pieceObject(sq, surface, minConfidence)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			objects = append(objects, o)
+		}
+	}
+	return objects, nil
+}
+
+// GetObjectPointClouds is the pieces on the board, one object for each occupied square, see
+// pieceObject, from the same capture as CaptureAllFromCamera's, which has all the squares.
+func (bc *PieceFinder) GetObjectPointClouds(ctx context.Context, cameraName string, extra map[string]interface{}) ([]*viz.Object, error) {
+	var objects []*viz.Object
+	_, err := bc.captureWith(ctx, viscapture.CaptureOptions{}, extra, func(_ viscapture.VisCapture, served *lastAnalysis) error {
+		if served == nil {
+			return nil
+		}
+		if served.pieces == nil {
+			pieces, err := pieceObjects(served.squares, bc.conf.minTypeConfidence())
+			if err != nil {
+				return err
+			}
+			served.pieces = pieces
+		}
+		objects = append([]*viz.Object(nil), served.pieces...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
@@ -0,0 +1,97 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestGetObjectPointClouds(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}, &frame, &pc)
+
+	// the capture still has every square, for the empty ones to move pieces to
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(all.Objects), test.ShouldEqual, 64)
+	occupied := map[string]string{}
+	for _, o := range all.Objects {
+		name, c, _ := strings.Cut(o.Geometry.Label(), "-")
+		if c != "0" {
+			occupied[name] = pieceColorNames[c[0]-'0']
+		}
+	}
+	test.That(t, len(occupied), test.ShouldEqual, 32)
+
+	objects, err := pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldEqual, len(occupied))
+	for _, o := range objects {
+		name, rest, ok := strings.Cut(o.Geometry.Label(), "-")
+		test.That(t, ok, test.ShouldBeTrue)
+		c, _, _ := strings.Cut(rest, "-")
+		test.That(t, occupied[name], test.ShouldEqual, c)
+		test.That(t, o.Size(), test.ShouldBeGreaterThan, minPiecePoints)
+	}
+
+	// white has played e4, so d2 is the pawn next to where e2's was
+	surface := squaresSurface(pf.last.squares)
+	test.That(t, surface.ok, test.ShouldBeTrue)
+	var d2 int
+	for i, o := range objects {
+		if strings.HasPrefix(o.Geometry.Label(), "d2-") {
+			d2 = i
+		}
+	}
+	test.That(t, objects[d2].Geometry.Label(), test.ShouldStartWith, "d2-white")
+	center := objects[d2].Geometry.Pose().Point()
+	test.That(t, surface.plane.height(center), test.ShouldBeGreaterThan, footprintHeight)
+	// a pawn's box is a pawn's size, in mm, with the points' center in it
+	dims := objects[d2].Geometry.ToProtobuf().GetBox().GetDimsMm()
+	for _, d := range []float64{dims.GetX(), dims.GetY(), dims.GetZ()} {
+		test.That(t, d, test.ShouldBeBetween, 5, 80)
+	}
+	md := objects[d2].MetaData()
+	mean := md.Center()
+	test.That(t, mean.X, test.ShouldBeBetween, center.X-dims.GetX()/2, center.X+dims.GetX()/2)
+	test.That(t, mean.Y, test.ShouldBeBetween, center.Y-dims.GetY()/2, center.Y+dims.GetY()/2)
+	test.That(t, mean.Z, test.ShouldBeBetween, center.Z-dims.GetZ()/2, center.Z+dims.GetZ()/2)
+}
+
+func TestGetObjectPointCloudsErrorFrame(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", MaxAge: 1, ErrorFrames: "passthrough"}, &frame, &pc)
+
+	objects, err := pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldBeGreaterThan, 0)
+
+	corners, err := findBoard(input)
+	test.That(t, err, test.ShouldBeNil)
+	frame = occludeHalf(input, corners)
+	objects, err = pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldBeGreaterThan, 0)
+
+	// the last analysis is kept to fall back on, but this capture is an error frame with no pieces
+	objects, err = pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, objects, test.ShouldBeNil)
+	test.That(t, pf.last, test.ShouldNotBeNil)
+}
@@ -0,0 +1,73 @@
+package viamchess
+
+import (
+	"context"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// cameraFrame is the input camera's frame in the frame system, the point cloud's frame.
+func (cfg *PieceFinderConfig) cameraFrame() string {
+	if cfg.CameraFrame == "" {
+		return cfg.Input
+	}
+	return cfg.CameraFrame
+}
+
+// piecePose is where a piece is: its box, see measurePiece, posed at its centroid.
+type piecePose struct {
+	pose spatialmath.Pose
+	dims r3.Vector
+}
+
+// piecePoses is where the piece on each of squares with one is, by index into squares, in the
+// world frame, and whether it is: when the frame system can't say where the camera is that's
+// logged and they're all in the camera's frame.
+func (bc *PieceFinder) piecePoses(ctx context.Context, squares []squareInfo) (map[int]piecePose, bool, error) {
+	poses := map[int]piecePose{}
+	if imageOnly(squares) {
+		return poses, false, nil
+	}
+	surface := squaresSurface(squares)
+	for i, sq := range squares {
+		piece, err := measurePiece(sq, surface)
+		if err != nil {
+			return nil, false, err
+		}
+		if piece != nil {
+			poses[i] = piecePose{spatialmath.NewPoseFromPoint(piece.centroid), piece.dims}
+		}
+	}
+	if len(poses) == 0 {
+		return poses, bc.rfs != nil, nil
+	}
+
+	if bc.rfs == nil {
+		bc.logger.Warnf("no frame system, the pieces' poses are in %s's frame", bc.conf.cameraFrame())
+		return poses, false, nil
+	}
+	world := make(map[int]piecePose, len(poses))
+	for i, p := range poses {
+		in, err := bc.rfs.TransformPose(ctx, referenceframe.NewPoseInFrame(bc.conf.cameraFrame(), p.pose), referenceframe.World, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, false, err
+			}
+			bc.logger.Warnf("the pieces' poses are in %s's frame, it isn't in the frame system: %v", bc.conf.cameraFrame(), err)
+			return poses, false, nil
+		}
+		world[i] = piecePose{in.Pose(), p.dims}
+	}
+	return world, true, nil
+}
+
+// addWorldPoses puts whether last's objects are posed in the world frame in a capture's extra as
+// "world_poses", when camera-frame asks for them to be.
+func (bc *PieceFinder) addWorldPoses(extra map[string]interface{}, last *lastAnalysis) {
+	if bc.conf.CameraFrame != "" {
+		extra["world_poses"] = last.worldPoses
+	}
+}
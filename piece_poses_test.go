@@ -0,0 +1,84 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func objectOn(t *testing.T, all viscapture.VisCapture, name string) *viz.Object {
+	t.Helper()
+	for _, o := range all.Objects {
+		if strings.HasPrefix(o.Geometry.Label(), name+"-") {
+			return o
+		}
+	}
+	t.Fatalf("no object for %s", name)
+	return nil
+}
+
+func TestPiecePoses(t *testing.T) {
+	ctx := context.Background()
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	pc := tiltedBoard(t, image.Point{4, 3}) // d4
+	conf := &PieceFinderConfig{Input: "cam", CameraFrame: "cam-frame", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+	rfs := pf.rfs.(*inject.FrameSystemService)
+
+	// looking straight down from 700mm up, over (500, -200)
+	camera := spatialmath.NewPose(r3.Vector{X: 500, Y: -200, Z: 700}, &spatialmath.OrientationVectorDegrees{OX: 0, OY: 0, OZ: -1})
+	var asked string
+	rfs.TransformPoseFunc = func(ctx context.Context, pose *referenceframe.PoseInFrame, dst string,
+		additionalTransforms []*referenceframe.LinkInFrame,
+	) (*referenceframe.PoseInFrame, error) {
+		asked = pose.Parent()
+		if pose.Parent() != "cam-frame" {
+			return nil, errors.New("no such frame")
+		}
+		return referenceframe.NewPoseInFrame(dst, spatialmath.Compose(camera, pose.Pose())), nil
+	}
+
+	// without the frame system, the piece is where the camera has it
+	pf.rfs = nil
+	all, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["world_poses"], test.ShouldBeFalse)
+	inCamera := objectOn(t, all, "d4").Geometry.Pose()
+	// the piece is 40mm off the board, its points the top of it
+	test.That(t, inCamera.Point().Z, test.ShouldBeBetween, 540, 600)
+
+	pf.rfs = rfs
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, asked, test.ShouldEqual, "cam-frame")
+	test.That(t, all.Extra["world_poses"], test.ShouldBeTrue)
+	o := objectOn(t, all, "d4")
+	test.That(t, spatialmath.PoseAlmostEqual(o.Geometry.Pose(), spatialmath.Compose(camera, inCamera)), test.ShouldBeTrue)
+	// it's under the camera, 700mm less how far off it is
+	test.That(t, o.Geometry.Pose().Point().Z, test.ShouldAlmostEqual, 700-inCamera.Point().Z, 1e-6)
+
+	// a camera-frame the frame system doesn't have is the same as not having one
+	conf.CameraFrame = "elsewhere"
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["world_poses"], test.ShouldBeFalse)
+	test.That(t, spatialmath.PoseAlmostEqual(objectOn(t, all, "d4").Geometry.Pose(), inCamera), test.ShouldBeTrue)
+
+	// and without one there's nothing said about it
+	conf.CameraFrame = ""
+	all, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, all.Extra["world_poses"], test.ShouldBeNil)
+}
@@ -0,0 +1,56 @@
+package viamchess
+
+import (
+	"fmt"
+	"math"
+)
+
+// standardKingHeight (mm) is how far a king sticks up off the board in the set the defaults
+// were tuned on, suggestPieceScale compares against it.
+const standardKingHeight = 85.0
+
+// pieceScale is scale, or 1 if it's unset.
+func pieceScale(scale float64) float64 {
+	if scale <= 0 {
+		return 1
+	}
+	return scale
+}
+
+func validatePieceScale(scale float64) error {
+	if scale < 0 {
+		return fmt.Errorf("piece-scale has to be positive, got %v", scale)
+	}
+	return nil
+}
+
+// scaledDefault is explicit if it's set, otherwise def scaled for the pieces.
+func scaledDefault(explicit, def, scale float64) float64 {
+	if explicit > 0 {
+		return explicit
+	}
+	return def * pieceScale(scale)
+}
+
+// scaleWarning complains about an explicit setting that's more than twice off what piece-scale
+// would have picked, which usually means it was tuned for another set. "" if it looks fine.
+func scaleWarning(name string, explicit, def, scale float64) string {
+	if explicit <= 0 || scale <= 0 || scale == 1 {
+		return ""
+	}
+	want := def * scale
+	if explicit < want/2 || explicit > want*2 {
+		return fmt.Sprintf("%s is %v but piece-scale %v would make it %v, is it for another set?", name, explicit, scale, want)
+	}
+	return ""
+}
+
+// suggestPieceScale is the piece-scale that makes the tallest of heights (mm above the board)
+// a standard king, to one decimal. 0 if nothing sticks up at all.
+func suggestPieceScale(heights []float64) float64 {
+	tallest := 0.0
+	for _, h := range heights {
+		tallest = math.Max(tallest, h)
+	}
+	return math.Round(tallest/standardKingHeight*10) / 10
+}
@@ -0,0 +1,104 @@
+package viamchess
+
+import (
+	"context"
+	"image/color"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+func TestScaledDefaults(t *testing.T) {
+	cfg := &ChessConfig{PieceScale: 4}
+	test.That(t, cfg.safeHeight(), test.ShouldEqual, 800)
+	test.That(t, cfg.minGrabHeight(), test.ShouldEqual, 48)
+	test.That(t, cfg.grabStep(), test.ShouldEqual, 40)
+	test.That(t, cfg.liftClearance(), test.ShouldEqual, 120)
+	test.That(t, cfg.minLiftHeight(), test.ShouldEqual, 240)
+	test.That(t, cfg.dropPosition().Z, test.ShouldEqual, 240)
+	test.That(t, cfg.scaleWarnings(), test.ShouldBeEmpty)
+
+	// set explicitly it wins, but one tuned for a standard set gets flagged
+	cfg.MinLiftHeight = 60
+	test.That(t, cfg.minLiftHeight(), test.ShouldEqual, 60)
+	test.That(t, len(cfg.scaleWarnings()), test.ShouldEqual, 1)
+	cfg.MinLiftHeight = 300
+	test.That(t, cfg.scaleWarnings(), test.ShouldBeEmpty)
+
+	test.That(t, (&ChessConfig{}).safeHeight(), test.ShouldEqual, safeZ)
+	test.That(t, (&ChessConfig{MinLiftHeight: 20}).scaleWarnings(), test.ShouldBeEmpty)
+
+	pf := &PieceFinderConfig{PieceScale: 4}
+	test.That(t, pf.minPieceSize(), test.ShouldEqual, 100)
+	pf.MinPieceSize = 30
+	test.That(t, pf.minPieceSize(), test.ShouldEqual, 30)
+}
+
+func TestPieceScaleValidate(t *testing.T) {
+	base := ChessConfig{PieceFinder: "pf", Arm: "arm", Gripper: "gripper", PoseStart: "start"}
+
+	cfg := base
+	cfg.PieceScale = 4
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	cfg.PieceScale = -1
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a standard safe height would run a garden set's gripper into its own lift floor
+	cfg = base
+	cfg.PieceScale = 4
+	cfg.SafeHeight = 200
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, _, err = (&PieceFinderConfig{Input: "cam", PieceScale: -2}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestEstimatePieceColorScaled(t *testing.T) {
+	// a 60mm white piece on an empty square, the camera looking down so up is -Z
+	pc := pointcloud.NewBasicEmpty()
+	for x := range 10 {
+		for y := range 10 {
+			test.That(t, pc.Set(r3.Vector{X: float64(x), Y: float64(y), Z: 500}, pointcloud.NewColoredData(color.NRGBA{30, 30, 30, 255})), test.ShouldBeNil)
+			if x > 2 && x < 7 && y > 2 && y < 7 {
+				test.That(t, pc.Set(r3.Vector{X: float64(x), Y: float64(y), Z: 440}, pointcloud.NewColoredData(color.NRGBA{230, 230, 230, 255})), test.ShouldBeNil)
+			}
+		}
+	}
+
+	c, _ := estimatePieceColor(pc, boardSurface{}.heights(pc), &PieceFinderConfig{}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 1)
+	// at 4x a 60mm bump is a weed, not a pawn
+	c, _ = estimatePieceColor(pc, boardSurface{}.heights(pc), &PieceFinderConfig{PieceScale: 4}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 0)
+}
+
+func TestMoveScaledHeights(t *testing.T) {
+	s, f := newTestChess(t)
+	s.conf.PieceScale = 4
+	f.occupied = map[string]int{"e2": 1}
+
+	res, err := s.DoCommand(context.Background(), map[string]interface{}{
+		"move": map[string]interface{}{"from": "e2", "to": "e4", "n": 1},
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	// the 40mm fake pawn is under the 48mm grab floor, and carried at the 240mm lift floor
+	e2, e4 := fakeSquareCenter("e2"), fakeSquareCenter("e4")
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e2.X, Y: e2.Y, Z: 800})), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e2.X, Y: e2.Y, Z: 48})), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, res["lift_height"], test.ShouldEqual, 240.0)
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: e4.X, Y: e4.Y, Z: 240})), test.ShouldBeGreaterThanOrEqualTo, 0)
+}
+
+func TestSuggestPieceScale(t *testing.T) {
+	test.That(t, suggestPieceScale([]float64{0, 12, 85, 40}), test.ShouldEqual, 1)
+	test.That(t, suggestPieceScale([]float64{5, 200, 400}), test.ShouldEqual, 4.7)
+	test.That(t, suggestPieceScale(nil), test.ShouldEqual, 0)
+}
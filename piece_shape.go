@@ -0,0 +1,186 @@
+package viamchess
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r2"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	// footprintHeight is how far (mm) a point has to be off the board to be part of a piece's
+	// footprint, over the depth noise.
+	footprintHeight = 5.0
+	// A piece no taller than fallenHeight (mm) with a footprint more than fallenFootprint (mm)
+	// across, both times the piece scale, is lying on its side: standing, even a king is
+	// narrower than that.
+	fallenHeight    = 15.0
+	fallenFootprint = 45.0
+
+	// past these (mm) off the board a point isn't the board or a piece on it, see cropToBand
+	defaultMaxPieceHeight   = 150.0
+	defaultSurfaceTolerance = 20.0
+)
+
+func validatePieceBand(cfg *PieceFinderConfig) error {
+	if cfg.MaxPieceHeight < 0 {
+		return fmt.Errorf("max-piece-height can't be negative, got %v", cfg.MaxPieceHeight)
+	}
+	if cfg.maxPieceHeight() <= cfg.minPieceSize() {
+		return fmt.Errorf("max-piece-height (%v) has to be over min-piece-size (%v)", cfg.maxPieceHeight(), cfg.minPieceSize())
+	}
+	if cfg.SurfaceTolerance < 0 {
+		return fmt.Errorf("surface-tolerance can't be negative, got %v", cfg.SurfaceTolerance)
+	}
+	return nil
+}
+
+func (cfg *PieceFinderConfig) maxPieceHeight() float64 {
+	return scaledDefault(cfg.MaxPieceHeight, defaultMaxPieceHeight, cfg.PieceScale)
+}
+
+func (cfg *PieceFinderConfig) surfaceTolerance() float64 {
+	if cfg.SurfaceTolerance == 0 {
+		return defaultSurfaceTolerance
+	}
+	return cfg.SurfaceTolerance
+}
+
+// cropToBand is the points of pc from conf's surfaceTolerance below the board to its
+// maxPieceHeight above it, by height, leaving out the table under the board and whatever's
+// hanging over it. pc itself when that's all of them.
+func cropToBand(pc pointcloud.PointCloud, height func(r3.Vector) float64, conf *PieceFinderConfig) (pointcloud.PointCloud, error) {
+	lo, hi := -conf.surfaceTolerance(), conf.maxPieceHeight()
+	inside := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if h := height(p); h >= lo && h <= hi {
+			inside++
+		}
+		return true
+	})
+	if inside == pc.Size() {
+		return pc, nil
+	}
+	out := pointcloud.NewBasicPointCloud(inside)
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if h := height(p); h >= lo && h <= hi {
+			err = out.Set(p, d)
+		}
+		return err == nil
+	})
+	return out, err
+}
+
+// boardSurface is the board under the squares, for how high what's on them is.
+type boardSurface struct {
+	plane tablePlane
+	ok    bool // false when no plane could be fit, see heights
+
+	// the depth (mm) of the square's empty surface from calibrate_board, see withZero
+	zero   float64
+	zeroed bool
+}
+
+// fitBoardSurface is the plane most of the points of clouds, the squares' clouds, are on, a
+// sample of about planeSamples of them all.
+func fitBoardSurface(clouds []pointcloud.PointCloud) boardSurface {
+	total := 0
+	for _, c := range clouds {
+		total += c.Size()
+	}
+	step := max(1, total/planeSamples)
+	sample := pointcloud.NewBasicEmpty()
+	k := 0
+	for _, c := range clouds {
+		c.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+			if k%step == 0 {
+				_ = sample.Set(p, d)
+			}
+			k++
+			return true
+		})
+	}
+	plane, err := fitTablePlane(sample)
+	if err != nil {
+		return boardSurface{}
+	}
+	return boardSurface{plane: plane, ok: true}
+}
+
+// heights is how high (mm) a point of pc is above the board: over the plane, or without one
+// over pc's deepest point, as the camera looks down.
+func (s boardSurface) heights(pc pointcloud.PointCloud) func(r3.Vector) float64 {
+	if s.zeroed {
+		return func(p r3.Vector) float64 { return s.zero - p.Z }
+	}
+	if s.ok {
+		return s.plane.height
+	}
+	bottom := pc.MetaData().MaxZ
+	return func(p r3.Vector) float64 { return bottom - p.Z }
+}
+
+// coords is where a point is over the board, in mm.
+func (s boardSurface) coords(p r3.Vector) r2.Point {
+	if s.ok {
+		return s.plane.coords(p)
+	}
+	return r2.Point{X: p.X, Y: p.Y}
+}
+
+// footprintDirections are what a footprint is measured across, 45 degrees apart.
+var footprintDirections = []r2.Point{{X: 1}, {X: math.Sqrt2 / 2, Y: math.Sqrt2 / 2}, {Y: 1}, {X: -math.Sqrt2 / 2, Y: math.Sqrt2 / 2}}
+
+// pieceShape is how tall (mm) what's on the square pc is the points over is above the board
+// under it, the 95th percentile of the points more than footprintHeight up, and how far across
+// it is, the widest of footprintDirections between the 5th and 95th percentiles of those
+// points. Both are 0 when there aren't more than minPiecePoints of them.
+func pieceShape(pc pointcloud.PointCloud, surface boardSurface) (float64, float64) {
+	height := surface.heights(pc)
+	var hs []float64
+	var at []r2.Point
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if h := height(p); h > footprintHeight {
+			hs = append(hs, h)
+			at = append(at, surface.coords(p))
+		}
+		return true
+	})
+	if len(hs) <= minPiecePoints {
+		return 0, 0
+	}
+
+	percentiles := func(v []float64) (float64, float64) {
+		slices.Sort(v)
+		return v[len(v)*5/100], v[len(v)*95/100]
+	}
+	_, tall := percentiles(hs)
+	return tall, footprintAcross(at)
+}
+
+// footprintAcross is how far across points on the board are, the widest of footprintDirections
+// between the 5th and 95th percentiles of them.
+func footprintAcross(at []r2.Point) float64 {
+	across := 0.0
+	along := make([]float64, len(at))
+	for _, dir := range footprintDirections {
+		for i, p := range at {
+			along[i] = p.Dot(dir)
+		}
+		slices.Sort(along)
+		across = max(across, along[len(along)*95/100]-along[len(along)*5/100])
+	}
+	return across
+}
+
+// isFallen is whether a piece height tall and footprint across, see pieceShape, is lying on its
+// side, for pieces at scale.
+func isFallen(height, footprint, scale float64) bool {
+	s := pieceScale(scale)
+	return height > 0 && height <= fallenHeight*s && footprint > fallenFootprint*s
+}
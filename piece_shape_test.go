@@ -0,0 +1,139 @@
+package viamchess
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// squareCloudsOf is each of squares' clouds.
+func squareCloudsOf(squares []squareInfo) []pointcloud.PointCloud {
+	clouds := make([]pointcloud.PointCloud, len(squares))
+	for i, sq := range squares {
+		clouds[i] = sq.pc
+	}
+	return clouds
+}
+
+func TestPieceShapeBoards(t *testing.T) {
+	for _, board := range []string{"board4", "board13"} {
+		t.Run(board, func(t *testing.T) {
+			input, err := rimage.ReadImageFromFile("data/" + board + ".jpg")
+			test.That(t, err, test.ShouldBeNil)
+			pc, err := pointcloud.NewFromFile("data/"+board+".pcd", "")
+			test.That(t, err, test.ShouldBeNil)
+			squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+			test.That(t, err, test.ShouldBeNil)
+
+			occupied := 0
+			for _, sq := range squares {
+				test.That(t, sq.fallen, test.ShouldBeFalse)
+				if sq.color == 0 {
+					test.That(t, sq.heightMM, test.ShouldBeLessThan, defaultMinPieceSize)
+					continue
+				}
+				occupied++
+				test.That(t, sq.heightMM, test.ShouldBeBetween, 20, 90)
+				test.That(t, sq.footprintMM, test.ShouldBeBetween, 10, 60)
+			}
+			test.That(t, occupied, test.ShouldBeGreaterThanOrEqualTo, 31)
+		})
+	}
+}
+
+// pieceOnSquare is a 60mm square of board 500mm from the camera with a w x d x h (mm) box on
+// it, every 2mm.
+func pieceOnSquare(t *testing.T, w, d, h float64) pointcloud.PointCloud {
+	t.Helper()
+	pc := pointcloud.NewBasicEmpty()
+	gray := pointcloud.NewColoredData(color.NRGBA{128, 128, 128, 255})
+	for x := 0.0; x < 60; x += 2 {
+		for y := 0.0; y < 60; y += 2 {
+			z := 500.0
+			if x >= 30-w/2 && x < 30+w/2 && y >= 30-d/2 && y < 30+d/2 {
+				z -= h
+			}
+			test.That(t, pc.Set(r3.Vector{X: x, Y: y, Z: z}, gray), test.ShouldBeNil)
+		}
+	}
+	return pc
+}
+
+func TestPieceShape(t *testing.T) {
+	// a pawn standing up
+	pawn := pieceOnSquare(t, 30, 30, 50)
+	tall, across := pieceShape(pawn, boardSurface{})
+	test.That(t, tall, test.ShouldAlmostEqual, 50)
+	test.That(t, across, test.ShouldBeBetween, 26, 44)
+	test.That(t, isFallen(tall, across, 0), test.ShouldBeFalse)
+
+	// lying down it's low and long
+	fallen := pieceOnSquare(t, 56, 20, 12)
+	tall, across = pieceShape(fallen, boardSurface{})
+	test.That(t, tall, test.ShouldAlmostEqual, 12)
+	test.That(t, across, test.ShouldBeGreaterThan, fallenFootprint)
+	test.That(t, isFallen(tall, across, 0), test.ShouldBeTrue)
+	// but not for a set twice the size
+	test.That(t, isFallen(tall, across, 2), test.ShouldBeFalse)
+
+	// nothing on the square
+	tall, across = pieceShape(pieceOnSquare(t, 0, 0, 0), boardSurface{})
+	test.That(t, tall, test.ShouldEqual, 0)
+	test.That(t, across, test.ShouldEqual, 0)
+	test.That(t, isFallen(tall, across, 0), test.ShouldBeFalse)
+
+	// over a fit plane it's the same
+	surface := fitBoardSurface([]pointcloud.PointCloud{pawn})
+	test.That(t, surface.ok, test.ShouldBeTrue)
+	test.That(t, surface.plane.height(r3.Vector{X: 10, Y: 10, Z: 450}), test.ShouldAlmostEqual, 50)
+	tall, _ = pieceShape(pawn, surface)
+	test.That(t, tall, test.ShouldAlmostEqual, 50)
+}
+
+func TestMinPieceSizeFlatPiece(t *testing.T) {
+	// a travel set's pawn, 12mm tall
+	pc := pieceOnSquare(t, 20, 20, 12)
+	height := boardSurface{}.heights(pc)
+	c, _ := estimatePieceColor(pc, height, &PieceFinderConfig{}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 0)
+	c, _ = estimatePieceColor(pc, height, &PieceFinderConfig{MinPieceSize: 8}, minPiecePoints)
+	test.That(t, c, test.ShouldNotEqual, 0)
+}
+
+func TestCropToBand(t *testing.T) {
+	// something 200mm over the middle of the square, and a stray point well under the board
+	pc := pieceOnSquare(t, 20, 20, 200)
+	test.That(t, pc.Set(r3.Vector{X: 1, Y: 1, Z: 560}, nil), test.ShouldBeNil)
+	surface := fitBoardSurface([]pointcloud.PointCloud{pc})
+	test.That(t, surface.ok, test.ShouldBeTrue)
+
+	cropped, err := cropToBand(pc, surface.heights(pc), &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+	// 30 x 30 points, 10 x 10 of them over it, and the stray one
+	test.That(t, cropped.Size(), test.ShouldEqual, 30*30-10*10)
+	c, _ := estimatePieceColor(cropped, surface.heights(cropped), &PieceFinderConfig{}, minPiecePoints)
+	test.That(t, c, test.ShouldEqual, 0)
+
+	// up to 250mm it's a piece after all
+	cropped, err = cropToBand(pc, surface.heights(pc), &PieceFinderConfig{MaxPieceHeight: 250, SurfaceTolerance: 100})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cropped.Size(), test.ShouldEqual, pc.Size())
+	test.That(t, cropped, test.ShouldEqual, pc)
+}
+
+func TestPieceBandValidate(t *testing.T) {
+	_, _, err := (&PieceFinderConfig{Input: "cam", MaxPieceHeight: 100, SurfaceTolerance: 5}).Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", MaxPieceHeight: -1}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	// nothing could be a piece
+	_, _, err = (&PieceFinderConfig{Input: "cam", MaxPieceHeight: 20}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", SurfaceTolerance: -3}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
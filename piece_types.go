@@ -0,0 +1,277 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/corentings/chess/v2"
+)
+
+// PieceSize is how tall (mm above the board) and how far across a piece of one type is, see
+// pieceShape for how a square's are measured.
+type PieceSize struct {
+	Height    float64 `json:"height"`
+	Footprint float64 `json:"footprint"`
+}
+
+// pieceTypeNames are the names of the piece types for the piece-sizes config and the labels.
+var pieceTypeNames = map[chess.PieceType]string{
+	chess.Pawn:   "pawn",
+	chess.Knight: "knight",
+	chess.Bishop: "bishop",
+	chess.Rook:   "rook",
+	chess.Queen:  "queen",
+	chess.King:   "king",
+}
+
+// defaultPieceSizes are a standard Staunton set's.
+var defaultPieceSizes = map[chess.PieceType]PieceSize{
+	chess.Pawn:   {35, 25},
+	chess.Rook:   {43, 30},
+	chess.Knight: {49, 28},
+	chess.Bishop: {55, 26},
+	chess.Queen:  {66, 29},
+	chess.King:   {77, 30},
+}
+
+const (
+	// how far off (mm, times piece-scale) a piece's height and footprint are expected to be
+	// measured from its type's, footprints being the rougher of the two
+	pieceHeightSpread    = 4.0
+	pieceFootprintSpread = 8.0
+
+	defaultMinTypeConfidence = .6
+)
+
+func validatePieceSizes(sizes map[string]PieceSize, minConfidence float64) error {
+	for name, size := range sizes {
+		if pieceTypeFromName(name) == chess.NoPieceType {
+			return fmt.Errorf("piece-sizes has %q, not one of pawn, knight, bishop, rook, queen or king", name)
+		}
+		if size.Height <= 0 || size.Footprint < 0 {
+			return fmt.Errorf("piece-sizes %s needs a positive height and a footprint that isn't negative, got %+v", name, size)
+		}
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		return fmt.Errorf("min-type-confidence has to be between 0 and 1, got %v", minConfidence)
+	}
+	return nil
+}
+
+func pieceTypeFromName(name string) chess.PieceType {
+	for t, n := range pieceTypeNames {
+		if n == name {
+			return t
+		}
+	}
+	return chess.NoPieceType
+}
+
+// pieceSizes is the table classifyPiece goes by: the piece-sizes config over the defaults times
+// piece-scale, a footprint left out being the default's.
+func (cfg *PieceFinderConfig) pieceSizes() map[chess.PieceType]PieceSize {
+	scale := pieceScale(cfg.PieceScale)
+	sizes := map[chess.PieceType]PieceSize{}
+	for t, size := range defaultPieceSizes {
+		sizes[t] = PieceSize{size.Height * scale, size.Footprint * scale}
+	}
+	for name, size := range cfg.PieceSizes {
+		t := pieceTypeFromName(name)
+		if size.Footprint == 0 {
+			size.Footprint = sizes[t].Footprint
+		}
+		sizes[t] = size
+	}
+	return sizes
+}
+
+func (cfg *PieceFinderConfig) minTypeConfidence() float64 {
+	if cfg.MinTypeConfidence == 0 {
+		return defaultMinTypeConfidence
+	}
+	return cfg.MinTypeConfidence
+}
+
+// classifyPiece is the type in sizes a piece height tall and footprint across is most likely to
+// be, each one's measurements taken to be spread normally around its size, and how likely that
+// is next to the others. No type for something that isn't a standing piece.
+func classifyPiece(height, footprint float64, sizes map[chess.PieceType]PieceSize, scale float64) (chess.PieceType, float64) {
+	if height <= 0 {
+		return chess.NoPieceType, 0
+	}
+	s := pieceScale(scale)
+	best, bestLikelihood, total := chess.NoPieceType, 0.0, 0.0
+	// in a fixed order, so ties always go the same way
+	for _, t := range chess.PieceTypes() {
+		size, ok := sizes[t]
+		if !ok {
+			continue
+		}
+		dh := (height - size.Height) / (pieceHeightSpread * s)
+		df := (footprint - size.Footprint) / (pieceFootprintSpread * s)
+		likelihood := math.Exp(-(dh*dh + df*df) / 2)
+		total += likelihood
+		if likelihood > bestLikelihood {
+			best, bestLikelihood = t, likelihood
+		}
+	}
+	if total == 0 {
+		// further from all of them than a float can tell apart, the nearest by height will do
+		nearest := math.Inf(1)
+		for _, t := range chess.PieceTypes() {
+			if size, ok := sizes[t]; ok && math.Abs(height-size.Height) < nearest {
+				best, nearest = t, math.Abs(height-size.Height)
+			}
+		}
+		return best, 0
+	}
+	return best, bestLikelihood / total
+}
+
+// typed is the type of the piece on sq if classifyPiece was at least minConfidence sure of it.
+func (sq squareInfo) typed(minConfidence float64) chess.PieceType {
+	if sq.color == 0 || sq.typeConfidence < minConfidence {
+		return chess.NoPieceType
+	}
+	return sq.pieceType
+}
+
+// pieceLabel is <square>-<white or black>, with -<type> after it when it's typed.
+func pieceLabel(sq squareInfo, minConfidence float64) string {
+	label := fmt.Sprintf("%s-%s", sq.name, pieceColorNames[sq.color])
+	if t := sq.typed(minConfidence); t != chess.NoPieceType {
+		label += "-" + pieceTypeNames[t]
+	}
+	return label
+}
+
+// placementFEN is the piece placement part of a FEN of squares, an 8 x 8 board's, or "" when
+// any piece on them isn't typed.
+func placementFEN(squares []squareInfo, minConfidence float64) string {
+	if len(squares) != defaultGridSize*defaultGridSize {
+		return ""
+	}
+	var sb strings.Builder
+	for rank := defaultGridSize; rank >= 1; rank-- {
+		blanks := 0
+		for file := 'a'; file < 'a'+defaultGridSize; file++ {
+			sq := squares[squareIndex(file, rank, defaultGridSize)]
+			if sq.color == 0 {
+				blanks++
+				continue
+			}
+			t := sq.typed(minConfidence)
+			if t == chess.NoPieceType {
+				return ""
+			}
+			if blanks > 0 {
+				sb.WriteString(strconv.Itoa(blanks))
+				blanks = 0
+			}
+			letter := t.String()
+			if sq.color == 1 {
+				letter = strings.ToUpper(letter)
+			}
+			sb.WriteString(letter)
+		}
+		if blanks > 0 {
+			sb.WriteString(strconv.Itoa(blanks))
+		}
+		if rank > 1 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
+
+// addPlacement puts the placementFEN of squares in a capture's extra as "fen", when there is one.
+func (bc *PieceFinder) addPlacement(extra map[string]interface{}, squares []squareInfo) {
+	if fen := placementFEN(squares, bc.conf.minTypeConfidence()); fen != "" {
+		extra["fen"] = fen
+	}
+}
+
+// startingType is the type of the piece on file and rank in the starting position, none for the
+// squares that start empty.
+func startingType(file rune, rank int) chess.PieceType {
+	switch rank {
+	case 2, 7:
+		return chess.Pawn
+	case 1, 8:
+		return [8]chess.PieceType{chess.Rook, chess.Knight, chess.Bishop, chess.Queen, chess.King, chess.Bishop, chess.Knight, chess.Rook}[file-'a']
+	}
+	return chess.NoPieceType
+}
+
+// fitPieceSizes is the piece-sizes of squares set up in the starting position: the median
+// height and footprint of each type's standing pieces.
+func fitPieceSizes(squares []squareInfo) (map[string]PieceSize, error) {
+	if len(squares) != defaultGridSize*defaultGridSize {
+		return nil, errors.New("calibrate_pieces needs an 8 x 8 board")
+	}
+	heights := map[chess.PieceType][]float64{}
+	footprints := map[chess.PieceType][]float64{}
+	for _, sq := range squares {
+		t := startingType(sq.file, sq.rank)
+		if t == chess.NoPieceType || sq.color == 0 || sq.fallen || sq.heightMM <= 0 {
+			continue
+		}
+		heights[t] = append(heights[t], sq.heightMM)
+		footprints[t] = append(footprints[t], sq.footprintMM)
+	}
+	median := func(v []float64) float64 {
+		slices.Sort(v)
+		return math.Round(v[len(v)/2])
+	}
+	sizes := map[string]PieceSize{}
+	for t, name := range pieceTypeNames {
+		if len(heights[t]) == 0 {
+			return nil, fmt.Errorf("no %ss where they start to measure, set the pieces up first", name)
+		}
+		sizes[name] = PieceSize{median(heights[t]), median(footprints[t])}
+	}
+	return sizes, nil
+}
+
+// calibratePieces is {"calibrate_pieces": true}, fitting the piece-sizes to the pieces in the
+// current frame, which have to be in the starting position, and using them until the next
+// reconfigure. The config to keep them is in the response.
+func (bc *PieceFinder) calibratePieces(ctx context.Context) (map[string]interface{}, error) {
+	_, squares, err := bc.currentSquares(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := needsDepth("calibrate_pieces", squares); err != nil {
+		return nil, err
+	}
+	sizes, err := fitPieceSizes(squares)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.captureLock.Lock()
+	bc.pieceSizes = sizes
+	// the last analysis was typed by the old sizes
+	bc.last = nil
+	bc.captureLock.Unlock()
+
+	table := map[string]interface{}{}
+	for name, size := range sizes {
+		table[name] = map[string]interface{}{"height": size.Height, "footprint": size.Footprint}
+	}
+	line, err := json.Marshal(sizes)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"calibrated":  true,
+		"piece-sizes": table,
+		"config":      fmt.Sprintf(`"piece-sizes": %s`, line),
+	}, nil
+}
@@ -0,0 +1,183 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestClassifyPiece(t *testing.T) {
+	conf := &PieceFinderConfig{}
+	sizes := conf.pieceSizes()
+
+	// right on a type's size is that type, and sure of it
+	king := sizes[chess.King]
+	typ, confidence := classifyPiece(king.Height, king.Footprint, sizes, 0)
+	test.That(t, typ, test.ShouldEqual, chess.King)
+	test.That(t, confidence, test.ShouldBeGreaterThan, .9)
+	pawn := sizes[chess.Pawn]
+	typ, _ = classifyPiece(pawn.Height, pawn.Footprint, sizes, 0)
+	test.That(t, typ, test.ShouldEqual, chess.Pawn)
+
+	// half way between two is either, but not surely
+	bishop, queen := sizes[chess.Bishop], sizes[chess.Queen]
+	_, confidence = classifyPiece((bishop.Height+queen.Height)/2, (bishop.Footprint+queen.Footprint)/2, sizes, 0)
+	test.That(t, confidence, test.ShouldBeLessThan, .6)
+
+	// way off all of them is the nearest, without being sure of it at all
+	typ, confidence = classifyPiece(500, 30, sizes, 0)
+	test.That(t, typ, test.ShouldEqual, chess.King)
+	test.That(t, confidence, test.ShouldEqual, 0)
+	typ, _ = classifyPiece(0, 0, sizes, 0)
+	test.That(t, typ, test.ShouldEqual, chess.NoPieceType)
+
+	// a set twice the size has a pawn as tall as a king
+	big := (&PieceFinderConfig{PieceScale: 2}).pieceSizes()
+	test.That(t, big[chess.Pawn].Height, test.ShouldEqual, 2*pawn.Height)
+	typ, _ = classifyPiece(2*pawn.Height, 2*pawn.Footprint, big, 2)
+	test.That(t, typ, test.ShouldEqual, chess.Pawn)
+
+	// the config's sizes go over the defaults, a footprint left out is the default's
+	conf = &PieceFinderConfig{PieceSizes: map[string]PieceSize{"king": {Height: 90}}}
+	test.That(t, conf.pieceSizes()[chess.King], test.ShouldResemble, PieceSize{90, king.Footprint})
+	test.That(t, conf.pieceSizes()[chess.Queen], test.ShouldResemble, queen)
+}
+
+func TestPieceSizesValidate(t *testing.T) {
+	_, _, err := (&PieceFinderConfig{Input: "cam", PieceSizes: map[string]PieceSize{"king": {80, 30}}}).Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", PieceSizes: map[string]PieceSize{"wizard": {80, 30}}}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", PieceSizes: map[string]PieceSize{"pawn": {0, 30}}}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&PieceFinderConfig{Input: "cam", MinTypeConfidence: 1.5}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// startingSquares are the squares of the starting position, every piece typed surely.
+func startingSquares() []squareInfo {
+	squares := make([]squareInfo, 64)
+	for rank := 1; rank <= 8; rank++ {
+		for file := 'a'; file <= 'h'; file++ {
+			sq := squareInfo{rank: rank, file: file, name: squareNames[squareIndex(file, rank, 8)]}
+			if t := startingType(file, rank); t != chess.NoPieceType {
+				sq.color = 1
+				if rank > 4 {
+					sq.color = 2
+				}
+				sq.pieceType, sq.typeConfidence = t, 1
+				sq.heightMM = defaultPieceSizes[t].Height
+				sq.footprintMM = defaultPieceSizes[t].Footprint
+			}
+			squares[squareIndex(file, rank, 8)] = sq
+		}
+	}
+	return squares
+}
+
+func TestPlacementFEN(t *testing.T) {
+	squares := startingSquares()
+	want, _, _ := strings.Cut(chess.StartingPosition().String(), " ")
+	test.That(t, placementFEN(squares, .6), test.ShouldEqual, want)
+
+	// e4
+	squares[squareIndex('e', 4, 8)] = squares[squareIndex('e', 2, 8)]
+	squares[squareIndex('e', 2, 8)] = squareInfo{}
+	test.That(t, placementFEN(squares, .6), test.ShouldEqual, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR")
+
+	// one piece it isn't sure of is no FEN
+	squares[squareIndex('d', 8, 8)].typeConfidence = .3
+	test.That(t, placementFEN(squares, .6), test.ShouldEqual, "")
+	test.That(t, placementFEN(squares[:9], 0), test.ShouldEqual, "")
+}
+
+func TestFitPieceSizes(t *testing.T) {
+	squares := startingSquares()
+	squares[squareIndex('e', 8, 8)].heightMM = 81
+	sizes, err := fitPieceSizes(squares)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(sizes), test.ShouldEqual, 6)
+	// the median of the two kings
+	test.That(t, sizes["king"].Height, test.ShouldEqual, 81)
+	test.That(t, sizes["pawn"], test.ShouldResemble, defaultPieceSizes[chess.Pawn])
+
+	// no queens to go by
+	squares[squareIndex('d', 1, 8)] = squareInfo{}
+	squares[squareIndex('d', 8, 8)] = squareInfo{}
+	_, err = fitPieceSizes(squares)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPieceTypesBoard4(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}, &frame, &pc)
+
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	byName := map[string]squareInfo{}
+	for _, sq := range pf.last.squares {
+		byName[sq.name] = sq
+	}
+
+	// the kings and queens stand taller than every pawn, and the white ones are typed as them
+	for _, name := range []string{"e1", "d1", "e8", "d8"} {
+		for rank := 2; rank <= 7; rank += 5 {
+			for file := 'a'; file <= 'h'; file++ {
+				if pawn := byName[squareNames[squareIndex(file, rank, 8)]]; pawn.color != 0 {
+					test.That(t, byName[name].heightMM, test.ShouldBeGreaterThan, pawn.heightMM)
+				}
+			}
+		}
+	}
+	test.That(t, byName["e1"].typed(.6), test.ShouldEqual, chess.King)
+	test.That(t, byName["d1"].typed(.6), test.ShouldEqual, chess.Queen)
+	test.That(t, byName["e8"].pieceType, test.ShouldBeIn, chess.King, chess.Queen)
+	pawns := 0
+	for file := 'a'; file <= 'h'; file++ {
+		if byName[squareNames[squareIndex(file, 7, 8)]].typed(.6) == chess.Pawn {
+			pawns++
+		}
+	}
+	test.That(t, pawns, test.ShouldBeGreaterThanOrEqualTo, 6)
+
+	objects, err := pf.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	labels := map[string]bool{}
+	for _, o := range objects {
+		labels[o.Geometry.Label()] = true
+	}
+	test.That(t, labels["e1-white-king"], test.ShouldBeTrue)
+	test.That(t, labels["d1-white-queen"], test.ShouldBeTrue)
+
+	// fit to the position it's in, the table comes back in order
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"calibrate_pieces": true})
+	test.That(t, err, test.ShouldBeNil)
+	table := ret["piece-sizes"].(map[string]interface{})
+	height := func(name string) float64 {
+		return table[name].(map[string]interface{})["height"].(float64)
+	}
+	test.That(t, height("king"), test.ShouldBeGreaterThan, height("queen"))
+	test.That(t, height("queen"), test.ShouldBeGreaterThan, height("pawn"))
+	test.That(t, ret["config"], test.ShouldStartWith, `"piece-sizes": {`)
+
+	// and used from then on
+	pf.captureLock.Lock()
+	test.That(t, pf.captureConf().PieceSizes["king"].Height, test.ShouldEqual, height("king"))
+	pf.captureLock.Unlock()
+	ret2, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, ret2.Extra["unchanged"], test.ShouldBeFalse)
+}
@@ -0,0 +1,231 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+)
+
+// validateCorners errors unless corners, from the config, are 4 [x, y] TL, TR, BR, BL corners
+// of a board the right way up, or not there at all.
+func validateCorners(corners [][]int) error {
+	if corners == nil {
+		return nil
+	}
+	if len(corners) != 4 {
+		return fmt.Errorf("corners needs 4 [x, y] corners, got %d", len(corners))
+	}
+	for _, c := range corners {
+		if len(c) != 2 {
+			return fmt.Errorf("bad corner %v, needs to be [x, y]", c)
+		}
+		if c[0] < 0 || c[1] < 0 {
+			return fmt.Errorf("corner %v is outside the frame", c)
+		}
+	}
+	if err := configCorners(corners).check(); err != nil {
+		return fmt.Errorf("bad corners: %w", err)
+	}
+	return nil
+}
+
+// configCorners is corners, checked by validateCorners, as BoardCorners.
+func configCorners(corners [][]int) BoardCorners {
+	pts := make([]image.Point, len(corners))
+	for i, c := range corners {
+		pts[i] = image.Point{X: c[0], Y: c[1]}
+	}
+	return boardCornersFromSlice(pts)
+}
+
+// checkCornersInFrame errors if any of corners is outside the frames of a camera with props,
+// when they say how big those are.
+func checkCornersInFrame(corners [][]int, props camera.Properties) error {
+	if corners == nil || props.IntrinsicParams == nil || props.IntrinsicParams.Width == 0 {
+		return nil
+	}
+	frame := image.Rect(0, 0, props.IntrinsicParams.Width, props.IntrinsicParams.Height)
+	for _, c := range configCorners(corners).Slice() {
+		if !c.In(frame) {
+			return fmt.Errorf("corner %v is outside the %dx%d frame", c, frame.Dx(), frame.Dy())
+		}
+	}
+	return nil
+}
+
+// pinnedBoard is the board at corners in img, an error if they aren't all in it.
+func pinnedBoard(corners [][]int, img image.Image) (BoardCorners, error) {
+	board := configCorners(corners)
+	for _, c := range board.Slice() {
+		if !c.In(img.Bounds()) {
+			return BoardCorners{}, fmt.Errorf("pinned corner %v is outside the %v frame", c, img.Bounds())
+		}
+	}
+	return board, nil
+}
+
+// parseCorners is {cmd: [[x, y], ...]}'s 4 TL, TR, BR, BL corners.
+func parseCorners(cmd string, arg interface{}) ([]r2.Point, error) {
+	list, _ := arg.([]interface{})
+	if len(list) != 4 {
+		return nil, fmt.Errorf("%s needs 4 [x, y] corners, got %v", cmd, arg)
+	}
+	corners := make([]r2.Point, 4)
+	for i, c := range list {
+		xy, ok := c.([]interface{})
+		if !ok || len(xy) != 2 {
+			return nil, fmt.Errorf("bad corner %v", c)
+		}
+		x, okX := toFloat(xy[0])
+		y, okY := toFloat(xy[1])
+		if !okX || !okY {
+			return nil, fmt.Errorf("bad corner %v", c)
+		}
+		corners[i] = r2.Point{X: x, Y: y}
+	}
+	return corners, nil
+}
+
+// captureConf is bc.conf with the corners set_corners pinned, the piece sizes calibrate_pieces
+// fit, the board calibrate_board measured and the piece colors learn_piece_colors learned,
+// bc.captureLock held.
+func (bc *PieceFinder) captureConf() *PieceFinderConfig {
+	conf := *bc.conf
+	conf.Corners = bc.pinned
+	conf.zero = bc.zero
+	conf.colorBaseline = bc.colorBaseline
+	conf.anchors = bc.anchors
+	if bc.pieceSizes != nil {
+		conf.PieceSizes = bc.pieceSizes
+	}
+	return &conf
+}
+
+// setCorners is {"set_corners": [[x, y], ...]}, pinning the board's TL, TR, BR, BL corners the
+// way the corners config does until the next reconfigure. An empty list goes back to finding
+// the board every capture.
+func (bc *PieceFinder) setCorners(arg interface{}) (map[string]interface{}, error) {
+	var pinned [][]int
+	if list, ok := arg.([]interface{}); !ok || len(list) > 0 {
+		corners, err := parseCorners("set_corners", arg)
+		if err != nil {
+			return nil, err
+		}
+		pinned = roundedCorners(corners)
+		if err := validateCorners(pinned); err != nil {
+			return nil, err
+		}
+		if err := checkCornersInFrame(pinned, bc.props); err != nil {
+			return nil, err
+		}
+	}
+
+	bc.captureLock.Lock()
+	defer bc.captureLock.Unlock()
+	bc.pinned = pinned
+	// the board moved, whether or not the frame did
+	bc.last = nil
+	return map[string]interface{}{"corners": cornersJSON(pinned)}, nil
+}
+
+// saveCorners is {"save_corners": true}, the corners the board finder finds in the current
+// frame, pinned or not, and the config line that pins them.
+func (bc *PieceFinder) saveCorners(ctx context.Context) (map[string]interface{}, error) {
+	img, err := bc.currentImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := bc.input.NextPointCloud(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	res := findCorners(ctx, nil, img, pc, bc.props, bc.conf, opts)
+	if !res.Found {
+		if res.Err != nil {
+			return nil, fmt.Errorf("board not found (%s): %w", res.Reason, res.Err)
+		}
+		return nil, fmt.Errorf("board not found (%s)", res.Reason)
+	}
+	corners := roundedCorners(res.SubPixelCorners)
+	return map[string]interface{}{
+		"corners": cornersJSON(corners),
+		"config":  cornersConfigLine(corners),
+	}, nil
+}
+
+// roundedCorners is corners to the nearest pixel, the way the corners config has them.
+func roundedCorners(corners []r2.Point) [][]int {
+	res := [][]int{}
+	for _, c := range roundCorners(corners) {
+		res = append(res, []int{c.X, c.Y})
+	}
+	return res
+}
+
+// cornersJSON is corners the way DoCommand returns them.
+func cornersJSON(corners [][]int) []interface{} {
+	res := []interface{}{}
+	for _, c := range corners {
+		res = append(res, []interface{}{c[0], c[1]})
+	}
+	return res
+}
+
+// cornersConfigLine is the piece finder config line pinning corners.
+func cornersConfigLine(corners [][]int) string {
+	parts := make([]string, len(corners))
+	for i, c := range corners {
+		parts[i] = fmt.Sprintf("[%d, %d]", c[0], c[1])
+	}
+	return fmt.Sprintf(`"corners": [%s]`, strings.Join(parts, ", "))
+}
+
+// findBoardAndPiecesWithCorners is findBoardAndPieces on the board at corners, TL, TR, BR, BL
+// in srcImg, for a caller that already knows where the board is: the board finder isn't run.
+func findBoardAndPiecesWithCorners(srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig, corners []image.Point) ([]squareInfo, error) {
+	pinned := [][]int{}
+	for _, c := range corners {
+		pinned = append(pinned, []int{c.X, c.Y})
+	}
+	if err := validateCorners(pinned); err != nil {
+		return nil, err
+	}
+	withCorners := *conf
+	withCorners.Corners = pinned
+	return findBoardAndPieces(srcImg, pc, props, &withCorners)
+}
+
+// requestCorners is arg, the "corners" of a capture's extra or a DoCommand, [[x, y], ...] TL,
+// TR, BR, BL in img, for the board to be there instead of being looked for. nil when there
+// aren't any, or when they aren't 4 corners of a board in img, which is logged: it's looked
+// for then.
+func (bc *PieceFinder) requestCorners(arg interface{}, img image.Image) [][]int {
+	if arg == nil {
+		return nil
+	}
+	corners, err := parseCorners("corners", arg)
+	if err == nil {
+		pinned := roundedCorners(corners)
+		if err = validateCorners(pinned); err == nil {
+			if _, err = pinnedBoard(pinned, img); err == nil {
+				return pinned
+			}
+		}
+	}
+	bc.logger.Warnf("ignoring the corners given, finding the board instead: %v", err)
+	return nil
+}
+
+// cornersKey is where a DoCommand's "corners" are in its ctx, for currentSquares.
+type cornersKey struct{}
@@ -0,0 +1,194 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+// board13Corners are where board13's corners are, what a calibration would pin.
+var board13Corners = [][]int{{314, 22}, {979, 22}, {976, 687}, {313, 687}}
+
+func TestPinnedCornersConfig(t *testing.T) {
+	var cfg PieceFinderConfig
+	err := json.Unmarshal([]byte(`{"input": "cam", "corners": [[314, 22], [979, 22], [976, 687], [313, 687]]}`), &cfg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Corners, test.ShouldResemble, board13Corners)
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	for _, bad := range [][][]int{
+		{},
+		{{314, 22}, {979, 22}, {976, 687}},
+		{{314, 22}, {979, 22}, {976}, {313, 687}},
+		{{-1, 22}, {979, 22}, {976, 687}, {313, 687}},
+		// TR and TL swapped
+		{{979, 22}, {314, 22}, {976, 687}, {313, 687}},
+	} {
+		_, _, err = (&PieceFinderConfig{Input: "cam", Corners: bad}).Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+
+	// the RealSense's frames are 1280x720
+	test.That(t, checkCornersInFrame(board13Corners, touch.RealSenseProperties), test.ShouldBeNil)
+	test.That(t, checkCornersInFrame([][]int{{314, 22}, {1300, 22}, {976, 687}, {313, 687}}, touch.RealSenseProperties), test.ShouldNotBeNil)
+	test.That(t, checkCornersInFrame(board13Corners, camera.Properties{}), test.ShouldBeNil)
+}
+
+func TestPinnedCornersCapture(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	// a flat frame with no board to find, and no visibility check to turn it away
+	flat := image.NewRGBA(input.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	frame := image.Image(flat)
+	conf := &PieceFinderConfig{Input: "cam", Corners: board13Corners, MinVisibleScore: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+
+	ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ret.Objects), test.ShouldEqual, 64)
+	col, row := gridPosition('a', 1, Rotation0, defaultGridSize)
+	test.That(t, pf.squares[0].originalBounds, test.ShouldResemble, configCorners(board13Corners).squareBounds(col, row, defaultGridSize, defaultSquareMargin))
+
+	// in the real frame the pieces are the same as when the board is found in it
+	found, err := findBoardAndPieces(input, pc, pf.props, &PieceFinderConfig{CornerSource: cornerSourceImage})
+	test.That(t, err, test.ShouldBeNil)
+	pinned, err := findBoardAndPieces(input, pc, pf.props, &PieceFinderConfig{Corners: board13Corners})
+	test.That(t, err, test.ShouldBeNil)
+	for i, s := range pinned {
+		test.That(t, s.color, test.ShouldEqual, found[i].color)
+	}
+
+	// warping by them is the warp of their homography, but for rounding where a pixel lands on a
+	// 256th of a frame pixel
+	warped, layout, err := WarpBoard(input, configCorners(board13Corners).Slice(), WarpOptions{Width: 400})
+	test.That(t, err, test.ShouldBeNil)
+	h, err := ComputeHomography(configCorners(board13Corners).Slice(), 400)
+	test.That(t, err, test.ShouldBeNil)
+	direct, err := perspectiveTransform(input, h, 400, 400, InterpolationBilinear)
+	test.That(t, err, test.ShouldBeNil)
+	worst := 0
+	for i, v := range warped.Pix {
+		worst = max(worst, int(v)-int(direct.Pix[i]), int(direct.Pix[i])-int(v))
+	}
+	test.That(t, worst, test.ShouldBeLessThanOrEqualTo, 2)
+	test.That(t, layout.Board, test.ShouldResemble, image.Rect(0, 0, 400, 400))
+
+	// moved at runtime, and back to finding them, which there's nothing to find in
+	moved := []interface{}{[]interface{}{320, 30}, []interface{}{970, 30}, []interface{}{970, 680}, []interface{}{320, 680}}
+	res, err := pf.DoCommand(ctx, map[string]interface{}{"set_corners": moved})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["corners"], test.ShouldResemble, moved)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pf.squares[0].originalBounds, test.ShouldResemble, configCorners([][]int{{320, 30}, {970, 30}, {970, 680}, {320, 680}}).squareBounds(col, row, defaultGridSize, defaultSquareMargin))
+
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"set_corners": []interface{}{}})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"set_corners": moved[:3]})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = pf.DoCommand(ctx, map[string]interface{}{"set_corners": []interface{}{
+		[]interface{}{320, 30}, []interface{}{1970, 30}, []interface{}{970, 680}, []interface{}{320, 680},
+	}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, pf.pinned, test.ShouldBeNil)
+}
+
+func TestSaveCorners(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	res, err := pf.DoCommand(ctx, map[string]interface{}{"save_corners": true})
+	test.That(t, err, test.ShouldBeNil)
+	t.Logf("%v", res["config"])
+	corners := res["corners"].([]interface{})
+	test.That(t, len(corners), test.ShouldEqual, 4)
+
+	// pasted into the config it parses back to the same corners
+	var cfg PieceFinderConfig
+	test.That(t, json.Unmarshal([]byte(`{"input": "cam", `+res["config"].(string)+`}`), &cfg), test.ShouldBeNil)
+	test.That(t, cornersJSON(cfg.Corners), test.ShouldResemble, corners)
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, maxCornerError(configCorners(cfg.Corners).Slice(), configCorners(board13Corners).Slice()), test.ShouldBeLessThan, 30)
+}
+
+func TestFindBoardAndPiecesWithCorners(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board4.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board4.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage}
+	opts, err := conf.boardFinderOptions()
+	test.That(t, err, test.ShouldBeNil)
+
+	found := findCorners(ctx, nil, input, pc, touch.RealSenseProperties, conf, opts)
+	test.That(t, found.Found, test.ShouldBeTrue)
+	auto, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, conf)
+	test.That(t, err, test.ShouldBeNil)
+	given, err := findBoardAndPiecesWithCorners(input, pc, touch.RealSenseProperties, conf, found.Board.Slice())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(given), test.ShouldEqual, len(auto))
+	for i := range auto {
+		test.That(t, given[i].name, test.ShouldEqual, auto[i].name)
+		test.That(t, given[i].color, test.ShouldEqual, auto[i].color)
+	}
+
+	_, err = findBoardAndPiecesWithCorners(input, pc, touch.RealSenseProperties, conf, found.Board.Slice()[:3])
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a capture given them labels the squares the same
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam", Rotation: "180", CornerSource: cornerSourceImage, ChangeThreshold: -1}, &frame, &pc)
+	labels := func(extra map[string]interface{}) []string {
+		ret, err := pf.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, extra)
+		test.That(t, err, test.ShouldBeNil)
+		out := []string{}
+		for _, o := range ret.Objects {
+			out = append(out, o.Geometry.Label())
+		}
+		return out
+	}
+	want := labels(nil)
+	corners := []interface{}{}
+	for _, c := range found.Board.Slice() {
+		corners = append(corners, []interface{}{float64(c.X), float64(c.Y)})
+	}
+	test.That(t, labels(map[string]interface{}{"corners": corners}), test.ShouldResemble, want)
+	// bad ones are ignored and the board looked for
+	test.That(t, labels(map[string]interface{}{"corners": corners[:2]}), test.ShouldResemble, want)
+	test.That(t, labels(map[string]interface{}{"corners": "top left"}), test.ShouldResemble, want)
+	offFrame := []interface{}{[]interface{}{1.0, 1.0}, []interface{}{5000.0, 1.0}, []interface{}{5000.0, 5000.0}, []interface{}{1.0, 5000.0}}
+	test.That(t, labels(map[string]interface{}{"corners": offFrame}), test.ShouldResemble, want)
+
+	// and so are the frame commands
+	ret, err := pf.DoCommand(ctx, map[string]interface{}{"sample_squares": true, "corners": corners})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(ret["squares"].([]interface{})), test.ShouldEqual, 64)
+}
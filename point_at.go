@@ -0,0 +1,121 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/utils/trace"
+)
+
+const (
+	defaultPointHeight = 80.0
+	defaultPointDip    = 20.0
+	defaultPointHoldMs = 1500
+)
+
+// errBusy is a point_at that came in while another command has the arm.
+var errBusy = errors.New("busy with another command, try point_at again when it's done")
+
+// PointAtCmd is {"point_at": {"square": "f7", "hold_ms": 1500, "dip": true}}, showing a
+// square without touching it.
+type PointAtCmd struct {
+	Square string
+	HoldMs int `mapstructure:"hold_ms"`
+	Dip    bool
+}
+
+// pointHeight is how far over a square, or the piece on it, the gripper hovers when pointing.
+func (cfg *ChessConfig) pointHeight() float64 {
+	return scaledDefault(cfg.PointHeight, defaultPointHeight, cfg.PieceScale)
+}
+
+// pointAtWaypoints are where the gripper goes to point at a square whose top is center:
+// down from safe height to hover over it, a dip with dip set, and back up. It never gets lower
+// than lift-clearance over center or higher than safe height.
+func (s *viamChessChess) pointAtWaypoints(center r3.Vector, dip bool) []r3.Vector {
+	safe := s.conf.safeHeight()
+	low := center.Z + s.motionSettings().liftClearance
+	hover := min(safe, max(low, center.Z+s.conf.pointHeight()))
+
+	at := func(z float64) r3.Vector { return r3.Vector{X: center.X, Y: center.Y, Z: z} }
+	res := []r3.Vector{at(safe), at(hover)}
+	if dip {
+		res = append(res, at(max(low, hover-defaultPointDip*pieceScale(s.conf.PieceScale))), at(hover))
+	}
+	return append(res, at(safe))
+}
+
+// pointAt moves to the waypoints for cmd.Square, holding over it for cmd.HoldMs.
+// DoCommand takes the gripper back to the start pose afterwards. abortPoint cancels it.
+func (s *viamChessChess) pointAt(ctx context.Context, cmd PointAtCmd) (map[string]interface{}, error) {
+	ctx, span := trace.StartSpan(ctx, "pointAt")
+	defer span.End()
+
+	sq, err := ParseSquare(cmd.Square)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.HoldMs < 0 {
+		return nil, fmt.Errorf("hold_ms can't be negative")
+	}
+	hold := time.Duration(cmd.HoldMs) * time.Millisecond
+	if cmd.HoldMs == 0 {
+		hold = defaultPointHoldMs * time.Millisecond
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.pointLock.Lock()
+	s.pointCancel = cancel
+	s.pointLock.Unlock()
+	defer func() {
+		s.pointLock.Lock()
+		s.pointCancel = nil
+		s.pointLock.Unlock()
+	}()
+
+	data, err := s.capture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	center, err := s.getCenterFor(data, sq.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	waypoints := s.pointAtWaypoints(center, cmd.Dip)
+	s.logger.Infof("pointing at %s, hovering at %.0f", sq, waypoints[1].Z)
+	for i, p := range waypoints {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("point_at %s aborted: %w", sq, err)
+		}
+		err = s.moveGripper(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(waypoints)-2 { // last stop over the square
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("point_at %s aborted: %w", sq, ctx.Err())
+			case <-time.After(hold):
+			}
+		}
+	}
+
+	return map[string]interface{}{"square": sq.String(), "hover_height": waypoints[1].Z}, nil
+}
+
+// abortPoint stops a point_at in progress, false if there isn't one.
+func (s *viamChessChess) abortPoint() bool {
+	s.pointLock.Lock()
+	defer s.pointLock.Unlock()
+	if s.pointCancel == nil {
+		return false
+	}
+	s.pointCancel()
+	return true
+}
@@ -0,0 +1,86 @@
+package viamchess
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+)
+
+func TestPointAtWaypoints(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{
+		"point_at": map[string]interface{}{"square": "F7", "hold_ms": 1, "dip": true},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["square"], test.ShouldEqual, "f7")
+	test.That(t, res["hover_height"], test.ShouldEqual, defaultPointHeight)
+
+	// f7 is at 250,300 in the fake world: down from safe height, dip, back up, home
+	c := fakeSquareCenter("f7")
+	want := []string{}
+	for _, z := range []float64{safeZ, 80, 60, 80, safeZ} {
+		want = append(want, fakeMoveEvent(r3.Vector{X: c.X, Y: c.Y, Z: z}))
+	}
+	moves := []string{}
+	for _, e := range f.events {
+		if e[:4] == "move" {
+			moves = append(moves, e)
+		}
+	}
+	test.That(t, moves, test.ShouldResemble, want)
+	test.That(t, f.events[len(f.events)-2:], test.ShouldResemble, []string{"start", "open"})
+	test.That(t, f.indexOf("grab"), test.ShouldEqual, -1)
+
+	// over a piece it hovers over the top of it, and the dip stops lift-clearance above it
+	waypoints := s.pointAtWaypoints(r3.Vector{X: c.X, Y: c.Y, Z: 150}, true)
+	test.That(t, waypoints[1].Z, test.ShouldEqual, safeZ)
+	test.That(t, waypoints[2].Z, test.ShouldEqual, 180)
+
+	_, err = s.DoCommand(ctx, map[string]interface{}{"point_at": map[string]interface{}{"square": "i9"}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestPointAtBusyAndAbort(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	// a move is in flight
+	s.doCommandLock.Lock()
+	_, err := s.DoCommand(ctx, map[string]interface{}{"point_at": map[string]interface{}{"square": "e4"}})
+	test.That(t, errors.Is(err, errBusy), test.ShouldBeTrue)
+	test.That(t, f.events, test.ShouldBeEmpty)
+	s.doCommandLock.Unlock()
+
+	res, err := s.DoCommand(ctx, map[string]interface{}{"abort": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["aborted"], test.ShouldBeFalse)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.DoCommand(ctx, map[string]interface{}{"point_at": map[string]interface{}{"square": "e4", "hold_ms": 60000}})
+		done <- err
+	}()
+
+	hover := fakeMoveEvent(r3.Vector{X: 200, Y: 150, Z: defaultPointHeight})
+	for f.indexOf(hover) < 0 {
+		time.Sleep(time.Millisecond)
+	}
+	res, err = s.DoCommand(ctx, map[string]interface{}{"abort": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["aborted"], test.ShouldBeTrue)
+
+	select {
+	case err = <-done:
+		test.That(t, errors.Is(err, context.Canceled), test.ShouldBeTrue)
+	case <-time.After(5 * time.Second):
+		t.Fatal("point_at didn't stop")
+	}
+	// it still went home
+	test.That(t, f.events[len(f.events)-2:], test.ShouldResemble, []string{"start", "open"})
+}
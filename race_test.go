@@ -0,0 +1,7 @@
+//go:build race
+
+package viamchess
+
+// raceEnabled is whether the race detector is on, which has sync.Pool drop items on purpose, so
+// what pooling saves can't be counted.
+const raceEnabled = true
@@ -0,0 +1,50 @@
+package viamchess_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+
+	"viamchess"
+)
+
+func TestImportDoesNotRegister(t *testing.T) {
+	models := viamchess.Models()
+	for _, m := range models {
+		_, ok := resource.LookupRegistration(m.API, m.Model)
+		test.That(t, ok, test.ShouldBeFalse)
+	}
+
+	viamchess.RegisterModels()
+	viamchess.RegisterModels()
+	for _, m := range models {
+		_, ok := resource.LookupRegistration(m.API, m.Model)
+		test.That(t, ok, test.ShouldBeTrue)
+	}
+}
+
+func TestModelsMatchMeta(t *testing.T) {
+	data, err := os.ReadFile("meta.json")
+	test.That(t, err, test.ShouldBeNil)
+
+	var meta struct {
+		Models []struct {
+			API   string `json:"api"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	test.That(t, json.Unmarshal(data, &meta), test.ShouldBeNil)
+
+	listed := []string{}
+	for _, m := range meta.Models {
+		listed = append(listed, m.API+" "+m.Model)
+	}
+	served := []string{}
+	for _, m := range viamchess.Models() {
+		served = append(served, m.API.String()+" "+m.Model.String())
+	}
+	test.That(t, served, test.ShouldResemble, listed)
+}
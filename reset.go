@@ -87,3 +87,133 @@ func nextResetMove(theState *resetState) (chess.Square, chess.Square, error) {
 
 	return -1, -1, nil
 }
+
+// centerRanks are where pieces end up mid game, and what gets in the way of putting the
+// home ranks back.
+var centerRanks = []chess.Rank{chess.Rank3, chess.Rank4, chess.Rank5, chess.Rank6}
+
+const (
+	defaultResetMoveFactor = 1.5
+
+	// maxResetMoves is way more than a reset can take, it's only there so a broken plan errors
+	// instead of spinning forever.
+	maxResetMoves = 200
+)
+
+type resetMove struct {
+	from, to chess.Square
+}
+
+func (s *resetState) clone() *resetState {
+	return &resetState{
+		board:     chess.NewBoard(s.board.SquareMap()),
+		graveyard: append([]int(nil), s.graveyard...),
+	}
+}
+
+// pendingHomes is how many home squares on each file are still empty.
+func pendingHomes(theState *resetState) [8]int {
+	var pending [8]int
+	for _, r := range homeRanks {
+		for f := chess.FileA; f <= chess.FileH; f++ {
+			if theState.board.Piece(chess.NewSquare(f, r)) == chess.NoPiece {
+				pending[f]++
+			}
+		}
+	}
+	return pending
+}
+
+// freeHome is the empty home square for what closest to file f.
+func freeHome(theState *resetState, correct *chess.Board, what chess.Piece, f chess.File) (chess.Square, bool) {
+	best, bestDist := chess.NoSquare, 100
+	for _, r := range homeRanks {
+		for hf := chess.FileA; hf <= chess.FileH; hf++ {
+			sq := chess.NewSquare(hf, r)
+			if correct.Piece(sq) != what || theState.board.Piece(sq) != chess.NoPiece {
+				continue
+			}
+			d := int(hf) - int(f)
+			if d < 0 {
+				d = -d
+			}
+			if d < bestDist {
+				best, bestDist = sq, d
+			}
+		}
+	}
+	return best, best != chess.NoSquare
+}
+
+// nextClearLaneMove sends a piece on ranks 3-6 straight to a free home square, picking first the
+// piece on the file with the most home squares still to fill, since it's in the way of those.
+func nextClearLaneMove(theState *resetState) (chess.Square, chess.Square, bool) {
+	correct := chess.NewGame().Position().Board()
+	pending := pendingHomes(theState)
+
+	from, to, bestScore := chess.NoSquare, chess.NoSquare, -1
+	for _, r := range centerRanks {
+		for f := chess.FileA; f <= chess.FileH; f++ {
+			sq := chess.NewSquare(f, r)
+			p := theState.board.Piece(sq)
+			if p == chess.NoPiece || pending[f] <= bestScore {
+				continue
+			}
+			home, ok := freeHome(theState, correct, p, f)
+			if !ok {
+				continue
+			}
+			from, to, bestScore = sq, home, pending[f]
+		}
+	}
+	return from, to, from != chess.NoSquare
+}
+
+// planReset is every move to get back to the starting position, in order. With clearLanes, the
+// pieces on ranks 3-6 go home first, so the rest don't have to be carried over them.
+func planReset(theState *resetState, clearLanes bool) ([]resetMove, error) {
+	theState = theState.clone()
+	moves := []resetMove{}
+
+	for len(moves) < maxResetMoves {
+		from, to, ok := chess.NoSquare, chess.NoSquare, false
+		if clearLanes {
+			from, to, ok = nextClearLaneMove(theState)
+		}
+		if !ok {
+			var err error
+			from, to, err = nextResetMove(theState)
+			if err != nil {
+				return nil, err
+			}
+			if from < 0 {
+				return moves, nil
+			}
+		}
+
+		moves = append(moves, resetMove{from, to})
+		err := theState.applyMove(from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("reset didn't finish in %d moves", maxResetMoves)
+}
+
+// planResetWithLanes is planReset clearing lanes first, unless that takes more than factor times
+// as many moves as the plain order.
+func planResetWithLanes(theState *resetState, factor float64) ([]resetMove, error) {
+	plain, err := planReset(theState, false)
+	if err != nil {
+		return nil, err
+	}
+	lanes, err := planReset(theState, true)
+	if err != nil {
+		return nil, err
+	}
+	if float64(len(lanes)) > factor*float64(len(plain)) {
+		return plain, nil
+	}
+	return lanes, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/corentings/chess/v2"
 	"go.viam.com/test"
 )
 
@@ -87,3 +88,93 @@ func TestReset2(t *testing.T) {
 	test.That(t, to, test.ShouldEqual, -1)
 
 }
+
+func readResetState(t *testing.T, fn string) *resetState {
+	theMainState, err := readState(context.Background(), fn)
+	test.That(t, err, test.ShouldBeNil)
+	return &resetState{theMainState.game.Position().Board(), theMainState.graveyard}
+}
+
+// playReset applies moves to a copy of theState and returns the board it ends up on.
+func playReset(t *testing.T, theState *resetState, moves []resetMove) *chess.Board {
+	theState = theState.clone()
+	for _, m := range moves {
+		test.That(t, theState.board.Piece(m.to), test.ShouldEqual, chess.NoPiece)
+		test.That(t, theState.applyMove(m.from, m.to), test.ShouldBeNil)
+	}
+	return theState.board
+}
+
+func TestPlanResetClearLanes(t *testing.T) {
+	theState := readResetState(t, "data/reset3.json")
+	start := chess.NewGame().Position().Board().String()
+
+	plain, err := planReset(theState, false)
+	test.That(t, err, test.ShouldBeNil)
+	lanes, err := planReset(theState, true)
+	test.That(t, err, test.ShouldBeNil)
+
+	test.That(t, playReset(t, theState, plain).String(), test.ShouldEqual, start)
+	test.That(t, playReset(t, theState, lanes).String(), test.ShouldEqual, start)
+	test.That(t, float64(len(lanes)), test.ShouldBeLessThanOrEqualTo, defaultResetMoveFactor*float64(len(plain)))
+
+	// d has the most empty home squares, so its pawn goes first
+	test.That(t, lanes[0].from.String(), test.ShouldEqual, "d3")
+	test.That(t, lanes[0].to.String(), test.ShouldEqual, "d2")
+
+	isCenter := func(sq chess.Square) bool {
+		return sq < 70 && sq.Rank() >= chess.Rank3 && sq.Rank() <= chess.Rank6
+	}
+
+	// the plain order fills e7 from the graveyard with the bishop still on e6
+	lastCenter, firstGraveyard := -1, -1
+	for i, m := range plain {
+		if isCenter(m.from) {
+			lastCenter = i
+		}
+		if m.from >= 70 && firstGraveyard < 0 {
+			firstGraveyard = i
+		}
+	}
+	test.That(t, lastCenter, test.ShouldBeGreaterThan, firstGraveyard)
+
+	// clearing lanes empties ranks 3-6 before anything comes out of the graveyard
+	lastCenter, firstGraveyard = -1, -1
+	for i, m := range lanes {
+		if isCenter(m.from) {
+			lastCenter = i
+		}
+		if m.from >= 70 && firstGraveyard < 0 {
+			firstGraveyard = i
+		}
+	}
+	test.That(t, firstGraveyard, test.ShouldBeGreaterThan, -1)
+	test.That(t, lastCenter, test.ShouldBeLessThan, firstGraveyard)
+
+	board := playReset(t, theState, lanes[:firstGraveyard])
+	for _, r := range centerRanks {
+		for f := chess.FileA; f <= chess.FileH; f++ {
+			test.That(t, board.Piece(chess.NewSquare(f, r)), test.ShouldEqual, chess.NoPiece)
+		}
+	}
+
+	// planning doesn't touch the state it started from
+	test.That(t, theState.graveyard, test.ShouldResemble, []int{12})
+}
+
+func TestPlanResetWithLanesFallsBack(t *testing.T) {
+	theState := readResetState(t, "data/reset3.json")
+
+	plain, err := planReset(theState, false)
+	test.That(t, err, test.ShouldBeNil)
+	lanes, err := planReset(theState, true)
+	test.That(t, err, test.ShouldBeNil)
+
+	moves, err := planResetWithLanes(theState, defaultResetMoveFactor)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, moves, test.ShouldResemble, lanes)
+
+	moves, err = planResetWithLanes(theState, .5)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, moves, test.ShouldResemble, plain)
+}
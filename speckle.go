@@ -0,0 +1,109 @@
+package viamchess
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+)
+
+const (
+	defaultSpeckleVoxel     = 5.0
+	defaultSpeckleMinPoints = 4
+)
+
+func validateSpeckle(voxel float64) error {
+	if voxel < 0 {
+		return fmt.Errorf("speckle-voxel can't be negative, got %v", voxel)
+	}
+	return nil
+}
+
+func (cfg *PieceFinderConfig) speckleVoxel() float64 {
+	if cfg.SpeckleVoxel == 0 {
+		return defaultSpeckleVoxel
+	}
+	return cfg.SpeckleVoxel
+}
+
+func (cfg *PieceFinderConfig) speckleMinPoints() int {
+	if cfg.SpeckleMinPoints == 0 {
+		return defaultSpeckleMinPoints
+	}
+	return cfg.SpeckleMinPoints
+}
+
+// removeSpeckle is pc without the depth speckle that floats over a square on its own, and how
+// many points that was: a point is kept when the speckleVoxel cube it's in and the 26 around it
+// have at least speckleMinPoints points in them, itself included. A negative speckleMinPoints
+// keeps them all.
+func removeSpeckle(pc pointcloud.PointCloud, conf *PieceFinderConfig) (pointcloud.PointCloud, int, error) {
+	need := conf.speckleMinPoints()
+	if need <= 1 || pc.Size() == 0 {
+		return pc, 0, nil
+	}
+	size := conf.speckleVoxel()
+	type key [3]int
+	voxel := func(p r3.Vector) key {
+		return key{int(math.Floor(p.X / size)), int(math.Floor(p.Y / size)), int(math.Floor(p.Z / size))}
+	}
+	counts := map[key]int{}
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		counts[voxel(p)]++
+		return true
+	})
+	keep := func(p r3.Vector) bool {
+		k := voxel(p)
+		n := counts[k]
+		// most points are on the board or a piece, in full cubes
+		for dx := -1; dx <= 1 && n < need; dx++ {
+			for dy := -1; dy <= 1 && n < need; dy++ {
+				for dz := -1; dz <= 1 && n < need; dz++ {
+					if dx != 0 || dy != 0 || dz != 0 {
+						n += counts[key{k[0] + dx, k[1] + dy, k[2] + dz}]
+					}
+				}
+			}
+		}
+		return n >= need
+	}
+
+	kept := 0
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if keep(p) {
+			kept++
+		}
+		return true
+	})
+	if kept == pc.Size() {
+		return pc, 0, nil
+	}
+	out := pointcloud.NewBasicPointCloud(kept)
+	var err error
+	pc.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		if keep(p) {
+			err = out.Set(p, d)
+		}
+		return err == nil
+	})
+	return out, pc.Size() - kept, err
+}
+
+// logSpeckle logs how many points of speckle were dropped from each of squares, at debug.
+func logSpeckle(logger logging.Logger, squares []squareInfo) {
+	total := 0
+	per := []string{}
+	for _, sq := range squares {
+		if sq.speckle > 0 {
+			total += sq.speckle
+			per = append(per, fmt.Sprintf("%s %d", sq.name, sq.speckle))
+		}
+	}
+	if total > 0 {
+		logger.Debugf("dropped %d points of depth speckle: %s", total, strings.Join(per, ", "))
+	}
+}
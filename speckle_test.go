@@ -0,0 +1,102 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/erh/vmodutils/touch"
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestSpeckleOverEmptySquare(t *testing.T) {
+	ip := touch.RealSenseProperties.IntrinsicParams
+	// a piece on e4 and nothing on d4 but speckle, 18 points floating 100 and 130mm over it
+	// more than a couple of cubes apart
+	pc := boxesBoard(t, raisedBox{image.Rect(595, 345, 625, 375), 40})
+	fleck := pointcloud.NewColoredData(color.NRGBA{250, 250, 250, 255})
+	for _, h := range []float64{100, 130} {
+		for _, v := range []int{342, 360, 378} {
+			for _, u := range []int{652, 670, 688} {
+				z := 600 - h
+				x, y := (float64(u)-ip.Ppx)/ip.Fx, (float64(v)-ip.Ppy)/ip.Fy
+				test.That(t, pc.Set(r3.Vector{X: x * z, Y: y * z, Z: z}, fleck), test.ShouldBeNil)
+			}
+		}
+	}
+
+	capture := func(minPoints int) *PieceFinder {
+		frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+		conf := &PieceFinderConfig{
+			Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1,
+			SpeckleMinPoints: minPoints,
+		}
+		pf := newTestPieceFinder(t, conf, &frame, &pc)
+		pf.pinned = conf.Corners
+		_, err := pf.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		return pf
+	}
+
+	// kept, it's enough of them to be a piece
+	pf := capture(-1)
+	test.That(t, lastSquare(t, pf, "d4").color, test.ShouldEqual, 1)
+	test.That(t, lastSquare(t, pf, "d4").speckle, test.ShouldEqual, 0)
+
+	pf = capture(0)
+	d4 := lastSquare(t, pf, "d4")
+	test.That(t, d4.color, test.ShouldEqual, 0)
+	test.That(t, d4.speckle, test.ShouldEqual, 18)
+	test.That(t, d4.export().Speckle, test.ShouldEqual, 18)
+	// the piece is all there
+	e4 := lastSquare(t, pf, "e4")
+	test.That(t, e4.color, test.ShouldEqual, 1)
+	test.That(t, e4.speckle, test.ShouldEqual, 0)
+	test.That(t, e4.heightMM, test.ShouldAlmostEqual, 40, 2)
+	for _, sq := range pf.last.squares {
+		if sq.name != "d4" {
+			test.That(t, sq.speckle, test.ShouldEqual, 0)
+		}
+	}
+
+	test.That(t, validateSpeckle(-1), test.ShouldNotBeNil)
+	test.That(t, validateSpeckle(0), test.ShouldBeNil)
+}
+
+func TestSpeckleOnlySquare(t *testing.T) {
+	// far off or washed out, d4 has only a few points, none anywhere near another
+	board := boxesBoard(t)
+	pc := pointcloud.NewBasicEmpty()
+	ip := touch.RealSenseProperties.IntrinsicParams
+	d4 := image.Rect(640, 330, 700, 390)
+	board.Iterate(0, 0, func(p r3.Vector, d pointcloud.Data) bool {
+		u, v := ip.PointToPixel(p.X, p.Y, p.Z)
+		at := image.Pt(int(math.Round(u)), int(math.Round(v)))
+		if at.In(d4) && ((at.X-640)%30 != 6 || (at.Y-330)%30 != 6) {
+			return true
+		}
+		test.That(t, pc.Set(p, d), test.ShouldBeNil)
+		return true
+	})
+
+	frame := image.Image(image.NewRGBA(image.Rect(0, 0, 1280, 720)))
+	conf := &PieceFinderConfig{Input: "cam", Rotation: "0", Corners: tiltedBoardCorners, MinVisibleScore: -1, ChangeThreshold: -1}
+	pf := newTestPieceFinder(t, conf, &frame, &pc)
+	pf.pinned = conf.Corners
+	all, err := pf.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(all.Objects), test.ShouldEqual, 64)
+
+	// it's all speckle, so there's nothing on it, but it's still where it is
+	sq := lastSquare(t, pf, "d4")
+	test.That(t, sq.color, test.ShouldEqual, 0)
+	test.That(t, sq.confidence, test.ShouldEqual, 1)
+	test.That(t, sq.speckle, test.ShouldBeGreaterThan, 0)
+	test.That(t, sq.speckle, test.ShouldEqual, sq.pc.Size())
+}
@@ -0,0 +1,103 @@
+package viamchess
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	xdraw "golang.org/x/image/draw"
+
+	"go.viam.com/rdk/rimage"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// defaultSquareCloudTile is how big (pixels) each square is in squareCloudsImage by default.
+const defaultSquareCloudTile = 64
+
+// squareCloudImage is the points over sq as the camera sees them, see touch.PCToImage, nil when
+// there are none or they have no color to draw.
+func squareCloudImage(sq squareInfo) image.Image {
+	if sq.pc == nil || sq.pc.Size() == 0 || !sq.pc.MetaData().HasColor {
+		return nil
+	}
+	return touch.PCToImage(sq.pc)
+}
+
+// squareCloudsImage is each of squares, an n x n board's, drawn by squareCloudImage into a
+// tile x tile pixel tile, as big as it fits and in the middle, all in one image laid out as
+// the board is from white's side: a8 top left, h1 bottom right. Squares with nothing to draw
+// are left black.
+func squareCloudsImage(squares []squareInfo, n, tile int) (*image.RGBA, error) {
+	if tile <= 0 {
+		return nil, fmt.Errorf("the tiles need to be at least a pixel, got %d", tile)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, n*tile, n*tile))
+	xdraw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, xdraw.Src)
+	for _, sq := range squares {
+		src := squareCloudImage(sq)
+		if src == nil {
+			continue
+		}
+		b := src.Bounds()
+		s := min(float64(tile)/float64(b.Dx()), float64(tile)/float64(b.Dy()))
+		w, h := max(1, int(float64(b.Dx())*s)), max(1, int(float64(b.Dy())*s))
+		x := int(sq.file-'a')*tile + (tile-w)/2
+		y := (n-sq.rank)*tile + (tile-h)/2
+		// over the black, where no point landed is left as that
+		xdraw.NearestNeighbor.Scale(dst, image.Rect(x, y, x+w, y+h), src, b, xdraw.Over, nil)
+	}
+	return dst, nil
+}
+
+// writeSquareClouds writes each of squares' squareCloudImage to dir, made if it isn't there,
+// as <name>.png, for looking at one square's closely.
+func writeSquareClouds(dir string, squares []squareInfo) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, sq := range squares {
+		img := squareCloudImage(sq)
+		if img == nil {
+			continue
+		}
+		if err := rimage.WriteImageToFile(filepath.Join(dir, sq.name+".png"), img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// squareClouds is the square_clouds command: the current frame's squareCloudsImage with tile
+// pixel tiles (0 is defaultSquareCloudTile) as a base64 PNG, and with dir each square's image
+// written to it too. They're the whole squares, square-margin and all.
+func (bc *PieceFinder) squareClouds(ctx context.Context, tile int, dir string) (map[string]interface{}, error) {
+	if tile == 0 {
+		tile = defaultSquareCloudTile
+	}
+	_, squares, err := bc.currentSquaresWith(ctx, func(conf *PieceFinderConfig) { conf.SquareMargin = -1 })
+	if err != nil {
+		return nil, err
+	}
+	opts, err := bc.conf.boardFinderOptions()
+	if err != nil {
+		return nil, err
+	}
+	img, err := squareCloudsImage(squares, opts.gridSize(), tile)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := writeSquareClouds(dir, squares); err != nil {
+			return nil, err
+		}
+	}
+	encoded, err := encodePNG(img)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"image": encoded}, nil
+}
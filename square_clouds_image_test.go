@@ -0,0 +1,84 @@
+package viamchess
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+func TestSquareCloudsImage(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+	squares, err := findBoardAndPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{})
+	test.That(t, err, test.ShouldBeNil)
+
+	img, err := squareCloudsImage(squares, 8, 40)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, 320, 320))
+
+	// every tile has plenty of the square's points, and no gaps to see through
+	for _, sq := range squares {
+		x, y := int(sq.file-'a')*40, (8-sq.rank)*40
+		lit := 0
+		for py := y; py < y+40; py++ {
+			for px := x; px < x+40; px++ {
+				c := img.RGBAAt(px, py)
+				test.That(t, c.A, test.ShouldEqual, 255)
+				if c.R > 0 || c.G > 0 || c.B > 0 {
+					lit++
+				}
+			}
+		}
+		test.That(t, lit, test.ShouldBeGreaterThan, 40*40/4)
+	}
+
+	_, err = squareCloudsImage(squares, 8, 0)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// and one by one
+	dir := t.TempDir()
+	test.That(t, writeSquareClouds(filepath.Join(dir, "squares"), squares), test.ShouldBeNil)
+	files, err := os.ReadDir(filepath.Join(dir, "squares"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 64)
+	e2, err := rimage.ReadImageFromFile(filepath.Join(dir, "squares", "e2.png"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, e2.Bounds().Dx(), test.ShouldBeGreaterThan, 0)
+}
+
+func TestPieceFinderSquareCloudsCommand(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	frame := image.Image(input)
+	pf := newTestPieceFinder(t, &PieceFinderConfig{Input: "cam"}, &frame, &pc)
+
+	ret, err := pf.DoCommand(context.Background(), map[string]interface{}{"square_clouds": true, "tile": 16.0})
+	test.That(t, err, test.ShouldBeNil)
+	b, err := base64.StdEncoding.DecodeString(ret["image"].(string))
+	test.That(t, err, test.ShouldBeNil)
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, cfg.Width, test.ShouldEqual, 8*16)
+	test.That(t, cfg.Height, test.ShouldEqual, 8*16)
+
+	// nothing's written without a dir
+	wd, err := os.Getwd()
+	test.That(t, err, test.ShouldBeNil)
+	_, err = os.Stat(filepath.Join(wd, "e2.png"))
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
@@ -0,0 +1,263 @@
+package viamchess
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/corentings/chess/v2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/vision/viscapture"
+)
+
+// Square is what the piece finder made of one square of the board.
+type Square struct {
+	Name string // like "e2"
+	File rune   // 'a' on
+	Rank int    // 1 on
+
+	// Color is 0 for empty, 1 for white and 2 for black, the way the capture's labels have it,
+	// and PieceColor the same as "empty", "white" or "black".
+	Color      int
+	Occupied   bool
+	PieceColor string
+	// Confidence is how sure (0 to 1) the piece finder is of Color. Ambiguous is whether the
+	// piece's color was too near both of the ones learn_piece_colors learned to tell which it
+	// is, in which case Color is the nearer one and Confidence under .5.
+	Confidence float64
+	Ambiguous  bool
+
+	// Height is how tall (mm) what's on it is, see pieceShape, 0 when there was only the image
+	// to go by.
+	Height float64
+	// Bounds is where the square is in the input frame.
+	Bounds image.Rectangle
+	// PointCount is how many points of the point cloud are over it, and Density how many fell on it
+	// next to the median square, under 1 for the squares far off from the camera or behind pieces.
+	// MinPiecePoints is how many had to stick up off it for a piece, which goes with Density.
+	// Speckle is how many more there were floating over it on their own, which were dropped.
+	PointCount     int
+	Density        float64
+	MinPiecePoints int
+	Speckle        int
+	// Anomaly is what's wrong with what's on it, nil when nothing is: a piece lying on its side
+	// or across it and another. The squares it's over share it.
+	Anomaly *Anomaly
+	// With side cameras, Cameras are the cameras that saw it, the overhead one first, and
+	// MeasuredBy the one its Height is from.
+	Cameras    []string
+	MeasuredBy string
+}
+
+// squareJSON is how a Square is marshaled.
+type squareJSON struct {
+	Name           string   `json:"name"`
+	File           string   `json:"file"`
+	Rank           int      `json:"rank"`
+	Color          int      `json:"color"`
+	Occupied       bool     `json:"occupied"`
+	PieceColor     string   `json:"piece_color"`
+	Confidence     float64  `json:"confidence"`
+	Ambiguous      bool     `json:"ambiguous"`
+	Height         float64  `json:"height"`
+	Bounds         [4]int   `json:"bounds"` // min x, min y, max x, max y
+	PointCount     int      `json:"point_count"`
+	Density        float64  `json:"density"`
+	MinPiecePoints int      `json:"min_piece_points"`
+	Speckle        int      `json:"speckle"`
+	Anomaly        *Anomaly `json:"anomaly"`
+	Cameras        []string `json:"cameras,omitempty"`
+	MeasuredBy     string   `json:"measured_by,omitempty"`
+}
+
+func (s Square) MarshalJSON() ([]byte, error) {
+	return json.Marshal(squareJSON{
+		Name:           s.Name,
+		File:           string(s.File),
+		Rank:           s.Rank,
+		Color:          s.Color,
+		Occupied:       s.Occupied,
+		PieceColor:     s.PieceColor,
+		Confidence:     s.Confidence,
+		Ambiguous:      s.Ambiguous,
+		Height:         s.Height,
+		Bounds:         [4]int{s.Bounds.Min.X, s.Bounds.Min.Y, s.Bounds.Max.X, s.Bounds.Max.Y},
+		PointCount:     s.PointCount,
+		Density:        s.Density,
+		MinPiecePoints: s.MinPiecePoints,
+		Speckle:        s.Speckle,
+		Anomaly:        s.Anomaly,
+		Cameras:        s.Cameras,
+		MeasuredBy:     s.MeasuredBy,
+	})
+}
+
+func (s *Square) UnmarshalJSON(data []byte) error {
+	var j squareJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if len([]rune(j.File)) != 1 {
+		return fmt.Errorf("bad square file %q", j.File)
+	}
+	*s = Square{
+		Name:           j.Name,
+		File:           []rune(j.File)[0],
+		Rank:           j.Rank,
+		Color:          j.Color,
+		Occupied:       j.Occupied,
+		PieceColor:     j.PieceColor,
+		Confidence:     j.Confidence,
+		Ambiguous:      j.Ambiguous,
+		Height:         j.Height,
+		Bounds:         image.Rect(j.Bounds[0], j.Bounds[1], j.Bounds[2], j.Bounds[3]),
+		PointCount:     j.PointCount,
+		Density:        j.Density,
+		MinPiecePoints: j.MinPiecePoints,
+		Speckle:        j.Speckle,
+		Anomaly:        j.Anomaly,
+		Cameras:        j.Cameras,
+		MeasuredBy:     j.MeasuredBy,
+	}
+	return nil
+}
+
+// ChessSquare is s on a chess board, chess.NoSquare when it's past h or 8.
+func (s Square) ChessSquare() chess.Square {
+	if s.File < 'a' || s.File > 'h' || s.Rank < 1 || s.Rank > 8 {
+		return chess.NoSquare
+	}
+	return chess.NewSquare(chess.File(s.File-'a'), chess.Rank(s.Rank-1))
+}
+
+// SquareOf is the empty Square sq of a chess board.
+func SquareOf(sq chess.Square) Square {
+	return newSquare(rune('a'+sq.File()), int(sq.Rank())+1, 0)
+}
+
+// newSquare is the Square of file and rank with color on it.
+func newSquare(file rune, rank, c int) Square {
+	return Square{
+		Name:       SquareName(file, rank),
+		File:       file,
+		Rank:       rank,
+		Color:      c,
+		Occupied:   c != 0,
+		PieceColor: pieceColorNames[c],
+	}
+}
+
+// export is sq as a Square.
+func (sq squareInfo) export() Square {
+	s := newSquare(sq.file, sq.rank, sq.color)
+	s.Confidence = sq.confidence
+	s.Ambiguous = sq.ambiguous
+	s.Height = sq.heightMM
+	s.Bounds = sq.originalBounds
+	if sq.pc != nil {
+		s.PointCount = sq.pc.Size()
+	}
+	s.Density = sq.density
+	s.MinPiecePoints = sq.minPoints
+	s.Speckle = sq.speckle
+	s.Anomaly = sq.anomaly
+	s.Cameras = sq.cameras
+	s.MeasuredBy = sq.measuredBy
+	return s
+}
+
+// exportSquares is each of squares as a Square, in the same order.
+func exportSquares(squares []squareInfo) []Square {
+	res := make([]Square, len(squares))
+	for i, sq := range squares {
+		res[i] = sq.export()
+	}
+	return res
+}
+
+// Board64 is the squares of a chess board, a1, b1 ... h1, a2 ... h8, so a chess.Square is
+// its index.
+type Board64 [64]Square
+
+// newBoard64 is squares, an 8 x 8 board's in findBoardAndPieces order, as a Board64.
+func newBoard64(squares []squareInfo) (Board64, error) {
+	var b Board64
+	if len(squares) != len(b) {
+		return b, fmt.Errorf("a Board64 needs an 8 x 8 board, got %d squares", len(squares))
+	}
+	for i, sq := range squares {
+		b[i] = sq.export()
+	}
+	return b, nil
+}
+
+// At is the square sq of b.
+func (b *Board64) At(sq chess.Square) Square {
+	return b[sq]
+}
+
+// Square is the square of b called name, like "e2".
+func (b *Board64) Square(name string) (Square, error) {
+	sq, err := ParseSquare(name)
+	if err != nil {
+		return Square{}, err
+	}
+	return b[sq], nil
+}
+
+// FindPieces is the board in srcImg and what's on each of its squares, the way the piece finder
+// with conf finds them, pc being the point cloud that goes with srcImg taken by a camera with
+// props, nil to go by the image alone.
+func FindPieces(srcImg image.Image, pc pointcloud.PointCloud, props camera.Properties, conf *PieceFinderConfig) (Board64, error) {
+	squares, err := findBoardAndPieces(srcImg, pc, props, conf)
+	if err != nil {
+		return Board64{}, err
+	}
+	return newBoard64(squares)
+}
+
+// captureBoard is the board in a piece finder capture, from its objects' "<square>-<color>"
+// labels and points, and which squares it had an object for. The ones it didn't are empty. The
+// labels don't say how sure the piece finder was, so every square is taken as sure.
+func captureBoard(data viscapture.VisCapture) (Board64, [64]bool) {
+	var b Board64
+	var seen [64]bool
+	for i := range b {
+		b[i] = SquareOf(chess.Square(i))
+	}
+	for _, o := range data.Objects {
+		name, c, ok := strings.Cut(o.Geometry.Label(), "-")
+		if !ok || len(c) == 0 || c[0] < '0' || c[0] > '2' {
+			continue
+		}
+		sq, err := ParseSquare(name)
+		if err != nil {
+			continue
+		}
+		s := newSquare(b[sq].File, b[sq].Rank, int(c[0]-'0'))
+		s.Confidence = 1
+		if o.PointCloud != nil {
+			s.PointCount = o.PointCloud.Size()
+		}
+		b[sq] = s
+		seen[sq] = true
+	}
+	return b, seen
+}
+
+// SquareName is the name of the square on file and rank, like "e2" for 'e', 2.
+func SquareName(file rune, rank int) string {
+	return fmt.Sprintf("%c%d", file, rank)
+}
+
+// ParseSquare parses a square like "e2", ignoring case and surrounding space.
+func ParseSquare(s string) (chess.Square, error) {
+	n := strings.ToLower(strings.TrimSpace(s))
+	if len(n) != 2 || n[0] < 'a' || n[0] > 'h' || n[1] < '1' || n[1] > '8' {
+		return chess.NoSquare, fmt.Errorf("invalid square %q, needs to be a file a-h then a rank 1-8, like e2", s)
+	}
+	return chess.NewSquare(chess.File(n[0]-'a'), chess.Rank(n[1]-'1')), nil
+}
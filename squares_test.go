@@ -0,0 +1,120 @@
+package viamchess
+
+import (
+	"encoding/json"
+	"image"
+	"testing"
+
+	"github.com/corentings/chess/v2"
+	"github.com/erh/vmodutils/touch"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/rimage"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestSquareJSON(t *testing.T) {
+	sq := Square{
+		Name: "e2", File: 'e', Rank: 2,
+		Color: 1, Occupied: true, PieceColor: "white", Confidence: .75,
+		Height: 35.5, Bounds: image.Rect(10, 20, 60, 70), PointCount: 812, Density: .5, MinPiecePoints: 5,
+	}
+	data, err := json.Marshal(sq)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(data), test.ShouldEqual,
+		`{"name":"e2","file":"e","rank":2,"color":1,"occupied":true,"piece_color":"white","confidence":0.75,"ambiguous":false,"height":35.5,"bounds":[10,20,60,70],"point_count":812,"density":0.5,"min_piece_points":5,"speckle":0,"anomaly":null}`)
+
+	var back Square
+	test.That(t, json.Unmarshal(data, &back), test.ShouldBeNil)
+	test.That(t, back, test.ShouldResemble, sq)
+
+	test.That(t, json.Unmarshal([]byte(`{"name":"e2","file":"ee","rank":2}`), &back), test.ShouldNotBeNil)
+}
+
+func TestBoard64JSON(t *testing.T) {
+	var b Board64
+	for i := range b {
+		b[i] = SquareOf(chess.Square(i))
+	}
+	b[chess.D8] = newSquare('d', 8, 2)
+	data, err := json.Marshal(b)
+	test.That(t, err, test.ShouldBeNil)
+
+	var back Board64
+	test.That(t, json.Unmarshal(data, &back), test.ShouldBeNil)
+	test.That(t, back, test.ShouldResemble, b)
+	// in a1, b1 ... h8 order
+	var names []map[string]interface{}
+	test.That(t, json.Unmarshal(data, &names), test.ShouldBeNil)
+	test.That(t, names[0]["name"], test.ShouldEqual, "a1")
+	test.That(t, names[7]["name"], test.ShouldEqual, "h1")
+	test.That(t, names[63]["name"], test.ShouldEqual, "h8")
+	test.That(t, names[59]["piece_color"], test.ShouldEqual, "black")
+}
+
+func TestSquareChessConversions(t *testing.T) {
+	for i := range 64 {
+		sq := chess.Square(i)
+		s := SquareOf(sq)
+		test.That(t, s.Name, test.ShouldEqual, sq.String())
+		test.That(t, s.ChessSquare(), test.ShouldEqual, sq)
+		test.That(t, SquareName(s.File, s.Rank), test.ShouldEqual, sq.String())
+		parsed, err := ParseSquare(s.Name)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, parsed, test.ShouldEqual, sq)
+		test.That(t, squareNames[i], test.ShouldEqual, sq.String())
+	}
+	test.That(t, SquareOf(chess.E2), test.ShouldResemble, Square{Name: "e2", File: 'e', Rank: 2, PieceColor: "empty"})
+	// a 10 x 10 board's j10 isn't on a chess board
+	test.That(t, Square{Name: "j10", File: 'j', Rank: 10}.ChessSquare(), test.ShouldEqual, chess.NoSquare)
+}
+
+func TestCaptureBoard(t *testing.T) {
+	data := viscapture.VisCapture{}
+	for _, name := range []string{"e2-0", "e4-1", "d7-2", "x-e4-1"} {
+		pc := pointcloud.NewBasicEmpty()
+		test.That(t, pc.Set(pointcloud.NewVector(0, 0, 0), nil), test.ShouldBeNil)
+		o, err := viz.NewObjectWithLabel(pc, name, nil)
+		test.That(t, err, test.ShouldBeNil)
+		data.Objects = append(data.Objects, o)
+	}
+	b, seen := captureBoard(data)
+	test.That(t, seen[chess.E4], test.ShouldBeTrue)
+	test.That(t, seen[chess.A1], test.ShouldBeFalse)
+	test.That(t, b.At(chess.E2).Occupied, test.ShouldBeFalse)
+	test.That(t, b.At(chess.E4).PieceColor, test.ShouldEqual, "white")
+	test.That(t, b.At(chess.E4).PointCount, test.ShouldEqual, 1)
+	d7, err := b.Square("D7")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, d7.Color, test.ShouldEqual, 2)
+	// no object, nothing there
+	test.That(t, b.At(chess.A1), test.ShouldResemble, SquareOf(chess.A1))
+	_, err = b.Square("z9")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestFindPiecesBoard13(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board13.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pc, err := pointcloud.NewFromFile("data/board13.pcd", "")
+	test.That(t, err, test.ShouldBeNil)
+
+	b, err := FindPieces(input, pc, touch.RealSenseProperties, &PieceFinderConfig{Rotation: "0", CornerSource: cornerSourceImage})
+	test.That(t, err, test.ShouldBeNil)
+	for i, sq := range b {
+		test.That(t, sq.ChessSquare(), test.ShouldEqual, chess.Square(i))
+		test.That(t, sq.PointCount, test.ShouldBeGreaterThan, 0)
+		test.That(t, sq.Bounds.Empty(), test.ShouldBeFalse)
+	}
+	test.That(t, b.At(chess.E4).Occupied, test.ShouldBeFalse)
+	test.That(t, b.At(chess.E2).PieceColor, test.ShouldEqual, "white")
+	test.That(t, b.At(chess.E1).PieceColor, test.ShouldEqual, "white")
+	test.That(t, b.At(chess.E8).PieceColor, test.ShouldEqual, "black")
+	test.That(t, b.At(chess.E1).Height, test.ShouldBeGreaterThan, b.At(chess.E2).Height)
+
+	// only an 8 x 8 board is a Board64
+	_, err = newBoard64(make([]squareInfo, 100))
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,140 @@
+package viamchess
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+// undistorter takes a camera's lens distortion out of its frames, so the board's edges are
+// straight for the Hough transform, through a table of where each pixel comes from in the frame.
+// The table is built on the first frame, and again only when the frame's size or the camera's
+// intrinsics or distortion change. The zero value is ready to use, and not safe for more than
+// one frame at a time.
+type undistorter struct {
+	width, height int
+	intrinsics    transform.PinholeCameraIntrinsics
+	model         transform.DistortionType
+	params        []float64
+
+	// src[i] is the offset in a frame's Pix of the top left of the four pixels output pixel i is
+	// interpolated from, -1 when it comes from outside the frame, and wx, wy how far (256ths) it
+	// is toward the bottom right one
+	src    []int32
+	wx, wy []uint8
+
+	rgba, out *image.RGBA
+}
+
+// distorts is true if props have both the intrinsics and the distortion undistort needs.
+func distorts(props camera.Properties) bool {
+	return props.IntrinsicParams != nil && props.DistortionParams != nil
+}
+
+// undistort is img as a pinhole camera with props's intrinsics would have seen it, the same size.
+// It's img itself when props have no distortion. The frame returned is only good until the
+// next call.
+func (u *undistorter) undistort(img image.Image, props camera.Properties) image.Image {
+	if !distorts(props) {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	u.build(width, height, props)
+
+	rgba, ok := img.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) || rgba.Stride != 4*width {
+		if u.rgba == nil || u.rgba.Bounds() != image.Rect(0, 0, width, height) {
+			u.rgba = image.NewRGBA(image.Rect(0, 0, width, height))
+		}
+		draw.Draw(u.rgba, u.rgba.Bounds(), img, bounds.Min, draw.Src)
+		rgba = u.rgba
+	}
+	if u.out == nil || u.out.Bounds() != rgba.Bounds() {
+		u.out = image.NewRGBA(rgba.Bounds())
+	}
+
+	in, out, stride := rgba.Pix, u.out.Pix, 4*width
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			for x := range width {
+				i := y*width + x
+				o := 4 * i
+				s := int(u.src[i])
+				if s < 0 {
+					out[o], out[o+1], out[o+2], out[o+3] = 0, 0, 0, 255
+					continue
+				}
+				wx, wy := int(u.wx[i]), int(u.wy[i])
+				for c := range 3 {
+					top := int(in[s+c])*(256-wx) + int(in[s+4+c])*wx
+					bottom := int(in[s+stride+c])*(256-wx) + int(in[s+stride+4+c])*wx
+					out[o+c] = uint8((top*(256-wy) + bottom*wy + 1<<15) >> 16)
+				}
+				out[o+3] = 255
+			}
+		}
+	})
+	return u.out
+}
+
+// build makes the table for width x height frames from a camera with props, unless it's
+// already for them.
+func (u *undistorter) build(width, height int, props camera.Properties) {
+	intrinsics, model, params := *props.IntrinsicParams, props.DistortionParams.ModelType(), props.DistortionParams.Parameters()
+	if u.src != nil && u.width == width && u.height == height && u.intrinsics == intrinsics &&
+		u.model == model && slices.Equal(u.params, params) {
+		return
+	}
+	u.width, u.height, u.intrinsics = width, height, intrinsics
+	u.model, u.params = model, slices.Clone(params)
+
+	n := width * height
+	u.src = make([]int32, n)
+	u.wx, u.wy = make([]uint8, n), make([]uint8, n)
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			for x := range width {
+				i := y*width + x
+				// the pixel centers line up, so the top left of the four is floor(p - .5)
+				p := distortPoint(props, r2.Point{X: float64(x) + .5, Y: float64(y) + .5}).Sub(r2.Point{X: .5, Y: .5})
+				fx, fy := math.Floor(p.X), math.Floor(p.Y)
+				if fx < 0 || fy < 0 || fx >= float64(width-1) || fy >= float64(height-1) {
+					u.src[i] = -1
+					continue
+				}
+				u.src[i] = int32((int(fy)*width + int(fx)) * 4)
+				u.wx[i] = uint8(math.Min(255, math.Round((p.X-fx)*256)))
+				u.wy[i] = uint8(math.Min(255, math.Round((p.Y-fy)*256)))
+			}
+		}
+	})
+}
+
+// distortPoint is where p, in a frame undistort made, is in the one the camera with props took.
+func distortPoint(props camera.Properties, p r2.Point) r2.Point {
+	ip := props.IntrinsicParams
+	x, y := props.DistortionParams.Transform((p.X-ip.Ppx)/ip.Fx, (p.Y-ip.Ppy)/ip.Fy)
+	return r2.Point{X: x*ip.Fx + ip.Ppx, Y: y*ip.Fy + ip.Ppy}
+}
+
+// distortResult is res, found in a frame undistort made, with its corners where they are in the
+// frame the camera with props took.
+func distortResult(res FindBoardResult, props camera.Properties) FindBoardResult {
+	if !res.Found || !distorts(props) {
+		return res
+	}
+	corners := make([]r2.Point, len(res.SubPixelCorners))
+	for i, c := range res.SubPixelCorners {
+		corners[i] = distortPoint(props, c)
+	}
+	found := foundResult(subPixelCornersFromSlice(corners))
+	found.CornerConfidence = res.CornerConfidence
+	return found
+}
@@ -0,0 +1,91 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/test"
+)
+
+// barrelProps are a 1280x720 camera with a wide lens's barrel distortion.
+func barrelProps(k1 float64) camera.Properties {
+	return camera.Properties{
+		IntrinsicParams:  &transform.PinholeCameraIntrinsics{Width: 1280, Height: 720, Fx: 906.07, Fy: 906.07, Ppx: 640, Ppy: 360},
+		DistortionParams: &transform.BrownConrady{RadialK1: k1},
+	}
+}
+
+// distorted is img as the camera with props would have taken it, each pixel the nearest one
+// of img it comes from.
+func distorted(img image.Image, props camera.Properties) *image.RGBA {
+	b := img.Bounds()
+	ip := props.IntrinsicParams
+	res := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// undo the distortion by fixed point iteration, it's close to the identity
+			dx, dy := (float64(x)+.5-ip.Ppx)/ip.Fx, (float64(y)+.5-ip.Ppy)/ip.Fy
+			ux, uy := dx, dy
+			for range 10 {
+				tx, ty := props.DistortionParams.Transform(ux, uy)
+				ux, uy = ux+dx-tx, uy+dy-ty
+			}
+			src := image.Point{X: int(math.Floor(ux*ip.Fx + ip.Ppx)), Y: int(math.Floor(uy*ip.Fy + ip.Ppy))}
+			if src.In(b) {
+				res.Set(x, y, img.At(src.X, src.Y))
+			}
+		}
+	}
+	return res
+}
+
+func TestUndistort(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	expected := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	props := barrelProps(-.15)
+
+	raw := distorted(input, props)
+	rawExpected := make([]image.Point, len(expected))
+	for i, c := range expected {
+		p := distortPoint(props, r2.Point{X: float64(c.X), Y: float64(c.Y)})
+		rawExpected[i] = image.Point{X: int(math.Round(p.X)), Y: int(math.Round(p.Y))}
+	}
+	// the bowed edges don't fit the grid as well, if they're found at all
+	bowed, err := findBoard(raw)
+	bowedError := math.Inf(1)
+	if err == nil {
+		bowedError = maxCornerError(bowed, rawExpected)
+	}
+	t.Logf("in the distorted frame: %v, expected %v (%.1f) %v", bowed, rawExpected, bowedError, err)
+
+	var u undistorter
+	flat := u.undistort(raw, props)
+	res := FindBoardEx(flat, DefaultBoardFinderOptions())
+	test.That(t, res.Found, test.ShouldBeTrue)
+	t.Logf("undistorted: %v (%.1f)", res.Corners, maxCornerError(res.Corners, expected))
+	test.That(t, maxCornerError(res.Corners, expected), test.ShouldBeLessThan, 4)
+
+	back := distortResult(res, props)
+	t.Logf("put back: %v (%.1f)", back.Corners, maxCornerError(back.Corners, rawExpected))
+	test.That(t, maxCornerError(back.Corners, rawExpected), test.ShouldBeLessThan, 4)
+	test.That(t, maxCornerError(back.Corners, rawExpected), test.ShouldBeLessThan, bowedError)
+	test.That(t, back.CornerConfidence, test.ShouldResemble, res.CornerConfidence)
+
+	// the table is only built again when the camera changes
+	table := &u.src[0]
+	u.undistort(raw, props)
+	test.That(t, &u.src[0], test.ShouldEqual, table)
+	u.undistort(raw, barrelProps(-.1))
+	test.That(t, &u.src[0], test.ShouldNotEqual, table)
+
+	// without distortion there's nothing to do
+	test.That(t, u.undistort(raw, camera.Properties{IntrinsicParams: props.IntrinsicParams}), test.ShouldEqual, raw)
+	test.That(t, distortResult(res, camera.Properties{}), test.ShouldResemble, res)
+}
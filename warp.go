@@ -0,0 +1,512 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/golang/geo/r2"
+)
+
+// Interpolation is how perspectiveTransform samples the source between its pixels.
+type Interpolation string
+
+const (
+	// InterpolationNearest takes the nearest source pixel, fastest, with jagged square edges.
+	InterpolationNearest Interpolation = "nearest"
+	// InterpolationBilinear blends the four source pixels around the point, the default.
+	InterpolationBilinear Interpolation = "bilinear"
+	// InterpolationBicubic fits a Catmull-Rom spline through the sixteen around it, a little
+	// sharper than bilinear when the board is warped bigger than it is in the frame.
+	InterpolationBicubic Interpolation = "bicubic"
+)
+
+func validateInterpolation(interp Interpolation) error {
+	switch interp {
+	case "", InterpolationNearest, InterpolationBilinear, InterpolationBicubic:
+		return nil
+	}
+	return fmt.Errorf("interpolation has to be %q, %q or %q, not %q",
+		InterpolationNearest, InterpolationBilinear, InterpolationBicubic, interp)
+}
+
+// WarpOptions are how WarpBoard lays the board out in its top down view.
+type WarpOptions struct {
+	// Width and Height are the view's size in pixels, either one 0 is the same as the other.
+	Width, Height int
+	// Margin is how much (fraction of the width and height) of the view is left around the
+	// board on each side, so a corner a little off doesn't cut off the outer files and ranks.
+	Margin float64
+	// Interpolation is how the frame is sampled, "" being bilinear.
+	Interpolation Interpolation
+	// Rotation is where white is in the frame, "0", "90", "180" or "270" as PieceFinderConfig
+	// has it or "auto" to go by where the pieces start, and the view is turned to put a1 at the
+	// bottom left whichever side of the table the camera is on. "" leaves it the way the frame
+	// has it.
+	Rotation string
+}
+
+// frameRotation is where white is in img with the board at corners, as opts has it, and whether
+// the view's turned for it.
+func (opts WarpOptions) frameRotation(img image.Image, corners []image.Point) (BoardRotation, bool, error) {
+	switch opts.Rotation {
+	case "":
+		return Rotation0, false, nil
+	case "auto":
+		rot, err := DetectBoardOrientation(img, corners)
+		return rot, true, err
+	}
+	rot, err := ParseBoardRotation(opts.Rotation)
+	return rot, true, err
+}
+
+// size is the view's width and height.
+func (opts WarpOptions) size() (int, int, error) {
+	width, height := opts.Width, opts.Height
+	if width == 0 {
+		width = height
+	}
+	if height == 0 {
+		height = width
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("warp size has to be positive, got %dx%d", opts.Width, opts.Height)
+	}
+	return width, height, nil
+}
+
+// WarpLayout is where WarpBoard put the board in its view.
+type WarpLayout struct {
+	// Width and Height are the view's size.
+	Width, Height int
+	// Board is the pixels the board takes up, its corners at the corners of the rectangle.
+	Board image.Rectangle
+	// Homography takes the frame's pixels to the view's.
+	Homography Homography
+	// Rotation is where white is in the view, see gridPosition: Rotation180, white along the
+	// bottom and a1 bottom left, when WarpOptions.Rotation turned it, and taken to be the native
+	// Rotation0 when it didn't.
+	Rotation BoardRotation
+}
+
+// Corners is the board's corners in the view.
+func (l WarpLayout) Corners() BoardCorners {
+	return BoardCorners{
+		TopLeft:     l.Board.Min,
+		TopRight:    image.Point{X: l.Board.Max.X, Y: l.Board.Min.Y},
+		BottomRight: l.Board.Max,
+		BottomLeft:  image.Point{X: l.Board.Min.X, Y: l.Board.Max.Y},
+	}
+}
+
+// SquareBounds is all of the square col, row counted from the top left of an n x n board in
+// the view. Neighbors share their edges, the way squareCell's do, and the last file and rank end
+// at the board's edge.
+func (l WarpLayout) SquareBounds(col, row, n int) image.Rectangle {
+	return l.Corners().squareRect(col, row, n)
+}
+
+// Square is SquareBounds of the square file, rank, 'a' and 1 being a1.
+func (l WarpLayout) Square(file rune, rank, n int) image.Rectangle {
+	col, row := gridPosition(file, rank, l.Rotation, n)
+	return l.SquareBounds(col, row, n)
+}
+
+// WarpBoard is the top down view, laid out by opts, of the board with corners (TL, TR, BR, BL)
+// in img, and where in it the board is.
+func WarpBoard(img image.Image, corners []image.Point, opts WarpOptions) (*image.RGBA, WarpLayout, error) {
+	rot, turn, err := opts.frameRotation(img, corners)
+	if err != nil {
+		return nil, WarpLayout{}, err
+	}
+	layout, err := warpLayout(corners, opts, rot, turn)
+	if err != nil {
+		return nil, WarpLayout{}, err
+	}
+	out, err := perspectiveTransform(img, layout.Homography, layout.Width, layout.Height, opts.Interpolation)
+	if err != nil {
+		return nil, WarpLayout{}, err
+	}
+	return out, layout, nil
+}
+
+// warpLayout is where WarpBoard puts the board with corners, laid out by opts, turned so white
+// is along the bottom from where it is, rot, if turn.
+func warpLayout(corners []image.Point, opts WarpOptions, rot BoardRotation, turn bool) (WarpLayout, error) {
+	if err := validateInterpolation(opts.Interpolation); err != nil {
+		return WarpLayout{}, err
+	}
+	width, height, err := opts.size()
+	if err != nil {
+		return WarpLayout{}, err
+	}
+	if opts.Margin < 0 || opts.Margin >= .5 {
+		return WarpLayout{}, fmt.Errorf("warp margin has to be from 0 up to .5, got %v", opts.Margin)
+	}
+	if len(corners) != 4 {
+		return WarpLayout{}, fmt.Errorf("need 4 corners, got %d", len(corners))
+	}
+
+	mx, my := int(math.Round(opts.Margin*float64(width))), int(math.Round(opts.Margin*float64(height)))
+	layout := WarpLayout{Width: width, Height: height, Board: image.Rect(mx, my, width-mx, height-my), Rotation: rot}
+	// turned, the view's top left is the frame's corner at a8, and the rest follow it clockwise
+	// the same in both
+	first := 0
+	if turn {
+		layout.Rotation = Rotation180
+		first = a8Corner(rot)
+	}
+	from, to := make([]r2.Point, 4), make([]r2.Point, 4)
+	for i := range corners {
+		c := corners[(first+i)%4]
+		from[i] = r2.Point{X: float64(c.X), Y: float64(c.Y)}
+	}
+	for i, c := range layout.Corners().Slice() {
+		to[i] = r2.Point{X: float64(c.X), Y: float64(c.Y)}
+	}
+	layout.Homography, err = fitHomography(from, to)
+	if err != nil {
+		return WarpLayout{}, err
+	}
+	return layout, nil
+}
+
+// a8Corner is which of the board's corners, top left, top right, bottom right, bottom left, a8 is
+// at with white where rot has it.
+func a8Corner(rot BoardRotation) int {
+	switch rot {
+	case Rotation90:
+		return 3
+	case Rotation180:
+		return 0
+	case Rotation270:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// BoardWarper is WarpBoard for a stream of frames of the same board. It keeps a table of which
+// pixels of the frame each pixel of the view is sampled from, and works it out again only when
+// the frame's size or the layout change, or a corner moves more than a pixel from where it was the
+// last time it did, so each frame is only the blending. The zero value is ready to use, and not
+// safe for more than one frame at a time.
+type BoardWarper struct {
+	corners               []image.Point
+	opts                  WarpOptions
+	rot                   BoardRotation
+	width, height, stride int
+	layout                WarpLayout
+
+	// off[i] is the offset in the frame's pix of the top left of the four pixels view pixel i is
+	// between, -1 when it's outside it, wx, wy how far (256ths) it is toward the bottom right one,
+	// and edge whether there's a pixel right of it (1) and below it (2), not past the frame's edge
+	off           []int32
+	wx, wy, edges []uint8
+	// src is where each pixel comes from, see warpFrame.from, only made for bicubic sampling
+	src []int32
+
+	// box is the boxFactor the frames are reduced by into reduced before they're sampled, with
+	// toSource taking the view to it
+	box      int
+	toSource Homography
+	reduced  *image.RGBA
+}
+
+// Warp is WarpBoard through w's table.
+func (w *BoardWarper) Warp(img image.Image, corners []image.Point, opts WarpOptions) (*image.RGBA, WarpLayout, error) {
+	if err := validateInterpolation(opts.Interpolation); err != nil {
+		return nil, WarpLayout{}, err
+	}
+	rot, turn, err := opts.frameRotation(img, corners)
+	if err != nil {
+		return nil, WarpLayout{}, err
+	}
+	f := warpSource(img)
+	if err := w.build(f, corners, opts, rot, turn); err != nil {
+		return nil, WarpLayout{}, err
+	}
+	if w.box > 1 {
+		w.reduced = boxReduce(f, w.box, w.reduced)
+		f = warpSource(w.reduced)
+	}
+
+	width, height := w.layout.Width, w.layout.Height
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	pix, stride := f.pix, f.stride
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			row := out.Pix[y*out.Stride : y*out.Stride+4*width]
+			for x := range width {
+				i, o := y*width+x, row[4*x:4*x+4:4*x+4]
+				s := int(w.off[i])
+				if s < 0 {
+					o[0], o[1], o[2], o[3] = 0, 0, 0, 255
+					continue
+				}
+				wx, wy, edge := int(w.wx[i]), int(w.wy[i]), w.edges[i]
+				dx, dy := 4*int(edge&1), stride*int(edge>>1)
+				switch opts.Interpolation {
+				case InterpolationNearest:
+					if wx >= 128 {
+						s += dx
+					}
+					if wy >= 128 {
+						s += dy
+					}
+					copy(o[:3], pix[s:s+3])
+				case InterpolationBicubic:
+					f.sampleBicubic(int(w.src[2*i]), int(w.src[2*i+1]), o)
+				default:
+					for c := range 3 {
+						top := int(pix[s+c])*(256-wx) + int(pix[s+dx+c])*wx
+						bottom := int(pix[s+dy+c])*(256-wx) + int(pix[s+dy+dx+c])*wx
+						o[c] = uint8((top*(256-wy) + bottom*wy + 1<<15) >> 16)
+					}
+				}
+				o[3] = 255
+			}
+		}
+	})
+	return out, w.layout, nil
+}
+
+// build makes w's table for frames like f and the board at corners with white at rot, turned if
+// turn, unless it's close enough.
+func (w *BoardWarper) build(f warpFrame, corners []image.Point, opts WarpOptions, rot BoardRotation, turn bool) error {
+	// the table's the same whichever way it's sampled, only bicubic sampling needs src
+	bicubic := opts.Interpolation == InterpolationBicubic
+	opts.Interpolation = ""
+	if w.off != nil && w.opts == opts && w.rot == rot && w.width == f.width && w.height == f.height && w.stride == f.stride &&
+		!cornersMoved(w.corners, corners, 1) {
+		if bicubic && w.src == nil {
+			w.buildSource(boxFrame(f, w.box))
+		}
+		return nil
+	}
+	layout, err := warpLayout(corners, opts, rot, turn)
+	if err != nil {
+		return err
+	}
+	toSource, err := layout.Homography.Inverse()
+	if err != nil {
+		return err
+	}
+
+	w.corners, w.opts, w.rot, w.layout = slices.Clone(corners), opts, rot, layout
+	w.width, w.height, w.stride = f.width, f.height, f.stride
+	// the table's into the frame reduced, when it is
+	w.box = boxFactor(toSource, layout.Width, layout.Height)
+	if w.box > 1 {
+		f, toSource = boxFrame(f, w.box), boxHomography(toSource, w.box)
+	}
+	w.toSource = toSource
+	n := layout.Width * layout.Height
+	w.off = resizeZeroed(w.off, n)
+	w.wx, w.wy, w.edges = resizeZeroed(w.wx, n), resizeZeroed(w.wy, n), resizeZeroed(w.edges, n)
+	w.src = nil
+	parallelRows(layout.Height, func(start, end int) {
+		for y := start; y < end; y++ {
+			for x := range layout.Width {
+				i := y*layout.Width + x
+				sx, sy := f.from(toSource, x, y)
+				if sx == warpOutside {
+					w.off[i] = -1
+					continue
+				}
+				// the same taps warpFrame.sampleBilinear takes
+				x0, y0 := int(sx)>>8, int(sy)>>8
+				x1, y1 := max(0, min(f.width-1, x0+1)), max(0, min(f.height-1, y0+1))
+				x0, y0 = max(0, min(f.width-1, x0)), max(0, min(f.height-1, y0))
+				w.off[i] = int32(y0*f.stride + 4*x0)
+				w.wx[i], w.wy[i] = uint8(sx&255), uint8(sy&255)
+				if x1 != x0 {
+					w.edges[i] |= 1
+				}
+				if y1 != y0 {
+					w.edges[i] |= 2
+				}
+			}
+		}
+	})
+	if bicubic {
+		w.buildSource(f)
+	}
+	return nil
+}
+
+// buildSource makes w.src for w's layout and frames like f, reduced if they are.
+func (w *BoardWarper) buildSource(f warpFrame) {
+	toSource, width := w.toSource, w.layout.Width
+	w.src = make([]int32, 2*width*w.layout.Height)
+	parallelRows(w.layout.Height, func(start, end int) {
+		for y := start; y < end; y++ {
+			for x := range width {
+				i := 2 * (y*width + x)
+				w.src[i], w.src[i+1] = f.from(toSource, x, y)
+			}
+		}
+	})
+}
+
+// cornersMoved is true if any of corners is more than limit pixels either way from the same one of
+// was, or there aren't as many.
+func cornersMoved(was, corners []image.Point, limit int) bool {
+	if len(was) != len(corners) {
+		return true
+	}
+	for i, c := range corners {
+		d := c.Sub(was[i])
+		if max(d.X, -d.X, d.Y, -d.Y) > limit {
+			return true
+		}
+	}
+	return false
+}
+
+// warpOutside is where a pixel of the view comes from when it isn't in the frame.
+const warpOutside = math.MinInt32
+
+// perspectiveTransform is the width x height top down view h makes of img, each pixel sampled
+// with interp where its middle comes from in img, the same pixel-centered-on-integers
+// coordinates the board finder uses. When the view is a lot smaller than img, it's sampled from
+// img box filtered down to nearer its size instead, see boxFactor, so what's thin isn't lost
+// between the pixels it samples. What comes from outside img is black.
+func perspectiveTransform(img image.Image, h Homography, width, height int, interp Interpolation) (*image.RGBA, error) {
+	toSource, err := h.Inverse()
+	if err != nil {
+		return nil, err
+	}
+	src := warpSource(img)
+	if k := boxFactor(toSource, width, height); k > 1 {
+		src = warpSource(boxReduce(src, k, nil))
+		toSource = boxHomography(toSource, k)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	sample := src.sampler(interp)
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			row := out.Pix[y*out.Stride : y*out.Stride+4*width]
+			for x := range width {
+				sx, sy := src.from(toSource, x, y)
+				sample(sx, sy, row[4*x:4*x+4])
+			}
+		}
+	})
+	return out, nil
+}
+
+// warpFrame is a frame's pixels the way the samplers read them, pixel x, y at pix[y*stride+4*x:].
+type warpFrame struct {
+	pix                   []uint8
+	stride, width, height int
+}
+
+// warpSource is img for the samplers, img's own pixels if it's an *image.RGBA.
+func warpSource(img image.Image) warpFrame {
+	bounds := img.Bounds()
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+	return warpFrame{
+		pix:    src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y):],
+		stride: src.Stride, width: bounds.Dx(), height: bounds.Dy(),
+	}
+}
+
+// from is where the middle of pixel x, y of the view comes from in f through toSource, in
+// 256ths of a pixel, or warpOutside if it isn't in f.
+func (f warpFrame) from(toSource Homography, x, y int) (int32, int32) {
+	p := toSource.Apply(r2.Point{X: float64(x) + .5, Y: float64(y) + .5})
+	if !(p.X >= -.5 && p.Y >= -.5 && p.X < float64(f.width)-.5 && p.Y < float64(f.height)-.5) {
+		return warpOutside, warpOutside
+	}
+	return int32(math.Floor(p.X * 256)), int32(math.Floor(p.Y * 256))
+}
+
+// sampler is what puts the red, green and blue interp makes at a point in 256ths of a pixel,
+// and opaque alpha, in a pixel of the view, black if it's warpOutside.
+func (f warpFrame) sampler(interp Interpolation) func(x, y int32, o []uint8) {
+	sample := f.sampleBilinear
+	switch interp {
+	case InterpolationNearest:
+		sample = f.sampleNearest
+	case InterpolationBicubic:
+		sample = f.sampleBicubic
+	}
+	return func(x, y int32, o []uint8) {
+		if x == warpOutside {
+			o[0], o[1], o[2], o[3] = 0, 0, 0, 255
+			return
+		}
+		sample(int(x), int(y), o)
+		o[3] = 255
+	}
+}
+
+// sampleNearest is the pixel nearest x, y.
+func (f warpFrame) sampleNearest(x, y int, o []uint8) {
+	px := max(0, min(f.width-1, (x+128)>>8))
+	py := max(0, min(f.height-1, (y+128)>>8))
+	copy(o[:3], f.pix[py*f.stride+4*px:])
+}
+
+// sampleBilinear blends the four pixels around x, y, the ones past the edges taken to be the
+// edge's own.
+func (f warpFrame) sampleBilinear(x, y int, o []uint8) {
+	wx, wy := x&255, y&255
+	x0, y0 := x>>8, y>>8
+	x1, y1 := max(0, min(f.width-1, x0+1)), max(0, min(f.height-1, y0+1))
+	x0, y0 = max(0, min(f.width-1, x0)), max(0, min(f.height-1, y0))
+
+	tl, tr := f.pix[y0*f.stride+4*x0:], f.pix[y0*f.stride+4*x1:]
+	bl, br := f.pix[y1*f.stride+4*x0:], f.pix[y1*f.stride+4*x1:]
+	for c := range 3 {
+		top := int(tl[c])*(256-wx) + int(tr[c])*wx
+		bottom := int(bl[c])*(256-wx) + int(br[c])*wx
+		o[c] = uint8((top*(256-wy) + bottom*wy + 1<<15) >> 16)
+	}
+}
+
+// sampleBicubic is sampleBilinear with the sixteen pixels around x, y, through a Catmull-Rom
+// spline each way, clamped to 0-255 where it overshoots an edge.
+func (f warpFrame) sampleBicubic(x, y int, o []uint8) {
+	kx, ky := &catmullRom[x&255], &catmullRom[y&255]
+	var xs, ys [4]int
+	for i := range 4 {
+		xs[i] = 4 * max(0, min(f.width-1, x>>8+i-1))
+		ys[i] = f.stride * max(0, min(f.height-1, y>>8+i-1))
+	}
+	for c := range 3 {
+		v := 0.0
+		for j, row := range ys {
+			across := 0.0
+			for i, col := range xs {
+				across += kx[i] * float64(f.pix[row+col+c])
+			}
+			v += ky[j] * across
+		}
+		o[c] = uint8(max(0, min(255, math.Round(v))))
+	}
+}
+
+// catmullRom is the weights of the pixels at -1, 0, 1 and 2 for a point i 256ths of the way
+// from 0 to 1.
+var catmullRom = func() [256][4]float64 {
+	var weights [256][4]float64
+	for i := range weights {
+		t := float64(i) / 256
+		t2, t3 := t*t, t*t*t
+		weights[i] = [4]float64{
+			(-t3 + 2*t2 - t) / 2,
+			(3*t3 - 5*t2 + 2) / 2,
+			(-3*t3 + 4*t2 + t) / 2,
+			(t3 - t2) / 2,
+		}
+	}
+	return weights
+}()
@@ -0,0 +1,94 @@
+package viamchess
+
+import (
+	"image"
+	"math"
+
+	"github.com/golang/geo/r2"
+)
+
+// minBoxScale is how many of the frame's pixels each of the view's has to be, each way all over
+// it, for the frame to be box filtered before it's sampled. Under that every pixel of the frame
+// is near enough one the view samples that nothing thin is lost between them.
+const minBoxScale = 2
+
+// boxFactor is how many of the frame's pixels each way to average into one before sampling the
+// width x height view toSource takes to the frame: the fewest of them one of the view's pixels
+// covers across or down at its corners, rounded down, or 1 when that's under minBoxScale.
+func boxFactor(toSource Homography, width, height int) int {
+	scale := math.Inf(1)
+	for _, c := range []r2.Point{{X: 0, Y: 0}, {X: float64(width), Y: 0}, {X: float64(width), Y: float64(height)}, {X: 0, Y: float64(height)}} {
+		at := toSource.Apply(c)
+		for _, d := range []r2.Point{{X: 1}, {Y: 1}} {
+			// toward the middle, so it's the view's own pixel
+			if c.X > 0 {
+				d.X = -d.X
+			}
+			if c.Y > 0 {
+				d.Y = -d.Y
+			}
+			scale = min(scale, toSource.Apply(c.Add(d)).Sub(at).Norm())
+		}
+	}
+	if !(scale >= minBoxScale) {
+		return 1
+	}
+	return int(scale)
+}
+
+// boxSize is how big f is averaged k x k, the blocks on the right and bottom edges what's left.
+func boxSize(f warpFrame, k int) (int, int) {
+	return (f.width + k - 1) / k, (f.height + k - 1) / k
+}
+
+// boxFrame is what the samplers are told the frame boxReduce makes of frames like f is, without
+// its pixels, f itself when k doesn't reduce it.
+func boxFrame(f warpFrame, k int) warpFrame {
+	if k <= 1 {
+		return f
+	}
+	width, height := boxSize(f, k)
+	return warpFrame{stride: 4 * width, width: width, height: height}
+}
+
+// boxReduce is f with each k x k block of its pixels averaged into one, in dst if it's the
+// right size.
+func boxReduce(f warpFrame, k int, dst *image.RGBA) *image.RGBA {
+	width, height := boxSize(f, k)
+	if dst == nil || dst.Rect != image.Rect(0, 0, width, height) {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	parallelRows(height, func(start, end int) {
+		for y := start; y < end; y++ {
+			y0, y1 := y*k, min(f.height, (y+1)*k)
+			for x := range width {
+				x0, x1 := x*k, min(f.width, (x+1)*k)
+				var sum [3]int
+				for sy := y0; sy < y1; sy++ {
+					row := f.pix[sy*f.stride:]
+					for sx := x0; sx < x1; sx++ {
+						p := row[4*sx : 4*sx+3 : 4*sx+3]
+						sum[0] += int(p[0])
+						sum[1] += int(p[1])
+						sum[2] += int(p[2])
+					}
+				}
+				n := (y1 - y0) * (x1 - x0)
+				o := dst.Pix[y*dst.Stride+4*x : y*dst.Stride+4*x+4 : y*dst.Stride+4*x+4]
+				for c := range 3 {
+					o[c] = uint8((sum[c] + n/2) / n)
+				}
+				o[3] = 255
+			}
+		}
+	})
+	return dst
+}
+
+// boxHomography is toSource to the frame boxReduce makes with k: pixel i of it is the average of
+// i*k to i*k+k-1, so its middle is where theirs is.
+func boxHomography(toSource Homography, k int) Homography {
+	s := 1 / float64(k)
+	shift := -float64(k-1) / 2 * s
+	return Homography{s, 0, shift, 0, s, shift, 0, 0, 1}.mul(toSource)
+}
@@ -0,0 +1,75 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestBoxFactor(t *testing.T) {
+	square := func(size float64) Homography {
+		h, err := computeHomography([]r2.Point{{X: 0, Y: 0}, {X: size, Y: 0}, {X: size, Y: size}, {X: 0, Y: size}}, 100)
+		test.That(t, err, test.ShouldBeNil)
+		toSource, err := h.Inverse()
+		test.That(t, err, test.ShouldBeNil)
+		return toSource
+	}
+	test.That(t, boxFactor(square(100), 100, 100), test.ShouldEqual, 1)
+	test.That(t, boxFactor(square(190), 100, 100), test.ShouldEqual, 1)
+	test.That(t, boxFactor(square(200), 100, 100), test.ShouldEqual, 2)
+	test.That(t, boxFactor(square(470), 100, 100), test.ShouldEqual, 4)
+	// it goes by the side that's shrunk least
+	rect, err := fitHomography(
+		[]r2.Point{{X: 0, Y: 0}, {X: 400, Y: 0}, {X: 400, Y: 150}, {X: 0, Y: 150}},
+		[]r2.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}})
+	test.That(t, err, test.ShouldBeNil)
+	toSource, err := rect.Inverse()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, boxFactor(toSource, 100, 100), test.ShouldEqual, 1)
+}
+
+func TestBoxFilterWarp(t *testing.T) {
+	// one pixel stripes, black and white, shrunk 8 times: each pixel of the view is over 4 of each
+	src := image.NewRGBA(image.Rect(0, 0, 800, 800))
+	for y := range 800 {
+		for x := 0; x < 800; x += 2 {
+			src.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			src.SetRGBA(x+1, y, color.RGBA{0, 0, 0, 255})
+		}
+	}
+	corners := []image.Point{{0, 0}, {800, 0}, {800, 800}, {0, 800}}
+	for _, interp := range []Interpolation{InterpolationNearest, InterpolationBilinear, InterpolationBicubic} {
+		out, _, err := WarpBoard(src, corners, WarpOptions{Width: 100, Interpolation: interp})
+		test.That(t, err, test.ShouldBeNil)
+		for y := range 100 {
+			for x := range 100 {
+				test.That(t, out.RGBAAt(x, y).G, test.ShouldBeBetweenOrEqual, 126, 129)
+			}
+		}
+	}
+
+	// the warper reduces the frames the same
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	board := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	var w BoardWarper
+	for _, interp := range []Interpolation{InterpolationNearest, InterpolationBilinear, InterpolationBicubic} {
+		opts := WarpOptions{Width: 160, Height: 200, Interpolation: interp}
+		want, wantLayout, err := WarpBoard(input, board, opts)
+		test.That(t, err, test.ShouldBeNil)
+		got, layout, err := w.Warp(input, board, opts)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, w.box, test.ShouldEqual, 2)
+		test.That(t, layout, test.ShouldResemble, wantLayout)
+		test.That(t, got.Pix, test.ShouldResemble, want.Pix)
+	}
+	// and not when the view's near the board's size
+	_, _, err = w.Warp(input, board, WarpOptions{Width: 400})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, w.box, test.ShouldEqual, 1)
+}
@@ -0,0 +1,118 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// How the board camera draws its warped image, see BoardCameraConfig.Mode.
+const (
+	modeImage   = "image"   // the board as it is
+	modeHue     = "hue"     // every pixel at full saturation and value, for color calibration
+	modeGray    = "gray"    // what the board finder sees
+	modeEdges   = "edges"   // the Sobel magnitude, for the board's lines
+	modeMask    = "mask"    // the dark squares, what the point cloud's board finder looks for
+	modeSquares = "squares" // each square filled with its mean color, for what's on it
+)
+
+func validateWarpMode(mode string) error {
+	switch mode {
+	case "", modeImage, modeHue, modeGray, modeEdges, modeMask, modeSquares:
+		return nil
+	}
+	return fmt.Errorf("bad mode %q, needs to be %s, %s, %s, %s, %s or %s",
+		mode, modeImage, modeHue, modeGray, modeEdges, modeMask, modeSquares)
+}
+
+// grayToRGBA is gray as an RGBA image with bounds, which start at 0, 0 as warped images do,
+// each value through fn.
+func grayToRGBA(gray *GrayPlane, bounds image.Rectangle, fn func(uint8) uint8) *image.RGBA {
+	dst := image.NewRGBA(bounds)
+	parallelRows(gray.Height, func(start, end int) {
+		for y := start; y < end; y++ {
+			row := dst.Pix[y*dst.Stride:]
+			for x, v := range gray.Row(y) {
+				v = fn(v)
+				row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = v, v, v, 255
+			}
+		}
+	})
+	return dst
+}
+
+// hueView is img in modeHue.
+func hueView(img *image.RGBA) *image.RGBA {
+	return themePixels(img, func(c color.RGBA) color.RGBA {
+		h, _, _ := hsv(c)
+		return hueColor(h)
+	})
+}
+
+// grayView is img in modeGray, the board finder's gray.
+func grayView(img *image.RGBA) *image.RGBA {
+	gray := makeGrayImage(img)
+	defer gray.release()
+	return grayToRGBA(gray, img.Bounds(), func(v uint8) uint8 { return v })
+}
+
+// edgesView is img in modeEdges, the board finder's Sobel magnitude of its gray.
+func edgesView(img *image.RGBA) *image.RGBA {
+	gray := makeGrayImage(img)
+	defer gray.release()
+	sobel := sobelEdgeDetection(gray)
+	defer sobel.release()
+	return grayToRGBA(sobel.magnitude, img.Bounds(), func(v uint8) uint8 { return v })
+}
+
+// maskView is img in modeMask: white where it's darker than Otsu's split of the gray inside
+// board, as the point cloud's board finder splits off the dark squares, black elsewhere.
+func maskView(img *image.RGBA, board image.Rectangle) *image.RGBA {
+	gray := makeGrayImage(img)
+	defer gray.release()
+	var hist [256]int
+	r := board.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for _, v := range gray.Row(y)[r.Min.X:r.Max.X] {
+			hist[v]++
+		}
+	}
+	level, _, _, _ := otsuSplit(&hist)
+	return grayToRGBA(gray, img.Bounds(), func(v uint8) uint8 {
+		if int(v) < level {
+			return 255
+		}
+		return 0
+	})
+}
+
+// squaresView is img of an n x n board laid out by layout in modeSquares: each square filled
+// with its mean color, black around them.
+func squaresView(img *image.RGBA, layout WarpLayout, n int) *image.RGBA {
+	squares := make([]squareInfo, 0, n*n)
+	for row := range n {
+		for col := range n {
+			squares = append(squares, squareInfo{originalBounds: layout.SquareBounds(col, row, n)})
+		}
+	}
+	return themeSquares(img.Bounds(), squares, squareMeanColors(img, squares))
+}
+
+// renderWarpMode is img, warped by layout from an n x n board, drawn in mode.
+func renderWarpMode(img *image.RGBA, layout WarpLayout, n int, mode string) (*image.RGBA, error) {
+	switch mode {
+	case "", modeImage:
+		return img, nil
+	case modeHue:
+		return hueView(img), nil
+	case modeGray:
+		return grayView(img), nil
+	case modeEdges:
+		return edgesView(img), nil
+	case modeMask:
+		return maskView(img, layout.Board), nil
+	case modeSquares:
+		return squaresView(img, layout, n), nil
+	}
+	return nil, validateWarpMode(mode)
+}
@@ -0,0 +1,124 @@
+package viamchess
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+// warpedBoard1 is board1 warped to 400 x 400 with no margin, so the squares are 50 pixels.
+func warpedBoard1(t *testing.T) (*image.RGBA, WarpLayout) {
+	t.Helper()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	warped, layout, err := WarpBoard(input, corners, WarpOptions{Width: 400})
+	test.That(t, err, test.ShouldBeNil)
+	return warped, layout
+}
+
+// patchMean is the mean of the red channel of img over r, all there is for the gray modes.
+func patchMean(img *image.RGBA, r image.Rectangle) float64 {
+	sum := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += int(img.RGBAAt(x, y).R)
+		}
+	}
+	return float64(sum) / float64(r.Dx()*r.Dy())
+}
+
+func TestWarpModes(t *testing.T) {
+	warped, layout := warpedBoard1(t)
+	test.That(t, layout.SquareBounds(1, 0, 8), test.ShouldResemble, image.Rect(50, 0, 100, 50))
+	p := image.Pt(120, 220)
+	c := warped.RGBAAt(p.X, p.Y)
+
+	t.Run("hue", func(t *testing.T) {
+		h, _, _ := hsv(c)
+		test.That(t, hueView(warped).RGBAAt(p.X, p.Y), test.ShouldResemble, hueColor(h))
+	})
+
+	t.Run("gray", func(t *testing.T) {
+		v := uint8((int(c.R) + int(c.G) + int(c.B)) / 3)
+		test.That(t, grayView(warped).RGBAAt(p.X, p.Y), test.ShouldResemble, color.RGBA{v, v, v, 255})
+	})
+
+	t.Run("edges", func(t *testing.T) {
+		edges := edgesView(warped)
+		// across the line between the third and fourth files, not in the middle of a square
+		line := patchMean(edges, image.Rect(148, 160, 152, 190))
+		middle := patchMean(edges, image.Rect(170, 160, 180, 190))
+		test.That(t, line, test.ShouldBeGreaterThan, 4*middle)
+	})
+
+	t.Run("mask", func(t *testing.T) {
+		mask := maskView(warped, layout.Board)
+		gray := grayView(warped)
+		// of two squares side by side the darker is the one in the mask
+		a, b := layout.SquareBounds(3, 3, 8).Inset(8), layout.SquareBounds(4, 3, 8).Inset(8)
+		if patchMean(gray, a) > patchMean(gray, b) {
+			a, b = b, a
+		}
+		test.That(t, patchMean(mask, a), test.ShouldBeGreaterThan, 200)
+		test.That(t, patchMean(mask, b), test.ShouldBeLessThan, 55)
+		for _, v := range []uint8{mask.RGBAAt(10, 10).R, mask.RGBAAt(300, 300).R} {
+			test.That(t, v == 0 || v == 255, test.ShouldBeTrue)
+		}
+	})
+
+	t.Run("squares", func(t *testing.T) {
+		squares := squaresView(warped, layout, 8)
+		sq := layout.SquareBounds(2, 4, 8)
+		want := squareMeanColors(warped, []squareInfo{{originalBounds: sq}})[0]
+		test.That(t, squares.RGBAAt(sq.Min.X, sq.Min.Y), test.ShouldResemble, want)
+		test.That(t, squares.RGBAAt(sq.Max.X-1, sq.Max.Y-1), test.ShouldResemble, want)
+	})
+
+	// image is the warp itself, and they're all the same size
+	same, err := renderWarpMode(warped, layout, 8, modeImage)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, same, test.ShouldEqual, warped)
+	for _, mode := range []string{modeHue, modeGray, modeEdges, modeMask, modeSquares} {
+		out, err := renderWarpMode(warped, layout, 8, mode)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, out.Bounds(), test.ShouldResemble, warped.Bounds())
+	}
+	_, err = renderWarpMode(warped, layout, 8, "sepia")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestBoardCameraMode(t *testing.T) {
+	ctx := context.Background()
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	pinned := [][]int{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	_, _, err = (&BoardCameraConfig{Input: "cam", Mode: "sepia"}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	warped := func(conf *BoardCameraConfig, extra map[string]interface{}) *image.RGBA {
+		bc := newTestBoardCamera(t, conf, input, nil)
+		images, _, err := bc.Images(ctx, []string{sourceWarped}, extra)
+		test.That(t, err, test.ShouldBeNil)
+		img, err := images[0].Image(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		return img.(*image.RGBA)
+	}
+	plain := warped(&BoardCameraConfig{Input: "cam", Width: 400, Corners: pinned}, nil)
+	edges := warped(&BoardCameraConfig{Input: "cam", Width: 400, Corners: pinned, Mode: modeEdges}, nil)
+
+	// drawn in the mode with the grid on it, which the plain image doesn't get
+	test.That(t, edges.RGBAAt(150, 175), test.ShouldResemble, overlayGridColor)
+	test.That(t, plain.RGBAAt(150, 175), test.ShouldNotResemble, overlayGridColor)
+	c := edges.RGBAAt(175, 175)
+	test.That(t, c.R == c.G && c.G == c.B, test.ShouldBeTrue)
+
+	// unless the overlay says not to
+	bare := warped(&BoardCameraConfig{Input: "cam", Width: 400, Corners: pinned, Mode: modeEdges}, map[string]interface{}{"overlay": "none"})
+	test.That(t, bare.RGBAAt(150, 175), test.ShouldNotResemble, overlayGridColor)
+}
@@ -0,0 +1,68 @@
+package viamchess
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// What the board camera draws on its warped image, extra["overlay"] to Images.
+const (
+	overlayNone   = "none"
+	overlayGrid   = "grid"
+	overlayLabels = "labels"
+	overlayBoth   = "both"
+)
+
+var (
+	overlayGridColor  = color.RGBA{0, 255, 0, 255}
+	overlayLabelColor = color.RGBA{255, 0, 0, 255}
+)
+
+// overlayMode is extra["overlay"], overlayNone when it isn't there.
+func overlayMode(extra map[string]interface{}) (string, error) {
+	v, ok := extra["overlay"]
+	if !ok {
+		return overlayNone, nil
+	}
+	mode, _ := v.(string)
+	switch mode {
+	case overlayNone, overlayGrid, overlayLabels, overlayBoth:
+		return mode, nil
+	}
+	return "", fmt.Errorf("overlay has to be none, grid, labels or both, got %v", v)
+}
+
+// drawWarpOverlay draws mode on img, a warped view of an n x n board laid out by layout: a
+// line along each square's edges for the grid, and each file's letter in the bottom right of
+// its square on the first rank and each rank's number in the top left of its square on the a
+// file for the labels, wherever the rotation puts them.
+func drawWarpOverlay(img *image.RGBA, layout WarpLayout, n int, mode string) {
+	if mode == overlayGrid || mode == overlayBoth {
+		board := layout.Board
+		drawRect(img, board, overlayGridColor)
+		for i := 1; i < n; i++ {
+			x := layout.SquareBounds(i, 0, n).Min.X
+			for y := board.Min.Y; y < board.Max.Y; y++ {
+				img.Set(x, y, overlayGridColor)
+			}
+			y := layout.SquareBounds(0, i, n).Min.Y
+			for x := board.Min.X; x < board.Max.X; x++ {
+				img.Set(x, y, overlayGridColor)
+			}
+		}
+	}
+
+	if mode == overlayLabels || mode == overlayBoth {
+		// basicfont.Face7x13 is 7 wide, and 10 of its 13 are above the baseline
+		for i := range n {
+			file := 'a' + rune(i)
+			sq := layout.Square(file, 1, n)
+			drawString(img, sq.Max.X-9, sq.Max.Y-3, string(file), overlayLabelColor)
+
+			rank := fmt.Sprint(i + 1)
+			sq = layout.Square('a', i+1, n)
+			drawString(img, sq.Min.X+2, sq.Min.Y+12, rank, overlayLabelColor)
+		}
+	}
+}
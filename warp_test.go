@@ -0,0 +1,286 @@
+package viamchess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/golang/geo/r2"
+
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/test"
+)
+
+func TestPerspectiveTransform(t *testing.T) {
+	// a 2x2 gradient, warped to 4x4: the whole of it is from -.5 to 1.5 each way
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, color.RGBA{0, 0, 0, 255})
+	src.SetRGBA(1, 0, color.RGBA{100, 0, 0, 255})
+	src.SetRGBA(0, 1, color.RGBA{50, 0, 0, 255})
+	src.SetRGBA(1, 1, color.RGBA{150, 0, 0, 255})
+	h, err := computeHomography([]r2.Point{{X: -.5, Y: -.5}, {X: 1.5, Y: -.5}, {X: 1.5, Y: 1.5}, {X: -.5, Y: 1.5}}, 4)
+	test.That(t, err, test.ShouldBeNil)
+
+	// pixel 1, 1 of the output is from .25, .25: a quarter of the way to each of the other three,
+	// (0*.75 + 100*.25)*.75 + (50*.75 + 150*.25)*.25 = 37.5
+	bilinear, err := perspectiveTransform(src, h, 4, 4, InterpolationBilinear)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, bilinear.RGBAAt(1, 1), test.ShouldResemble, color.RGBA{38, 0, 0, 255})
+	// past the middle of the edge pixels they're the edge's own
+	test.That(t, bilinear.RGBAAt(0, 0).R, test.ShouldEqual, 0)
+	test.That(t, bilinear.RGBAAt(3, 3).R, test.ShouldEqual, 150)
+	test.That(t, bilinear.RGBAAt(2, 1).R, test.ShouldEqual, 88)
+
+	nearest, err := perspectiveTransform(src, h, 4, 4, InterpolationNearest)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, nearest.RGBAAt(1, 1).R, test.ShouldEqual, 0)
+	test.That(t, nearest.RGBAAt(2, 1).R, test.ShouldEqual, 100)
+
+	// with the edges repeated the ramp flattens out at them, and Catmull-Rom follows it down:
+	// 20.3 along the top and 70.3 along the bottom, 30.5 between them
+	bicubic, err := perspectiveTransform(src, h, 4, 4, InterpolationBicubic)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, bicubic.RGBAAt(1, 1).R, test.ShouldEqual, 30)
+	// and it overshoots a little past the step into the corner
+	test.That(t, bicubic.RGBAAt(3, 3).R, test.ShouldEqual, 161)
+
+	// what isn't on the source is black, and any kind of image works
+	wide, err := computeHomography([]r2.Point{{X: -.5, Y: -.5}, {X: 3.5, Y: -.5}, {X: 3.5, Y: 3.5}, {X: -.5, Y: 3.5}}, 4)
+	test.That(t, err, test.ShouldBeNil)
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	gray.SetGray(1, 1, color.Gray{200})
+	out, err := perspectiveTransform(gray, wide, 4, 4, InterpolationBilinear)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out.RGBAAt(1, 1), test.ShouldResemble, color.RGBA{200, 200, 200, 255})
+	test.That(t, out.RGBAAt(3, 3), test.ShouldResemble, color.RGBA{0, 0, 0, 255})
+
+	square := []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	_, _, err = WarpBoard(src, square, WarpOptions{Width: 4, Interpolation: "lanczos"})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = WarpBoard(src, square, WarpOptions{})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = WarpBoard(src, square, WarpOptions{Width: 4, Margin: .5})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestWarpBoardSmoother(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	// the board's squares shrunk to 40 pixels, the ones nearest sampling skips make for speckled squares
+	variance := func(interp Interpolation) float64 {
+		warped, _, err := WarpBoard(input, corners, WarpOptions{Width: 320, Interpolation: interp})
+		test.That(t, err, test.ShouldBeNil)
+		return meanSquareVariance(warped, 8)
+	}
+	nearest, bilinear := variance(InterpolationNearest), variance(InterpolationBilinear)
+	t.Logf("square variance nearest %.1f, bilinear %.1f", nearest, bilinear)
+	test.That(t, bilinear, test.ShouldBeLessThan, nearest)
+	test.That(t, variance(""), test.ShouldEqual, bilinear)
+}
+
+func TestWarpBoardMargin(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	col, row := gridPosition('a', 1, Rotation0, 8)
+
+	tight, layout, err := WarpBoard(input, corners, WarpOptions{Width: 800})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, tight.Bounds(), test.ShouldResemble, image.Rect(0, 0, 800, 800))
+	test.That(t, layout.Board, test.ShouldResemble, image.Rect(0, 0, 800, 800))
+	test.That(t, layout.SquareBounds(col, row, 8), test.ShouldResemble, image.Rect(700, 0, 800, 100))
+
+	// with 5% each side the board is 720 across from 40, 40, a1 shifted in and a tenth smaller
+	warped, layout, err := WarpBoard(input, corners, WarpOptions{Width: 800, Margin: .05})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, warped.Bounds(), test.ShouldResemble, image.Rect(0, 0, 800, 800))
+	test.That(t, layout.Board, test.ShouldResemble, image.Rect(40, 40, 760, 760))
+	a1 := layout.SquareBounds(col, row, 8)
+	test.That(t, a1, test.ShouldResemble, image.Rect(670, 40, 760, 130))
+	test.That(t, layout.SquareBounds(7, 7, 8).Max, test.ShouldResemble, layout.Board.Max)
+	for i, c := range corners {
+		p := MapSourceToWarped(layout.Homography, r2.Point{X: float64(c.X), Y: float64(c.Y)})
+		want := layout.Corners().Slice()[i]
+		test.That(t, p.X, test.ShouldAlmostEqual, want.X, 1e-6)
+		test.That(t, p.Y, test.ShouldAlmostEqual, want.Y, 1e-6)
+	}
+	// and what's in a1 is what was in it without the margin
+	test.That(t, meanGreen(warped, a1), test.ShouldAlmostEqual, meanGreen(tight, image.Rect(700, 0, 800, 100)), 3)
+
+	// the width and height apart, the margin is of each
+	_, layout, err = WarpBoard(input, corners, WarpOptions{Width: 800, Height: 400, Margin: .05})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, layout.Board, test.ShouldResemble, image.Rect(40, 20, 760, 380))
+	test.That(t, layout.SquareBounds(col, row, 8), test.ShouldResemble, image.Rect(670, 20, 760, 65))
+}
+
+func TestWarpBoardRotation(t *testing.T) {
+	for _, tc := range []struct {
+		fn       string
+		rotation string
+		e2       image.Rectangle
+	}{
+		{"data/board5.jpg", "0", image.Rect(550, 100, 640, 190)},
+		{"data/board1.jpg", "180", image.Rect(650, 480, 740, 570)},
+	} {
+		input, err := rimage.ReadImageFromFile(tc.fn)
+		test.That(t, err, test.ShouldBeNil)
+		corners, err := findBoard(input)
+		test.That(t, err, test.ShouldBeNil)
+
+		for _, rotation := range []string{tc.rotation, "auto"} {
+			_, layout, err := WarpBoard(input, corners, WarpOptions{Width: 800, Margin: .05, Rotation: rotation})
+			test.That(t, err, test.ShouldBeNil)
+			// a1 is bottom left and e2 is back on e2 in the frame whichever way the camera is
+			test.That(t, layout.Rotation, test.ShouldEqual, Rotation180)
+			test.That(t, layout.Square('a', 1, 8), test.ShouldResemble, layout.SquareBounds(0, 7, 8))
+			e2 := layout.Square('e', 2, 8)
+			toFrame, err := layout.Homography.Inverse()
+			test.That(t, err, test.ShouldBeNil)
+			center := toFrame.Apply(r2.Point{X: float64(e2.Min.X+e2.Max.X) / 2, Y: float64(e2.Min.Y+e2.Max.Y) / 2})
+			t.Logf("%s rotation %s e2 center: %v", tc.fn, rotation, center)
+			test.That(t, image.Pt(int(center.X), int(center.Y)).In(tc.e2), test.ShouldBeTrue)
+		}
+	}
+
+	// left as the frame has it the corners are where they were, a quarter turn moves them round one
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	for _, tc := range []struct {
+		rotation string
+		first    int
+	}{{"", 0}, {"180", 0}, {"0", 2}, {"90", 3}, {"270", 1}} {
+		_, layout, err := WarpBoard(input, corners, WarpOptions{Width: 800, Rotation: tc.rotation})
+		test.That(t, err, test.ShouldBeNil)
+		for i, c := range layout.Corners().Slice() {
+			from := corners[(tc.first+i)%4]
+			p := MapSourceToWarped(layout.Homography, r2.Point{X: float64(from.X), Y: float64(from.Y)})
+			test.That(t, p.X, test.ShouldAlmostEqual, c.X, 1e-6)
+			test.That(t, p.Y, test.ShouldAlmostEqual, c.Y, 1e-6)
+		}
+	}
+
+	// the warper's table is for the one rotation
+	var w BoardWarper
+	for _, rotation := range []string{"", "0", "90"} {
+		opts := WarpOptions{Width: 400, Rotation: rotation}
+		got, layout, err := w.Warp(input, corners, opts)
+		test.That(t, err, test.ShouldBeNil)
+		want, wantLayout, err := WarpBoard(input, corners, opts)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, layout, test.ShouldResemble, wantLayout)
+		test.That(t, got.Pix, test.ShouldResemble, want.Pix)
+	}
+
+	_, _, err = WarpBoard(input, corners, WarpOptions{Width: 800, Rotation: "45"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+// meanGreen is the average green of r in img.
+func meanGreen(img *image.RGBA, r image.Rectangle) float64 {
+	sum := 0.0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sum += float64(img.RGBAAt(x, y).G)
+		}
+	}
+	return sum / float64(r.Dx()*r.Dy())
+}
+
+// meanSquareVariance is the variance of the green of the middle half of each of the n x n
+// squares of the warped board, averaged over them.
+func meanSquareVariance(warped *image.RGBA, n int) float64 {
+	size := warped.Bounds().Dx() / n
+	total := 0.0
+	for row := range n {
+		for col := range n {
+			count, sum, sumSq := 0.0, 0.0, 0.0
+			for y := row*size + size/4; y < row*size+3*size/4; y++ {
+				for x := col*size + size/4; x < col*size+3*size/4; x++ {
+					v := float64(warped.RGBAAt(x, y).G)
+					count++
+					sum += v
+					sumSq += v * v
+				}
+			}
+			m := sum / count
+			total += sumSq/count - m*m
+		}
+	}
+	return total / float64(n*n)
+}
+
+func TestBoardWarper(t *testing.T) {
+	input, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(t, err, test.ShouldBeNil)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+
+	var w BoardWarper
+	for _, interp := range []Interpolation{InterpolationNearest, InterpolationBilinear, InterpolationBicubic} {
+		opts := WarpOptions{Width: 400, Height: 360, Margin: .05, Interpolation: interp}
+		want, wantLayout, err := WarpBoard(input, corners, opts)
+		test.That(t, err, test.ShouldBeNil)
+		got, layout, err := w.Warp(input, corners, opts)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, layout, test.ShouldResemble, wantLayout)
+		test.That(t, got.Pix, test.ShouldResemble, want.Pix)
+	}
+	table := &w.off[0]
+
+	// a pixel's jitter keeps the table, and the view, of the corners it was made for
+	opts := WarpOptions{Width: 400, Height: 360, Margin: .05}
+	before, _, err := w.Warp(input, corners, opts)
+	test.That(t, err, test.ShouldBeNil)
+	jittered := []image.Point{{391, 47}, {965, 86}, {938, 665}, {347, 634}}
+	same, _, err := w.Warp(input, jittered, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, &w.off[0], test.ShouldEqual, table)
+	test.That(t, same.Pix, test.ShouldResemble, before.Pix)
+
+	// more than that, or another layout, makes it again
+	moved := []image.Point{{393, 48}, {965, 85}, {939, 665}, {347, 635}}
+	got, layout, err := w.Warp(input, moved, opts)
+	test.That(t, err, test.ShouldBeNil)
+	want, wantLayout, err := WarpBoard(input, moved, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, layout, test.ShouldResemble, wantLayout)
+	test.That(t, got.Pix, test.ShouldResemble, want.Pix)
+
+	opts.Margin = 0
+	got, _, err = w.Warp(input, moved, opts)
+	test.That(t, err, test.ShouldBeNil)
+	want, _, err = WarpBoard(input, moved, opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, got.Pix, test.ShouldResemble, want.Pix)
+
+	_, _, err = w.Warp(input, moved, WarpOptions{Width: 400, Interpolation: "lanczos"})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func BenchmarkWarpBoard(b *testing.B) {
+	jpg, err := rimage.ReadImageFromFile("data/board1.jpg")
+	test.That(b, err, test.ShouldBeNil)
+	// already RGBA, so it's the warp being timed and not the JPEG's colors
+	input := image.NewRGBA(jpg.Bounds())
+	draw.Draw(input, input.Bounds(), jpg, image.Point{}, draw.Src)
+	corners := []image.Point{{390, 48}, {965, 85}, {939, 665}, {347, 635}}
+	opts := WarpOptions{Width: 800}
+
+	b.Run("uncached", func(b *testing.B) {
+		for b.Loop() {
+			_, _, err := WarpBoard(input, corners, opts)
+			test.That(b, err, test.ShouldBeNil)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		var w BoardWarper
+		for b.Loop() {
+			_, _, err := w.Warp(input, corners, opts)
+			test.That(b, err, test.ShouldBeNil)
+		}
+	})
+}
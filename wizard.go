@@ -0,0 +1,329 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/vision/viscapture"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// calibration is what the setup wizard learned, saved after every step so a half done
+// setup isn't lost. Nothing reads it back automatically, the values are meant for the config.
+type calibration struct {
+	Corners              [][]int `json:"corners,omitempty"`
+	OrientationConfirmed bool    `json:"orientation-confirmed"`
+	PieceScale           float64 `json:"piece-scale,omitempty"`
+
+	// Graveyard is where the first and last captured pieces will be put down.
+	Graveyard []r3.Vector `json:"graveyard,omitempty"`
+
+	SafeHeight    float64 `json:"safe-height,omitempty"`
+	MinGrabHeight float64 `json:"min-grab-height,omitempty"`
+	LiftClearance float64 `json:"lift-clearance,omitempty"`
+	MinLiftHeight float64 `json:"min-lift-height,omitempty"`
+
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// wizardStep is one step of setup. run checks the board looks like requires says, then does
+// the calibration. requires is a translation key, the instructions are "wizard.<name>".
+type wizardStep struct {
+	name     string
+	requires string
+	run      func(s *viamChessChess, ctx context.Context, data viscapture.VisCapture, cal *calibration) error
+}
+
+var wizardSteps = []wizardStep{
+	{
+		name:     "corners",
+		requires: "wizard.empty-board",
+		run:      (*viamChessChess).wizardCorners,
+	},
+	{
+		name:     "orientation",
+		requires: "wizard.starting-position",
+		run:      (*viamChessChess).wizardOrientation,
+	},
+	{
+		name:     "heights",
+		requires: "wizard.starting-position",
+		run:      (*viamChessChess).wizardHeights,
+	},
+	{
+		name:     "graveyard",
+		requires: "wizard.starting-position",
+		run:      (*viamChessChess).wizardGraveyard,
+	},
+	{
+		name:     "envelope",
+		requires: "wizard.starting-position",
+		run:      (*viamChessChess).wizardEnvelope,
+	},
+}
+
+// wizardRun is a setup in progress, step indexes wizardSteps.
+type wizardRun struct {
+	step int
+	cal  calibration
+}
+
+// wizardCommand runs {"wizard": "start" | "next" | "status" | "abort"}.
+func (s *viamChessChess) wizardCommand(ctx context.Context, action string) (map[string]interface{}, error) {
+	switch action {
+	case "start":
+		s.wizard = &wizardRun{}
+		return s.wizard.status(s.conf.Locale), nil
+	case "status":
+		if s.wizard == nil {
+			return map[string]interface{}{"active": false}, nil
+		}
+		return s.wizard.status(s.conf.Locale), nil
+	case "abort":
+		s.wizard = nil
+		return map[string]interface{}{"active": false, "aborted": true}, nil
+	case "next":
+	default:
+		return nil, fmt.Errorf("unknown wizard action %q, needs to be start, next, status or abort", action)
+	}
+
+	w := s.wizard
+	if w == nil {
+		return nil, fmt.Errorf("no wizard running, start one with {\"wizard\": \"start\"}")
+	}
+	step := wizardSteps[w.step]
+
+	data, err := s.capture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = step.run(s, ctx, data, &w.cal)
+	if err != nil {
+		return nil, fmt.Errorf("wizard step %s: %w", step.name, err)
+	}
+
+	w.step++
+	if w.step == len(wizardSteps) {
+		now := time.Now()
+		w.cal.Completed = &now
+	}
+	err = s.saveCalibration(w.cal)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := w.status(s.conf.Locale)
+	if w.step == len(wizardSteps) {
+		s.wizard = nil
+	}
+	return ret, nil
+}
+
+func (w *wizardRun) status(locale string) map[string]interface{} {
+	if w.step == len(wizardSteps) {
+		return map[string]interface{}{
+			"active":      true,
+			"done":        true,
+			"calibration": w.cal,
+		}
+	}
+	step := wizardSteps[w.step]
+	return map[string]interface{}{
+		"active":       true,
+		"done":         false,
+		"step":         step.name,
+		"index":        w.step + 1,
+		"of":           len(wizardSteps),
+		"requires":     localize(locale, step.requires),
+		"instructions": localize(locale, "wizard."+step.name),
+	}
+}
+
+func (s *viamChessChess) saveCalibration(cal calibration) error {
+	b, err := json.MarshalIndent(&cal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.calibrationFile, b, 0666)
+}
+
+// checkBoardEmpty errors, naming them, if any squares have pieces on them, or aren't in data.
+func checkBoardEmpty(locale string, data viscapture.VisCapture) error {
+	board, seen := captureBoard(data)
+	occupied := []string{}
+	for i, sq := range board {
+		if sq.Occupied || !seen[i] {
+			occupied = append(occupied, sq.Name)
+		}
+	}
+	if len(occupied) > 0 {
+		return errors.New(localize(locale, "mismatch.not-empty", strings.Join(occupied, " ")))
+	}
+	return nil
+}
+
+// checkStartingPosition errors, naming them, if any squares don't match the starting position.
+func checkStartingPosition(locale string, data viscapture.VisCapture) error {
+	board, seen := captureBoard(data)
+	want := func(rank int) int {
+		switch rank {
+		case 1, 2:
+			return 1
+		case 7, 8:
+			return 2
+		}
+		return 0
+	}
+
+	wrong, flipped := []string{}, 0
+	for i, sq := range board {
+		if sq.Color != want(sq.Rank) || !seen[i] {
+			wrong = append(wrong, sq.Name)
+		}
+		if sq.Color == want(9-sq.Rank) && sq.Occupied {
+			flipped++
+		}
+	}
+	if len(wrong) == 0 {
+		return nil
+	}
+	if flipped == 32 {
+		return errors.New(localize(locale, "mismatch.flipped"))
+	}
+	return errors.New(localize(locale, "mismatch.not-start", strings.Join(wrong, " ")))
+}
+
+func (s *viamChessChess) wizardCorners(ctx context.Context, data viscapture.VisCapture, cal *calibration) error {
+	err := checkBoardEmpty(s.conf.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.pieceFinder.DoCommand(ctx, map[string]interface{}{"debug": true})
+	if err != nil {
+		return err
+	}
+	if found, _ := res["found"].(bool); !found {
+		return fmt.Errorf("board not found (%v)", res["reason"])
+	}
+
+	cal.Corners = nil
+	corners, _ := res["corners"].([]interface{})
+	for _, c := range corners {
+		xy, ok := c.([]interface{})
+		if !ok || len(xy) != 2 {
+			return fmt.Errorf("bad corner %v from the piece finder", c)
+		}
+		x, okX := toFloat(xy[0])
+		y, okY := toFloat(xy[1])
+		if !okX || !okY {
+			return fmt.Errorf("bad corner %v from the piece finder", c)
+		}
+		cal.Corners = append(cal.Corners, []int{int(x), int(y)})
+	}
+	if len(cal.Corners) != 4 {
+		return fmt.Errorf("piece finder returned %d corners", len(cal.Corners))
+	}
+	return nil
+}
+
+func (s *viamChessChess) wizardOrientation(ctx context.Context, data viscapture.VisCapture, cal *calibration) error {
+	err := checkStartingPosition(s.conf.Locale, data)
+	if err != nil {
+		return err
+	}
+	cal.OrientationConfirmed = true
+	return nil
+}
+
+func (s *viamChessChess) wizardHeights(ctx context.Context, data viscapture.VisCapture, cal *calibration) error {
+	err := checkStartingPosition(s.conf.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.pieceFinder.DoCommand(ctx, map[string]interface{}{"density": "height"})
+	if err != nil {
+		return err
+	}
+	scale, ok := toFloat(res["suggested_piece_scale"])
+	if !ok || scale <= 0 {
+		return fmt.Errorf("piece finder didn't suggest a piece scale: %v", res["suggested_piece_scale"])
+	}
+	cal.PieceScale = scale
+	return nil
+}
+
+func (s *viamChessChess) wizardGraveyard(ctx context.Context, data viscapture.VisCapture, cal *calibration) error {
+	err := checkStartingPosition(s.conf.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	cal.Graveyard = nil
+	for _, pos := range []int{0, 15} {
+		p, err := s.graveyardPosition(data, pos)
+		if err != nil {
+			return err
+		}
+		err = s.moveGripper(ctx, r3.Vector{X: p.X, Y: p.Y, Z: s.conf.safeHeight()})
+		if err != nil {
+			return fmt.Errorf("can't reach graveyard spot %d at %v: %w", pos, p, err)
+		}
+		cal.Graveyard = append(cal.Graveyard, p)
+	}
+	return nil
+}
+
+func (s *viamChessChess) wizardEnvelope(ctx context.Context, data viscapture.VisCapture, cal *calibration) error {
+	err := checkStartingPosition(s.conf.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	boards, tallest := []float64{}, math.Inf(-1)
+	for _, o := range data.Objects {
+		if strings.HasSuffix(o.Geometry.Label(), "-0") {
+			md := o.MetaData()
+			boards = append(boards, md.Center().Z)
+			continue
+		}
+		tallest = math.Max(tallest, touch.PCFindHighestInRegion(o, image.Rect(-1000, -1000, 1000, 1000)).Z)
+	}
+	sort.Float64s(boards)
+	board := boards[len(boards)/2]
+
+	// a piece carried over the tallest one hangs its own height under the gripper
+	need := board + 2*(tallest-board) + s.conf.liftClearance()
+	if s.conf.safeHeight() < need {
+		return fmt.Errorf("safe-height %v is too low, carrying a piece over the tallest one needs %.0f", s.conf.safeHeight(), need)
+	}
+
+	cal.SafeHeight = s.conf.safeHeight()
+	cal.MinGrabHeight = s.conf.minGrabHeight()
+	cal.LiftClearance = s.conf.liftClearance()
+	cal.MinLiftHeight = s.conf.minLiftHeight()
+	return nil
+}
+
+// toFloat is v as a float64, whatever kind of number DoCommand handed back.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+package viamchess
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/test"
+)
+
+// startingPosition is the fake robot's occupied map for a freshly set up board.
+func startingPosition(flipped bool) map[string]int {
+	white, black := 1, 2
+	if flipped {
+		white, black = black, white
+	}
+	occupied := map[string]int{}
+	for _, name := range squareNames {
+		switch name[1] {
+		case '1', '2':
+			occupied[name] = white
+		case '7', '8':
+			occupied[name] = black
+		}
+	}
+	return occupied
+}
+
+func TestWizard(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	pf := s.pieceFinder.(*inject.VisionService)
+	pf.DoCommandFunc = func(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+		if _, ok := cmd["debug"]; ok {
+			return map[string]interface{}{
+				"found":   true,
+				"corners": []interface{}{[]interface{}{390, 48}, []interface{}{965, 85}, []interface{}{939, 665}, []interface{}{347, 635}},
+			}, nil
+		}
+		return map[string]interface{}{"suggested_piece_scale": 1.1}, nil
+	}
+
+	wizard := func(action string) (map[string]interface{}, error) {
+		return s.DoCommand(ctx, map[string]interface{}{"wizard": action})
+	}
+
+	res, err := wizard("status")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["active"], test.ShouldBeFalse)
+	_, err = wizard("next")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = wizard("sideways")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	res, err = wizard("start")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["step"], test.ShouldEqual, "corners")
+	test.That(t, res["requires"], test.ShouldEqual, "empty board")
+
+	// a step whose board isn't ready says what's wrong and stays put
+	f.occupied = map[string]int{"e2": 1}
+	_, err = wizard("next")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "e2")
+	res, err = wizard("status")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["step"], test.ShouldEqual, "corners")
+
+	f.occupied = map[string]int{}
+	res, err = wizard("next")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["step"], test.ShouldEqual, "orientation")
+	test.That(t, res["index"], test.ShouldEqual, 2)
+
+	f.occupied = startingPosition(true)
+	_, err = wizard("next")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "rotation")
+
+	f.occupied = startingPosition(false)
+	for _, step := range []string{"heights", "graveyard", "envelope"} {
+		res, err = wizard("next")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res["step"], test.ShouldEqual, step)
+	}
+	res, err = wizard("next")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["done"], test.ShouldBeTrue)
+
+	res, err = wizard("status")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["active"], test.ShouldBeFalse)
+
+	// the arm checked it can get to both ends of the graveyard
+	a8, a1 := fakeSquareCenter("a8"), fakeSquareCenter("a1")
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: a8.X, Y: a8.Y - 80, Z: safeZ})), test.ShouldBeGreaterThanOrEqualTo, 0)
+	test.That(t, f.indexOf(fakeMoveEvent(r3.Vector{X: a1.X, Y: a1.Y - 160, Z: safeZ})), test.ShouldBeGreaterThanOrEqualTo, 0)
+
+	b, err := os.ReadFile(s.calibrationFile)
+	test.That(t, err, test.ShouldBeNil)
+	var cal calibration
+	test.That(t, json.Unmarshal(b, &cal), test.ShouldBeNil)
+	test.That(t, cal.Corners, test.ShouldResemble, [][]int{{390, 48}, {965, 85}, {939, 665}, {347, 635}})
+	test.That(t, cal.OrientationConfirmed, test.ShouldBeTrue)
+	test.That(t, cal.PieceScale, test.ShouldEqual, 1.1)
+	test.That(t, cal.Graveyard, test.ShouldResemble, []r3.Vector{{X: a8.X, Y: a8.Y - 80, Z: 60}, {X: a1.X, Y: a1.Y - 160, Z: 60}})
+	test.That(t, cal.SafeHeight, test.ShouldEqual, safeZ)
+	test.That(t, cal.LiftClearance, test.ShouldEqual, defaultLiftClearance)
+	test.That(t, cal.Completed, test.ShouldNotBeNil)
+}
+
+func TestWizardAbortAndEnvelope(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	s.wizard = &wizardRun{step: len(wizardSteps) - 1}
+	res, err := s.DoCommand(ctx, map[string]interface{}{"wizard": "status"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["step"], test.ShouldEqual, "envelope")
+
+	// the fake pieces are 40mm, carrying one over another needs 110mm
+	f.occupied = startingPosition(false)
+	s.conf.SafeHeight = 100
+	_, err = s.DoCommand(ctx, map[string]interface{}{"wizard": "next"})
+	test.That(t, err.Error(), test.ShouldContainSubstring, "safe-height")
+
+	res, err = s.DoCommand(ctx, map[string]interface{}{"wizard": "abort"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["aborted"], test.ShouldBeTrue)
+	test.That(t, s.wizard, test.ShouldBeNil)
+	_, err = os.Stat(s.calibrationFile)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
+
+func TestWizardStatusDoesntMove(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestChess(t)
+
+	// a piece left in the gripper is recovered by the next command that moves, not these
+	s.held = &heldPiece{from: "e2", to: "e4", z: 30}
+	f.holding = true
+
+	for _, action := range []string{"start", "status", "abort"} {
+		_, err := s.DoCommand(ctx, map[string]interface{}{"wizard": action})
+		test.That(t, err, test.ShouldBeNil)
+	}
+	test.That(t, f.events, test.ShouldBeEmpty)
+	test.That(t, s.held, test.ShouldNotBeNil)
+}